@@ -32,9 +32,10 @@ func (r *RpcReader) Read(p []byte) (n int, err error) {
 	// Relay call
 	n, err = r.Reader.Read(p)
 
-	// Scan for events
-	if err != nil {
-		r.scan(p)
+	// Scan for events (in the data that was read, not the rest of
+	// the buffer, which may hold stale data from earlier reads)
+	if n > 0 {
+		r.scan(p[:n])
 	}
 
 	return n, err
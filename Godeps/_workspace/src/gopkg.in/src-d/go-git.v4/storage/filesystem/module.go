@@ -0,0 +1,20 @@
+package filesystem
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+)
+
+type ModuleStorage struct {
+	dir *dotgit.DotGit
+}
+
+func (s *ModuleStorage) Module(name string) (storage.Storer, error) {
+	fs, err := s.dir.Module(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorage(fs, cache.NewObjectLRUDefault()), nil
+}
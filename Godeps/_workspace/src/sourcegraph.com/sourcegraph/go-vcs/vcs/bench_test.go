@@ -2,14 +2,17 @@ package vcs_test
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
 
 	"golang.org/x/tools/godoc/vfs"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/bzrcmd"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/git"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gogit"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/hg"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/hgcmd"
 )
@@ -53,6 +56,83 @@ func BenchmarkFileSystem_GitCmd(b *testing.B) {
 	}
 }
 
+// BenchmarkFileSystem_GitCmdNoCatFileBatch measures the gitcmd
+// FileSystem when it runs git once per file, for comparison with
+// BenchmarkFileSystem_GitCmd.
+func BenchmarkFileSystem_GitCmdNoCatFileBatch(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	gitcmd.CatFileBatch = false
+	defer func() { gitcmd.CatFileBatch = true }()
+
+	cmds, files := makeGitCommandsAndFiles(benchFileSystemCommits)
+	r, err := gitcmd.Open(initGitRepository(b, cmds...))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchFileSystem(b, r, "mytag", files)
+	}
+}
+
+// BenchmarkFileSystem_GitCmdLargeBlob measures reading a large file
+// with the gitcmd FileSystem (see the allocations per op).
+func BenchmarkFileSystem_GitCmdLargeBlob(b *testing.B) {
+	r, err := gitcmd.Open(initGitRepository(b,
+		"head -c 67108864 /dev/zero | tr '\\0' x > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2014-05-06T19:20:21Z git commit -m commit --author='a <a@a.com>' --date 2014-05-06T19:20:21Z",
+	))
+	if err != nil {
+		b.Fatal(err)
+	}
+	commitID, err := r.ResolveRevision("master")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fs, err := r.FileSystem(commitID)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := fs.Open("f")
+		if err != nil {
+			b.Fatal(err)
+		}
+		n, err := io.Copy(ioutil.Discard, f)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(n)
+	}
+}
+
+func BenchmarkFileSystem_GitGoGit(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, files := makeGitCommandsAndFiles(benchFileSystemCommits)
+	r := makeGitRepositoryGoGit(b, cmds...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchFileSystem(b, r, "mytag", files)
+	}
+}
+
 func BenchmarkFileSystem_HgNative(b *testing.B) {
 	defer func() {
 		b.StopTimer()
@@ -86,6 +166,21 @@ func BenchmarkFileSystem_HgCmd(b *testing.B) {
 	}
 }
 
+func BenchmarkFileSystem_BzrCmd(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, files := makeBzrCommandsAndFiles(benchFileSystemCommits)
+	r := makeBzrRepositoryCmd(b, cmds...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchFileSystem(b, r, "mytag", files)
+	}
+}
+
 func BenchmarkGetCommit_GitLibGit2(b *testing.B) {
 	defer func() {
 		b.StopTimer()
@@ -129,6 +224,28 @@ func BenchmarkGetCommit_GitCmd(b *testing.B) {
 	}
 }
 
+func BenchmarkGetCommit_GitGoGit(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, _ := makeGitCommandsAndFiles(benchGetCommitCommits)
+	r := makeGitRepositoryGoGit(b, cmds...)
+	openRepo := func() benchRepository {
+		r, err := gogit.Open(r.Dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchGetCommit(b, openRepo, "mytag")
+	}
+}
+
 func BenchmarkGetCommit_HgNative(b *testing.B) {
 	defer func() {
 		b.StopTimer()
@@ -173,6 +290,28 @@ func BenchmarkGetCommit_HgCmd(b *testing.B) {
 	}
 }
 
+func BenchmarkGetCommit_BzrCmd(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, _ := makeBzrCommandsAndFiles(benchGetCommitCommits)
+	r := makeBzrRepositoryCmd(b, cmds...)
+	openRepo := func() benchRepository {
+		r, err := bzrcmd.Open(r.Dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchGetCommit(b, openRepo, "mytag")
+	}
+}
+
 func BenchmarkCommits_GitLibGit2(b *testing.B) {
 	defer func() {
 		b.StopTimer()
@@ -216,6 +355,28 @@ func BenchmarkCommits_GitCmd(b *testing.B) {
 	}
 }
 
+func BenchmarkCommits_GitGoGit(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, _ := makeGitCommandsAndFiles(benchCommitsCommits)
+	r := makeGitRepositoryGoGit(b, cmds...)
+	openRepo := func() benchRepository {
+		r, err := gogit.Open(r.Dir)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchCommits(b, openRepo, "mytag")
+	}
+}
+
 func BenchmarkCommits_HgNative(b *testing.B) {
 	defer func() {
 		b.StopTimer()
@@ -259,6 +420,27 @@ func BenchmarkCommits_HgCmd(b *testing.B) {
 	}
 }
 
+func BenchmarkCommits_BzrCmd(b *testing.B) {
+	defer func() {
+		b.StopTimer()
+		b.StartTimer()
+	}()
+
+	cmds, _ := makeBzrCommandsAndFiles(benchCommitsCommits)
+	openRepo := func() benchRepository {
+		r, err := bzrcmd.Open(initBzrRepository(b, cmds...))
+		if err != nil {
+			b.Fatal(err)
+		}
+		return r
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchCommits(b, openRepo, "mytag")
+	}
+}
+
 func makeGitCommandsAndFiles(n int) (cmds, files []string) {
 	for i := 0; i < n; i++ {
 		name := benchFilename(i)
@@ -289,6 +471,21 @@ func makeHgCommandsAndFiles(n int) (cmds []string, files []string) {
 	return cmds, files
 }
 
+func makeBzrCommandsAndFiles(n int) (cmds []string, files []string) {
+	for i := 0; i < n; i++ {
+		name := benchFilename(i)
+		files = append(files, name)
+		cmds = append(cmds,
+			fmt.Sprintf("mkdir -p %s", filepath.Dir(name)),
+			fmt.Sprintf("echo hello%d >> %s", i, name),
+			fmt.Sprintf("bzr add -q %s", name),
+			fmt.Sprintf("bzr commit -q -m hello%d --author='a <a@a.com>' --commit-time='2014-05-06 19:20:21 +0000'", i),
+		)
+	}
+	cmds = append(cmds, "bzr tag -q mytag")
+	return cmds, files
+}
+
 func benchFilename(i int) string {
 	switch i % 4 {
 	case 0:
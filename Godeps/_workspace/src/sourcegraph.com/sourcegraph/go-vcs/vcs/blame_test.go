@@ -29,6 +29,11 @@ func TestRepository_BlameFile(t *testing.T) {
 		"hg add f",
 		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
 	}
+	hgEmptyFileCommands := []string{
+		"touch f",
+		"hg add f",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
 	tests := map[string]struct {
 		repo interface {
 			vcs.Blamer
@@ -90,6 +95,14 @@ func TestRepository_BlameFile(t *testing.T) {
 				},
 			},
 		},
+		"hg cmd empty file": {
+			repo: makeHgRepositoryCmd(t, hgEmptyFileCommands...),
+			path: "f",
+			opt: &vcs.BlameOptions{
+				NewestCommit: "tip",
+			},
+			wantHunks: nil,
+		},
 	}
 
 	for label, test := range tests {
@@ -111,3 +124,123 @@ func TestRepository_BlameFile(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_BlameFile_timeZone(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo line1 > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05-0130",
+	}
+	tests := map[string]struct {
+		repo vcs.Blamer
+	}{
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, cmds...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, cmds...)},
+	}
+
+	for label, test := range tests {
+		hunks, err := test.repo.BlameFile("f", &vcs.BlameOptions{NewestCommit: "master"})
+		if err != nil {
+			t.Errorf("%s: BlameFile: %s", label, err)
+			continue
+		}
+		if len(hunks) != 1 {
+			t.Errorf("%s: got %d hunks, want 1", label, len(hunks))
+			continue
+		}
+		if got, want := hunks[0].Author.Time().Format(time.RFC3339), "2006-01-02T15:04:05-01:30"; got != want {
+			t.Errorf("%s: got author time %s, want %s", label, got, want)
+		}
+	}
+}
+
+func TestRepository_BlameFile_mailmap(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo 'a <a@a.com> <a@old.com>' > .mailmap",
+		"echo line1 > f",
+		"git add .mailmap f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='old <a@old.com>' --date 2006-01-02T15:04:05Z",
+	}
+	tests := map[string]struct {
+		repo vcs.Blamer
+	}{
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, cmds...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, cmds...)},
+	}
+
+	for label, test := range tests {
+		hunks, err := test.repo.BlameFile("f", &vcs.BlameOptions{NewestCommit: "master", UseMailmap: true})
+		if err != nil {
+			t.Errorf("%s: BlameFile: %s", label, err)
+			continue
+		}
+		if len(hunks) != 1 {
+			t.Errorf("%s: got %d hunks, want 1", label, len(hunks))
+			continue
+		}
+		if got, want := hunks[0].Author.Name+" <"+hunks[0].Author.Email+">", "a <a@a.com>"; got != want {
+			t.Errorf("%s: got author %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestRepository_BlameFile_ignoreRevs(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo \"x = 'a'\" > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo 'x = \"a\"' > f",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -am reformat --author='b <b@b.com>' --date 2006-01-02T15:04:06Z",
+		"git tag reformat",
+		"git rev-parse HEAD > .git-blame-ignore-revs",
+		"git add .git-blame-ignore-revs",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m ignore --author='b <b@b.com>' --date 2006-01-02T15:04:07Z",
+	}
+	repos := map[string]interface {
+		vcs.Blamer
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git libgit2": makeGitRepositoryLibGit2(t, cmds...),
+		"git cmd":     makeGitRepositoryCmd(t, cmds...),
+	}
+
+	for repoLabel, repo := range repos {
+		reformat, err := repo.ResolveRevision("reformat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := map[string]struct {
+			opt        *vcs.BlameOptions
+			wantAuthor string
+		}{
+			"no IgnoreRevs":  {opt: &vcs.BlameOptions{NewestCommit: "master"}, wantAuthor: "b"},
+			"IgnoreRevs":     {opt: &vcs.BlameOptions{NewestCommit: "master", IgnoreRevs: []vcs.CommitID{reformat}}, wantAuthor: "a"},
+			"IgnoreRevsFile": {opt: &vcs.BlameOptions{NewestCommit: "master", IgnoreRevsFile: ".git-blame-ignore-revs"}, wantAuthor: "a"},
+		}
+		for label, test := range tests {
+			label = repoLabel + " " + label
+			hunks, err := repo.BlameFile("f", test.opt)
+			if err != nil {
+				t.Errorf("%s: BlameFile: %s", label, err)
+				continue
+			}
+			if len(hunks) != 1 {
+				t.Errorf("%s: got %d hunks, want 1", label, len(hunks))
+				continue
+			}
+			if got := hunks[0].Author.Name; got != test.wantAuthor {
+				t.Errorf("%s: got author %q, want %q", label, got, test.wantAuthor)
+			}
+		}
+
+		if _, err := repo.BlameFile("f", &vcs.BlameOptions{NewestCommit: "master", IgnoreRevs: []vcs.CommitID{"--output=x"}}); err == nil {
+			t.Errorf("%s: BlameFile with unsafe IgnoreRevs: got nil error", repoLabel)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package bzrcmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
+)
+
+func init() {
+	vcs.RegisterOpener("bzr", func(dir string) (vcs.Repository, error) {
+		return Open(dir)
+	})
+	vcs.RegisterCloner("bzr", func(url, dir string, opt vcs.CloneOpt) (vcs.Repository, error) {
+		return CloneBzrRepository(url, dir, opt)
+	})
+}
+
+func CloneBzrRepository(url, dir string, opt vcs.CloneOpt) (*Repository, error) {
+	args := []string{"branch", "--use-existing-dir"}
+	if opt.Bare {
+		args = append(args, "--no-tree")
+	}
+	args = append(args, "--", url, dir)
+	cmd := exec.Command("bzr", args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("exec `bzr branch` failed: %s. Output was:\n\n%s", err, out)
+	}
+	return Open(dir)
+}
+
+func Open(dir string) (*Repository, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".bzr")); err != nil {
+		// All bzr branches (with or without a working tree) have a
+		// ".bzr" directory.
+		return nil, &os.PathError{
+			Op:   "Open",
+			Path: filepath.Join(dir, ".bzr"),
+			Err:  errors.New("Bazaar repository not found."),
+		}
+	}
+	return &Repository{Dir: dir}, nil
+}
+
+// Repository is a Bazaar branch. Commit IDs are bzr revision IDs
+// (such as "a@a.com-20140506192021-k3m1bqv0ynxqm3ez"), not revision
+// numbers, because revision numbers change when a branch's mainline
+// changes.
+type Repository struct {
+	Dir string
+
+	editLock sync.RWMutex // protects ops that change repository data
+}
+
+// bzrNullRevisionID is the revision ID of the (empty) revision
+// before a branch's first revision.
+const bzrNullRevisionID = "null:"
+
+// revSpec returns the bzr revision specifier for the commit ID.
+func revSpec(id vcs.CommitID) string { return "revid:" + string(id) }
+
+func isUnknownRevisionError(output []byte) bool {
+	return bytes.Contains(output, []byte("does not exist in branch")) ||
+		bytes.Contains(output, []byte("No such tag"))
+}
+
+// revnoPattern matches a bzr revision number, such as "3" (on the
+// mainline), "1.2.1" (merged from another branch), or "-1" (counting
+// back from the branch tip).
+var revnoPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)*$`)
+
+// checkRevSpec returns vcs.ErrRevisionNotFound unless spec is a
+// revision number or a "revid:", "tag:", or "date:" revision spec of a
+// single revision. Other bzr revision specs (such as "branch:" and
+// "ancestor:") name another branch, which bzr would open from any
+// path or fetch from any URL.
+func checkRevSpec(spec string) error {
+	if strings.Contains(spec, "..") { // a range
+		return vcs.ErrRevisionNotFound
+	}
+	if revnoPattern.MatchString(spec) {
+		return nil
+	}
+	for _, prefix := range []string{"revid:", "tag:", "date:"} {
+		if strings.HasPrefix(spec, prefix) && len(spec) > len(prefix) {
+			return nil
+		}
+	}
+	return vcs.ErrRevisionNotFound
+}
+
+func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	if err := checkRevSpec(spec); err != nil {
+		return "", err
+	}
+	cmd := exec.Command("bzr", "revision-info", "--revision="+spec)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if isUnknownRevisionError(out) {
+			return "", vcs.ErrRevisionNotFound
+		}
+		return "", fmt.Errorf("exec `bzr revision-info` failed: %s. Output was:\n\n%s", err, bytes.TrimSpace(out))
+	}
+
+	// format: "REVNO REVID"
+	fields := bytes.Fields(out)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("unexpected `bzr revision-info` output: %q", out)
+	}
+	if string(fields[1]) == bzrNullRevisionID {
+		return "", vcs.ErrRevisionNotFound
+	}
+	return vcs.CommitID(fields[1]), nil
+}
+
+func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
+	commitID, err := r.ResolveRevision("tag:" + name)
+	if err == vcs.ErrRevisionNotFound {
+		return "", vcs.ErrTagNotFound
+	}
+	return commitID, err
+}
+
+// ResolveBranch resolves the branch's own name (its nick). A bzr
+// branch is a directory, so no other branch names exist.
+func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
+	branches, err := r.Branches(vcs.BranchesOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, b := range branches {
+		if b.Name == name {
+			return b.Head, nil
+		}
+	}
+	return "", vcs.ErrBranchNotFound
+}
+
+// Branches returns the repository's only branch, named by its nick
+// (or no branches if it has no revisions).
+func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	if opt.ContainsCommit != "" {
+		return nil, fmt.Errorf("vcs.BranchesOptions.ContainsCommit option not implemented")
+	}
+
+	cmd := exec.Command("bzr", "nick")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("exec `bzr nick` failed: %s. Output was:\n\n%s", err, out)
+	}
+	name := string(bytes.TrimSpace(out))
+
+	head, err := r.ResolveRevision("-1")
+	if err == vcs.ErrRevisionNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return []*vcs.Branch{{Name: name, Head: head}}, nil
+}
+
+func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	cmd := exec.Command("bzr", "tags", "--show-ids", "--sort=natural")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("exec `bzr tags` failed: %s. Output was:\n\n%s", err, out)
+	}
+
+	var tags []*vcs.Tag
+	for _, line := range bytes.Split(bytes.TrimSuffix(out, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		// format: "NAME      REVID" (arbitrary amount of whitespace
+		// between NAME and REVID)
+		i := bytes.LastIndexAny(line, " \t")
+		if i == -1 {
+			return nil, fmt.Errorf("unexpectedly no whitespace in line in `bzr tags` output: %q", line)
+		}
+		tags = append(tags, &vcs.Tag{
+			Name:     string(bytes.TrimRight(line[:i], " \t")),
+			CommitID: vcs.CommitID(line[i+1:]),
+		})
+	}
+	return tags, nil
+}
+
+func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	out, err := r.log("--limit=1", "--revision="+revSpec(id))
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseBzrLog(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) != 1 {
+		return nil, fmt.Errorf("bzr log: expected 1 commit, got %d", len(commits))
+	}
+	return commits[0], nil
+}
+
+// Commits lists the commits reachable from opt.Head, including
+// revisions merged into the mainline. bzr log can't skip or count
+// revisions itself, so all matching revisions are read and N and
+// Skip are applied afterwards.
+func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Cursor != "" {
+		return nil, 0, errors.New("bzr: commits cursor not supported")
+	}
+	if opt.Follow {
+		return nil, 0, errors.New("bzr: commits follow not supported")
+	}
+	if opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() {
+		return nil, 0, errors.New("bzr: commits author and date filters not supported")
+	}
+
+	args := []string{"--levels=0", "--revision=.." + revSpec(opt.Head)}
+	if opt.Path != "" {
+		args = append(args, "--", internal.Rel(opt.Path))
+	}
+	out, err := r.log(args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	commits, err := parseBzrLog(out)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if opt.Base != "" {
+		out, err := r.log("--levels=0", "--revision=.."+revSpec(opt.Base))
+		if err != nil {
+			return nil, 0, err
+		}
+		baseCommits, err := parseBzrLog(out)
+		if err != nil {
+			return nil, 0, err
+		}
+		exclude := make(map[vcs.CommitID]struct{}, len(baseCommits))
+		for _, c := range baseCommits {
+			exclude[c.ID] = struct{}{}
+		}
+		included := commits[:0]
+		for _, c := range commits {
+			if _, ok := exclude[c.ID]; !ok {
+				included = append(included, c)
+			}
+		}
+		commits = included
+	}
+
+	var total uint
+	if !opt.NoTotal {
+		total = uint(len(commits))
+	}
+	if opt.Skip >= uint(len(commits)) {
+		return nil, total, nil
+	}
+	commits = commits[opt.Skip:]
+	if opt.N != 0 && opt.N < uint(len(commits)) {
+		commits = commits[:opt.N]
+	}
+	return commits, total, nil
+}
+
+// log runs `bzr log` with revision IDs and the original timezone of
+// each revision, plus args.
+func (r *Repository) log(args ...string) ([]byte, error) {
+	cmd := exec.Command("bzr", append([]string{"log", "--long", "--show-ids", "--timezone=original"}, args...)...)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if isUnknownRevisionError(out) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec `bzr log` failed: %s. Output was:\n\n%s", err, bytes.TrimSpace(out))
+	}
+	return out, nil
+}
+
+// bzrLogSeparator begins each revision in `bzr log --long` output
+// (indented by 4 spaces per merge level).
+var bzrLogSeparator = strings.Repeat("-", 60)
+
+// bzrTimestampLayout is the time layout of the "timestamp:" field in
+// `bzr log --long` output.
+const bzrTimestampLayout = "Mon 2006-01-02 15:04:05 -0700"
+
+// parseBzrLog parses the output of `bzr log --long --show-ids
+// --timezone=original`, including the indented revisions merged into
+// each mainline revision if --levels=0 was given.
+func parseBzrLog(out []byte) ([]*vcs.Commit, error) {
+	var (
+		commits   []*vcs.Commit
+		indent    string // indentation of the current revision's lines
+		inMessage bool
+		message   []string
+		committer string
+		author    string
+		timestamp time.Time
+	)
+	finish := func() error {
+		if len(commits) == 0 {
+			return nil
+		}
+		c := commits[len(commits)-1]
+		if c.ID == "" {
+			return errors.New("bzr log: revision has no revision-id")
+		}
+		if author == "" {
+			author = committer
+		}
+		name, email := parseBzrUser(author)
+		c.Author = vcs.NewSignature(name, email, timestamp)
+		if committer != "" {
+			name, email := parseBzrUser(committer)
+			cs := vcs.NewSignature(name, email, timestamp)
+			c.Committer = &cs
+		}
+		c.Message = strings.TrimRight(strings.Join(message, "\n"), "\n")
+		c.Subject, c.Body = vcs.SplitMessage(c.Message)
+		return nil
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, 16*1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if trimmed := strings.TrimLeft(line, " "); trimmed == bzrLogSeparator {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			commits = append(commits, &vcs.Commit{})
+			indent = line[:len(line)-len(trimmed)]
+			inMessage, message, committer, author, timestamp = false, nil, "", "", time.Time{}
+			continue
+		}
+		if len(commits) == 0 {
+			continue
+		}
+		line = strings.TrimPrefix(line, indent)
+
+		if inMessage {
+			message = append(message, strings.TrimPrefix(line, "  "))
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		key, value := line[:i], strings.TrimSpace(line[i+1:])
+		c := commits[len(commits)-1]
+		switch key {
+		case "revision-id":
+			c.ID = vcs.CommitID(value)
+		case "parent":
+			c.Parents = append(c.Parents, vcs.CommitID(value))
+		case "committer":
+			committer = value
+		case "author", "authors":
+			// Use the first of multiple ", "-separated authors.
+			author = strings.SplitN(value, ", ", 2)[0]
+		case "timestamp":
+			var err error
+			timestamp, err = time.Parse(bzrTimestampLayout, value)
+			if err != nil {
+				return nil, err
+			}
+		case "message":
+			inMessage = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// parseBzrUser splits a bzr user (usually of the form "Name <email>")
+// into a name and email.
+func parseBzrUser(user string) (name, email string) {
+	if i := strings.Index(user, "<"); i != -1 {
+		email = user[i+1:]
+		if j := strings.Index(email, ">"); j != -1 {
+			email = email[:j]
+		}
+		name = strings.TrimSpace(user[:i])
+		if name == "" {
+			name = email
+		}
+		return name, email
+	}
+	if i := strings.Index(user, "@"); i != -1 {
+		return user[:i], user
+	}
+	return user, ""
+}
+
+func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) error {
+	if opt.SSH != nil {
+		return fmt.Errorf("bzrcmd: ssh remote not supported")
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	cmd := exec.Command("bzr", "pull", "--overwrite")
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("exec `bzr pull` failed: %s. Output was:\n\n%s", err, out)
+	}
+	return nil
+}
+
+func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
+	return nil, fmt.Errorf("Committers() not implemented for vcs type: bzr")
+}
+
+func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	return &bzrFSCmd{
+		repo: r,
+		at:   at,
+	}, nil
+}
+
+type bzrFSCmd struct {
+	repo *Repository
+	at   vcs.CommitID
+}
+
+// isNotExistError returns whether output is bzr's error message for
+// a path that is not in the revision.
+func isNotExistError(output []byte) bool {
+	return bytes.Contains(output, []byte("is not present in revision")) ||
+		bytes.Contains(output, []byte("not versioned"))
+}
+
+func (fs *bzrFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
+	name = internal.Rel(name)
+	cmd := exec.Command("bzr", "cat", "--revision="+revSpec(fs.at), "--", name)
+	cmd.Dir = fs.repo.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if isNotExistError(stderr.Bytes()) {
+			return nil, os.ErrNotExist
+		}
+		if isUnknownRevisionError(stderr.Bytes()) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec `bzr cat` failed: %s. Output was:\n\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return util.NopCloser{ReadSeeker: bytes.NewReader(out)}, nil
+}
+
+func (fs *bzrFSCmd) Lstat(path string) (os.FileInfo, error) {
+	return fs.stat(path)
+}
+
+func (fs *bzrFSCmd) Stat(path string) (os.FileInfo, error) {
+	// TODO(sqs): follow symlinks (as Stat is required to do)
+	return fs.stat(path)
+}
+
+func (fs *bzrFSCmd) stat(path string) (os.FileInfo, error) {
+	path = filepath.Clean(internal.Rel(path))
+
+	var fi *util.FileInfo
+	if path == "." {
+		fi = &util.FileInfo{Name_: ".", Mode_: os.ModeDir}
+	} else {
+		// bzr has no command to stat a single path, so find the
+		// path's entry in its parent dir.
+		entries, err := fs.ReadDir(filepath.Dir(path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Name() == filepath.Base(path) {
+				fi = e.(*util.FileInfo)
+				break
+			}
+		}
+		if fi == nil {
+			return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+		}
+	}
+
+	if fi.Mode_.IsRegular() {
+		f, err := fs.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		fi.Size_ = int64(len(data))
+	}
+
+	// The mtime is the time of the last revision that changed the
+	// path.
+	args := []string{"--limit=1", "--revision=.." + revSpec(fs.at)}
+	if path != "." {
+		args = append(args, "--", path)
+	}
+	out, err := fs.repo.log(args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseBzrLog(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 1 {
+		fi.ModTime_ = commits[0].Author.Date.Time()
+	}
+
+	return fi, nil
+}
+
+func (fs *bzrFSCmd) ReadDir(path string) ([]os.FileInfo, error) {
+	path = filepath.Clean(internal.Rel(path))
+	args := []string{"ls", "--from-root", "--revision=" + revSpec(fs.at)}
+	if path != "." {
+		args = append(args, "--", path)
+	}
+	cmd := exec.Command("bzr", args...)
+	cmd.Dir = fs.repo.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if isNotExistError(out) {
+			return nil, &os.PathError{Op: "readdir", Path: path, Err: os.ErrNotExist}
+		}
+		if isUnknownRevisionError(out) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec `bzr ls` failed: %s. Output was:\n\n%s", err, out)
+	}
+
+	var prefix string
+	if path != "." {
+		prefix = path + "/"
+	}
+	var fis []os.FileInfo
+	for _, name := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if name == "" {
+			continue
+		}
+
+		// `bzr ls` appends "/" to dirs and "@" to symlinks.
+		var mode os.FileMode
+		if strings.HasSuffix(name, "/") {
+			mode = os.ModeDir
+		} else if strings.HasSuffix(name, "@") {
+			mode = os.ModeSymlink
+		}
+		if mode != 0 {
+			name = name[:len(name)-1]
+		}
+
+		name = strings.TrimPrefix(name, prefix)
+		if name == "" || strings.Contains(name, "/") {
+			continue // not an immediate child of path
+		}
+		fis = append(fis, &util.FileInfo{Name_: name, Mode_: mode})
+	}
+	return fis, nil
+}
+
+func (fs *bzrFSCmd) String() string {
+	return fmt.Sprintf("bzr repository %s commit %s (cmd)", fs.repo.Dir, fs.at)
+}
@@ -0,0 +1,124 @@
+package bzrcmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestParseBzrLog(t *testing.T) {
+	out := `------------------------------------------------------------
+revno: 2 [merge]
+tags: mytag
+revision-id: a@a.com-20140506192021-cccccccccccccccc
+parent: a@a.com-20060102150405-aaaaaaaaaaaaaaaa
+parent: b@b.com-20140506000000-bbbbbbbbbbbbbbbb
+committer: a <a@a.com>
+branch nick: trunk
+timestamp: Tue 2014-05-06 19:20:21 +0200
+message:
+  merge
+
+  body
+    ------------------------------------------------------------
+    revno: 1.1.1
+    revision-id: b@b.com-20140506000000-bbbbbbbbbbbbbbbb
+    parent: a@a.com-20060102150405-aaaaaaaaaaaaaaaa
+    author: c <c@c.com>, d <d@d.com>
+    committer: b <b@b.com>
+    branch nick: feature
+    timestamp: Tue 2014-05-06 00:00:00 +0000
+    message:
+      feature
+------------------------------------------------------------
+revno: 1
+revision-id: a@a.com-20060102150405-aaaaaaaaaaaaaaaa
+committer: a <a@a.com>
+branch nick: trunk
+timestamp: Mon 2006-01-02 15:04:05 +0000
+message:
+  root
+`
+	commits, err := parseBzrLog([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := func(name, email, date string) vcs.Signature {
+		tm, err := time.Parse(bzrTimestampLayout, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vcs.NewSignature(name, email, tm)
+	}
+	sigPtr := func(name, email, date string) *vcs.Signature {
+		s := sig(name, email, date)
+		return &s
+	}
+	want := []*vcs.Commit{
+		{
+			ID:        "a@a.com-20140506192021-cccccccccccccccc",
+			Author:    sig("a", "a@a.com", "Tue 2014-05-06 19:20:21 +0200"),
+			Committer: sigPtr("a", "a@a.com", "Tue 2014-05-06 19:20:21 +0200"),
+			Message:   "merge\n\nbody",
+			Parents:   []vcs.CommitID{"a@a.com-20060102150405-aaaaaaaaaaaaaaaa", "b@b.com-20140506000000-bbbbbbbbbbbbbbbb"},
+			Subject:   "merge",
+			Body:      "body",
+		},
+		{
+			ID:        "b@b.com-20140506000000-bbbbbbbbbbbbbbbb",
+			Author:    sig("c", "c@c.com", "Tue 2014-05-06 00:00:00 +0000"),
+			Committer: sigPtr("b", "b@b.com", "Tue 2014-05-06 00:00:00 +0000"),
+			Message:   "feature",
+			Parents:   []vcs.CommitID{"a@a.com-20060102150405-aaaaaaaaaaaaaaaa"},
+			Subject:   "feature",
+		},
+		{
+			ID:        "a@a.com-20060102150405-aaaaaaaaaaaaaaaa",
+			Author:    sig("a", "a@a.com", "Mon 2006-01-02 15:04:05 +0000"),
+			Committer: sigPtr("a", "a@a.com", "Mon 2006-01-02 15:04:05 +0000"),
+			Message:   "root",
+			Subject:   "root",
+		},
+	}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("got commits\n%+v\n\nwant\n%+v", commits, want)
+	}
+}
+
+func TestCheckRevSpec(t *testing.T) {
+	tests := map[string]bool{
+		"1":     true,
+		"1.2.1": true,
+		"-1":    true,
+		"revid:a@a.com-20140506192021-k3m1bqv0ynxqm3ez": true,
+		"tag:v1.0":                    true,
+		"date:2014-05-06":             true,
+		"":                            false,
+		"revid:":                      false,
+		"1..2":                        false,
+		"revid:a..branch:/tmp/b":      false,
+		"branch:/tmp/b":               false,
+		"branch:http://example.com/b": false,
+		"ancestor:/tmp/b":             false,
+		"before:1":                    false,
+		"last:1":                      false,
+	}
+	for spec, valid := range tests {
+		err := checkRevSpec(spec)
+		if valid && err != nil {
+			t.Errorf("%q: got err %v, want valid", spec, err)
+		} else if !valid && err != vcs.ErrRevisionNotFound {
+			t.Errorf("%q: got err %v, want %v", spec, err, vcs.ErrRevisionNotFound)
+		}
+	}
+
+	// Invalid specs are rejected before running bzr (which would fail
+	// differently in a dir that doesn't exist).
+	r := &Repository{Dir: "/doesntexist"}
+	if _, err := r.ResolveRevision("branch:/tmp/b"); err != vcs.ErrRevisionNotFound {
+		t.Errorf("ResolveRevision: got err %v, want %v", err, vcs.ErrRevisionNotFound)
+	}
+}
@@ -0,0 +1,11 @@
+package vcs
+
+// A CommitCounter is a repository that can count the commits in a
+// range without listing them.
+type CommitCounter interface {
+	// CountCommits returns the number of commits that
+	// (Repository).Commits would list for opt if it weren't limited
+	// (i.e., the total that Commits returns). Only opt's Head, Base,
+	// Path, Follow, Author, Since, and Until fields are used.
+	CountCommits(opt CommitsOptions) (uint, error)
+}
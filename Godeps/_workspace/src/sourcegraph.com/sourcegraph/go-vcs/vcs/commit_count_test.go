@@ -0,0 +1,126 @@
+package vcs_test
+
+import (
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestCommitCounter_CountCommits(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q -b b",
+		"echo b > b",
+		"git add b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -m b --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git mv b c",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m c --author='b <b@b.com>' --date 2006-01-02T15:04:07Z",
+		"git checkout -q master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:08Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:09Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:09Z git merge --no-ff -m merge b",
+	}
+	repo := makeGitRepositoryCmd(t, gitCommands...)
+	head, err := repo.ResolveRevision("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := repo.ResolveRevision("master~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			vcs.CommitCounter
+			Commits(vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		opt       vcs.CommitsOptions
+		wantCount uint
+	}{
+		"git cmd all": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head},
+			wantCount: 5,
+		},
+		"git cmd Base": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Base: base},
+			wantCount: 3,
+		},
+		"git cmd Path": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Path: "c"},
+			wantCount: 1,
+		},
+		"git cmd Path and Follow": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Path: "c", Follow: true},
+			wantCount: 2,
+		},
+		"git cmd Author": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Author: "b@b"},
+			wantCount: 1,
+		},
+		"git cmd Author, Path, and Follow": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Path: "c", Follow: true, Author: "b@b"},
+			wantCount: 1,
+		},
+		"git cmd Since": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Since: time.Date(2006, 1, 2, 15, 4, 7, 0, time.UTC)},
+			wantCount: 3,
+		},
+		"git cmd Until": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Until: time.Date(2006, 1, 2, 15, 4, 6, 0, time.UTC)},
+			wantCount: 2,
+		},
+		"git cmd Since and Until": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Since: time.Date(2006, 1, 2, 8, 4, 7, 0, time.FixedZone("", -7*60*60)), Until: time.Date(2006, 1, 2, 15, 4, 8, 0, time.UTC)},
+			wantCount: 2,
+		},
+		"git cmd N and Skip are ignored": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, N: 1, Skip: 1},
+			wantCount: 5,
+		},
+	}
+
+	for label, test := range tests {
+		count, err := test.repo.CountCommits(test.opt)
+		if err != nil {
+			t.Errorf("%s: CountCommits(%+v): %s", label, test.opt, err)
+			continue
+		}
+		if count != test.wantCount {
+			t.Errorf("%s: got count %d, want %d", label, count, test.wantCount)
+		}
+
+		// The count must agree with the commits listed (and the
+		// total returned) by Commits.
+		listOpt := test.opt
+		listOpt.N, listOpt.Skip = 0, 0
+		commits, total, err := test.repo.Commits(listOpt)
+		if err != nil {
+			t.Errorf("%s: Commits(%+v): %s", label, listOpt, err)
+			continue
+		}
+		if count != uint(len(commits)) || count != total {
+			t.Errorf("%s: got count %d, want len(Commits) %d and total %d", label, count, len(commits), total)
+		}
+	}
+
+	if _, err := repo.CountCommits(vcs.CommitsOptions{Head: nonexistentCommitID}); err != vcs.ErrCommitNotFound {
+		t.Errorf("nonexistent head: got error %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+	if _, err := repo.CountCommits(vcs.CommitsOptions{Head: head, Follow: true}); err != vcs.ErrInvalidFollow {
+		t.Errorf("Follow without Path: got error %v, want %v", err, vcs.ErrInvalidFollow)
+	}
+}
@@ -0,0 +1,43 @@
+package vcs
+
+// A CommitGrapher is a repository that can list the parent
+// relationships of a range of commits (for example, to draw a commit
+// graph) without reading the full commits.
+type CommitGrapher interface {
+	// CommitGraph returns the ID and parents of each commit that
+	// (Repository).Commits would list for opt, in the same order.
+	// Only opt's Head, Base, N, Skip, Path, and IncludeGenerations
+	// fields are used.
+	CommitGraph(opt CommitsOptions) ([]*CommitNode, error)
+}
+
+// A CommitNode is a commit in a commit graph: its ID and the IDs of
+// its parents.
+type CommitNode struct {
+	ID      CommitID   `json:"id"`
+	Parents []CommitID `json:"parents,omitempty"`
+
+	// Generation is the commit's generation number (its topological
+	// level: 1 for a root commit, and otherwise 1 more than the
+	// greatest generation number of its parents), if it was requested
+	// and is known (see CommitsOptions.IncludeGenerations). If commit
+	// A is an ancestor of commit B, A's generation number is less
+	// than B's, so clients can rule out ancestry without walking the
+	// graph.
+	Generation uint32 `json:"generation,omitempty"`
+}
+
+// A CommitGraphWriter is a repository that can maintain a
+// commit-graph file (like git's), which speeds up walking the commit
+// graph and records each commit's generation number.
+type CommitGraphWriter interface {
+	// WriteCommitGraph adds the commits reachable from the
+	// repository's refs to its commit-graph file, creating it if
+	// needed (like `git commit-graph write --reachable --split`).
+	WriteCommitGraph() error
+
+	// VerifyCommitGraph checks the commit-graph file against the
+	// commits it describes (like `git commit-graph verify`). It
+	// returns nil if there is no commit-graph file.
+	VerifyCommitGraph() error
+}
@@ -0,0 +1,184 @@
+package vcs_test
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestCommitGrapher_CommitGraph(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q -b b",
+		"echo b > b",
+		"git add b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -m b --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git checkout -q master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:08Z git merge --no-ff -m merge b",
+	}
+	repo := makeGitRepositoryCmd(t, gitCommands...)
+	head, err := repo.ResolveRevision("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := repo.ResolveRevision("master~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			vcs.CommitGrapher
+			Commits(vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		opt       vcs.CommitsOptions
+		wantNodes int
+	}{
+		"git cmd all": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head},
+			wantNodes: 4,
+		},
+		"git cmd N and Skip": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, N: 2, Skip: 1},
+			wantNodes: 2,
+		},
+		"git cmd Base": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Base: base},
+			wantNodes: 2,
+		},
+		"git cmd Path": {
+			repo:      repo,
+			opt:       vcs.CommitsOptions{Head: head, Path: "b"},
+			wantNodes: 1,
+		},
+	}
+
+	for label, test := range tests {
+		nodes, err := test.repo.CommitGraph(test.opt)
+		if err != nil {
+			t.Errorf("%s: CommitGraph(%+v): %s", label, test.opt, err)
+			continue
+		}
+		if len(nodes) != test.wantNodes {
+			t.Errorf("%s: got %d nodes, want %d", label, len(nodes), test.wantNodes)
+		}
+
+		// The graph must agree with the full commits.
+		commits, _, err := test.repo.Commits(test.opt)
+		if err != nil {
+			t.Errorf("%s: Commits(%+v): %s", label, test.opt, err)
+			continue
+		}
+		var want []*vcs.CommitNode
+		for _, c := range commits {
+			want = append(want, &vcs.CommitNode{ID: c.ID, Parents: c.Parents})
+		}
+		if !reflect.DeepEqual(nodes, want) {
+			t.Errorf("%s: got nodes %s, want %s", label, asJSON(nodes), asJSON(want))
+		}
+	}
+
+	// The merge commit has 2 parents.
+	nodes, err := repo.CommitGraph(vcs.CommitsOptions{Head: head, N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || len(nodes[0].Parents) != 2 {
+		t.Errorf("got merge commit node %s, want 2 parents", asJSON(nodes))
+	}
+
+	if _, err := repo.CommitGraph(vcs.CommitsOptions{Head: nonexistentCommitID}); err != vcs.ErrCommitNotFound {
+		t.Errorf("nonexistent head: got error %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
+
+func TestCommitGraphWriter(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q -b b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m b --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git checkout -q master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m baz --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:08Z git merge --no-ff -m merge b",
+	}
+	repo := makeGitRepositoryCmd(t, gitCommands...)
+
+	// generations returns the generation number of each commit
+	// reachable from master, in CommitGraph order.
+	generations := func() []uint32 {
+		head, err := repo.ResolveRevision("master")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := repo.CommitGraph(vcs.CommitsOptions{Head: head, IncludeGenerations: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gens := make([]uint32, len(nodes))
+		for i, node := range nodes {
+			gens[i] = node.Generation
+		}
+		return gens
+	}
+
+	// Without a commit-graph file, generation numbers are omitted.
+	if gens, want := generations(), []uint32{0, 0, 0, 0, 0}; !reflect.DeepEqual(gens, want) {
+		t.Errorf("before WriteCommitGraph: got generations %v, want %v", gens, want)
+	}
+	if err := repo.VerifyCommitGraph(); err != nil {
+		t.Errorf("VerifyCommitGraph with no commit-graph file: %s", err)
+	}
+
+	if err := repo.WriteCommitGraph(); err != nil {
+		t.Fatal(err)
+	}
+	// merge, baz, bar, b, foo
+	if gens, want := generations(), []uint32{4, 3, 2, 2, 1}; !reflect.DeepEqual(gens, want) {
+		t.Errorf("after WriteCommitGraph: got generations %v, want %v", gens, want)
+	}
+
+	// Commits made after the commit-graph file was written have no
+	// generation number until it's written again (which adds a layer
+	// to the split commit-graph chain).
+	gitCommit := exec.Command("bash", "-c", "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:09Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:09Z")
+	gitCommit.Dir = repo.Dir
+	if out, err := gitCommit.CombinedOutput(); err != nil {
+		t.Fatalf("exec %v failed: %s. Output was:\n\n%s", gitCommit.Args, err, out)
+	}
+	if gens, want := generations(), []uint32{0, 4, 3, 2, 2, 1}; !reflect.DeepEqual(gens, want) {
+		t.Errorf("after new commit: got generations %v, want %v", gens, want)
+	}
+	if err := repo.WriteCommitGraph(); err != nil {
+		t.Fatal(err)
+	}
+	if gens, want := generations(), []uint32{5, 4, 3, 2, 2, 1}; !reflect.DeepEqual(gens, want) {
+		t.Errorf("after second WriteCommitGraph: got generations %v, want %v", gens, want)
+	}
+
+	// A single (unsplit) commit-graph file is read too.
+	gitWrite := exec.Command("git", "commit-graph", "write", "--reachable")
+	gitWrite.Dir = repo.Dir
+	if out, err := gitWrite.CombinedOutput(); err != nil {
+		t.Fatalf("exec %v failed: %s. Output was:\n\n%s", gitWrite.Args, err, out)
+	}
+	if gens, want := generations(), []uint32{5, 4, 3, 2, 2, 1}; !reflect.DeepEqual(gens, want) {
+		t.Errorf("with unsplit commit-graph: got generations %v, want %v", gens, want)
+	}
+
+	if err := repo.VerifyCommitGraph(); err != nil {
+		t.Errorf("VerifyCommitGraph: %s", err)
+	}
+}
@@ -0,0 +1,13 @@
+package vcs
+
+// A CommitRefsLister is a repository that can list the refs that
+// point at a commit.
+type CommitRefsLister interface {
+	// RefsForCommit returns the names (such as "master" and "v1.0",
+	// not "refs/heads/master" and "refs/tags/v1.0") of the branches
+	// and tags that point exactly at commit. An annotated tag points
+	// at the commit it tags. Unlike BranchesOptions.ContainsCommit,
+	// refs whose history merely contains commit are not included.
+	// Both lists are sorted.
+	RefsForCommit(commit CommitID) (branches []string, tags []string, err error)
+}
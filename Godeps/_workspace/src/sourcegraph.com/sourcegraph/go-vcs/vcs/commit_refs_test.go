@@ -0,0 +1,62 @@
+package vcs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestCommitRefsLister_RefsForCommit(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag v1.0",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git branch release-2",
+		"git tag v2.0",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git tag -a -m annotated v2.0-annotated",
+	}
+	const (
+		foo = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+		bar = "5c47584dbdf4e75ce93b8050bd0003462572a66a"
+	)
+	tests := map[string]struct {
+		repo         vcs.CommitRefsLister
+		commitID     vcs.CommitID
+		wantBranches []string
+		wantTags     []string
+	}{
+		"git cmd tagged": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: foo,
+			wantTags: []string{"v1.0"},
+		},
+		"git cmd branch tip and tagged": {
+			repo:         makeGitRepositoryCmd(t, gitCommands...),
+			commitID:     bar,
+			wantBranches: []string{"master", "release-2"},
+			wantTags:     []string{"v2.0", "v2.0-annotated"},
+		},
+		"git cmd no refs": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: nonexistentCommitID,
+		},
+	}
+
+	for label, test := range tests {
+		branches, tags, err := test.repo.RefsForCommit(test.commitID)
+		if err != nil {
+			t.Errorf("%s: RefsForCommit(%s): %s", label, test.commitID, err)
+			continue
+		}
+		if !reflect.DeepEqual(branches, test.wantBranches) {
+			t.Errorf("%s: got branches %v, want %v", label, branches, test.wantBranches)
+		}
+		if !reflect.DeepEqual(tags, test.wantTags) {
+			t.Errorf("%s: got tags %v, want %v", label, tags, test.wantTags)
+		}
+	}
+}
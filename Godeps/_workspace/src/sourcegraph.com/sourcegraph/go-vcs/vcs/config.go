@@ -0,0 +1,85 @@
+package vcs
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// A Configurer reads and writes a repository's local configuration
+// (like `git config --local`).
+type Configurer interface {
+	// GetConfig returns the value of the configuration key (such as
+	// "uploadpack.allowFilter"). If the key is not set,
+	// ErrConfigKeyNotFound is returned.
+	GetConfig(key string) (string, error)
+
+	// SetConfig sets the configuration key to value. Only keys that
+	// satisfy SettableConfigKey may be set; others cause
+	// ErrConfigKeyNotSettable.
+	SetConfig(key, value string) error
+}
+
+var (
+	// ErrConfigKeyNotFound is returned by (Configurer).GetConfig when
+	// the key is not set.
+	ErrConfigKeyNotFound = errors.New("config key not found")
+
+	// ErrInvalidConfigKey is returned by Configurer methods when the
+	// key does not satisfy ValidConfigKey.
+	ErrInvalidConfigKey = errors.New("invalid config key (must be section.name or section.subsection.name)")
+
+	// ErrConfigKeyNotSettable is returned by (Configurer).SetConfig
+	// when the key does not satisfy SettableConfigKey.
+	ErrConfigKeyNotSettable = errors.New("config key may not be set")
+)
+
+// configKeyPattern matches git config keys: a section, an optional
+// subsection (which may contain any characters except newlines and
+// NULs), and a variable name.
+var configKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.-]*(\.[^\n\x00]+)?\.[A-Za-z][A-Za-z0-9-]*$`)
+
+// ValidConfigKey returns whether key is a well-formed configuration
+// key. Keys that are not (such as those beginning with "-", which git
+// would interpret as a flag) are rejected.
+func ValidConfigKey(key string) bool {
+	return configKeyPattern.MatchString(key)
+}
+
+// settableConfigKeys are the (lowercased) configuration keys that
+// SetConfig may set. They only tune how the repository is stored and
+// served. Many other keys (such as core.sshCommand, core.hooksPath,
+// diff.external, credential.helper, filter.<driver>.clean, and
+// url.<base>.insteadOf) make git run commands or contact other hosts,
+// so allowing them would let whoever sets them run code on the
+// server.
+var settableConfigKeys = map[string]bool{
+	"core.bigfilethreshold":               true,
+	"core.compression":                    true,
+	"core.logallrefupdates":               true,
+	"fetch.fsckobjects":                   true,
+	"gc.auto":                             true,
+	"gc.autopacklimit":                    true,
+	"gc.reflogexpire":                     true,
+	"gc.reflogexpireunreachable":          true,
+	"pack.compression":                    true,
+	"receive.denydeletes":                 true,
+	"receive.denynonfastforwards":         true,
+	"receive.fsckobjects":                 true,
+	"repack.writebitmaps":                 true,
+	"transfer.fsckobjects":                true,
+	"uploadpack.allowanysha1inwant":       true,
+	"uploadpack.allowfilter":              true,
+	"uploadpack.allowreachablesha1inwant": true,
+	"uploadpack.allowtipsha1inwant":       true,
+	"user.email":                          true,
+	"user.name":                           true,
+}
+
+// SettableConfigKey returns whether key may be set with
+// (Configurer).SetConfig. Only an explicit list of harmless keys
+// (such as uploadpack.allowFilter) may be set. Keys are compared
+// case-insensitively, as git does for keys without a subsection.
+func SettableConfigKey(key string) bool {
+	return ValidConfigKey(key) && settableConfigKeys[strings.ToLower(key)]
+}
@@ -0,0 +1,58 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestConfigurer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		repo vcs.Configurer
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t)},
+	}
+
+	for label, test := range tests {
+		if _, err := test.repo.GetConfig("foo.bar"); err != vcs.ErrConfigKeyNotFound {
+			t.Errorf("%s: GetConfig of unset key: got error %v, want %v", label, err, vcs.ErrConfigKeyNotFound)
+		}
+
+		for _, kv := range [][2]string{{"user.name", "baz"}, {"user.email", "-x y"}, {"uploadpack.allowFilter", "true"}} {
+			if err := test.repo.SetConfig(kv[0], kv[1]); err != nil {
+				t.Errorf("%s: SetConfig(%q, %q): %s", label, kv[0], kv[1], err)
+				continue
+			}
+			value, err := test.repo.GetConfig(kv[0])
+			if err != nil {
+				t.Errorf("%s: GetConfig(%q): %s", label, kv[0], err)
+				continue
+			}
+			if value != kv[1] {
+				t.Errorf("%s: GetConfig(%q): got %q, want %q", label, kv[0], value, kv[1])
+			}
+		}
+
+		// Keys that could make git run commands (or that aren't
+		// known to be harmless) can't be set.
+		for _, key := range []string{"foo.bar", "core.sshCommand", "core.hooksPath", "core.fsmonitor", "diff.external", "credential.helper", "filter.x.clean", "url.https://example.com/.insteadOf", "uploadpack.packObjectsHook"} {
+			if err := test.repo.SetConfig(key, "x"); err != vcs.ErrConfigKeyNotSettable {
+				t.Errorf("%s: SetConfig(%q): got error %v, want %v", label, key, err, vcs.ErrConfigKeyNotSettable)
+			}
+			if _, err := test.repo.GetConfig(key); err != vcs.ErrConfigKeyNotFound {
+				t.Errorf("%s: GetConfig(%q) after disallowed SetConfig: got error %v, want %v", label, key, err, vcs.ErrConfigKeyNotFound)
+			}
+		}
+
+		for _, key := range []string{"", "foo", "-foo.bar", "--global", "foo.", ".bar", "foo.bar\nbaz.qux", "foo.1bar"} {
+			if err := test.repo.SetConfig(key, "x"); err != vcs.ErrInvalidConfigKey {
+				t.Errorf("%s: SetConfig(%q): got error %v, want %v", label, key, err, vcs.ErrInvalidConfigKey)
+			}
+			if _, err := test.repo.GetConfig(key); err != vcs.ErrInvalidConfigKey {
+				t.Errorf("%s: GetConfig(%q): got error %v, want %v", label, key, err, vcs.ErrInvalidConfigKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package vcs
+
+import "errors"
+
+// A DefaultBrancher determines a repository's default branch.
+type DefaultBrancher interface {
+	// DefaultBranch returns the name (such as "master", not
+	// "refs/heads/master") of the repository's default branch. It is
+	// the branch that HEAD points to if HEAD is a symbolic ref to an
+	// existing branch; otherwise (such as in a mirror whose HEAD is
+	// detached or points to a nonexistent branch) it is derived from
+	// the remote's HEAD and the existing branches.
+	//
+	// If the repository has no commits, ErrRepoEmpty is returned. If
+	// it has commits but no default branch can be determined,
+	// ErrBranchNotFound is returned.
+	DefaultBranch() (string, error)
+}
+
+// ErrRepoEmpty is returned when an operation requires commits but
+// the repository has none (for example, it was just created with
+// `git init`).
+var ErrRepoEmpty = errors.New("repository is empty")
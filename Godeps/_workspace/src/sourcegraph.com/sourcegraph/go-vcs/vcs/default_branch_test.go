@@ -0,0 +1,54 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestDefaultBrancher_DefaultBranch(t *testing.T) {
+	t.Parallel()
+
+	commit := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z"
+	tests := map[string]struct {
+		repo    vcs.DefaultBrancher
+		want    string
+		wantErr error
+	}{
+		"git cmd symbolic HEAD": {
+			repo: makeGitRepositoryCmd(t, "git symbolic-ref HEAD refs/heads/trunk", commit, "git branch master"),
+			want: "trunk",
+		},
+		"git cmd detached HEAD": {
+			repo: makeGitRepositoryCmd(t, "git symbolic-ref HEAD refs/heads/trunk", commit, "git branch other", commit, "git checkout -q --detach other"),
+			want: "other",
+		},
+		"git cmd remote HEAD": {
+			repo: makeGitRepositoryCmd(t, "git symbolic-ref HEAD refs/heads/trunk", commit, "git branch dev", "git symbolic-ref refs/remotes/origin/HEAD refs/remotes/origin/dev", "git symbolic-ref HEAD refs/heads/gone"),
+			want: "dev",
+		},
+		"git cmd conventional name": {
+			repo: makeGitRepositoryCmd(t, "git symbolic-ref HEAD refs/heads/master", commit, "git branch dev", "git symbolic-ref HEAD refs/heads/gone"),
+			want: "master",
+		},
+		"git cmd ambiguous": {
+			repo:    makeGitRepositoryCmd(t, "git symbolic-ref HEAD refs/heads/a", commit, "git branch b", "git symbolic-ref HEAD refs/heads/gone"),
+			wantErr: vcs.ErrBranchNotFound,
+		},
+		"git cmd empty": {
+			repo:    makeGitRepositoryCmd(t),
+			wantErr: vcs.ErrRepoEmpty,
+		},
+	}
+
+	for label, test := range tests {
+		branch, err := test.repo.DefaultBranch()
+		if err != test.wantErr {
+			t.Errorf("%s: DefaultBranch: got error %v, want %v", label, err, test.wantErr)
+			continue
+		}
+		if branch != test.want {
+			t.Errorf("%s: got default branch %q, want %q", label, branch, test.want)
+		}
+	}
+}
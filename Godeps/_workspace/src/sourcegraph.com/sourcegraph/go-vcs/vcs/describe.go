@@ -0,0 +1,23 @@
+package vcs
+
+import "errors"
+
+type Describer interface {
+	// Describe returns a human-readable name for the commit based on
+	// the most recent tag reachable from it (like `git describe
+	// --tags --long`), such as "v1.2.3-4-gabcdef0".
+	Describe(CommitID, DescribeOptions) (string, error)
+}
+
+// DescribeOptions specifies options for (Describer).Describe.
+type DescribeOptions struct {
+	All        bool `url:",omitempty"` // use any ref, not just tags (like `git describe --all`)
+	Abbrev     int  `url:",omitempty"` // number of hex digits in the abbreviated commit ID (0 means the default)
+	ExactMatch bool `url:",omitempty"` // only describe the commit if a tag points directly at it
+	Always     bool `url:",omitempty"` // fall back to the abbreviated commit ID if no tag can describe the commit
+}
+
+// ErrNoDescription is returned by (Describer).Describe when no tag
+// (or ref, if DescribeOptions.All is set) can describe the commit
+// and DescribeOptions.Always is not set.
+var ErrNoDescription = errors.New("no tag can describe commit")
@@ -0,0 +1,78 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestDescriber_Describe(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag v1.0",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	const (
+		foo = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+		bar = "5c47584dbdf4e75ce93b8050bd0003462572a66a"
+	)
+	tests := map[string]struct {
+		repo     vcs.Describer
+		commitID vcs.CommitID
+		opt      vcs.DescribeOptions
+		want     string
+		wantErr  error
+	}{
+		"git cmd exact tag": {
+			repo:     makeGitRepositoryCmd(t, gitCommands[:2]...),
+			commitID: foo,
+			want:     "v1.0-0-gea167fe",
+		},
+		"git cmd ahead of tag": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			want:     "v1.0-1-g5c47584",
+		},
+		"git cmd abbrev": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			opt:      vcs.DescribeOptions{Abbrev: 10},
+			want:     "v1.0-1-g5c47584dbd",
+		},
+		"git cmd exact match": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			opt:      vcs.DescribeOptions{ExactMatch: true},
+			wantErr:  vcs.ErrNoDescription,
+		},
+		"git cmd no tags": {
+			repo:     makeGitRepositoryCmd(t, gitCommands[0]),
+			commitID: foo,
+			wantErr:  vcs.ErrNoDescription,
+		},
+		"git cmd no tags always": {
+			repo:     makeGitRepositoryCmd(t, gitCommands[0]),
+			commitID: foo,
+			opt:      vcs.DescribeOptions{Always: true},
+			want:     "ea167fe",
+		},
+		"git cmd nonexistent commit": {
+			repo:     makeGitRepositoryCmd(t, gitCommands[:2]...),
+			commitID: nonexistentCommitID,
+			wantErr:  vcs.ErrCommitNotFound,
+		},
+	}
+
+	for label, test := range tests {
+		desc, err := test.repo.Describe(test.commitID, test.opt)
+		if err != test.wantErr {
+			t.Errorf("%s: Describe: got err %v, want %v", label, err, test.wantErr)
+			continue
+		}
+		if desc != test.want {
+			t.Errorf("%s: got description %q, want %q", label, desc, test.want)
+		}
+	}
+}
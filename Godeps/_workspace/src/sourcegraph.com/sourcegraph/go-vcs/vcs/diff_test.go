@@ -1,6 +1,7 @@
 package vcs_test
 
 import (
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -12,8 +13,6 @@ import (
 func TestRepository_Diff(t *testing.T) {
 	t.Parallel()
 
-	// TODO(sqs): test ExcludeReachableFromBoth
-
 	cmds := []string{
 		"echo line1 > f",
 		"git add f",
@@ -114,6 +113,69 @@ func TestRepository_Diff(t *testing.T) {
 	}
 }
 
+func TestRepository_Diff_excludeReachableFromBoth(t *testing.T) {
+	t.Parallel()
+
+	// The base branch changes g after head forks from it.
+	cmds := []string{
+		"echo line1 > f",
+		"echo line1 > g",
+		"git add f g",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q -b head",
+		"echo line2 >> f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -am foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testhead",
+		"git checkout -q -",
+		"echo line2 >> g",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -am foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+	}
+	tests := map[string]struct {
+		opt       *vcs.DiffOptions
+		wantFiles []string
+	}{
+		"two-dot":   {opt: &vcs.DiffOptions{}, wantFiles: []string{"f", "g"}},
+		"three-dot": {opt: &vcs.DiffOptions{ExcludeReachableFromBoth: true}, wantFiles: []string{"f"}},
+	}
+
+	repos := map[string]interface {
+		vcs.Differ
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git libgit2": makeGitRepositoryLibGit2(t, cmds...),
+		"git cmd":     makeGitRepositoryCmd(t, cmds...),
+	}
+	for repoLabel, repo := range repos {
+		baseCommitID, err := repo.ResolveRevision("testbase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		headCommitID, err := repo.ResolveRevision("testhead")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for label, test := range tests {
+			label = repoLabel + " " + label
+			diff, err := repo.Diff(baseCommitID, headCommitID, test.opt)
+			if err != nil {
+				t.Errorf("%s: Diff: %s", label, err)
+				continue
+			}
+			var files []string
+			for _, line := range strings.Split(diff.Raw, "\n") {
+				if strings.HasPrefix(line, "diff --git ") {
+					files = append(files, strings.Fields(line)[2])
+				}
+			}
+			if !reflect.DeepEqual(files, test.wantFiles) {
+				t.Errorf("%s: got changed files %v, want %v (diff: %q)", label, files, test.wantFiles, diff.Raw)
+			}
+		}
+	}
+}
+
 func TestRepository_Diff_rename(t *testing.T) {
 	t.Parallel()
 
@@ -165,7 +227,8 @@ func TestRepository_Diff_rename(t *testing.T) {
 			repo: makeGitRepositoryCmd(t, cmds...),
 			base: "testbase", head: "testhead",
 			wantDiff: &vcs.Diff{
-				Raw: "diff --git f g\nsimilarity index 100%\nrename from f\nrename to g\n",
+				Raw:     "diff --git f g\nsimilarity index 100%\nrename from f\nrename to g\n",
+				Renames: []*vcs.DiffRename{{OrigPath: "f", Path: "g", Similarity: 100}},
 			},
 			opt: opt,
 		},
@@ -221,6 +284,186 @@ func TestRepository_Diff_rename(t *testing.T) {
 	}
 }
 
+func TestRepository_Diff_renameThreshold(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"printf 'line1\\nline2\\nline3\\nline4\\nline5\\nline6\\nline7\\nline8\\nline9\\nline10\\n' > f",
+		"printf 'k1\\nk2\\nk3\\nk4\\nk5\\nk6\\nk7\\nk8\\nk9\\nk10\\n' > k",
+		"git add f k",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+		"git mv f g",
+		"sed -i.bak -e 's/line1$/changed1/' -e 's/line2/changed2/' g && rm g.bak",
+		"cp k h && echo k11 >> k",
+		"git add g h k",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testhead",
+	}
+	tests := map[string]struct {
+		opt         *vcs.DiffOptions
+		wantRenames []*vcs.DiffRename
+	}{
+		"above threshold": {
+			opt:         &vcs.DiffOptions{RenameThreshold: 70},
+			wantRenames: []*vcs.DiffRename{{OrigPath: "f", Path: "g", Similarity: 73}},
+		},
+		"below threshold": {
+			opt:         &vcs.DiffOptions{RenameThreshold: 90},
+			wantRenames: nil,
+		},
+		"copies": {
+			opt: &vcs.DiffOptions{RenameThreshold: 70, DetectCopies: true},
+			wantRenames: []*vcs.DiffRename{
+				{OrigPath: "f", Path: "g", Similarity: 73},
+				{OrigPath: "k", Path: "h", Similarity: 100, Copy: true},
+			},
+		},
+	}
+
+	repos := map[string]interface {
+		vcs.Differ
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git libgit2": makeGitRepositoryLibGit2(t, cmds...),
+		"git cmd":     makeGitRepositoryCmd(t, cmds...),
+	}
+	for repoLabel, repo := range repos {
+		baseCommitID, err := repo.ResolveRevision("testbase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		headCommitID, err := repo.ResolveRevision("testhead")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for label, test := range tests {
+			label = repoLabel + " " + label
+			diff, err := repo.Diff(baseCommitID, headCommitID, test.opt)
+			if err != nil {
+				t.Errorf("%s: Diff: %s", label, err)
+				continue
+			}
+			if !reflect.DeepEqual(diff.Renames, test.wantRenames) {
+				t.Errorf("%s: got renames %s, want %s", label, asJSON(diff.Renames), asJSON(test.wantRenames))
+			}
+			if isRename := strings.Contains(diff.Raw, "\nrename from f\n"); isRename != (test.wantRenames != nil) {
+				t.Errorf("%s: got rename in raw diff %v, want %v", label, isRename, test.wantRenames != nil)
+			}
+		}
+	}
+}
+
+func TestRepository_Diff_ignoreWhitespace(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"printf 'if x {\\nfoo()\\n}\\n' > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+		"printf 'if x {\\n\\tfoo()\\n}\\n' > f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -am foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testhead",
+	}
+	tests := map[string]struct {
+		opt       *vcs.DiffOptions
+		wantEmpty bool
+	}{
+		"no options":             {opt: &vcs.DiffOptions{}, wantEmpty: false},
+		"IgnoreWhitespace":       {opt: &vcs.DiffOptions{IgnoreWhitespace: true}, wantEmpty: true},
+		"IgnoreWhitespaceChange": {opt: &vcs.DiffOptions{IgnoreWhitespaceChange: true}, wantEmpty: false}, // adds whitespace where there was none
+	}
+
+	repos := map[string]interface {
+		vcs.Differ
+		ResolveRevision(spec string) (vcs.CommitID, error)
+	}{
+		"git libgit2": makeGitRepositoryLibGit2(t, cmds...),
+		"git cmd":     makeGitRepositoryCmd(t, cmds...),
+	}
+	for repoLabel, repo := range repos {
+		baseCommitID, err := repo.ResolveRevision("testbase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		headCommitID, err := repo.ResolveRevision("testhead")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for label, test := range tests {
+			label = repoLabel + " " + label
+			diff, err := repo.Diff(baseCommitID, headCommitID, test.opt)
+			if err != nil {
+				t.Errorf("%s: Diff: %s", label, err)
+				continue
+			}
+			if empty := diff.Raw == ""; empty != test.wantEmpty {
+				t.Errorf("%s: got empty diff %v, want %v (diff: %q)", label, empty, test.wantEmpty, diff.Raw)
+			}
+		}
+	}
+}
+
+func TestRepository_DiffTrees(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"mkdir -p upstream vendor/upstream",
+		"printf 'a\\nb\\n' > upstream/f",
+		"printf 'x\\n' > upstream/g",
+		"git add upstream",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+		"printf 'a\\nc\\n' > vendor/upstream/f",
+		"cp upstream/g vendor/upstream/g",
+		"git add vendor",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testhead",
+	}
+	repo := makeGitRepositoryCmd(t, cmds...)
+
+	diff, err := repo.DiffTrees("testbase:upstream", "testhead:vendor/upstream", &vcs.DiffOptions{OrigPrefix: "a/", NewPrefix: "b/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only f differs, and its paths are relative to the trees.
+	if want := "diff --git a/f b/f\n"; !strings.HasPrefix(diff.Raw, want) {
+		t.Errorf("got diff %q, want it to start with %q", diff.Raw, want)
+	}
+	if strings.Count(diff.Raw, "diff --git ") != 1 || !strings.Contains(diff.Raw, "\n-b\n+c\n") {
+		t.Errorf("got diff %q, want only the change to f", diff.Raw)
+	}
+
+	// A root tree can be compared with a subtree.
+	diff, err = repo.DiffTrees("testhead:upstream", "testhead", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{" upstream/f\n", " upstream/g\n", " vendor/upstream/f\n", " vendor/upstream/g\n"} {
+		if !strings.Contains(diff.Raw, want) {
+			t.Errorf("got diff %q, want it to contain %q", diff.Raw, want)
+		}
+	}
+
+	if _, err := repo.DiffTrees("testbase:upstream", "nonexistent:upstream", nil); err != vcs.ErrCommitNotFound {
+		t.Errorf("nonexistent revision: got error %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+	if _, err := repo.DiffTrees("testbase:upstream", "testbase:vendor/upstream", nil); !os.IsNotExist(err) {
+		t.Errorf("nonexistent path: got error %v, want os.ErrNotExist", err)
+	}
+	if _, err := repo.DiffTrees("testbase:upstream/f", "testhead:vendor/upstream/f", nil); err == nil {
+		t.Error("blob specs: got no error")
+	}
+	for _, spec := range []string{"--output=x", "testbase..testhead", "testbase:../x", "testbase:/upstream", "testbase:./upstream", "testbase:upstream\nHEAD", ":upstream", "testbase:upstream/"} {
+		if _, err := repo.DiffTrees(spec, "testhead", nil); err != vcs.ErrInvalidTreeSpec {
+			t.Errorf("tree spec %q: got error %v, want %v", spec, err, vcs.ErrInvalidTreeSpec)
+		}
+	}
+}
+
 func TestRepository_CrossRepoDiff_git(t *testing.T) {
 	t.Parallel()
 
@@ -335,3 +578,67 @@ func TestRepository_CrossRepoDiff_git(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_CrossRepoDiff_hg(t *testing.T) {
+	t.Parallel()
+
+	hgCmdsBase := []string{
+		"echo line1 > f",
+		"hg add f",
+		"hg commit -m foo --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	}
+	hgCmdsHead := append(append([]string{}, hgCmdsBase...),
+		"echo line2 >> f",
+		"hg commit -m bar --date '2006-12-06 13:18:29 UTC' --user 'a <a@a.com>'",
+	)
+	tests := map[string]struct {
+		baseRepo interface {
+			vcs.CrossRepoDiffer
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		headRepo vcs.Repository
+		wantDiff *vcs.Diff
+	}{
+		"hg cmd": {
+			baseRepo: makeHgRepositoryCmd(t, hgCmdsBase...),
+			headRepo: makeHgRepositoryCmd(t, hgCmdsHead...),
+			wantDiff: &vcs.Diff{
+				Raw: "diff --git f f\n--- f\n+++ f\n@@ -1,1 +1,2 @@\n line1\n+line2\n",
+			},
+		},
+		"hg native": {
+			baseRepo: makeHgRepositoryNative(t, hgCmdsBase...),
+			headRepo: makeHgRepositoryNative(t, hgCmdsHead...),
+			wantDiff: &vcs.Diff{
+				Raw: "diff --git f f\n--- f\n+++ f\n@@ -1,1 +1,2 @@\n line1\n+line2\n",
+			},
+		},
+	}
+
+	for label, test := range tests {
+		baseCommitID, err := test.baseRepo.ResolveRevision("tip")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision on base: %s", label, err)
+			continue
+		}
+
+		headCommitID, err := test.headRepo.ResolveRevision("tip")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision on head: %s", label, err)
+			continue
+		}
+
+		diff, err := test.baseRepo.CrossRepoDiff(baseCommitID, test.headRepo, headCommitID, nil)
+		if err != nil {
+			t.Errorf("%s: CrossRepoDiff(%s, %v, %s): %s", label, baseCommitID, test.headRepo, headCommitID, err)
+			continue
+		}
+		if !reflect.DeepEqual(diff, test.wantDiff) {
+			t.Errorf("%s: diff != wantDiff\n\ndiff ==========\n%s\n\nwantDiff ==========\n%s", label, asJSON(diff), asJSON(test.wantDiff))
+		}
+
+		if _, err := test.baseRepo.CrossRepoDiff(baseCommitID, test.headRepo, nonexistentCommitID, nil); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: CrossRepoDiff with bad head commit ID: want ErrCommitNotFound, got %v", label, err)
+		}
+	}
+}
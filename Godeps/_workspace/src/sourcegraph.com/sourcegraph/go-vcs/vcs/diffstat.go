@@ -0,0 +1,29 @@
+package vcs
+
+// A DiffStater is a repository that can summarize the changes made by
+// a commit without computing its full diff.
+type DiffStater interface {
+	// DiffStat returns the files changed by a commit (compared to its
+	// first parent, or to the empty tree for a root commit) and the
+	// number of lines added and deleted in each. For merge commits,
+	// only the changes relative to the first parent are included
+	// (like `git show -m --first-parent`).
+	DiffStat(CommitID) ([]*FileStat, error)
+}
+
+// A FileStat describes the changes made to a single file.
+type FileStat struct {
+	// Path is the file's path (after the change, if it was renamed).
+	Path string
+
+	// OrigPath is the file's path before the change, if it was
+	// renamed.
+	OrigPath string `json:",omitempty"`
+
+	// Added and Deleted are the number of lines added and deleted. They
+	// are 0 for binary files.
+	Added, Deleted int
+
+	// Binary is whether the file is binary.
+	Binary bool `json:",omitempty"`
+}
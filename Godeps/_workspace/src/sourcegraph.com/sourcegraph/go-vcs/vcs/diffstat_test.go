@@ -0,0 +1,81 @@
+package vcs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestDiffStater_DiffStat(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"printf 'a\\nb\\nc\\nd\\n' > f",
+		"printf '\\000\\001' > bin",
+		"git add f bin",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag root",
+		"git mv f g",
+		"printf 'a\\nb\\nc\\nd\\ne\\n' > g",
+		"printf '\\000\\002' > bin",
+		"echo x > h",
+		"git add g bin h",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag change",
+		"git checkout -q -b side root",
+		"echo y > s",
+		"git add s",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m side --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:05Z git merge -q --no-edit side",
+		"git tag merge",
+	}
+	repo := makeGitRepositoryCmd(t, cmds...)
+	tests := map[string]struct {
+		repo interface {
+			vcs.DiffStater
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		rev  string // can be any revspec; is resolved during the test
+		want []*vcs.FileStat
+	}{
+		"git cmd root commit": {
+			repo: repo,
+			rev:  "root",
+			want: []*vcs.FileStat{{Path: "bin", Binary: true}, {Path: "f", Added: 4}},
+		},
+		"git cmd rename and binary": {
+			repo: repo,
+			rev:  "change",
+			want: []*vcs.FileStat{{Path: "bin", Binary: true}, {Path: "g", OrigPath: "f", Added: 1}, {Path: "h", Added: 1}},
+		},
+		"git cmd merge commit (first parent)": {
+			repo: repo,
+			rev:  "merge",
+			want: []*vcs.FileStat{{Path: "s", Added: 1}},
+		},
+	}
+
+	for label, test := range tests {
+		commitID, err := test.repo.ResolveRevision(test.rev)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q): %s", label, test.rev, err)
+			continue
+		}
+
+		stats, err := test.repo.DiffStat(commitID)
+		if err != nil {
+			t.Errorf("%s: DiffStat(%s): %s", label, commitID, err)
+			continue
+		}
+		if !reflect.DeepEqual(stats, test.want) {
+			t.Errorf("%s: DiffStat(%s): got %v, want %v", label, commitID, asJSON(stats), asJSON(test.want))
+		}
+	}
+
+	// Test that a nonexistent commit returns ErrCommitNotFound.
+	if _, err := repo.DiffStat(nonexistentCommitID); err != vcs.ErrCommitNotFound {
+		t.Errorf("for nonexistent commit: got err %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
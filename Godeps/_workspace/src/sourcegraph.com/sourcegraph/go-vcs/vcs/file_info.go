@@ -1,5 +1,10 @@
 package vcs
 
+import (
+	"io"
+	"os"
+)
+
 // ModeSubmodule is an os.FileMode mask indicating that the file is a
 // VCS submodule (e.g., a git submodule).
 const ModeSubmodule = 0160000
@@ -21,3 +26,28 @@ type SymlinkInfo struct {
 	// Dest is the path that the symlink points to.
 	Dest string
 }
+
+// A MultiStater is a file system (returned by a repository's
+// FileSystem method) that can stat many paths at once more
+// efficiently than by calling Stat for each path.
+type MultiStater interface {
+	// StatMulti stats each of the paths (as Stat does). The returned
+	// slices have one element per path: either its os.FileInfo or the
+	// error statting it (such as an *os.PathError whose Err is
+	// os.ErrNotExist for a path that doesn't exist). An error that
+	// affects all of the paths is returned for each path.
+	StatMulti(paths []string) ([]os.FileInfo, []error)
+}
+
+// A TarExporter is a file system (returned by a repository's
+// FileSystem method) that can export a whole subtree at once, more
+// efficiently than by reading each of its files.
+type TarExporter interface {
+	// Tar returns a tar archive of the file or directory at path
+	// (and, for a directory, everything under it). The names of the
+	// archive's entries are relative to the root of the file system,
+	// not to path. The archive is streamed, so the caller must close
+	// the returned reader. If path doesn't exist, an
+	// *os.PathError whose Err is os.ErrNotExist is returned.
+	Tar(path string) (io.ReadCloser, error)
+}
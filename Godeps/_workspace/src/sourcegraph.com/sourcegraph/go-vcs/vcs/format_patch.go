@@ -0,0 +1,26 @@
+package vcs
+
+import "io"
+
+// A PatchFormatter formats commits as email-style patches.
+type PatchFormatter interface {
+	// FormatPatch returns the patches of the commits in
+	// opt.Base..head (or of only head, if opt.Base is empty), oldest
+	// first, in mbox format (like `git format-patch --stdout`) that
+	// `git am` can apply. The patches are streamed, so the caller must
+	// close the returned reader. If head or opt.Base is not a commit,
+	// ErrCommitNotFound is returned.
+	FormatPatch(head CommitID, opt FormatPatchOptions) (io.ReadCloser, error)
+}
+
+// FormatPatchOptions specifies options for
+// (PatchFormatter).FormatPatch.
+type FormatPatchOptions struct {
+	Base CommitID `url:",omitempty"` // if set, format the commits in Base..head instead of only head
+
+	// IncludeMerges includes merge commits, each as its diff against
+	// its first parent, and omits the commits that they merged (so
+	// that the patches still apply in order). By default, merge
+	// commits are omitted and the commits they merged are included.
+	IncludeMerges bool `url:",omitempty"`
+}
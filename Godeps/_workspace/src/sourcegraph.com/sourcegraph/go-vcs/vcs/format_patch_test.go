@@ -0,0 +1,112 @@
+package vcs_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+)
+
+func TestPatchFormatter(t *testing.T) {
+	t.Parallel()
+
+	// A root commit, then a merge of a branch (adding b) into master
+	// (which added c).
+	cmds := []string{
+		"echo a > a",
+		"git add a",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m root --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag root",
+		"git checkout -b feat",
+		"echo b > b",
+		"git add b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -m feat --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git checkout master",
+		"echo c > c",
+		"git add c",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m main --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:08Z git merge --no-ff -m merge feat",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.Repository
+			vcs.PatchFormatter
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, cmds...)},
+	}
+
+	// git runs a command in dir and returns its trimmed output.
+	git := func(dir string, stdin []byte, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_NAME=b", "GIT_AUTHOR_EMAIL=b@b.com", "GIT_COMMITTER_NAME=b", "GIT_COMMITTER_EMAIL=b@b.com", "HOME="+dir)
+		cmd.Stdin = bytes.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	for label, test := range tests {
+		dir := test.repo.(*gitcmd.Repository).Dir
+		base, err := test.repo.ResolveRevision("root")
+		if err != nil {
+			t.Fatal(err)
+		}
+		head, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantTree := git(dir, nil, "rev-parse", "master^{tree}")
+
+		for _, opt := range []vcs.FormatPatchOptions{{Base: base}, {Base: base, IncludeMerges: true}} {
+			rc, err := test.repo.FormatPatch(head, opt)
+			if err != nil {
+				t.Errorf("%s: FormatPatch(%+v): %s", label, opt, err)
+				continue
+			}
+			patches, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Apply the patches to a fresh clone at the base commit.
+			cloneDir := filepath.Join(makeTmpDir(t, "format-patch"), "clone")
+			git(dir, nil, "clone", "--quiet", dir, cloneDir)
+			git(cloneDir, nil, "reset", "--quiet", "--hard", string(base))
+			git(cloneDir, patches, "am", "--quiet")
+			if tree := git(cloneDir, nil, "rev-parse", "HEAD^{tree}"); tree != wantTree {
+				t.Errorf("%s: FormatPatch(%+v): got tree %s after applying patches, want %s. Patches were:\n\n%s", label, opt, tree, wantTree, patches)
+			}
+			if subjects := git(cloneDir, nil, "log", "--format=%s", string(base)+"..HEAD"); opt.IncludeMerges != strings.Contains(subjects, "merge") {
+				t.Errorf("%s: FormatPatch(%+v): got commits %q, want merge included only if IncludeMerges", label, opt, subjects)
+			}
+		}
+
+		// A single commit.
+		rc, err := test.repo.FormatPatch(base, vcs.FormatPatchOptions{})
+		if err != nil {
+			t.Fatalf("%s: FormatPatch of a single commit: %s", label, err)
+		}
+		patch, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if !bytes.Contains(patch, []byte("Subject: [PATCH] root\n")) || bytes.Count(patch, []byte("\nSubject: ")) != 1 {
+			t.Errorf("%s: FormatPatch of a single commit: got\n%s\nwant only the root commit's patch", label, patch)
+		}
+
+		if _, err := test.repo.FormatPatch("doesntexist", vcs.FormatPatchOptions{}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: FormatPatch of a nonexistent commit: got error %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+		if _, err := test.repo.FormatPatch(head, vcs.FormatPatchOptions{Base: "--output=/tmp/x"}); err == nil {
+			t.Errorf("%s: FormatPatch with a flag as the base: got no error", label)
+		}
+	}
+}
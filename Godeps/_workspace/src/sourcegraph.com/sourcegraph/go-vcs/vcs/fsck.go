@@ -0,0 +1,52 @@
+package vcs
+
+// A Fscker checks the integrity of a repository's objects.
+type Fscker interface {
+	// Fsck checks the connectivity and validity of all of the
+	// repository's objects (like `git fsck --full`). If the check
+	// runs, it returns a nil error even if it finds problems; they
+	// are listed in the report, which is empty for a clean
+	// repository.
+	Fsck() (FsckReport, error)
+}
+
+// FsckReport lists the problems found by (Fscker).Fsck.
+type FsckReport struct {
+	Problems []*FsckProblem `json:",omitempty"`
+}
+
+// OK returns whether the report lists no problems other than
+// dangling objects (which are unreachable but intact, such as those
+// left behind by deleted branches or rewritten history).
+func (r FsckReport) OK() bool {
+	for _, p := range r.Problems {
+		if p.Kind != FsckDangling {
+			return false
+		}
+	}
+	return true
+}
+
+// FsckProblem is a problem found by (Fscker).Fsck.
+type FsckProblem struct {
+	Kind FsckProblemKind
+
+	// ObjectType ("commit", "tree", "blob", or "tag") and ObjectID
+	// identify the object with the problem, if known.
+	ObjectType string `json:",omitempty"`
+	ObjectID   string `json:",omitempty"`
+
+	// Message is the problem as reported by the VCS.
+	Message string
+}
+
+// FsckProblemKind is the kind of an FsckProblem.
+type FsckProblemKind string
+
+const (
+	FsckDangling   FsckProblemKind = "dangling"    // an unreachable object
+	FsckMissing    FsckProblemKind = "missing"     // a referenced object that does not exist (or can't be read)
+	FsckBrokenLink FsckProblemKind = "broken-link" // a reference (from another object) to a missing object
+	FsckCorrupt    FsckProblemKind = "corrupt"     // an object that is corrupt or invalid
+	FsckWarning    FsckProblemKind = "warning"     // a minor problem, such as a malformed but readable object
+)
@@ -0,0 +1,73 @@
+package vcs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+)
+
+func TestFscker(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo line1 > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	// The blob of f (containing "line1\n").
+	const blobID = "a29bdeb434d874c9b1d8969c40c42161b03fafdc"
+
+	tests := map[string]struct {
+		repo vcs.Fscker
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, cmds...)},
+	}
+
+	for label, test := range tests {
+		report, err := test.repo.Fsck()
+		if err != nil {
+			t.Errorf("%s: Fsck: %s", label, err)
+			continue
+		}
+		if len(report.Problems) != 0 || !report.OK() {
+			t.Errorf("%s: clean repo: got problems %s, want none", label, asJSON(report.Problems))
+		}
+
+		// Corrupt the blob.
+		objFile := filepath.Join(test.repo.(*gitcmd.Repository).Dir, ".git", "objects", blobID[:2], blobID[2:])
+		if err := os.Chmod(objFile, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(objFile, []byte("garbage"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err = test.repo.Fsck()
+		if err != nil {
+			t.Errorf("%s: corrupt repo: Fsck: %s", label, err)
+			continue
+		}
+		if report.OK() {
+			t.Errorf("%s: corrupt repo: got OK report, want problems", label)
+		}
+		var corrupt, missing bool
+		for _, p := range report.Problems {
+			if p.ObjectID != blobID {
+				continue
+			}
+			switch p.Kind {
+			case vcs.FsckCorrupt:
+				corrupt = true
+			case vcs.FsckMissing:
+				missing = p.ObjectType == "blob"
+			}
+		}
+		if !corrupt || !missing {
+			t.Errorf("%s: corrupt repo: got problems %s, want the blob %s to be reported corrupt and missing", label, asJSON(report.Problems), blobID)
+		}
+	}
+}
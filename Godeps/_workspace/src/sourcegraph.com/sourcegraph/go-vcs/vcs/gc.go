@@ -0,0 +1,14 @@
+package vcs
+
+// A GarbageCollector cleans up and compacts a repository's storage.
+type GarbageCollector interface {
+	// GC removes unreachable objects and packs loose objects (like
+	// `git gc`). Other operations that modify the repository wait
+	// until it completes.
+	GC(GCOptions) error
+}
+
+// GCOptions specifies options for (GarbageCollector).GC.
+type GCOptions struct {
+	Aggressive bool `url:",omitempty"` // optimize more thoroughly, at the cost of much more time (like `git gc --aggressive`)
+}
@@ -0,0 +1,91 @@
+package vcs_test
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo line1 > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo line2 >> f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	cmdRepo, goGitRepo := makeGitRepositoryCmd(t, cmds...), makeGitRepositoryGoGit(t, cmds...)
+	tests := map[string]struct {
+		repo interface {
+			vcs.Repository
+			vcs.GarbageCollector
+		}
+		dir string
+	}{
+		"git cmd":    {repo: cmdRepo, dir: cmdRepo.Dir},
+		"git go-git": {repo: goGitRepo, dir: goGitRepo.Dir},
+	}
+
+	for label, test := range tests {
+		dir := test.dir
+		if n := looseObjects(t, dir); n == 0 {
+			t.Fatalf("%s: before GC: got no loose objects, want some", label)
+		}
+
+		for i, opt := range []vcs.GCOptions{{}, {Aggressive: true}} {
+			// Add a commit (outside of the repository), so that GC
+			// writes a new pack that the repository must find.
+			commit := exec.Command("bash", "-c", "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m baz --author='a <a@a.com>' --date 2006-01-02T15:04:05Z")
+			commit.Dir = dir
+			if out, err := commit.CombinedOutput(); err != nil {
+				t.Fatalf("%s: git commit failed: %s\n%s", label, err, out)
+			}
+			wantCommits := uint(3 + i)
+
+			if err := test.repo.GC(opt); err != nil {
+				t.Errorf("%s: GC(%+v): %s", label, opt, err)
+				continue
+			}
+			if n := looseObjects(t, dir); n != 0 {
+				t.Errorf("%s: after GC(%+v): got %d loose objects, want 0", label, opt, n)
+			}
+
+			commitID, err := test.repo.ResolveBranch("master")
+			if err != nil {
+				t.Errorf("%s: after GC(%+v): ResolveBranch: %s", label, opt, err)
+				continue
+			}
+			if _, n, err := test.repo.Commits(vcs.CommitsOptions{Head: commitID}); err != nil || n != wantCommits {
+				t.Errorf("%s: after GC(%+v): Commits: got %d commits (error %v), want %d", label, opt, n, err, wantCommits)
+			}
+		}
+	}
+}
+
+// looseObjects returns the number of loose objects in the git
+// repository at dir.
+func looseObjects(t *testing.T, dir string) int {
+	cmd := exec.Command("git", "count-objects", "-v")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "count: ") {
+			n, err := strconv.Atoi(strings.TrimPrefix(line, "count: "))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+	}
+	t.Fatalf("no count in git count-objects output:\n%s", out)
+	return 0
+}
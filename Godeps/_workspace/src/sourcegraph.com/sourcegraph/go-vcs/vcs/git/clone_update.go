@@ -10,8 +10,10 @@ extern int _govcs_gcrypt_init();
 */
 import "C"
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/user"
 	"strings"
@@ -45,6 +47,14 @@ func init() {
 }
 
 func Clone(url, dir string, opt vcs.CloneOpt) (vcs.Repository, error) {
+	if opt.Depth > 0 || opt.Branch != "" {
+		// libgit2 doesn't support shallow or single-branch clones.
+		if _, err := gitcmd.Clone(url, dir, opt); err != nil {
+			return nil, err
+		}
+		return Open(dir)
+	}
+
 	clopt := git2go.CloneOptions{Bare: opt.Bare}
 
 	rc, cfs, err := makeRemoteCallbacks(url, opt.RemoteOpts)
@@ -74,6 +84,13 @@ func Clone(url, dir string, opt vcs.CloneOpt) (vcs.Repository, error) {
 }
 
 func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) error {
+	// libgit2 doesn't support shallow or single-branch fetches.
+	if branch, err := r.Repository.CloneBranch(); err != nil {
+		return err
+	} else if branch != "" {
+		return r.Repository.UpdateEverything(opt)
+	}
+
 	// TODO(sqs): allow use of a remote other than "origin"
 	rm, err := r.u.Remotes.Lookup("origin")
 	if err != nil {
@@ -112,7 +129,7 @@ func (f cleanupFuncs) run() error {
 
 // makeRemoteCallbacks constructs the remote callbacks for libgit2
 // remote operations. Currently the remote callbacks are trivial
-// (empty) except when using an SSH remote.
+// (empty) except when using an SSH remote or HTTPS credentials.
 //
 // cleanupFuncs's run method should be called when the RemoteCallbacks
 // struct is done being used. It is OK to ignore the error return.
@@ -126,35 +143,56 @@ func makeRemoteCallbacks(url string, opt vcs.RemoteOpts) (rc *git2go.RemoteCallb
 	}()
 
 	if opt.SSH != nil {
-		var privkeyFilename, pubkeyFilename string
-		var privkeyFile, pubkeyFile *os.File
+		// Write each private key (and its public key) to temp files.
+		// They are offered in order, followed by the SSH agent's keys
+		// if opt.SSH.UseAgent is set.
+		type keyPair struct{ pubkeyFilename, privkeyFilename string }
+		var keyPairs []keyPair
 		var err error
 
-		if opt.SSH.PrivateKey != nil {
-			privkeyFilename, privkeyFile, err = util.WriteKeyTempFile(url, opt.SSH.PrivateKey)
+		for i, privKeyData := range append([][]byte{opt.SSH.PrivateKey}, opt.SSH.PrivateKeys...) {
+			if privKeyData == nil {
+				continue
+			}
+			privkeyFilename, privkeyFile, err := util.WriteKeyTempFile(url, privKeyData)
 			if err != nil {
-				return nil, nil, err
+				return nil, cfs, err
 			}
 			cfs = append(cfs, privkeyFile.Close)
 			cfs = append(cfs, func() error { return os.Remove(privkeyFile.Name()) })
 
-			// Derive public key from private key if empty.
-			if opt.SSH.PublicKey == nil {
-				privKey, err := ssh.ParsePrivateKey(opt.SSH.PrivateKey)
+			// Derive public key from private key if empty. (Only
+			// the first key has a PublicKey field.)
+			pubKeyData := opt.SSH.PublicKey
+			if i > 0 || pubKeyData == nil {
+				privKey, err := ssh.ParsePrivateKey(privKeyData)
 				if err != nil {
 					return nil, cfs, err
 				}
-				opt.SSH.PublicKey = ssh.MarshalAuthorizedKey(privKey.PublicKey())
+				pubKeyData = ssh.MarshalAuthorizedKey(privKey.PublicKey())
 			}
 
-			pubkeyFilename, pubkeyFile, err = util.WriteKeyTempFile(url, opt.SSH.PublicKey)
+			pubkeyFilename, pubkeyFile, err := util.WriteKeyTempFile(url, pubKeyData)
 			if err != nil {
 				return nil, cfs, err
 			}
 			cfs = append(cfs, pubkeyFile.Close)
 			cfs = append(cfs, func() error { return os.Remove(pubkeyFile.Name()) })
+
+			keyPairs = append(keyPairs, keyPair{pubkeyFilename, privkeyFilename})
+		}
+
+		// Verify host keys against the given known_hosts, if any,
+		// instead of the standard files.
+		knownHosts := standardKnownHosts
+		if opt.SSH.KnownHosts != nil {
+			knownHosts, err = sshutil.ParseKnownHosts(bytes.NewReader(opt.SSH.KnownHosts))
+			if err != nil {
+				return nil, cfs, err
+			}
 		}
 
+		var tries int // number of keys offered so far
 		rc = &git2go.RemoteCallbacks{
 			CredentialsCallback: git2go.CredentialsCallback(func(url string, usernameFromURL string, allowedTypes git2go.CredType) (git2go.ErrorCode, *git2go.Cred) {
 				var username string
@@ -170,9 +208,20 @@ func makeRemoteCallbacks(url string, opt vcs.RemoteOpts) (rc *git2go.RemoteCallb
 						}
 					}
 				}
-				if allowedTypes&git2go.CredTypeSshKey != 0 && opt.SSH.PrivateKey != nil {
-					rv, cred := git2go.NewCredSshKey(username, pubkeyFilename, privkeyFilename, "")
-					return git2go.ErrorCode(rv), &cred
+				if allowedTypes&git2go.CredTypeSshKey != 0 {
+					// libgit2 calls this callback again if the
+					// server rejects a key, so offer the next one.
+					if tries < len(keyPairs) {
+						kp := keyPairs[tries]
+						tries++
+						rv, cred := git2go.NewCredSshKey(username, kp.pubkeyFilename, kp.privkeyFilename, "")
+						return git2go.ErrorCode(rv), &cred
+					}
+					if opt.SSH.UseAgent && tries == len(keyPairs) {
+						tries++
+						rv, cred := git2go.NewCredSshKeyFromAgent(username)
+						return git2go.ErrorCode(rv), &cred
+					}
 				}
 				log.Printf("No authentication available for git URL %q.", url)
 				rv, cred := git2go.NewCredDefault()
@@ -184,7 +233,7 @@ func makeRemoteCallbacks(url string, opt vcs.RemoteOpts) (rc *git2go.RemoteCallb
 				// host keys using known_hosts, but let's ignore valid
 				// so we don't get that behavior unexpectedly.
 
-				if InsecureSkipCheckVerifySSH {
+				if InsecureSkipCheckVerifySSH && opt.SSH.KnownHosts == nil {
 					return git2go.ErrOk
 				}
 
@@ -193,7 +242,7 @@ func makeRemoteCallbacks(url string, opt vcs.RemoteOpts) (rc *git2go.RemoteCallb
 				}
 
 				if cert.Hostkey.Kind&git2go.HostkeyMD5 > 0 {
-					keys, found := standardKnownHosts.Lookup(hostname)
+					keys, found := knownHosts.Lookup(sshKnownHostsName(hostname, url))
 					if found {
 						hostFingerprint := md5String(cert.Hostkey.HashMD5)
 						for _, key := range keys {
@@ -211,13 +260,49 @@ func makeRemoteCallbacks(url string, opt vcs.RemoteOpts) (rc *git2go.RemoteCallb
 		}
 	}
 
+	if opt.HTTPS != nil {
+		if rc == nil {
+			rc = &git2go.RemoteCallbacks{}
+		}
+		sshCredentials := rc.CredentialsCallback
+		rc.CredentialsCallback = git2go.CredentialsCallback(func(url string, usernameFromURL string, allowedTypes git2go.CredType) (git2go.ErrorCode, *git2go.Cred) {
+			if allowedTypes&git2go.CredTypeUserpassPlaintext != 0 {
+				username := opt.HTTPS.User
+				if username == "" {
+					username = usernameFromURL
+				}
+				rv, cred := git2go.NewCredUserpassPlaintext(username, opt.HTTPS.Pass)
+				return git2go.ErrorCode(rv), &cred
+			}
+			if sshCredentials != nil {
+				return sshCredentials(url, usernameFromURL, allowedTypes)
+			}
+			log.Printf("No authentication available for git URL %q.", url)
+			rv, cred := git2go.NewCredDefault()
+			return git2go.ErrorCode(rv), &cred
+		})
+	}
+
 	return rc, cfs, nil
 }
 
+// sshKnownHostsName returns the name that known_hosts files list the
+// SSH server at hostname under, for the remote at rawurl. Only
+// "ssh://" URLs can specify a port; scp-like URLs ("user@host:path")
+// always use the default port.
+func sshKnownHostsName(hostname, rawurl string) string {
+	var port string
+	if u, err := url.Parse(rawurl); err == nil && u.Scheme != "" {
+		port = u.Port()
+	}
+	return sshutil.KnownHostsName(hostname, port)
+}
+
 // InsecureSkipCheckVerifySSH controls whether the client verifies the
 // SSH server's certificate or host key. If InsecureSkipCheckVerifySSH
 // is true, the program is susceptible to a man-in-the-middle
-// attack. This should only be used for testing.
+// attack. This should only be used for testing. It is ignored for
+// remotes whose vcs.SSHConfig specifies KnownHosts.
 var InsecureSkipCheckVerifySSH bool
 
 // standardKnownHosts contains known_hosts from the system known_hosts
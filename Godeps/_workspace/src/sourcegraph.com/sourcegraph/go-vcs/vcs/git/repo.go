@@ -18,7 +18,6 @@ import (
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
-	"sourcegraph.com/sqs/pbtypes"
 )
 
 func init() {
@@ -214,6 +213,11 @@ func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Cursor != "" || opt.Path != "" || opt.UseMailmap || opt.ReencodeMessage || opt.IncludeSignatureStatus || opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() {
+		// Not implemented in libgit2 yet, so call gitcmd.
+		return r.Repository.Commits(opt)
+	}
+
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
@@ -283,12 +287,18 @@ func (r *Repository) makeCommit(c *git2go.Commit) *vcs.Commit {
 	}
 
 	au, cm := c.Author(), c.Committer()
+	msg := strings.TrimSuffix(c.Message(), "\n")
+	committer := vcs.NewSignature(cm.Name, cm.Email, cm.When)
+	subject, body := vcs.SplitMessage(msg)
 	return &vcs.Commit{
 		ID:        vcs.CommitID(c.Id().String()),
-		Author:    vcs.Signature{au.Name, au.Email, pbtypes.NewTimestamp(au.When)},
-		Committer: &vcs.Signature{cm.Name, cm.Email, pbtypes.NewTimestamp(cm.When)},
-		Message:   strings.TrimSuffix(c.Message(), "\n"),
+		Author:    vcs.NewSignature(au.Name, au.Email, au.When),
+		Committer: &committer,
+		Message:   msg,
 		Parents:   parents,
+		Trailers:  vcs.ParseTrailers(msg),
+		Subject:   subject,
+		Body:      body,
 	}
 }
 
@@ -361,7 +371,7 @@ func (r *Repository) diffHoldingEditLock(base, head vcs.CommitID, opt *vcs.DiffO
 		opt = &vcs.DiffOptions{}
 	}
 
-	if opt.ExcludeReachableFromBoth {
+	if opt.ExcludeReachableFromBoth || opt.RenameThreshold != 0 || opt.DetectCopies || opt.IgnoreWhitespace || opt.IgnoreWhitespaceChange {
 		// Not implemented in libgit2 yet, so call gitcmd.
 		return r.Repository.Diff(base, head, opt)
 	}
@@ -438,6 +448,11 @@ func (r *Repository) diffHoldingEditLock(base, head vcs.CommitID, opt *vcs.DiffO
 }
 
 func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk, error) {
+	if opt != nil && (opt.UseMailmap || len(opt.IgnoreRevs) > 0 || opt.IgnoreRevsFile != "") {
+		// Not implemented in libgit2 yet, so call gitcmd.
+		return r.Repository.BlameFile(path, opt)
+	}
+
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
@@ -497,11 +512,7 @@ func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk,
 			StartByte: byteOffset,
 			EndByte:   endByteOffset,
 			CommitID:  vcs.CommitID(hunk.FinalCommitId.String()),
-			Author: vcs.Signature{
-				Name:  hunk.FinalSignature.Name,
-				Email: hunk.FinalSignature.Email,
-				Date:  pbtypes.NewTimestamp(hunk.FinalSignature.When.In(time.UTC)),
-			},
+			Author:    vcs.NewSignature(hunk.FinalSignature.Name, hunk.FinalSignature.Email, hunk.FinalSignature.When),
 		}
 		byteOffset = endByteOffset
 		lines = lines[hunk.LinesInHunk:]
@@ -668,48 +679,7 @@ func (fs *gitFSLibGit2) Stat(path string) (os.FileInfo, error) {
 	fs.repoEditLock.RLock()
 	defer fs.repoEditLock.RUnlock()
 
-	path = filepath.Clean(internal.Rel(path))
-
-	mtime, err := fs.getModTime()
-	if err != nil {
-		return nil, err
-	}
-
-	if path == "." {
-		return &util.FileInfo{Mode_: os.ModeDir, ModTime_: mtime}, nil
-	}
-
-	e, err := fs.getEntry(path)
-	if err != nil {
-		return nil, err
-	}
-
-	if e.Filemode == git2go.FilemodeLink {
-		// dereference symlink
-		b, err := fs.repo.LookupBlob(e.Id)
-		if err != nil {
-			return nil, err
-		}
-
-		derefPath := string(b.Contents())
-		fi, err := fs.Lstat(derefPath)
-		if err != nil {
-			return nil, err
-		}
-
-		// Use original filename.
-		fi.(*util.FileInfo).Name_ = filepath.Base(path)
-
-		return fi, nil
-	}
-
-	fi, err := fs.makeFileInfo(path, e)
-	if err != nil {
-		return nil, err
-	}
-	fi.ModTime_ = mtime
-
-	return fi, nil
+	return internal.Stat(fs.Lstat, path)
 }
 
 func (fs *gitFSLibGit2) getModTime() (time.Time, error) {
@@ -758,12 +728,12 @@ func (fs *gitFSLibGit2) fileInfo(e *git2go.TreeEntry) (*util.FileInfo, error) {
 	defer b.Free()
 
 	var sys interface{}
-	var mode os.FileMode
+	mode := os.FileMode(0644)
 	if e.Filemode == git2go.FilemodeBlobExecutable {
-		mode |= 0111
+		mode = 0755
 	}
 	if e.Filemode == git2go.FilemodeLink {
-		mode |= os.ModeSymlink
+		mode = os.ModeSymlink
 
 		// Dereference symlink.
 		b, err := fs.repo.LookupBlob(e.Id)
@@ -0,0 +1,150 @@
+package gitcmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// blameAuthor is the author information that `git blame --porcelain`
+// outputs for a commit (only for the first hunk from that commit).
+type blameAuthor struct {
+	name, email string
+	time        int64
+	tz          *time.Location
+}
+
+func (a *blameAuthor) signature() vcs.Signature {
+	tz := a.tz
+	if tz == nil {
+		tz = time.UTC
+	}
+	return vcs.NewSignature(a.name, a.email, time.Unix(a.time, 0).In(tz))
+}
+
+// parseBlamePorcelain parses the output of `git blame --porcelain`
+// into hunks, one for each group of consecutive lines from the same
+// commit.
+//
+// Each line of the file is described by a header ("<commit> <orig
+// line> <final line>", followed by " <num lines>" on the first line
+// of a group), then "<key> <value>" lines describing the commit (only
+// the first time the commit appears), and then the line's contents
+// prefixed by a tab. The "<key> <value>" lines are matched by key, so
+// unknown, reordered, and missing keys are tolerated.
+func parseBlamePorcelain(out []byte) ([]*vcs.Hunk, error) {
+	var (
+		authors    = map[vcs.CommitID]*blameAuthor{}
+		hunks      []*vcs.Hunk
+		hunk       *vcs.Hunk    // the current hunk
+		author     *blameAuthor // author of the last header's commit
+		byteOffset int
+	)
+	endHunk := func() {
+		if hunk != nil {
+			hunk.EndByte = byteOffset
+			hunk.Author = authors[hunk.CommitID].signature()
+			hunks = append(hunks, hunk)
+			hunk = nil
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if author == nil {
+				return nil, fmt.Errorf("unexpected line contents before header in git blame output: %q", line)
+			}
+			// The tab stands in for the newline that ends the line.
+			byteOffset += len(line)
+			continue
+		}
+
+		if commitID, fields, ok := parseBlameHeader(line); ok {
+			if author = authors[commitID]; author == nil {
+				author = &blameAuthor{}
+				authors[commitID] = author
+			}
+			if len(fields) < 4 {
+				// Another line in the current group.
+				if hunk == nil || hunk.CommitID != commitID {
+					return nil, fmt.Errorf("unexpected git blame header (not in a group from the same commit): %q", line)
+				}
+				continue
+			}
+			finalLine, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("bad final line number in git blame header %q: %s", line, err)
+			}
+			numLines, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("bad number of lines in git blame header %q: %s", line, err)
+			}
+			endHunk()
+			hunk = &vcs.Hunk{
+				CommitID:  commitID,
+				StartLine: finalLine,
+				EndLine:   finalLine + numLines,
+				StartByte: byteOffset,
+			}
+			continue
+		}
+
+		if author == nil {
+			return nil, fmt.Errorf("unexpected line before header in git blame output: %q", line)
+		}
+		key, value := line, ""
+		if i := strings.Index(line, " "); i != -1 {
+			key, value = line[:i], line[i+1:]
+		}
+		switch key {
+		case "author":
+			author.name = value
+		case "author-mail":
+			author.email = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+		case "author-time":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad author-time %q in git blame output: %s", value, err)
+			}
+			author.time = t
+		case "author-tz":
+			tz, err := parseGitTZ(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad author-tz %q in git blame output: %s", value, err)
+			}
+			author.tz = tz
+		}
+		// Other keys (such as committer*, summary, previous,
+		// boundary, and filename) aren't needed.
+	}
+	endHunk()
+	return hunks, nil
+}
+
+// parseBlameHeader parses a header line of `git blame --porcelain`
+// output ("<commit> <orig line> <final line>[ <num lines>]").
+func parseBlameHeader(line string) (commitID vcs.CommitID, fields []string, ok bool) {
+	fields = strings.Split(line, " ")
+	if len(fields) != 3 && len(fields) != 4 {
+		return "", nil, false
+	}
+	if len(fields[0]) < 40 || !isHex(fields[0]) {
+		return "", nil, false
+	}
+	return vcs.CommitID(fields[0]), fields, true
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
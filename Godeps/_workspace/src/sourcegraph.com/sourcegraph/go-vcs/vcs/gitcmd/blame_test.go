@@ -0,0 +1,165 @@
+package gitcmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// blamePorcelain is the output of `git blame -w --porcelain` of a
+// file whose lines were written by a root (boundary) commit
+// ee95af4..., a commit feb4918... (with a previous commit), and a
+// commit c6cffc0... (whose 2 lines are a single group).
+const blamePorcelain = `ee95af4668e79448ba0e5428c43eaed162b18ff2 1 1 1
+author a
+author-mail <a@a.com>
+author-time 1136214245
+author-tz +0000
+committer a
+committer-mail <a@a.com>
+committer-time 1136214245
+committer-tz +0000
+summary foo
+boundary
+filename f
+	a
+feb4918df6a2c1db1ca9a3f6d0c1e8a0d12904fb 2 2 1
+author b
+author-mail <b@b.com>
+author-time 1136207046
+author-tz +0200
+committer a
+committer-mail <a@a.com>
+committer-time 1136214245
+committer-tz +0000
+summary bar
+previous ee95af4668e79448ba0e5428c43eaed162b18ff2 f
+filename f
+	x
+ee95af4668e79448ba0e5428c43eaed162b18ff2 3 3 1
+	c
+feb4918df6a2c1db1ca9a3f6d0c1e8a0d12904fb 4 4 1
+	d
+c6cffc0f4191ce9d61f0c42d6284e845b259965f 5 5 2
+author c
+author-mail <c@c.com>
+author-time 1136214247
+author-tz +0000
+committer a
+committer-mail <a@a.com>
+committer-time 1136214247
+committer-tz +0000
+summary baz
+previous feb4918df6a2c1db1ca9a3f6d0c1e8a0d12904fb f
+filename f
+	e
+c6cffc0f4191ce9d61f0c42d6284e845b259965f 6 6
+	f
+`
+
+func TestParseBlamePorcelain(t *testing.T) {
+	const (
+		commitA = "ee95af4668e79448ba0e5428c43eaed162b18ff2"
+		commitB = "feb4918df6a2c1db1ca9a3f6d0c1e8a0d12904fb"
+		commitC = "c6cffc0f4191ce9d61f0c42d6284e845b259965f"
+	)
+	plus2, err := parseGitTZ("+0200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc, err := parseGitTZ("+0000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorA := vcs.NewSignature("a", "a@a.com", time.Unix(1136214245, 0).In(utc))
+	authorB := vcs.NewSignature("b", "b@b.com", time.Unix(1136207046, 0).In(plus2))
+	authorC := vcs.NewSignature("c", "c@c.com", time.Unix(1136214247, 0).In(utc))
+
+	tests := map[string]struct {
+		porcelain string
+		want      []*vcs.Hunk
+	}{
+		"boundary, previous, and multi-line group": {
+			porcelain: blamePorcelain,
+			want: []*vcs.Hunk{
+				{StartLine: 1, EndLine: 2, StartByte: 0, EndByte: 2, CommitID: commitA, Author: authorA},
+				{StartLine: 2, EndLine: 3, StartByte: 2, EndByte: 4, CommitID: commitB, Author: authorB},
+				{StartLine: 3, EndLine: 4, StartByte: 4, EndByte: 6, CommitID: commitA, Author: authorA},
+				{StartLine: 4, EndLine: 5, StartByte: 6, EndByte: 8, CommitID: commitB, Author: authorB},
+				{StartLine: 5, EndLine: 7, StartByte: 8, EndByte: 12, CommitID: commitC, Author: authorC},
+			},
+		},
+		"reordered and missing keys": {
+			porcelain: commitA + ` 1 1 1
+filename f
+author-tz +0200
+author-mail <a@a.com>
+author-time 1136207046
+author a
+	a
+`,
+			want: []*vcs.Hunk{
+				{StartLine: 1, EndLine: 2, StartByte: 0, EndByte: 2, CommitID: commitA, Author: vcs.NewSignature("a", "a@a.com", time.Unix(1136207046, 0).In(plus2))},
+			},
+		},
+		"no contents (empty file)": {
+			porcelain: commitA + ` 1 1 1
+author a
+author-mail <a@a.com>
+author-time 1136214245
+author-tz +0000
+boundary
+filename f
+`,
+			want: []*vcs.Hunk{
+				{StartLine: 1, EndLine: 2, CommitID: commitA, Author: authorA},
+			},
+		},
+		"empty output": {
+			porcelain: "",
+			want:      nil,
+		},
+	}
+	for label, test := range tests {
+		hunks, err := parseBlamePorcelain([]byte(test.porcelain))
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(hunks, test.want) {
+			t.Errorf("%s: got hunks %s, want %s", label, asJSON(hunks), asJSON(test.want))
+		}
+	}
+}
+
+func TestParseBlamePorcelain_malformed(t *testing.T) {
+	tests := map[string]string{
+		"contents before header":     "\ta\n",
+		"key before header":          "author a\n",
+		"continuation without group": "ee95af4668e79448ba0e5428c43eaed162b18ff2 1 1\n\ta\n",
+		"continuation of other commit": `ee95af4668e79448ba0e5428c43eaed162b18ff2 1 1 2
+	a
+feb4918df6a2c1db1ca9a3f6d0c1e8a0d12904fb 2 2
+	b
+`,
+		"bad line number": "ee95af4668e79448ba0e5428c43eaed162b18ff2 1 x 1\n",
+		"bad author-time": "ee95af4668e79448ba0e5428c43eaed162b18ff2 1 1 1\nauthor-time x\n",
+		"bad author-tz":   "ee95af4668e79448ba0e5428c43eaed162b18ff2 1 1 1\nauthor-tz x\n",
+	}
+	for label, porcelain := range tests {
+		if hunks, err := parseBlamePorcelain([]byte(porcelain)); err == nil {
+			t.Errorf("%s: got hunks %s, want error", label, asJSON(hunks))
+		}
+	}
+}
+
+func asJSON(v interface{}) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,209 @@
+package gitcmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CatFileBatch is a boolean indicating whether FileSystem should read
+// objects from long-lived `git cat-file --batch` and `git cat-file
+// --batch-check` processes instead of running git once per file
+// (which is much slower when walking a tree).
+var CatFileBatch = true
+
+// errObjectMissing is returned by (*catFile).query when the object
+// does not exist.
+var errObjectMissing = errors.New("object missing")
+
+// catFileIdleTimeout is how long a `git cat-file` process may go
+// unused before it is stopped.
+var catFileIdleTimeout = 30 * time.Second
+
+// catFile is a long-lived `git cat-file --batch` (or --batch-check)
+// process. The process is stateful, so queries are serialized. It is
+// started lazily, restarted after an I/O error, and stopped after
+// catFileIdleTimeout without queries (or when it is closed).
+type catFile struct {
+	dir   string
+	check bool // run with --batch-check (print object info but not contents)
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	idle   *time.Timer // stops the process after catFileIdleTimeout
+}
+
+// catFileObject is the info line that `git cat-file --batch` prints
+// before each object.
+type catFileObject struct {
+	oid  string
+	typ  string
+	size int64
+}
+
+func (c *catFile) start() error {
+	flag := "--batch"
+	if c.check {
+		flag = "--batch-check"
+	}
+	cmd := exec.Command("git", "cat-file", flag)
+	cmd.Dir = c.dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.cmd, c.stdin, c.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// query looks up the object named by spec (any object name accepted by
+// `git cat-file`, such as "COMMITID:path/to/file"). If c was not
+// created with check, the object's contents are returned as well. If
+// the object does not exist, errObjectMissing is returned.
+func (c *catFile) query(spec string) (*catFileObject, []byte, error) {
+	if strings.Contains(spec, "\n") {
+		return nil, nil, fmt.Errorf("git cat-file: invalid object name %q", spec)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return nil, nil, err
+		}
+	}
+	obj, data, err := c.read(spec)
+	if err != nil && err != errObjectMissing {
+		// The process's output is now out of sync with our queries, so
+		// start a new one next time.
+		c.close()
+	} else if c.idle == nil {
+		c.idle = time.AfterFunc(catFileIdleTimeout, c.closeIdle)
+	} else {
+		c.idle.Reset(catFileIdleTimeout)
+	}
+	return obj, data, err
+}
+
+// closeIdle terminates the process after it has been idle for
+// catFileIdleTimeout.
+func (c *catFile) closeIdle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.close()
+}
+
+// read writes spec to the process and reads its response. The caller
+// must hold c.mu.
+func (c *catFile) read(spec string) (*catFileObject, []byte, error) {
+	if _, err := io.WriteString(c.stdin, spec+"\n"); err != nil {
+		return nil, nil, err
+	}
+
+	line, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if strings.HasSuffix(line, " missing") || strings.HasSuffix(line, " ambiguous") {
+		return nil, nil, errObjectMissing
+	}
+
+	// Format is "OID TYPE SIZE".
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("invalid `git cat-file` output: %q", line)
+	}
+	size, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid `git cat-file` object size: %q", line)
+	}
+	obj := &catFileObject{oid: parts[0], typ: parts[1], size: size}
+	if c.check {
+		return obj, nil, nil
+	}
+
+	// The contents are followed by a newline.
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, data); err != nil {
+		return nil, nil, err
+	}
+	return obj, data[:size], nil
+}
+
+// Close terminates the process (if it is running).
+func (c *catFile) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.close()
+}
+
+// close terminates the process. The caller must hold c.mu.
+func (c *catFile) close() error {
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+	if c.cmd == nil {
+		return nil
+	}
+	// Closing stdin makes cat-file exit cleanly.
+	c.stdin.Close()
+	err := c.cmd.Wait()
+	c.cmd, c.stdin, c.stdout = nil, nil, nil
+	return err
+}
+
+// treeEntry is an entry in a git tree object.
+type treeEntry struct {
+	mode int64
+	name string
+	oid  string
+}
+
+// parseTree parses the raw contents of a git tree object (as printed
+// by `git cat-file --batch`), which is a sequence of "MODE NAME\0"
+// followed by the 20-byte binary object ID.
+func parseTree(data []byte) ([]treeEntry, error) {
+	var entries []treeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		if sp == -1 {
+			return nil, errors.New("invalid git tree object: no mode")
+		}
+		mode, err := strconv.ParseInt(string(data[:sp]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid git tree object mode: %s", err)
+		}
+		data = data[sp+1:]
+
+		nul := bytes.IndexByte(data, 0)
+		if nul == -1 || len(data) < nul+1+20 {
+			return nil, errors.New("invalid git tree object: truncated entry")
+		}
+		name := string(data[:nul])
+		oid := hex.EncodeToString(data[nul+1 : nul+1+20])
+		data = data[nul+1+20:]
+
+		entries = append(entries, treeEntry{mode: mode, name: name, oid: oid})
+	}
+	return entries, nil
+}
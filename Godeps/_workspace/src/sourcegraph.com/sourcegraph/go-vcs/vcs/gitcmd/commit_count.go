@@ -0,0 +1,86 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.CommitCounter = (*Repository)(nil)
+
+func (r *Repository) CountCommits(opt vcs.CommitsOptions) (uint, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return 0, err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return 0, err
+	}
+	if err := vcs.CheckFollow(opt); err != nil {
+		return 0, err
+	}
+
+	total, stderr, err := r.countCommits(opt)
+	if err != nil {
+		if empty, _ := r.isEmpty(); empty {
+			return 0, vcs.ErrRepoEmpty
+		}
+		if isRevNotFoundOutput(stderr, string(opt.Head), string(opt.Base)) {
+			return 0, vcs.ErrCommitNotFound
+		}
+		return 0, err
+	}
+	return total, nil
+}
+
+// countCommits counts the commits that `git log` lists for opt
+// (ignoring N, Skip, and Cursor), using `git rev-list
+// --count` unless opt.Follow is set. If the command fails, its
+// trimmed stderr is returned along with the error.
+//
+// The caller is responsible for doing checkSpecArgSafety on opt.Head
+// and opt.Base and must hold r.editLock.
+func (r *Repository) countCommits(opt vcs.CommitsOptions) (uint, []byte, error) {
+	rng := string(opt.Head)
+	if opt.Base != "" {
+		rng = string(opt.Base) + ".." + string(opt.Head)
+	}
+
+	if opt.Follow {
+		// rev-list doesn't support --follow, so count the commits
+		// that `git log --follow` lists.
+		args := append([]string{"log", "--follow", "--format=format:%H"}, commitFilterArgs(opt)...)
+		cmd := exec.Command("git", append(args, rng, "--", opt.Path)...)
+		cmd.Dir = r.Dir
+		out, stderr, err := dividedOutput(cmd)
+		if err != nil {
+			stderr = bytes.TrimSpace(stderr)
+			return 0, stderr, fmt.Errorf("exec `git log --follow` failed: %s. Output was:\n\n%s", err, stderr)
+		}
+		if out = bytes.TrimSpace(out); len(out) == 0 {
+			return 0, nil, nil
+		}
+		return uint(bytes.Count(out, []byte("\n")) + 1), nil, nil
+	}
+
+	args := append([]string{"rev-list", "--count"}, commitFilterArgs(opt)...)
+	cmd := exec.Command("git", append(args, rng)...)
+	if opt.Path != "" {
+		cmd.Args = append(cmd.Args, "--", opt.Path)
+	}
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		return 0, stderr, fmt.Errorf("exec `git rev-list --count` failed: %s. Output was:\n\n%s", err, stderr)
+	}
+	total, err := parseUint(string(bytes.TrimSpace(out)))
+	if err != nil {
+		return 0, nil, err
+	}
+	return total, nil, nil
+}
@@ -0,0 +1,150 @@
+package gitcmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var (
+	_ vcs.CommitGrapher     = (*Repository)(nil)
+	_ vcs.CommitGraphWriter = (*Repository)(nil)
+)
+
+func (r *Repository) CommitGraph(opt vcs.CommitsOptions) ([]*vcs.CommitNode, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return nil, err
+	}
+
+	args := []string{"log", "--format=format:%H %P"}
+	if opt.N != 0 {
+		args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
+	}
+	if opt.Skip != 0 {
+		args = append(args, "--skip="+strconv.FormatUint(uint64(opt.Skip), 10))
+	}
+	rng := string(opt.Head)
+	if opt.Base != "" {
+		rng = string(opt.Base) + ".." + string(opt.Head)
+	}
+	args = append(args, rng)
+	if opt.Path != "" {
+		args = append(args, "--", opt.Path)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		if empty, _ := r.isEmpty(); empty {
+			return nil, vcs.ErrRepoEmpty
+		}
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(opt.Head), string(opt.Base)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+
+	var nodes []*vcs.CommitNode
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		node := &vcs.CommitNode{ID: vcs.CommitID(fields[0])}
+		for _, p := range fields[1:] {
+			node.Parents = append(node.Parents, vcs.CommitID(p))
+		}
+		nodes = append(nodes, node)
+	}
+
+	if opt.IncludeGenerations {
+		if err := r.setGenerations(nodes); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// setGenerations sets the Generation field of each node whose commit
+// is in the repository's commit-graph file. The caller must hold
+// r.editLock.
+func (r *Repository) setGenerations(nodes []*vcs.CommitNode) error {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "objects/info")
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+	infoDir := string(bytes.TrimSpace(out))
+	if !filepath.IsAbs(infoDir) {
+		infoDir = filepath.Join(r.Dir, infoDir)
+	}
+
+	layers, err := openCommitGraph(infoDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, g := range layers {
+			g.Close()
+		}
+	}()
+
+	for _, node := range nodes {
+		oid, err := hex.DecodeString(string(node.ID))
+		if err != nil {
+			continue
+		}
+		for _, g := range layers {
+			gen, err := g.generation(oid)
+			if err != nil {
+				return err
+			}
+			if gen != 0 {
+				node.Generation = gen
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Repository) WriteCommitGraph() error {
+	// git replaces the commit-graph file atomically, so reads needn't
+	// wait for it to be written.
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "commit-graph", "write", "--reachable", "--split")
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (r *Repository) VerifyCommitGraph() error {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "commit-graph", "verify", "--no-progress")
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package gitcmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A commitGraphFile is a git commit-graph file (or one layer of a
+// split commit-graph chain). See
+// https://git-scm.com/docs/commit-graph-format. Lookups read the file
+// as needed, so it isn't read into memory.
+type commitGraphFile struct {
+	f       *os.File
+	hashLen int64
+	fanout  [256]uint32
+	oidl    int64 // offset of the OID Lookup chunk
+	cdat    int64 // offset of the Commit Data chunk
+}
+
+var errInvalidCommitGraph = errors.New("invalid commit-graph file")
+
+// openCommitGraphFile opens and checks the header of the commit-graph
+// file at name.
+func openCommitGraphFile(name string) (g *commitGraphFile, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			if err != errInvalidCommitGraph {
+				err = fmt.Errorf("reading commit-graph file %s: %s", name, err)
+			}
+		}
+	}()
+
+	var header [8]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "CGPH" || header[4] != 1 {
+		return nil, errInvalidCommitGraph
+	}
+	g = &commitGraphFile{f: f}
+	switch header[5] {
+	case 1:
+		g.hashLen = 20 // SHA-1
+	case 2:
+		g.hashLen = 32 // SHA-256
+	default:
+		return nil, errInvalidCommitGraph
+	}
+
+	// The chunk table of contents has an entry (a 4-byte ID and an
+	// 8-byte offset) for each chunk, followed by a terminating entry.
+	numChunks := int(header[6])
+	toc := make([]byte, 12*(numChunks+1))
+	if _, err := f.ReadAt(toc, int64(len(header))); err != nil {
+		return nil, err
+	}
+	var oidf int64
+	for i := 0; i < numChunks; i++ {
+		entry := toc[12*i : 12*(i+1)]
+		offset := int64(binary.BigEndian.Uint64(entry[4:]))
+		switch string(entry[:4]) {
+		case "OIDF":
+			oidf = offset
+		case "OIDL":
+			g.oidl = offset
+		case "CDAT":
+			g.cdat = offset
+		}
+	}
+	if oidf == 0 || g.oidl == 0 || g.cdat == 0 {
+		return nil, errInvalidCommitGraph
+	}
+
+	// Entry i of the OID Fanout chunk is the number of commits whose
+	// ID's first byte is at most i.
+	var fanout [4 * 256]byte
+	if _, err := f.ReadAt(fanout[:], oidf); err != nil {
+		return nil, err
+	}
+	for i := range g.fanout {
+		g.fanout[i] = binary.BigEndian.Uint32(fanout[4*i:])
+	}
+	return g, nil
+}
+
+// generation returns the topological level of the commit whose binary
+// ID is oid, or 0 if the commit isn't in the file (or the file was
+// written without generation numbers).
+func (g *commitGraphFile) generation(oid []byte) (uint32, error) {
+	if int64(len(oid)) != g.hashLen {
+		return 0, nil
+	}
+
+	// Binary search the OID Lookup chunk, which lists the commit IDs
+	// in order, between the bounds that the fanout gives.
+	var lo uint32
+	if oid[0] > 0 {
+		lo = g.fanout[oid[0]-1]
+	}
+	hi := g.fanout[oid[0]]
+	buf := make([]byte, g.hashLen)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := g.f.ReadAt(buf, g.oidl+int64(mid)*g.hashLen); err != nil {
+			return 0, err
+		}
+		switch c := bytes.Compare(buf, oid); {
+		case c < 0:
+			lo = mid + 1
+		case c > 0:
+			hi = mid
+		default:
+			// Each Commit Data entry is the tree ID, 2 4-byte parent
+			// positions, and 8 bytes whose upper 30 bits are the
+			// topological level.
+			var data [4]byte
+			if _, err := g.f.ReadAt(data[:], g.cdat+int64(mid)*(g.hashLen+16)+g.hashLen+8); err != nil {
+				return 0, err
+			}
+			return binary.BigEndian.Uint32(data[:]) >> 2, nil
+		}
+	}
+	return 0, nil
+}
+
+func (g *commitGraphFile) Close() error { return g.f.Close() }
+
+// openCommitGraph opens the commit-graph file in the objects/info
+// directory infoDir, or else the layers of its split commit-graph
+// chain (as git does). If there is neither, it returns no files.
+func openCommitGraph(infoDir string) ([]*commitGraphFile, error) {
+	g, err := openCommitGraphFile(filepath.Join(infoDir, "commit-graph"))
+	if err == nil {
+		return []*commitGraphFile{g}, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	graphsDir := filepath.Join(infoDir, "commit-graphs")
+	chain, err := os.Open(filepath.Join(graphsDir, "commit-graph-chain"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer chain.Close()
+
+	var layers []*commitGraphFile
+	closeLayers := func() {
+		for _, g := range layers {
+			g.Close()
+		}
+	}
+	s := bufio.NewScanner(chain)
+	for s.Scan() {
+		hash := strings.TrimSpace(s.Text())
+		if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+			closeLayers()
+			return nil, errInvalidCommitGraph
+		}
+		g, err := openCommitGraphFile(filepath.Join(graphsDir, "graph-"+hash+".graph"))
+		if err != nil {
+			closeLayers()
+			return nil, err
+		}
+		layers = append(layers, g)
+	}
+	if err := s.Err(); err != nil {
+		closeLayers()
+		return nil, err
+	}
+	return layers, nil
+}
@@ -0,0 +1,136 @@
+package gitcmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCommitGraphFile tests reading the generation numbers in
+// commit-graph files written by git, against the topological levels
+// computed from `git rev-list --parents`.
+func TestCommitGraphFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitcmd-commit-graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(c string) string {
+		cmd := exec.Command("bash", "-c", c)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("exec %q failed: %s. Output was:\n\n%s", c, err, out)
+		}
+		return string(out)
+	}
+	// commit adds n commits to the current branch, the first of
+	// which merges the given branch (if any).
+	var numCommits int
+	commit := func(n int, merge string) {
+		for i := 0; i < n; i++ {
+			numCommits++
+			if i == 0 && merge != "" {
+				run(fmt.Sprintf("git merge -q --no-ff -m %d %s", numCommits, merge))
+				continue
+			}
+			run(fmt.Sprintf("git commit -q --allow-empty -m %d", numCommits))
+		}
+	}
+
+	// levels returns the topological level of each commit: 1 for a
+	// root commit, and otherwise 1 more than its parents' maximum.
+	levels := func() map[string]uint32 {
+		lines := strings.Split(strings.TrimSpace(run("git rev-list --reverse --topo-order --parents --all")), "\n")
+		levels := make(map[string]uint32, len(lines))
+		for _, line := range lines {
+			ids := strings.Fields(line)
+			var level uint32
+			for _, p := range ids[1:] {
+				if levels[p] > level {
+					level = levels[p]
+				}
+			}
+			levels[ids[0]] = level + 1
+		}
+		return levels
+	}
+
+	// check checks that the commit-graph has the generation number
+	// of each commit in want.
+	check := func(label string, want map[string]uint32) {
+		layers, err := openCommitGraph(filepath.Join(dir, ".git/objects/info"))
+		if err != nil {
+			t.Fatalf("%s: %s", label, err)
+		}
+		defer func() {
+			for _, g := range layers {
+				g.Close()
+			}
+		}()
+		if len(layers) == 0 {
+			t.Fatalf("%s: got no commit-graph files", label)
+		}
+
+		for id, level := range want {
+			oid, err := hex.DecodeString(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gen uint32
+			for _, g := range layers {
+				if gen, err = g.generation(oid); err != nil {
+					t.Fatalf("%s: %s", label, err)
+				} else if gen != 0 {
+					break
+				}
+			}
+			if gen != level {
+				t.Errorf("%s: commit %s: got generation %d, want %d", label, id, gen, level)
+			}
+		}
+
+		// Commits that aren't in the file have no generation number.
+		if gen, err := layers[0].generation(make([]byte, 20)); err != nil || gen != 0 {
+			t.Errorf("%s: nonexistent commit: got generation %d (error %v), want 0", label, gen, err)
+		}
+	}
+
+	// Create enough commits (on several branches, with merges) that
+	// their IDs span many fanout entries.
+	run("git init -q && git symbolic-ref HEAD refs/heads/master")
+	commit(5, "")
+	for i := 0; i < 4; i++ {
+		branch := fmt.Sprintf("b%d", i)
+		run("git checkout -q -b " + branch + " master~" + fmt.Sprint(i))
+		commit(3+i, "")
+		run("git checkout -q master")
+		commit(2, branch)
+	}
+
+	for _, version := range []string{"1", "2"} {
+		label := "generationVersion=" + version
+		run("git -c commitGraph.generationVersion=" + version + " commit-graph write --reachable")
+		check(label, levels())
+	}
+
+	// Commits added later are in a new layer of a split commit-graph
+	// chain.
+	run("rm -f .git/objects/info/commit-graph && git commit-graph write --reachable --split")
+	run("git checkout -q -b c master~3")
+	commit(4, "")
+	run("git checkout -q master")
+	commit(3, "c")
+	run("git commit-graph write --reachable --split=no-merge")
+	if layers := strings.Fields(run("cat .git/objects/info/commit-graphs/commit-graph-chain")); len(layers) != 2 {
+		t.Fatalf("got %d commit-graph layers, want 2", len(layers))
+	}
+	check("split", levels())
+}
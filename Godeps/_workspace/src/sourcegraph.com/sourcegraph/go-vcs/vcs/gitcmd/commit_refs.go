@@ -0,0 +1,44 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.CommitRefsLister = (*Repository)(nil)
+
+func (r *Repository) RefsForCommit(commit vcs.CommitID) (branches []string, tags []string, err error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.Command("git", "for-each-ref", "--points-at="+string(commit), "--format=%(refname)", "--", "refs/heads/", "refs/tags/")
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(commit)) {
+			return nil, nil, vcs.ErrCommitNotFound
+		}
+		return nil, nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+
+	for _, ref := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+		if name := strings.TrimPrefix(ref, "refs/heads/"); name != ref {
+			branches = append(branches, name)
+		} else if name := strings.TrimPrefix(ref, "refs/tags/"); name != ref {
+			tags = append(tags, name)
+		}
+	}
+	sort.Strings(branches)
+	sort.Strings(tags)
+	return branches, tags, nil
+}
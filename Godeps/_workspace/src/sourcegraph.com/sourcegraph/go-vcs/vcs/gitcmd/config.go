@@ -0,0 +1,55 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.Configurer = (*Repository)(nil)
+
+func (r *Repository) GetConfig(key string) (string, error) {
+	if !vcs.ValidConfigKey(key) {
+		return "", vcs.ErrInvalidConfigKey
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "config", "--local", "--get", "--", key)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		// git config exits with status 1 if the key is not set.
+		if exitStatus(err) == 1 {
+			return "", vcs.ErrConfigKeyNotFound
+		}
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (r *Repository) SetConfig(key, value string) error {
+	if !vcs.ValidConfigKey(key) {
+		return vcs.ErrInvalidConfigKey
+	}
+	if !vcs.SettableConfigKey(key) {
+		return vcs.ErrConfigKeyNotSettable
+	}
+	if strings.ContainsRune(value, '\x00') {
+		return fmt.Errorf("invalid value for config key %q (contains NUL)", key)
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	cmd := exec.Command("git", "config", "--local", "--", key, value)
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
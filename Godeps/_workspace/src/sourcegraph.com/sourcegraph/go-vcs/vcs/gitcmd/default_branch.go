@@ -0,0 +1,125 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.DefaultBrancher = (*Repository)(nil)
+
+func (r *Repository) DefaultBranch() (string, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	refs, err := r.forEachRef("refs/heads/")
+	if err != nil {
+		return "", err
+	}
+	branches := make(map[string]string, len(refs)) // branch name -> commit ID
+	for _, ref := range refs {
+		branches[strings.TrimPrefix(ref[1], "refs/heads/")] = ref[0]
+	}
+
+	// Use the branch that HEAD points to, if it exists.
+	headRef, err := r.symbolicRef("HEAD")
+	if err != nil {
+		return "", err
+	}
+	if name := strings.TrimPrefix(headRef, "refs/heads/"); name != headRef {
+		if _, ok := branches[name]; ok {
+			return name, nil
+		}
+	}
+
+	if len(branches) == 0 {
+		if empty, err := r.isEmpty(); err != nil {
+			return "", err
+		} else if empty {
+			return "", vcs.ErrRepoEmpty
+		}
+		return "", vcs.ErrBranchNotFound
+	}
+
+	// Use the remote's default branch, as recorded by `git clone` or
+	// `git remote set-head`.
+	ref, err := r.symbolicRef("refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", err
+	}
+	if name := strings.TrimPrefix(ref, "refs/remotes/origin/"); name != ref {
+		if _, ok := branches[name]; ok {
+			return name, nil
+		}
+	}
+
+	// If HEAD is detached, use the branch it's at (preferring the
+	// conventional default branch names if several are).
+	if headRef == "" {
+		head, err := r.revParse("HEAD")
+		if err != nil {
+			return "", err
+		}
+		var atHead []string
+		for name, commitID := range branches {
+			if commitID == head {
+				atHead = append(atHead, name)
+			}
+		}
+		if len(atHead) == 1 {
+			return atHead[0], nil
+		}
+		for _, name := range []string{"master", "main"} {
+			if commitID, ok := branches[name]; ok && commitID == head {
+				return name, nil
+			}
+		}
+	}
+
+	if len(branches) == 1 {
+		for name := range branches {
+			return name, nil
+		}
+	}
+	for _, name := range []string{"master", "main"} {
+		if _, ok := branches[name]; ok {
+			return name, nil
+		}
+	}
+	return "", vcs.ErrBranchNotFound
+}
+
+// symbolicRef returns the ref that the symbolic ref name points to,
+// or "" if name is not a symbolic ref (or does not exist). The caller
+// must be holding r.editLock.
+func (r *Repository) symbolicRef(name string) (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "-q", "--", name)
+	cmd.Dir = r.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit status of 1 means name is not a symbolic ref.
+		if exitStatus(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("exec %v in %s failed: %s", cmd.Args, cmd.Dir, err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// revParse returns the commit ID that spec refers to, or "" if it
+// does not refer to a commit. The caller must be holding r.editLock.
+func (r *Repository) revParse(spec string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "-q", "--verify", spec+"^{commit}")
+	cmd.Dir = r.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitStatus(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("exec %v in %s failed: %s", cmd.Args, cmd.Dir, err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
@@ -0,0 +1,127 @@
+package gitcmd
+
+import (
+	"bytes"
+	"strings"
+)
+
+// revNotFoundPrefixes are the beginnings of the messages (after
+// "fatal: " or "error: ") with which git commands report that a
+// revision, which follows the prefix (possibly quoted), doesn't name
+// an object. Different git versions and commands phrase this
+// differently.
+var revNotFoundPrefixes = []string{
+	"bad object ",
+	"bad revision ",
+	"ambiguous argument ", // only if followed by ": unknown revision ..."
+	"Not a valid object name ",
+	"Not a valid commit name ",
+	"invalid object name ",
+	"malformed object name ",
+	"no such commit ",
+	"failed to resolve ",
+	"Invalid revision range ",
+	"Invalid symmetric difference expression ",
+}
+
+// revNotFoundSuffixes are the ends of the messages with which git
+// commands report that a revision, which precedes the suffix
+// (possibly quoted), doesn't name an object.
+var revNotFoundSuffixes = []string{
+	" is neither a commit nor blob",
+	": could not get object info",
+}
+
+// revNotFoundMessages are messages with which git commands report that
+// the revision they were given doesn't name an object, without naming
+// the revision.
+var revNotFoundMessages = []string{
+	"git cat-file: could not get object info", // newer versions of git
+}
+
+// pathNotFoundMessages are the parts of the messages with which git
+// commands report that a path doesn't exist in a tree.
+var pathNotFoundMessages = []string{
+	"exists on disk, but not in",
+	" does not exist in ",
+	"does not exist (neither on disk nor in the index)",
+	"did not match any file",
+}
+
+// isRevNotFoundOutput reports whether the standard error output of a
+// failed git command says that one of revs doesn't name an object
+// (e.g., "fatal: bad object <rev>" or "fatal: ambiguous argument
+// '<rev>': unknown revision or path not in the working tree."). A rev
+// that is the beginning of a range or is followed by a suffix (such
+// as "^{commit}") in the message matches too. If revs is empty, a
+// message about any revision matches. Messages that don't name the
+// revision at all (see revNotFoundMessages) always match.
+func isRevNotFoundOutput(stderr []byte, revs ...string) bool {
+	for _, line := range strings.Split(string(bytes.TrimSpace(stderr)), "\n") {
+		line = strings.TrimSpace(line)
+		msg := strings.TrimPrefix(strings.TrimPrefix(line, "fatal: "), "error: ")
+		for _, m := range revNotFoundMessages {
+			if msg == m {
+				return true
+			}
+		}
+		for _, prefix := range revNotFoundPrefixes {
+			if !strings.HasPrefix(msg, prefix) {
+				continue
+			}
+			if prefix == "ambiguous argument " && !strings.Contains(msg, ": unknown revision") {
+				continue
+			}
+			if startsWithRev(strings.TrimPrefix(msg[len(prefix):], "'"), revs) {
+				return true
+			}
+		}
+		for _, suffix := range revNotFoundSuffixes {
+			if !strings.HasSuffix(msg, suffix) {
+				continue
+			}
+			rest := strings.TrimSuffix(strings.TrimSuffix(msg, suffix), "'")
+			if len(revs) == 0 {
+				return true
+			}
+			for _, rev := range revs {
+				if rev != "" && strings.HasSuffix(rest, rev) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// startsWithRev reports whether s begins with one of revs, followed by
+// the end of s or by a character that can't be part of the revision
+// name in git's messages. If revs is empty, it returns true.
+func startsWithRev(s string, revs []string) bool {
+	if len(revs) == 0 {
+		return true
+	}
+	for _, rev := range revs {
+		if rev == "" || !strings.HasPrefix(s, rev) {
+			continue
+		}
+		if rest := s[len(rev):]; rest == "" || strings.ContainsAny(rest[:1], "'.:^~ ") {
+			return true
+		}
+	}
+	return false
+}
+
+// isPathNotFoundOutput reports whether the standard error output of a
+// failed git command says that a path doesn't exist in a tree (e.g.,
+// "fatal: path 'a' does not exist in 'master'" or, if the path exists
+// in the working tree, "fatal: path 'a' exists on disk, but not in
+// 'master'").
+func isPathNotFoundOutput(stderr []byte) bool {
+	for _, msg := range pathNotFoundMessages {
+		if bytes.Contains(stderr, []byte(msg)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package gitcmd
+
+import "testing"
+
+func TestIsRevNotFoundOutput(t *testing.T) {
+	const (
+		id   = "f6b7e0b5d64c1c1c80ba5f4a24e1e6fbcbe6c1b0"
+		base = "a4d1e9bd32b98b5e3c1c7d7f0df4c6b8d3a9b3cc"
+	)
+	tests := []struct {
+		stderr string
+		revs   []string
+		want   bool
+	}{
+		// git log, show, diff, and rev-list of a missing object
+		// (all versions).
+		{"fatal: bad object " + id, []string{id}, true},
+		{"fatal: bad object " + id + "\n", []string{id}, true},
+
+		// git show and rev-list of an unknown revision (git 1.x
+		// quotes it, git >= 2.x may too).
+		{"fatal: bad revision '" + id + "'", []string{id}, true},
+		{"fatal: bad revision " + id, []string{id}, true},
+
+		// git rev-parse and log of an unknown branch name.
+		{"fatal: ambiguous argument 'nobranch^{commit}': unknown revision or path not in the working tree.\nUse '--' to separate paths from revisions, like this:\n'git <command> [<revision>...] -- [<file>...]'", []string{"nobranch^{commit}"}, true},
+		{"fatal: ambiguous argument 'nobranch': unknown revision or path not in the working tree.", []string{"nobranch"}, true},
+
+		// git merge-base and describe of a missing commit (older
+		// versions say "commit name", newer "object name").
+		{"fatal: Not a valid commit name " + id, []string{base, id}, true},
+		{"fatal: Not a valid object name " + id, []string{base, id}, true},
+		{"fatal: Not a valid object name '" + id + "'", []string{id}, true},
+		{"fatal: invalid object name '" + id + "'.", []string{id}, true},
+
+		// git describe of a missing commit (git 2.x).
+		{"fatal: " + id + " is neither a commit nor blob", []string{id}, true},
+
+		// git cat-file -t of a missing object (newer versions omit
+		// the object name).
+		{"fatal: git cat-file " + id + ": could not get object info", []string{id}, true},
+		{"fatal: git cat-file: could not get object info", []string{id}, true},
+
+		// git branch --contains and for-each-ref --points-at of a
+		// missing commit.
+		{"error: malformed object name " + id, []string{id}, true},
+		{"error: no such commit " + id, []string{id}, true},
+		{"error: malformed object name " + id, nil, true},
+
+		// git notes show of a missing commit.
+		{"error: failed to resolve '" + id + "' as a valid ref.", []string{id}, true},
+		{"fatal: failed to resolve '" + id + "' as a valid ref.", []string{id}, true},
+
+		// git log and diff of a range whose base or head is
+		// missing.
+		{"fatal: Invalid revision range " + base + ".." + id, []string{id, base}, true},
+		{"fatal: Invalid symmetric difference expression " + base + "..." + id, []string{base, id}, true},
+		{"fatal: bad revision '" + base + "..." + id + "'", []string{base, id}, true},
+
+		// Messages about other revisions, or that aren't about
+		// missing revisions.
+		{"fatal: bad object " + base, []string{id}, false},
+		{"fatal: bad object " + id + "0", []string{id}, false},
+		{"fatal: ambiguous argument 'nobranch': both revision and filename", []string{"nobranch"}, false},
+		{"fatal: not a git repository (or any of the parent directories): .git", []string{id}, false},
+		{"fatal: unable to read tree " + id, []string{id}, false},
+		{"error: no note found for object " + id + ".", []string{id}, false},
+		{"", []string{id}, false},
+		{"fatal: bad object " + id, []string{""}, false},
+	}
+	for _, test := range tests {
+		if got := isRevNotFoundOutput([]byte(test.stderr), test.revs...); got != test.want {
+			t.Errorf("%q (revs %q): got %v, want %v", test.stderr, test.revs, got, test.want)
+		}
+	}
+}
+
+func TestIsPathNotFoundOutput(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		// git show <rev>:<path> (git 1.x capitalizes "Path").
+		{"fatal: Path 'a/b' does not exist in 'master'", true},
+		{"fatal: path 'a/b' does not exist in 'master'", true},
+		{"fatal: Path 'a/b' exists on disk, but not in 'master'.", true},
+		{"fatal: path 'a/b' exists on disk, but not in 'f6b7e0b5d64c1c1c80ba5f4a24e1e6fbcbe6c1b0'", true},
+		{"fatal: path 'a/b' does not exist (neither on disk nor in the index)", true},
+
+		// git archive and ls-tree of a missing path.
+		{"error: pathspec 'a/b' did not match any files", true},
+		{"error: pathspec 'a/b' did not match any file(s) known to git.", true},
+
+		{"fatal: bad object f6b7e0b5d64c1c1c80ba5f4a24e1e6fbcbe6c1b0", false},
+		{"fatal: not a tree object", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := isPathNotFoundOutput([]byte(test.stderr)); got != test.want {
+			t.Errorf("%q: got %v, want %v", test.stderr, got, test.want)
+		}
+	}
+}
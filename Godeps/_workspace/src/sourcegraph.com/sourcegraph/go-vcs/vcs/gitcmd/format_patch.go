@@ -0,0 +1,67 @@
+package gitcmd
+
+import (
+	"io"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.PatchFormatter = (*Repository)(nil)
+
+func (r *Repository) FormatPatch(head vcs.CommitID, opt vcs.FormatPatchOptions) (io.ReadCloser, error) {
+	if err := checkSpecArgSafety(string(head)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return nil, err
+	}
+
+	r.editLock.RLock()
+	unlock := r.editLock.RUnlock
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
+
+	// Check the commits first, since errors can't be reported once
+	// the patches are being streamed.
+	for _, commit := range []vcs.CommitID{head, opt.Base} {
+		if commit != "" && !r.commitExists(commit) {
+			return nil, vcs.ErrCommitNotFound
+		}
+	}
+
+	var args []string
+	if opt.IncludeMerges {
+		// git format-patch always omits merges, but git log can
+		// produce the same format.
+		args = []string{"log", "--pretty=email", "--patch", "--stat", "--reverse", "--first-parent", "--diff-merges=first-parent"}
+	} else {
+		args = []string{"format-patch", "--stdout"}
+	}
+	if opt.Base != "" {
+		args = append(args, string(opt.Base)+".."+string(head))
+	} else {
+		args = append(args, "-1", string(head))
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	rc, err := startCmdReadCloser(cmd, unlock)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the repository locked until the caller is done reading.
+	unlock = nil
+	return rc, nil
+}
+
+// commitExists returns whether spec refers to a commit. The caller
+// must hold r.editLock.
+func (r *Repository) commitExists(spec vcs.CommitID) bool {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", string(spec)+"^{commit}")
+	cmd.Dir = r.Dir
+	return cmd.Run() == nil
+}
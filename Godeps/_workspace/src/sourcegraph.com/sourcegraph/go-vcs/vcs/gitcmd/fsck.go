@@ -0,0 +1,77 @@
+package gitcmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.Fscker = (*Repository)(nil)
+
+func (r *Repository) Fsck() (vcs.FsckReport, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "fsck", "--full", "--no-progress")
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	problems := parseFsckOutput(append(out, stderr...))
+	if err != nil && len(problems) == 0 {
+		// git fsck exits with a nonzero status when it finds
+		// problems, so only fail if it didn't report any.
+		return vcs.FsckReport{}, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+	return vcs.FsckReport{Problems: problems}, nil
+}
+
+var (
+	// fsckObjectPattern matches "dangling blob <id>" and "missing
+	// tree <id>" lines.
+	fsckObjectPattern = regexp.MustCompile(`^(dangling|missing) (commit|tree|blob|tag) ([0-9a-f]{40,64})$`)
+
+	// fsckLinkPattern matches each of the 2 lines of a broken link
+	// ("broken link from  commit <id>" and "to    tree <id>").
+	fsckLinkPattern = regexp.MustCompile(`^(broken link from|to)\s+(commit|tree|blob|tag) ([0-9a-f]{40,64})$`)
+
+	// fsckErrorPattern matches "error: <msg>" and "warning in tree
+	// <id>: <msg>" lines.
+	fsckErrorPattern = regexp.MustCompile(`^(error|fatal|warning)(?: in (commit|tree|blob|tag) ([0-9a-f]{40,64}))?: (.*)$`)
+
+	objectIDPattern = regexp.MustCompile(`\b[0-9a-f]{40}(?:[0-9a-f]{24})?\b`)
+)
+
+// parseFsckOutput parses the output (stdout and stderr) of `git fsck`
+// into the problems it reports. Informational lines are ignored.
+func parseFsckOutput(out []byte) []*vcs.FsckProblem {
+	var problems []*vcs.FsckProblem
+	var linkFrom string // first line of a broken link
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if m := fsckObjectPattern.FindStringSubmatch(line); m != nil {
+			problems = append(problems, &vcs.FsckProblem{Kind: vcs.FsckProblemKind(m[1]), ObjectType: m[2], ObjectID: m[3], Message: line})
+		} else if m := fsckLinkPattern.FindStringSubmatch(line); m != nil {
+			if m[1] == "broken link from" {
+				linkFrom = m[2] + " " + m[3]
+			} else if linkFrom != "" {
+				problems = append(problems, &vcs.FsckProblem{Kind: vcs.FsckBrokenLink, ObjectType: m[2], ObjectID: m[3], Message: "broken link from " + linkFrom + " to " + m[2] + " " + m[3]})
+				linkFrom = ""
+			}
+		} else if m := fsckErrorPattern.FindStringSubmatch(line); m != nil {
+			p := &vcs.FsckProblem{Kind: vcs.FsckCorrupt, ObjectType: m[2], ObjectID: m[3], Message: m[4]}
+			if m[1] == "warning" {
+				p.Kind = vcs.FsckWarning
+			}
+			if p.ObjectID == "" {
+				p.ObjectID = objectIDPattern.FindString(m[4])
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
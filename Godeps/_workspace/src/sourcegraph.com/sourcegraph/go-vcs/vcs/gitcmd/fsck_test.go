@@ -0,0 +1,42 @@
+package gitcmd
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestParseFsckOutput(t *testing.T) {
+	// The output of `git fsck --full` of a repository with a corrupt
+	// blob 45b983b..., a missing tree df55a7d..., and a dangling blob
+	// 8809af5....
+	out := `error: inflate: data stream error (incorrect header check)
+error: unable to unpack header of .git/objects/45/b983be36b73c0788dc9cbcb76cbb80fc7bb057
+error: 45b983be36b73c0788dc9cbcb76cbb80fc7bb057: object corrupt or missing: .git/objects/45/b983be36b73c0788dc9cbcb76cbb80fc7bb057
+broken link from  commit b6cf5f9de9679c324d6ed59c3c83722192d45b28
+              to    tree df55a7dce59d040dc7819c1e241082965a80ebd9
+missing blob 45b983be36b73c0788dc9cbcb76cbb80fc7bb057
+dangling blob 8809af5ee92edf4d22dd92e81dcbf04280a04a1f
+missing tree df55a7dce59d040dc7819c1e241082965a80ebd9
+warning in tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904: hasDotgit: contains '.git'
+notice: HEAD points to an unborn branch (master)
+`
+	want := []*vcs.FsckProblem{
+		{Kind: vcs.FsckCorrupt, Message: "inflate: data stream error (incorrect header check)"},
+		{Kind: vcs.FsckCorrupt, Message: "unable to unpack header of .git/objects/45/b983be36b73c0788dc9cbcb76cbb80fc7bb057"},
+		{Kind: vcs.FsckCorrupt, ObjectID: "45b983be36b73c0788dc9cbcb76cbb80fc7bb057", Message: "45b983be36b73c0788dc9cbcb76cbb80fc7bb057: object corrupt or missing: .git/objects/45/b983be36b73c0788dc9cbcb76cbb80fc7bb057"},
+		{Kind: vcs.FsckBrokenLink, ObjectType: "tree", ObjectID: "df55a7dce59d040dc7819c1e241082965a80ebd9", Message: "broken link from commit b6cf5f9de9679c324d6ed59c3c83722192d45b28 to tree df55a7dce59d040dc7819c1e241082965a80ebd9"},
+		{Kind: vcs.FsckMissing, ObjectType: "blob", ObjectID: "45b983be36b73c0788dc9cbcb76cbb80fc7bb057", Message: "missing blob 45b983be36b73c0788dc9cbcb76cbb80fc7bb057"},
+		{Kind: vcs.FsckDangling, ObjectType: "blob", ObjectID: "8809af5ee92edf4d22dd92e81dcbf04280a04a1f", Message: "dangling blob 8809af5ee92edf4d22dd92e81dcbf04280a04a1f"},
+		{Kind: vcs.FsckMissing, ObjectType: "tree", ObjectID: "df55a7dce59d040dc7819c1e241082965a80ebd9", Message: "missing tree df55a7dce59d040dc7819c1e241082965a80ebd9"},
+		{Kind: vcs.FsckWarning, ObjectType: "tree", ObjectID: "4b825dc642cb6eb9a060e54bf8d69288fbee4904", Message: "hasDotgit: contains '.git'"},
+	}
+	if problems := parseFsckOutput([]byte(out)); !reflect.DeepEqual(problems, want) {
+		t.Errorf("got problems\n%s\n\nwant\n%s", asJSON(problems), asJSON(want))
+	}
+
+	if problems := parseFsckOutput(nil); problems != nil {
+		t.Errorf("got problems %v for no output, want none", problems)
+	}
+}
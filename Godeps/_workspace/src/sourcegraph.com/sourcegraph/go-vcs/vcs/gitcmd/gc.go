@@ -0,0 +1,27 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.GarbageCollector = (*Repository)(nil)
+
+func (r *Repository) GC(opt vcs.GCOptions) error {
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	args := []string{"gc", "--quiet"}
+	if opt.Aggressive {
+		args = append(args, "--aggressive")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
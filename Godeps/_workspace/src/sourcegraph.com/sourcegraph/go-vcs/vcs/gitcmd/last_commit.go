@@ -0,0 +1,233 @@
+package gitcmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+var _ vcs.LastCommitter = (*Repository)(nil)
+
+func (r *Repository) LastCommits(at vcs.CommitID, paths []string) ([]*vcs.Commit, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(at)); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(paths))
+	var walkNames []string
+	for i, path := range paths {
+		name := filepath.Clean(internal.Rel(path))
+		if name == ".." || strings.HasPrefix(name, "../") {
+			// Outside the repository, so no commit changed it.
+			continue
+		}
+		names[i] = name
+		walkNames = append(walkNames, name)
+	}
+
+	changes, stderr, err := r.lastChanges(string(at), walkNames, "%H")
+	if err != nil {
+		if empty, _ := r.isEmpty(); empty {
+			return nil, vcs.ErrRepoEmpty
+		}
+		if isRevNotFoundOutput(stderr, string(at)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec `git log` failed: %s. Output was:\n\n%s", err, stderr)
+	}
+
+	// Read all of the commits at once.
+	commits := map[vcs.CommitID]*vcs.Commit{}
+	args := []string{"log", "--no-walk=unsorted", "--date=raw", "--no-use-mailmap", commitLogFormat}
+	for _, id := range changes {
+		if _, seen := commits[vcs.CommitID(id)]; !seen {
+			commits[vcs.CommitID(id)] = nil
+			args = append(args, id)
+		}
+	}
+	if len(commits) > 0 {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = r.Dir
+		out, stderr, err := dividedOutput(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("exec `git log --no-walk` failed: %s. Output was:\n\n%s", err, stderr)
+		}
+		br := bufio.NewReader(bytes.NewReader(out))
+		for {
+			commit, err := readLogCommit(br, false, false)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			commits[commit.ID] = commit
+		}
+	}
+
+	lastCommits := make([]*vcs.Commit, len(paths))
+	for i, name := range names {
+		if id, ok := changes[name]; ok && name != "" {
+			lastCommits[i] = commits[vcs.CommitID(id)]
+		}
+	}
+	return lastCommits, nil
+}
+
+// lastChanges walks the history of at in a single `git log` (which
+// stops as soon as it has seen a commit that changed each of the
+// paths) and returns, for each path, the latest commit that changed
+// it (or any file in it), printed with the `git log` format (which
+// must not print a tab or newline). Paths that no commit changed are
+// omitted. If `git log` fails, its standard error output is returned.
+func (r *Repository) lastChanges(at string, paths []string, format string) (changes map[string]string, stderr []byte, err error) {
+	changes = make(map[string]string, len(paths))
+	if len(paths) == 0 {
+		return changes, nil, nil
+	}
+	pending := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		pending[path] = struct{}{}
+	}
+
+	// Each commit is printed as "\x00ID PARENTS\tFORMAT\nFILE1\x00FILE2\x00...",
+	// followed by a "\x00" separator. With -m, a merge commit is
+	// printed once for each parent that it differs from, with the
+	// files that differ from that parent.
+	cmd := exec.Command("git", "log", "-m", "-z", "--name-only", "--format=format:%x00%H %P%x09"+format, at, "--")
+	cmd.Args = append(cmd.Args, paths...)
+	cmd.Dir = r.Dir
+	var stderrBuf bytes.Buffer
+	cmd.Stderr = &stderrBuf
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		commit   logChange
+		value    string // the formatted commit
+		finished bool
+	)
+	// flush attributes the current commit to the pending paths that
+	// it changed.
+	flush := func() {
+		for path := range commit.changed() {
+			if _, ok := pending[path]; ok {
+				changes[path] = value
+				delete(pending, path)
+			}
+		}
+	}
+
+	br := bufio.NewReader(stdout)
+	var header bool
+	for len(pending) > 0 {
+		tok, err := br.ReadString('\x00')
+		if err == io.EOF && tok == "" {
+			flush()
+			finished = true
+			break
+		} else if err != nil && err != io.EOF {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, nil, err
+		}
+		tok = strings.TrimSuffix(tok, "\x00")
+		if tok == "" {
+			header = true
+			continue
+		}
+		if header {
+			header = false
+			var hdr string
+			hdr, tok = tok, ""
+			if i := strings.IndexByte(hdr, '\n'); i != -1 {
+				hdr, tok = hdr[:i], hdr[i+1:]
+			}
+			tab := strings.IndexByte(hdr, '\t')
+			if tab == -1 {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return nil, nil, fmt.Errorf("invalid `git log` commit header: %q", hdr)
+			}
+			ids := strings.Fields(hdr[:tab])
+			if len(ids) == 0 {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return nil, nil, fmt.Errorf("invalid `git log` commit header: %q", hdr)
+			}
+			if ids[0] != commit.id {
+				flush()
+				commit = logChange{id: ids[0], parents: len(ids) - 1}
+				value = hdr[tab+1:]
+			}
+			commit.diffs = append(commit.diffs, nil)
+		}
+		if tok != "" && len(commit.diffs) > 0 {
+			commit.diffs[len(commit.diffs)-1] = append(commit.diffs[len(commit.diffs)-1], tok)
+		}
+	}
+
+	if !finished {
+		// Don't wait for git to print the rest of the history.
+		cmd.Process.Kill()
+		cmd.Wait()
+		return changes, nil, nil
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, stderrBuf.Bytes(), err
+	}
+	return changes, nil, nil
+}
+
+// A logChange is a commit printed by `git log -m --name-only`.
+type logChange struct {
+	id      string
+	parents int        // number of parents
+	diffs   [][]string // the files that differ from each parent it was printed for
+}
+
+// changed returns the set of paths (files and their parent dirs,
+// including ".") that the commit changed: for a merge commit, those
+// that differ from all of its parents.
+func (c *logChange) changed() map[string]struct{} {
+	if c.parents > 1 && len(c.diffs) < c.parents {
+		// The commit is identical to one of its parents.
+		return nil
+	}
+	var changed map[string]struct{}
+	for i, files := range c.diffs {
+		dirs := map[string]struct{}{}
+		for _, file := range files {
+			for name := file; ; name = pathpkg.Dir(name) {
+				if _, seen := dirs[name]; seen {
+					break
+				}
+				if i == 0 {
+					dirs[name] = struct{}{}
+				} else if _, ok := changed[name]; ok {
+					dirs[name] = struct{}{}
+				}
+				if name == "." {
+					break
+				}
+			}
+		}
+		changed = dirs
+	}
+	return changed
+}
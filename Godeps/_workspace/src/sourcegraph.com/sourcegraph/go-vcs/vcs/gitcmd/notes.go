@@ -0,0 +1,44 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.NotesGetter = (*Repository)(nil)
+
+func (r *Repository) GetNotes(commit vcs.CommitID, ref string) (string, error) {
+	if ref == "" {
+		ref = "commits"
+	}
+	if !vcs.ValidRefName(ref) {
+		return "", vcs.ErrInvalidRefName
+	}
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return "", err
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "notes", "--ref="+ref, "show", string(commit))
+	cmd.Dir = r.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		switch {
+		case strings.HasPrefix(msg, "error: no note found for object"):
+			return "", vcs.ErrNoteNotFound
+		case isRevNotFoundOutput([]byte(msg), string(commit)):
+			return "", vcs.ErrCommitNotFound
+		}
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, msg)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
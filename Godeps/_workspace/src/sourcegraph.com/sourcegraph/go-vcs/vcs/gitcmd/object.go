@@ -0,0 +1,102 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.ObjectGetter = (*Repository)(nil)
+
+func (r *Repository) GetObject(id string) (string, io.ReadCloser, error) {
+	if !vcs.ValidObjectID(id) {
+		return "", nil, vcs.ErrInvalidObjectID
+	}
+
+	r.editLock.RLock()
+	unlock := r.editLock.RUnlock
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
+
+	cmd := exec.Command("git", "cat-file", "-t", id)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		if isRevNotFoundOutput(stderr, id) {
+			return "", nil, vcs.ErrObjectNotFound
+		}
+		return "", nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+	typ := string(bytes.TrimSpace(out))
+
+	// Stream blobs (which may be large) as-is, and pretty-print other
+	// objects.
+	args := []string{"cat-file", "-p", id}
+	if typ == "blob" {
+		args = []string{"cat-file", "blob", id}
+	}
+	cmd = exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	rc, err := startCmdReadCloser(cmd, unlock)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Keep the repository locked until the caller is done reading.
+	unlock = nil
+	return typ, rc, nil
+}
+
+// cmdReadCloser reads the output of a running command. Closing it
+// waits for the command to exit and then calls unlock.
+type cmdReadCloser struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	eof    bool // whether all of the output was read
+	unlock func()
+}
+
+// startCmdReadCloser starts cmd and returns a reader of its output.
+// Closing the reader calls unlock.
+func startCmdReadCloser(cmd *exec.Cmd, unlock func()) (*cmdReadCloser, error) {
+	rc := &cmdReadCloser{cmd: cmd, unlock: unlock}
+	cmd.Stderr = &rc.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	rc.ReadCloser = stdout
+	return rc, nil
+}
+
+func (rc *cmdReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(p)
+	if err == io.EOF {
+		rc.eof = true
+	}
+	return n, err
+}
+
+// Close stops the command (if it is still running) and returns its
+// error, if it failed after writing all of its output.
+func (rc *cmdReadCloser) Close() error {
+	err := rc.ReadCloser.Close()
+	waitErr := rc.cmd.Wait()
+	rc.unlock()
+	// The command fails with a broken pipe if the caller didn't read
+	// all of its output, which is not an error.
+	if err == nil && waitErr != nil && rc.eof {
+		err = fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", rc.cmd.Args, waitErr, bytes.TrimSpace(rc.stderr.Bytes()))
+	}
+	return err
+}
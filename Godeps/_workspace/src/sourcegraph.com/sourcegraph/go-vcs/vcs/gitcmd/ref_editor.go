@@ -0,0 +1,99 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.RefEditor = (*Repository)(nil)
+
+func (r *Repository) CreateBranch(name string, commit vcs.CommitID) error {
+	return r.createRef("refs/heads/", name, commit)
+}
+
+func (r *Repository) DeleteBranch(name string) error {
+	return r.deleteRef("refs/heads/", name, vcs.ErrBranchNotFound)
+}
+
+func (r *Repository) CreateTag(name string, commit vcs.CommitID) error {
+	return r.createRef("refs/tags/", name, commit)
+}
+
+func (r *Repository) DeleteTag(name string) error {
+	return r.deleteRef("refs/tags/", name, vcs.ErrTagNotFound)
+}
+
+// createRef creates the ref named prefix+name pointing to the commit.
+func (r *Repository) createRef(prefix, name string, commit vcs.CommitID) error {
+	if !vcs.ValidRefName(name) {
+		return vcs.ErrInvalidRefName
+	}
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return err
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	if r.refExists(prefix + name) {
+		return vcs.ErrRefExists
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", string(commit)+"^{commit}")
+	cmd.Dir = r.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return vcs.ErrCommitNotFound
+	}
+	commitID := string(bytes.TrimSpace(out))
+
+	if prefix == "refs/tags/" {
+		cmd = exec.Command("git", "tag", name, commitID)
+	} else {
+		// The empty old value makes update-ref fail if the ref exists.
+		cmd = exec.Command("git", "update-ref", prefix+name, commitID, "")
+	}
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// deleteRef deletes the ref named prefix+name, returning notFound if
+// it doesn't exist.
+func (r *Repository) deleteRef(prefix, name string, notFound error) error {
+	if !vcs.ValidRefName(name) {
+		return vcs.ErrInvalidRefName
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	if !r.refExists(prefix + name) {
+		return notFound
+	}
+
+	var cmd *exec.Cmd
+	if prefix == "refs/tags/" {
+		cmd = exec.Command("git", "tag", "-d", name)
+	} else {
+		cmd = exec.Command("git", "update-ref", "-d", prefix+name)
+	}
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// refExists returns whether the fully qualified ref exists. The caller
+// must hold r.editLock.
+func (r *Repository) refExists(ref string) bool {
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", ref)
+	cmd.Dir = r.Dir
+	return cmd.Run() == nil
+}
@@ -0,0 +1,149 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.Reflogger = (*Repository)(nil)
+
+func (r *Repository) Reflog(ref string) ([]*vcs.ReflogEntry, error) {
+	if !vcs.ValidRefName(ref) {
+		return nil, vcs.ErrInvalidRefName
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	// HEAD has its own reflog, which is distinct from the reflog of
+	// the branch it points to.
+	fullName := ref
+	if ref != "HEAD" {
+		cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "--symbolic-full-name", ref)
+		cmd.Dir = r.Dir
+		out, err := cmd.Output()
+		fullName = string(bytes.TrimSpace(out))
+		if err != nil || fullName == "" {
+			return nil, vcs.ErrRevisionNotFound
+		}
+	}
+
+	// `git reflog show` doesn't report the old value of each entry,
+	// so read the reflog file directly (its location is determined
+	// by git, so it is correct for bare repositories and worktrees).
+	cmd := exec.Command("git", "rev-parse", "--git-path", "logs/"+fullName)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+	logPath := string(bytes.TrimSpace(out))
+	if !filepath.IsAbs(logPath) {
+		logPath = filepath.Join(r.Dir, logPath)
+	}
+	data, err := ioutil.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return nil, vcs.ErrNoReflog
+	} else if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	entries := make([]*vcs.ReflogEntry, 0, len(lines))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == "" {
+			continue
+		}
+		e, err := parseReflogEntry(lines[i])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, vcs.ErrNoReflog
+	}
+	return entries, nil
+}
+
+var _ vcs.RevisionAtResolver = (*Repository)(nil)
+
+func (r *Repository) ResolveRevisionAt(ref string, t time.Time) (vcs.CommitID, error) {
+	if !vcs.ValidRefName(ref) {
+		return "", vcs.ErrInvalidRefName
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	// git parses the date in "@{...}" with its approxidate parser,
+	// which reads this format exactly (unlike, e.g., RFC 3339).
+	spec := ref + "@{" + t.UTC().Format("2006-01-02 15:04:05 -0700") + "}^{commit}"
+	cmd := exec.Command("git", "rev-parse", spec)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		if isRevNotFoundOutput(stderr, spec) {
+			// Either ref doesn't exist or it has no reflog.
+			cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+			cmd.Dir = r.Dir
+			if err := cmd.Run(); err == nil {
+				return "", vcs.ErrNoReflog
+			}
+			return "", r.emptyRepoErrorOr(vcs.ErrRevisionNotFound)
+		}
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+
+	// Instead of failing, git warns and resolves to the oldest
+	// reflog entry if the reflog doesn't go back far enough.
+	if bytes.Contains(stderr, []byte("only goes back to")) {
+		return "", vcs.ErrReflogTooShort
+	}
+	return vcs.CommitID(bytes.TrimSpace(out)), nil
+}
+
+// parseReflogEntry parses a reflog line of the form "<old> <new>
+// <name> <<email>> <timestamp> <tz>\t<message>".
+func parseReflogEntry(line string) (*vcs.ReflogEntry, error) {
+	header, msg := line, ""
+	if i := strings.IndexByte(line, '\t'); i != -1 {
+		header, msg = line[:i], line[i+1:]
+	}
+
+	parts := strings.SplitN(header, " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("bad reflog line: %q", line)
+	}
+	ident := parts[2]
+	emailStart, emailEnd := strings.LastIndexByte(ident, '<'), strings.LastIndexByte(ident, '>')
+	if emailStart == -1 || emailEnd < emailStart {
+		return nil, fmt.Errorf("bad reflog line: %q", line)
+	}
+	name := strings.TrimSpace(ident[:emailStart])
+	email := ident[emailStart+1 : emailEnd]
+
+	date, err := parseGitRawDate(ident[emailEnd+1:])
+	if err != nil {
+		return nil, fmt.Errorf("bad reflog line: %q: %s", line, err)
+	}
+
+	e := &vcs.ReflogEntry{
+		Old:     vcs.CommitID(parts[0]),
+		New:     vcs.CommitID(parts[1]),
+		Actor:   vcs.NewSignature(name, email, date),
+		Message: msg,
+	}
+	if i := strings.Index(msg, ": "); i != -1 {
+		e.Action, e.Message = msg[:i], msg[i+2:]
+	}
+	return e, nil
+}
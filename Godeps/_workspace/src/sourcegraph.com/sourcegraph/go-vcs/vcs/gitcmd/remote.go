@@ -0,0 +1,149 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.RemoteManager = (*Repository)(nil)
+
+func (r *Repository) AddRemote(name, url string) error {
+	if !vcs.ValidRemoteName(name) {
+		return vcs.ErrInvalidRemoteName
+	}
+	if !vcs.ValidRemoteURL(url) {
+		return vcs.ErrInvalidRemoteURL
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	if _, err := r.remoteURL(name); err == nil {
+		return vcs.ErrRemoteExists
+	} else if err != vcs.ErrRemoteNotFound {
+		return err
+	}
+
+	cmd := exec.Command("git", "remote", "add", "--", name, url)
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (r *Repository) RemoveRemote(name string) error {
+	if !vcs.ValidRemoteName(name) {
+		return vcs.ErrInvalidRemoteName
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	if _, err := r.remoteURL(name); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "remote", "remove", "--", name)
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (r *Repository) ListRemotes() ([]*vcs.Remote, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "config", "--local", "-z", "--get-regexp", `^remote\..*\.url$`)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		// git config exits with status 1 if no keys match.
+		if exitStatus(err) == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+
+	// Each entry is "remote.<name>.url\n<url>\x00".
+	var remotes []*vcs.Remote
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry == "" {
+			continue
+		}
+		key, url := entry, ""
+		if i := strings.Index(entry, "\n"); i != -1 {
+			key, url = entry[:i], entry[i+1:]
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")
+		remotes = append(remotes, &vcs.Remote{Name: name, URL: url})
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
+
+func (r *Repository) Fetch(remote string, opt vcs.RemoteOpts) error {
+	if !vcs.ValidRemoteName(remote) {
+		return vcs.ErrInvalidRemoteName
+	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	// Check that the remote exists, or else git would interpret its
+	// name as a URL or path.
+	if _, err := r.remoteURL(remote); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "fetch", "--", remote)
+	cmd.Dir = r.Dir
+
+	if opt.SSH != nil {
+		env, tmpFiles, err := makeGitSSHWrapper(opt.SSH)
+		defer removeSSHFiles(tmpFiles)
+		if err != nil {
+			return err
+		}
+		cmd.Env = env
+	}
+	if opt.HTTPS != nil {
+		askPass, env, err := makeGitAskPass(opt.HTTPS)
+		if askPass != "" {
+			defer os.Remove(askPass)
+		}
+		if err != nil {
+			return err
+		}
+		addEnv(cmd, env)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// remoteURL returns the URL of the remote named name, or
+// vcs.ErrRemoteNotFound if it doesn't exist. The caller must hold
+// r.editLock.
+func (r *Repository) remoteURL(name string) (string, error) {
+	cmd := exec.Command("git", "config", "--local", "--get", "--", "remote."+name+".url")
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		if exitStatus(err) == 1 {
+			return "", vcs.ErrRemoteNotFound
+		}
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
@@ -11,6 +11,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	pathpkg "path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -19,12 +20,13 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
-	"sourcegraph.com/sqs/pbtypes"
 
+	"golang.org/x/text/encoding/ianaindex"
 	"golang.org/x/tools/godoc/vfs"
 )
 
@@ -73,37 +75,146 @@ func Open(dir string) (*Repository, error) {
 }
 
 func Clone(url, dir string, opt vcs.CloneOpt) (*Repository, error) {
+	if err := checkSpecArgSafety(opt.Branch); err != nil {
+		return nil, err
+	}
+
 	args := []string{"clone"}
 	if opt.Bare {
 		args = append(args, "--bare")
 	}
-	if opt.Mirror {
+	singleBranch := opt.Depth > 0 || opt.Branch != ""
+	if singleBranch {
+		// --mirror fetches every ref, which defeats the purpose of a
+		// single-branch clone.
+		args = append(args, "--single-branch")
+		if opt.Branch != "" {
+			args = append(args, "--branch="+opt.Branch)
+		}
+		if opt.Depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(opt.Depth))
+		}
+	} else if opt.Mirror {
 		args = append(args, "--mirror")
 	}
+	if opt.Progress != nil {
+		args = append(args, "--progress")
+	}
 	args = append(args, "--", url, dir)
 	cmd := exec.Command("git", args...)
 
 	if opt.SSH != nil {
-		gitSSHWrapper, keyFile, err := makeGitSSHWrapper(opt.SSH.PrivateKey)
-		defer func() {
-			if keyFile != "" {
-				if err := os.Remove(keyFile); err != nil {
-					log.Fatalf("Error removing SSH key file %s: %s.", keyFile, err)
-				}
-			}
-		}()
+		env, tmpFiles, err := makeGitSSHWrapper(opt.SSH)
+		defer removeSSHFiles(tmpFiles)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = env
+	}
+	if opt.HTTPS != nil {
+		askPass, env, err := makeGitAskPass(opt.HTTPS)
+		if askPass != "" {
+			defer os.Remove(askPass)
+		}
 		if err != nil {
 			return nil, err
 		}
-		defer os.Remove(gitSSHWrapper)
-		cmd.Env = []string{"GIT_SSH=" + gitSSHWrapper}
+		addEnv(cmd, env)
 	}
 
-	out, err := cmd.CombinedOutput()
+	var out bytes.Buffer
+	var w io.Writer = &out
+	if opt.Progress != nil {
+		w = io.MultiWriter(&out, opt.Progress)
+	}
+	// Use the same writer for both, so that exec.Cmd writes them
+	// from a single goroutine.
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("exec `git clone` failed: %s. Output was:\n\n%s", err, out.Bytes())
+	}
+
+	r, err := Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if singleBranch {
+		if err := r.configureSingleBranch(opt); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+const (
+	// branchConfigKey and depthConfigKey are the git config keys that
+	// record the branch and depth of a single-branch clone, so that
+	// UpdateEverything fetches only that branch and with the same
+	// depth.
+	branchConfigKey = "vcs.branch"
+	depthConfigKey  = "vcs.depth"
+)
+
+// configureSingleBranch records the branch (and depth, for a shallow
+// clone) of a newly created single-branch clone. A bare clone has no
+// fetch refspec, so it also sets one that updates the branch in
+// place.
+func (r *Repository) configureSingleBranch(opt vcs.CloneOpt) error {
+	cmd := exec.Command("git", "symbolic-ref", "HEAD")
+	cmd.Dir = r.Dir
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("exec %v in %s failed: %s", cmd.Args, cmd.Dir, err)
+	}
+	ref := string(bytes.TrimSpace(out))
+
+	configs := [][2]string{{branchConfigKey, strings.TrimPrefix(ref, "refs/heads/")}}
+	if opt.Depth > 0 {
+		configs = append(configs, [2]string{depthConfigKey, strconv.Itoa(opt.Depth)})
+	}
+	if opt.Bare {
+		configs = append(configs, [2]string{"remote.origin.fetch", "+" + ref + ":" + ref})
+	}
+	for _, kv := range configs {
+		cmd := exec.Command("git", "config", kv[0], kv[1])
+		cmd.Dir = r.Dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("exec %v in %s failed: %s. Output was:\n\n%s", cmd.Args, cmd.Dir, err, out)
+		}
+	}
+	return nil
+}
+
+// getConfig returns the value of the git config key, or "" if it is
+// not set.
+func (r *Repository) getConfig(key string) (string, error) {
+	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = r.Dir
+	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("exec `git clone` failed: %s. Output was:\n\n%s", err, out)
+		// Exit status of 1 means the key is not set.
+		if exitStatus(err) == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("exec %v in %s failed: %s", cmd.Args, cmd.Dir, err)
 	}
-	return Open(dir)
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// CloneBranch returns the branch that the repository was cloned with
+// if it is a single-branch (or shallow) clone, or "" otherwise.
+func (r *Repository) CloneBranch() (string, error) {
+	return r.getConfig(branchConfigKey)
+}
+
+// CloneDepth returns the depth that the repository was shallow-cloned
+// with, or 0 if it is not a shallow clone.
+func (r *Repository) CloneDepth() (int, error) {
+	depth, err := r.getConfig(depthConfigKey)
+	if err != nil || depth == "" {
+		return 0, err
+	}
+	return strconv.Atoi(depth)
 }
 
 // checkSpecArgSafety returns a non-nil err if spec begins with a "-", which could
@@ -136,10 +247,10 @@ func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 	cmd.Dir = r.Dir
 	stdout, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		if bytes.Contains(stderr, []byte("unknown revision")) {
-			return "", vcs.ErrRevisionNotFound
+		if isRevNotFoundOutput(stderr, spec) {
+			return "", r.emptyRepoErrorOr(vcs.ErrRevisionNotFound)
 		}
-		return "", fmt.Errorf("exec `git rev-parse` failed: %s. Stderr was:\n\n%s", err, stderr)
+		return "", r.emptyRepoErrorOr(fmt.Errorf("exec `git rev-parse` failed: %s. Stderr was:\n\n%s", err, stderr))
 	}
 	return vcs.CommitID(bytes.TrimSpace(stdout)), nil
 }
@@ -149,7 +260,7 @@ func (r *Repository) ResolveRef(name string) (vcs.CommitID, error) {
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrRefNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
@@ -157,7 +268,7 @@ func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrBranchNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
 func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
@@ -165,36 +276,40 @@ func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
 	if err == vcs.ErrRevisionNotFound {
 		return "", vcs.ErrTagNotFound
 	}
-	return commitID, nil
+	return commitID, err
 }
 
-// branchFilter is a filter for branch names.
-// If not empty, only contained branch names are allowed. If empty, all names are allowed.
-// The map should be made so it's not nil.
-type branchFilter map[string]struct{}
+// branchFilter is a filter for branch names. Each element is a set
+// of allowed branch names, and a name must be in all of the sets to
+// be allowed. If there are no sets, all names are allowed.
+type branchFilter []map[string]struct{}
 
 // allows will return true if the current filter set-up validates against
 // the passed string. If there are no filters, all strings pass.
 func (f branchFilter) allows(name string) bool {
-	if len(f) == 0 {
-		return true
+	for _, set := range f {
+		if _, ok := set[name]; !ok {
+			return false
+		}
 	}
-	_, ok := f[name]
-	return ok
+	return true
 }
 
-// add adds a slice of strings to the filter.
-func (f branchFilter) add(list []string) {
+// add adds a set of allowed names (from a slice of strings) to the
+// filter.
+func (f *branchFilter) add(list []string) {
+	set := make(map[string]struct{}, len(list))
 	for _, l := range list {
-		f[l] = struct{}{}
+		set[l] = struct{}{}
 	}
+	*f = append(*f, set)
 }
 
 func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	f := make(branchFilter)
+	var f branchFilter
 	if opt.MergedInto != "" {
 		b, err := r.branches("--merged", opt.MergedInto)
 		if err != nil {
@@ -203,14 +318,17 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 		f.add(b)
 	}
 	if opt.ContainsCommit != "" {
-		b, err := r.branches("--contains=" + opt.ContainsCommit)
+		if err := checkSpecArgSafety(string(opt.ContainsCommit)); err != nil {
+			return nil, err
+		}
+		b, err := r.branches("--contains=" + string(opt.ContainsCommit))
 		if err != nil {
 			return nil, err
 		}
 		f.add(b)
 	}
 
-	refs, err := r.showRef("--heads")
+	refs, err := r.forEachRef("refs/heads/", "upstream:short")
 	if err != nil {
 		return nil, err
 	}
@@ -223,7 +341,7 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 			continue
 		}
 
-		branch := &vcs.Branch{Name: name, Head: id}
+		branch := &vcs.Branch{Name: name, Head: id, Upstream: ref[2]}
 		if opt.IncludeCommit {
 			branch.Commit, err = r.getCommit(id)
 			if err != nil {
@@ -242,12 +360,18 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 }
 
 // branches runs the `git branch` command followed by the given arguments and
-// returns the list of branches if successful.
+// returns the list of branches if successful. If a commit given in args
+// does not exist, vcs.ErrCommitNotFound is returned.
 func (r *Repository) branches(args ...string) ([]string, error) {
 	cmd := exec.Command("git", append([]string{"branch"}, args...)...)
 	cmd.Dir = r.Dir
 	out, err := cmd.Output()
 	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			if isRevNotFoundOutput(ee.Stderr) {
+				return nil, vcs.ErrCommitNotFound
+			}
+		}
 		return nil, fmt.Errorf("exec %v in %s failed: %v (output follows)\n\n%s", cmd.Args, cmd.Dir, err, out)
 	}
 	lines := strings.Split(string(out), "\n")
@@ -290,55 +414,192 @@ func (r *Repository) Tags() ([]*vcs.Tag, error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	refs, err := r.showRef("--tags")
+	refs, err := r.forEachRef("refs/tags/", "objecttype", "*objectname", "taggername", "taggeremail", "taggerdate:raw", "contents")
 	if err != nil {
 		return nil, err
 	}
 
 	tags := make([]*vcs.Tag, len(refs))
 	for i, ref := range refs {
-		tags[i] = &vcs.Tag{
+		tag := &vcs.Tag{
 			Name:     strings.TrimPrefix(ref[1], "refs/tags/"),
 			CommitID: vcs.CommitID(ref[0]),
 		}
+		if ref[2] == "tag" {
+			tag.Annotated = true
+			tag.CommitID = vcs.CommitID(ref[3])
+			if ref[4] != "" || ref[5] != "" {
+				date, err := parseGitRawDate(ref[6])
+				if err != nil {
+					return nil, fmt.Errorf("parsing git tagger date of tag %q: %s", tag.Name, err)
+				}
+				tagger := vcs.NewSignature(ref[4], strings.TrimSuffix(strings.TrimPrefix(ref[5], "<"), ">"), date)
+				tag.Tagger = &tagger
+			}
+			tag.Message = strings.TrimSuffix(ref[7], "\n")
+		}
+		tags[i] = tag
 	}
 	return tags, nil
 }
 
-type byteSlices [][]byte
+func (r *Repository) Describe(commitID vcs.CommitID, opt vcs.DescribeOptions) (string, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
 
-func (p byteSlices) Len() int           { return len(p) }
-func (p byteSlices) Less(i, j int) bool { return bytes.Compare(p[i], p[j]) < 0 }
-func (p byteSlices) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+	if err := checkSpecArgSafety(string(commitID)); err != nil {
+		return "", err
+	}
 
-func (r *Repository) showRef(arg string) ([][2]string, error) {
-	cmd := exec.Command("git", "show-ref", arg)
+	args := []string{"describe", "--tags", "--long"}
+	if opt.All {
+		args = append(args, "--all")
+	}
+	if opt.Abbrev != 0 {
+		args = append(args, "--abbrev="+strconv.Itoa(opt.Abbrev))
+	}
+	if opt.ExactMatch {
+		args = append(args, "--exact-match")
+	}
+	if opt.Always {
+		args = append(args, "--always")
+	}
+	args = append(args, string(commitID))
+
+	cmd := exec.Command("git", args...)
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		// Exit status of 1 and no output means there were no
-		// results. This is not a fatal error.
-		if exitStatus(err) == 1 && len(out) == 0 {
-			return nil, nil
+		stderr = bytes.TrimSpace(stderr)
+		switch {
+		case bytes.HasPrefix(stderr, []byte("fatal: No names found")), bytes.HasPrefix(stderr, []byte("fatal: No tags can describe")), bytes.HasPrefix(stderr, []byte("fatal: no tag exactly matches")):
+			return "", vcs.ErrNoDescription
+		case isRevNotFoundOutput(stderr, string(commitID)):
+			return "", vcs.ErrCommitNotFound
 		}
-		return nil, fmt.Errorf("exec `git show-ref %s` in %s failed: %s. Output was:\n\n%s", arg, r.Dir, err, out)
+		return "", r.emptyRepoErrorOr(fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr))
 	}
+	return string(bytes.TrimSpace(out)), nil
+}
 
-	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
-	lines := bytes.Split(out, []byte("\n"))
-	sort.Sort(byteSlices(lines)) // sort for consistency
-	refs := make([][2]string, len(lines))
-	for i, line := range lines {
-		if len(line) <= 41 {
-			return nil, errors.New("unexpectedly short (<=41 bytes) line in `git show-ref ...` output")
+// parseGitRawDate parses a date in git's raw format ("1136214245
+// +0000"). The returned time is in the date's time zone (or UTC if it
+// has none).
+func parseGitRawDate(s string) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.UTC
+	if len(fields) > 1 {
+		if loc, err = parseGitTZ(fields[1]); err != nil {
+			return time.Time{}, err
 		}
-		id := line[:40]
-		name := line[41:]
-		refs[i] = [2]string{string(id), string(name)}
 	}
+	return time.Unix(sec, 0).In(loc), nil
+}
+
+// parseGitTZ parses a time zone offset in git's format ("+0900" or
+// "-0130").
+func parseGitTZ(s string) (*time.Location, error) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return nil, fmt.Errorf("invalid time zone offset %q", s)
+	}
+	hours, err := strconv.Atoi(s[1:3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone offset %q", s)
+	}
+	minutes, err := strconv.Atoi(s[3:5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone offset %q", s)
+	}
+	offset := hours*3600 + minutes*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone("", offset), nil
+}
+
+// forEachRef runs `git for-each-ref` for the refs matching pattern
+// and returns, for each ref, its object ID, its full name, and then
+// the values of the given for-each-ref fields (such as "objecttype"
+// or "*objectname"). The refs are sorted by object ID and then name
+// (as `git show-ref` sorts them) for consistency.
+func (r *Repository) forEachRef(pattern string, fields ...string) ([][]string, error) {
+	fields = append([]string{"objectname", "refname"}, fields...)
+	var format bytes.Buffer
+	for _, f := range fields {
+		format.WriteString("%(" + f + ")%00")
+	}
+
+	cmd := exec.Command("git", "for-each-ref", "--format="+format.String(), "--", pattern)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("exec `git for-each-ref %s` in %s failed: %s. Output was:\n\n%s", pattern, r.Dir, err, stderr)
+	}
+
+	allParts := bytes.Split(out, []byte{'\x00'})
+	numRefs := len(allParts) / len(fields)
+	refs := make(refRecords, numRefs)
+	for i := range refs {
+		parts := allParts[len(fields)*i : len(fields)*(i+1)]
+
+		// for-each-ref outputs are newline separated, so all but the
+		// 1st object ID part has an erroneous leading newline.
+		parts[0] = bytes.TrimPrefix(parts[0], []byte{'\n'})
+
+		refs[i] = make([]string, len(fields))
+		for j, part := range parts {
+			refs[i][j] = string(part)
+		}
+	}
+	sort.Sort(refs)
 	return refs, nil
 }
 
+// refRecords sorts the output of forEachRef by object ID and then
+// name.
+type refRecords [][]string
+
+func (p refRecords) Len() int { return len(p) }
+func (p refRecords) Less(i, j int) bool {
+	if p[i][0] != p[j][0] {
+		return p[i][0] < p[j][0]
+	}
+	return p[i][1] < p[j][1]
+}
+func (p refRecords) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// isEmpty returns whether the repository has no refs (and therefore
+// no reachable commits). The caller must be holding r.editLock.
+func (r *Repository) isEmpty() (bool, error) {
+	head, err := r.revParse("HEAD")
+	if err != nil || head != "" {
+		return false, err
+	}
+	refs, err := r.forEachRef("refs/")
+	if err != nil {
+		return false, err
+	}
+	return len(refs) == 0, nil
+}
+
+// emptyRepoErrorOr returns vcs.ErrRepoEmpty if the repository is
+// empty, or else err. It is called after a git command fails, because
+// git reports operations on an empty repository as (for example)
+// unknown revisions. The caller must be holding r.editLock.
+func (r *Repository) emptyRepoErrorOr(err error) error {
+	if empty, _ := r.isEmpty(); empty {
+		return vcs.ErrRepoEmpty
+	}
+	return err
+}
+
 func exitStatus(err error) int {
 	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
@@ -392,12 +653,21 @@ func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error
 	return r.commitLog(opt)
 }
 
-func isBadObjectErr(output, obj string) bool {
-	return string(output) == "fatal: bad object "+obj
-}
+// StreamCommits calls fn for each commit matching the options as it
+// is read from `git log`, so that callers need not hold every commit
+// in memory at once.
+func (r *Repository) StreamCommits(opt vcs.CommitsOptions, fn func(*vcs.Commit) error) (uint, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
 
-func isInvalidRevisionRangeError(output, obj string) bool {
-	return strings.HasPrefix(output, "fatal: Invalid revision range "+obj)
+	if err := checkSpecArgSafety(string(opt.Head)); err != nil {
+		return 0, err
+	}
+	if err := checkSpecArgSafety(string(opt.Base)); err != nil {
+		return 0, err
+	}
+
+	return r.streamCommitLog(opt, fn)
 }
 
 // commitLog returns a list of commits, and total number of commits
@@ -405,7 +675,60 @@ func isInvalidRevisionRangeError(output, obj string) bool {
 //
 // The caller is responsible for doing checkSpecArgSafety on opt.Head and opt.Base.
 func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
-	args := []string{"log", `--format=format:%H%x00%aN%x00%aE%x00%at%x00%cN%x00%cE%x00%ct%x00%B%x00%P%x00`}
+	var commits []*vcs.Commit
+	total, err := r.streamCommitLog(opt, func(c *vcs.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return commits, total, nil
+}
+
+// streamCommitLog is like commitLog, but it calls fn for each commit
+// as it is parsed instead of collecting them. If fn returns an error,
+// `git log` is killed and the error is returned.
+//
+// The caller is responsible for doing checkSpecArgSafety on opt.Head and opt.Base.
+func (r *Repository) streamCommitLog(opt vcs.CommitsOptions, fn func(*vcs.Commit) error) (uint, error) {
+	if err := vcs.CheckFollow(opt); err != nil {
+		return 0, err
+	}
+
+	// A cursor continues a previous listing from the commits it
+	// would have visited next.
+	heads := []string{string(opt.Head)}
+	if opt.Cursor != "" {
+		ids, err := vcs.ParseCommitsCursor(opt.Cursor)
+		if err != nil {
+			return 0, err
+		}
+		heads = make([]string, len(ids))
+		for i, id := range ids {
+			heads[i] = string(id)
+		}
+	}
+
+	args := []string{"log", "--date=raw"}
+	format := commitLogFormat
+	if opt.UseMailmap {
+		args = append(args, "--use-mailmap")
+		format = commitLogFormatMailmap
+	} else {
+		args = append(args, "--no-use-mailmap")
+	}
+	if opt.IncludeSignatureStatus {
+		// Append the signature status field that readLogCommit
+		// reads.
+		format += "%G?%x00"
+	}
+	args = append(args, format)
+	if opt.ReencodeMessage {
+		// Output the raw message bytes; readLogCommit transcodes
+		// them. Older versions of git ignore "none" and output UTF-8.
+		args = append(args, "--encoding=none")
+	}
 	if opt.N != 0 {
 		args = append(args, "-n", strconv.FormatUint(uint64(opt.N), 10))
 	}
@@ -413,16 +736,24 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 		args = append(args, "--skip="+strconv.FormatUint(uint64(opt.Skip), 10))
 	}
 
-	if opt.Path != "" {
+	if opt.Follow {
 		args = append(args, "--follow")
 	}
+	args = append(args, commitFilterArgs(opt)...)
 
-	// Range
+	// Range (like `git log Base..Head`).
 	rng := string(opt.Head)
 	if opt.Base != "" {
-		rng += "..." + string(opt.Base)
+		rng = string(opt.Base) + ".." + string(opt.Head)
+	}
+	if opt.Cursor == "" {
+		args = append(args, rng)
+	} else {
+		if opt.Base != "" {
+			args = append(args, "^"+string(opt.Base))
+		}
+		args = append(args, heads...)
 	}
-	args = append(args, rng)
 
 	if opt.Path != "" {
 		args = append(args, "--", opt.Path)
@@ -430,74 +761,165 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 
 	cmd := exec.Command("git", args...)
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		out = bytes.TrimSpace(out)
-		if isBadObjectErr(string(out), string(opt.Head)) {
-			return nil, 0, vcs.ErrCommitNotFound
-		}
-		return nil, 0, fmt.Errorf("exec `git log` failed: %s. Output was:\n\n%s", err, out)
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
 	}
 
-	const partsPerCommit = 9 // number of \x00-separated fields per commit
-	allParts := bytes.Split(out, []byte{'\x00'})
-	numCommits := len(allParts) / partsPerCommit
-	commits := make([]*vcs.Commit, numCommits)
-	for i := 0; i < numCommits; i++ {
-		parts := allParts[partsPerCommit*i : partsPerCommit*(i+1)]
-
-		// log outputs are newline separated, so all but the 1st commit ID part
-		// has an erroneous leading newline.
-		parts[0] = bytes.TrimPrefix(parts[0], []byte{'\n'})
-
-		authorTime, err := strconv.ParseInt(string(parts[3]), 10, 64)
-		if err != nil {
-			return nil, 0, fmt.Errorf("parsing git commit author time: %s", err)
+	br := bufio.NewReader(stdout)
+	for {
+		commit, err := readLogCommit(br, opt.ReencodeMessage, opt.IncludeSignatureStatus)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = fn(commit)
 		}
-		committerTime, err := strconv.ParseInt(string(parts[6]), 10, 64)
 		if err != nil {
-			return nil, 0, fmt.Errorf("parsing git commit committer time: %s", err)
+			cmd.Process.Kill()
+			cmd.Wait()
+			return 0, err
 		}
-
-		var parents []vcs.CommitID
-		if parentPart := parts[8]; len(parentPart) > 0 {
-			parentIDs := bytes.Split(parentPart, []byte{' '})
-			parents = make([]vcs.CommitID, len(parentIDs))
-			for i, id := range parentIDs {
-				parents[i] = vcs.CommitID(id)
-			}
+	}
+	if err := cmd.Wait(); err != nil {
+		if empty, _ := r.isEmpty(); empty {
+			return 0, vcs.ErrRepoEmpty
 		}
-
-		commits[i] = &vcs.Commit{
-			ID:        vcs.CommitID(parts[0]),
-			Author:    vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(time.Unix(authorTime, 0))},
-			Committer: &vcs.Signature{string(parts[4]), string(parts[5]), pbtypes.NewTimestamp(time.Unix(committerTime, 0))},
-			Message:   string(bytes.TrimSuffix(parts[7], []byte{'\n'})),
-			Parents:   parents,
+		out := bytes.TrimSpace(stderr.Bytes())
+		if isRevNotFoundOutput(out, append(heads, string(opt.Base))...) {
+			return 0, vcs.ErrCommitNotFound
 		}
+		return 0, fmt.Errorf("exec `git log` failed: %s. Output was:\n\n%s", err, out)
 	}
 
 	// Count commits.
-	var total uint
-	if !opt.NoTotal {
-		cmd = exec.Command("git", "rev-list", "--count", rng)
-		if opt.Path != "" {
-			// This doesn't include --follow flag because rev-list doesn't support it, so the number may be slightly off.
-			cmd.Args = append(cmd.Args, "--", opt.Path)
-		}
-		cmd.Dir = r.Dir
-		out, err = cmd.CombinedOutput()
-		if err != nil {
-			return nil, 0, fmt.Errorf("exec `git rev-list --count` failed: %s. Output was:\n\n%s", err, out)
+	if opt.NoTotal {
+		return 0, nil
+	}
+	total, _, err := r.countCommits(opt)
+	return total, err
+}
+
+// commitFilterArgs returns the `git log` (and `git rev-list`) options
+// that select only the commits matching opt's Author, Since, and
+// Until.
+func commitFilterArgs(opt vcs.CommitsOptions) []string {
+	var args []string
+	if opt.Author != "" {
+		args = append(args, "--author="+opt.Author)
+	}
+	if !opt.Since.IsZero() {
+		args = append(args, "--since=@"+strconv.FormatInt(opt.Since.Unix(), 10))
+	}
+	if !opt.Until.IsZero() {
+		args = append(args, "--until=@"+strconv.FormatInt(opt.Until.Unix(), 10))
+	}
+	return args
+}
+
+// commitLogFormat is the `git log` format option that readLogCommit
+// parses. commitLogFormatMailmap is the same, but with the names and
+// email addresses mapped by the mailmap (with --use-mailmap).
+const (
+	commitLogFormat        = `--format=format:%H%x00%an%x00%ae%x00%ad%x00%cn%x00%ce%x00%cd%x00%B%x00%P%x00%e%x00`
+	commitLogFormatMailmap = `--format=format:%H%x00%aN%x00%aE%x00%ad%x00%cN%x00%cE%x00%cd%x00%B%x00%P%x00%e%x00`
+)
+
+// readLogCommit reads the next commit from the output of the `git
+// log` command run by streamCommitLog. It returns io.EOF if there are
+// no more commits. If reencode is true, the message is transcoded
+// from the commit's encoding to UTF-8. If signatureStatus is true,
+// the output has an additional signature status ("%G?") field.
+func readLogCommit(br *bufio.Reader, reencode, signatureStatus bool) (*vcs.Commit, error) {
+	partsPerCommit := 10 // number of \x00-separated fields per commit
+	if signatureStatus {
+		partsPerCommit++
+	}
+	parts := make([][]byte, partsPerCommit)
+	for i := range parts {
+		part, err := br.ReadBytes('\x00')
+		if err == io.EOF {
+			if i == 0 && len(part) == 0 {
+				return nil, io.EOF
+			}
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
 		}
-		out = bytes.TrimSpace(out)
-		total, err = parseUint(string(out))
-		if err != nil {
-			return nil, 0, err
+		parts[i] = part[:len(part)-1]
+	}
+
+	// log outputs are newline separated, so all but the 1st commit ID part
+	// has an erroneous leading newline.
+	parts[0] = bytes.TrimPrefix(parts[0], []byte{'\n'})
+
+	authorTime, err := parseGitRawDate(string(parts[3]))
+	if err != nil {
+		return nil, fmt.Errorf("parsing git commit author time: %s", err)
+	}
+	committerTime, err := parseGitRawDate(string(parts[6]))
+	if err != nil {
+		return nil, fmt.Errorf("parsing git commit committer time: %s", err)
+	}
+
+	var parents []vcs.CommitID
+	if parentPart := parts[8]; len(parentPart) > 0 {
+		parentIDs := bytes.Split(parentPart, []byte{' '})
+		parents = make([]vcs.CommitID, len(parentIDs))
+		for i, id := range parentIDs {
+			parents[i] = vcs.CommitID(id)
 		}
 	}
 
-	return commits, total, nil
+	msg := bytes.TrimSuffix(parts[7], []byte{'\n'})
+	if reencode {
+		msg = decodeMessage(msg, string(parts[9]))
+	}
+
+	committer := vcs.NewSignature(string(parts[4]), string(parts[5]), committerTime)
+	subject, body := vcs.SplitMessage(string(msg))
+	var signed bool
+	if signatureStatus {
+		// "N" means no signature; anything else ("G" for a good
+		// signature, "B" for a bad one, "U" for an unknown key, etc.)
+		// means that the commit is signed.
+		signed = len(parts[10]) > 0 && string(parts[10]) != "N"
+	}
+	return &vcs.Commit{
+		ID:        vcs.CommitID(parts[0]),
+		Author:    vcs.NewSignature(string(parts[1]), string(parts[2]), authorTime),
+		Committer: &committer,
+		Message:   string(msg),
+		Parents:   parents,
+		Trailers:  vcs.ParseTrailers(string(msg)),
+		Subject:   subject,
+		Body:      body,
+		Signed:    signed,
+	}, nil
+}
+
+// decodeMessage transcodes msg from the named encoding (from a
+// commit's encoding header) to UTF-8. If msg is already valid UTF-8
+// (because git transcoded it), or the encoding is empty or unknown, or
+// msg can't be decoded, msg is returned unchanged.
+func decodeMessage(msg []byte, encoding string) []byte {
+	if encoding == "" || utf8.Valid(msg) {
+		return msg
+	}
+	enc, err := ianaindex.IANA.Encoding(encoding)
+	if err != nil || enc == nil {
+		return msg
+	}
+	decoded, err := enc.NewDecoder().Bytes(msg)
+	if err != nil {
+		return msg
+	}
+	return decoded
 }
 
 func parseUint(s string) (uint, error) {
@@ -517,12 +939,10 @@ func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.D
 	if opt == nil {
 		opt = &vcs.DiffOptions{}
 	}
-	args := []string{"diff", "--full-index"}
-	if opt.DetectRenames {
-		args = append(args, "-M")
+	args, err := diffArgs(opt)
+	if err != nil {
+		return nil, err
 	}
-	args = append(args, "--src-prefix="+opt.OrigPrefix)
-	args = append(args, "--dst-prefix="+opt.NewPrefix)
 
 	rng := string(base)
 	if opt.ExcludeReachableFromBoth {
@@ -537,19 +957,191 @@ func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.D
 		cmd.Args = append(cmd.Args, opt.Paths...)
 	}
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		out = bytes.TrimSpace(out)
-		if isBadObjectErr(string(out), string(base)) || isBadObjectErr(string(out), string(head)) || isInvalidRevisionRangeError(string(out), string(base)) || isInvalidRevisionRangeError(string(out), string(head)) {
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(base), string(head)) {
 			return nil, vcs.ErrCommitNotFound
 		}
-		return nil, fmt.Errorf("exec `git diff` failed: %s. Output was:\n\n%s", err, out)
+		return nil, r.emptyRepoErrorOr(fmt.Errorf("exec `git diff` failed: %s. Output was:\n\n%s", err, stderr))
+	}
+	return &vcs.Diff{
+		Raw:     string(out),
+		Renames: parseDiffRenames(out),
+	}, nil
+}
+
+// diffArgs returns the `git diff` command-line arguments (up to but
+// not including the revisions) for the options.
+func diffArgs(opt *vcs.DiffOptions) ([]string, error) {
+	if opt.RenameThreshold < 0 || opt.RenameThreshold > 100 {
+		return nil, fmt.Errorf("invalid rename threshold %d%% (must be between 0 and 100)", opt.RenameThreshold)
+	}
+	var threshold string
+	if opt.RenameThreshold != 0 {
+		threshold = strconv.Itoa(opt.RenameThreshold) + "%"
+	}
+
+	args := []string{"diff", "--full-index"}
+	if opt.DetectRenames || opt.RenameThreshold != 0 {
+		args = append(args, "-M"+threshold)
+	}
+	if opt.DetectCopies {
+		args = append(args, "-C"+threshold)
+	}
+	if opt.IgnoreWhitespace {
+		args = append(args, "-w")
+	}
+	if opt.IgnoreWhitespaceChange {
+		args = append(args, "-b")
+	}
+	args = append(args, "--src-prefix="+opt.OrigPrefix)
+	args = append(args, "--dst-prefix="+opt.NewPrefix)
+	return args, nil
+}
+
+func (r *Repository) DiffTrees(base, head string, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if opt == nil {
+		opt = &vcs.DiffOptions{}
+	}
+	if opt.ExcludeReachableFromBoth {
+		return nil, errors.New("diffing trees doesn't support ExcludeReachableFromBoth")
+	}
+	args, err := diffArgs(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the tree specs to tree IDs first, so that only object
+	// IDs (and no user input) are passed to `git diff`.
+	trees, err := r.resolveTreeSpecs(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, trees[0], trees[1], "--")
+	cmd := exec.Command("git", args...)
+	cmd.Args = append(cmd.Args, opt.Paths...)
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("exec `git diff` failed: %s. Output was:\n\n%s", err, bytes.TrimSpace(stderr))
 	}
 	return &vcs.Diff{
-		Raw: string(out),
+		Raw:     string(out),
+		Renames: parseDiffRenames(out),
 	}, nil
 }
 
+// parseTreeSpec splits a tree spec ("<rev>" or "<rev>:<path>") into
+// its revision and path. If either could be interpreted as anything
+// else (such as a command-line option, a revision range, or a path
+// relative to the current directory), vcs.ErrInvalidTreeSpec is
+// returned.
+func parseTreeSpec(spec string) (rev, path string, err error) {
+	rev = spec
+	if i := strings.Index(spec, ":"); i != -1 {
+		rev, path = spec[:i], spec[i+1:]
+	}
+	if strings.IndexFunc(spec, func(c rune) bool { return c <= ' ' || c == 0x7f }) != -1 {
+		return "", "", vcs.ErrInvalidTreeSpec
+	}
+	if rev == "" || strings.HasPrefix(rev, "-") || strings.Contains(rev, "..") {
+		return "", "", vcs.ErrInvalidTreeSpec
+	}
+	if path != "" && (path != pathpkg.Clean(path) || strings.HasPrefix(path, "/") || path == ".." || strings.HasPrefix(path, "../")) {
+		return "", "", vcs.ErrInvalidTreeSpec
+	}
+	return rev, path, nil
+}
+
+// resolveTreeSpecs returns the tree IDs of the tree specs (see
+// parseTreeSpec). If a spec's revision doesn't exist,
+// vcs.ErrCommitNotFound is returned; if its path doesn't exist, an
+// os.ErrNotExist-satisfying error is returned.
+func (r *Repository) resolveTreeSpecs(specs ...string) ([]string, error) {
+	// Query each spec's commit and tree.
+	var in bytes.Buffer
+	paths := make([]string, len(specs))
+	for i, spec := range specs {
+		rev, path, err := parseTreeSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+		fmt.Fprintf(&in, "%s^{commit}\n%s:%s\n", rev, rev, path)
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch-check")
+	cmd.Dir = r.Dir
+	cmd.Stdin = &in
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("exec `git cat-file --batch-check` failed: %s. Output was:\n\n%s", err, bytes.TrimSpace(stderr))
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != 2*len(specs) {
+		return nil, fmt.Errorf("unexpected `git cat-file --batch-check` output %q", out)
+	}
+
+	trees := make([]string, len(specs))
+	for i, spec := range specs {
+		commit, tree := strings.Fields(lines[2*i]), strings.Fields(lines[2*i+1])
+		if len(commit) != 3 || commit[1] != "commit" {
+			return nil, vcs.ErrCommitNotFound
+		}
+		if len(tree) != 3 {
+			return nil, &os.PathError{Op: "DiffTrees", Path: paths[i], Err: os.ErrNotExist}
+		}
+		if tree[1] != "tree" {
+			return nil, fmt.Errorf("tree spec %q refers to a %s, not a tree", spec, tree[1])
+		}
+		trees[i] = tree[0]
+	}
+	return trees, nil
+}
+
+// parseDiffRenames returns the renames and copies described by the
+// extended header lines (such as "similarity index 90%" and "rename
+// from f") of `git diff` output.
+func parseDiffRenames(diff []byte) []*vcs.DiffRename {
+	var renames []*vcs.DiffRename
+	var cur *vcs.DiffRename // the current file's rename, if in its header
+	for _, line := range strings.Split(string(diff), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = &vcs.DiffRename{}
+		case cur == nil:
+		case strings.HasPrefix(line, "similarity index "):
+			cur.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			cur.Copy = strings.HasPrefix(line, "copy ")
+			cur.OrigPath = unquoteDiffPath(line[strings.Index(line, " from ")+len(" from "):])
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			cur.Path = unquoteDiffPath(line[strings.Index(line, " to ")+len(" to "):])
+			renames = append(renames, cur)
+			cur = nil
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "@@ "), strings.HasPrefix(line, "Binary files "):
+			cur = nil
+		}
+	}
+	return renames
+}
+
+// unquoteDiffPath unquotes a path in a `git diff` header, which git
+// quotes (like a C string) if it contains special characters.
+func unquoteDiffPath(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if s, err := strconv.Unquote(path); err == nil {
+			return s
+		}
+	}
+	return path
+}
+
 // A CrossRepo is a git repository that can be used in cross-repo
 // operations (e.g., as the head repository for a cross-repo diff in
 // another git repository's CrossRepoDiff method, or as the 2nd repo
@@ -602,28 +1194,42 @@ func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) error {
 	r.editLock.Lock()
 	defer r.editLock.Unlock()
 
-	cmd := exec.Command("git", "remote", "update")
+	depth, err := r.CloneDepth()
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if depth > 0 {
+		// Keep the history of a shallow clone truncated.
+		cmd = exec.Command("git", "fetch", "--depth", strconv.Itoa(depth), "origin")
+	} else {
+		cmd = exec.Command("git", "remote", "update")
+	}
 	cmd.Dir = r.Dir
 
 	if opt.SSH != nil {
-		gitSSHWrapper, keyFile, err := makeGitSSHWrapper(opt.SSH.PrivateKey)
-		defer func() {
-			if keyFile != "" {
-				if err := os.Remove(keyFile); err != nil {
-					log.Fatalf("Error removing SSH key file %s: %s.", keyFile, err)
-				}
-			}
-		}()
+		env, tmpFiles, err := makeGitSSHWrapper(opt.SSH)
+		defer removeSSHFiles(tmpFiles)
 		if err != nil {
 			return err
 		}
-		defer os.Remove(gitSSHWrapper)
-		cmd.Env = []string{"GIT_SSH=" + gitSSHWrapper}
+		cmd.Env = env
+	}
+	if opt.HTTPS != nil {
+		askPass, env, err := makeGitAskPass(opt.HTTPS)
+		if askPass != "" {
+			defer os.Remove(askPass)
+		}
+		if err != nil {
+			return err
+		}
+		addEnv(cmd, env)
 	}
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("exec `git remote update` failed: %s. Output was:\n\n%s", err, out)
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
 	}
 	return nil
 }
@@ -649,12 +1255,26 @@ func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk,
 	if opt.StartLine != 0 || opt.EndLine != 0 {
 		args = append(args, fmt.Sprintf("-L%d,%d", opt.StartLine, opt.EndLine))
 	}
+	for _, rev := range opt.IgnoreRevs {
+		if err := checkSpecArgSafety(string(rev)); err != nil {
+			return nil, err
+		}
+		args = append(args, "--ignore-rev="+string(rev))
+	}
+	if opt.IgnoreRevsFile != "" {
+		ignoreRevsFile, err := r.writeTempBlob(string(opt.NewestCommit), opt.IgnoreRevsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(ignoreRevsFile)
+		args = append(args, "--ignore-revs-file="+ignoreRevsFile)
+	}
 	args = append(args, string(opt.NewestCommit), "--", path)
 	cmd := exec.Command("git", args...)
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("exec `git blame` failed: %s. Output was:\n\n%s", err, out)
+		return nil, r.emptyRepoErrorOr(fmt.Errorf("exec `git blame` failed: %s. Output was:\n\n%s", err, stderr))
 	}
 	if len(out) < 1 {
 		// go 1.8.5 changed the behavior of `git blame` on empty files.
@@ -668,104 +1288,184 @@ func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk,
 		return nil, fmt.Errorf("Expected git output of length at least 1")
 	}
 
-	commits := make(map[string]vcs.Commit)
-	hunks := make([]*vcs.Hunk, 0)
-	remainingLines := strings.Split(string(out[:len(out)-1]), "\n")
-	byteOffset := 0
-	for len(remainingLines) > 0 {
-		// Consume hunk
-		hunkHeader := strings.Split(remainingLines[0], " ")
-		if len(hunkHeader) != 4 {
-			fmt.Printf("Remaining lines: %+v, %d, '%s'\n", remainingLines, len(remainingLines), remainingLines[0])
-			return nil, fmt.Errorf("Expected at least 4 parts to hunkHeader, but got: '%s'", hunkHeader)
-		}
-		commitID := hunkHeader[0]
-		lineNoCur, _ := strconv.Atoi(hunkHeader[2])
-		nLines, _ := strconv.Atoi(hunkHeader[3])
-		hunk := &vcs.Hunk{
-			CommitID:  vcs.CommitID(commitID),
-			StartLine: int(lineNoCur),
-			EndLine:   int(lineNoCur + nLines),
-			StartByte: byteOffset,
-		}
-
-		if _, in := commits[commitID]; in {
-			// Already seen commit
-			byteOffset += len(remainingLines[1])
-			remainingLines = remainingLines[2:]
+	return parseBlamePorcelain(out)
+}
+
+func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	cmd := exec.Command("git", "merge-base", "--", string(a), string(b))
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+	return vcs.CommitID(bytes.TrimSpace(out)), nil
+}
+
+// writeTempBlob writes the contents of the file at path in the tree
+// of rev (or HEAD, if rev is empty) to a new temporary file and
+// returns the temporary file's name. The caller must remove it.
+func (r *Repository) writeTempBlob(rev, path string) (string, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	if err := checkSpecArgSafety(rev); err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", "cat-file", "blob", rev+":"+path)
+	cmd.Dir = r.Dir
+	data, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(stderr))
+	}
+
+	f, err := ioutil.TempFile("", "go-vcs-gitcmd-blob")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (r *Repository) DiffStat(commitID vcs.CommitID) ([]*vcs.FileStat, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if err := checkSpecArgSafety(string(commitID)); err != nil {
+		return nil, err
+	}
+
+	// Use -m --first-parent so that merge commits are diffed against
+	// their first parent (instead of producing a combined diff, which
+	// --numstat omits).
+	cmd := exec.Command("git", "show", "-m", "--first-parent", "--numstat", "-z", "-M", "--format=", string(commitID), "--")
+	cmd.Dir = r.Dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(commitID)) {
+			return nil, vcs.ErrCommitNotFound
+		}
+		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+	return parseNumstat(out)
+}
+
+// parseNumstat parses the output of `git diff --numstat -z`. Each
+// entry is "<added>\t<deleted>\t<path>\x00" or, for renames,
+// "<added>\t<deleted>\t\x00<orig path>\x00<path>\x00". Binary files
+// have "-" for the added and deleted counts.
+func parseNumstat(out []byte) ([]*vcs.FileStat, error) {
+	var stats []*vcs.FileStat
+	fields := bytes.Split(bytes.TrimLeft(out, "\n"), []byte{'\x00'})
+	for i := 0; i < len(fields); i++ {
+		f := bytes.TrimLeft(fields[i], "\n")
+		if len(f) == 0 {
+			continue
+		}
+		parts := bytes.SplitN(f, []byte{'\t'}, 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid git numstat line %q", f)
+		}
+		stat := &vcs.FileStat{Path: string(parts[2])}
+		if string(parts[0]) == "-" && string(parts[1]) == "-" {
+			stat.Binary = true
 		} else {
-			// New commit
-			author := strings.Join(strings.Split(remainingLines[1], " ")[1:], " ")
-			email := strings.Join(strings.Split(remainingLines[2], " ")[1:], " ")
-			if len(email) >= 2 && email[0] == '<' && email[len(email)-1] == '>' {
-				email = email[1 : len(email)-1]
+			var err error
+			if stat.Added, err = strconv.Atoi(string(parts[0])); err != nil {
+				return nil, fmt.Errorf("invalid git numstat line %q: %s", f, err)
 			}
-			authorTime, err := strconv.ParseInt(strings.Join(strings.Split(remainingLines[3], " ")[1:], " "), 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to parse author-time %q", remainingLines[3])
+			if stat.Deleted, err = strconv.Atoi(string(parts[1])); err != nil {
+				return nil, fmt.Errorf("invalid git numstat line %q: %s", f, err)
 			}
-			summary := strings.Join(strings.Split(remainingLines[9], " ")[1:], " ")
-			commit := vcs.Commit{
-				ID:      vcs.CommitID(commitID),
-				Message: summary,
-				Author: vcs.Signature{
-					Name:  author,
-					Email: email,
-					Date:  pbtypes.NewTimestamp(time.Unix(authorTime, 0).In(time.UTC)),
-				},
+		}
+		if stat.Path == "" {
+			// Rename: the original and new paths follow.
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("invalid git numstat rename entry %q", f)
 			}
+			stat.OrigPath, stat.Path = string(fields[i+1]), string(fields[i+2])
+			i += 2
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
 
-			if len(remainingLines) >= 13 && strings.HasPrefix(remainingLines[10], "previous ") {
-				byteOffset += len(remainingLines[12])
-				remainingLines = remainingLines[13:]
-			} else if len(remainingLines) >= 13 && remainingLines[10] == "boundary" {
-				byteOffset += len(remainingLines[12])
-				remainingLines = remainingLines[13:]
-			} else if len(remainingLines) >= 12 {
-				byteOffset += len(remainingLines[11])
-				remainingLines = remainingLines[12:]
-			} else if len(remainingLines) == 11 {
-				// Empty file
-				remainingLines = remainingLines[11:]
-			} else {
-				return nil, fmt.Errorf("Unexpected number of remaining lines (%d):\n%s", len(remainingLines), "  "+strings.Join(remainingLines, "\n  "))
-			}
+func (r *Repository) IsAncestor(a, b vcs.CommitID) (bool, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
 
-			commits[commitID] = commit
-		}
+	if err := checkSpecArgSafety(string(a)); err != nil {
+		return false, err
+	}
+	if err := checkSpecArgSafety(string(b)); err != nil {
+		return false, err
+	}
 
-		if commit, present := commits[commitID]; present {
-			// Should always be present, but check just to avoid
-			// panicking in case of a (somewhat likely) bug in our
-			// git-blame parser above.
-			hunk.CommitID = commit.ID
-			hunk.Author = commit.Author
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", string(a), string(b))
+	cmd.Dir = r.Dir
+	_, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(a), string(b)) {
+			return false, vcs.ErrCommitNotFound
 		}
-
-		// Consume remaining lines in hunk
-		for i := 1; i < nLines; i++ {
-			byteOffset += len(remainingLines[1])
-			remainingLines = remainingLines[2:]
+		// Exit status of 1 means that a is not an ancestor of b.
+		if exitStatus(err) == 1 {
+			return false, nil
 		}
-
-		hunk.EndByte = byteOffset
-		hunks = append(hunks, hunk)
+		return false, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
 	}
-
-	return hunks, nil
+	return true, nil
 }
 
-func (r *Repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
+func (r *Repository) AheadBehind(base, head vcs.CommitID) (ahead, behind int, err error) {
 	r.editLock.RLock()
 	defer r.editLock.RUnlock()
 
-	cmd := exec.Command("git", "merge-base", "--", string(a), string(b))
+	if err := checkSpecArgSafety(string(base)); err != nil {
+		return 0, 0, err
+	}
+	if err := checkSpecArgSafety(string(head)); err != nil {
+		return 0, 0, err
+	}
+
+	rng := string(base) + "..." + string(head)
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", rng, "--")
 	cmd.Dir = r.Dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		return "", fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		stderr = bytes.TrimSpace(stderr)
+		if isRevNotFoundOutput(stderr, string(base), string(head)) {
+			return 0, 0, vcs.ErrCommitNotFound
+		}
+		return 0, 0, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
 	}
-	return vcs.CommitID(bytes.TrimSpace(out)), nil
+
+	// The output is "<behind>\t<ahead>" (the left side of the range
+	// is base).
+	counts := strings.Fields(string(out))
+	if len(counts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected output from %v: %q", cmd.Args, out)
+	}
+	if behind, err = strconv.Atoi(counts[0]); err != nil {
+		return 0, 0, err
+	}
+	if ahead, err = strconv.Atoi(counts[1]); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
 }
 
 func (r *Repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b vcs.CommitID) (vcs.CommitID, error) {
@@ -939,12 +1639,17 @@ func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
 		return nil, err
 	}
 
-	return &gitFSCmd{
+	fs := &gitFSCmd{
 		dir:          r.Dir,
 		at:           at,
 		repo:         r,
 		repoEditLock: &r.editLock,
-	}, nil
+	}
+	if CatFileBatch {
+		fs.batch = &catFile{dir: r.Dir}
+		fs.check = &catFile{dir: r.Dir, check: true}
+	}
+	return fs, nil
 }
 
 type gitFSCmd struct {
@@ -952,42 +1657,87 @@ type gitFSCmd struct {
 	at           vcs.CommitID
 	repo         *Repository
 	repoEditLock *sync.RWMutex
+
+	// batch and check, if set, are used to read objects without
+	// running git for each file (see CatFileBatch).
+	batch, check *catFile
+}
+
+var _ io.Closer = (*gitFSCmd)(nil)
+
+// Close stops the `git cat-file` processes (if any) used by fs. They
+// are also stopped after they have been idle for a while, so callers
+// that can't close fs (because they only have a vfs.FileSystem) don't
+// leak them. fs may still be used after it is closed.
+func (fs *gitFSCmd) Close() error {
+	if fs.batch == nil {
+		return nil
+	}
+	err := fs.batch.Close()
+	if err2 := fs.check.Close(); err == nil {
+		err = err2
+	}
+	return err
 }
 
 func (fs *gitFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
 	name = internal.Rel(name)
 	fs.repoEditLock.RLock()
 	defer fs.repoEditLock.RUnlock()
-	b, err := fs.readFileBytes(name)
-	if err != nil {
-		return nil, err
+
+	if fs.check != nil {
+		// Small files (which are most files) are faster to read in
+		// full from the long-lived `git cat-file --batch` process.
+		obj, _, err := fs.check.query(string(fs.at) + ":" + name)
+		if err == nil && obj.typ == "blob" && obj.size <= maxBufferedBlobSize {
+			b, err := fs.readFileBytes(name)
+			if err != nil {
+				return nil, err
+			}
+			return util.NopCloser{ReadSeeker: bytes.NewReader(b)}, nil
+		}
 	}
-	return util.NopCloser{bytes.NewReader(b)}, nil
+	return fs.openStream(name)
 }
 
 func (fs *gitFSCmd) readFileBytes(name string) ([]byte, error) {
+	if fs.batch != nil {
+		// Fall back to `git show` for anything but a blob, so that
+		// nonexistent files and submodules are handled below.
+		if obj, data, err := fs.batch.query(string(fs.at) + ":" + name); err == nil && obj.typ == "blob" {
+			return data, nil
+		}
+	}
+
 	cmd := exec.Command("git", "show", string(fs.at)+":"+name)
 	cmd.Dir = fs.dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		if bytes.Contains(out, []byte("exists on disk, but not in")) || bytes.Contains(out, []byte("does not exist")) {
-			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
-		}
-		if bytes.HasPrefix(out, []byte("fatal: bad object ")) {
-			// Could be a git submodule.
-			fi, err := fs.Stat(name)
-			if err != nil {
-				return nil, err
-			}
-			// Return empty for a submodule for now.
-			if fi.Mode()&vcs.ModeSubmodule != 0 {
-				return nil, nil
-			}
+		return nil, fs.showError(name, cmd.Args, err, stderr)
+	}
+	return out, nil
+}
 
+// showError returns the error for a failed `git show` (run with args)
+// of the file at name, given its standard error output. It returns
+// nil if the file is a submodule, whose contents are empty.
+func (fs *gitFSCmd) showError(name string, args []string, err error, out []byte) error {
+	if isPathNotFoundOutput(out) {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if bytes.HasPrefix(out, []byte("fatal: bad object ")) {
+		// Could be a git submodule.
+		fi, err := fs.Stat(name)
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		// Return empty for a submodule for now.
+		if fi.Mode()&vcs.ModeSubmodule != 0 {
+			return nil
+		}
+
 	}
-	return out, nil
+	return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", args, err, out)
 }
 
 func (fs *gitFSCmd) Lstat(path string) (os.FileInfo, error) {
@@ -1005,6 +1755,10 @@ func (fs *gitFSCmd) Lstat(path string) (os.FileInfo, error) {
 		return &util.FileInfo{Mode_: os.ModeDir, ModTime_: mtime}, nil
 	}
 
+	if fs.batch != nil {
+		return fs.lstatBatch(path)
+	}
+
 	fis, err := fs.lsTree(path)
 	if err != nil {
 		return nil, err
@@ -1021,51 +1775,155 @@ func (fs *gitFSCmd) Lstat(path string) (os.FileInfo, error) {
 // on large repositories).
 var SetModTime = true
 
+// gitLogDateLayout is the time layout of the default date format
+// (%ad) of `git log`.
+const gitLogDateLayout = "Mon Jan _2 15:04:05 2006 -0700"
+
 func (fs *gitFSCmd) getModTimeFromGitLog(path string) (time.Time, error) {
 	if !SetModTime {
 		return time.Time{}, nil
 	}
 	cmd := exec.Command("git", "log", "-1", "--format=%ad", string(fs.at), "--", path)
 	cmd.Dir = fs.dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, out)
+		return time.Time{}, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
 	}
 	timeStr := strings.Trim(string(out), "\n")
 	if timeStr == "" {
 		return time.Time{}, &os.PathError{Op: "mtime", Path: path, Err: os.ErrNotExist}
 	}
-	return time.Parse("Mon Jan _2 15:04:05 2006 -0700", timeStr)
+	return time.Parse(gitLogDateLayout, timeStr)
 }
 
 func (fs *gitFSCmd) Stat(path string) (os.FileInfo, error) {
-	path = internal.Rel(path)
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
+	return internal.Stat(fs.Lstat, path)
+}
+
+var _ vcs.MultiStater = (*gitFSCmd)(nil)
 
+// StatMulti implements vcs.MultiStater. It lists all of the paths'
+// tree entries with a single `git ls-tree` and finds their mod times
+// with a single `git log` (instead of running both for each path, as
+// Stat does). Symlinks are followed using Stat.
+func (fs *gitFSCmd) StatMulti(paths []string) ([]os.FileInfo, []error) {
 	fs.repoEditLock.RLock()
 	defer fs.repoEditLock.RUnlock()
 
-	fi, err := fs.Lstat(path)
+	fis := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+	failAll := func(err error) ([]os.FileInfo, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return fis, errs
+	}
+
+	names := make([]string, len(paths))
+	var lsPaths []string
+	for i, path := range paths {
+		name := filepath.Clean(internal.Rel(path))
+		if name == ".." || strings.HasPrefix(name, "../") {
+			errs[i] = &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+			continue
+		}
+		if err := checkSpecArgSafety(name); err != nil {
+			errs[i] = err
+			continue
+		}
+		names[i] = name
+		if name != "." {
+			lsPaths = append(lsPaths, name)
+		}
+	}
+
+	// The -t flag makes `git ls-tree` list the tree entries of
+	// directories that contain other listed paths, too.
+	entries := map[string]lsTreeEntry{}
+	if len(lsPaths) > 0 {
+		cmd := exec.Command("git", "ls-tree", "-z", "-t", "--full-name", "--long", string(fs.at), "--")
+		cmd.Args = append(cmd.Args, lsPaths...)
+		cmd.Dir = fs.dir
+		out, stderr, err := dividedOutput(cmd)
+		if err != nil {
+			return failAll(fmt.Errorf("exec `git ls-tree` failed: %s. Output was:\n\n%s", err, stderr))
+		}
+		es, err := parseLsTree(out)
+		if err != nil {
+			return failAll(err)
+		}
+		for _, e := range es {
+			entries[e.name] = e
+		}
+	}
+
+	var mtimeNames []string
+	for i, name := range names {
+		if errs[i] != nil {
+			continue
+		}
+		if name == "." {
+			fis[i] = &util.FileInfo{Mode_: os.ModeDir}
+			mtimeNames = append(mtimeNames, name)
+			continue
+		}
+		e, ok := entries[name]
+		if !ok {
+			errs[i] = &os.PathError{Op: "ls-tree", Path: name, Err: os.ErrNotExist}
+			continue
+		}
+		const gitModeSymlink = 0120000
+		if e.mode == gitModeSymlink {
+			fis[i], errs[i] = internal.Stat(fs.Lstat, name)
+			continue
+		}
+		fis[i], errs[i] = fs.makeFileInfoWithoutModTime(name, e.mode, e.typ, e.oid, e.size)
+		if errs[i] == nil {
+			mtimeNames = append(mtimeNames, name)
+		}
+	}
+
+	mtimes, err := fs.getModTimesFromGitLog(mtimeNames)
 	if err != nil {
-		return nil, err
+		return failAll(err)
+	}
+	for i, name := range names {
+		if mtime, ok := mtimes[name]; ok && errs[i] == nil {
+			fis[i].(*util.FileInfo).ModTime_ = mtime
+		}
 	}
+	return fis, errs
+}
 
-	if fi.Mode()&os.ModeSymlink != 0 {
-		// Deref symlink.
-		si := fi.Sys().(vcs.SymlinkInfo)
-		fi2, err := fs.Lstat(si.Dest)
+// getModTimesFromGitLog is like getModTimeFromGitLog, but it finds
+// the mod times of all of the paths in a single `git log`.
+func (fs *gitFSCmd) getModTimesFromGitLog(paths []string) (map[string]time.Time, error) {
+	mtimes := make(map[string]time.Time, len(paths))
+	if !SetModTime {
+		return mtimes, nil
+	}
+	dates, stderr, err := fs.repo.lastChanges(string(fs.at), paths, "%ad")
+	if err != nil {
+		return nil, fmt.Errorf("exec `git log` failed: %s. Output was:\n\n%s", err, stderr)
+	}
+	for path, date := range dates {
+		mtimes[path], err = time.Parse(gitLogDateLayout, date)
 		if err != nil {
 			return nil, err
 		}
-		fi2.(*util.FileInfo).Name_ = fi.Name()
-		return fi2, nil
 	}
-
-	return fi, nil
+	return mtimes, nil
 }
 
 func (fs *gitFSCmd) ReadDir(path string) ([]os.FileInfo, error) {
 	fs.repoEditLock.RLock()
 	defer fs.repoEditLock.RUnlock()
+	if fs.batch != nil {
+		return fs.readDirBatch(filepath.Clean(internal.Rel(path)))
+	}
 	// Trailing slash is necessary to ls-tree under the dir (not just
 	// to list the dir's tree entry in its parent dir).
 	return fs.lsTree(filepath.Clean(internal.Rel(path)) + "/")
@@ -1082,20 +1940,68 @@ func (fs *gitFSCmd) lsTree(path string) ([]os.FileInfo, error) {
 
 	cmd := exec.Command("git", "ls-tree", "-z", "--full-name", "--long", string(fs.at), "--", path)
 	cmd.Dir = fs.dir
-	out, err := cmd.CombinedOutput()
+	out, stderr, err := dividedOutput(cmd)
 	if err != nil {
-		if bytes.Contains(out, []byte("exists on disk, but not in")) {
+		if isPathNotFoundOutput(stderr) {
 			return nil, &os.PathError{Op: "ls-tree", Path: path, Err: os.ErrNotExist}
 		}
-		return nil, fmt.Errorf("exec `git ls-files` failed: %s. Output was:\n\n%s", err, out)
+		return nil, fmt.Errorf("exec `git ls-tree` failed: %s. Output was:\n\n%s", err, stderr)
 	}
 
 	if len(out) == 0 {
+		// The root tree always exists (but is empty in a commit with
+		// no files), and git has no empty subtrees.
+		if path == "./" {
+			return []os.FileInfo{}, nil
+		}
 		return nil, os.ErrNotExist
 	}
 
+	entries, err := parseLsTree(out)
+	if err != nil {
+		return nil, err
+	}
+	fis := make([]*util.FileInfo, len(entries))
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		fis[i], err = fs.makeFileInfoWithoutModTime(e.name, e.mode, e.typ, e.oid, e.size)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = e.name
+	}
+	return fs.withModTimes(fis, names)
+}
+
+// withModTimes sets the ModTime of each of fis (whose full paths are
+// names) with a single `git log`, and returns them sorted by name.
+func (fs *gitFSCmd) withModTimes(fis []*util.FileInfo, names []string) ([]os.FileInfo, error) {
+	mtimes, err := fs.getModTimesFromGitLog(names)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]os.FileInfo, len(fis))
+	for i, fi := range fis {
+		fi.ModTime_ = mtimes[names[i]]
+		sorted[i] = fi
+	}
+	util.SortFileInfosByName(sorted)
+	return sorted, nil
+}
+
+// lsTreeEntry is an entry in the output of `git ls-tree --long`.
+type lsTreeEntry struct {
+	name string
+	mode int64
+	typ  string
+	oid  string
+	size int64
+}
+
+// parseLsTree parses the output of `git ls-tree -z --long`.
+func parseLsTree(out []byte) ([]lsTreeEntry, error) {
 	lines := bytes.Split(out, []byte{'\x00'})
-	fis := make([]os.FileInfo, len(lines)-1)
+	entries := make([]lsTreeEntry, 0, len(lines)-1)
 	for i, line := range lines {
 		if i == len(lines)-1 {
 			// last entry is empty
@@ -1125,6 +2031,7 @@ func (fs *gitFSCmd) lsTree(path string) ([]os.FileInfo, error) {
 		sizeB := restParts[0]
 		var size int64
 		if len(sizeB) != 0 && sizeB[0] != '-' {
+			var err error
 			size, err = strconv.ParseInt(string(sizeB), 10, 64)
 			if err != nil {
 				return nil, err
@@ -1132,59 +2039,147 @@ func (fs *gitFSCmd) lsTree(path string) ([]os.FileInfo, error) {
 		}
 		name := string(restParts[1])
 
-		var sys interface{}
-
 		mode, err := strconv.ParseInt(string(parts[0]), 8, 32)
 		if err != nil {
 			return nil, err
 		}
-		switch typ {
-		case "blob":
-			const gitModeSymlink = 020000
-			if mode&gitModeSymlink != 0 {
-				// Dereference symlink.
-				b, err := fs.readFileBytes(name)
-				if err != nil {
-					return nil, err
-				}
-				mode = int64(os.ModeSymlink)
-				sys = vcs.SymlinkInfo{Dest: string(b)}
-			} else {
-				// Regular file.
-				mode = mode | 0644
-			}
-		case "commit":
-			mode = mode | vcs.ModeSubmodule
-			cmd := exec.Command("git", "config", "--get", "submodule."+name+".url")
-			cmd.Dir = fs.dir
-			url := "" // url is not available if submodules are not initialized
-			if out, err := cmd.Output(); err == nil {
-				url = string(bytes.TrimSpace(out))
+		entries = append(entries, lsTreeEntry{name: name, mode: mode, typ: typ, oid: string(oid), size: size})
+	}
+	return entries, nil
+}
+
+// lstatBatch is like Lstat, but it reads path's parent tree using
+// fs.batch. The caller must be holding fs.repoEditLock.RLock().
+func (fs *gitFSCmd) lstatBatch(path string) (os.FileInfo, error) {
+	entries, err := fs.readTreeBatch(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &os.PathError{Op: "ls-tree", Path: path, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	base := filepath.Base(path)
+	for _, e := range entries {
+		if e.name == base {
+			fi, err := fs.treeEntryFileInfo(path, e)
+			if err != nil {
+				return nil, err
 			}
-			sys = vcs.SubmoduleInfo{
-				URL:      url,
-				CommitID: vcs.CommitID(oid),
+			fis, err := fs.withModTimes([]*util.FileInfo{fi}, []string{path})
+			if err != nil {
+				return nil, err
 			}
-		case "tree":
-			mode = mode | int64(os.ModeDir)
+			return fis[0], nil
+		}
+	}
+	return nil, &os.PathError{Op: "ls-tree", Path: path, Err: os.ErrNotExist}
+}
+
+// readDirBatch is like ReadDir, but it reads the tree using
+// fs.batch. The caller must be holding fs.repoEditLock.RLock().
+func (fs *gitFSCmd) readDirBatch(path string) ([]os.FileInfo, error) {
+	entries, err := fs.readTreeBatch(path)
+	if err != nil {
+		return nil, err
+	}
+	fis := make([]*util.FileInfo, len(entries))
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = filepath.Join(path, e.name)
+		fis[i], err = fs.treeEntryFileInfo(names[i], e)
+		if err != nil {
+			return nil, err
 		}
+	}
+	return fs.withModTimes(fis, names)
+}
+
+// readTreeBatch returns the entries of the tree at dir.
+func (fs *gitFSCmd) readTreeBatch(dir string) ([]treeEntry, error) {
+	spec := string(fs.at) + ":" + dir
+	if dir == "." {
+		spec = string(fs.at) + "^{tree}"
+	}
+	obj, data, err := fs.batch.query(spec)
+	if err == errObjectMissing || (err == nil && obj.typ != "tree") {
+		return nil, &os.PathError{Op: "ls-tree", Path: dir, Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseTree(data)
+}
 
-		mtime, err := fs.getModTimeFromGitLog(name)
+// treeEntryFileInfo returns the os.FileInfo (without its ModTime) for
+// the tree entry e, whose full path is name. It uses fs.check to look
+// up the sizes of blobs.
+func (fs *gitFSCmd) treeEntryFileInfo(name string, e treeEntry) (*util.FileInfo, error) {
+	var typ string
+	var size int64
+	switch e.mode {
+	case 040000:
+		typ = "tree"
+	case 0160000:
+		typ = "commit"
+	default:
+		typ = "blob"
+		obj, _, err := fs.check.query(e.oid)
 		if err != nil {
 			return nil, err
 		}
+		size = obj.size
+	}
+	return fs.makeFileInfoWithoutModTime(name, e.mode, typ, e.oid, size)
+}
 
-		fis[i] = &util.FileInfo{
-			Name_:    filepath.Base(name),
-			Mode_:    os.FileMode(mode),
-			Size_:    size,
-			ModTime_: mtime,
-			Sys_:     sys,
+// makeFileInfoWithoutModTime returns the os.FileInfo for the file at
+// name, given the git mode, object type, and object ID of its tree
+// entry. It leaves the ModTime unset (so that the caller can look up
+// many files' mod times at once).
+func (fs *gitFSCmd) makeFileInfoWithoutModTime(name string, mode int64, typ, oid string, size int64) (*util.FileInfo, error) {
+	var sys interface{}
+	switch typ {
+	case "blob":
+		const gitModeSymlink = 020000
+		if mode&gitModeSymlink != 0 {
+			// Dereference symlink.
+			b, err := fs.readFileBytes(name)
+			if err != nil {
+				return nil, err
+			}
+			mode = int64(os.ModeSymlink)
+			sys = vcs.SymlinkInfo{Dest: string(b)}
+		} else {
+			// Regular file (git only records whether it's
+			// executable: 100755 or 100644).
+			if mode&0111 != 0 {
+				mode = 0755
+			} else {
+				mode = 0644
+			}
+		}
+	case "commit":
+		mode = mode | vcs.ModeSubmodule
+		cmd := exec.Command("git", "config", "--get", "submodule."+name+".url")
+		cmd.Dir = fs.dir
+		url := "" // url is not available if submodules are not initialized
+		if out, err := cmd.Output(); err == nil {
+			url = string(bytes.TrimSpace(out))
 		}
+		sys = vcs.SubmoduleInfo{
+			URL:      url,
+			CommitID: vcs.CommitID(oid),
+		}
+	case "tree":
+		mode = int64(os.ModeDir)
 	}
-	util.SortFileInfosByName(fis)
 
-	return fis, nil
+	return &util.FileInfo{
+		Name_: filepath.Base(name),
+		Mode_: os.FileMode(mode),
+		Size_: size,
+		Sys_:  sys,
+	}, nil
 }
 
 func (fs *gitFSCmd) String() string {
@@ -1192,57 +2187,140 @@ func (fs *gitFSCmd) String() string {
 }
 
 // makeGitSSHWrapper writes a GIT_SSH wrapper that runs ssh with the
-// private key. You should close and remove the sshWrapper and remove
-// the keyFile after using them.
-func makeGitSSHWrapper(privKey []byte) (sshWrapper, keyFile string, err error) {
-	var otherOpt string
-	if InsecureSkipCheckVerifySSH {
-		otherOpt = "-o StrictHostKeyChecking=no"
+// private keys in opt (offered in order) and, if opt.UseAgent is set,
+// the local SSH agent. If opt.KnownHosts is non-nil, the server's host
+// key must be listed in it, and InsecureSkipCheckVerifySSH is
+// ignored. It returns the environment that git should be run with.
+// The caller must call removeSSHFiles on tmpFiles after using them,
+// even if err is non-nil.
+func makeGitSSHWrapper(opt *vcs.SSHConfig) (env, tmpFiles []string, err error) {
+	otherOpts := []string{"-o ControlMaster=no", "-o ControlPath=none"}
+	if opt.KnownHosts != nil {
+		knownHostsFile, err := writeTempFile("go-vcs-gitcmd-known-hosts", opt.KnownHosts, 0600)
+		if knownHostsFile != "" {
+			tmpFiles = append(tmpFiles, knownHostsFile)
+		}
+		if err != nil {
+			return nil, tmpFiles, err
+		}
+		otherOpts = append(otherOpts, "-o StrictHostKeyChecking=yes", "-o UserKnownHostsFile="+knownHostsFile, "-o GlobalKnownHostsFile=/dev/null")
+	} else if InsecureSkipCheckVerifySSH {
+		otherOpts = append(otherOpts, "-o StrictHostKeyChecking=no")
 	}
 
-	kf, err := ioutil.TempFile("", "go-vcs-gitcmd-key")
-	if err != nil {
-		return "", "", err
+	// TODO(sqs): encrypt and store the keys in the env so that
+	// attackers can't decrypt if they have disk access after our
+	// process dies
+	var nkeys int
+	for _, key := range append([][]byte{opt.PrivateKey}, opt.PrivateKeys...) {
+		if len(key) == 0 {
+			continue
+		}
+		keyFile, err := writeTempFile("go-vcs-gitcmd-key", key, 0600)
+		if keyFile != "" {
+			tmpFiles = append(tmpFiles, keyFile)
+		}
+		if err != nil {
+			return nil, tmpFiles, err
+		}
+		otherOpts = append(otherOpts, "-i "+keyFile)
+		nkeys++
 	}
-	keyFile = kf.Name()
-	if err := kf.Chmod(0600); err != nil {
-		return "", keyFile, err
+	if nkeys > 0 && !opt.UseAgent {
+		// Don't offer any other keys (such as the user's default
+		// keys), so that the given keys are tried first.
+		otherOpts = append(otherOpts, "-o IdentitiesOnly=yes")
 	}
-	if _, err := kf.Write(privKey); err != nil {
-		return "", keyFile, err
+
+	script := "#!/bin/sh\nexec /usr/bin/ssh " + strings.Join(otherOpts, " ") + ` "$@"` + "\n"
+	sshWrapper, err := writeTempFile("go-vcs-gitcmd", []byte(script), 0500)
+	if sshWrapper != "" {
+		tmpFiles = append(tmpFiles, sshWrapper)
 	}
-	if err := kf.Close(); err != nil {
-		return "", keyFile, err
+	if err != nil {
+		return nil, tmpFiles, err
 	}
 
-	// TODO(sqs): encrypt and store the key in the env so that
-	// attackers can't decrypt if they have disk access after our
-	// process dies
-	script := `
-	#!/bin/sh
-	exec /usr/bin/ssh -o ControlMaster=no -o ControlPath=none ` + otherOpt + ` -i ` + keyFile + ` "$@"
-`
+	env = []string{"GIT_SSH=" + sshWrapper}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); opt.UseAgent && sock != "" {
+		env = append(env, "SSH_AUTH_SOCK="+sock)
+	}
+	return env, tmpFiles, nil
+}
 
-	tf, err := ioutil.TempFile("", "go-vcs-gitcmd")
+// writeTempFile writes data to a new temporary file with the given
+// mode. If the file was created, its name is returned even if an
+// error occurs, so that the caller can remove it.
+func writeTempFile(prefix string, data []byte, mode os.FileMode) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
 	if err != nil {
-		return "", keyFile, err
+		return "", err
+	}
+	name := f.Name()
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return name, err
 	}
-	tmpFile := tf.Name()
-	if _, err := tf.WriteString(script); err != nil {
-		return "", keyFile, err
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return name, err
+	}
+	return name, f.Close()
+}
+
+// removeSSHFiles removes the temporary files created by
+// makeGitSSHWrapper. Failing to remove them is fatal, because they
+// may contain private keys.
+func removeSSHFiles(tmpFiles []string) {
+	for _, name := range tmpFiles {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Error removing SSH file %s: %s.", name, err)
+		}
 	}
-	if err := tf.Chmod(0500); err != nil {
-		return "", "", err
+}
+
+// addEnv adds env to the environment of cmd. If cmd's environment
+// isn't set yet, it starts as the current process's environment (so
+// that git still finds HOME, PATH, proxy settings, etc.).
+func addEnv(cmd *exec.Cmd, env []string) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
 	}
-	if err := tf.Close(); err != nil {
-		return "", "", err
+	cmd.Env = append(cmd.Env, env...)
+}
+
+// makeGitAskPass writes a GIT_ASKPASS helper that answers git's
+// username and password prompts with the credentials in opt, and
+// returns it along with the environment that git should be run
+// with. The credentials are passed to the helper in the environment,
+// not in its arguments or contents, so that they don't show up in
+// process listings or on disk. You should remove the askPass helper
+// after using it.
+func makeGitAskPass(opt *vcs.HTTPSConfig) (askPass string, env []string, err error) {
+	script := `#!/bin/sh
+case "$1" in
+Username*) printf '%s\n' "$GO_VCS_HTTPS_USER" ;;
+*) printf '%s\n' "$GO_VCS_HTTPS_PASS" ;;
+esac
+`
+
+	askPass, err = writeTempFile("go-vcs-gitcmd-askpass", []byte(script), 0500)
+	if err != nil {
+		return askPass, nil, err
 	}
 
-	return tmpFile, keyFile, nil
+	env = []string{
+		"GIT_ASKPASS=" + askPass,
+		"GIT_TERMINAL_PROMPT=0", // fail instead of prompting if the credentials are rejected
+		"GO_VCS_HTTPS_USER=" + opt.User,
+		"GO_VCS_HTTPS_PASS=" + opt.Pass,
+	}
+	return askPass, env, nil
 }
 
 // InsecureSkipCheckVerifySSH controls whether the client verifies the
 // SSH server's certificate or host key. If InsecureSkipCheckVerifySSH
 // is true, the program is susceptible to a man-in-the-middle
-// attack. This should only be used for testing.
+// attack. This should only be used for testing. It is ignored for
+// remotes whose vcs.SSHConfig specifies KnownHosts.
 var InsecureSkipCheckVerifySSH bool
@@ -0,0 +1,150 @@
+package gitcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// TestGitFSCmd_ReadDir_root tests listing the root of a repository's
+// tree, both with `git ls-tree` and with `git cat-file --batch`.
+func TestGitFSCmd_ReadDir_root(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitcmd-readdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmds := []string{
+		"git init -q",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -q --allow-empty -m empty --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag empty",
+		"mkdir d",
+		"echo -n a > d/a",
+		"echo -n b > b",
+		"ln -s b c",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -q -m files --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo -n b2 > b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2007-01-02T15:04:05Z git commit -q -a -m b2 --author='a <a@a.com>' --date 2007-01-02T15:04:05Z",
+	}
+	for _, c := range cmds {
+		cmd := exec.Command("bash", "-c", c)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("exec %q failed: %s. Output was:\n\n%s", c, err, out)
+		}
+	}
+	r := &Repository{Dir: dir}
+	head, err := r.ResolveRevision("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := r.ResolveRevision("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type wantEntry struct {
+		name    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	t0, t1 := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), time.Date(2007, 1, 2, 15, 4, 5, 0, time.UTC)
+	want := []wantEntry{{"b", 0644, t1}, {"c", os.ModeSymlink, t0}, {"d", os.ModeDir, t0}}
+
+	for _, batch := range []bool{false, true} {
+		newFS := func(at vcs.CommitID) *gitFSCmd {
+			fs := &gitFSCmd{dir: dir, at: at, repo: r, repoEditLock: &r.editLock}
+			if batch {
+				fs.batch = &catFile{dir: dir}
+				fs.check = &catFile{dir: dir, check: true}
+			}
+			return fs
+		}
+
+		fs := newFS(head)
+		for _, path := range []string{"", ".", "/"} {
+			fis, err := fs.ReadDir(path)
+			if err != nil {
+				t.Errorf("batch=%v: ReadDir(%q): %s", batch, path, err)
+				continue
+			}
+			if len(fis) != len(want) {
+				t.Errorf("batch=%v: ReadDir(%q): got %d entries, want %d", batch, path, len(fis), len(want))
+				continue
+			}
+			for i, fi := range fis {
+				if fi == nil {
+					t.Errorf("batch=%v: ReadDir(%q): got nil entry %d", batch, path, i)
+					continue
+				}
+				if fi.Name() != want[i].name || fi.Mode() != want[i].mode {
+					t.Errorf("batch=%v: ReadDir(%q): got entry %d %q (mode %v), want %q (mode %v)", batch, path, i, fi.Name(), fi.Mode(), want[i].name, want[i].mode)
+				}
+				if !fi.ModTime().Equal(want[i].modTime) {
+					t.Errorf("batch=%v: ReadDir(%q): got entry %d %q mod time %v, want %v", batch, path, i, fi.Name(), fi.ModTime(), want[i].modTime)
+				}
+			}
+			if sys, ok := fis[1].Sys().(vcs.SymlinkInfo); !ok || sys.Dest != "b" {
+				t.Errorf("batch=%v: ReadDir(%q): got symlink Sys %#v, want destination %q", batch, path, fis[1].Sys(), "b")
+			}
+		}
+
+		// The root of a commit with no files is an empty directory.
+		fs = newFS(empty)
+		if fis, err := fs.ReadDir("."); err != nil || len(fis) != 0 {
+			t.Errorf("batch=%v: ReadDir of empty root: got %v (error %v), want no entries", batch, fis, err)
+		}
+
+		if _, err := newFS(head).ReadDir("doesntexist"); !os.IsNotExist(err) {
+			t.Errorf("batch=%v: ReadDir of nonexistent dir: got error %v, want os.IsNotExist", batch, err)
+		}
+	}
+}
+
+func TestCatFile_idleTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitcmd-catfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s. Output was:\n\n%s", err, out)
+	}
+
+	defer func(d time.Duration) { catFileIdleTimeout = d }(catFileIdleTimeout)
+	catFileIdleTimeout = 50 * time.Millisecond
+
+	c := &catFile{dir: dir, check: true}
+	defer c.Close()
+	running := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.cmd != nil
+	}
+
+	if _, _, err := c.query("HEAD"); err != errObjectMissing {
+		t.Fatalf("got error %v, want errObjectMissing", err)
+	}
+	if !running() {
+		t.Fatal("process not running after query")
+	}
+	for deadline := time.Now().Add(5 * time.Second); running(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("idle process was not stopped")
+		}
+	}
+
+	// It is restarted when needed.
+	if _, _, err := c.query("HEAD"); err != errObjectMissing {
+		t.Fatalf("after idle timeout: got error %v, want errObjectMissing", err)
+	}
+	if !running() {
+		t.Error("process not restarted")
+	}
+}
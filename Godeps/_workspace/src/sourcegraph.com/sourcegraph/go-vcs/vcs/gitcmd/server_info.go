@@ -0,0 +1,24 @@
+package gitcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.ServerInfoUpdater = (*Repository)(nil)
+
+func (r *Repository) UpdateServerInfo() error {
+	// Concurrent runs would fail to lock the files they write.
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	cmd := exec.Command("git", "update-server-info")
+	cmd.Dir = r.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package gitcmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
+)
+
+// maxBufferedBlobSize is the size of the largest file that
+// (*gitFSCmd).Open reads into memory. Larger files are streamed from
+// `git show`.
+const maxBufferedBlobSize = 1 << 20
+
+// openStream returns a reader that streams the contents of the file
+// at name from the output of `git show`. The caller must be holding
+// fs.repoEditLock.RLock() (but the returned reader may be used after
+// it is released).
+func (fs *gitFSCmd) openStream(name string) (vfs.ReadSeekCloser, error) {
+	s := &showReader{fs: fs, name: name}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+
+	// We can't see the whole output up front, so wait for the first
+	// byte to tell whether `git show` failed (e.g., because the file
+	// doesn't exist).
+	if _, err := s.r.Peek(1); err != io.EOF {
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+	args := s.cmd.Args
+	if err := s.cmd.Wait(); err != nil {
+		if err := fs.showError(name, args, err, s.stderr.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	// The file is empty (or is a submodule).
+	return util.NopCloser{ReadSeeker: bytes.NewReader(nil)}, nil
+}
+
+// showReader streams the contents of a file from the output of `git
+// show`. It supports seeking by discarding output, or by restarting
+// `git show` to seek backward.
+type showReader struct {
+	fs   *gitFSCmd
+	name string
+
+	cmd    *exec.Cmd // the running `git show` (nil if not started or exited)
+	stdout io.ReadCloser
+	r      *bufio.Reader
+	stderr bytes.Buffer
+	off    int64 // offset in the file of the next byte read from r
+	eof    bool  // whether all output has been read
+}
+
+func (s *showReader) start() error {
+	cmd := exec.Command("git", "show", string(s.fs.at)+":"+s.name)
+	cmd.Dir = s.fs.dir
+	s.stderr.Reset()
+	cmd.Stderr = &s.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.cmd, s.stdout, s.r = cmd, stdout, bufio.NewReader(stdout)
+	s.off, s.eof = 0, false
+	return nil
+}
+
+func (s *showReader) Read(p []byte) (int, error) {
+	if s.eof {
+		return 0, io.EOF
+	}
+	if s.cmd == nil {
+		if err := s.start(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.r.Read(p)
+	s.off += int64(n)
+	if err == io.EOF {
+		s.eof = true
+		args := s.cmd.Args
+		werr := s.cmd.Wait()
+		s.cmd = nil
+		if werr != nil {
+			return n, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", args, werr, bytes.TrimSpace(s.stderr.Bytes()))
+		}
+	}
+	return n, err
+}
+
+func (s *showReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = s.off + offset
+	case io.SeekEnd:
+		size, err := s.size()
+		if err != nil {
+			return 0, err
+		}
+		abs = size + offset
+	default:
+		return 0, errors.New("showReader.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("showReader.Seek: negative position")
+	}
+
+	if abs < s.off {
+		// Start over to seek backward.
+		if err := s.Close(); err != nil {
+			return 0, err
+		}
+		s.off, s.eof = 0, false
+	}
+	if abs > s.off {
+		if _, err := io.CopyN(ioutil.Discard, s, abs-s.off); err != nil && err != io.EOF {
+			return 0, err
+		}
+		s.off = abs // may be past the end of the file
+	}
+	return abs, nil
+}
+
+// size returns the size of the file.
+func (s *showReader) size() (int64, error) {
+	cmd := exec.Command("git", "cat-file", "-s", string(s.fs.at)+":"+s.name)
+	cmd.Dir = s.fs.dir
+	out, stderr, err := dividedOutput(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("exec %v failed: %s. Output was:\n\n%s", cmd.Args, err, stderr)
+	}
+	return strconv.ParseInt(string(bytes.TrimSpace(out)), 10, 64)
+}
+
+// Close stops `git show` (if it is still running).
+func (s *showReader) Close() error {
+	if s.cmd == nil {
+		return nil
+	}
+	s.stdout.Close()
+	s.cmd.Process.Kill()
+	s.cmd.Wait() // the error is expected because the process was killed
+	s.cmd = nil
+	return nil
+}
@@ -0,0 +1,91 @@
+package gitcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestMakeGitSSHWrapper_multipleKeys(t *testing.T) {
+	opt := &vcs.SSHConfig{
+		PrivateKey:  []byte("k0"),
+		PrivateKeys: [][]byte{[]byte("k1"), nil, []byte("k2")},
+		KnownHosts:  []byte("kh"),
+	}
+	env, tmpFiles, err := makeGitSSHWrapper(opt)
+	defer removeSSHFiles(tmpFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The known_hosts file, the 3 nonempty keys, and the wrapper.
+	if len(tmpFiles) != 5 {
+		t.Fatalf("got temp files %v, want 5", tmpFiles)
+	}
+	wrapper := tmpFiles[len(tmpFiles)-1]
+	if want := []string{"GIT_SSH=" + wrapper}; !reflect.DeepEqual(env, want) {
+		t.Errorf("got env %v, want %v", env, want)
+	}
+	script, err := ioutil.ReadFile(wrapper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(script), "#!/bin/sh\n") || !strings.Contains(string(script), "IdentitiesOnly=yes") {
+		t.Errorf("got wrapper script %q, want it to start with #!/bin/sh and offer only the given keys", script)
+	}
+
+	keyFiles, wantKeys := tmpFiles[1:4], []string{"k0", "k1", "k2"}
+	var lastIndex int
+	for i, keyFile := range keyFiles {
+		// The keys must be offered in order.
+		if idx := strings.Index(string(script), "-i "+keyFile); idx < lastIndex {
+			t.Errorf("key %d: got wrapper script %q, want it to offer %s after the previous keys", i, script, keyFile)
+		} else {
+			lastIndex = idx
+		}
+
+		fi, err := os.Stat(keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := fi.Mode().Perm(); mode != 0600 {
+			t.Errorf("key %d: got mode %o, want 0600", i, mode)
+		}
+		if data, _ := ioutil.ReadFile(keyFile); string(data) != wantKeys[i] {
+			t.Errorf("key %d: got contents %q, want %q", i, data, wantKeys[i])
+		}
+	}
+
+	removeSSHFiles(tmpFiles)
+	for _, name := range tmpFiles {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("got %s after removeSSHFiles (Stat error %v), want it removed", name, err)
+		}
+	}
+}
+
+func TestMakeGitSSHWrapper_agent(t *testing.T) {
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", "/tmp/agent.sock")
+
+	for _, useAgent := range []bool{false, true} {
+		env, tmpFiles, err := makeGitSSHWrapper(&vcs.SSHConfig{PrivateKey: []byte("k"), UseAgent: useAgent})
+		removeSSHFiles(tmpFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var gotAgent bool
+		for _, v := range env {
+			if v == "SSH_AUTH_SOCK=/tmp/agent.sock" {
+				gotAgent = true
+			}
+		}
+		if gotAgent != useAgent {
+			t.Errorf("UseAgent=%v: got env %v", useAgent, env)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package gitcmd
+
+import (
+	"io"
+	"os/exec"
+	"path/filepath"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+)
+
+var _ vcs.TarExporter = (*gitFSCmd)(nil)
+
+func (fs *gitFSCmd) Tar(path string) (io.ReadCloser, error) {
+	path = filepath.Clean(internal.Rel(path))
+	if err := checkSpecArgSafety(path); err != nil {
+		return nil, err
+	}
+
+	// Check that path exists first, since errors can't be reported
+	// once the archive is being streamed.
+	if _, err := fs.Lstat(path); err != nil {
+		return nil, err
+	}
+
+	fs.repoEditLock.RLock()
+	unlock := fs.repoEditLock.RUnlock
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
+
+	// Treat path literally, not as a pathspec pattern.
+	args := []string{"--literal-pathspecs", "archive", "--format=tar", string(fs.at)}
+	if path != "." {
+		args = append(args, "--", path)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = fs.dir
+	rc, err := startCmdReadCloser(cmd, unlock)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the repository locked until the caller is done reading.
+	// Closing the reader early stops and reaps `git archive`.
+	unlock = nil
+	return rc, nil
+}
@@ -0,0 +1,72 @@
+package gitcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGitFSCmd_Tar_closeError tests that closing a tar archive
+// returns the error of `git archive` if it failed after its output
+// was read, but not if the archive was closed early.
+func TestGitFSCmd_Tar_closeError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitcmd-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmds := []string{
+		"git init -q",
+		"echo -n a > a",
+		"echo -n b > b",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -q -m files --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	for _, c := range cmds {
+		cmd := exec.Command("bash", "-c", c)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("exec %q failed: %s. Output was:\n\n%s", c, err, out)
+		}
+	}
+	r := &Repository{Dir: dir}
+	head, err := r.ResolveRevision("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &gitFSCmd{dir: dir, at: head, repo: r, repoEditLock: &r.editLock}
+
+	// Closing early isn't an error.
+	rc, err := fs.Tar(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Errorf("got error %v closing unread archive, want nil", err)
+	}
+
+	// Remove a blob so that `git archive` fails partway through.
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "master:b").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(out))
+	if err := os.Remove(filepath.Join(dir, ".git", "objects", id[:2], id[2:])); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err = fs.Tar(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err == nil || !strings.Contains(err.Error(), id) {
+		t.Errorf("got error %v closing archive with a missing blob, want the `git archive` error (naming %s)", err, id)
+	}
+}
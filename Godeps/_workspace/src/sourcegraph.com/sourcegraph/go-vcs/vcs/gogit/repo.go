@@ -0,0 +1,537 @@
+// Package gogit implements a git repository using the pure-Go go-git
+// library for common read operations, so that they don't require
+// forking a git process. Operations that go-git doesn't support (or
+// that aren't implemented here yet) fall back to the gitcmd
+// implementation.
+package gogit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
+)
+
+func init() {
+	// Register under a distinct name, so that importing this package
+	// doesn't replace the "git" opener. Callers that want go-git to
+	// open git repositories can register Open as the "git" opener.
+	vcs.RegisterOpener("gogit", func(dir string) (vcs.Repository, error) {
+		return Open(dir)
+	})
+}
+
+type Repository struct {
+	*gitcmd.Repository
+	s *filesystem.Storage
+
+	// editLock protects ops that change repository data. Reads via
+	// go-git hold it for reading, and writes (which are implemented
+	// by gitcmd) hold it for writing (see write).
+	editLock sync.RWMutex
+}
+
+func (r *Repository) String() string {
+	return fmt.Sprintf("git (go-git) repo at %s", r.Dir)
+}
+
+// Open opens the git repository at dir, which may be either a
+// working tree (containing a .git directory) or a bare repository.
+// Unlike gitcmd.Open, it doesn't run any git commands.
+func Open(dir string) (*Repository, error) {
+	gitDir := filepath.Join(dir, ".git")
+	if fi, err := os.Stat(gitDir); err != nil || !fi.IsDir() {
+		gitDir = dir
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "objects")); err != nil {
+		return nil, &os.PathError{
+			Op:   "Open git repo",
+			Path: dir,
+			Err:  os.ErrNotExist,
+		}
+	}
+
+	s := filesystem.NewStorage(osfs.New(gitDir), cache.NewObjectLRUDefault())
+	return &Repository{Repository: &gitcmd.Repository{Dir: dir}, s: s}, nil
+}
+
+// write runs fn, which changes repository data (using gitcmd, which
+// has its own lock), while holding editLock. Afterwards it discards
+// go-git's index of the repository's packfiles, which fn may have
+// added or removed; it is read again when next needed.
+func (r *Repository) write(fn func() error) error {
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+	defer r.s.Reindex()
+	return fn()
+}
+
+func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) error {
+	return r.write(func() error { return r.Repository.UpdateEverything(opt) })
+}
+
+func (r *Repository) Fetch(remote string, opt vcs.RemoteOpts) error {
+	return r.write(func() error { return r.Repository.Fetch(remote, opt) })
+}
+
+func (r *Repository) GC(opt vcs.GCOptions) error {
+	return r.write(func() error { return r.Repository.GC(opt) })
+}
+
+func (r *Repository) CreateBranch(name string, commit vcs.CommitID) error {
+	return r.write(func() error { return r.Repository.CreateBranch(name, commit) })
+}
+
+func (r *Repository) DeleteBranch(name string) error {
+	return r.write(func() error { return r.Repository.DeleteBranch(name) })
+}
+
+func (r *Repository) CreateTag(name string, commit vcs.CommitID) error {
+	return r.write(func() error { return r.Repository.CreateTag(name, commit) })
+}
+
+func (r *Repository) DeleteTag(name string) error {
+	return r.write(func() error { return r.Repository.DeleteTag(name) })
+}
+
+// isCommitID returns whether s is a full (40-character) lowercase hex
+// commit ID.
+func isCommitID(s string) bool {
+	return len(s) == 40 && strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// isSimpleRefName returns whether name can only refer to a ref (and
+// not to a revision expression like "master~1" or "HEAD@{1}").
+func isSimpleRefName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "-") && !strings.ContainsAny(name, "~^:@{}*?[\\ \t\n")
+}
+
+func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	if isCommitID(spec) {
+		id, err := r.peelToCommit(plumbing.NewHash(spec))
+		if err == plumbing.ErrObjectNotFound {
+			return "", vcs.ErrRevisionNotFound
+		}
+		return id, err
+	}
+
+	if isSimpleRefName(spec) {
+		// Try the same refs, in the same order, as `git rev-parse`
+		// (see gitrevisions(7)).
+		for _, name := range []string{spec, "refs/" + spec, "refs/tags/" + spec, "refs/heads/" + spec, "refs/remotes/" + spec, "refs/remotes/" + spec + "/HEAD"} {
+			id, err := r.resolveRef(plumbing.ReferenceName(name))
+			if err == plumbing.ErrReferenceNotFound {
+				continue
+			}
+			return id, err
+		}
+	}
+
+	// Not implemented using go-git (e.g., revision expressions or
+	// abbreviated commit IDs), so call gitcmd.
+	return r.Repository.ResolveRevision(spec)
+}
+
+func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	id, err := r.resolveRef(plumbing.NewBranchReferenceName(name))
+	if err == plumbing.ErrReferenceNotFound {
+		return "", vcs.ErrBranchNotFound
+	}
+	return id, err
+}
+
+func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	id, err := r.resolveRef(plumbing.NewTagReferenceName(name))
+	if err == plumbing.ErrReferenceNotFound {
+		return "", vcs.ErrTagNotFound
+	}
+	return id, err
+}
+
+// resolveRef returns the commit that the named ref points to,
+// following symbolic refs and peeling annotated tags. The caller must
+// be holding r.editLock.
+func (r *Repository) resolveRef(name plumbing.ReferenceName) (vcs.CommitID, error) {
+	ref, err := storer.ResolveReference(r.s, name)
+	if err != nil {
+		return "", err
+	}
+	return r.peelToCommit(ref.Hash())
+}
+
+// peelToCommit returns the commit that the object h refers to,
+// peeling annotated tags. The caller must be holding r.editLock.
+func (r *Repository) peelToCommit(h plumbing.Hash) (vcs.CommitID, error) {
+	for {
+		o, err := r.s.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return "", err
+		}
+		switch o.Type() {
+		case plumbing.CommitObject:
+			return vcs.CommitID(h.String()), nil
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(r.s, o)
+			if err != nil {
+				return "", err
+			}
+			h = tag.Target
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a commit", h, o.Type())
+		}
+	}
+}
+
+// getCommit finds and returns the raw go-git Commit. The caller must
+// be holding r.editLock.
+func (r *Repository) getCommit(id vcs.CommitID) (*object.Commit, error) {
+	if !isCommitID(string(id)) {
+		return nil, vcs.ErrCommitNotFound
+	}
+	c, err := object.GetCommit(r.s, plumbing.NewHash(string(id)))
+	if err == plumbing.ErrObjectNotFound {
+		return nil, vcs.ErrCommitNotFound
+	}
+	return c, err
+}
+
+func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	c, err := r.getCommit(id)
+	if err != nil {
+		return nil, err
+	}
+	return makeCommit(c), nil
+}
+
+func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Path != "" || opt.Cursor != "" || opt.UseMailmap || opt.ReencodeMessage || opt.IncludeSignatureStatus || opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() || !isCommitID(string(opt.Head)) || (opt.Base != "" && !isCommitID(string(opt.Base))) {
+		// Not implemented using go-git yet, so call gitcmd.
+		return r.Repository.Commits(opt)
+	}
+
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	head, err := r.getCommit(opt.Head)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Exclude all commits reachable from Base (like `git log
+	// Base..Head`).
+	var hide map[plumbing.Hash]bool
+	if opt.Base != "" {
+		base, err := r.getCommit(opt.Base)
+		if err != nil {
+			return nil, 0, err
+		}
+		hide = map[plumbing.Hash]bool{}
+		err = object.NewCommitPreorderIter(base, nil, nil).ForEach(func(c *object.Commit) error {
+			hide[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Walk the commits in committer date order, like `git log`.
+	var commits []*vcs.Commit
+	total := uint(0)
+	err = object.NewCommitIterCTime(head, hide, nil).ForEach(func(c *object.Commit) error {
+		if total >= opt.Skip && (opt.N == 0 || uint(len(commits)) < opt.N) {
+			commits = append(commits, makeCommit(c))
+		}
+		total++
+		// If we want total, keep going until the end.
+		if !opt.NoTotal {
+			return nil
+		}
+		// Otherwise stop once N has been satisfied.
+		if opt.N != 0 && uint(len(commits)) >= opt.N {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if opt.NoTotal {
+		total = 0
+	}
+
+	return commits, total, nil
+}
+
+func makeCommit(c *object.Commit) *vcs.Commit {
+	var parents []vcs.CommitID
+	if len(c.ParentHashes) > 0 {
+		parents = make([]vcs.CommitID, len(c.ParentHashes))
+		for i, h := range c.ParentHashes {
+			parents[i] = vcs.CommitID(h.String())
+		}
+	}
+
+	msg := strings.TrimSuffix(c.Message, "\n")
+	committer := vcs.NewSignature(c.Committer.Name, c.Committer.Email, c.Committer.When)
+	subject, body := vcs.SplitMessage(msg)
+	return &vcs.Commit{
+		ID:        vcs.CommitID(c.Hash.String()),
+		Author:    vcs.NewSignature(c.Author.Name, c.Author.Email, c.Author.When),
+		Committer: &committer,
+		Message:   msg,
+		Parents:   parents,
+		Trailers:  vcs.ParseTrailers(msg),
+		Subject:   subject,
+		Body:      body,
+	}
+}
+
+func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	c, err := r.getCommit(at)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return &gitFSGoGit{dir: r.Dir, at: at, commit: c, tree: tree, s: r.s, repoEditLock: &r.editLock}, nil
+}
+
+type gitFSGoGit struct {
+	dir    string
+	at     vcs.CommitID
+	commit *object.Commit
+	tree   *object.Tree
+	s      *filesystem.Storage
+
+	// mu protects tree, whose subtree cache is not safe for
+	// concurrent use.
+	mu sync.Mutex
+
+	repoEditLock *sync.RWMutex
+}
+
+// getEntry returns the tree entry at path. The caller must be holding
+// fs.mu.
+func (fs *gitFSGoGit) getEntry(path string) (*object.TreeEntry, error) {
+	e, err := fs.tree.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	return e, err
+}
+
+func (fs *gitFSGoGit) readFileBytes(name string) ([]byte, error) {
+	fs.mu.Lock()
+	e, err := fs.getEntry(name)
+	fs.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Mode {
+	case filemode.Submodule:
+		// Return empty for a submodule for now.
+		return nil, nil
+	case filemode.Dir:
+		return nil, fmt.Errorf("read %s: is a directory", name)
+	}
+	return fs.readBlob(e.Hash)
+}
+
+func (fs *gitFSGoGit) readBlob(h plumbing.Hash) ([]byte, error) {
+	b, err := object.GetBlob(fs.s, h)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func (fs *gitFSGoGit) Open(name string) (vfs.ReadSeekCloser, error) {
+	name = internal.Rel(name)
+
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
+	b, err := fs.readFileBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	return util.NopCloser{ReadSeeker: bytes.NewReader(b)}, nil
+}
+
+func (fs *gitFSGoGit) Lstat(path string) (os.FileInfo, error) {
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
+	path = filepath.Clean(internal.Rel(path))
+
+	mtime := fs.getModTime()
+
+	if path == "." {
+		return &util.FileInfo{Mode_: os.ModeDir, ModTime_: mtime}, nil
+	}
+
+	fs.mu.Lock()
+	e, err := fs.getEntry(path)
+	fs.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.makeFileInfo(path, e)
+	if err != nil {
+		return nil, err
+	}
+	fi.ModTime_ = mtime
+
+	return fi, nil
+}
+
+func (fs *gitFSGoGit) Stat(path string) (os.FileInfo, error) {
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
+	return internal.Stat(fs.Lstat, path)
+}
+
+func (fs *gitFSGoGit) getModTime() time.Time {
+	return fs.commit.Author.When
+}
+
+func (fs *gitFSGoGit) makeFileInfo(path string, e *object.TreeEntry) (*util.FileInfo, error) {
+	switch e.Mode {
+	case filemode.Dir:
+		return &util.FileInfo{Name_: e.Name, Mode_: os.ModeDir}, nil
+
+	case filemode.Submodule:
+		url := "" // url is not available if submodules are not initialized
+		if cfg, err := fs.s.Config(); err == nil {
+			if sm, ok := cfg.Submodules[path]; ok {
+				url = sm.URL
+			}
+		}
+		return &util.FileInfo{
+			Name_: e.Name,
+			Mode_: vcs.ModeSubmodule,
+			Sys_: vcs.SubmoduleInfo{
+				URL:      url,
+				CommitID: vcs.CommitID(e.Hash.String()),
+			},
+		}, nil
+
+	case filemode.Symlink:
+		// Dereference symlink.
+		b, err := fs.readBlob(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return &util.FileInfo{
+			Name_: e.Name,
+			Size_: int64(len(b)),
+			Mode_: os.ModeSymlink,
+			Sys_:  vcs.SymlinkInfo{Dest: string(b)},
+		}, nil
+
+	case filemode.Regular, filemode.Deprecated, filemode.Executable:
+		size, err := fs.s.EncodedObjectSize(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(0644)
+		if e.Mode == filemode.Executable {
+			mode = 0755
+		}
+		return &util.FileInfo{
+			Name_: e.Name,
+			Size_: size,
+			Mode_: mode,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected file mode %v while making file info (expected file, dir, symlink, or submodule)", e.Mode)
+}
+
+func (fs *gitFSGoGit) ReadDir(path string) ([]os.FileInfo, error) {
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
+	path = filepath.Clean(internal.Rel(path))
+
+	subtree := fs.tree
+	if path != "." {
+		fs.mu.Lock()
+		e, err := fs.getEntry(path)
+		fs.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if e.Mode != filemode.Dir {
+			return nil, &os.PathError{Op: "readdir", Path: path, Err: errors.New("not a directory")}
+		}
+
+		subtree, err = object.GetTree(fs.s, e.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fis := make([]os.FileInfo, len(subtree.Entries))
+	for i := range subtree.Entries {
+		e := &subtree.Entries[i]
+		fi, err := fs.makeFileInfo(filepath.Join(path, e.Name), e)
+		if err != nil {
+			return nil, err
+		}
+		fi.ModTime_ = fs.getModTime()
+		fis[i] = fi
+	}
+	util.SortFileInfosByName(fis)
+
+	return fis, nil
+}
+
+func (fs *gitFSGoGit) String() string {
+	return fmt.Sprintf("git repository %s commit %s (go-git)", fs.dir, fs.at)
+}
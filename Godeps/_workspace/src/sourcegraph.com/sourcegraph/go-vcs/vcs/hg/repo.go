@@ -22,7 +22,6 @@ import (
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/hgcmd"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
-	"sourcegraph.com/sqs/pbtypes"
 )
 
 func init() {
@@ -147,6 +146,13 @@ func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Cursor != "" {
+		return nil, 0, errors.New("hg: commits cursor not supported")
+	}
+	if opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() {
+		return nil, 0, errors.New("hg: commits author and date filters not supported")
+	}
+
 	rec, err := r.getRec(opt.Head)
 	if err != nil {
 		return nil, 0, err
@@ -210,11 +216,14 @@ func (r *Repository) makeCommit(rec *hg_revlog.Rec) (*vcs.Commit, error) {
 		}
 	}
 
+	subject, body := vcs.SplitMessage(ce.Comment)
 	return &vcs.Commit{
 		ID:      vcs.CommitID(ce.Id),
-		Author:  vcs.Signature{addr.Name, addr.Address, pbtypes.NewTimestamp(ce.Date)},
+		Author:  vcs.NewSignature(addr.Name, addr.Address, ce.Date),
 		Message: ce.Comment,
 		Parents: parents,
+		Subject: subject,
+		Body:    body,
 	}, nil
 }
 
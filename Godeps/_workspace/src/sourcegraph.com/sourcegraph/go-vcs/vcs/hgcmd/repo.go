@@ -1,9 +1,7 @@
 package hgcmd
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -11,16 +9,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"sourcegraph.com/sourcegraph/go-diff/diff"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
-	"sourcegraph.com/sqs/pbtypes"
 
 	"golang.org/x/tools/godoc/vfs"
 )
@@ -60,14 +59,24 @@ func Open(dir string) (*Repository, error) {
 			Err:  errors.New("Mercurial repository not found."),
 		}
 	}
-	return &Repository{dir}, nil
+	return &Repository{Dir: dir}, nil
 }
 
 type Repository struct {
 	Dir string
+
+	// editLock protects ops that change repository data (pulls).
+	// hg's own store lock keeps each command consistent, but commands
+	// that read the repository hold editLock.RLock so that, as with
+	// the git backends, they aren't run during a pull. (None of the
+	// commands use the working copy; they all name a revision.)
+	editLock sync.RWMutex
 }
 
 func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
 	cmd := exec.Command("hg", "identify", "--debug", "-i", "--rev="+spec)
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
@@ -102,6 +111,9 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 		return nil, fmt.Errorf("vcs.BranchesOptions.ContainsCommit option not implemented")
 	}
 
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
 	refs, err := r.execAndParseCols("branches")
 	if err != nil {
 		return nil, err
@@ -118,6 +130,9 @@ func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 }
 
 func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
 	refs, err := r.execAndParseCols("tags")
 	if err != nil {
 		return nil, err
@@ -172,6 +187,9 @@ func (r *Repository) execAndParseCols(subcmd string) ([][2]string, error) {
 }
 
 func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
 	commits, _, err := r.commitLog(vcs.CommitsOptions{Head: id, N: 1, NoTotal: true})
 	if err != nil {
 		return nil, err
@@ -185,6 +203,9 @@ func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 }
 
 func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
 	return r.commitLog(opt)
 }
 
@@ -195,6 +216,13 @@ func isUnknownRevisionError(output, revSpec string) bool {
 }
 
 func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Cursor != "" {
+		return nil, 0, errors.New("hg: commits cursor not supported")
+	}
+	if opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() {
+		return nil, 0, errors.New("hg: commits author and date filters not supported")
+	}
+
 	revSpec := string(opt.Head)
 	if opt.Skip != 0 {
 		revSpec += "~" + strconv.FormatUint(uint64(opt.N), 10)
@@ -236,11 +264,14 @@ func (r *Repository) commitLog(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, err
 			return nil, 0, fmt.Errorf("r.GetParents failed: %s. Output was:\n\n%s", err, out)
 		}
 
+		subject, body := vcs.SplitMessage(string(parts[4]))
 		commits[i] = &vcs.Commit{
 			ID:      id,
-			Author:  vcs.Signature{string(parts[1]), string(parts[2]), pbtypes.NewTimestamp(authorTime)},
+			Author:  vcs.NewSignature(string(parts[1]), string(parts[2]), authorTime),
 			Message: string(parts[4]),
 			Parents: parents,
+			Subject: subject,
+			Body:    body,
 		}
 	}
 
@@ -304,7 +335,14 @@ func (r *Repository) getParents(revSpec vcs.CommitID) ([]vcs.CommitID, error) {
 }
 
 func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
-	cmd := exec.Command("hg", "-v", "diff", "-p", "--git", "--rev="+string(base), "--rev="+string(head), "--")
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	baseRev := string(base)
+	if opt != nil && opt.ExcludeReachableFromBoth {
+		baseRev = "ancestor(" + string(base) + ", " + string(head) + ")"
+	}
+	cmd := exec.Command("hg", "-v", "diff", "-p", "--git", "--rev="+baseRev, "--rev="+string(head), "--")
 	if opt != nil {
 		cmd.Args = append(cmd.Args, opt.Paths...)
 	}
@@ -344,10 +382,64 @@ func (r *Repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.D
 	}, nil
 }
 
+// A CrossRepo is a Mercurial repository that can be used in
+// cross-repo operations (e.g., as the head repository for a
+// cross-repo diff in another Mercurial repository's CrossRepoDiff
+// method).
+type CrossRepo interface {
+	HgRootDir() string // the repo's root directory
+}
+
+func (r *Repository) HgRootDir() string { return r.Dir }
+
+func (r *Repository) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repository, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	var headDir string // path to head repo on local filesystem
+	if headRepo, ok := headRepo.(CrossRepo); ok {
+		headDir = headRepo.HgRootDir()
+	} else {
+		return nil, fmt.Errorf("hg cross-repo diff not supported against head repo type %T", headRepo)
+	}
+
+	if headDir == r.Dir {
+		return r.Diff(base, head, opt)
+	}
+
+	if err := r.pullRemote(headDir, head); err != nil {
+		return nil, err
+	}
+
+	return r.Diff(base, head, opt)
+}
+
+// pullRemote pulls the changeset rev (and its ancestors) from the
+// repository at repoDir into this repository.
+func (r *Repository) pullRemote(repoDir string, rev vcs.CommitID) error {
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
+	// The repositories need not be related (like `git fetch`), so
+	// use --force.
+	cmd := exec.Command("hg", "pull", "--force", "--rev="+string(rev), "--", repoDir)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), string(rev)) {
+			return vcs.ErrCommitNotFound
+		}
+		return fmt.Errorf("exec %v in %s failed: %s. Output was:\n\n%s", cmd.Args, cmd.Dir, err, out)
+	}
+	return nil
+}
+
 func (r *Repository) UpdateEverything(opt vcs.RemoteOpts) error {
 	if opt.SSH != nil {
 		return fmt.Errorf("hgcmd: ssh remote not supported")
 	}
+
+	r.editLock.Lock()
+	defer r.editLock.Unlock()
+
 	cmd := exec.Command("hg", "pull")
 	cmd.Dir = r.Dir
 	out, err := cmd.CombinedOutput()
@@ -361,63 +453,109 @@ func (r *Repository) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hunk,
 	if opt == nil {
 		opt = &vcs.BlameOptions{}
 	}
+	if opt.OldestCommit != "" {
+		return nil, fmt.Errorf("OldestCommit not implemented")
+	}
+	if len(opt.IgnoreRevs) > 0 || opt.IgnoreRevsFile != "" {
+		return nil, fmt.Errorf("IgnoreRevs and IgnoreRevsFile not implemented")
+	}
+
+	rev := string(opt.NewestCommit)
+	if rev == "" {
+		rev = "tip"
+	}
 
-	// TODO(sqs): implement OldestCommit
-	cmd := exec.Command("python", "-", r.Dir, string(opt.NewestCommit), path)
+	r.editLock.RLock()
+	defer r.editLock.RUnlock()
+
+	// --debug causes full changeset IDs to be printed, and -v causes
+	// full user names (with email addresses) to be printed.
+	cmd := exec.Command("hg", "annotate", "--debug", "-v", "-u", "-d", "-n", "-c", "--rev="+rev, "--", internal.Rel(path))
 	cmd.Dir = r.Dir
-	cmd.Stdin = strings.NewReader(hgRepoAnnotatePy)
-	stdout, err := cmd.StdoutPipe()
+	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, err
+		out = bytes.TrimSpace(out)
+		if isUnknownRevisionError(string(out), rev) {
+			return nil, vcs.ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("exec `hg annotate` failed: %s. Output was:\n\n%s", err, out)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
+	if len(out) == 0 {
+		// Empty file (same as the git backend).
+		return nil, nil
 	}
 
-	in := bufio.NewReader(stdout)
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
+	return parseHgAnnotate(out, opt.StartLine, opt.EndLine)
+}
+
+// hgAnnotateLine matches a line of `hg annotate --debug -v -u -d -n
+// -c` output: "USER REV CHANGESET DATE: LINE" (with USER and REV
+// padded with leading whitespace).
+var hgAnnotateLine = regexp.MustCompile(`^\s*(.*?)\s+\d+ ([0-9a-f]{40}) (\w{3} \w{3} \d{2} \d{2}:\d{2}:\d{2} \d{4} [+-]\d{4}): (.*)$`)
+
+// parseHgAnnotate parses the output of `hg annotate --debug -v -u
+// -d -n -c` into hunks, coalescing consecutive lines from the same
+// changeset. If startLine or endLine are nonzero, only lines in that
+// 1-indexed, inclusive range are included.
+func parseHgAnnotate(out []byte, startLine, endLine int) ([]*vcs.Hunk, error) {
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	var hunks []*vcs.Hunk
+	byteOffset := 0
+	for i, line := range lines {
+		m := hgAnnotateLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("unexpected line in `hg annotate` output: %q", line)
+		}
+		user, commitID, dateStr, content := m[1], vcs.CommitID(m[2]), m[3], m[4]
+		lineNo := i + 1
+		start := byteOffset
+		byteOffset += len(content) + 1 // include newline
+		if lineNo < startLine || (endLine != 0 && lineNo > endLine) {
+			continue
+		}
 
-	var data struct {
-		Commits map[string]struct {
-			Author     struct{ Name, Email string }
-			AuthorDate time.Time
+		if n := len(hunks); n > 0 && hunks[n-1].CommitID == commitID {
+			hunks[n-1].EndLine = lineNo + 1
+			hunks[n-1].EndByte = byteOffset
+			continue
 		}
-		Hunks map[string][]struct {
-			CommitID           string
-			StartLine, EndLine int
-			StartByte, EndByte int
+
+		date, err := time.Parse("Mon Jan 02 15:04:05 2006 -0700", dateStr)
+		if err != nil {
+			return nil, err
 		}
+		name, email := parseHgUser(user)
+		hunks = append(hunks, &vcs.Hunk{
+			StartLine: lineNo,
+			EndLine:   lineNo + 1,
+			StartByte: start,
+			EndByte:   byteOffset,
+			CommitID:  commitID,
+			Author:    vcs.NewSignature(name, email, date),
+		})
 	}
-	jsonErr := json.NewDecoder(in).Decode(&data)
-	errOut, _ := ioutil.ReadAll(stderr)
-	if jsonErr != nil {
-		cmd.Wait()
-		return nil, fmt.Errorf("%s (stderr: %s)", jsonErr, errOut)
-	}
-	if err := cmd.Wait(); err != nil {
-		return nil, fmt.Errorf("%s (stderr: %s)", err, errOut)
-	}
-
-	hunks := make([]*vcs.Hunk, len(data.Hunks[path]))
-	for i, hunk := range data.Hunks[path] {
-		c := data.Commits[hunk.CommitID]
-		hunks[i] = &vcs.Hunk{
-			StartLine: hunk.StartLine,
-			EndLine:   hunk.EndLine,
-			StartByte: hunk.StartByte,
-			EndByte:   hunk.EndByte,
-			CommitID:  vcs.CommitID(hunk.CommitID),
-			Author: vcs.Signature{
-				Name:  c.Author.Name,
-				Email: c.Author.Email,
-				Date:  pbtypes.NewTimestamp(c.AuthorDate.In(time.UTC)),
-			},
+	return hunks, nil
+}
+
+// parseHgUser splits an hg user (usually of the form "Name <email>")
+// into a name and email, like hg's "person" and "email" template
+// filters.
+func parseHgUser(user string) (name, email string) {
+	if i := strings.Index(user, "<"); i != -1 {
+		email = user[i+1:]
+		if j := strings.Index(email, ">"); j != -1 {
+			email = email[:j]
 		}
+		name = strings.Trim(strings.TrimSpace(user[:i]), `"`)
+		if name == "" {
+			name = email
+		}
+		return name, email
 	}
-	return hunks, nil
+	if i := strings.Index(user, "@"); i != -1 {
+		return user[:i], user
+	}
+	return user, ""
 }
 
 func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
@@ -426,17 +564,27 @@ func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, er
 
 func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
 	return &hgFSCmd{
-		dir: r.Dir,
-		at:  at,
+		dir:          r.Dir,
+		at:           at,
+		repoEditLock: &r.editLock,
 	}, nil
 }
 
 type hgFSCmd struct {
-	dir string
-	at  vcs.CommitID
+	dir          string
+	at           vcs.CommitID
+	repoEditLock *sync.RWMutex
 }
 
 func (fs *hgFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+	return fs.open(name)
+}
+
+// open is like Open. The caller must be holding
+// fs.repoEditLock.RLock().
+func (fs *hgFSCmd) open(name string) (vfs.ReadSeekCloser, error) {
 	name = internal.Rel(name)
 	cmd := exec.Command("hg", "cat", "--rev="+string(fs.at), "--", name)
 	cmd.Dir = fs.dir
@@ -457,6 +605,9 @@ func (fs *hgFSCmd) Lstat(path string) (os.FileInfo, error) {
 func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 	// TODO(sqs): follow symlinks (as Stat is required to do)
 
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+
 	path = internal.Rel(path)
 	var mtime time.Time
 
@@ -481,7 +632,7 @@ func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 	err = cmd.Run()
 	if err != nil {
 		// hg doesn't track dirs, so use a workaround to see if path is a dir.
-		if _, err := fs.ReadDir(path); err == nil {
+		if _, err := fs.readDir(path); err == nil {
 			return &util.FileInfo{Name_: filepath.Base(path), Mode_: os.ModeDir,
 				ModTime_: mtime}, nil
 		}
@@ -489,7 +640,7 @@ func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 	}
 
 	// read file to determine file size
-	f, err := fs.Open(path)
+	f, err := fs.open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -501,6 +652,14 @@ func (fs *hgFSCmd) Stat(path string) (os.FileInfo, error) {
 }
 
 func (fs *hgFSCmd) ReadDir(path string) ([]os.FileInfo, error) {
+	fs.repoEditLock.RLock()
+	defer fs.repoEditLock.RUnlock()
+	return fs.readDir(path)
+}
+
+// readDir is like ReadDir. The caller must be holding
+// fs.repoEditLock.RLock().
+func (fs *hgFSCmd) readDir(path string) ([]os.FileInfo, error) {
 	path = filepath.Clean(internal.Rel(path))
 	// This combination of --include and --exclude opts gets all the files in
 	// the dir specified by path, plus all files one level deeper (but no
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
+)
+
+// MaxSymlinkDepth is the maximum number of symlinks that Stat follows
+// when resolving a path (to detect symlink loops).
+const MaxSymlinkDepth = 40
+
+// ErrSymlinkLoop is returned (in an *os.PathError) by Stat when a
+// path can't be resolved within MaxSymlinkDepth symlinks.
+var ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// Stat follows symlinks at name (using lstat to get info about each
+// path, which must return a vcs.SymlinkInfo in the Sys field for
+// symlinks) and returns info about the final target, with the name
+// of the original path. Symlinks whose targets are absolute or
+// outside of the repository are treated as nonexistent.
+func Stat(lstat func(name string) (os.FileInfo, error), name string) (os.FileInfo, error) {
+	orig := path.Clean(Rel(name))
+	p := orig
+	for depth := 0; ; depth++ {
+		fi, err := lstat(p)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if p == orig {
+				return fi, nil
+			}
+			return renameFileInfo(fi, path.Base(orig)), nil
+		}
+
+		if depth == MaxSymlinkDepth {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: ErrSymlinkLoop}
+		}
+
+		si, ok := fi.Sys().(vcs.SymlinkInfo)
+		if !ok {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: errors.New("symlink has no destination")}
+		}
+		if path.IsAbs(si.Dest) {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+		}
+		p = path.Join(path.Dir(p), si.Dest)
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+		}
+	}
+}
+
+// renameFileInfo returns a copy of fi with a different name (e.g.,
+// the name of the symlink that was followed to get the file info).
+func renameFileInfo(fi os.FileInfo, name string) os.FileInfo {
+	if ufi, ok := fi.(*util.FileInfo); ok {
+		fi2 := *ufi
+		fi2.Name_ = name
+		return &fi2
+	}
+	return &util.FileInfo{Name_: name, Mode_: fi.Mode(), Size_: fi.Size(), ModTime_: fi.ModTime(), Sys_: fi.Sys()}
+}
@@ -0,0 +1,13 @@
+package vcs
+
+// A LastCommitter is a repository that can find the last commit that
+// changed each of many paths (for example, to show alongside each
+// entry of a directory listing).
+type LastCommitter interface {
+	// LastCommits returns, for each of the paths, the most recent
+	// commit in the history of at that changed it (or, for a
+	// directory, that changed any file in it). The returned slice has
+	// one element per path, which is nil if no commit changed the
+	// path (e.g., because it doesn't exist).
+	LastCommits(at CommitID, paths []string) ([]*Commit, error)
+}
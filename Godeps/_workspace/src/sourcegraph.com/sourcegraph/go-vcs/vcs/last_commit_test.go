@@ -0,0 +1,96 @@
+package vcs_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestLastCommitter_LastCommits(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"mkdir -p d/e",
+		"echo a > a",
+		"echo b > d/b",
+		"echo c > d/e/c",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m c1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo b2 > d/b",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -m c2 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git checkout -q -b x",
+		"echo c3 > d/e/c",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m c3 --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"git checkout -q master",
+		"echo a4 > a",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z git commit -m c4 --author='a <a@a.com>' --date 2006-01-02T15:04:08Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:09Z GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:09Z git merge --no-ff -m merge x",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.LastCommitter
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			Commits(vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+
+	paths := []string{"a", "d", "d/b", "d/e", "/d/e/c", ".", "nonexistent"}
+	// The merge changed the root dir (it differs from both parents),
+	// but not d (it's identical to d in branch x).
+	wantSubjects := []string{"c4", "c3", "c2", "c3", "c3", "merge", ""}
+	for label, test := range tests {
+		head, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		commits, err := test.repo.LastCommits(head, paths)
+		if err != nil {
+			t.Errorf("%s: LastCommits: %s", label, err)
+			continue
+		}
+		if len(commits) != len(paths) {
+			t.Errorf("%s: got %d commits, want %d", label, len(commits), len(paths))
+			continue
+		}
+		for i, path := range paths {
+			commit := commits[i]
+			if wantSubjects[i] == "" {
+				if commit != nil {
+					t.Errorf("%s: %s: got commit %q, want nil", label, path, commit.Subject)
+				}
+				continue
+			}
+			if commit == nil {
+				t.Errorf("%s: %s: got nil commit, want %q", label, path, wantSubjects[i])
+				continue
+			}
+			if commit.Subject != wantSubjects[i] {
+				t.Errorf("%s: %s: got commit %q, want %q", label, path, commit.Subject, wantSubjects[i])
+			}
+
+			// The commit must be the same as Commits returns.
+			want, _, err := test.repo.Commits(vcs.CommitsOptions{Head: head, Path: strings.TrimPrefix(path, "/"), N: 1, NoTotal: true})
+			if err != nil {
+				t.Errorf("%s: %s: Commits: %s", label, path, err)
+				continue
+			}
+			if len(want) != 1 || !reflect.DeepEqual(commit, want[0]) {
+				t.Errorf("%s: %s: got commit %+v, want (as Commits) %+v", label, path, commit, want)
+			}
+		}
+
+		if _, err := test.repo.LastCommits(nonexistentCommitID, paths); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: LastCommits(nonexistent commit): got error %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
@@ -20,3 +20,21 @@ type CrossRepoMerger interface {
 	// in repoB.
 	CrossRepoMergeBase(a CommitID, repoB Repository, b CommitID) (CommitID, error)
 }
+
+// An AncestorChecker is a repository that can determine whether a
+// commit is an ancestor of another.
+type AncestorChecker interface {
+	// IsAncestor returns whether commit a is an ancestor of (or the
+	// same as) commit b. If a is not an ancestor of b, it returns
+	// false and a nil error.
+	IsAncestor(a, b CommitID) (bool, error)
+}
+
+// An AheadBehindCounter is a repository that can count how far two
+// commits have diverged.
+type AheadBehindCounter interface {
+	// AheadBehind returns the number of commits reachable from head
+	// but not base (ahead) and reachable from base but not head
+	// (behind).
+	AheadBehind(base, head CommitID) (ahead, behind int, err error)
+}
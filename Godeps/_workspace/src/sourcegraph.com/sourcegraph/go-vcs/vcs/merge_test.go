@@ -162,3 +162,120 @@ func TestMerger_CrossRepoMergeBase(t *testing.T) {
 		}
 	}
 }
+
+func TestAncestorChecker_IsAncestor(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+		"git checkout -b b2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	repo := makeGitRepositoryCmd(t, cmds...)
+	tests := map[string]struct {
+		repo interface {
+			vcs.AncestorChecker
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		a, b string // can be any revspec; is resolved during the test
+		want bool
+	}{
+		"git cmd ancestor":     {repo: repo, a: "testbase", b: "b2", want: true},
+		"git cmd same commit":  {repo: repo, a: "b2", b: "b2", want: true},
+		"git cmd descendant":   {repo: repo, a: "b2", b: "testbase", want: false},
+		"git cmd diverged":     {repo: repo, a: "b2", b: "master", want: false},
+		"git cmd not ancestor": {repo: repo, a: "master", b: "b2", want: false},
+	}
+
+	for label, test := range tests {
+		a, err := test.repo.ResolveRevision(test.a)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q) on a: %s", label, test.a, err)
+			continue
+		}
+		b, err := test.repo.ResolveRevision(test.b)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q) on b: %s", label, test.b, err)
+			continue
+		}
+
+		isAncestor, err := test.repo.IsAncestor(a, b)
+		if err != nil {
+			t.Errorf("%s: IsAncestor(%s, %s): %s", label, a, b, err)
+			continue
+		}
+		if isAncestor != test.want {
+			t.Errorf("%s: IsAncestor(%s, %s): got %v, want %v", label, a, b, isAncestor, test.want)
+		}
+	}
+
+	// Test that a nonexistent commit returns ErrCommitNotFound.
+	b, err := repo.ResolveRevision("b2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.IsAncestor(nonexistentCommitID, b); err != vcs.ErrCommitNotFound {
+		t.Errorf("for nonexistent commit: got err %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
+
+func TestAheadBehindCounter_AheadBehind(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag testbase",
+		"git checkout -b b2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m baz --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	repo := makeGitRepositoryCmd(t, cmds...)
+	tests := map[string]struct {
+		repo interface {
+			vcs.AheadBehindCounter
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+		base, head            string // can be any revspec; is resolved during the test
+		wantAhead, wantBehind int
+	}{
+		"git cmd fast-forward": {repo: repo, base: "testbase", head: "b2", wantAhead: 2, wantBehind: 0},
+		"git cmd diverged":     {repo: repo, base: "master", head: "b2", wantAhead: 2, wantBehind: 1},
+		"git cmd same commit":  {repo: repo, base: "master", head: "master", wantAhead: 0, wantBehind: 0},
+	}
+
+	for label, test := range tests {
+		base, err := test.repo.ResolveRevision(test.base)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q) on base: %s", label, test.base, err)
+			continue
+		}
+		head, err := test.repo.ResolveRevision(test.head)
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(%q) on head: %s", label, test.head, err)
+			continue
+		}
+
+		ahead, behind, err := test.repo.AheadBehind(base, head)
+		if err != nil {
+			t.Errorf("%s: AheadBehind(%s, %s): %s", label, base, head, err)
+			continue
+		}
+		if ahead != test.wantAhead || behind != test.wantBehind {
+			t.Errorf("%s: AheadBehind(%s, %s): got ahead %d, behind %d, want ahead %d, behind %d", label, base, head, ahead, behind, test.wantAhead, test.wantBehind)
+		}
+	}
+
+	// Test that a nonexistent commit returns ErrCommitNotFound.
+	head, err := repo.ResolveRevision("b2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.AheadBehind(nonexistentCommitID, head); err != vcs.ErrCommitNotFound {
+		t.Errorf("for nonexistent commit: got err %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
@@ -0,0 +1,30 @@
+package vcs
+
+import "strings"
+
+// SplitMessage splits a commit message into its subject and body the
+// same way git does for the "%s" and "%b" log format placeholders.
+// The subject is the first paragraph of the message, with its lines
+// joined by a single space (so that a subject wrapped over several
+// lines is kept together). The body is the rest of the message after
+// the blank lines that follow the subject.
+func SplitMessage(message string) (subject, body string) {
+	lines := strings.Split(message, "\n")
+
+	// Skip leading blank lines.
+	i := 0
+	for i < len(lines) && isBlankLine(lines[i]) {
+		i++
+	}
+
+	var subjectLines []string
+	for ; i < len(lines) && !isBlankLine(lines[i]); i++ {
+		subjectLines = append(subjectLines, strings.TrimRight(lines[i], " \t\r"))
+	}
+	for i < len(lines) && isBlankLine(lines[i]) {
+		i++
+	}
+	return strings.Join(subjectLines, " "), strings.Join(lines[i:], "\n")
+}
+
+func isBlankLine(line string) bool { return strings.TrimSpace(line) == "" }
@@ -0,0 +1,29 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		message               string
+		wantSubject, wantBody string
+	}{
+		{"", "", ""},
+		{"subject", "subject", ""},
+		{"subject\n\nbody", "subject", "body"},
+		{"\n\nsubject  \nwrapped\n\n\nbody\n\nmore", "subject wrapped", "body\n\nmore"},
+		{"subject\n \nbody", "subject", "body"},
+	}
+	for _, test := range tests {
+		subject, body := vcs.SplitMessage(test.message)
+		if subject != test.wantSubject {
+			t.Errorf("%q: got subject %q, want %q", test.message, subject, test.wantSubject)
+		}
+		if body != test.wantBody {
+			t.Errorf("%q: got body %q, want %q", test.message, body, test.wantBody)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package vcs
+
+import "errors"
+
+// A NotesGetter is a repository that can read the notes attached to
+// commits (as with `git notes`).
+type NotesGetter interface {
+	// GetNotes returns the note attached to commit in the notes ref
+	// (such as "commits" for refs/notes/commits, which is used if ref
+	// is empty). If the commit has no note in ref (or ref doesn't
+	// exist), ErrNoteNotFound is returned. If ref is not a valid ref
+	// name (see ValidRefName), ErrInvalidRefName is returned.
+	GetNotes(commit CommitID, ref string) (string, error)
+}
+
+// ErrNoteNotFound is returned by (NotesGetter).GetNotes when the
+// commit has no note.
+var ErrNoteNotFound = errors.New("note not found")
@@ -0,0 +1,85 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestNotesGetter_GetNotes(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git notes add -m 'reviewed' HEAD",
+		"GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git notes --ref=ci add -m 'build: passed' -m 'duration: 3m' HEAD~1",
+	}
+	const (
+		foo = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+		bar = "5c47584dbdf4e75ce93b8050bd0003462572a66a"
+	)
+	tests := map[string]struct {
+		repo     vcs.NotesGetter
+		commitID vcs.CommitID
+		ref      string
+		want     string
+		wantErr  error
+	}{
+		"git cmd default ref": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			want:     "reviewed",
+		},
+		"git cmd custom ref": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: foo,
+			ref:      "ci",
+			want:     "build: passed\n\nduration: 3m",
+		},
+		"git cmd qualified ref": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: foo,
+			ref:      "refs/notes/ci",
+			want:     "build: passed\n\nduration: 3m",
+		},
+		"git cmd no note": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: foo,
+			wantErr:  vcs.ErrNoteNotFound,
+		},
+		"git cmd nonexistent ref": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			ref:      "doesntexist",
+			wantErr:  vcs.ErrNoteNotFound,
+		},
+		"git cmd nonexistent commit": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: nonexistentCommitID,
+			wantErr:  vcs.ErrNoteNotFound,
+		},
+		"git cmd unresolvable commit": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: "doesntexist",
+			wantErr:  vcs.ErrCommitNotFound,
+		},
+		"git cmd invalid ref": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: bar,
+			ref:      "--exec=x",
+			wantErr:  vcs.ErrInvalidRefName,
+		},
+	}
+
+	for label, test := range tests {
+		note, err := test.repo.GetNotes(test.commitID, test.ref)
+		if err != test.wantErr {
+			t.Errorf("%s: GetNotes(%s, %q): got error %v, want %v", label, test.commitID, test.ref, err, test.wantErr)
+			continue
+		}
+		if note != test.want {
+			t.Errorf("%s: got note %q, want %q", label, note, test.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package vcs
+
+import (
+	"errors"
+	"io"
+)
+
+// An ObjectGetter reads raw objects from a repository by ID.
+type ObjectGetter interface {
+	// GetObject returns the type ("blob", "tree", "commit", or "tag")
+	// and the contents of the object with the given full object ID
+	// (which must satisfy ValidObjectID). Blob contents are returned
+	// as-is; other objects are returned as their canonical text (like
+	// `git cat-file -p`). The caller must close the returned reader.
+	GetObject(id string) (typ string, contents io.ReadCloser, err error)
+}
+
+// ErrObjectNotFound is returned by (ObjectGetter).GetObject when the
+// object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrInvalidObjectID is returned by (ObjectGetter).GetObject when the
+// object ID does not satisfy ValidObjectID.
+var ErrInvalidObjectID = errors.New("invalid object ID (must be 40 or 64 lowercase hex characters)")
+
+// ValidObjectID returns whether id is a full SHA-1 or SHA-256 object
+// ID (40 or 64 lowercase hex characters).
+func ValidObjectID(id string) bool {
+	if len(id) != 40 && len(id) != 64 {
+		return false
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
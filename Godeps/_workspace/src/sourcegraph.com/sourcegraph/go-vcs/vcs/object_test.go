@@ -0,0 +1,88 @@
+package vcs_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestObjectGetter_GetObject(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo -n hello > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	const (
+		commit = "6cd5a450c033558cdce19893acbaa811b2399896"
+		tree   = "42bb57d1e79cc0af4c2af35480fd5ca001630afc"
+		blob   = "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0"
+	)
+	tests := map[string]struct {
+		repo     vcs.ObjectGetter
+		id       string
+		wantType string
+		want     string
+		wantErr  error
+	}{
+		"git cmd blob": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			id:       blob,
+			wantType: "blob",
+			want:     "hello",
+		},
+		"git cmd commit": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			id:       commit,
+			wantType: "commit",
+			want:     "tree " + tree + "\nauthor a <a@a.com> 1136214245 +0000\ncommitter a <a@a.com> 1136214245 +0000\n\nfoo\n",
+		},
+		"git cmd tree": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			id:       tree,
+			wantType: "tree",
+			want:     "100644 blob " + blob + "\tf\n",
+		},
+		"git cmd nonexistent": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			id:      strings.Repeat("a", 40),
+			wantErr: vcs.ErrObjectNotFound,
+		},
+		"git cmd abbreviated": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			id:      blob[:7],
+			wantErr: vcs.ErrInvalidObjectID,
+		},
+		"git cmd rev spec": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			id:      "HEAD",
+			wantErr: vcs.ErrInvalidObjectID,
+		},
+	}
+
+	for label, test := range tests {
+		typ, rc, err := test.repo.GetObject(test.id)
+		if err != test.wantErr {
+			t.Errorf("%s: GetObject: got error %v, want %v", label, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("%s: read object: %s", label, err)
+			continue
+		}
+		if typ != test.wantType {
+			t.Errorf("%s: got type %q, want %q", label, typ, test.wantType)
+		}
+		if string(data) != test.want {
+			t.Errorf("%s: got contents %q, want %q", label, data, test.want)
+		}
+	}
+}
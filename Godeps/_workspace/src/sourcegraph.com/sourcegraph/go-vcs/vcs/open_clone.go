@@ -1,6 +1,9 @@
 package vcs
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 // An Opener is a function that opens a repository rooted at dir in the
 // filesystem. An Opener should fail if there exists no repository rooted at
@@ -88,6 +91,25 @@ type CloneOpt struct {
 	Bare   bool // create a bare repo
 	Mirror bool // create a mirror repo (`git clone --mirror`)
 
+	// Depth, if nonzero, creates a shallow clone with history
+	// truncated to this many commits (`git clone --depth`). A shallow
+	// clone only fetches a single branch, so it can't also be a
+	// mirror. Subsequent calls to UpdateEverything on a shallow clone
+	// also fetch with --depth so that its history stays truncated.
+	Depth int
+
+	// Branch, if set, clones only the named branch (`git clone
+	// --single-branch --branch`), so it can't also be a
+	// mirror. Subsequent calls to UpdateEverything only fetch that
+	// branch.
+	Branch string
+
+	// Progress, if set, receives the progress messages that the VCS
+	// prints while cloning (such as git's "Receiving objects: 45%
+	// (450/1000)"), which are separated by "\r" or "\n". It may be
+	// ignored by VCSes that don't report progress.
+	Progress io.Writer
+
 	RemoteOpts // configures communication with the remote repository
 
 	// TODO(sqs): these options are fairly
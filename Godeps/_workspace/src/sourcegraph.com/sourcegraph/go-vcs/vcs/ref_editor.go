@@ -0,0 +1,59 @@
+package vcs
+
+import (
+	"errors"
+	"strings"
+)
+
+// A RefEditor creates and deletes branches and tags.
+type RefEditor interface {
+	// CreateBranch creates a branch pointing to the commit. If the
+	// branch already exists, ErrRefExists is returned.
+	CreateBranch(name string, commit CommitID) error
+
+	// DeleteBranch deletes the branch. If it doesn't exist,
+	// ErrBranchNotFound is returned.
+	DeleteBranch(name string) error
+
+	// CreateTag creates a lightweight tag pointing to the commit. If
+	// the tag already exists, ErrRefExists is returned.
+	CreateTag(name string, commit CommitID) error
+
+	// DeleteTag deletes the tag. If it doesn't exist, ErrTagNotFound
+	// is returned.
+	DeleteTag(name string) error
+}
+
+var (
+	// ErrRefExists is returned by (RefEditor).CreateBranch and
+	// CreateTag when the ref already exists.
+	ErrRefExists = errors.New("ref already exists")
+
+	// ErrInvalidRefName is returned by RefEditor methods when the
+	// branch or tag name does not satisfy ValidRefName.
+	ErrInvalidRefName = errors.New("invalid branch or tag name")
+)
+
+// ValidRefName returns whether name is a valid branch or tag name
+// (following the rules of `git check-ref-format --branch`). In
+// particular, names that begin with "-" (which git would interpret
+// as a flag) are invalid.
+func ValidRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//") {
+		return false
+	}
+	for _, c := range name {
+		if c < ' ' || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
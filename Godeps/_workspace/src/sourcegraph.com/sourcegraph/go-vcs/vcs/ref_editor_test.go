@@ -0,0 +1,77 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRefEditor(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	const commitID = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+	tests := map[string]struct {
+		repo interface {
+			vcs.RefEditor
+			ResolveBranch(string) (vcs.CommitID, error)
+			ResolveTag(string) (vcs.CommitID, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+
+	for label, test := range tests {
+		// Branches.
+		if err := test.repo.CreateBranch("feature/b", commitID); err != nil {
+			t.Errorf("%s: CreateBranch: %s", label, err)
+		}
+		if id, err := test.repo.ResolveBranch("feature/b"); err != nil || id != commitID {
+			t.Errorf("%s: ResolveBranch after CreateBranch: got %q, %v, want %q", label, id, err, commitID)
+		}
+		if err := test.repo.CreateBranch("feature/b", commitID); err != vcs.ErrRefExists {
+			t.Errorf("%s: CreateBranch of existing branch: got error %v, want %v", label, err, vcs.ErrRefExists)
+		}
+		if err := test.repo.CreateBranch("c", nonexistentCommitID); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: CreateBranch at nonexistent commit: got error %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+		if err := test.repo.DeleteBranch("feature/b"); err != nil {
+			t.Errorf("%s: DeleteBranch: %s", label, err)
+		}
+		if _, err := test.repo.ResolveBranch("feature/b"); err != vcs.ErrBranchNotFound {
+			t.Errorf("%s: ResolveBranch after DeleteBranch: got error %v, want %v", label, err, vcs.ErrBranchNotFound)
+		}
+		if err := test.repo.DeleteBranch("feature/b"); err != vcs.ErrBranchNotFound {
+			t.Errorf("%s: DeleteBranch of nonexistent branch: got error %v, want %v", label, err, vcs.ErrBranchNotFound)
+		}
+
+		// Tags.
+		if err := test.repo.CreateTag("v1.0", commitID); err != nil {
+			t.Errorf("%s: CreateTag: %s", label, err)
+		}
+		if id, err := test.repo.ResolveTag("v1.0"); err != nil || id != commitID {
+			t.Errorf("%s: ResolveTag after CreateTag: got %q, %v, want %q", label, id, err, commitID)
+		}
+		if err := test.repo.CreateTag("v1.0", commitID); err != vcs.ErrRefExists {
+			t.Errorf("%s: CreateTag of existing tag: got error %v, want %v", label, err, vcs.ErrRefExists)
+		}
+		if err := test.repo.DeleteTag("v1.0"); err != nil {
+			t.Errorf("%s: DeleteTag: %s", label, err)
+		}
+		if err := test.repo.DeleteTag("v1.0"); err != vcs.ErrTagNotFound {
+			t.Errorf("%s: DeleteTag of nonexistent tag: got error %v, want %v", label, err, vcs.ErrTagNotFound)
+		}
+
+		// Invalid names.
+		for _, name := range []string{"", "-d", "--force", "a..b", "a b", "a~1", "HEAD^", "a:b", "a/", "a.lock", ".a", "a/.b", "a@{1}", "@"} {
+			if err := test.repo.CreateBranch(name, commitID); err != vcs.ErrInvalidRefName {
+				t.Errorf("%s: CreateBranch(%q): got error %v, want %v", label, name, err, vcs.ErrInvalidRefName)
+			}
+			if err := test.repo.DeleteTag(name); err != vcs.ErrInvalidRefName {
+				t.Errorf("%s: DeleteTag(%q): got error %v, want %v", label, name, err, vcs.ErrInvalidRefName)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package vcs
+
+import (
+	"errors"
+	"time"
+)
+
+// A Reflogger is a repository that can read the reflog of a ref (the
+// record of the values the ref has had, as with `git reflog`).
+type Reflogger interface {
+	// Reflog returns the reflog entries of ref (such as "master",
+	// "refs/heads/master", or "HEAD"), newest first. If ref doesn't
+	// exist, ErrRevisionNotFound is returned. If ref is not a valid
+	// ref name (see ValidRefName), ErrInvalidRefName is returned.
+	//
+	// Repositories that don't record reflogs (such as bare mirror
+	// clones, which have core.logAllRefUpdates disabled) have no
+	// reflog for ref. In that case ErrNoReflog is returned, which
+	// callers should treat as an empty (rather than a failed) result.
+	Reflog(ref string) ([]*ReflogEntry, error)
+}
+
+// ErrNoReflog is returned by (Reflogger).Reflog when the repository
+// has no reflog for the ref.
+var ErrNoReflog = errors.New("no reflog for ref")
+
+// A RevisionAtResolver is a repository that can resolve the value a
+// ref had at a past time, using the ref's reflog.
+type RevisionAtResolver interface {
+	// ResolveRevisionAt returns the commit that ref (such as
+	// "master" or "HEAD") pointed to at time t (like `git rev-parse
+	// ref@{<t>}`). If ref doesn't exist, ErrRevisionNotFound is
+	// returned. If ref is not a valid ref name (see ValidRefName),
+	// ErrInvalidRefName is returned.
+	//
+	// If the repository has no reflog for ref, ErrNoReflog is
+	// returned. If the reflog doesn't go back as far as t, which
+	// happens when t is before the ref was created or its oldest
+	// reflog entries have expired, ErrReflogTooShort is returned
+	// (instead of the oldest value the reflog records).
+	ResolveRevisionAt(ref string, t time.Time) (CommitID, error)
+}
+
+// ErrReflogTooShort is returned by (RevisionAtResolver).ResolveRevisionAt
+// when the ref's reflog doesn't go back as far as the requested time.
+var ErrReflogTooShort = errors.New("reflog doesn't go back to the requested time")
+
+// A ReflogEntry records a single update to a ref.
+type ReflogEntry struct {
+	// Old and New are the ref's values before and after the update.
+	// Old is all zeros if the update created the ref.
+	Old, New CommitID
+
+	// Actor is the identity that made the update, and when.
+	Actor Signature
+
+	// Action is the kind of update (such as "commit", "commit
+	// (amend)", "reset", "push", or "branch"), from the part of the
+	// reflog message before the first ": ".
+	Action string
+
+	// Message is the rest of the reflog message (such as the
+	// subject of the commit, or "moving to HEAD~1" for a reset). If
+	// the reflog message has no ": ", Message is the whole reflog
+	// message and Action is empty.
+	Message string `json:",omitempty"`
+}
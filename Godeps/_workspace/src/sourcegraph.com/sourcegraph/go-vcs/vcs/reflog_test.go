@@ -0,0 +1,197 @@
+package vcs_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+)
+
+func TestReflogger_Reflog(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git reset --hard HEAD~1",
+		"git tag v1.0",
+	}
+	const (
+		zero = "0000000000000000000000000000000000000000"
+		foo  = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+		bar  = "5c47584dbdf4e75ce93b8050bd0003462572a66a"
+	)
+	wantEntries := []*vcs.ReflogEntry{
+		{
+			Old:     bar,
+			New:     foo,
+			Actor:   vcs.NewSignature("b", "b@b.com", time.Date(2006, 1, 2, 15, 4, 7, 0, time.UTC)),
+			Action:  "reset",
+			Message: "moving to HEAD~1",
+		},
+		{
+			Old:     foo,
+			New:     bar,
+			Actor:   vcs.NewSignature("a", "a@a.com", time.Date(2006, 1, 2, 15, 4, 6, 0, time.UTC)),
+			Action:  "commit",
+			Message: "bar",
+		},
+		{
+			Old:     zero,
+			New:     foo,
+			Actor:   vcs.NewSignature("a", "a@a.com", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)),
+			Action:  "commit (initial)",
+			Message: "foo",
+		},
+	}
+
+	mirrorDir := makeTmpDir(t, "git-mirror")
+	mirror, err := gitcmd.Clone("file://"+initGitRepository(t, gitCommands...), mirrorDir, vcs.CloneOpt{Bare: true, Mirror: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		repo    vcs.Reflogger
+		ref     string
+		want    []*vcs.ReflogEntry
+		wantErr error
+	}{
+		"git cmd branch": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			ref:  "master",
+			want: wantEntries,
+		},
+		"git cmd full ref name": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			ref:  "refs/heads/master",
+			want: wantEntries,
+		},
+		"git cmd HEAD": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			ref:  "HEAD",
+			want: wantEntries,
+		},
+		"git cmd tag without reflog": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			ref:     "v1.0",
+			wantErr: vcs.ErrNoReflog,
+		},
+		"git cmd nonexistent ref": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			ref:     "doesntexist",
+			wantErr: vcs.ErrRevisionNotFound,
+		},
+		"git cmd invalid ref": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			ref:     "--all",
+			wantErr: vcs.ErrInvalidRefName,
+		},
+		"git cmd mirror": {
+			repo:    mirror,
+			ref:     "master",
+			wantErr: vcs.ErrNoReflog,
+		},
+	}
+
+	for label, test := range tests {
+		entries, err := test.repo.Reflog(test.ref)
+		if err != test.wantErr {
+			t.Errorf("%s: Reflog(%q): got error %v, want %v", label, test.ref, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(entries, test.want) {
+			t.Errorf("%s: got entries %s, want %s", label, asJSON(entries), asJSON(test.want))
+		}
+	}
+}
+
+func TestRevisionAtResolver_ResolveRevisionAt(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"git symbolic-ref HEAD refs/heads/master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-03T15:04:05Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-03T15:04:05Z",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-04T15:04:05Z git reset --hard HEAD~1",
+		"git tag v1.0",
+	}
+	const foo = "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"
+	repo := makeGitRepositoryCmd(t, gitCommands...)
+	bar, err := repo.ResolveRevision("master@{1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		ref     string
+		t       time.Time
+		want    vcs.CommitID
+		wantErr error
+	}{
+		"after first commit": {
+			ref:  "master",
+			t:    time.Date(2006, 1, 2, 20, 0, 0, 0, time.UTC),
+			want: foo,
+		},
+		"after second commit": {
+			ref:  "master",
+			t:    time.Date(2006, 1, 3, 20, 0, 0, 0, time.UTC),
+			want: bar,
+		},
+		"after reset (in another time zone)": {
+			ref:  "master",
+			t:    time.Date(2006, 1, 4, 20, 0, 0, 0, time.FixedZone("PST", -8*60*60)),
+			want: foo,
+		},
+		"HEAD": {
+			ref:  "HEAD",
+			t:    time.Date(2006, 1, 3, 20, 0, 0, 0, time.UTC),
+			want: bar,
+		},
+		"before the reflog": {
+			ref:     "master",
+			t:       time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: vcs.ErrReflogTooShort,
+		},
+		"tag without reflog": {
+			ref:     "v1.0",
+			t:       time.Date(2006, 1, 3, 20, 0, 0, 0, time.UTC),
+			wantErr: vcs.ErrNoReflog,
+		},
+		"nonexistent ref": {
+			ref:     "doesntexist",
+			t:       time.Date(2006, 1, 3, 20, 0, 0, 0, time.UTC),
+			wantErr: vcs.ErrRevisionNotFound,
+		},
+		"invalid ref": {
+			ref:     "master@{1}",
+			t:       time.Date(2006, 1, 3, 20, 0, 0, 0, time.UTC),
+			wantErr: vcs.ErrInvalidRefName,
+		},
+	}
+	for label, test := range tests {
+		commitID, err := repo.ResolveRevisionAt(test.ref, test.t)
+		if err != test.wantErr {
+			t.Errorf("%s: ResolveRevisionAt(%q, %s): got error %v, want %v", label, test.ref, test.t, err, test.wantErr)
+			continue
+		}
+		if commitID != test.want {
+			t.Errorf("%s: ResolveRevisionAt(%q, %s): got %q, want %q", label, test.ref, test.t, commitID, test.want)
+		}
+	}
+
+	// Date specs can also be resolved directly.
+	for spec, want := range map[string]vcs.CommitID{
+		"master@{2006-01-03 20:00:00 +0000}": bar,
+		"HEAD@{2006-01-02 20:00:00 +0000}":   foo,
+		"master@{1.year.ago}":                foo,
+	} {
+		if commitID, err := repo.ResolveRevision(spec); err != nil || commitID != want {
+			t.Errorf("ResolveRevision(%q): got %q (error %v), want %q", spec, commitID, err, want)
+		}
+	}
+}
@@ -1,14 +1,43 @@
 package vcs
 
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
 // RemoteOpts configures interactions with a remote repository.
 type RemoteOpts struct {
-	SSH *SSHConfig // ssh configuration for communication with the remote
+	SSH   *SSHConfig   // ssh configuration for communication with the remote
+	HTTPS *HTTPSConfig // https configuration for communication with the remote
 }
 
 type SSHConfig struct {
 	User       string `json:",omitempty"` // ssh user (if empty, inferred from URL)
 	PublicKey  []byte `json:",omitempty"` // ssh public key (if nil, inferred from PrivateKey)
 	PrivateKey []byte // ssh private key, usually passed to ssh.ParsePrivateKey (passphrases currently unsupported)
+
+	// PrivateKeys are additional ssh private keys, which are offered
+	// (in order) after PrivateKey. Use them when one of several keys
+	// (such as per-repository deploy keys) may be authorized.
+	PrivateKeys [][]byte `json:",omitempty"`
+
+	// UseAgent also offers the keys in the local SSH agent (at
+	// SSH_AUTH_SOCK).
+	UseAgent bool `json:",omitempty"`
+
+	// KnownHosts, if non-nil, contains the host keys (in the format of
+	// an OpenSSH known_hosts file) that the remote's host key is
+	// verified against. If it is set, the host key is always verified,
+	// even if host key checking is otherwise disabled for testing.
+	KnownHosts []byte `json:",omitempty"`
+}
+
+// HTTPSConfig holds the credentials for an HTTP(S) remote that
+// requires authentication.
+type HTTPSConfig struct {
+	User string `json:",omitempty"` // username (if empty, inferred from URL)
+	Pass string // password or access token
 }
 
 // A RemoteUpdater is a repository that can fetch updates to itself
@@ -18,3 +47,84 @@ type RemoteUpdater interface {
 	// default remote repository. The implementation is VCS-dependent.
 	UpdateEverything(RemoteOpts) error
 }
+
+// A Remote is a named remote repository.
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// A RemoteManager manages a repository's named remotes (like `git
+// remote`) and fetches from them.
+type RemoteManager interface {
+	// AddRemote adds a remote named name with the URL. If a remote
+	// with the name already exists, ErrRemoteExists is returned.
+	AddRemote(name, url string) error
+
+	// RemoveRemote removes the remote named name (and its
+	// remote-tracking refs). If it doesn't exist, ErrRemoteNotFound
+	// is returned.
+	RemoveRemote(name string) error
+
+	// ListRemotes returns the repository's remotes, sorted by name.
+	ListRemotes() ([]*Remote, error)
+
+	// Fetch fetches from the remote named remote (into its
+	// remote-tracking refs, such as refs/remotes/upstream/master for
+	// a remote named "upstream"). If it doesn't exist,
+	// ErrRemoteNotFound is returned.
+	Fetch(remote string, opt RemoteOpts) error
+}
+
+var (
+	// ErrRemoteNotFound is returned by RemoteManager methods when the
+	// named remote doesn't exist.
+	ErrRemoteNotFound = errors.New("remote not found")
+
+	// ErrRemoteExists is returned by (RemoteManager).AddRemote when a
+	// remote with the name already exists.
+	ErrRemoteExists = errors.New("remote already exists")
+
+	// ErrInvalidRemoteName is returned by RemoteManager methods when
+	// the remote name does not satisfy ValidRemoteName.
+	ErrInvalidRemoteName = errors.New("invalid remote name")
+
+	// ErrInvalidRemoteURL is returned by (RemoteManager).AddRemote
+	// when the URL does not satisfy ValidRemoteURL.
+	ErrInvalidRemoteURL = errors.New("invalid remote URL (must be an http, https, git, or ssh URL, or an scp-like user@host:path)")
+)
+
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// ValidRemoteName returns whether name is a valid remote name: 1 to
+// 100 letters, digits, ".", "_", or "-", beginning with a letter or
+// digit (so that git can't interpret it as a flag) and usable as a
+// ref name component.
+func ValidRemoteName(name string) bool {
+	return remoteNamePattern.MatchString(name) && !strings.Contains(name, "..") && !strings.HasSuffix(name, ".") && !strings.HasSuffix(name, ".lock")
+}
+
+var (
+	// remoteURLPattern matches URLs with a network transport. The
+	// user and host may not begin with "-", so that ssh can't
+	// interpret them as flags.
+	remoteURLPattern = regexp.MustCompile(`^(?i:https?|git|ssh|git\+ssh|ssh\+git)://([A-Za-z0-9._~%!$&'()*+,;=][^/?#@\s]*@)?[A-Za-z0-9\[][A-Za-z0-9.:\[\]-]*(/\S*)?$`)
+
+	// scpLikeRemotePattern matches scp-like ssh addresses, such as
+	// "git@example.com:foo/bar.git".
+	scpLikeRemotePattern = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9._~-]*@)?[A-Za-z0-9][A-Za-z0-9.-]*:\S+$`)
+)
+
+// ValidRemoteURL returns whether url is a valid remote URL: an http,
+// https, git, or ssh URL, or an scp-like ssh address. Other
+// transports (such as local paths, file:// URLs, and remote helpers
+// like "ext::cmd"), which could read the server's files or run
+// commands on it, are invalid.
+func ValidRemoteURL(url string) bool {
+	if strings.Contains(url, "://") {
+		return remoteURLPattern.MatchString(url)
+	}
+	// Reject remote helper addresses (such as "ext::cmd"), which
+	// would otherwise look like scp-like addresses.
+	return !strings.Contains(url, "::") && scpLikeRemotePattern.MatchString(url)
+}
@@ -2,6 +2,9 @@ package vcs
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"golang.org/x/tools/godoc/vfs"
 )
@@ -44,7 +47,8 @@ type Repository interface {
 	// as this can be expensive for large branches.
 	Commits(CommitsOptions) (commits []*Commit, total uint, err error)
 
-	// Committers returns the per-author commit statistics of the repo.
+	// Committers returns the per-author commit statistics of the
+	// repo. Authors are mapped using the repository's .mailmap.
 	Committers(CommittersOptions) ([]*Committer, error)
 
 	// FileSystem opens the repository file tree at a given commit ID.
@@ -57,6 +61,17 @@ type Repository interface {
 	FileSystem(at CommitID) (vfs.FileSystem, error)
 }
 
+// A CommitStreamer is a repository that can list commits
+// incrementally, without holding them all in memory at once.
+type CommitStreamer interface {
+	// StreamCommits calls fn for each commit matching the options,
+	// in the same order as (Repository).Commits would return them. If
+	// fn returns an error, StreamCommits stops and returns that
+	// error. The returned total is the same as Commits' total and is
+	// only known after all commits have been passed to fn.
+	StreamCommits(opt CommitsOptions, fn func(*Commit) error) (total uint, err error)
+}
+
 // A Blamer is a repository that can blame portions of a file.
 type Blamer interface {
 	BlameFile(path string, opt *BlameOptions) ([]*Hunk, error)
@@ -69,6 +84,22 @@ type BlameOptions struct {
 
 	StartLine int `json:",omitempty" url:",omitempty"` // 1-indexed start byte (or 0 for beginning of file)
 	EndLine   int `json:",omitempty" url:",omitempty"` // 1-indexed end byte (or 0 for end of file)
+
+	// UseMailmap maps hunk authors to their canonical names and
+	// emails using the repository's .mailmap. (The git command
+	// implementation always does this, like `git blame`.)
+	UseMailmap bool `json:",omitempty" url:",omitempty"`
+
+	// IgnoreRevs are commits (such as bulk reformatting commits)
+	// whose changes are attributed to the commits that previously
+	// changed the same lines (like `git blame --ignore-rev`).
+	IgnoreRevs []CommitID `json:",omitempty" url:",omitempty"`
+
+	// IgnoreRevsFile is the path of a file in the repository (at
+	// NewestCommit) that lists commits to ignore, one per line (like
+	// `git blame --ignore-revs-file`). It is usually
+	// ".git-blame-ignore-revs".
+	IgnoreRevsFile string `json:",omitempty" url:",omitempty"`
 }
 
 // A Hunk is a contiguous portion of a file associated with a commit.
@@ -98,6 +129,24 @@ type CrossRepoDiffer interface {
 	CrossRepoDiff(base CommitID, headRepo Repository, head CommitID, opt *DiffOptions) (*Diff, error)
 }
 
+// A TreeDiffer is a repository that can compute diffs between two
+// trees that may be at different paths (e.g., to compare a vendored
+// copy of a directory with its upstream).
+type TreeDiffer interface {
+	// DiffTrees shows changes between two trees, each specified as
+	// "<rev>:<path>" (or "<rev>" for the commit's root tree). The
+	// file names in the diff are relative to the trees. If a
+	// revision doesn't exist, ErrCommitNotFound is returned; if a
+	// path doesn't exist, an os.ErrNotExist-satisfying error is
+	// returned. The ExcludeReachableFromBoth option isn't supported.
+	DiffTrees(base, head string, opt *DiffOptions) (*Diff, error)
+}
+
+// ErrInvalidTreeSpec is returned by (TreeDiffer).DiffTrees when a tree
+// spec is malformed or could be interpreted as something other than a
+// tree (such as a command-line option or a revision range).
+var ErrInvalidTreeSpec = errors.New("invalid tree spec (must be <rev> or <rev>:<clean relative path>)")
+
 var (
 	ErrRefNotFound      = errors.New("ref not found")
 	ErrBranchNotFound   = errors.New("branch not found")
@@ -130,7 +179,134 @@ type CommitsOptions struct {
 
 	Path string // only commits modifying the given path are selected (optional)
 
+	// Follow continues listing the history of Path beyond renames
+	// (like `git log --follow`). Path must be a single file path (see
+	// CheckFollow).
+	Follow bool `url:",omitempty"`
+
+	// Author, if set, selects only commits whose author name or
+	// email matches this regular expression (like `git log
+	// --author`).
+	Author string `url:",omitempty"`
+
+	// Since and Until, if set, select only commits committed at or
+	// after Since and at or before Until (like `git log --since` and
+	// `--until`).
+	Since time.Time `url:",omitempty"`
+	Until time.Time `url:",omitempty"`
+
 	NoTotal bool // avoid counting the total number of commits
+
+	// Cursor continues a previous listing (see NextCommitsCursor)
+	// instead of starting at Head. The total is still computed from
+	// Head.
+	Cursor string `url:",omitempty"`
+
+	// UseMailmap maps commit authors and committers to their
+	// canonical names and emails using the repository's .mailmap
+	// (like `git log --use-mailmap`).
+	UseMailmap bool `url:",omitempty"`
+
+	// ReencodeMessage transcodes each commit message from the
+	// encoding named in the commit's encoding header (such as
+	// ISO-8859-1) to UTF-8. Messages in unknown encodings are
+	// returned as their raw bytes.
+	ReencodeMessage bool `url:",omitempty"`
+
+	// IncludeSignatureStatus sets each commit's Signed field (from
+	// git's "%G?" log format, which is much cheaper than running `git
+	// verify-commit` for each commit but still checks each signature
+	// with gpg, so it is off by default). SSH signatures are only
+	// recognized if git is configured with gpg.ssh.allowedSignersFile.
+	IncludeSignatureStatus bool `url:",omitempty"`
+
+	// IncludeGenerations sets each CommitNode's Generation field in
+	// (CommitGrapher).CommitGraph, for commits in the repository's
+	// commit-graph file (see CommitGraphWriter). Commits that aren't
+	// in it (or all commits, if there is no commit-graph file) have
+	// no generation number; it isn't computed without one. Commits
+	// ignores it.
+	IncludeGenerations bool `url:",omitempty"`
+}
+
+// ErrInvalidFollow is returned by (Repository).Commits when
+// CommitsOptions.Follow is set but Path is not a single file path.
+var ErrInvalidFollow = errors.New("Follow requires Path to be a single file path (not empty or a glob)")
+
+// CheckFollow returns ErrInvalidFollow if opt.Follow is set and
+// opt.Path is empty, a glob, or a magic pathspec (because `git log
+// --follow` only works with a single file path).
+func CheckFollow(opt CommitsOptions) error {
+	if opt.Follow && (opt.Path == "" || strings.ContainsAny(opt.Path, "*?[") || strings.HasPrefix(opt.Path, ":")) {
+		return ErrInvalidFollow
+	}
+	return nil
+}
+
+// NextCommitsCursor returns the cursor (for CommitsOptions.Cursor)
+// that continues a commit listing after commits, which must be the
+// commits returned by (Repository).Commits for opt. It returns "" if
+// there are no more commits, or if opt doesn't limit the listing to a
+// page of N commits (or uses Skip or Follow, since the followed path
+// may have been renamed before the cursor's commits).
+//
+// The cursor lists the commits that the listing would visit next
+// (the parents of listed commits that haven't been listed yet), so
+// continuing from it doesn't re-walk the listed commits. Because it
+// only refers to commit IDs, later pages are stable even if the
+// listing's Head is a branch that has since moved; commits added to
+// the branch after the first page are not included.
+//
+// If opt has a Path, the listing omits the commits that didn't change
+// it, whose parents (and other listed commits' ancestors) aren't
+// known. So the cursor lists only the parents of the last listed
+// commit, which (following git's simplified history of the path)
+// continues the listing after it.
+func NextCommitsCursor(opt CommitsOptions, commits []*Commit) string {
+	if opt.N == 0 || opt.Skip != 0 || opt.Follow || uint(len(commits)) < opt.N {
+		return ""
+	}
+
+	var next []CommitID
+	if opt.Path != "" {
+		next = commits[len(commits)-1].Parents
+	} else {
+		// The listing's Head is always the first commit visited, so
+		// only a previous cursor's commits may still be unvisited.
+		if opt.Cursor != "" {
+			next, _ = ParseCommitsCursor(opt.Cursor)
+		}
+		for _, c := range commits {
+			next = append(next, c.Parents...)
+		}
+	}
+
+	listed := make(map[CommitID]struct{}, len(commits))
+	for _, c := range commits {
+		listed[c.ID] = struct{}{}
+	}
+	ids := make([]string, 0, len(next))
+	for _, id := range next {
+		if _, seen := listed[id]; !seen {
+			listed[id] = struct{}{}
+			ids = append(ids, string(id))
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// ParseCommitsCursor parses a cursor created by NextCommitsCursor and
+// returns the commits that the listing continues from.
+func ParseCommitsCursor(cursor string) ([]CommitID, error) {
+	parts := strings.Split(cursor, ",")
+	ids := make([]CommitID, len(parts))
+	for i, part := range parts {
+		if len(part) != 40 || strings.Trim(part, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("invalid commits cursor %q", cursor)
+		}
+		ids[i] = CommitID(part)
+	}
+	return ids, nil
 }
 
 // CommittersOptions specifies limits on the list of committers returned by
@@ -147,12 +323,47 @@ type DiffOptions struct {
 	DetectRenames         bool
 	OrigPrefix, NewPrefix string // prefixes for orig and new filenames (e.g., "a/", "b/")
 
-	ExcludeReachableFromBoth bool // like "<rev1>...<rev2>" (see `git rev-parse --help`)
+	// RenameThreshold is the minimum similarity (a percentage from 0
+	// to 100) for a deleted and added file pair to be reported as a
+	// rename or copy (like `git diff -M<n>%`). If nonzero, it implies
+	// DetectRenames. If 0, git's default (50%) is used.
+	RenameThreshold int `json:",omitempty" url:",omitempty"`
+
+	// DetectCopies reports files that were copied from another file
+	// changed in the same diff (like `git diff -C`). It implies
+	// DetectRenames.
+	DetectCopies bool `json:",omitempty" url:",omitempty"`
+
+	IgnoreWhitespace       bool `json:",omitempty" url:",omitempty"` // ignore all whitespace (like `git diff -w`)
+	IgnoreWhitespaceChange bool `json:",omitempty" url:",omitempty"` // ignore changes in amount of whitespace (like `git diff -b`)
+
+	// ExcludeReachableFromBoth makes a "three-dot" diff (like `git
+	// diff <base>...<head>`) of the changes on head since it diverged
+	// from base, i.e., between the merge base of base and head, and
+	// head. This is usually what a pull request shows, because it
+	// excludes the changes made on base after the fork point.
+	ExcludeReachableFromBoth bool
 }
 
 // A Diff represents changes between two commits.
 type Diff struct {
 	Raw string // the raw diff output
+
+	// Renames lists the files detected as renamed or copied (if
+	// rename or copy detection was requested in the DiffOptions).
+	Renames []*DiffRename `json:",omitempty"`
+}
+
+// A DiffRename describes a file that was renamed or copied.
+type DiffRename struct {
+	OrigPath, Path string
+
+	// Similarity is the percentage of the file's content that is
+	// unchanged.
+	Similarity int
+
+	// Copy is whether the file was copied (instead of renamed).
+	Copy bool `json:",omitempty"`
 }
 
 type Branches []*Branch
@@ -1,10 +1,15 @@
 package vcs_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
 	"reflect"
 	"strings"
 	"testing"
@@ -12,10 +17,13 @@ import (
 
 	"golang.org/x/tools/godoc/vfs"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/bzrcmd"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/git"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gogit"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/hg"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs/hgcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/svncmd"
 	"sourcegraph.com/sqs/pbtypes"
 )
 
@@ -52,6 +60,11 @@ func TestRepository_ResolveBranch(t *testing.T) {
 			branch:       "master",
 			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
 		},
+		"git go-git": {
+			repo:         makeGitRepositoryGoGit(t, gitCommands...),
+			branch:       "master",
+			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+		},
 		"git cmd": {
 			repo:         makeGitRepositoryCmd(t, gitCommands...),
 			branch:       "master",
@@ -105,6 +118,11 @@ func TestRepository_ResolveBranch_error(t *testing.T) {
 			branch:  "doesntexist",
 			wantErr: vcs.ErrBranchNotFound,
 		},
+		"git go-git": {
+			repo:    makeGitRepositoryGoGit(t, gitCommands...),
+			branch:  "doesntexist",
+			wantErr: vcs.ErrBranchNotFound,
+		},
 		"git cmd": {
 			repo:    makeGitRepositoryCmd(t, gitCommands...),
 			branch:  "doesntexist",
@@ -158,6 +176,11 @@ func TestRepository_ResolveRevision(t *testing.T) {
 			spec:         "master",
 			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
 		},
+		"git go-git": {
+			repo:         makeGitRepositoryGoGit(t, gitCommands...),
+			spec:         "master",
+			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+		},
 		"git cmd": {
 			repo:         makeGitRepositoryCmd(t, gitCommands...),
 			spec:         "master",
@@ -211,6 +234,11 @@ func TestRepository_ResolveRevision_error(t *testing.T) {
 			spec:    "doesntexist",
 			wantErr: vcs.ErrRevisionNotFound,
 		},
+		"git go-git testcase1": {
+			repo:    makeGitRepositoryGoGit(t, gitCommands...),
+			spec:    "doesntexist",
+			wantErr: vcs.ErrRevisionNotFound,
+		},
 		"git cmd testcase1": {
 			repo:    makeGitRepositoryCmd(t, gitCommands...),
 			spec:    "doesntexist",
@@ -234,6 +262,11 @@ func TestRepository_ResolveRevision_error(t *testing.T) {
 			spec:    "2874b2ef9be165966e5620fc29b592c041262721",
 			wantErr: vcs.ErrRevisionNotFound,
 		},
+		"git go-git testcase2": {
+			repo:    makeGitRepositoryGoGit(t, gitCommands...),
+			spec:    "2874b2ef9be165966e5620fc29b592c041262721",
+			wantErr: vcs.ErrRevisionNotFound,
+		},
 		"git cmd testcase2": {
 			repo:    makeGitRepositoryCmd(t, gitCommands...),
 			spec:    "2874b2ef9be165966e5620fc29b592c041262721",
@@ -289,6 +322,11 @@ func TestRepository_ResolveTag(t *testing.T) {
 			tag:          "t",
 			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
 		},
+		"git go-git": {
+			repo:         makeGitRepositoryGoGit(t, gitCommands...),
+			tag:          "t",
+			wantCommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+		},
 		"git cmd": {
 			repo:         makeGitRepositoryCmd(t, gitCommands...),
 			tag:          "t",
@@ -342,6 +380,11 @@ func TestRepository_ResolveTag_error(t *testing.T) {
 			tag:     "doesntexist",
 			wantErr: vcs.ErrTagNotFound,
 		},
+		"git go-git": {
+			repo:    makeGitRepositoryGoGit(t, gitCommands...),
+			tag:     "doesntexist",
+			wantErr: vcs.ErrTagNotFound,
+		},
 		"git cmd": {
 			repo:    makeGitRepositoryCmd(t, gitCommands...),
 			tag:     "doesntexist",
@@ -470,6 +513,40 @@ func TestRepository_Branches_MergedInto(t *testing.T) {
 	}
 }
 
+func TestRepository_Branches_upstream(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git branch b0",
+		"git config branch.b0.remote .",
+		"git config branch.b0.merge refs/heads/master",
+	}
+	tests := map[string]struct {
+		repo interface {
+			Branches(vcs.BranchesOptions) ([]*vcs.Branch, error)
+		}
+		wantBranches []*vcs.Branch
+	}{
+		"git cmd": {
+			repo:         makeGitRepositoryCmd(t, gitCommands...),
+			wantBranches: []*vcs.Branch{{Name: "b0", Head: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8", Upstream: "master"}, {Name: "master", Head: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"}},
+		},
+	}
+
+	for label, test := range tests {
+		branches, err := test.repo.Branches(vcs.BranchesOptions{})
+		if err != nil {
+			t.Errorf("%s: Branches: %s", label, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(branches, test.wantBranches) {
+			t.Errorf("%s: got branches == %v, want %v", label, asJSON(branches), asJSON(test.wantBranches))
+		}
+	}
+}
+
 func TestRepository_Branches_ContainsCommit(t *testing.T) {
 	t.Parallel()
 
@@ -478,6 +555,8 @@ func TestRepository_Branches_ContainsCommit(t *testing.T) {
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m master --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 		"git checkout HEAD^ -b branch2",
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m branch2 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -q --detach",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m detached --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
 	}
 
 	tests := map[string]struct {
@@ -492,13 +571,14 @@ func TestRepository_Branches_ContainsCommit(t *testing.T) {
 				"920c0e9d7b287b030ac9770fd7ba3ee9dc1760d9": []*vcs.Branch{{Name: "branch2", Head: "920c0e9d7b287b030ac9770fd7ba3ee9dc1760d9"}},
 				"1224d334dfe08f4693968ea618ad63ae86ec16ca": []*vcs.Branch{{Name: "master", Head: "1224d334dfe08f4693968ea618ad63ae86ec16ca"}},
 				"2816a72df28f699722156e545d038a5203b959de": []*vcs.Branch{{Name: "master", Head: "1224d334dfe08f4693968ea618ad63ae86ec16ca"}, {Name: "branch2", Head: "920c0e9d7b287b030ac9770fd7ba3ee9dc1760d9"}},
+				"f67595bf31239821d0a6c6787806b9277c2ca012": nil,
 			},
 		},
 	}
 
 	for label, test := range tests {
 		for commit, wantBranches := range test.commitToWantBranches {
-			branches, err := test.repo.Branches(vcs.BranchesOptions{ContainsCommit: commit})
+			branches, err := test.repo.Branches(vcs.BranchesOptions{ContainsCommit: vcs.CommitID(commit)})
 			if err != nil {
 				t.Errorf("%s: Branches: %s", label, err)
 				continue
@@ -508,6 +588,12 @@ func TestRepository_Branches_ContainsCommit(t *testing.T) {
 				t.Errorf("%s: got branches == %v, want %v", label, asJSON(branches), asJSON(wantBranches))
 			}
 		}
+
+		// Test that a nonexistent commit returns ErrCommitNotFound (not
+		// an empty list of branches).
+		if _, err := test.repo.Branches(vcs.BranchesOptions{ContainsCommit: nonexistentCommitID}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: for nonexistent commit: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
 	}
 }
 
@@ -579,9 +665,10 @@ func TestRepository_Branches_IncludeCommit(t *testing.T) {
 					Name: "master", Head: "a3c1537db9797215208eec56f8e7c9c37f8358ca",
 					Commit: &vcs.Commit{
 						ID:        "a3c1537db9797215208eec56f8e7c9c37f8358ca",
-						Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
-						Committer: &vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+						Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+						Committer: &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
 						Message:   "foo0",
+						Subject:   "foo0",
 						Parents:   nil,
 					},
 				},
@@ -589,9 +676,10 @@ func TestRepository_Branches_IncludeCommit(t *testing.T) {
 					Name: "b0", Head: "c4a53701494d1d788b1ceeb8bf32e90224962473",
 					Commit: &vcs.Commit{
 						ID:        "c4a53701494d1d788b1ceeb8bf32e90224962473",
-						Author:    vcs.Signature{"b", "b@b.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
-						Committer: &vcs.Signature{"b", "b@b.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+						Author:    vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+						Committer: &vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
 						Message:   "foo1",
+						Subject:   "foo1",
 						Parents:   []vcs.CommitID{"a3c1537db9797215208eec56f8e7c9c37f8358ca"},
 					},
 				},
@@ -664,6 +752,48 @@ func TestRepository_Tags(t *testing.T) {
 	}
 }
 
+func TestRepository_Tags_annotated(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=b GIT_COMMITTER_EMAIL=b@b.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git tag -a t0 -m 'release t0'",
+		"git tag t1",
+	}
+	tests := map[string]struct {
+		repo interface {
+			Tags() ([]*vcs.Tag, error)
+		}
+		wantTags []*vcs.Tag
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			wantTags: []*vcs.Tag{
+				{
+					Name:      "t0",
+					CommitID:  "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+					Annotated: true,
+					Tagger:    &vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+					Message:   "release t0",
+				},
+				{Name: "t1", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
+			},
+		},
+	}
+
+	for label, test := range tests {
+		tags, err := test.repo.Tags()
+		if err != nil {
+			t.Errorf("%s: Tags: %s", label, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(tags, test.wantTags) {
+			t.Errorf("%s: got tags == %v, want %v", label, asJSON(tags), asJSON(test.wantTags))
+		}
+	}
+}
+
 func TestRepository_GetCommit(t *testing.T) {
 	t.Parallel()
 
@@ -673,9 +803,10 @@ func TestRepository_GetCommit(t *testing.T) {
 	}
 	wantGitCommit := &vcs.Commit{
 		ID:        "b266c7e3ca00b1a17ad0b1449825d0854225c007",
-		Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
-		Committer: &vcs.Signature{"c", "c@c.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
+		Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+		Committer: &vcs.Signature{Name: "c", Email: "c@c.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
 		Message:   "bar",
+		Subject:   "bar",
 		Parents:   []vcs.CommitID{"ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
 	}
 	hgCommands := []string{
@@ -688,8 +819,9 @@ func TestRepository_GetCommit(t *testing.T) {
 	}
 	wantHgCommit := &vcs.Commit{
 		ID:      "c6320cdba5ebc6933bd7c94751dcd633d6aa0759",
-		Author:  vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
+		Author:  vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
 		Message: "bar",
+		Subject: "bar",
 		Parents: []vcs.CommitID{"e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf"},
 	}
 	tests := map[string]struct {
@@ -704,6 +836,11 @@ func TestRepository_GetCommit(t *testing.T) {
 			id:         "b266c7e3ca00b1a17ad0b1449825d0854225c007",
 			wantCommit: wantGitCommit,
 		},
+		"git go-git": {
+			repo:       makeGitRepositoryGoGit(t, gitCommands...),
+			id:         "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+			wantCommit: wantGitCommit,
+		},
 		"git cmd": {
 			repo:       makeGitRepositoryCmd(t, gitCommands...),
 			id:         "b266c7e3ca00b1a17ad0b1449825d0854225c007",
@@ -739,10 +876,48 @@ func TestRepository_GetCommit(t *testing.T) {
 	}
 }
 
-func TestRepository_Commits(t *testing.T) {
+func TestRepository_GetCommit_timeZone(t *testing.T) {
 	t.Parallel()
 
-	// TODO(sqs): test CommitsOptions.Base
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:07-0700 git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:06+0900",
+	}
+	tests := map[string]struct {
+		repo interface {
+			GetCommit(vcs.CommitID) (*vcs.Commit, error)
+			ResolveRevision(spec string) (vcs.CommitID, error)
+		}
+	}{
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, gitCommands...)},
+		"git go-git":  {repo: makeGitRepositoryGoGit(t, gitCommands...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+
+	for label, test := range tests {
+		id, err := test.repo.ResolveRevision("HEAD")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		commit, err := test.repo.GetCommit(id)
+		if err != nil {
+			t.Errorf("%s: GetCommit: %s", label, err)
+			continue
+		}
+
+		if got, want := commit.Author.Time().Format(time.RFC3339), "2006-01-02T15:04:06+09:00"; got != want {
+			t.Errorf("%s: got author time %s, want %s", label, got, want)
+		}
+		if commit.Committer == nil {
+			t.Errorf("%s: got no committer", label)
+		} else if got, want := commit.Committer.Time().Format(time.RFC3339), "2006-01-02T15:04:07-07:00"; got != want {
+			t.Errorf("%s: got committer time %s, want %s", label, got, want)
+		}
+	}
+}
+
+func TestRepository_Commits(t *testing.T) {
+	t.Parallel()
 
 	gitCommands := []string{
 		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
@@ -751,16 +926,18 @@ func TestRepository_Commits(t *testing.T) {
 	wantGitCommits := []*vcs.Commit{
 		{
 			ID:        "b266c7e3ca00b1a17ad0b1449825d0854225c007",
-			Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
-			Committer: &vcs.Signature{"c", "c@c.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
+			Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+			Committer: &vcs.Signature{Name: "c", Email: "c@c.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
 			Message:   "bar",
+			Subject:   "bar",
 			Parents:   []vcs.CommitID{"ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
 		},
 		{
 			ID:        "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
-			Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
-			Committer: &vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Committer: &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
 			Message:   "foo",
+			Subject:   "foo",
 			Parents:   nil,
 		},
 	}
@@ -775,14 +952,16 @@ func TestRepository_Commits(t *testing.T) {
 	wantHgCommits := []*vcs.Commit{
 		{
 			ID:      "c6320cdba5ebc6933bd7c94751dcd633d6aa0759",
-			Author:  vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
+			Author:  vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
 			Message: "bar",
+			Subject: "bar",
 			Parents: []vcs.CommitID{"e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf"},
 		},
 		{
 			ID:      "e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf",
-			Author:  vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
+			Author:  vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:29Z")},
 			Message: "foo",
+			Subject: "foo",
 			Parents: nil,
 		},
 	}
@@ -800,6 +979,12 @@ func TestRepository_Commits(t *testing.T) {
 			wantCommits: wantGitCommits,
 			wantTotal:   2,
 		},
+		"git go-git": {
+			repo:        makeGitRepositoryGoGit(t, gitCommands...),
+			id:          "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+			wantCommits: wantGitCommits,
+			wantTotal:   2,
+		},
 		"git cmd": {
 			repo:        makeGitRepositoryCmd(t, gitCommands...),
 			id:          "b266c7e3ca00b1a17ad0b1449825d0854225c007",
@@ -866,18 +1051,20 @@ func TestRepository_Commits_options(t *testing.T) {
 	wantGitCommits := []*vcs.Commit{
 		{
 			ID:        "b266c7e3ca00b1a17ad0b1449825d0854225c007",
-			Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
-			Committer: &vcs.Signature{"c", "c@c.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
+			Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:06Z")},
+			Committer: &vcs.Signature{Name: "c", Email: "c@c.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:07Z")},
 			Message:   "bar",
+			Subject:   "bar",
 			Parents:   []vcs.CommitID{"ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
 		},
 	}
 	wantGitCommits2 := []*vcs.Commit{
 		{
 			ID:        "ade564eba4cf904492fb56dcd287ac633e6e082c",
-			Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:08Z")},
-			Committer: &vcs.Signature{"c", "c@c.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:08Z")},
+			Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:08Z")},
+			Committer: &vcs.Signature{Name: "c", Email: "c@c.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:08Z")},
 			Message:   "qux",
+			Subject:   "qux",
 			Parents:   []vcs.CommitID{"b266c7e3ca00b1a17ad0b1449825d0854225c007"},
 		},
 	}
@@ -895,8 +1082,9 @@ func TestRepository_Commits_options(t *testing.T) {
 	wantHgCommits := []*vcs.Commit{
 		{
 			ID:      "c6320cdba5ebc6933bd7c94751dcd633d6aa0759",
-			Author:  vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
+			Author:  vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-12-06T13:18:30Z")},
 			Message: "bar",
+			Subject: "bar",
 			Parents: []vcs.CommitID{"e8e11ff1be92a7be71b9b5cdb4cc674b7dc9facf"},
 		},
 	}
@@ -914,6 +1102,12 @@ func TestRepository_Commits_options(t *testing.T) {
 			wantCommits: wantGitCommits,
 			wantTotal:   3,
 		},
+		"git go-git": {
+			repo:        makeGitRepositoryGoGit(t, gitCommands...),
+			opt:         vcs.CommitsOptions{Head: "ade564eba4cf904492fb56dcd287ac633e6e082c", N: 1, Skip: 1},
+			wantCommits: wantGitCommits,
+			wantTotal:   3,
+		},
 		"git cmd": {
 			repo:        makeGitRepositoryCmd(t, gitCommands...),
 			opt:         vcs.CommitsOptions{Head: "ade564eba4cf904492fb56dcd287ac633e6e082c", N: 1, Skip: 1},
@@ -929,6 +1123,15 @@ func TestRepository_Commits_options(t *testing.T) {
 			wantCommits: wantGitCommits2,
 			wantTotal:   1,
 		},
+		"git go-git Head": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+			opt: vcs.CommitsOptions{
+				Head: "ade564eba4cf904492fb56dcd287ac633e6e082c",
+				Base: "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+			},
+			wantCommits: wantGitCommits2,
+			wantTotal:   1,
+		},
 		"git cmd Head": {
 			repo: makeGitRepositoryCmd(t, gitCommands...),
 			opt: vcs.CommitsOptions{
@@ -982,157 +1185,796 @@ func TestRepository_Commits_options(t *testing.T) {
 	}
 }
 
-func TestRepository_Commits_options_path(t *testing.T) {
+func TestRepository_Commits_base(t *testing.T) {
 	t.Parallel()
 
+	// a: foo -> bar; b: foo -> qux. The range b..a should include
+	// only bar (not qux, as b...a would).
 	gitCommands := []string{
-		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
-		"touch file1",
-		"touch --date=2006-01-02T15:04:05Z file1 || touch -t " + times[0] + " file1",
-		"git add file1",
-		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit2 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
-		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m commit3 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
-	}
-	wantGitCommits := []*vcs.Commit{
-		{
-			ID:        "546a3ef26e581624ef997cb8c0ba01ee475fc1dc",
-			Author:    vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
-			Committer: &vcs.Signature{"a", "a@a.com", mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
-			Message:   "commit2",
-			Parents:   []vcs.CommitID{"a04652fa1998a0a7d2f2f77ecb7021de943d3aab"},
-		},
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git branch b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git branch a",
+		"git checkout -q b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m qux --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
 	}
 	tests := map[string]struct {
 		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
 			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
 		}
-		opt         vcs.CommitsOptions
-		wantCommits []*vcs.Commit
-		wantTotal   uint
 	}{
-		"git cmd Path 0": {
-			repo: makeGitRepositoryCmd(t, gitCommands...),
-			opt: vcs.CommitsOptions{
-				Head: "master",
-				Path: "doesnt-exist",
-			},
-			wantCommits: nil,
-			wantTotal:   0,
-		},
-		"git cmd Path 1": {
-			repo: makeGitRepositoryCmd(t, gitCommands...),
-			opt: vcs.CommitsOptions{
-				Head: "master",
-				Path: "file1",
-			},
-			wantCommits: wantGitCommits,
-			wantTotal:   1,
-		},
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, gitCommands...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, gitCommands...)},
 	}
 
 	for label, test := range tests {
-		commits, total, err := test.repo.Commits(test.opt)
+		head, err := test.repo.ResolveRevision("a")
 		if err != nil {
-			t.Errorf("%s: Commits(): %s", label, err)
+			t.Errorf("%s: ResolveRevision(a): %s", label, err)
 			continue
 		}
-
-		if total != test.wantTotal {
-			t.Errorf("%s: got %d total commits, want %d", label, total, test.wantTotal)
+		base, err := test.repo.ResolveRevision("b")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision(b): %s", label, err)
+			continue
 		}
 
-		if len(commits) != len(test.wantCommits) {
-			t.Errorf("%s: got %d commits, want %d", label, len(commits), len(test.wantCommits))
+		commits, total, err := test.repo.Commits(vcs.CommitsOptions{Head: head, Base: base})
+		if err != nil {
+			t.Errorf("%s: Commits: %s", label, err)
+			continue
+		}
+		if total != 1 {
+			t.Errorf("%s: got %d total commits, want 1", label, total)
+		}
+		if len(commits) != 1 || commits[0].ID != head {
+			t.Errorf("%s: got commits %+v, want only %s", label, commits, head)
 		}
 
-		for i := 0; i < len(commits) || i < len(test.wantCommits); i++ {
-			var gotC, wantC *vcs.Commit
-			if i < len(commits) {
-				gotC = commits[i]
-			}
-			if i < len(test.wantCommits) {
-				wantC = test.wantCommits[i]
-			}
-			if !commitsEqual(gotC, wantC) {
-				t.Errorf("%s: got commit %d == %+v, want %+v", label, i, gotC, wantC)
-			}
+		// Test that a nonexistent base returns ErrCommitNotFound.
+		if _, _, err := test.repo.Commits(vcs.CommitsOptions{Head: head, Base: nonexistentCommitID}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: for nonexistent base: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
 		}
 	}
 }
 
-func TestRepository_FileSystem_Symlinks(t *testing.T) {
+func TestRepository_Commits_cursor(t *testing.T) {
 	t.Parallel()
 
 	gitCommands := []string{
-		"touch file1",
-		"ln -s file1 link1",
-		"touch --date=2006-01-02T15:04:05Z file1 link1 || touch -t " + times[0] + " file1 link1",
-		"git add link1 file1",
-		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
-	}
-	hgCommands := []string{
-		"touch file1",
-		"ln -s file1 link1",
-		"touch --date=2006-01-02T15:04:05Z file1 link1 || touch -t " + times[0] + " file1 link1",
-		"hg add link1 file1",
-		"hg commit -m commit1 --user 'a <a@a.com>' --date '2006-01-02 15:04:05 UTC'",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git checkout -b b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"git checkout master",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m baz --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+		"GIT_AUTHOR_NAME=a GIT_AUTHOR_EMAIL=a@a.com GIT_AUTHOR_DATE=2006-01-02T15:04:08Z GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:08Z git merge --no-ff -m qux b",
 	}
-
 	tests := map[string]struct {
 		repo interface {
-			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
 		}
-		commitID vcs.CommitID
-
-		testFileInfoSys bool // whether to check the SymlinkInfo in FileInfo.Sys()
 	}{
-		// TODO(sqs): implement Lstat and symlink handling for git libgit2, git
-		// cmd, and hg cmd.
-
-		"git libgit2": {
-			repo:     makeGitRepositoryLibGit2(t, gitCommands...),
-			commitID: "85d3a39020cf28af4b887552fcab9e31a49f2ced",
-
-			testFileInfoSys: true,
-		},
-		"git cmd": {
-			repo:     makeGitRepositoryCmd(t, gitCommands...),
-			commitID: "85d3a39020cf28af4b887552fcab9e31a49f2ced",
-
-			testFileInfoSys: true,
-		},
-		"hg native": {
-			repo:     makeHgRepositoryNative(t, hgCommands...),
-			commitID: "c3fed02bbbc0b58418f32a363b8263aa46b0349e",
-			// TODO(sqs): implement SymlinkInfo
-		},
-		// "hg cmd": {
-		// 	repo:     &HgRepositoryCmd{initHgRepository(t, hgCommands...)},
-		// 	commitID: "c3fed02bbbc0b58418f32a363b8263aa46b0349e",
-		// },
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, gitCommands...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, gitCommands...)},
 	}
+
 	for label, test := range tests {
-		fs, err := test.repo.FileSystem(test.commitID)
+		all, wantTotal, err := test.repo.Commits(vcs.CommitsOptions{Head: "master"})
 		if err != nil {
-			t.Errorf("%s: FileSystem: %s", label, err)
+			t.Errorf("%s: Commits: %s", label, err)
 			continue
 		}
 
-		// file1 should be a file.
-		file1Info, err := fs.Stat("file1")
-		if err != nil {
-			t.Errorf("%s: fs.Stat(file1): %s", label, err)
+		// Paging through the commits with cursors should yield the
+		// same commits (including those only reachable from the
+		// merge's second parent) and total.
+		var paged []*vcs.Commit
+		opt := vcs.CommitsOptions{Head: "master", N: 1}
+		for i := 0; i < 10; i++ {
+			commits, total, err := test.repo.Commits(opt)
+			if err != nil {
+				t.Errorf("%s: Commits(%+v): %s", label, opt, err)
+				break
+			}
+			if total != wantTotal {
+				t.Errorf("%s: Commits(%+v): got total %d, want %d", label, opt, total, wantTotal)
+			}
+			paged = append(paged, commits...)
+			if opt.Cursor = vcs.NextCommitsCursor(opt, commits); opt.Cursor == "" {
+				break
+			}
+		}
+		if len(paged) != len(all) {
+			t.Errorf("%s: got %d paged commits, want %d", label, len(paged), len(all))
 			continue
 		}
-		if !file1Info.Mode().IsRegular() {
-			t.Errorf("%s: file1 Stat !IsRegular (mode: %o)", label, file1Info.Mode())
+		for i := range paged {
+			if paged[i].ID != all[i].ID {
+				t.Errorf("%s: got paged commit %d == %s, want %s", label, i, paged[i].ID, all[i].ID)
+			}
 		}
 
-		checkSymlinkFileInfo := func(label string, link os.FileInfo) {
-			if link.Mode()&os.ModeSymlink == 0 {
-				t.Errorf("%s: link mode is not symlink (mode: %o)", label, link.Mode())
-			}
-			if want := "link1"; link.Name() != want {
+		// Test that an invalid cursor returns an error.
+		if _, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master", Cursor: "master:1"}); err == nil {
+			t.Errorf("%s: for invalid cursor: got err == nil", label)
+		}
+
+		// Test that a cursor for a nonexistent commit returns ErrCommitNotFound.
+		if _, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master", Cursor: string(nonexistentCommitID)}); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: for nonexistent commit: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
+
+func TestRepository_Commits_cursorPath(t *testing.T) {
+	t.Parallel()
+
+	var gitCommands []string
+	for i, file := range []string{"f", "g", "f", "g", "f"} {
+		date := fmt.Sprintf("2006-01-02T15:04:0%dZ", i)
+		gitCommands = append(gitCommands,
+			fmt.Sprintf("echo %d >> %s", i, file),
+			"git add "+file,
+			fmt.Sprintf("GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=%s git commit -m %d --author='a <a@a.com>' --date %s", date, i, date),
+		)
+	}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+
+	for label, test := range tests {
+		all, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master", Path: "f"})
+		if err != nil {
+			t.Errorf("%s: Commits: %s", label, err)
+			continue
+		}
+		if len(all) != 3 {
+			t.Errorf("%s: got %d commits that changed f, want 3", label, len(all))
+			continue
+		}
+
+		// Paging through the commits that changed the path should
+		// yield each of them once, skipping the commits in between
+		// that didn't change it.
+		var paged []*vcs.Commit
+		opt := vcs.CommitsOptions{Head: "master", Path: "f", N: 1}
+		for i := 0; i < 10; i++ {
+			commits, _, err := test.repo.Commits(opt)
+			if err != nil {
+				t.Errorf("%s: Commits(%+v): %s", label, opt, err)
+				break
+			}
+			paged = append(paged, commits...)
+			if opt.Cursor = vcs.NextCommitsCursor(opt, commits); opt.Cursor == "" {
+				break
+			}
+		}
+		var got, want []vcs.CommitID
+		for _, c := range paged {
+			got = append(got, c.ID)
+		}
+		for _, c := range all {
+			want = append(want, c.ID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got paged commits %v, want %v", label, got, want)
+		}
+	}
+}
+
+func TestRepository_StreamCommits(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			vcs.Repository
+			vcs.CommitStreamer
+		}
+		id vcs.CommitID
+	}{
+		"git libgit2": {
+			repo: makeGitRepositoryLibGit2(t, gitCommands...),
+			id:   "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+		},
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			id:   "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+		},
+	}
+
+	for label, test := range tests {
+		// StreamCommits should yield the same commits and total as
+		// Commits.
+		wantCommits, wantTotal, err := test.repo.Commits(vcs.CommitsOptions{Head: test.id})
+		if err != nil {
+			t.Errorf("%s: Commits: %s", label, err)
+			continue
+		}
+
+		var commits []*vcs.Commit
+		total, err := test.repo.StreamCommits(vcs.CommitsOptions{Head: test.id}, func(c *vcs.Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: StreamCommits: %s", label, err)
+			continue
+		}
+		if total != wantTotal {
+			t.Errorf("%s: got %d total commits, want %d", label, total, wantTotal)
+		}
+		if len(commits) != len(wantCommits) {
+			t.Errorf("%s: got %d commits, want %d", label, len(commits), len(wantCommits))
+			continue
+		}
+		for i := range commits {
+			if !commitsEqual(commits[i], wantCommits[i]) {
+				t.Errorf("%s: got commit %d == %+v, want %+v", label, i, commits[i], wantCommits[i])
+			}
+		}
+
+		// Test that an error returned by fn stops the stream.
+		errStop := errors.New("stop")
+		var n int
+		if _, err := test.repo.StreamCommits(vcs.CommitsOptions{Head: test.id}, func(c *vcs.Commit) error {
+			n++
+			return errStop
+		}); err != errStop {
+			t.Errorf("%s: got err %v, want %v", label, err, errStop)
+		}
+		if n != 1 {
+			t.Errorf("%s: got fn called %d times after error, want 1", label, n)
+		}
+
+		// Test that trying to stream from a nonexistent commit returns ErrCommitNotFound.
+		if _, err := test.repo.StreamCommits(vcs.CommitsOptions{Head: nonexistentCommitID}, func(*vcs.Commit) error { return nil }); err != vcs.ErrCommitNotFound {
+			t.Errorf("%s: for nonexistent commit: got err %v, want %v", label, err, vcs.ErrCommitNotFound)
+		}
+	}
+}
+
+func TestRepository_Commits_options_path(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"touch file1",
+		"touch --date=2006-01-02T15:04:05Z file1 || touch -t " + times[0] + " file1",
+		"git add file1",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit2 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=c GIT_COMMITTER_EMAIL=c@c.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit --allow-empty -m commit3 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	wantGitCommits := []*vcs.Commit{
+		{
+			ID:        "546a3ef26e581624ef997cb8c0ba01ee475fc1dc",
+			Author:    vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Committer: &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2006-01-02T15:04:05Z")},
+			Message:   "commit2",
+			Subject:   "commit2",
+			Parents:   []vcs.CommitID{"a04652fa1998a0a7d2f2f77ecb7021de943d3aab"},
+		},
+	}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		opt         vcs.CommitsOptions
+		wantCommits []*vcs.Commit
+		wantTotal   uint
+	}{
+		"git cmd Path 0": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			opt: vcs.CommitsOptions{
+				Head: "master",
+				Path: "doesnt-exist",
+			},
+			wantCommits: nil,
+			wantTotal:   0,
+		},
+		"git cmd Path 1": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+			opt: vcs.CommitsOptions{
+				Head: "master",
+				Path: "file1",
+			},
+			wantCommits: wantGitCommits,
+			wantTotal:   1,
+		},
+	}
+
+	for label, test := range tests {
+		commits, total, err := test.repo.Commits(test.opt)
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+
+		if total != test.wantTotal {
+			t.Errorf("%s: got %d total commits, want %d", label, total, test.wantTotal)
+		}
+
+		if len(commits) != len(test.wantCommits) {
+			t.Errorf("%s: got %d commits, want %d", label, len(commits), len(test.wantCommits))
+		}
+
+		for i := 0; i < len(commits) || i < len(test.wantCommits); i++ {
+			var gotC, wantC *vcs.Commit
+			if i < len(commits) {
+				gotC = commits[i]
+			}
+			if i < len(test.wantCommits) {
+				wantC = test.wantCommits[i]
+			}
+			if !commitsEqual(gotC, wantC) {
+				t.Errorf("%s: got commit %d == %+v, want %+v", label, i, gotC, wantC)
+			}
+		}
+	}
+}
+
+func TestRepository_Commits_options_follow(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo a > file1",
+		"git add file1",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git mv file1 file2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit -m commit2 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"echo b >> file2",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -am commit3 --author='a <a@a.com>' --date 2006-01-02T15:04:07Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		opt          vcs.CommitsOptions
+		wantMessages []string
+		wantTotal    uint
+		wantErr      error
+	}{
+		"git cmd no Follow": {
+			repo:         makeGitRepositoryCmd(t, gitCommands...),
+			opt:          vcs.CommitsOptions{Head: "master", Path: "file2"},
+			wantMessages: []string{"commit3", "commit2"},
+			wantTotal:    2,
+		},
+		"git cmd Follow": {
+			repo:         makeGitRepositoryCmd(t, gitCommands...),
+			opt:          vcs.CommitsOptions{Head: "master", Path: "file2", Follow: true},
+			wantMessages: []string{"commit3", "commit2", "commit1"},
+			wantTotal:    3,
+		},
+		"git libgit2 Follow": {
+			repo:         makeGitRepositoryLibGit2(t, gitCommands...),
+			opt:          vcs.CommitsOptions{Head: "master", Path: "file2", Follow: true},
+			wantMessages: []string{"commit3", "commit2", "commit1"},
+			wantTotal:    3,
+		},
+		"git cmd Follow no Path": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			opt:     vcs.CommitsOptions{Head: "master", Follow: true},
+			wantErr: vcs.ErrInvalidFollow,
+		},
+		"git cmd Follow glob": {
+			repo:    makeGitRepositoryCmd(t, gitCommands...),
+			opt:     vcs.CommitsOptions{Head: "master", Path: "file*", Follow: true},
+			wantErr: vcs.ErrInvalidFollow,
+		},
+	}
+
+	for label, test := range tests {
+		commits, total, err := test.repo.Commits(test.opt)
+		if err != test.wantErr {
+			t.Errorf("%s: Commits(): got error %v, want %v", label, err, test.wantErr)
+			continue
+		}
+
+		if total != test.wantTotal {
+			t.Errorf("%s: got %d total commits, want %d", label, total, test.wantTotal)
+		}
+
+		var messages []string
+		for _, c := range commits {
+			messages = append(messages, c.Message)
+		}
+		if !reflect.DeepEqual(messages, test.wantMessages) {
+			t.Errorf("%s: got commits %v, want %v", label, messages, test.wantMessages)
+		}
+	}
+}
+
+func TestRepository_Commits_mailmap(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo 'a <a@a.com> <a@old.com>' > .mailmap",
+		"git add .mailmap",
+		"GIT_COMMITTER_NAME=old GIT_COMMITTER_EMAIL=a@old.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='old <a@old.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m commit2 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+		opt        vcs.CommitsOptions
+		wantAuthor []string
+	}{
+		"git cmd": {
+			repo:       makeGitRepositoryCmd(t, gitCommands...),
+			opt:        vcs.CommitsOptions{Head: "master"},
+			wantAuthor: []string{"a <a@a.com>", "old <a@old.com>"},
+		},
+		"git cmd UseMailmap": {
+			repo:       makeGitRepositoryCmd(t, gitCommands...),
+			opt:        vcs.CommitsOptions{Head: "master", UseMailmap: true},
+			wantAuthor: []string{"a <a@a.com>", "a <a@a.com>"},
+		},
+		"git go-git UseMailmap": {
+			repo:       makeGitRepositoryGoGit(t, gitCommands...),
+			opt:        vcs.CommitsOptions{Head: "master", UseMailmap: true},
+			wantAuthor: []string{"a <a@a.com>", "a <a@a.com>"},
+		},
+		"git libgit2 UseMailmap": {
+			repo:       makeGitRepositoryLibGit2(t, gitCommands...),
+			opt:        vcs.CommitsOptions{Head: "master", UseMailmap: true},
+			wantAuthor: []string{"a <a@a.com>", "a <a@a.com>"},
+		},
+	}
+
+	for label, test := range tests {
+		commits, _, err := test.repo.Commits(test.opt)
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+
+		var authors, committers []string
+		for _, c := range commits {
+			authors = append(authors, c.Author.Name+" <"+c.Author.Email+">")
+			committers = append(committers, c.Committer.Name+" <"+c.Committer.Email+">")
+		}
+		if !reflect.DeepEqual(authors, test.wantAuthor) {
+			t.Errorf("%s: got authors %q, want %q", label, authors, test.wantAuthor)
+		}
+		if !reflect.DeepEqual(committers, test.wantAuthor) {
+			t.Errorf("%s: got committers %q, want %q", label, committers, test.wantAuthor)
+		}
+	}
+}
+
+func TestRepository_Commits_reencodeMessage(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git -c i18n.commitEncoding=ISO-8859-1 commit --allow-empty -m \"$(printf 'caf\\351')\" --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git -c i18n.commitEncoding=x-unknown commit --allow-empty -m \"$(printf 'na\\357ve')\" --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	wantMessages := []string{"na\xefve", "caf\u00e9"}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+		},
+		"git libgit2": {
+			repo: makeGitRepositoryLibGit2(t, gitCommands...),
+		},
+	}
+
+	for label, test := range tests {
+		commits, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master", ReencodeMessage: true})
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+
+		var messages []string
+		for _, c := range commits {
+			messages = append(messages, c.Message)
+		}
+		if !reflect.DeepEqual(messages, wantMessages) {
+			t.Errorf("%s: got commit messages %q, want %q", label, messages, wantMessages)
+		}
+	}
+}
+
+func TestRepository_Commits_trailers(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m 'subject' -m 'body' -m 'Co-authored-by: b <b@b.com>' -m 'Reviewed-by: d <d@d.com>' --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git commit --allow-empty -m 'subject' -m 'body' -m \"$(printf 'Co-authored-by: b <b@b.com>\\nCo-authored-by: c <c@c.com>\\nSigned-off-by: a <a@a.com>')\" --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	wantMessages := []string{
+		"subject\n\nbody\n\nCo-authored-by: b <b@b.com>\nCo-authored-by: c <c@c.com>\nSigned-off-by: a <a@a.com>",
+		"subject\n\nbody\n\nCo-authored-by: b <b@b.com>\n\nReviewed-by: d <d@d.com>",
+	}
+	wantTrailers := []vcs.Trailers{
+		{
+			"Co-authored-by": {"b <b@b.com>", "c <c@c.com>"},
+			"Signed-off-by":  {"a <a@a.com>"},
+		},
+		{
+			"Reviewed-by": {"d <d@d.com>"},
+		},
+	}
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+		},
+		"git libgit2": {
+			repo: makeGitRepositoryLibGit2(t, gitCommands...),
+		},
+	}
+
+	for label, test := range tests {
+		commits, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master"})
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+
+		var messages []string
+		var trailers []vcs.Trailers
+		for _, c := range commits {
+			messages = append(messages, c.Message)
+			trailers = append(trailers, c.Trailers)
+		}
+		if !reflect.DeepEqual(messages, wantMessages) {
+			t.Errorf("%s: got commit messages %q, want %q", label, messages, wantMessages)
+		}
+		if !reflect.DeepEqual(trailers, wantTrailers) {
+			t.Errorf("%s: got commit trailers %v, want %v", label, trailers, wantTrailers)
+		}
+	}
+}
+
+func TestRepository_Commits_signatureStatus(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not found")
+	}
+	gnupgHome, err := ioutil.TempDir("", "go-vcs-gnupg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gnupgHome)
+
+	gpg := "GNUPGHOME=" + gnupgHome + " "
+	if out, err := exec.Command("bash", "-c", gpg+"gpg --batch --quiet --passphrase '' --quick-gen-key 'a <a@a.com>' ed25519 sign never").CombinedOutput(); err != nil {
+		t.Fatalf("gpg failed: %s\n%s", err, out)
+	}
+	defer exec.Command("bash", "-c", gpg+"gpgconf --kill all").Run()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m unsigned --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		gpg + "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:06Z git -c user.signingkey=a@a.com commit -S --allow-empty -m signed --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+		},
+		"git libgit2": {
+			repo: makeGitRepositoryLibGit2(t, gitCommands...),
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+		},
+	}
+
+	for label, test := range tests {
+		// Use the full commit ID, which go-git handles itself.
+		head, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+
+		// The signature can't be verified (the key isn't in the
+		// default keyring), but the commit is still signed.
+		commits, _, err := test.repo.Commits(vcs.CommitsOptions{Head: head, IncludeSignatureStatus: true})
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+		if len(commits) != 2 {
+			t.Errorf("%s: got %d commits, want 2", label, len(commits))
+			continue
+		}
+		for _, c := range commits {
+			if want := c.Subject == "signed"; c.Signed != want {
+				t.Errorf("%s: commit %q: got Signed %v, want %v", label, c.Subject, c.Signed, want)
+			}
+		}
+
+		// Without IncludeSignatureStatus, Signed is not set.
+		commits, _, err = test.repo.Commits(vcs.CommitsOptions{Head: head})
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+		for _, c := range commits {
+			if c.Signed {
+				t.Errorf("%s: commit %q: got Signed without IncludeSignatureStatus", label, c.Subject)
+			}
+		}
+	}
+}
+
+func TestRepository_Commits_subjectBody(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit --allow-empty -m \"$(printf 'subject\\nwrapped\\n\\n\\nparagraph 1\\n\\nparagraph 2\\n  indented')\" --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	dir := initGitRepository(t, gitCommands...)
+
+	// Compare against git's own subject and body formats.
+	gitFormat := func(format string) string {
+		c := exec.Command("git", "log", "-1", "--format="+format)
+		c.Dir = dir
+		out, err := c.Output()
+		if err != nil {
+			t.Fatalf("git log --format=%s: %s", format, err)
+		}
+		return strings.TrimSuffix(string(out), "\n")
+	}
+	wantSubject := gitFormat("%s")
+	wantBody := strings.TrimSuffix(gitFormat("%b"), "\n")
+	if want := "subject wrapped"; wantSubject != want {
+		t.Fatalf("git subject: got %q, want %q", wantSubject, want)
+	}
+	if want := "paragraph 1\n\nparagraph 2\n  indented"; wantBody != want {
+		t.Fatalf("git body: got %q, want %q", wantBody, want)
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
+		}
+	}{
+		"git cmd": {
+			repo: makeGitRepositoryCmd(t, gitCommands...),
+		},
+		"git go-git": {
+			repo: makeGitRepositoryGoGit(t, gitCommands...),
+		},
+		"git libgit2": {
+			repo: makeGitRepositoryLibGit2(t, gitCommands...),
+		},
+	}
+
+	for label, test := range tests {
+		commits, _, err := test.repo.Commits(vcs.CommitsOptions{Head: "master"})
+		if err != nil {
+			t.Errorf("%s: Commits(): %s", label, err)
+			continue
+		}
+		if len(commits) != 1 {
+			t.Errorf("%s: got %d commits, want 1", label, len(commits))
+			continue
+		}
+
+		c := commits[0]
+		if c.Subject != wantSubject {
+			t.Errorf("%s: got subject %q, want %q", label, c.Subject, wantSubject)
+		}
+		if c.Body != wantBody {
+			t.Errorf("%s: got body %q, want %q", label, c.Body, wantBody)
+		}
+		if want := "subject\nwrapped\n\nparagraph 1\n\nparagraph 2\n  indented"; c.Message != want {
+			t.Errorf("%s: got message %q, want %q", label, c.Message, want)
+		}
+	}
+}
+
+func TestRepository_FileSystem_Symlinks(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"touch file1",
+		"ln -s file1 link1",
+		"touch --date=2006-01-02T15:04:05Z file1 link1 || touch -t " + times[0] + " file1 link1",
+		"git add link1 file1",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	hgCommands := []string{
+		"touch file1",
+		"ln -s file1 link1",
+		"touch --date=2006-01-02T15:04:05Z file1 link1 || touch -t " + times[0] + " file1 link1",
+		"hg add link1 file1",
+		"hg commit -m commit1 --user 'a <a@a.com>' --date '2006-01-02 15:04:05 UTC'",
+	}
+
+	tests := map[string]struct {
+		repo interface {
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}
+		commitID vcs.CommitID
+
+		testFileInfoSys bool // whether to check the SymlinkInfo in FileInfo.Sys()
+	}{
+		// TODO(sqs): implement Lstat and symlink handling for git libgit2, git
+		// cmd, and hg cmd.
+
+		"git libgit2": {
+			repo:     makeGitRepositoryLibGit2(t, gitCommands...),
+			commitID: "85d3a39020cf28af4b887552fcab9e31a49f2ced",
+
+			testFileInfoSys: true,
+		},
+		"git go-git": {
+			repo:     makeGitRepositoryGoGit(t, gitCommands...),
+			commitID: "85d3a39020cf28af4b887552fcab9e31a49f2ced",
+
+			testFileInfoSys: true,
+		},
+		"git cmd": {
+			repo:     makeGitRepositoryCmd(t, gitCommands...),
+			commitID: "85d3a39020cf28af4b887552fcab9e31a49f2ced",
+
+			testFileInfoSys: true,
+		},
+		"hg native": {
+			repo:     makeHgRepositoryNative(t, hgCommands...),
+			commitID: "c3fed02bbbc0b58418f32a363b8263aa46b0349e",
+			// TODO(sqs): implement SymlinkInfo
+		},
+		// "hg cmd": {
+		// 	repo:     &HgRepositoryCmd{initHgRepository(t, hgCommands...)},
+		// 	commitID: "c3fed02bbbc0b58418f32a363b8263aa46b0349e",
+		// },
+	}
+	for label, test := range tests {
+		fs, err := test.repo.FileSystem(test.commitID)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+
+		// file1 should be a file.
+		file1Info, err := fs.Stat("file1")
+		if err != nil {
+			t.Errorf("%s: fs.Stat(file1): %s", label, err)
+			continue
+		}
+		if !file1Info.Mode().IsRegular() {
+			t.Errorf("%s: file1 Stat !IsRegular (mode: %o)", label, file1Info.Mode())
+		}
+
+		checkSymlinkFileInfo := func(label string, link os.FileInfo) {
+			if link.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("%s: link mode is not symlink (mode: %o)", label, link.Mode())
+			}
+			if want := "link1"; link.Name() != want {
 				t.Errorf("%s: got link.Name() == %q, want %q", label, link.Name(), want)
 			}
 			if test.testFileInfoSys {
@@ -1176,12 +2018,284 @@ func TestRepository_FileSystem_Symlinks(t *testing.T) {
 		if !link1Info.Mode().IsRegular() {
 			t.Errorf("%s: link1 Stat !IsRegular (mode: %o)", label, link1Info.Mode())
 		}
-		if link1Info.Name() != "link1" {
-			t.Errorf("%s: got link1 Name %q, want %q", label, link1Info.Name(), "link1")
+		if link1Info.Name() != "link1" {
+			t.Errorf("%s: got link1 Name %q, want %q", label, link1Info.Name(), "link1")
+		}
+	}
+}
+
+func TestRepository_FileSystem_Stat_followSymlinks(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"mkdir dir",
+		"echo -n abc > dir/file1",
+		"ln -s dir/file1 link-to-file",
+		"ln -s dir link-to-dir",
+		"ln -s ../link-to-file dir/link-to-link",
+		"ln -s loop2 loop1",
+		"ln -s loop1 loop2",
+		"ln -s ../outside link-outside",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}
+	}{
+		"git libgit2": {repo: makeGitRepositoryLibGit2(t, gitCommands...)},
+		"git cmd":     {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+	for label, test := range tests {
+		commitID, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		fs, err := test.repo.FileSystem(commitID)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+
+		// Symlinks to a file (directly and via another symlink).
+		for _, name := range []string{"link-to-file", "dir/link-to-link"} {
+			fi, err := fs.Stat(name)
+			if err != nil {
+				t.Errorf("%s: fs.Stat(%s): %s", label, name, err)
+				continue
+			}
+			if !fi.Mode().IsRegular() {
+				t.Errorf("%s: %s Stat !IsRegular (mode: %o)", label, name, fi.Mode())
+			}
+			if got, want := fi.Size(), int64(3); got != want {
+				t.Errorf("%s: %s Stat got size %d, want %d", label, name, got, want)
+			}
+			if got, want := fi.Name(), path.Base(name); got != want {
+				t.Errorf("%s: %s Stat got name %q, want %q", label, name, got, want)
+			}
+
+			// Lstat still returns info about the link itself.
+			lfi, err := fs.Lstat(name)
+			if err != nil {
+				t.Errorf("%s: fs.Lstat(%s): %s", label, name, err)
+				continue
+			}
+			if lfi.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("%s: %s Lstat is not symlink (mode: %o)", label, name, lfi.Mode())
+			}
+		}
+
+		// Symlink to a dir.
+		if fi, err := fs.Stat("link-to-dir"); err != nil {
+			t.Errorf("%s: fs.Stat(link-to-dir): %s", label, err)
+		} else if !fi.Mode().IsDir() {
+			t.Errorf("%s: link-to-dir Stat !IsDir (mode: %o)", label, fi.Mode())
+		}
+
+		// Circular symlinks.
+		if _, err := fs.Stat("loop1"); !isSymlinkLoopError(err) {
+			t.Errorf("%s: fs.Stat(loop1): got error %v, want symlink loop error", label, err)
+		}
+
+		// Symlink outside the repository.
+		if _, err := fs.Stat("link-outside"); !os.IsNotExist(err) {
+			t.Errorf("%s: fs.Stat(link-outside): got error %v, want os.IsNotExist", label, err)
+		}
+	}
+}
+
+func TestRepository_FileSystem_StatMulti(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"mkdir -p dir/sub",
+		"echo -n abc > dir/sub/file1",
+		"echo -n abcd > file2",
+		"chmod +x file2",
+		"ln -s dir/sub/file1 link",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo -n x > dir/file3",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2007-01-02T15:04:05Z git commit -m commit2 --author='a <a@a.com>' --date 2007-01-02T15:04:05Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+	for label, test := range tests {
+		commitID, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		fs, err := test.repo.FileSystem(commitID)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+		ms, ok := fs.(vcs.MultiStater)
+		if !ok {
+			t.Errorf("%s: FileSystem is not a MultiStater", label)
+			continue
+		}
+
+		paths := []string{".", "dir", "/dir/sub/file1", "dir/sub", "file2", "link", "dir/file3", "nonexistent", "dir/nonexistent", "../outside"}
+		fis, errs := ms.StatMulti(paths)
+		if len(fis) != len(paths) || len(errs) != len(paths) {
+			t.Errorf("%s: StatMulti returned %d infos and %d errors, want %d", label, len(fis), len(errs), len(paths))
+			continue
+		}
+		for i, path := range paths {
+			want, wantErr := fs.Stat(path)
+			if wantErr != nil {
+				if !os.IsNotExist(errs[i]) {
+					t.Errorf("%s: %s: got error %v, want os.IsNotExist", label, path, errs[i])
+				}
+				continue
+			}
+			if errs[i] != nil {
+				t.Errorf("%s: %s: StatMulti: %s", label, path, errs[i])
+				continue
+			}
+			got := fis[i]
+			if got.Name() != want.Name() || got.Mode() != want.Mode() || got.Size() != want.Size() || !got.ModTime().Equal(want.ModTime()) {
+				t.Errorf("%s: %s: got name %q mode %o size %d mtime %s, want (as Stat) name %q mode %o size %d mtime %s", label, path, got.Name(), got.Mode(), got.Size(), got.ModTime(), want.Name(), want.Mode(), want.Size(), want.ModTime())
+			}
+		}
+	}
+}
+
+func TestRepository_FileSystem_Tar(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"mkdir -p dir/sub",
+		"echo -n abc > dir/sub/file1",
+		"echo -n x > dir/file2",
+		"echo -n abcd > file3",
+		"ln -s sub/file1 dir/link",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+	for label, test := range tests {
+		commitID, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Errorf("%s: ResolveRevision: %s", label, err)
+			continue
+		}
+		fs, err := test.repo.FileSystem(commitID)
+		if err != nil {
+			t.Errorf("%s: FileSystem: %s", label, err)
+			continue
+		}
+		te, ok := fs.(vcs.TarExporter)
+		if !ok {
+			t.Errorf("%s: FileSystem is not a TarExporter", label)
+			continue
+		}
+
+		// Walk the tree with ReadDir to get the expected files (and
+		// symlink destinations).
+		want := map[string]string{}
+		var walk func(dir string)
+		walk = func(dir string) {
+			fis, err := fs.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("%s: ReadDir(%q): %s", label, dir, err)
+			}
+			for _, fi := range fis {
+				name := path.Join(dir, fi.Name())
+				switch {
+				case fi.IsDir():
+					walk(name)
+				case fi.Mode()&os.ModeSymlink != 0:
+					want[name] = fi.Sys().(vcs.SymlinkInfo).Dest
+				default:
+					data, err := vfs.ReadFile(fs, name)
+					if err != nil {
+						t.Fatalf("%s: ReadFile(%q): %s", label, name, err)
+					}
+					want[name] = string(data)
+				}
+			}
+		}
+		walk("dir")
+
+		rc, err := te.Tar("dir")
+		if err != nil {
+			t.Errorf("%s: Tar: %s", label, err)
+			continue
+		}
+		got := map[string]string{}
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: reading tar: %s", label, err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeReg:
+				data, err := ioutil.ReadAll(tr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[hdr.Name] = string(data)
+			case tar.TypeSymlink:
+				got[hdr.Name] = hdr.Linkname
+			}
+		}
+		if err := rc.Close(); err != nil {
+			t.Errorf("%s: Close: %s", label, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got tar files %v, want (as ReadDir walk) %v", label, got, want)
+		}
+
+		// Closing the reader early must not hang or leak the
+		// `git archive` process (which would keep the repository
+		// locked).
+		rc, err = te.Tar(".")
+		if err != nil {
+			t.Fatalf("%s: Tar: %s", label, err)
+		}
+		if _, err := rc.Read(make([]byte, 1)); err != nil {
+			t.Fatalf("%s: Read: %s", label, err)
+		}
+		rc.Close()
+		if _, err := fs.ReadDir("."); err != nil {
+			t.Errorf("%s: ReadDir after closing tar early: %s", label, err)
+		}
+
+		if _, err := te.Tar("nonexistent"); !os.IsNotExist(err) {
+			t.Errorf("%s: Tar of nonexistent path: got error %v, want os.IsNotExist", label, err)
 		}
 	}
 }
 
+func isSymlinkLoopError(err error) bool {
+	pe, ok := err.(*os.PathError)
+	return ok && pe.Err.Error() == "too many levels of symbolic links"
+}
+
 func TestRepository_FileSystem(t *testing.T) {
 	t.Parallel()
 
@@ -1231,6 +2345,11 @@ func TestRepository_FileSystem(t *testing.T) {
 			first:  "b6602ca96bdc0ab647278577a3c6edcb8fe18fb0",
 			second: "ace35f1597e087fe2d302ed6cb2763174e6b9660",
 		},
+		"git go-git": {
+			repo:   makeGitRepositoryGoGit(t, gitCommands...),
+			first:  "b6602ca96bdc0ab647278577a3c6edcb8fe18fb0",
+			second: "ace35f1597e087fe2d302ed6cb2763174e6b9660",
+		},
 		"git cmd": {
 			repo:   makeGitRepositoryCmd(t, gitCommands...),
 			first:  "b6602ca96bdc0ab647278577a3c6edcb8fe18fb0",
@@ -1392,6 +2511,75 @@ func TestRepository_FileSystem(t *testing.T) {
 	}
 }
 
+func TestRepository_FileSystem_Open_largeFile(t *testing.T) {
+	t.Parallel()
+
+	// The file is larger than gitcmd reads into memory, so it is
+	// streamed.
+	const size = 2 << 20
+	want := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	gitCommands := []string{
+		"for i in $(seq 131072); do printf 0123456789abcdef; done > f",
+		"git add f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	tests := map[string]struct {
+		repo interface {
+			ResolveRevision(spec string) (vcs.CommitID, error)
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}
+	}{
+		"git cmd": {repo: makeGitRepositoryCmd(t, gitCommands...)},
+	}
+
+	for label, test := range tests {
+		commitID, err := test.repo.ResolveRevision("master")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, err := test.repo.FileSystem(commitID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := fs.Open("f")
+		if err != nil {
+			t.Errorf("%s: Open: %s", label, err)
+			continue
+		}
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Errorf("%s: ReadAll: %s", label, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: got %d bytes of contents, want %d", label, len(data), len(want))
+		}
+
+		if end, err := f.Seek(0, os.SEEK_END); err != nil || end != size {
+			t.Errorf("%s: Seek to end: got %d, %v, want %d", label, end, err, size)
+		}
+		for _, off := range []int64{size - 16, 20, 0} {
+			if _, err := f.Seek(off, os.SEEK_SET); err != nil {
+				t.Errorf("%s: Seek(%d): %s", label, off, err)
+				continue
+			}
+			buf := make([]byte, 4)
+			if _, err := io.ReadFull(f, buf); err != nil {
+				t.Errorf("%s: read at %d: %s", label, off, err)
+			} else if !bytes.Equal(buf, want[off:off+4]) {
+				t.Errorf("%s: read at %d: got %q, want %q", label, off, buf, want[off:off+4])
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Errorf("%s: Close: %s", label, err)
+		}
+
+		if _, err := fs.Open("doesntexist"); !os.IsNotExist(err) {
+			t.Errorf("%s: Open nonexistent file: got error %v, want os.IsNotExist", label, err)
+		}
+	}
+}
+
 func TestRepository_FileSystem_gitSubmodules(t *testing.T) {
 	t.Parallel()
 
@@ -1513,6 +2701,35 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestClone_singleBranch(t *testing.T) {
+	t.Parallel()
+
+	commitCmd := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z --allow-empty"
+	baseDir := initGitRepository(t, commitCmd, "git branch b1", "git branch b2")
+	headDir := makeTmpDir(t, "git-clone")
+
+	r, err := gitcmd.Clone(baseDir, headDir, vcs.CloneOpt{Bare: true, Mirror: true, Branch: "b1"})
+	if err != nil {
+		t.Fatalf("Clone(%q, %q): %s", baseDir, headDir, err)
+	}
+
+	branches, err := r.Branches(vcs.BranchesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	if want := []string{"b1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got branches %v, want %v", names, want)
+	}
+
+	if _, err := gitcmd.Clone(baseDir, makeTmpDir(t, "git-clone"), vcs.CloneOpt{Branch: "--upload-pack=touch"}); err == nil {
+		t.Error("Clone with flag-like branch name: got nil error, want error")
+	}
+}
+
 func TestRepository_UpdateEverything(t *testing.T) {
 	t.Parallel()
 
@@ -1617,6 +2834,341 @@ func TestRepository_UpdateEverything(t *testing.T) {
 	}
 }
 
+func TestRepository_UpdateEverything_shallow(t *testing.T) {
+	t.Parallel()
+
+	commitCmd := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z --allow-empty"
+	baseDir := initGitRepository(t, commitCmd, commitCmd)
+	headDir := makeTmpDir(t, "git-clone")
+
+	// Local clones ignore --depth unless the URL is a file:// URL.
+	r, err := gitcmd.Clone("file://"+baseDir, headDir, vcs.CloneOpt{Bare: true, Mirror: true, Depth: 1})
+	if err != nil {
+		t.Fatalf("Clone(%q, %q): %s", baseDir, headDir, err)
+	}
+
+	headAndTotal := func() (vcs.CommitID, uint) {
+		head, err := r.ResolveRevision("HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, total, err := r.Commits(vcs.CommitsOptions{Head: head})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return head, total
+	}
+	oldHead, total := headAndTotal()
+	if want := uint(1); total != want {
+		t.Errorf("after clone: got %d commits, want %d", total, want)
+	}
+
+	c := exec.Command("bash", "-c", commitCmd)
+	c.Dir = baseDir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("exec `%s` failed: %s. Output was:\n\n%s", commitCmd, err, out)
+	}
+
+	if err := r.UpdateEverything(vcs.RemoteOpts{}); err != nil {
+		t.Fatalf("UpdateEverything: %s", err)
+	}
+	newHead, total := headAndTotal()
+	if newHead == oldHead {
+		t.Errorf("after update: HEAD is still %s, want new commit", oldHead)
+	}
+	if want := uint(1); total != want {
+		t.Errorf("after update: got %d commits, want %d", total, want)
+	}
+}
+
+func TestRepository_bzr(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("bzr"); err != nil {
+		t.Skip("bzr not found")
+	}
+	r := makeBzrRepositoryCmd(t,
+		"echo -n a > f",
+		"mkdir d && echo -n bb > d/g",
+		"bzr add -q f d",
+		"bzr commit -q -m foo --commit-time='2006-01-02 15:04:05 +0000'",
+		"echo -n ccc > f",
+		"bzr commit -q -m $'bar\\n\\nbody' --author='b <b@b.com>' --commit-time='2014-05-06 19:20:21 +0000'",
+		"bzr tag -q t",
+	)
+
+	head, err := r.ResolveRevision("-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := r.ResolveTag("t"); err != nil || id != head {
+		t.Errorf("ResolveTag: got %q (err %v), want %q", id, err, head)
+	}
+	if _, err := r.ResolveTag("nonexistent"); err != vcs.ErrTagNotFound {
+		t.Errorf("ResolveTag(nonexistent): got err %v, want %v", err, vcs.ErrTagNotFound)
+	}
+	if tags, err := r.Tags(); err != nil || !reflect.DeepEqual(tags, []*vcs.Tag{{Name: "t", CommitID: head}}) {
+		t.Errorf("Tags: got %v (err %v)", asJSON(tags), err)
+	}
+	branches, err := r.Branches(vcs.BranchesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 || branches[0].Head != head {
+		t.Fatalf("Branches: got %v, want 1 branch with head %q", asJSON(branches), head)
+	}
+	if id, err := r.ResolveBranch(branches[0].Name); err != nil || id != head {
+		t.Errorf("ResolveBranch: got %q (err %v), want %q", id, err, head)
+	}
+
+	commits, total, err := r.Commits(vcs.CommitsOptions{Head: head})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || total != 2 {
+		t.Fatalf("Commits: got %d commits (total %d), want 2", len(commits), total)
+	}
+	root := commits[1]
+	wantCommit := &vcs.Commit{
+		ID:        head,
+		Author:    vcs.Signature{Name: "b", Email: "b@b.com", Date: mustParseTime(time.RFC3339, "2014-05-06T19:20:21Z")},
+		Committer: &vcs.Signature{Name: "a", Email: "a@a.com", Date: mustParseTime(time.RFC3339, "2014-05-06T19:20:21Z")},
+		Message:   "bar\n\nbody",
+		Parents:   []vcs.CommitID{root.ID},
+		Subject:   "bar",
+		Body:      "body",
+	}
+	commit, err := r.GetCommit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !commitsEqual(commit, wantCommit) {
+		t.Errorf("GetCommit: got %v, want %v", asJSON(commit), asJSON(wantCommit))
+	}
+	if _, err := r.GetCommit(nonexistentCommitID); err != vcs.ErrCommitNotFound {
+		t.Errorf("GetCommit(nonexistent): got err %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+	if commits, _, err := r.Commits(vcs.CommitsOptions{Head: head, Base: root.ID}); err != nil || len(commits) != 1 || commits[0].ID != head {
+		t.Errorf("Commits with Base: got %v (err %v), want only %q", asJSON(commits), err, head)
+	}
+
+	fs, err := r.FileSystem(root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := vfs.ReadFile(fs, "f")
+	if err != nil || string(data) != "a" {
+		t.Errorf("ReadFile(f) at root commit: got %q (err %v), want %q", data, err, "a")
+	}
+	entries, err := fs.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "d" || !entries[0].IsDir() || entries[1].Name() != "f" {
+		t.Errorf("ReadDir(.): got %v, want [d/ f]", asJSON(entries))
+	}
+	fi, err := fs.Stat("d/g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 2 || !fi.ModTime().Equal(root.Author.Date.Time()) {
+		t.Errorf("Stat(d/g): got size %d, mtime %s", fi.Size(), fi.ModTime())
+	}
+	if _, err := fs.Stat("nonexistent"); !os.IsNotExist(err) {
+		t.Errorf("Stat(nonexistent): got err %v, want not exist", err)
+	}
+}
+
+func TestRepository_svn(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("svnadmin"); err != nil {
+		t.Skip("svnadmin not found")
+	}
+	r := makeSvnRepositoryCmd(t,
+		"echo -n a > f",
+		"mkdir d && echo -n bb > d/g",
+		"svn add -q f d",
+		"svn commit -q -m foo --username a",
+		"echo -n ccc > f",
+		"svn commit -q -m $'bar\\n\\nbody' --username b",
+		"mkdir e && svn add -q e && svn commit -q -m 'other' --username b",
+	)
+
+	head, err := r.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != "3" {
+		t.Errorf("ResolveRevision(HEAD): got %q, want %q", head, "3")
+	}
+	if id, err := r.ResolveRevision("r2"); err != nil || id != "2" {
+		t.Errorf("ResolveRevision(r2): got %q (err %v), want %q", id, err, "2")
+	}
+	if _, err := r.ResolveRevision("100"); err != vcs.ErrRevisionNotFound {
+		t.Errorf("ResolveRevision(100): got err %v, want %v", err, vcs.ErrRevisionNotFound)
+	}
+
+	commit, err := r.GetCommit("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.ID != "2" || commit.Author.Name != "b" || commit.Message != "bar\n\nbody" || commit.Subject != "bar" || !reflect.DeepEqual(commit.Parents, []vcs.CommitID{"1"}) {
+		t.Errorf("GetCommit(2): got %v", asJSON(commit))
+	}
+	if _, err := r.GetCommit("100"); err != vcs.ErrCommitNotFound {
+		t.Errorf("GetCommit(100): got err %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+
+	commitIDs := func(commits []*vcs.Commit) (ids []vcs.CommitID) {
+		for _, c := range commits {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	tests := map[string]struct {
+		opt       vcs.CommitsOptions
+		wantIDs   []vcs.CommitID
+		wantTotal uint
+	}{
+		"all":        {vcs.CommitsOptions{Head: head}, []vcs.CommitID{"3", "2", "1"}, 3},
+		"N and Skip": {vcs.CommitsOptions{Head: head, N: 1, Skip: 1, NoTotal: true}, []vcs.CommitID{"2"}, 0},
+		"Base":       {vcs.CommitsOptions{Head: head, Base: "1"}, []vcs.CommitID{"3", "2"}, 2},
+		"Path":       {vcs.CommitsOptions{Head: head, Path: "d"}, []vcs.CommitID{"1"}, 1},
+	}
+	for label, test := range tests {
+		commits, total, err := r.Commits(test.opt)
+		if err != nil {
+			t.Errorf("%s: Commits: %s", label, err)
+			continue
+		}
+		if ids := commitIDs(commits); !reflect.DeepEqual(ids, test.wantIDs) || total != test.wantTotal {
+			t.Errorf("%s: got commits %v (total %d), want %v (total %d)", label, ids, total, test.wantIDs, test.wantTotal)
+		}
+	}
+
+	fs, err := r.FileSystem("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := vfs.ReadFile(fs, "f")
+	if err != nil || string(data) != "a" {
+		t.Errorf("ReadFile(f) at revision 1: got %q (err %v), want %q", data, err, "a")
+	}
+	entries, err := fs.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "d" || !entries[0].IsDir() || entries[1].Name() != "f" || entries[1].Size() != 1 {
+		t.Errorf("ReadDir(.): got %v, want [d/ f]", asJSON(entries))
+	}
+	if fi, err := fs.Stat("d/g"); err != nil || fi.Size() != 2 || fi.IsDir() {
+		t.Errorf("Stat(d/g): got %v (err %v)", asJSON(fi), err)
+	}
+	if _, err := fs.Stat("e"); !os.IsNotExist(err) {
+		t.Errorf("Stat(e) at revision 1: got err %v, want not exist", err)
+	}
+}
+
+func TestRepository_emptyRepo(t *testing.T) {
+	t.Parallel()
+
+	dir := makeTmpDir(t, "git-empty")
+	c := exec.Command("git", "init", "--bare")
+	c.Dir = dir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("exec %v failed: %s. Output was:\n\n%s", c.Args, err, out)
+	}
+	r, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.ResolveRevision("HEAD"); err != vcs.ErrRepoEmpty {
+		t.Errorf("ResolveRevision: got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+	if _, err := r.ResolveBranch("master"); err != vcs.ErrRepoEmpty {
+		t.Errorf("ResolveBranch: got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+	if _, _, err := r.Commits(vcs.CommitsOptions{Head: "HEAD"}); err != vcs.ErrRepoEmpty {
+		t.Errorf("Commits: got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+	if _, err := r.DefaultBranch(); err != vcs.ErrRepoEmpty {
+		t.Errorf("DefaultBranch: got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+
+	// Listing refs of an empty repository is not an error.
+	if branches, err := r.Branches(vcs.BranchesOptions{}); err != nil {
+		t.Errorf("Branches: %s", err)
+	} else if len(branches) != 0 {
+		t.Errorf("Branches: got %d branches, want none", len(branches))
+	}
+}
+
+// TestRepository_stderrWarnings tests that warnings git writes to
+// stderr aren't mistaken for the output of commands whose stdout is
+// parsed.
+func TestRepository_stderrWarnings(t *testing.T) {
+	t.Parallel()
+
+	r := makeGitRepositoryCmd(t,
+		"mkdir dir && echo -n hello > dir/f",
+		"git add dir/f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag t",
+		// Makes git (2.36 and newer) print a deprecation warning to
+		// stderr when it runs.
+		"git config core.fsyncObjectFiles true",
+	)
+
+	commits, _, err := r.Commits(vcs.CommitsOptions{Head: "master"})
+	if err != nil {
+		t.Fatalf("Commits: %s", err)
+	}
+	if len(commits) != 1 || commits[0].Message != "foo" {
+		t.Errorf("Commits: got %s, want 1 commit with message %q", asJSON(commits), "foo")
+	}
+
+	branches, err := r.Branches(vcs.BranchesOptions{})
+	if err != nil {
+		t.Fatalf("Branches: %s", err)
+	}
+	if len(branches) != 1 || branches[0].Name != "master" || branches[0].Head != commits[0].ID {
+		t.Errorf("Branches: got %s, want only master at %s", asJSON(branches), commits[0].ID)
+	}
+	tags, err := r.Tags()
+	if err != nil {
+		t.Fatalf("Tags: %s", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "t" || tags[0].CommitID != commits[0].ID {
+		t.Errorf("Tags: got %s, want only t at %s", asJSON(tags), commits[0].ID)
+	}
+
+	fs, err := r.FileSystem(commits[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fis, err := fs.ReadDir("dir")
+	if err != nil {
+		t.Fatalf("ReadDir: %s", err)
+	}
+	if len(fis) != 1 || fis[0].Name() != "f" || fis[0].Size() != 5 {
+		t.Errorf("ReadDir: got %d entries, want only f (5 bytes)", len(fis))
+	}
+	f, err := fs.Open("dir/f")
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("got file contents %q, want %q", data, "hello")
+	}
+}
+
 // initGitRepository initializes a new Git repository and runs cmds in a new
 // temporary directory (returned as dir).
 func initGitRepository(t testing.TB, cmds ...string) (dir string) {
@@ -1657,6 +3209,18 @@ func makeGitRepositoryLibGit2(t testing.TB, cmds ...string) *git.Repository {
 	return r
 }
 
+// makeGitRepositoryGoGit calls initGitRepository to create a new Git
+// repository and run cmds in it, and then returns the go-git-backed
+// repository.
+func makeGitRepositoryGoGit(t testing.TB, cmds ...string) *gogit.Repository {
+	dir := initGitRepository(t, cmds...)
+	r, err := gogit.Open(dir)
+	if err != nil {
+		t.Fatalf("gogit.Open(%q) failed: %s", dir, err)
+	}
+	return r
+}
+
 // initHgRepository initializes a new Hg repository and runs cmds in a new
 // temporary directory (returned as dir).
 func initHgRepository(t testing.TB, cmds ...string) (dir string) {
@@ -1696,6 +3260,67 @@ func makeHgRepositoryNative(t testing.TB, cmds ...string) *hg.Repository {
 	return r
 }
 
+// initBzrRepository initializes a new Bazaar branch and runs cmds in a
+// new temporary directory (returned as dir).
+func initBzrRepository(t testing.TB, cmds ...string) (dir string) {
+	dir = makeTmpDir(t, "bzr")
+	cmds = append([]string{"bzr init -q", "bzr whoami --branch 'a <a@a.com>'"}, cmds...)
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Command %q failed. Output was:\n\n%s", cmd, out)
+		}
+	}
+	return dir
+}
+
+// makeBzrRepositoryCmd calls initBzrRepository to create a new Bazaar
+// (cmd implementation) repository and run cmds in it, and then returns
+// the repository.
+func makeBzrRepositoryCmd(t testing.TB, cmds ...string) *bzrcmd.Repository {
+	dir := initBzrRepository(t, cmds...)
+	r, err := bzrcmd.Open(dir)
+	if err != nil {
+		t.Fatalf("bzrcmd.Open(%q) failed: %s", dir, err)
+	}
+	return r
+}
+
+// initSvnRepository creates a new svn repository in a new temporary
+// directory, checks it out to another new temporary directory
+// (returned as dir), and runs cmds in the working copy.
+func initSvnRepository(t testing.TB, cmds ...string) (dir string) {
+	repoDir := makeTmpDir(t, "svnrepo")
+	if out, err := exec.Command("svnadmin", "create", repoDir).CombinedOutput(); err != nil {
+		t.Fatalf("svnadmin create failed. Output was:\n\n%s", out)
+	}
+	dir = makeTmpDir(t, "svn")
+	cmds = append([]string{"svn checkout -q file://" + repoDir + " ."}, cmds...)
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		out, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Command %q failed. Output was:\n\n%s", cmd, out)
+		}
+	}
+	return dir
+}
+
+// makeSvnRepositoryCmd calls initSvnRepository to create a new svn
+// repository and run cmds in its working copy, and then returns the
+// repository.
+func makeSvnRepositoryCmd(t testing.TB, cmds ...string) *svncmd.Repository {
+	dir := initSvnRepository(t, cmds...)
+	r, err := svncmd.Open(dir)
+	if err != nil {
+		t.Fatalf("svncmd.Open(%q) failed: %s", dir, err)
+	}
+	return r
+}
+
 func commitsEqual(a, b *vcs.Commit) bool {
 	if (a == nil) != (b == nil) {
 		return false
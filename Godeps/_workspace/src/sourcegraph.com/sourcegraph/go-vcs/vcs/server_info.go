@@ -0,0 +1,11 @@
+package vcs
+
+// A ServerInfoUpdater is a repository that can update the auxiliary
+// files that "dumb" protocol clients (which fetch the repository's
+// files directly, without a git server process) use to find its refs
+// and packs.
+type ServerInfoUpdater interface {
+	// UpdateServerInfo updates the files that list the repository's
+	// refs and packs (like `git update-server-info`).
+	UpdateServerInfo() error
+}
@@ -0,0 +1,26 @@
+package vcs
+
+import (
+	"time"
+
+	"sourcegraph.com/sqs/pbtypes"
+)
+
+// NewSignature returns a signature with the given name, email, and
+// date. The date's time zone offset is recorded in TzOffset, so that
+// Time returns the date in its original time zone.
+func NewSignature(name, email string, date time.Time) Signature {
+	_, offset := date.Zone()
+	return Signature{
+		Name:     name,
+		Email:    email,
+		Date:     pbtypes.NewTimestamp(date),
+		TzOffset: int32(offset),
+	}
+}
+
+// Time returns the signature's date in its original time zone (see
+// TzOffset).
+func (s Signature) Time() time.Time {
+	return s.Date.Time().In(time.FixedZone("", int(s.TzOffset)))
+}
@@ -92,6 +92,16 @@ func (khs KnownHosts) Lookup(hostname string) (hostKeys []ssh.PublicKey, found b
 	return hostKeys, found
 }
 
+// KnownHostsName returns the name that known_hosts files list the SSH
+// server at host and port under: host itself for the default port
+// (22, or if port is empty), and "[host]:port" otherwise.
+func KnownHostsName(host, port string) string {
+	if port == "" || port == "22" {
+		return host
+	}
+	return "[" + host + "]:" + port
+}
+
 // ReadStandardKnownHostsFiles reads and parses the known_hosts files
 // at /etc/ssh/ssh_known_hosts and ~/.ssh/known_hosts.
 func ReadStandardKnownHostsFiles() (KnownHosts, error) {
@@ -31,6 +31,35 @@ xenon.stanford.edu,171.64.66.201 ssh-rsa AAAAB3NzaC1yc2EAAAABIwAAAQEAsWfJKexGQfH
 	}
 }
 
+func TestKnownHostsName(t *testing.T) {
+	tests := []struct {
+		host, port string
+		want       string
+	}{
+		{"example.com", "", "example.com"},
+		{"example.com", "22", "example.com"},
+		{"example.com", "2222", "[example.com]:2222"},
+		{"127.0.0.1", "41234", "[127.0.0.1]:41234"},
+	}
+	for _, test := range tests {
+		if got := KnownHostsName(test.host, test.port); got != test.want {
+			t.Errorf("KnownHostsName(%q, %q): got %q, want %q", test.host, test.port, got, test.want)
+		}
+	}
+
+	// Hosts on nonstandard ports are looked up by that name.
+	kh, err := ParseKnownHosts(strings.NewReader("[127.0.0.1]:41234 ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDGHQdtXMcknVwFzVfWlrrBI2T34kvisUq8UR2wL2/1r62RuUAj7iRbLDHU1qcBLQLaDS6YbcN5Jng9ILEDaxJxl/p+7jMSdSY4tUIoFSXUL4h870ddRNXgAegjXZScv1drwgRHp/b9Qm+raxPawEx5ajsmUllB+AjQX9z4cQAljbE5JeMxihPyswMQIAF/gKbTlhvLeVlif05sLHxyXVTbacIXO8H0XJZOXP3oiPQJ2RJWZkhYn8QvfWhig1he+dAJLJxwdHOwTAf1kHazh9gL50cbMDAV2cc8bOYR39XDdK75eGMvYWkgLD7TzftAgzS2JHGIRwXQoa6SvSLbXCP1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kh.Lookup(KnownHostsName("127.0.0.1", "41234")); !ok {
+		t.Error("got no host key for 127.0.0.1 port 41234, want 1")
+	}
+	if _, ok := kh.Lookup(KnownHostsName("127.0.0.1", "22")); ok {
+		t.Error("got host key for 127.0.0.1 port 22, want none")
+	}
+}
+
 func TestParseKnownHosts_invalidFormat(t *testing.T) {
 	data := `
 bad format
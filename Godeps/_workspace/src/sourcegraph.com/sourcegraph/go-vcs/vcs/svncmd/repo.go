@@ -0,0 +1,494 @@
+package svncmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/internal"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/util"
+)
+
+func init() {
+	vcs.RegisterOpener("svn", func(dir string) (vcs.Repository, error) {
+		return Open(dir)
+	})
+	vcs.RegisterCloner("svn", func(url, dir string, opt vcs.CloneOpt) (vcs.Repository, error) {
+		return CloneSvnRepository(url, dir, opt)
+	})
+}
+
+// CloneSvnRepository checks out the svn repository at url into
+// dir. Only the checkout's URL is used afterwards (all data is read
+// from the repository), so a bare clone is an empty checkout.
+func CloneSvnRepository(url, dir string, opt vcs.CloneOpt) (*Repository, error) {
+	args := []string{"checkout", "--non-interactive"}
+	if opt.Bare {
+		args = append(args, "--depth=empty")
+	}
+	args = append(args, "--", url, dir)
+	cmd := exec.Command("svn", args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("exec `svn checkout` failed: %s. Output was:\n\n%s", err, out)
+	}
+	return Open(dir)
+}
+
+func Open(dir string) (*Repository, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".svn")); err != nil {
+		return nil, &os.PathError{
+			Op:   "Open",
+			Path: filepath.Join(dir, ".svn"),
+			Err:  errors.New("Subversion working copy not found."),
+		}
+	}
+	return &Repository{Dir: dir}, nil
+}
+
+// Repository is a read-only view of the history of the svn repository
+// (or the subtree of it, such as trunk) that the working copy in Dir
+// was checked out from. Commit IDs are svn revision numbers (such as
+// "42"). History is linear, so each commit has at most one parent:
+// the previous revision that changed the subtree.
+type Repository struct {
+	Dir string
+
+	urlOnce sync.Once
+	url     string // the working copy's repository URL
+	urlErr  error
+}
+
+// repoURL returns the URL of the repository subtree that the working
+// copy was checked out from.
+func (r *Repository) repoURL() (string, error) {
+	r.urlOnce.Do(func() {
+		var info *svnInfoEntry
+		info, r.urlErr = r.info(".")
+		if r.urlErr == nil {
+			r.url = info.URL
+		}
+	})
+	return r.url, r.urlErr
+}
+
+// target returns the svn target (URL with peg revision) for path in
+// the repository at revision rev.
+func (r *Repository) target(path, rev string) (string, error) {
+	url, err := r.repoURL()
+	if err != nil {
+		return "", err
+	}
+	if path = internal.Rel(path); path != "." && path != "" {
+		url += "/" + path
+	}
+	return url + "@" + rev, nil
+}
+
+// isUnknownRevisionError returns whether output is svn's error
+// message for a nonexistent or invalid revision.
+func isUnknownRevisionError(output []byte) bool {
+	return bytes.Contains(output, []byte("E160006")) || // No such revision
+		bytes.Contains(output, []byte("E205000")) // Syntax error in revision argument
+}
+
+// isNotExistError returns whether output is svn's error message for
+// a path that doesn't exist in the revision.
+func isNotExistError(output []byte) bool {
+	return bytes.Contains(output, []byte("E160013")) || // path not found
+		bytes.Contains(output, []byte("W160013")) ||
+		bytes.Contains(output, []byte("W170000")) // non-existent in revision
+}
+
+// run runs svn with args in the working copy and returns its stdout
+// (or an error including its stderr).
+func (r *Repository) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("svn", append([]string{"--non-interactive"}, args...)...)
+	cmd.Dir = r.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if isUnknownRevisionError(stderr.Bytes()) {
+			return nil, vcs.ErrRevisionNotFound
+		}
+		if isNotExistError(stderr.Bytes()) {
+			return nil, os.ErrNotExist
+		}
+		return nil, fmt.Errorf("exec `svn %s` failed: %s. Output was:\n\n%s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// svnCommit is the "commit" or "logentry" element of svn's XML
+// output.
+type svnCommit struct {
+	Revision string `xml:"revision,attr"`
+	Author   string `xml:"author"`
+	Date     string `xml:"date"`
+	Msg      string `xml:"msg"`
+}
+
+func (c *svnCommit) date() (time.Time, error) {
+	if c.Date == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, c.Date)
+}
+
+type svnInfoEntry struct {
+	Kind   string    `xml:"kind,attr"`
+	URL    string    `xml:"url"`
+	Commit svnCommit `xml:"commit"`
+}
+
+// info returns `svn info` for target (a working copy path or URL).
+func (r *Repository) info(target string) (*svnInfoEntry, error) {
+	out, err := r.run("info", "--xml", "--", target)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Entries []*svnInfoEntry `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &info); err != nil {
+		return nil, err
+	}
+	if len(info.Entries) != 1 {
+		return nil, fmt.Errorf("svn info: expected 1 entry, got %d", len(info.Entries))
+	}
+	return info.Entries[0], nil
+}
+
+// ResolveRevision resolves a revision number (optionally prefixed
+// with "r", as in "r42") or "HEAD" to the last revision at or before
+// it that changed the repository subtree.
+func (r *Repository) ResolveRevision(spec string) (vcs.CommitID, error) {
+	if spec != "HEAD" {
+		spec = strings.TrimPrefix(spec, "r")
+		if _, err := strconv.ParseUint(spec, 10, 64); err != nil {
+			return "", vcs.ErrRevisionNotFound
+		}
+	}
+	target, err := r.target(".", spec)
+	if err != nil {
+		return "", err
+	}
+	info, err := r.info(target)
+	if err == os.ErrNotExist {
+		return "", vcs.ErrRevisionNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if info.Commit.Revision == "" || info.Commit.Revision == "0" {
+		return "", vcs.ErrRevisionNotFound
+	}
+	return vcs.CommitID(info.Commit.Revision), nil
+}
+
+// ResolveTag always returns vcs.ErrTagNotFound. svn tags are
+// directories, not refs.
+func (r *Repository) ResolveTag(name string) (vcs.CommitID, error) {
+	return "", vcs.ErrTagNotFound
+}
+
+// ResolveBranch always returns vcs.ErrBranchNotFound. svn branches
+// are directories, not refs.
+func (r *Repository) ResolveBranch(name string) (vcs.CommitID, error) {
+	return "", vcs.ErrBranchNotFound
+}
+
+func (r *Repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	return nil, nil
+}
+
+func (r *Repository) Tags() ([]*vcs.Tag, error) {
+	return nil, nil
+}
+
+// log returns the log entries (newest first) of path at rev and
+// earlier, up to limit entries (or all if limit is 0).
+func (r *Repository) log(path, rev string, limit uint) ([]*svnCommit, error) {
+	target, err := r.target(path, rev)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"log", "--xml", "--revision=" + rev + ":0"}
+	if limit != 0 {
+		args = append(args, "--limit="+strconv.FormatUint(uint64(limit), 10))
+	}
+	out, err := r.run(append(args, "--", target)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSvnLog(out)
+}
+
+// parseSvnLog parses the output of `svn log --xml`.
+func parseSvnLog(out []byte) ([]*svnCommit, error) {
+	var log struct {
+		Entries []*svnCommit `xml:"logentry"`
+	}
+	if err := xml.Unmarshal(out, &log); err != nil {
+		return nil, err
+	}
+	return log.Entries, nil
+}
+
+// makeCommits converts svn log entries (newest first) into commits,
+// each of whose parent is the next entry. The last entry is only
+// returned as the last commit's parent (not as a commit) if
+// lastIsParent.
+func makeCommits(entries []*svnCommit, lastIsParent bool) ([]*vcs.Commit, error) {
+	n := len(entries)
+	if lastIsParent && n > 0 {
+		n--
+	}
+	commits := make([]*vcs.Commit, n)
+	for i, e := range entries[:n] {
+		date, err := e.date()
+		if err != nil {
+			return nil, err
+		}
+		msg := strings.TrimRight(e.Msg, "\n")
+		subject, body := vcs.SplitMessage(msg)
+		commits[i] = &vcs.Commit{
+			ID:      vcs.CommitID(e.Revision),
+			Author:  vcs.NewSignature(e.Author, "", date),
+			Message: msg,
+			Subject: subject,
+			Body:    body,
+		}
+		if i+1 < len(entries) {
+			commits[i].Parents = []vcs.CommitID{vcs.CommitID(entries[i+1].Revision)}
+		}
+	}
+	return commits, nil
+}
+
+func (r *Repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
+	if _, err := strconv.ParseUint(string(id), 10, 64); err != nil {
+		return nil, vcs.ErrCommitNotFound
+	}
+
+	// Also get the parent.
+	entries, err := r.log(".", string(id), 2)
+	if err == vcs.ErrRevisionNotFound || err == os.ErrNotExist {
+		return nil, vcs.ErrCommitNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].Revision != string(id) {
+		// The revision exists but didn't change the subtree.
+		return nil, vcs.ErrCommitNotFound
+	}
+	commits, err := makeCommits(entries, len(entries) == 2)
+	if err != nil {
+		return nil, err
+	}
+	return commits[0], nil
+}
+
+// Commits lists the revisions that changed the subtree (or opt.Path
+// in it) at or before opt.Head. If opt.Path is set, each commit's
+// parent is the previous revision that changed the path (as with
+// `git log --parents -- PATH`).
+func (r *Repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	if opt.Cursor != "" {
+		return nil, 0, errors.New("svn: commits cursor not supported")
+	}
+	if opt.Follow {
+		return nil, 0, errors.New("svn: commits follow not supported")
+	}
+	if opt.Author != "" || !opt.Since.IsZero() || !opt.Until.IsZero() {
+		return nil, 0, errors.New("svn: commits author and date filters not supported")
+	}
+	if _, err := strconv.ParseUint(string(opt.Head), 10, 64); err != nil {
+		return nil, 0, vcs.ErrCommitNotFound
+	}
+	var base uint64
+	if opt.Base != "" {
+		var err error
+		base, err = strconv.ParseUint(string(opt.Base), 10, 64)
+		if err != nil {
+			return nil, 0, vcs.ErrCommitNotFound
+		}
+	}
+
+	// Only the needed entries (plus one for the last commit's parent)
+	// are read unless the total is needed.
+	var limit uint
+	if opt.NoTotal && opt.N != 0 && opt.Base == "" {
+		limit = opt.Skip + opt.N + 1
+	}
+	path := opt.Path
+	if path == "" {
+		path = "."
+	}
+	entries, err := r.log(path, string(opt.Head), limit)
+	if err == vcs.ErrRevisionNotFound || err == os.ErrNotExist {
+		return nil, 0, vcs.ErrCommitNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+	commits, err := makeCommits(entries, limit != 0 && uint(len(entries)) == limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// History is linear, so Base..Head is the revisions after Base.
+	if opt.Base != "" {
+		for i, c := range commits {
+			if rev, _ := strconv.ParseUint(string(c.ID), 10, 64); rev <= base {
+				commits = commits[:i]
+				break
+			}
+		}
+	}
+
+	var total uint
+	if !opt.NoTotal {
+		total = uint(len(commits))
+	}
+	if opt.Skip >= uint(len(commits)) {
+		return nil, total, nil
+	}
+	commits = commits[opt.Skip:]
+	if opt.N != 0 && opt.N < uint(len(commits)) {
+		commits = commits[:opt.N]
+	}
+	return commits, total, nil
+}
+
+func (r *Repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
+	return nil, fmt.Errorf("Committers() not implemented for vcs type: svn")
+}
+
+func (r *Repository) FileSystem(at vcs.CommitID) (vfs.FileSystem, error) {
+	if _, err := strconv.ParseUint(string(at), 10, 64); err != nil {
+		return nil, vcs.ErrCommitNotFound
+	}
+	return &svnFSCmd{
+		repo: r,
+		at:   at,
+	}, nil
+}
+
+type svnFSCmd struct {
+	repo *Repository
+	at   vcs.CommitID
+}
+
+func (fs *svnFSCmd) Open(name string) (vfs.ReadSeekCloser, error) {
+	target, err := fs.repo.target(name, string(fs.at))
+	if err != nil {
+		return nil, err
+	}
+	out, err := fs.repo.run("cat", "--", target)
+	if err == vcs.ErrRevisionNotFound {
+		return nil, vcs.ErrCommitNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return util.NopCloser{ReadSeeker: bytes.NewReader(out)}, nil
+}
+
+func (fs *svnFSCmd) Lstat(path string) (os.FileInfo, error) {
+	return fs.Stat(path)
+}
+
+func (fs *svnFSCmd) Stat(path string) (os.FileInfo, error) {
+	// TODO(sqs): follow symlinks (as Stat is required to do)
+
+	path = filepath.Clean(internal.Rel(path))
+	target, err := fs.repo.target(path, string(fs.at))
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.repo.info(target)
+	if err == os.ErrNotExist {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	} else if err == vcs.ErrRevisionNotFound {
+		return nil, vcs.ErrCommitNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	mtime, err := info.Commit.date()
+	if err != nil {
+		return nil, err
+	}
+	fi := &util.FileInfo{Name_: filepath.Base(path), ModTime_: mtime}
+	if info.Kind == "dir" {
+		fi.Mode_ = os.ModeDir
+	} else {
+		// svn info doesn't report sizes, so read the file.
+		f, err := fs.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		fi.Size_ = int64(len(data))
+	}
+	return fi, nil
+}
+
+func (fs *svnFSCmd) ReadDir(path string) ([]os.FileInfo, error) {
+	path = filepath.Clean(internal.Rel(path))
+	target, err := fs.repo.target(path, string(fs.at))
+	if err != nil {
+		return nil, err
+	}
+	out, err := fs.repo.run("list", "--xml", "--", target)
+	if err == os.ErrNotExist {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: os.ErrNotExist}
+	} else if err == vcs.ErrRevisionNotFound {
+		return nil, vcs.ErrCommitNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Entries []struct {
+			Kind   string    `xml:"kind,attr"`
+			Name   string    `xml:"name"`
+			Size   int64     `xml:"size"`
+			Commit svnCommit `xml:"commit"`
+		} `xml:"list>entry"`
+	}
+	if err := xml.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+	fis := make([]os.FileInfo, len(list.Entries))
+	for i, e := range list.Entries {
+		mtime, err := e.Commit.date()
+		if err != nil {
+			return nil, err
+		}
+		fi := &util.FileInfo{Name_: e.Name, Size_: e.Size, ModTime_: mtime}
+		if e.Kind == "dir" {
+			fi.Mode_ = os.ModeDir
+		}
+		fis[i] = fi
+	}
+	return fis, nil
+}
+
+func (fs *svnFSCmd) String() string {
+	return fmt.Sprintf("svn repository %s revision %s (cmd)", fs.repo.Dir, fs.at)
+}
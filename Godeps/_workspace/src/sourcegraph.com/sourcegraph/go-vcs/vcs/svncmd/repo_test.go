@@ -0,0 +1,75 @@
+package svncmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestMakeCommits(t *testing.T) {
+	out := `<?xml version="1.0" encoding="UTF-8"?>
+<log>
+<logentry
+   revision="5">
+<author>a</author>
+<date>2014-05-06T19:20:21.000000Z</date>
+<msg>bar
+
+body
+</msg>
+</logentry>
+<logentry
+   revision="2">
+<author>b</author>
+<date>2006-01-02T15:04:05.000000Z</date>
+<msg>foo</msg>
+</logentry>
+</log>
+`
+	entries, err := parseSvnLog([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := func(name, date string) vcs.Signature {
+		tm, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vcs.NewSignature(name, "", tm)
+	}
+	commit5 := &vcs.Commit{
+		ID:      "5",
+		Author:  sig("a", "2014-05-06T19:20:21Z"),
+		Message: "bar\n\nbody",
+		Parents: []vcs.CommitID{"2"},
+		Subject: "bar",
+		Body:    "body",
+	}
+	commit2 := &vcs.Commit{
+		ID:      "2",
+		Author:  sig("b", "2006-01-02T15:04:05Z"),
+		Message: "foo",
+		Subject: "foo",
+	}
+
+	tests := map[string]struct {
+		lastIsParent bool
+		want         []*vcs.Commit
+	}{
+		"all":            {false, []*vcs.Commit{commit5, commit2}},
+		"last is parent": {true, []*vcs.Commit{commit5}},
+	}
+	for label, test := range tests {
+		commits, err := makeCommits(entries, test.lastIsParent)
+		if err != nil {
+			t.Errorf("%s: %s", label, err)
+			continue
+		}
+		if !reflect.DeepEqual(commits, test.want) {
+			t.Errorf("%s: got commits %+v, want %+v", label, commits, test.want)
+		}
+	}
+}
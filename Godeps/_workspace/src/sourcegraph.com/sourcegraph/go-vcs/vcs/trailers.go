@@ -0,0 +1,156 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Trailers are the trailers of a commit message, such as
+// "Signed-off-by: a <a@a.com>" and "Co-authored-by: b <b@b.com>". It
+// maps each trailer token (as written in the message, so
+// "Co-authored-by" and "Co-Authored-By" are distinct) to the values
+// of the trailers with that token, in the order they appear.
+type Trailers map[string][]string
+
+// gitGeneratedTrailerPrefixes are the prefixes of trailer lines that
+// git itself generates. A trailer block containing one of them may
+// also contain some non-trailer lines (as in `git interpret-trailers`).
+var gitGeneratedTrailerPrefixes = []string{"Signed-off-by: ", "(cherry picked from commit "}
+
+// ParseTrailers parses the trailers from the last paragraph of the
+// commit message, following the rules of `git interpret-trailers
+// --parse`: the paragraph must not be the message's first paragraph
+// (the subject), and it must consist only of trailer lines ("Token:
+// value") and their continuation lines (which begin with whitespace
+// and are unfolded into the preceding value), unless it contains a
+// line that git generates (such as "Signed-off-by: "), in which case
+// at least 25% of its lines must be trailers. Non-trailer lines in
+// such a paragraph are ignored.
+//
+// If the message has no trailers, nil is returned.
+func ParseTrailers(message string) Trailers {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+
+	// Find the last paragraph, excluding the first paragraph.
+	start := -1
+	for i := len(lines) - 1; i > 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			start = i + 1
+			break
+		}
+	}
+	if start == -1 || start == len(lines) {
+		return nil
+	}
+	block := lines[start:]
+
+	var (
+		trailers         Trailers
+		tokens           []string // token of each trailer, in order
+		values           []string // value of each trailer, in order
+		numTrailers      int
+		numNonTrailers   int
+		hasGitGenerated  bool
+		lastWasTrailer   bool
+		lastTrailerIndex = -1
+	)
+	for _, line := range block {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			// Continuation of the preceding trailer's value.
+			if lastWasTrailer {
+				values[lastTrailerIndex] += " " + strings.TrimSpace(line)
+				continue
+			}
+			numNonTrailers++
+			continue
+		}
+		lastWasTrailer = false
+		for _, prefix := range gitGeneratedTrailerPrefixes {
+			if strings.HasPrefix(line, prefix) {
+				hasGitGenerated = true
+			}
+		}
+		token, value, ok := splitTrailer(line)
+		if !ok {
+			numNonTrailers++
+			continue
+		}
+		numTrailers++
+		tokens = append(tokens, token)
+		values = append(values, value)
+		lastWasTrailer = true
+		lastTrailerIndex = len(values) - 1
+	}
+
+	if numTrailers == 0 || (numNonTrailers > 0 && !(hasGitGenerated && numTrailers*3 >= numNonTrailers)) {
+		return nil
+	}
+	trailers = make(Trailers, len(tokens))
+	for i, token := range tokens {
+		trailers[token] = append(trailers[token], values[i])
+	}
+	return trailers
+}
+
+// splitTrailer splits a trailer line ("Token: value") into its token
+// and value. The token consists of letters, digits, and hyphens, and
+// may be followed by whitespace before the ":".
+func splitTrailer(line string) (token, value string, ok bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	token = strings.TrimRight(line[:i], " \t")
+	if token == "" {
+		return "", "", false
+	}
+	for _, c := range token {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return "", "", false
+		}
+	}
+	return token, strings.TrimSpace(line[i+1:]), true
+}
+
+// Marshal implements proto.Marshaler. The trailers are encoded as
+// "Token: value" lines, sorted by token.
+func (t Trailers) Marshal() ([]byte, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	tokens := make([]string, 0, len(t))
+	for token := range t {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var buf bytes.Buffer
+	for _, token := range tokens {
+		for _, value := range t[token] {
+			buf.WriteString(token)
+			buf.WriteString(": ")
+			buf.WriteString(value)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (t *Trailers) Unmarshal(data []byte) error {
+	*t = nil
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		token, value, ok := splitTrailer(s.Text())
+		if !ok {
+			continue
+		}
+		if *t == nil {
+			*t = Trailers{}
+		}
+		(*t)[token] = append((*t)[token], value)
+	}
+	return s.Err()
+}
@@ -0,0 +1,79 @@
+package vcs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestParseTrailers(t *testing.T) {
+	tests := map[string]struct {
+		message string
+		want    vcs.Trailers
+	}{
+		"subject only": {
+			message: "Signed-off-by: a <a@a.com>",
+			want:    nil,
+		},
+		"no trailers": {
+			message: "subject\n\nbody",
+			want:    nil,
+		},
+		"trailers": {
+			message: "subject\n\nbody\n\nCo-authored-by: b <b@b.com>\nCo-authored-by: c <c@c.com>\nSigned-off-by: a <a@a.com>\n",
+			want: vcs.Trailers{
+				"Co-authored-by": {"b <b@b.com>", "c <c@c.com>"},
+				"Signed-off-by":  {"a <a@a.com>"},
+			},
+		},
+		"continuation line": {
+			message: "subject\n\nFixes: a long\n  description",
+			want:    vcs.Trailers{"Fixes": {"a long description"}},
+		},
+		"not last paragraph": {
+			message: "subject\n\nSigned-off-by: a <a@a.com>\n\nbody",
+			want:    nil,
+		},
+		"mixed without git-generated trailer": {
+			message: "subject\n\nbody\nFixes: 123",
+			want:    nil,
+		},
+		"mixed with git-generated trailer": {
+			message: "subject\n\nbody\nSigned-off-by: a <a@a.com>",
+			want:    vcs.Trailers{"Signed-off-by": {"a <a@a.com>"}},
+		},
+		"token with whitespace": {
+			message: "subject\n\nnot a trailer: x",
+			want:    nil,
+		},
+	}
+	for label, test := range tests {
+		got := vcs.ParseTrailers(test.message)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", label, got, test.want)
+		}
+	}
+}
+
+func TestTrailers_Marshal(t *testing.T) {
+	trailers := vcs.Trailers{
+		"Signed-off-by":  {"a <a@a.com>"},
+		"Co-authored-by": {"b <b@b.com>", "c <c@c.com>"},
+	}
+	data, err := trailers.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Co-authored-by: b <b@b.com>\nCo-authored-by: c <c@c.com>\nSigned-off-by: a <a@a.com>\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+
+	var got vcs.Trailers
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, trailers) {
+		t.Errorf("got %v, want %v", got, trailers)
+	}
+}
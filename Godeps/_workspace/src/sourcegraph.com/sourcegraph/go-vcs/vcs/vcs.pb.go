@@ -36,6 +36,20 @@ type Commit struct {
 	Message   string     `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
 	// Parents are the commit IDs of this commit's parent commits.
 	Parents []CommitID `protobuf:"bytes,5,rep,name=parents,customtype=CommitID" json:"parents,omitempty"`
+	// Trailers are the trailers ("Signed-off-by: ...", etc.) parsed
+	// from the end of the commit message. The message itself is not
+	// modified.
+	Trailers Trailers `protobuf:"bytes,6,opt,name=trailers,customtype=Trailers" json:"trailers,omitempty"`
+	// Subject is the first paragraph of the commit message (with its
+	// lines joined by spaces), as in git's "%s" log format.
+	Subject string `protobuf:"bytes,7,opt,name=subject,proto3" json:"subject,omitempty"`
+	// Body is the commit message after the subject, as in git's "%b"
+	// log format.
+	Body string `protobuf:"bytes,8,opt,name=body,proto3" json:"body,omitempty"`
+	// Signed is whether the commit has a (GPG or other) signature,
+	// whether or not it is valid. It is only set if requested with
+	// CommitsOptions.IncludeSignatureStatus.
+	Signed bool `protobuf:"varint,9,opt,name=signed,proto3" json:"signed,omitempty"`
 }
 
 func (m *Commit) Reset()         { *m = Commit{} }
@@ -60,6 +74,9 @@ type Signature struct {
 	Name  string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	Email string            `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
 	Date  pbtypes.Timestamp `protobuf:"bytes,3,opt,name=date" json:"date"`
+	// TzOffset is the time zone offset of date, in seconds east of
+	// UTC (e.g., 32400 for +0900).
+	TzOffset int32 `protobuf:"varint,4,opt,name=tz_offset,proto3" json:"tz_offset,omitempty"`
 }
 
 func (m *Signature) Reset()         { *m = Signature{} }
@@ -84,6 +101,9 @@ type Branch struct {
 	Commit *Commit `protobuf:"bytes,4,opt,name=commit" json:"commit,omitempty"`
 	// Counts optionally contains the commit counts relative to specified branch.
 	Counts *BehindAhead `protobuf:"bytes,3,opt,name=counts" json:"counts,omitempty"`
+	// Upstream is the short name of the branch's upstream (tracking)
+	// branch, if any.
+	Upstream string `protobuf:"bytes,5,opt,name=upstream,proto3" json:"upstream,omitempty"`
 }
 
 func (m *Branch) Reset()         { *m = Branch{} }
@@ -129,7 +149,7 @@ type BranchesOptions struct {
 	BehindAheadBranch string `protobuf:"bytes,1,opt,name=behind_ahead_branch,proto3" json:"behind_ahead_branch,omitempty" url:",omitempty"`
 	// ContainsCommit filters the list of branches to only those that
 	// contain a specific commit ID (if set).
-	ContainsCommit string `protobuf:"bytes,3,opt,name=contains_commit,proto3" json:"contains_commit,omitempty" url:",omitempty"`
+	ContainsCommit CommitID `protobuf:"bytes,3,opt,name=contains_commit,proto3,customtype=CommitID" json:"contains_commit,omitempty" url:",omitempty"`
 }
 
 func (m *BranchesOptions) Reset()         { *m = BranchesOptions{} }
@@ -138,14 +158,36 @@ func (*BranchesOptions) ProtoMessage()    {}
 
 // A Tag is a VCS tag.
 type Tag struct {
-	Name     string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// CommitID is the ID of the commit that the tag points to. For
+	// annotated tags, it is the peeled commit ID, not the ID of the
+	// tag object.
+	//
+	// TODO(sqs): A git tag can point to other tags, or really any
+	// other object. How should we handle this case? For now, we're
+	// just assuming they're all commit IDs.
 	CommitID CommitID `protobuf:"bytes,2,opt,name=commit_id,proto3,customtype=CommitID" json:"commit_id,omitempty"`
+	// Annotated is whether this is an annotated tag (as opposed to a
+	// lightweight tag, which is just a ref to a commit).
+	Annotated bool `protobuf:"varint,5,opt,name=annotated,proto3" json:"annotated,omitempty"`
+	// Tagger is the signature of the person who created the tag. It
+	// is only set for annotated tags.
+	Tagger *Signature `protobuf:"bytes,3,opt,name=tagger" json:"tagger,omitempty"`
+	// Message is the annotated tag's message.
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
 }
 
 func (m *Tag) Reset()         { *m = Tag{} }
 func (m *Tag) String() string { return proto.CompactTextString(m) }
 func (*Tag) ProtoMessage()    {}
 
+func (m *Tag) GetTagger() *Signature {
+	if m != nil {
+		return m.Tagger
+	}
+	return nil
+}
+
 // SearchOptions specifies options for a repository search.
 type SearchOptions struct {
 	// the query string
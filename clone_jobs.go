@@ -0,0 +1,162 @@
+package vcsstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+// cloneJobRetention is how long a finished clone job is remembered, so
+// that clients polling it can see how it finished.
+const cloneJobRetention = time.Hour
+
+// An AsyncCloner clones repositories in the background, for clients
+// whose requests would time out waiting for large clones.
+type AsyncCloner interface {
+	// CloneAsync starts cloning the repository in the background (as
+	// Clone does, and without opening it) and returns the new clone
+	// job. If the repository is already being cloned by another job,
+	// that job is returned instead, so that a repository is never
+	// cloned twice at once.
+	CloneAsync(repoPath string, cloneInfo *vcsclient.CloneInfo) (*vcsclient.CloneJob, error)
+
+	// CloneJob returns the current state of the repository's clone
+	// job with the given ID. If there is no such job (or it finished
+	// more than an hour ago), vcsclient.ErrCloneJobNotExist is
+	// returned.
+	CloneJob(repoPath, id string) (*vcsclient.CloneJob, error)
+}
+
+// A cloneJob is a clone started by CloneAsync. It is an io.Writer
+// that records the latest of the progress messages written to it.
+type cloneJob struct {
+	key repoKey
+
+	mu       sync.Mutex
+	job      vcsclient.CloneJob
+	finished time.Time
+	partial  []byte // unterminated progress message
+}
+
+func (j *cloneJob) setState(state vcsclient.CloneJobState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.State = state
+}
+
+// finish records that the job is done (if err is nil) or failed.
+func (j *cloneJob) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err == nil {
+		j.job.State = vcsclient.CloneJobDone
+	} else {
+		j.job.State = vcsclient.CloneJobFailed
+		j.job.Error = err.Error()
+	}
+	j.finished = time.Now()
+}
+
+// expired returns whether the job finished more than
+// cloneJobRetention ago.
+func (j *cloneJob) expired() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finished.IsZero() && time.Since(j.finished) > cloneJobRetention
+}
+
+func (j *cloneJob) snapshot() *vcsclient.CloneJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	return &job
+}
+
+// Write records the last complete progress message in p (progress
+// messages are terminated by "\r" or "\n").
+func (j *cloneJob) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.partial = append(j.partial, p...)
+	for {
+		i := bytes.IndexAny(j.partial, "\r\n")
+		if i == -1 {
+			break
+		}
+		if msg := bytes.TrimSpace(j.partial[:i]); len(msg) > 0 {
+			j.job.Progress = string(msg)
+		}
+		j.partial = j.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *service) CloneAsync(repoPath string, cloneInfo *vcsclient.CloneInfo) (*vcsclient.CloneJob, error) {
+	cloneDir, err := s.CloneDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	key := repoKey{cloneDir}
+
+	s.cloneJobsMu.Lock()
+	defer s.cloneJobsMu.Unlock()
+	for id, job := range s.cloneJobs {
+		if job.expired() {
+			delete(s.cloneJobs, id)
+		}
+	}
+	if job := s.activeCloneJobs[key]; job != nil {
+		return job.snapshot(), nil
+	}
+
+	id, err := newCloneJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &cloneJob{key: key, job: vcsclient.CloneJob{ID: id, State: vcsclient.CloneJobQueued}}
+	s.cloneJobs[id] = job
+	s.activeCloneJobs[key] = job
+
+	go func() {
+		_, err := s.clone(repoPath, cloneInfo, job)
+		if err == nil {
+			s.Close(repoPath)
+		} else {
+			s.logf("Clone job %s for %s failed: %s.", id, repoPath, err)
+		}
+
+		s.cloneJobsMu.Lock()
+		defer s.cloneJobsMu.Unlock()
+		delete(s.activeCloneJobs, key)
+		job.finish(err)
+	}()
+
+	return job.snapshot(), nil
+}
+
+func (s *service) CloneJob(repoPath, id string) (*vcsclient.CloneJob, error) {
+	cloneDir, err := s.CloneDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cloneJobsMu.Lock()
+	defer s.cloneJobsMu.Unlock()
+	job := s.cloneJobs[id]
+	if job == nil || job.key != (repoKey{cloneDir}) || job.expired() {
+		return nil, vcsclient.ErrCloneJobNotExist
+	}
+	return job.snapshot(), nil
+}
+
+func newCloneJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -63,7 +63,7 @@ func (p *VCSDataProvider) Keys(keyPrefix string) ([]string, error) {
 		}
 
 		if info.Mode().IsDir() {
-			vcsTypes := []string{"git", "hg"}
+			vcsTypes := []string{"git", "hg", "bzr", "svn"}
 			for _, vcsType := range vcsTypes {
 				_, err := vcs.Open(vcsType, path)
 				if err == nil {
@@ -9,33 +9,47 @@ import "C"
 
 import (
 	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	_ "expvar"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/lox/httpcache"
+	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
-	_ "sourcegraph.com/sourcegraph/go-vcs/vcs/git"
+	_ "sourcegraph.com/sourcegraph/go-vcs/vcs/bzrcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/git"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gogit"
 	_ "sourcegraph.com/sourcegraph/go-vcs/vcs/hg"
+	_ "sourcegraph.com/sourcegraph/go-vcs/vcs/svncmd"
 	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/server"
 	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 var (
-	storageDir = flag.String("s", "/tmp/vcsstore", "storage root dir for VCS repos")
-	verbose    = flag.Bool("v", true, "show verbose output")
+	storageDir    = flag.String("s", "/tmp/vcsstore", "storage root dir for VCS repos")
+	hashedStorage = flag.Bool("s.hashed", false, "store each repo in a dir named by the SHA-256 hash of its repo ID (must not be changed for an existing storage dir)")
+	verbose       = flag.Bool("v", true, "show verbose output")
 
 	defaultPort = "9090"
 )
@@ -97,11 +111,34 @@ var subcommands = []subcommand{
 func serveCmd(args []string) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	debug := fs.Bool("d", false, "debug mode (don't use on publicly available servers)")
-	bindAddr := fs.String("http", ":"+defaultPort, "HTTP listen address")
+	bindAddr := fs.String("http", ":"+defaultPort, "HTTP listen address (if empty, only listen on -unix)")
+	unixSocket := fs.String("unix", "", "Unix domain socket path to listen on, in addition to -http (datad clustering still requires a TCP -http address)")
 	tlsCert := fs.String("tls.cert", "", "TLS certificate file (if set, server uses TLS)")
 	tlsKey := fs.String("tls.key", "", "TLS key file (if set, server uses TLS)")
+	tlsClientCA := fs.String("tls.clientca", "", "TLS client CA certificates file (if set, require clients to present a certificate signed by one of these CAs; requires -tls.cert and -tls.key)")
 	basicAuth := fs.String("http.basicauth", "", "if set to 'user:passwd', require HTTP Basic Auth")
+	authTokens := fs.String("auth.tokens", "", "if set, require a bearer token (one of those listed, one per line, in this file) for repository operations")
 	cache := fs.String("cache", "", "HTTP cache (either 'mem' or 'disk:/path/to/cache/dir')")
+	rateLimit := fs.Float64("ratelimit", 0, "max requests per second per client, on average (0 means unlimited)")
+	rateLimitBurst := fs.Int("ratelimit.burst", 0, "max burst of requests per client (0 means the same as -ratelimit)")
+	metrics := fs.Bool("metrics", true, "serve Prometheus metrics at /metrics")
+	maxOpenRepos := fs.Int("repos.maxopen", 0, "max repositories to keep open, including idle ones (0 means a default limit, negative means close them as soon as they are unused)")
+	maxStorage := fs.Int64("storage.max", 0, "max total size in bytes of the stored repositories; clones that would exceed it fail (0 means unlimited)")
+	evictForStorage := fs.Bool("storage.evict", false, "when a clone would exceed -storage.max, delete the least recently accessed repositories to make room instead of failing")
+	partialClone := fs.Bool("git.partialclone", false, "allow git clients to make partial clones (such as with --filter=blob:none)")
+	commitGraph := fs.Bool("git.commitgraph", false, "write each repository's git commit-graph file after clones, updates, and pushes (so commit graph responses can include generation numbers)")
+	dumbHTTP := fs.Bool("git.dumbhttp", false, "also serve the dumb HTTP git protocol (for clients without smart HTTP support)")
+	tmpMaxAge := fs.Duration("tmp.maxage", 0, "remove temporary dirs in the storage dir (such as those of interrupted clones) that are unmodified for this long (0 means 1h, negative means never)")
+	tmpSweep := fs.Duration("tmp.sweep", 10*time.Minute, "how often to remove stale temporary dirs (0 means only at startup)")
+	cacheLong := fs.Duration("cachecontrol.long", 0, "Cache-Control max-age of responses that never change, such as those for full commit IDs (0 means 1 year)")
+	cacheShort := fs.Duration("cachecontrol.short", 0, "Cache-Control max-age of responses that may change, such as those for branches (0 means 7s)")
+	cacheRoutes := fs.String("cachecontrol.routes", "", "comma-separated per-route overrides of -cachecontrol.long and -cachecontrol.short, each 'route=long/short' (such as 'vcs:repo.tree-entry=24h/1m'; an empty duration means the global one)")
+	requestLog := fs.String("log.requests", "", "log each request (with its request ID) to stderr in 'text' or 'json' format (empty means don't)")
+	corsOrigins := fs.String("cors.origins", "", "comma-separated origins (or '*' for all) from which browsers may make cross-origin requests (empty means none)")
+	corsMethods := fs.String("cors.methods", "", "comma-separated HTTP methods that cross-origin requests may use (empty means GET, HEAD, POST, PUT, and DELETE)")
+	corsHeaders := fs.String("cors.headers", "", "comma-separated request headers that cross-origin requests may set (empty means a default list)")
+	maxContentsSize := fs.Int64("tree.maxcontents", 0, "max size in bytes of file contents included in tree entry responses, unless the client requests a range or the entire file (0 means unlimited)")
+	gitBackend := fs.String("git.backend", "libgit2", "git repository implementation ('libgit2', 'gitcmd', or 'gogit')")
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, `usage: vcsstore serve [options]
 
@@ -118,6 +155,21 @@ The options are:
 		fs.Usage()
 	}
 
+	// The libgit2 and gitcmd packages both register themselves as
+	// the "git" opener in their init funcs, so the winner would
+	// depend on package init order. Register the chosen one
+	// explicitly.
+	switch *gitBackend {
+	case "libgit2":
+		vcs.RegisterOpener("git", func(dir string) (vcs.Repository, error) { return git.Open(dir) })
+	case "gitcmd":
+		vcs.RegisterOpener("git", func(dir string) (vcs.Repository, error) { return gitcmd.Open(dir) })
+	case "gogit":
+		vcs.RegisterOpener("git", func(dir string) (vcs.Repository, error) { return gogit.Open(dir) })
+	default:
+		log.Fatalf("Unknown git backend %q (must be 'libgit2', 'gitcmd', or 'gogit').", *gitBackend)
+	}
+
 	err := os.MkdirAll(*storageDir, 0700)
 	if err != nil {
 		log.Fatalf("Error creating directory %q: %s.", *storageDir, err)
@@ -131,16 +183,59 @@ The options are:
 	}
 
 	conf := &vcsstore.Config{
-		StorageDir: *storageDir,
-		Log:        log.New(logw, "vcsstore: ", log.LstdFlags),
+		StorageDir:          *storageDir,
+		HashedStorage:       *hashedStorage,
+		Log:                 log.New(logw, "vcsstore: ", log.LstdFlags),
+		RateLimit:           *rateLimit,
+		RateLimitBurst:      *rateLimitBurst,
+		MaxOpenRepos:        *maxOpenRepos,
+		MaxStorageBytes:     *maxStorage,
+		EvictForStorage:     *evictForStorage,
+		AllowPartialClone:   *partialClone,
+		AllowDumbHTTP:       *dumbHTTP,
+		TmpDirMaxAge:        *tmpMaxAge,
+		TmpDirSweepInterval: *tmpSweep,
+		CacheMaxAges:        vcsstore.CacheMaxAges{Long: *cacheLong, Short: *cacheShort},
+		CORSAllowedOrigins:  splitList(*corsOrigins),
+		CORSAllowedMethods:  splitList(*corsMethods),
+		CORSAllowedHeaders:  splitList(*corsHeaders),
 	}
 	if *debug {
 		conf.DebugLog = log.New(logw, "vcsstore DEBUG: ", log.LstdFlags)
 	}
+	if conf.RouteCacheMaxAges, err = parseRouteCacheMaxAges(*cacheRoutes); err != nil {
+		log.Fatalf("Invalid -cachecontrol.routes: %s.", err)
+	}
+	switch *requestLog {
+	case "":
+	case "text":
+		conf.RequestLog = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	case "json":
+		conf.RequestLog = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	default:
+		log.Fatalf("Unknown request log format %q (must be 'text' or 'json').", *requestLog)
+	}
 
 	vh := server.NewHandler(vcsstore.NewService(conf), server.NewGitTransporter(conf), nil)
 	vh.Log = log.New(logw, "server: ", log.LstdFlags)
 	vh.Debug = *debug
+	vh.Metrics = *metrics
+	vh.MaxContentsSize = *maxContentsSize
+	vh.WriteCommitGraphs = *commitGraph
+	vh.UpdateServerInfo = conf.AllowDumbHTTP
+	vh.RateLimiter = server.NewRateLimiter(conf)
+	vh.CacheMaxAges = conf.CacheMaxAges
+	vh.RouteCacheMaxAges = conf.RouteCacheMaxAges
+	vh.RequestLog = conf.RequestLog
+	vh.CORS = server.NewCORS(conf)
+	if *authTokens != "" {
+		tokens, err := readTokensFile(*authTokens)
+		if err != nil {
+			log.Fatalf("Error reading auth tokens file %q: %s.", *authTokens, err)
+		}
+		log.Printf("Requiring bearer token auth (%d tokens)", len(tokens))
+		vh.Authenticator = &server.BearerTokenAuthenticator{Tokens: tokens}
+	}
 
 	var h http.Handler
 	if *basicAuth != "" {
@@ -160,15 +255,93 @@ The options are:
 	h = cacheHandler(*cache, h)
 	http.Handle("/", handlers.CombinedLoggingHandler(os.Stderr, h))
 
+	if *unixSocket != "" {
+		// The socket is served without TLS; access to it is controlled
+		// by the socket file's permissions.
+		ln, err := listenUnix(*unixSocket)
+		if err != nil {
+			log.Fatalf("Error listening on Unix socket %q: %s.", *unixSocket, err)
+		}
+		fmt.Fprintf(os.Stderr, "Starting HTTP server on Unix socket %s\n", *unixSocket)
+		if *bindAddr == "" {
+			log.Fatal(http.Serve(ln, nil))
+		}
+		go func() {
+			log.Fatal(http.Serve(ln, nil))
+		}()
+	} else if *bindAddr == "" {
+		log.Fatalf("At least one of -http and -unix must be set.")
+	}
+
 	if *tlsCert != "" || *tlsKey != "" {
+		// HTTP/2 is enabled automatically when serving TLS.
+		srv := &http.Server{Addr: *bindAddr}
+		if *tlsClientCA != "" {
+			srv.TLSConfig, err = clientCATLSConfig(*tlsClientCA)
+			if err != nil {
+				log.Fatalf("Error reading TLS client CA file %q: %s.", *tlsClientCA, err)
+			}
+			log.Printf("Requiring TLS client certificates (CAs in %s)", *tlsClientCA)
+		}
 		fmt.Fprintf(os.Stderr, "Starting HTTPS server on %s (cert %s, key %s)\n", *bindAddr, *tlsCert, *tlsKey)
-		log.Fatal(http.ListenAndServeTLS(*bindAddr, *tlsCert, *tlsKey, nil))
+		log.Fatal(srv.ListenAndServeTLS(*tlsCert, *tlsKey))
 	} else {
+		if *tlsClientCA != "" {
+			log.Fatalf("The -tls.clientca option requires -tls.cert and -tls.key.")
+		}
 		fmt.Fprintf(os.Stderr, "Starting HTTP server on %s\n", *bindAddr)
 		log.Fatal(http.ListenAndServe(*bindAddr, nil))
 	}
 }
 
+// listenUnix listens on the Unix domain socket at path. The socket
+// file is removed when the process is interrupted or terminated.
+func listenUnix(path string) (net.Listener, error) {
+	// Remove a stale socket file left by a server that didn't exit
+	// cleanly, but not one that a running server is listening on.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another server is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigc
+		os.Remove(path)
+		log.Fatalf("Received %s, exiting.", sig)
+	}()
+	return ln, nil
+}
+
+// clientCATLSConfig returns a TLS config that requires clients to
+// present a certificate signed by one of the CAs in the PEM-encoded
+// caFile.
+func clientCATLSConfig(caFile string) (*tls.Config, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no PEM-encoded certificates found")
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
 func cacheHandler(cacheOpt string, h http.Handler) http.Handler {
 	if cacheOpt == "" {
 		return h
@@ -193,6 +366,78 @@ func cacheHandler(cacheOpt string, h http.Handler) http.Handler {
 	return ch
 }
 
+// readTokensFile reads a file containing one token per line. Blank
+// lines and lines starting with '#' are ignored.
+func readTokensFile(name string) ([]string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	return tokens, nil
+}
+
+// splitList splits a comma-separated list, omitting empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseRouteCacheMaxAges parses the -cachecontrol.routes flag value:
+// a comma-separated list of "route=long/short" overrides, where route
+// is the name of a vcsclient route and either duration may be empty.
+func parseRouteCacheMaxAges(s string) (map[string]vcsstore.CacheMaxAges, error) {
+	var m map[string]vcsstore.CacheMaxAges
+	router := (*mux.Router)(vcsclient.NewRouter(nil))
+	for _, v := range splitList(s) {
+		route, durs, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("override %q is not of the form route=long/short", v)
+		}
+		if router.Get(route) == nil {
+			return nil, fmt.Errorf("unknown route %q", route)
+		}
+		long, short, ok := strings.Cut(durs, "/")
+		if !ok {
+			return nil, fmt.Errorf("override %q is not of the form route=long/short", v)
+		}
+		var maxAges vcsstore.CacheMaxAges
+		for _, d := range []struct {
+			s   string
+			dur *time.Duration
+		}{{long, &maxAges.Long}, {short, &maxAges.Short}} {
+			if d.s == "" {
+				continue
+			}
+			dur, err := time.ParseDuration(d.s)
+			if err != nil {
+				return nil, err
+			}
+			if dur < 0 {
+				return nil, fmt.Errorf("negative max-age %s for route %q", dur, route)
+			}
+			*d.dur = dur
+		}
+		if m == nil {
+			m = map[string]vcsstore.CacheMaxAges{}
+		}
+		m[route] = maxAges
+	}
+	return m, nil
+}
+
 func newBasicAuthHandler(user, passwd string, h http.Handler) http.Handler {
 	want := "Basic " + base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", user, passwd)))
 	return &basicAuthHandler{h, []byte(want)}
@@ -234,14 +479,21 @@ The options are:
 
 	repoPath := fs.Arg(0)
 
-	fmt.Println("RepositoryPath:      ", filepath.Join(*storageDir, vcsstore.EncodeRepositoryPath(repoPath)))
+	conf := &vcsstore.Config{StorageDir: *storageDir, HashedStorage: *hashedStorage}
+	cloneDir, err := conf.CloneDir(repoPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("RepositoryPath:      ", cloneDir)
 	fmt.Println("URL:                 ", vcsclient.NewRouter(nil).URLToRepo(repoPath))
 }
 
 func cloneCmd(args []string) {
 	fs := flag.NewFlagSet("clone", flag.ExitOnError)
-	urlStr := fs.String("url", "http://localhost:"+defaultPort, "base URL to a running vcsstore API server")
+	urlStr := fs.String("url", "http://localhost:"+defaultPort, "base URL to a running vcsstore API server (or 'unix:/path/to/socket')")
 	sshKeyFile := fs.String("i", "", "ssh private key file for clone remote")
+	knownHostsFile := fs.String("known-hosts", "", "ssh known_hosts file to verify the clone remote's host key against")
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, `usage: vcsstore clone [options] repo-id vcs-type clone-url
 
@@ -259,7 +511,7 @@ The options are:
 		fs.Usage()
 	}
 
-	baseURL, err := url.Parse(*urlStr)
+	c, err := vcsclient.NewFromURL(*urlStr)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -271,7 +523,6 @@ The options are:
 	}
 
 	var repo vcs.Repository
-	c := vcsclient.New(baseURL, nil)
 	repo, err = c.Repository(repoPath)
 	if err != nil {
 		log.Fatal("Open repository: ", err)
@@ -285,6 +536,16 @@ The options are:
 		}
 		opt.SSH = &vcs.SSHConfig{PrivateKey: key}
 	}
+	if *knownHostsFile != "" {
+		knownHosts, err := ioutil.ReadFile(*knownHostsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if opt.SSH == nil {
+			opt.SSH = &vcs.SSHConfig{}
+		}
+		opt.SSH.KnownHosts = knownHosts
+	}
 
 	if repo, ok := repo.(vcsclient.RepositoryCloneUpdater); ok {
 		err := repo.CloneOrUpdate(&vcsclient.CloneInfo{
@@ -302,7 +563,7 @@ The options are:
 
 func getCmd(args []string) {
 	fs := flag.NewFlagSet("get", flag.ExitOnError)
-	urlStr := fs.String("url", "http://localhost:"+defaultPort, "base URL to a running vcsstore API server")
+	urlStr := fs.String("url", "http://localhost:"+defaultPort, "base URL to a running vcsstore API server (or 'unix:/path/to/socket')")
 	method := fs.String("method", "GET", "HTTP request method")
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, `usage: vcsstore get [options] repo-id [extra-path]
@@ -325,6 +586,11 @@ The options are:
 		extraPath = fs.Arg(1)
 	}
 
+	var hc *http.Client
+	if socketPath := strings.TrimPrefix(*urlStr, "unix:"); socketPath != *urlStr {
+		hc = vcsclient.UnixSocketHTTPClient(socketPath)
+		*urlStr = "http://unix/"
+	}
 	baseURL, err := url.Parse(*urlStr)
 	if err != nil {
 		log.Fatal(err)
@@ -336,7 +602,7 @@ The options are:
 	url = baseURL.ResolveReference(url)
 	url.Path = filepath.Join(url.Path, extraPath)
 
-	normalGet(*method, nil, url)
+	normalGet(*method, hc, url)
 }
 
 func normalGet(method string, c *http.Client, url *url.URL) {
@@ -0,0 +1,122 @@
+package vcsstore
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// defaultCommitCountCacheSize is the number of commit counts cached
+// if Config.CommitCountCacheSize is 0.
+const defaultCommitCountCacheSize = 10000
+
+// A CommitCountCache memoizes the total number of commits returned by
+// a repository's Commits method, which is expensive to compute on
+// large repositories.
+type CommitCountCache interface {
+	// CommitCount returns the cached total number of commits for
+	// the repository and options, and whether it was cached.
+	CommitCount(repoPath string, opt vcs.CommitsOptions) (total uint, ok bool)
+
+	// SetCommitCount caches the total number of commits for the
+	// repository and options. It is a no-op if the count for opt
+	// could change (e.g., if opt.Head is a branch name and not a
+	// commit ID).
+	SetCommitCount(repoPath string, opt vcs.CommitsOptions, total uint)
+}
+
+// commitCountKey identifies a commit count. The N, Skip, and Cursor
+// options don't affect the total, so they are not part of the key.
+type commitCountKey struct {
+	repoPath   string
+	head, base vcs.CommitID
+	path       string
+	follow     bool
+	author     string
+	since      time.Time // in UTC, so that equal times are equal keys
+	until      time.Time
+}
+
+// newCommitCountKey returns the cache key for the repository and
+// options, and whether the count they identify is immutable (and
+// therefore cacheable).
+func newCommitCountKey(repoPath string, opt vcs.CommitsOptions) (commitCountKey, bool) {
+	key := commitCountKey{
+		repoPath: repoPath,
+		head:     opt.Head,
+		base:     opt.Base,
+		path:     opt.Path,
+		follow:   opt.Follow,
+		author:   opt.Author,
+		since:    opt.Since.UTC(),
+		until:    opt.Until.UTC(),
+	}
+	return key, isCanonicalCommitID(opt.Head) && (opt.Base == "" || isCanonicalCommitID(opt.Base))
+}
+
+// isCanonicalCommitID returns whether id is a full 40-character
+// commit ID (as opposed to an abbreviated ID or a symbolic revision
+// such as a branch name).
+func isCanonicalCommitID(id vcs.CommitID) bool {
+	return len(id) == 40 && isLowercaseHex(string(id))
+}
+
+// commitCountCache is an LRU CommitCountCache.
+type commitCountCache struct {
+	size int
+
+	mu      sync.Mutex
+	ll      *list.List // of *commitCountEntry, most recently used first
+	entries map[commitCountKey]*list.Element
+}
+
+type commitCountEntry struct {
+	key   commitCountKey
+	total uint
+}
+
+func newCommitCountCache(size int) *commitCountCache {
+	return &commitCountCache{
+		size:    size,
+		ll:      list.New(),
+		entries: map[commitCountKey]*list.Element{},
+	}
+}
+
+func (c *commitCountCache) CommitCount(repoPath string, opt vcs.CommitsOptions) (uint, bool) {
+	key, ok := newCommitCountKey(repoPath, opt)
+	if !ok {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[key]; present {
+		c.ll.MoveToFront(e)
+		return e.Value.(*commitCountEntry).total, true
+	}
+	return 0, false
+}
+
+func (c *commitCountCache) SetCommitCount(repoPath string, opt vcs.CommitsOptions, total uint) {
+	key, ok := newCommitCountKey(repoPath, opt)
+	if !ok || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[key]; present {
+		c.ll.MoveToFront(e)
+		e.Value.(*commitCountEntry).total = total
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&commitCountEntry{key: key, total: total})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*commitCountEntry).key)
+	}
+}
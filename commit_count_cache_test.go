@@ -0,0 +1,75 @@
+package vcsstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestCommitCountCache(t *testing.T) {
+	c := newCommitCountCache(2)
+
+	a := vcs.CommitsOptions{Head: vcs.CommitID(strings.Repeat("a", 40))}
+	b := vcs.CommitsOptions{Head: vcs.CommitID(strings.Repeat("b", 40))}
+	d := vcs.CommitsOptions{Head: vcs.CommitID(strings.Repeat("d", 40))}
+
+	c.SetCommitCount("r", a, 1)
+	c.SetCommitCount("r", b, 2)
+	if total, ok := c.CommitCount("r", a); !ok || total != 1 {
+		t.Errorf("got count (%d, %v), want (1, true)", total, ok)
+	}
+
+	// a was used more recently than b, so b is evicted.
+	c.SetCommitCount("r", d, 3)
+	if _, ok := c.CommitCount("r", b); ok {
+		t.Error("got b cached, want evicted")
+	}
+	if total, ok := c.CommitCount("r", d); !ok || total != 3 {
+		t.Errorf("got count (%d, %v), want (3, true)", total, ok)
+	}
+
+	// N and Skip don't affect the total.
+	aPage := a
+	aPage.N, aPage.Skip = 10, 20
+	if total, ok := c.CommitCount("r", aPage); !ok || total != 1 {
+		t.Errorf("got count (%d, %v), want (1, true)", total, ok)
+	}
+
+	// Filters do affect the total.
+	aAuthor := a
+	aAuthor.Author = "alice"
+	if _, ok := c.CommitCount("r", aAuthor); ok {
+		t.Error("got a with author filter cached, want not cached")
+	}
+	since := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	aSince := a
+	aSince.Since = since
+	c.SetCommitCount("r", aSince, 4)
+	aSince.Since = since.In(time.FixedZone("", -7*60*60))
+	if total, ok := c.CommitCount("r", aSince); !ok || total != 4 {
+		t.Errorf("got count (%d, %v) for the same time in another zone, want (4, true)", total, ok)
+	}
+
+	// Counts are per-repository.
+	if _, ok := c.CommitCount("other", a); ok {
+		t.Error("got a cached in other repo, want not cached")
+	}
+}
+
+func TestCommitCountCache_notCanonical(t *testing.T) {
+	c := newCommitCountCache(10)
+
+	opts := []vcs.CommitsOptions{
+		{Head: "master"},
+		{Head: "abcd"},
+		{Head: vcs.CommitID(strings.Repeat("a", 40)), Base: "master"},
+	}
+	for _, opt := range opts {
+		c.SetCommitCount("r", opt, 1)
+		if _, ok := c.CommitCount("r", opt); ok {
+			t.Errorf("%+v: got cached, want not cached", opt)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package vcsstore
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// defaultDiffCacheSize is the total size in bytes of the diffs cached
+// if Config.DiffCacheSize is 0.
+const defaultDiffCacheSize = 64 << 20
+
+// A DiffCache memoizes diffs between commits, which are expensive to
+// compute for large changes and are often requested repeatedly (for
+// example, by each viewer of a pull request).
+type DiffCache interface {
+	// Diff returns the cached diff of the repository between base and
+	// head with the options, and whether it was cached. The returned
+	// diff must not be modified.
+	Diff(repoPath string, base, head vcs.CommitID, opt *vcs.DiffOptions) (diff *vcs.Diff, ok bool)
+
+	// SetDiff caches the diff of the repository between base and head
+	// with the options. It is a no-op if the diff could change (i.e.,
+	// if base or head is not a full commit ID). The diff must not be
+	// modified afterwards.
+	SetDiff(repoPath string, base, head vcs.CommitID, opt *vcs.DiffOptions, diff *vcs.Diff)
+}
+
+// diffKey identifies a diff. Options that don't change git's output
+// (such as the order of the paths, or a rename threshold when renames
+// aren't detected) are normalized, so that equivalent requests share
+// an entry.
+type diffKey struct {
+	repoPath   string
+	base, head vcs.CommitID
+
+	paths                    string // sorted and NUL-separated
+	origPrefix, newPrefix    string
+	renameThreshold          int // 0 if renames aren't detected
+	detectCopies             bool
+	ignoreWhitespace         bool
+	ignoreWhitespaceChange   bool
+	excludeReachableFromBoth bool
+}
+
+// newDiffKey returns the cache key for the repository, commits, and
+// options, and whether the diff they identify is immutable (and
+// therefore cacheable).
+func newDiffKey(repoPath string, base, head vcs.CommitID, opt *vcs.DiffOptions) (diffKey, bool) {
+	key := diffKey{repoPath: repoPath, base: base, head: head}
+	if opt != nil {
+		paths := append([]string(nil), opt.Paths...)
+		sort.Strings(paths)
+		key.paths = strings.Join(paths, "\x00")
+		key.origPrefix, key.newPrefix = opt.OrigPrefix, opt.NewPrefix
+		if opt.DetectRenames || opt.RenameThreshold != 0 || opt.DetectCopies {
+			key.renameThreshold = opt.RenameThreshold
+			if key.renameThreshold == 0 {
+				key.renameThreshold = 50 // git's default
+			}
+		}
+		key.detectCopies = opt.DetectCopies
+		key.ignoreWhitespace = opt.IgnoreWhitespace
+		key.ignoreWhitespaceChange = opt.IgnoreWhitespaceChange && !opt.IgnoreWhitespace // -w implies -b
+		key.excludeReachableFromBoth = opt.ExcludeReachableFromBoth
+	}
+	return key, isCanonicalCommitID(base) && isCanonicalCommitID(head)
+}
+
+// diffSize returns the approximate number of bytes that diff occupies.
+func diffSize(diff *vcs.Diff) int64 {
+	size := int64(len(diff.Raw))
+	for _, r := range diff.Renames {
+		size += int64(len(r.OrigPath) + len(r.Path))
+	}
+	return size
+}
+
+// diffCache is an LRU DiffCache whose entries' diffs total at most
+// size bytes.
+type diffCache struct {
+	size int64
+
+	mu      sync.Mutex
+	used    int64      // total size of the cached diffs
+	ll      *list.List // of *diffEntry, most recently used first
+	entries map[diffKey]*list.Element
+}
+
+type diffEntry struct {
+	key  diffKey
+	diff *vcs.Diff
+	size int64
+}
+
+func newDiffCache(size int64) *diffCache {
+	return &diffCache{
+		size:    size,
+		ll:      list.New(),
+		entries: map[diffKey]*list.Element{},
+	}
+}
+
+func (c *diffCache) Diff(repoPath string, base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, bool) {
+	key, ok := newDiffKey(repoPath, base, head, opt)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[key]; present {
+		c.ll.MoveToFront(e)
+		return e.Value.(*diffEntry).diff, true
+	}
+	return nil, false
+}
+
+func (c *diffCache) SetDiff(repoPath string, base, head vcs.CommitID, opt *vcs.DiffOptions, diff *vcs.Diff) {
+	key, ok := newDiffKey(repoPath, base, head, opt)
+	size := diffSize(diff)
+	if !ok || c.size <= 0 || size > c.size {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, present := c.entries[key]; present {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*diffEntry)
+		c.used += size - entry.size
+		entry.diff, entry.size = diff, size
+	} else {
+		c.entries[key] = c.ll.PushFront(&diffEntry{key: key, diff: diff, size: size})
+		c.used += size
+	}
+	for c.used > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		entry := oldest.Value.(*diffEntry)
+		delete(c.entries, entry.key)
+		c.used -= entry.size
+	}
+}
@@ -0,0 +1,93 @@
+package vcsstore
+
+import (
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestDiffCache(t *testing.T) {
+	c := newDiffCache(10)
+
+	a, b, d := vcs.CommitID(strings.Repeat("a", 40)), vcs.CommitID(strings.Repeat("b", 40)), vcs.CommitID(strings.Repeat("d", 40))
+
+	c.SetDiff("r", a, b, nil, &vcs.Diff{Raw: "ab123"})
+	c.SetDiff("r", b, d, nil, &vcs.Diff{Raw: "bd123"})
+	if diff, ok := c.Diff("r", a, b, nil); !ok || diff.Raw != "ab123" {
+		t.Errorf("got diff (%+v, %v), want (ab123, true)", diff, ok)
+	}
+
+	// The cache is full, and a-b was used more recently than b-d, so
+	// b-d is evicted.
+	c.SetDiff("r", a, d, nil, &vcs.Diff{Raw: "ad"})
+	if _, ok := c.Diff("r", b, d, nil); ok {
+		t.Error("got b-d cached, want evicted")
+	}
+	if diff, ok := c.Diff("r", a, d, nil); !ok || diff.Raw != "ad" {
+		t.Errorf("got diff (%+v, %v), want (ad, true)", diff, ok)
+	}
+
+	// A diff larger than the whole cache isn't cached.
+	c.SetDiff("r", d, a, nil, &vcs.Diff{Raw: strings.Repeat("x", 11)})
+	if _, ok := c.Diff("r", d, a, nil); ok {
+		t.Error("got oversized diff cached, want not cached")
+	}
+	if _, ok := c.Diff("r", a, b, nil); !ok {
+		t.Error("got a-b evicted by oversized diff, want cached")
+	}
+
+	// Diffs are per-repository and per-direction.
+	if _, ok := c.Diff("other", a, b, nil); ok {
+		t.Error("got a-b cached in other repo, want not cached")
+	}
+	if _, ok := c.Diff("r", b, a, nil); ok {
+		t.Error("got b-a cached, want not cached")
+	}
+}
+
+func TestDiffCache_options(t *testing.T) {
+	c := newDiffCache(100)
+
+	a, b := vcs.CommitID(strings.Repeat("a", 40)), vcs.CommitID(strings.Repeat("b", 40))
+
+	c.SetDiff("r", a, b, &vcs.DiffOptions{Paths: []string{"x", "y"}, DetectRenames: true, IgnoreWhitespace: true}, &vcs.Diff{Raw: "1"})
+
+	// Equivalent options share an entry.
+	equivalent := []*vcs.DiffOptions{
+		{Paths: []string{"y", "x"}, DetectRenames: true, IgnoreWhitespace: true},
+		{Paths: []string{"x", "y"}, RenameThreshold: 50, IgnoreWhitespace: true},
+		{Paths: []string{"x", "y"}, DetectRenames: true, IgnoreWhitespace: true, IgnoreWhitespaceChange: true},
+	}
+	for _, opt := range equivalent {
+		if diff, ok := c.Diff("r", a, b, opt); !ok || diff.Raw != "1" {
+			t.Errorf("%+v: got diff (%+v, %v), want (1, true)", opt, diff, ok)
+		}
+	}
+
+	different := []*vcs.DiffOptions{
+		nil,
+		{Paths: []string{"x"}, DetectRenames: true, IgnoreWhitespace: true},
+		{Paths: []string{"x", "y"}, RenameThreshold: 90, IgnoreWhitespace: true},
+		{Paths: []string{"x", "y"}, DetectRenames: true},
+		{Paths: []string{"x", "y"}, DetectRenames: true, IgnoreWhitespace: true, ExcludeReachableFromBoth: true},
+	}
+	for _, opt := range different {
+		if _, ok := c.Diff("r", a, b, opt); ok {
+			t.Errorf("%+v: got cached, want not cached", opt)
+		}
+	}
+}
+
+func TestDiffCache_notCanonical(t *testing.T) {
+	c := newDiffCache(100)
+
+	a := vcs.CommitID(strings.Repeat("a", 40))
+	pairs := [][2]vcs.CommitID{{a, "master"}, {"master", a}, {a, "abcd"}, {a, vcs.CommitID(strings.Repeat("A", 40))}}
+	for _, p := range pairs {
+		c.SetDiff("r", p[0], p[1], nil, &vcs.Diff{Raw: "x"})
+		if _, ok := c.Diff("r", p[0], p[1], nil); ok {
+			t.Errorf("%v: got cached, want not cached", p)
+		}
+	}
+}
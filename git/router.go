@@ -11,6 +11,7 @@ const (
 	RouteGitInfoRefs    = "git.info-refs"
 	RouteGitUploadPack  = "git.upload-pack"
 	RouteGitReceivePack = "git.receive-pack"
+	RouteGitDumbFile    = "git.dumb-file"
 )
 
 var GitMatcher mux.MatcherFunc = func(req *http.Request, rt *mux.RouteMatch) bool {
@@ -31,6 +32,7 @@ func NewRouter(base *mux.Router) *mux.Router {
 	gm.Path("/info/refs").Methods("GET").Name(RouteGitInfoRefs)
 	gm.Path("/git-upload-pack").Methods("POST").Name(RouteGitUploadPack)
 	gm.Path("/git-receive-pack").Methods("POST").Name(RouteGitReceivePack)
+	gm.Path("/{File:HEAD|objects/.+}").Methods("GET").Name(RouteGitDumbFile)
 
 	return base
 }
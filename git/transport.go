@@ -1,31 +1,78 @@
 package git
 
-import "io"
+import (
+	"context"
+	"errors"
+	"io"
+)
 
 const (
 	ServiceReceivePack = "receive-pack"
 	ServiceUploadPack  = "upload-pack"
 )
 
+// ErrInvalidService is returned by CheckService for a service other
+// than ServiceReceivePack or ServiceUploadPack.
+var ErrInvalidService = errors.New(`invalid git service (must be "upload-pack" or "receive-pack")`)
+
+// CheckService returns ErrInvalidService unless service is one of the
+// smart protocol services. Implementations of GitTransport must call
+// it before passing a service name to git, so that a crafted name
+// can't run an arbitrary git subcommand.
+func CheckService(service string) error {
+	if service != ServiceUploadPack && service != ServiceReceivePack {
+		return ErrInvalidService
+	}
+	return nil
+}
+
 type GitTransporter interface {
 	GitTransport(repoPath string) (GitTransport, error)
 }
 
 // GitTransport represents a git repository with all the functions to
-// support the "smart" transfer protocol.
+// support the "smart" transfer protocol. Each method stops (and
+// returns ctx.Err()) when ctx is canceled. ReceivePack and UploadPack
+// may wait for a blocked read from r to return, so the caller must
+// ensure that it returns when ctx is canceled.
 type GitTransport interface {
 	// InfoRefs writes the output of git-info-refs to w.
-	InfoRefs(w io.Writer, service string) error
+	InfoRefs(ctx context.Context, w io.Writer, service string) error
 
 	// ReceivePack writes the output of git-receive-pack to w, reading
 	// from r.
-	ReceivePack(w io.Writer, r io.Reader, opt GitTransportOpt) error
+	ReceivePack(ctx context.Context, w io.Writer, r io.Reader, opt GitTransportOpt) error
 
 	// UploadPack writes the output of git-upload-pack to w, reading
 	// from r.
-	UploadPack(w io.Writer, r io.Reader, opt GitTransportOpt) error
+	UploadPack(ctx context.Context, w io.Writer, r io.Reader, opt GitTransportOpt) error
+}
+
+// A DumbTransport is a GitTransport that can also serve the "dumb"
+// HTTP transfer protocol, whose clients fetch the files of the
+// repository (such as "info/refs" and objects) directly.
+type DumbTransport interface {
+	// OpenFile opens the file at name (relative to the git dir) for
+	// a dumb protocol client. "info/refs" and "objects/info/packs"
+	// aren't updated here (since OpenFile only reads the repository);
+	// they must be updated (see vcs.ServerInfoUpdater) whenever the
+	// repository's refs or packs change. If the dumb protocol is
+	// disabled or name isn't a file that dumb protocol clients fetch,
+	// an os.ErrNotExist-satisfying error is returned.
+	OpenFile(ctx context.Context, name string) (io.ReadCloser, error)
 }
 
 type GitTransportOpt struct {
 	ContentEncoding string
+
+	// OnPush, if set, is called after a successful receive-pack with
+	// the ref updates that the client pushed (and that were applied).
+	OnPush func(updates []RefUpdate)
+}
+
+// A RefUpdate is an update of a ref pushed by a client.
+type RefUpdate struct {
+	Ref string // full name of the ref (such as "refs/heads/master")
+	Old string // commit ID before the update (all zeros if the ref was created)
+	New string // commit ID after the update (all zeros if the ref was deleted)
 }
@@ -0,0 +1,49 @@
+package vcsstore
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cloneCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vcsstore",
+		Name:      "clones_total",
+		Help:      "Total number of repository clones, by VCS type and result.",
+	}, []string{"vcs", "result"})
+	cloneDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vcsstore",
+		Name:      "clone_duration_seconds",
+		Help:      "Durations of repository clones, by VCS type.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"vcs"})
+	openRepos = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "vcsstore",
+		Name:      "open_repos",
+		Help:      "Number of repositories currently open (in use or idle).",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cloneCount, cloneDuration, openRepos)
+}
+
+// observeClone records metrics for a clone of a repository of type
+// vcsType that took d.
+func observeClone(vcsType string, err error, d time.Duration) {
+	// vcsType comes from the client, so only use known values as
+	// labels to keep cardinality bounded.
+	switch vcsType {
+	case "git", "hg", "bzr", "svn":
+	default:
+		vcsType = "other"
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	cloneCount.WithLabelValues(vcsType, result).Inc()
+	cloneDuration.WithLabelValues(vcsType).Observe(d.Seconds())
+}
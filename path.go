@@ -1,10 +1,15 @@
 package vcsstore
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
 	pathpkg "path"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 func EncodeRepositoryPath(repoPath string) (path string) {
@@ -15,6 +20,94 @@ func DecodeRepositoryPath(path string) (repoPath string) {
 	return path
 }
 
+// HashRepositoryPath returns the slash-separated path (relative to
+// the storage dir) of the repository's directory in the hashed
+// storage layout (see Config.HashedStorage). It is the hex-encoded
+// SHA-256 hash of repoPath, sharded into two levels of directories
+// named by the hash's first 4 hex digits (e.g., "3f/a2/3fa2...").
+func HashRepositoryPath(repoPath string) string {
+	sum := sha256.Sum256([]byte(repoPath))
+	h := hex.EncodeToString(sum[:])
+	return h[:2] + "/" + h[2:4] + "/" + h
+}
+
+// repoPathFileSuffix is appended to a repository's directory name in
+// the hashed storage layout to get the name of the file (next to the
+// directory) that records the repository's repo path.
+const repoPathFileSuffix = ".repo-path"
+
+// tmpDirPrefix is the name prefix of the temporary directories that
+// repositories are cloned or initialized in before they are renamed
+// into place.
+const tmpDirPrefix = "_tmp_"
+
+// recordRepositoryPath records the repo path of the repository at
+// cloneDir, if the hashed storage layout is used (in the flat layout,
+// the repo path is the directory's path).
+func (c *Config) recordRepositoryPath(repoPath, cloneDir string) error {
+	if !c.HashedStorage {
+		return nil
+	}
+	return ioutil.WriteFile(cloneDir+repoPathFileSuffix, []byte(repoPath), 0600)
+}
+
+// ListRepositories returns the sorted repo paths of the repositories
+// stored in the storage dir (using the layout set by
+// Config.HashedStorage). In the flat layout, repositories stored
+// inside another repository's directory (such as "a/b" inside "a")
+// are not listed.
+func (c *Config) ListRepositories() ([]string, error) {
+	var repoPaths []string
+	err := filepath.Walk(c.StorageDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == c.StorageDir {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), tmpDirPrefix) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if c.HashedStorage {
+			if fi.Mode().IsRegular() && strings.HasSuffix(path, repoPathFileSuffix) {
+				// Ignore the record if the repository was removed.
+				if _, err := os.Stat(strings.TrimSuffix(path, repoPathFileSuffix)); os.IsNotExist(err) {
+					return nil
+				}
+				repoPath, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				repoPaths = append(repoPaths, string(repoPath))
+			}
+			return nil
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+		if _, err := vcsTypeFromDir(path); err != nil {
+			// Not a repository, but it may contain repositories.
+			return nil
+		}
+		rel, err := filepath.Rel(c.StorageDir, path)
+		if err != nil {
+			return err
+		}
+		repoPaths = append(repoPaths, DecodeRepositoryPath(filepath.ToSlash(rel)))
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repoPaths)
+	return repoPaths, nil
+}
+
 func vcsTypeFromDir(cloneDir string) (vcsType string, err error) {
 	if _, err := os.Stat(filepath.Join(cloneDir, ".git")); err == nil {
 		// git non-bare
@@ -24,6 +117,10 @@ func vcsTypeFromDir(cloneDir string) (vcsType string, err error) {
 		return "git", nil
 	} else if _, err := os.Stat(filepath.Join(cloneDir, ".hg")); err == nil {
 		return "hg", nil
+	} else if _, err := os.Stat(filepath.Join(cloneDir, ".bzr")); err == nil {
+		return "bzr", nil
+	} else if _, err := os.Stat(filepath.Join(cloneDir, ".svn")); err == nil {
+		return "svn", nil
 	} else {
 		if _, err := os.Stat(cloneDir); os.IsNotExist(err) {
 			return "", err
@@ -2,8 +2,12 @@ package vcsstore
 
 import (
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -29,11 +33,95 @@ func TestEncodeAndDecodeRepositoryPath(t *testing.T) {
 	}
 }
 
+func TestHashRepositoryPath(t *testing.T) {
+	// These repo paths collide or are too long for the filesystem
+	// when stored at their (cleaned) repo paths.
+	long := "a.b/" + strings.Repeat("c", 300)
+	tests := []struct{ a, b string }{
+		{"a.b/c/../d", "a.b/d"},
+		{"a.b//c", "a.b/c"},
+		{"a.b/c/", "a.b/c"},
+	}
+	for _, test := range tests {
+		if a, b := EncodeRepositoryPath(test.a), EncodeRepositoryPath(test.b); a != b {
+			t.Errorf("%q and %q: got flat paths %q and %q, want them to collide (test is out of date)", test.a, test.b, a, b)
+		}
+		if a, b := HashRepositoryPath(test.a), HashRepositoryPath(test.b); a == b {
+			t.Errorf("%q and %q: got colliding hashed path %q", test.a, test.b, a)
+		}
+	}
+
+	hashed := HashRepositoryPath(long)
+	if want := 2 + 1 + 2 + 1 + 64; len(hashed) != want {
+		t.Errorf("got hashed path %q (length %d), want length %d", hashed, len(hashed), want)
+	}
+	if !strings.HasPrefix(hashed, hashed[6:8]+"/"+hashed[8:10]+"/") {
+		t.Errorf("got hashed path %q, want it sharded by its first 4 hex digits", hashed)
+	}
+}
+
+func TestConfig_ListRepositories(t *testing.T) {
+	long := "a.b/" + strings.Repeat("c", 300)
+	tests := map[string]struct {
+		conf      Config
+		repoPaths []string
+	}{
+		"flat": {
+			conf:      Config{},
+			repoPaths: []string{"a.b/c", "a.b/d", "x.y/z"},
+		},
+		"hashed": {
+			conf:      Config{HashedStorage: true},
+			repoPaths: []string{"a.b/c", "a.b/c/d", "a.b/c/../d", "a.b/d", long},
+		},
+	}
+	for label, test := range tests {
+		func() {
+			storageDir, err := ioutil.TempDir("", "vcsstore-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(storageDir)
+
+			test.conf.StorageDir = storageDir
+			test.conf.Log = log.New(ioutil.Discard, "", 0)
+			s := NewService(&test.conf)
+			for _, repoPath := range test.repoPaths {
+				if err := s.Init(repoPath, "git"); err != nil {
+					t.Fatalf("%s: Init(%q): %s", label, repoPath, err)
+				}
+			}
+			for _, repoPath := range test.repoPaths {
+				if _, err := s.Open(repoPath); err != nil {
+					t.Errorf("%s: Open(%q): %s", label, repoPath, err)
+					continue
+				}
+				s.Close(repoPath)
+			}
+
+			// Leftover temporary dirs are ignored.
+			if err := os.Mkdir(filepath.Join(storageDir, tmpDirPrefix+"x"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			repoPaths, err := test.conf.ListRepositories()
+			if err != nil {
+				t.Fatalf("%s: ListRepositories: %s", label, err)
+			}
+			want := append([]string(nil), test.repoPaths...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(repoPaths, want) {
+				t.Errorf("%s: got repo paths %q, want %q", label, repoPaths, want)
+			}
+		}()
+	}
+}
+
 func TestVCSTypeFromDir(t *testing.T) {
 	tests := []struct {
 		initCmd    string
 		expVCSType string
-	}{{"git init", "git"}, {"git init --bare", "git"}, {"hg init", "hg"}}
+	}{{"git init", "git"}, {"git init --bare", "git"}, {"hg init", "hg"}, {"bzr init", "bzr"}}
 
 	for _, test := range tests {
 		func() {
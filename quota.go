@@ -0,0 +1,251 @@
+package vcsstore
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+// A StorageRecorder is a Service that limits the total size of its
+// repositories (see Config.MaxStorageBytes). It records each
+// repository's size when it is cloned, and must be told when the size
+// may have changed otherwise (such as after an update or a push).
+type StorageRecorder interface {
+	// RecordRepositorySize records the current size of the
+	// repository.
+	RecordRepositorySize(repoPath string)
+}
+
+var _ StorageRecorder = (*service)(nil)
+
+func (s *service) RecordRepositorySize(repoPath string) {
+	if s.MaxStorageBytes <= 0 {
+		return
+	}
+	cloneDir, err := s.CloneDir(repoPath)
+	if err != nil {
+		return
+	}
+	size, err := repoSize(cloneDir)
+	if err != nil {
+		s.Log.Printf("Error recording the size of repository %s: %s.", repoPath, err)
+		return
+	}
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	s.repoSizes[repoKey{cloneDir}] = size
+}
+
+// makeRoomFor returns the size of the repository at newDir (which is
+// not yet in its clone dir) and nil if it fits within
+// Config.MaxStorageBytes along with the stored repositories.
+// Otherwise, if Config.EvictForStorage is set, it evicts the least
+// recently accessed repositories until the new one fits; if it's not
+// set (or not enough repositories can be evicted),
+// vcsclient.ErrStorageQuotaExceeded is returned. The stored
+// repositories' sizes are those recorded in s.repoSizes (which are
+// measured and recorded if missing). The caller must hold s.quotaMu.
+func (s *service) makeRoomFor(newDir string) (newSize int64, err error) {
+	newSize, err = repoSize(newDir)
+	if err != nil {
+		return 0, err
+	}
+
+	repoPaths, err := s.ListRepositories()
+	if err != nil {
+		return 0, err
+	}
+	type storedRepo struct {
+		repoPath, cloneDir string
+		size               int64
+		lastAccess         time.Time
+	}
+	repos := make([]storedRepo, 0, len(repoPaths))
+	stored := make(map[repoKey]struct{}, len(repoPaths))
+	total := newSize
+	for _, repoPath := range repoPaths {
+		cloneDir, err := s.CloneDir(repoPath)
+		if err != nil {
+			return 0, err
+		}
+		key := repoKey{cloneDir}
+		stored[key] = struct{}{}
+		size, ok := s.repoSizes[key]
+		if !ok {
+			size, err = repoSize(cloneDir)
+			if err != nil {
+				return 0, err
+			}
+			s.repoSizes[key] = size
+		}
+		repos = append(repos, storedRepo{repoPath: repoPath, cloneDir: cloneDir, size: size, lastAccess: s.lastAccessTime(cloneDir)})
+		total += size
+	}
+	s.forgetDeletedRepos(stored)
+	if total <= s.MaxStorageBytes {
+		return newSize, nil
+	}
+	if !s.EvictForStorage {
+		return 0, vcsclient.ErrStorageQuotaExceeded
+	}
+
+	sort.Slice(repos, func(i, j int) bool { return repos[i].lastAccess.Before(repos[j].lastAccess) })
+	for _, r := range repos {
+		evicted, err := s.evictRepo(r.repoPath, r.cloneDir)
+		if err != nil {
+			return 0, err
+		}
+		if !evicted {
+			continue
+		}
+		s.Log.Printf("Evicted repository %s (%d bytes) at %s to stay within the storage quota.", r.repoPath, r.size, r.cloneDir)
+		if total -= r.size; total <= s.MaxStorageBytes {
+			return newSize, nil
+		}
+	}
+	return 0, vcsclient.ErrStorageQuotaExceeded
+}
+
+// forgetDeletedRepos removes the recorded sizes and last access times
+// of repositories that are no longer stored (i.e., that aren't in
+// stored). The caller must hold s.quotaMu.
+func (s *service) forgetDeletedRepos(stored map[repoKey]struct{}) {
+	for key := range s.repoSizes {
+		if _, ok := stored[key]; !ok {
+			delete(s.repoSizes, key)
+		}
+	}
+
+	s.repoMuMu.Lock()
+	defer s.repoMuMu.Unlock()
+	for key := range s.lastAccess {
+		if _, ok := stored[key]; !ok && s.repos[key] == nil {
+			delete(s.lastAccess, key)
+		}
+	}
+}
+
+// lastAccessTime returns the time the repository at cloneDir was last
+// opened. If it hasn't been opened since the service started, the
+// modification time of its directory is used.
+func (s *service) lastAccessTime(cloneDir string) time.Time {
+	s.repoMuMu.Lock()
+	t, ok := s.lastAccess[repoKey{cloneDir}]
+	s.repoMuMu.Unlock()
+	if ok {
+		return t
+	}
+	if fi, err := os.Stat(cloneDir); err == nil {
+		return fi.ModTime()
+	}
+	return time.Time{}
+}
+
+// evictRepo deletes the repository at cloneDir unless it is in use
+// (or being cloned), and returns whether it was deleted. The caller
+// must hold s.quotaMu.
+func (s *service) evictRepo(repoPath, cloneDir string) (bool, error) {
+	key := repoKey{cloneDir}
+
+	s.repoMuMu.Lock()
+	if s.repoUsers[key] > 0 {
+		s.repoMuMu.Unlock()
+		return false, nil
+	}
+	mu := s.repoMu[key]
+	if mu != nil && !mu.TryLock() {
+		s.repoMuMu.Unlock()
+		return false, nil
+	}
+	if mu != nil {
+		defer mu.Unlock()
+	}
+	repo := s.repos[key]
+	delete(s.repos, key)
+	if e, ok := s.idleRepoElems[key]; ok {
+		s.idleRepos.Remove(e)
+		delete(s.idleRepoElems, key)
+	}
+	delete(s.lastAccess, key)
+	openRepos.Set(float64(len(s.repos)))
+	s.repoMuMu.Unlock()
+	delete(s.repoSizes, key) // the caller holds s.quotaMu
+
+	if repo != nil {
+		closeRepo(repo)
+	}
+
+	// Move the repository out of the way first, so that it is never
+	// visible partially deleted.
+	evictDir := filepath.Join(filepath.Dir(cloneDir), tmpDirPrefix+"evicted-"+filepath.Base(cloneDir)+"-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	s.acquireTmpDir(evictDir)
+	if err := os.Rename(cloneDir, evictDir); err != nil {
+		s.releaseTmpDir(evictDir)
+		return false, err
+	}
+	if s.HashedStorage {
+		if err := os.Remove(cloneDir + repoPathFileSuffix); err != nil && !os.IsNotExist(err) {
+			s.releaseTmpDir(evictDir)
+			return false, err
+		}
+	}
+	s.BumpRefsGeneration(repoPath)
+	return true, s.releaseTmpDir(evictDir)
+}
+
+// repoSize returns the size in bytes of the repository at dir. For
+// git repositories, it is the size of the repository's objects (as
+// reported by `git count-objects`); otherwise it is the total size of
+// the files in dir.
+func repoSize(dir string) (int64, error) {
+	if vcsType, err := vcsTypeFromDir(dir); err == nil && vcsType == "git" {
+		cmd := exec.Command("git", "count-objects", "-v")
+		cmd.Dir = dir
+		if out, err := cmd.Output(); err == nil {
+			return parseCountObjectsSize(out)
+		}
+	}
+
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// parseCountObjectsSize returns the total size in bytes of the loose,
+// packed, and garbage objects in the output of `git count-objects
+// -v` (which reports sizes in KiB).
+func parseCountObjectsSize(out []byte) (int64, error) {
+	var kib int64
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		field := strings.SplitN(sc.Text(), ": ", 2)
+		if len(field) != 2 {
+			continue
+		}
+		switch field[0] {
+		case "size", "size-pack", "size-garbage":
+			n, err := strconv.ParseInt(field[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			kib += n
+		}
+	}
+	return kib * 1024, sc.Err()
+}
@@ -0,0 +1,129 @@
+package vcsstore
+
+import (
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// A RefsCache memoizes data derived from a repository's refs (such as
+// the commit that a branch points to). Cached data is invalidated
+// whenever the repository's refs generation is bumped, which should
+// happen whenever its refs may have changed (e.g., after a remote
+// update or a push).
+//
+// Any other cached data that is keyed on a non-canonical revision
+// (such as a FileSystem for a branch name) must also be invalidated
+// when the refs generation changes. Data keyed on a full commit ID is
+// immutable and may be cached indefinitely.
+type RefsCache interface {
+	// RefsGeneration returns the repository's current refs
+	// generation. It changes every time BumpRefsGeneration is called.
+	RefsGeneration(repoPath string) uint64
+
+	// BumpRefsGeneration increments the repository's refs
+	// generation, invalidating all cached data derived from its refs.
+	BumpRefsGeneration(repoPath string)
+
+	// ResolvedRevision returns the cached commit ID that key (such
+	// as a branch name) resolved to in the repository's current refs
+	// generation, and whether it was cached.
+	ResolvedRevision(repoPath, key string) (vcs.CommitID, bool)
+
+	// SetResolvedRevision caches the commit ID that key resolved to
+	// in refs generation gen (which the caller must have obtained
+	// before resolving key). It is a no-op if the repository's refs
+	// generation is no longer gen.
+	SetResolvedRevision(repoPath string, gen uint64, key string, commitID vcs.CommitID)
+}
+
+// maxResolvedRevisions is the maximum number of resolved revisions
+// cached per repository. When it is exceeded, the repository's cached
+// revisions are discarded.
+const maxResolvedRevisions = 1000
+
+// maxRefsRepos is the maximum number of repositories whose refs are
+// cached. When it is exceeded, all repositories' cached refs are
+// discarded.
+var maxRefsRepos = 10000
+
+// refsCache is a RefsCache.
+type refsCache struct {
+	mu sync.Mutex
+
+	// lastGen is the most recently assigned refs generation (of any
+	// repository). Generations are assigned in increasing order, so
+	// that they don't repeat when a repository's refs are discarded
+	// and cached again. It starts at a value derived from the time
+	// the cache was created so that generations don't repeat across
+	// server restarts either.
+	lastGen uint64
+
+	refs map[string]*repoRefs
+}
+
+type repoRefs struct {
+	gen  uint64
+	revs map[string]vcs.CommitID
+}
+
+func newRefsCache() *refsCache {
+	return &refsCache{
+		lastGen: uint64(time.Now().UnixNano()),
+		refs:    map[string]*repoRefs{},
+	}
+}
+
+// get returns the refs of the repository. The caller must hold c.mu.
+func (c *refsCache) get(repoPath string) *repoRefs {
+	r, ok := c.refs[repoPath]
+	if !ok {
+		if len(c.refs) >= maxRefsRepos {
+			c.refs = map[string]*repoRefs{}
+		}
+		r = &repoRefs{gen: c.nextGen()}
+		c.refs[repoPath] = r
+	}
+	return r
+}
+
+// nextGen returns a new refs generation. The caller must hold c.mu.
+func (c *refsCache) nextGen() uint64 {
+	c.lastGen++
+	return c.lastGen
+}
+
+func (c *refsCache) RefsGeneration(repoPath string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(repoPath).gen
+}
+
+func (c *refsCache) BumpRefsGeneration(repoPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.get(repoPath)
+	r.gen = c.nextGen()
+	r.revs = nil
+}
+
+func (c *refsCache) ResolvedRevision(repoPath, key string) (vcs.CommitID, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commitID, ok := c.get(repoPath).revs[key]
+	return commitID, ok
+}
+
+func (c *refsCache) SetResolvedRevision(repoPath string, gen uint64, key string, commitID vcs.CommitID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.get(repoPath)
+	if r.gen != gen {
+		return
+	}
+	if r.revs == nil || len(r.revs) >= maxResolvedRevisions {
+		r.revs = map[string]vcs.CommitID{}
+	}
+	r.revs[key] = commitID
+}
@@ -0,0 +1,56 @@
+package vcsstore
+
+import "testing"
+
+func TestRefsCache(t *testing.T) {
+	c := newRefsCache()
+
+	gen := c.RefsGeneration("r")
+	c.SetResolvedRevision("r", gen, "branch:master", "a")
+	if commitID, ok := c.ResolvedRevision("r", "branch:master"); !ok || commitID != "a" {
+		t.Errorf("got (%q, %v), want (\"a\", true)", commitID, ok)
+	}
+
+	// Resolutions are per-repository.
+	if _, ok := c.ResolvedRevision("other", "branch:master"); ok {
+		t.Error("got cached in other repo, want not cached")
+	}
+
+	// Bumping the generation invalidates resolutions.
+	c.BumpRefsGeneration("r")
+	if c.RefsGeneration("r") == gen {
+		t.Error("got same generation after bump, want changed")
+	}
+	if _, ok := c.ResolvedRevision("r", "branch:master"); ok {
+		t.Error("got cached after bump, want not cached")
+	}
+
+	// A resolution from a previous generation is not cached.
+	c.SetResolvedRevision("r", gen, "branch:master", "a")
+	if _, ok := c.ResolvedRevision("r", "branch:master"); ok {
+		t.Error("got stale resolution cached, want not cached")
+	}
+}
+
+func TestRefsCache_maxRepos(t *testing.T) {
+	defer func(orig int) { maxRefsRepos = orig }(maxRefsRepos)
+	maxRefsRepos = 2
+	c := newRefsCache()
+
+	gen := c.RefsGeneration("r")
+	c.SetResolvedRevision("r", gen, "branch:master", "a")
+	c.RefsGeneration("r2")
+	c.RefsGeneration("r3")
+	if n := len(c.refs); n > maxRefsRepos {
+		t.Errorf("got %d repos cached, want at most %d", n, maxRefsRepos)
+	}
+
+	// Discarded refs are no longer cached, and the repository gets a
+	// new generation.
+	if _, ok := c.ResolvedRevision("r", "branch:master"); ok {
+		t.Error("got cached after discarding, want not cached")
+	}
+	if c.RefsGeneration("r") == gen {
+		t.Error("got same generation after discarding, want changed")
+	}
+}
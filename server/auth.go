@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/vcsstore/git"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+// Repository operations, passed to Handler.AuthorizeRepo.
+const (
+	OpRead  = "read"  // read repository data via the API
+	OpClone = "clone" // fetch or clone via the git transport, or clone/update the repository from its remotes
+	OpPush  = "push"  // push via the git transport, or create empty repositories or create or delete branches and tags via the API
+
+	OpConfig = "config" // set the repository's configuration (or read its secret configuration, or manage its remotes), or run maintenance (such as gc or fsck) on it
+)
+
+var (
+	// ErrUnauthorized is returned by an Authenticator when a request
+	// has no (or malformed) credentials. It results in an HTTP 401
+	// response.
+	ErrUnauthorized = errors.New("authentication required")
+
+	// ErrForbidden is returned by an Authenticator when a request's
+	// credentials don't permit access to a repository. It results in
+	// an HTTP 403 response.
+	ErrForbidden = errors.New("access denied")
+)
+
+// An Authenticator decides whether a request may operate on a
+// repository. It is called (if set on the Handler) before any
+// repository is opened and before any git process is spawned.
+type Authenticator interface {
+	// Authenticate returns nil if r may operate on the repository at
+	// repoPath. Otherwise it returns an error, which should be
+	// ErrUnauthorized or ErrForbidden (or have an HTTP status code)
+	// to control the HTTP response status.
+	Authenticate(r *http.Request, repoPath string) error
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary
+// functions as Authenticators.
+type AuthenticatorFunc func(r *http.Request, repoPath string) error
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request, repoPath string) error {
+	return f(r, repoPath)
+}
+
+// BearerTokenAuthenticator is an Authenticator that allows requests
+// whose "Authorization: Bearer <token>" header has one of a fixed set
+// of tokens. The tokens grant access to all repositories.
+type BearerTokenAuthenticator struct {
+	Tokens []string
+}
+
+var _ Authenticator = (*BearerTokenAuthenticator)(nil)
+
+func (a *BearerTokenAuthenticator) Authenticate(r *http.Request, repoPath string) error {
+	token, ok := bearerToken(r)
+	if !ok {
+		return ErrUnauthorized
+	}
+	for _, t := range a.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// bearerToken returns the token in r's "Authorization: Bearer
+// <token>" header, if any.
+func bearerToken(r *http.Request) (token string, ok bool) {
+	const prefix = "bearer "
+	hdr := r.Header.Get("authorization")
+	if len(hdr) <= len(prefix) || !strings.EqualFold(hdr[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(hdr[len(prefix):]), true
+}
+
+// authenticate checks whether r may operate on the repository at
+// repoPath, using h.Authenticator and h.AuthorizeRepo (if set), and
+// logs the decision.
+func (h *Handler) authenticate(r *http.Request, repoPath string) error {
+	op := repoOperation(r)
+	if h.Authenticator == nil && h.AuthorizeRepo == nil {
+		if isAPIWrite(r) {
+			return &httpError{http.StatusForbidden, errors.New("modifying repositories via the API requires authentication to be configured")}
+		}
+		return nil
+	}
+
+	if h.Authenticator != nil {
+		if err := h.Authenticator.Authenticate(r, repoPath); err != nil {
+			h.Log.Printf("Auth: denied %s %q (repo %s, %s): %s.", r.Method, r.URL.RequestURI(), repoPath, op, err)
+			return err
+		}
+	}
+	if h.AuthorizeRepo != nil {
+		token, _ := bearerToken(r)
+		if err := h.AuthorizeRepo(r.Context(), token, repoPath, op); err != nil {
+			h.Log.Printf("Auth: denied %s %q (repo %s, %s): %s.", r.Method, r.URL.RequestURI(), repoPath, op, err)
+			if errorHTTPStatusCode(err) == http.StatusInternalServerError {
+				err = &httpError{http.StatusForbidden, err}
+			}
+			return err
+		}
+	}
+	h.Log.Printf("Auth: allowed %s %q (repo %s, %s).", r.Method, r.URL.RequestURI(), repoPath, op)
+	if h.RateLimiter != nil {
+		h.RateLimiter.Verified(r)
+	}
+	return nil
+}
+
+// isAPIWrite returns whether r modifies (or runs maintenance on) its
+// repository via the API (as opposed to the git transport), or lists
+// its remotes or reads a configuration key (whose values may contain
+// credentials). Such requests are denied unless authentication is
+// configured.
+func isAPIWrite(r *http.Request) bool {
+	if rt := mux.CurrentRoute(r); rt != nil {
+		switch rt.GetName() {
+		case vcsclient.RouteRepoInit, vcsclient.RouteRepoSetConfig, vcsclient.RouteRepoGC, vcsclient.RouteRepoFsck, vcsclient.RouteRepoRemotes, vcsclient.RouteRepoAddRemote, vcsclient.RouteRepoRemoveRemote, vcsclient.RouteRepoCreateBranch, vcsclient.RouteRepoDeleteBranch, vcsclient.RouteRepoCreateTag, vcsclient.RouteRepoDeleteTag:
+			return true
+		case vcsclient.RouteRepoConfig:
+			return isSecretConfigKey(mux.Vars(r)["Key"])
+		}
+	}
+	return false
+}
+
+// repoOperation returns the operation (OpRead, OpClone, OpPush, or OpConfig)
+// that r performs on its repository.
+func repoOperation(r *http.Request) string {
+	var route string
+	if rt := mux.CurrentRoute(r); rt != nil {
+		route = rt.GetName()
+	}
+	switch route {
+	case git.RouteGitReceivePack, vcsclient.RouteRepoInit, vcsclient.RouteRepoCreateBranch, vcsclient.RouteRepoDeleteBranch, vcsclient.RouteRepoCreateTag, vcsclient.RouteRepoDeleteTag:
+		return OpPush
+	case git.RouteGitInfoRefs:
+		if r.URL.Query().Get("service") == "git-"+git.ServiceReceivePack {
+			return OpPush
+		}
+		return OpClone
+	case git.RouteGitUploadPack, git.RouteGitDumbFile, vcsclient.RouteRepoCreateOrUpdate, vcsclient.RouteRepoCloneAsync, vcsclient.RouteRepoFetchRemote:
+		return OpClone
+	case vcsclient.RouteRepoSetConfig, vcsclient.RouteRepoGC, vcsclient.RouteRepoFsck, vcsclient.RouteRepoRemotes, vcsclient.RouteRepoAddRemote, vcsclient.RouteRepoRemoveRemote:
+		return OpConfig
+	case vcsclient.RouteRepoConfig:
+		if isSecretConfigKey(mux.Vars(r)["Key"]) {
+			return OpConfig
+		}
+	}
+	return OpRead
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/vcsstore/git"
+)
+
+func TestBearerTokenAuthenticator(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	sm := &mockServiceForExistingRepo{
+		t: t,
+
+		repoPath: repoPath,
+	}
+	testHandler.Service = sm
+	testHandler.Authenticator = &BearerTokenAuthenticator{Tokens: []string{"t1", "t2"}}
+
+	tests := map[string]struct {
+		authHeader string
+		wantStatus int
+	}{
+		"no token":      {"", http.StatusUnauthorized},
+		"basic auth":    {"Basic dTpw", http.StatusUnauthorized},
+		"invalid token": {"Bearer t3", http.StatusForbidden},
+		"valid token":   {"Bearer t2", http.StatusOK},
+	}
+	for label, test := range tests {
+		sm.opened = false
+
+		req, err := http.NewRequest("GET", server.URL+testHandler.router.URLToRepo(repoPath).String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.authHeader != "" {
+			req.Header.Set("authorization", test.authHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got := resp.StatusCode; got != test.wantStatus {
+			t.Errorf("%s: got code %d, want %d", label, got, test.wantStatus)
+		}
+		if allowed := test.wantStatus == http.StatusOK; sm.opened != allowed {
+			t.Errorf("%s: got opened %v, want %v", label, sm.opened, allowed)
+		}
+		if test.wantStatus == http.StatusUnauthorized && resp.Header.Get("www-authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header in 401 response", label)
+		}
+	}
+}
+
+func TestAuthorizeRepo(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	trans := &mockGitTransporter{t: t}
+	testHandler.GitTransporter = trans
+	testHandler.Service = &mockServiceForExistingRepo{t: t}
+
+	type authz struct{ token, repoPath, op string }
+	var calls []authz
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		calls = append(calls, authz{token, repoPath, op})
+		if token == "t1" && repoPath == "a/b" && op != OpPush {
+			return nil
+		}
+		return errors.New("not allowed")
+	}
+
+	tests := []struct {
+		method, url string
+		gitClient   bool
+		token       string
+
+		wantStatus int
+		wantAuthz  authz
+	}{
+		{"GET", "/a/b", false, "t1", http.StatusOK, authz{"t1", "a/b", OpRead}},
+		{"GET", "/x/y", false, "t1", http.StatusForbidden, authz{"t1", "x/y", OpRead}},
+		{"GET", "/a/b", false, "", http.StatusForbidden, authz{"", "a/b", OpRead}},
+		{"GET", "/a/b/.git/info/refs?service=git-upload-pack", true, "t1", http.StatusOK, authz{"t1", "a/b", OpClone}},
+		{"GET", "/a/b/.git/info/refs?service=git-receive-pack", true, "t1", http.StatusForbidden, authz{"t1", "a/b", OpPush}},
+		{"POST", "/a/b/.git/git-receive-pack", true, "t1", http.StatusForbidden, authz{"t1", "a/b", OpPush}},
+	}
+	for _, test := range tests {
+		calls = nil
+		trans.called = false
+
+		req, err := http.NewRequest(test.method, server.URL+test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.gitClient {
+			req.Header.Set("user-agent", "git/2.0")
+		}
+		if test.token != "" {
+			req.Header.Set("authorization", "Bearer "+test.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got := resp.StatusCode; got != test.wantStatus {
+			t.Errorf("%s %s: got code %d, want %d", test.method, test.url, got, test.wantStatus)
+		}
+		if want := []authz{test.wantAuthz}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("%s %s: got AuthorizeRepo calls %+v, want %+v", test.method, test.url, calls, want)
+		}
+		if test.gitClient && trans.called != (test.wantStatus == http.StatusOK) {
+			t.Errorf("%s %s: got git transport called %v", test.method, test.url, trans.called)
+		}
+	}
+}
+
+type mockGitTransporter struct {
+	t      *testing.T
+	called bool
+}
+
+func (m *mockGitTransporter) GitTransport(repoPath string) (git.GitTransport, error) {
+	m.called = true
+	return mockGitTransport{}, nil
+}
+
+type mockGitTransport struct{}
+
+func (mockGitTransport) InfoRefs(ctx context.Context, w io.Writer, service string) error {
+	return nil
+}
+func (mockGitTransport) ReceivePack(ctx context.Context, w io.Writer, r io.Reader, opt git.GitTransportOpt) error {
+	return nil
+}
+func (mockGitTransport) UploadPack(ctx context.Context, w io.Writer, r io.Reader, opt git.GitTransportOpt) error {
+	return nil
+}
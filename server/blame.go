@@ -32,18 +32,18 @@ func (h *Handler) serveRepoBlameFile(w http.ResponseWriter, r *http.Request) err
 		}
 
 		if opt.NewestCommit != "" {
-			_, canon, err := checkCommitID(string(opt.NewestCommit))
+			_, canon, err := checkCommitID(repo, string(opt.NewestCommit))
 			if err != nil {
 				return err
 			}
 			if canon {
-				setLongCache(w)
+				h.setLongCache(w, r)
 			} else {
-				setShortCache(w)
+				h.setShortCache(w, r)
 			}
 		}
 
-		return writeJSON(w, hunks)
+		return writeResponse(w, r, hunks)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("BlameFile not yet implemented for %T", repo)}
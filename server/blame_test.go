@@ -56,6 +56,41 @@ func TestServeRepoBlameFile(t *testing.T) {
 	}
 }
 
+func TestServeRepoBlameFile_ignoreRevs(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	path := "f"
+	opt := vcs.BlameOptions{
+		NewestCommit:   vcs.CommitID(strings.Repeat("a", 40)),
+		IgnoreRevs:     []vcs.CommitID{"r1", "r2"},
+		IgnoreRevsFile: ".git-blame-ignore-revs",
+	}
+
+	rm := &mockBlameFile{
+		t:     t,
+		path:  path,
+		opt:   opt,
+		hunks: []*vcs.Hunk{{StartLine: 1, EndLine: 2, CommitID: "c"}},
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoBlameFile(repoPath, path, &opt).String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+}
+
 type mockBlameFile struct {
 	t *testing.T
 
@@ -74,7 +109,7 @@ func (m *mockBlameFile) BlameFile(path string, opt *vcs.BlameOptions) ([]*vcs.Hu
 	if path != m.path {
 		m.t.Errorf("mock: got path %q, want %q", path, m.path)
 	}
-	if *opt != m.opt {
+	if !reflect.DeepEqual(*opt, m.opt) {
 		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
 	}
 	m.called = true
@@ -8,7 +8,7 @@ import (
 )
 
 func (h *Handler) serveRepoBranches(w http.ResponseWriter, r *http.Request) error {
-	repo, _, done, err := h.getRepo(r)
+	repo, repoPath, done, err := h.getRepo(r)
 	if err != nil {
 		return err
 	}
@@ -18,17 +18,25 @@ func (h *Handler) serveRepoBranches(w http.ResponseWriter, r *http.Request) erro
 	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
 		return err
 	}
+	if opt.ContainsCommit != "" {
+		commitID, _, err := checkCommitID(repo, string(opt.ContainsCommit))
+		if err != nil {
+			return err
+		}
+		opt.ContainsCommit = commitID
+	}
 
 	type branches interface {
 		Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error)
 	}
 	if repo, ok := repo.(branches); ok {
+		h.setRefsGeneration(w, repoPath)
 		branches, err := repo.Branches(opt)
 		if err != nil {
 			return err
 		}
 
-		return writeJSON(w, branches)
+		return writeResponse(w, r, branches)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Branches not yet implemented for %T", repo)}
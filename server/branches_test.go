@@ -50,9 +50,45 @@ func TestServeRepoBranches(t *testing.T) {
 	}
 }
 
+func TestServeRepoBranches_ContainsCommit(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.BranchesOptions{ContainsCommit: "abcd"}
+
+	rm := &mockBranches{
+		t:   t,
+		opt: opt,
+		err: vcs.ErrCommitNotFound,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoBranches(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
 type mockBranches struct {
 	t *testing.T
 
+	// expected args
+	opt vcs.BranchesOptions
+
 	// return values
 	branches []*vcs.Branch
 	err      error
@@ -60,7 +96,10 @@ type mockBranches struct {
 	called bool
 }
 
-func (m *mockBranches) Branches(vcs.BranchesOptions) ([]*vcs.Branch, error) {
+func (m *mockBranches) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
+	if opt != m.opt {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
 	m.called = true
 	return m.branches, m.err
 }
@@ -1,16 +1,93 @@
 package server
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/vcsstore"
+)
+
+// The default max-ages of responses (see vcsstore.Config.CacheMaxAges).
+const (
+	defaultLongCacheMaxAge  = 365 * 24 * time.Hour
+	defaultShortCacheMaxAge = 7 * time.Second
+)
 
 var (
-	longCacheControl  = "max-age=31536000, public"
-	shortCacheControl = "max-age=7, public"
+	longCacheControl  = cacheControl(defaultLongCacheMaxAge)
+	shortCacheControl = cacheControl(defaultShortCacheMaxAge)
 )
 
-func setLongCache(w http.ResponseWriter) {
-	w.Header().Set("cache-control", longCacheControl)
+func cacheControl(maxAge time.Duration) string {
+	return fmt.Sprintf("max-age=%d, public", int64(maxAge/time.Second))
+}
+
+// setLongCache sets the Cache-Control header of a response that never
+// changes.
+func (h *Handler) setLongCache(w http.ResponseWriter, r *http.Request) {
+	maxAge := h.cacheMaxAges(r).Long
+	if maxAge == 0 {
+		maxAge = defaultLongCacheMaxAge
+	}
+	w.Header().Set("cache-control", cacheControl(maxAge))
 }
 
-func setShortCache(w http.ResponseWriter) {
-	w.Header().Set("cache-control", shortCacheControl)
+// setShortCache sets the Cache-Control header of a response that may
+// change.
+func (h *Handler) setShortCache(w http.ResponseWriter, r *http.Request) {
+	maxAge := h.cacheMaxAges(r).Short
+	if maxAge == 0 {
+		maxAge = defaultShortCacheMaxAge
+	}
+	w.Header().Set("cache-control", cacheControl(maxAge))
+}
+
+// cacheMaxAges returns the configured max-ages of responses to r's
+// route.
+func (h *Handler) cacheMaxAges(r *http.Request) vcsstore.CacheMaxAges {
+	maxAges := h.CacheMaxAges
+	if rt := mux.CurrentRoute(r); rt != nil {
+		if o, present := h.RouteCacheMaxAges[rt.GetName()]; present {
+			if o.Long != 0 {
+				maxAges.Long = o.Long
+			}
+			if o.Short != 0 {
+				maxAges.Short = o.Short
+			}
+		}
+	}
+	return maxAges
+}
+
+// checkLastModified sets the Last-Modified header of the response to
+// modtime (unless it is zero). If the request's If-Modified-Since
+// header shows that the client already has the response, it responds
+// with 304 Not Modified and returns true. It must be called after the
+// response's other headers (such as Cache-Control) are set.
+//
+// It may only be called for responses at a canonical commit ID. At
+// other revs, the modtime doesn't identify the response: the rev may
+// come to refer to a commit with different (but older) contents.
+func checkLastModified(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
+	if modtime.IsZero() {
+		return false
+	}
+	w.Header().Set("last-modified", modtime.UTC().Format(http.TimeFormat))
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("if-modified-since"))
+	if err != nil {
+		return false
+	}
+	// HTTP dates have 1-second resolution.
+	if modtime.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.Header().Add("vary", "Accept") // as writeResponse would have
+	w.WriteHeader(http.StatusNotModified)
+	return true
 }
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestCacheControl_configured(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo: &mockFileSystem{
+			t:  t,
+			at: commitID,
+			fs: mapFS(map[string]string{"myfile": "mydata"}),
+		},
+	}
+
+	tests := map[string]struct {
+		maxAges      vcsstore.CacheMaxAges
+		routeMaxAges map[string]vcsstore.CacheMaxAges
+		want         string
+	}{
+		"default": {
+			want: longCacheControl,
+		},
+		"configured": {
+			maxAges: vcsstore.CacheMaxAges{Long: time.Hour},
+			want:    "max-age=3600, public",
+		},
+		"route override": {
+			maxAges: vcsstore.CacheMaxAges{Long: time.Hour},
+			routeMaxAges: map[string]vcsstore.CacheMaxAges{
+				vcsclient.RouteRepoTreeEntry: {Long: 24 * time.Hour},
+			},
+			want: "max-age=86400, public",
+		},
+		"other route override": {
+			maxAges: vcsstore.CacheMaxAges{Long: time.Hour},
+			routeMaxAges: map[string]vcsstore.CacheMaxAges{
+				vcsclient.RouteRepoCommit: {Long: 24 * time.Hour},
+			},
+			want: "max-age=3600, public",
+		},
+		"route override of other max-age": {
+			maxAges: vcsstore.CacheMaxAges{Long: time.Hour},
+			routeMaxAges: map[string]vcsstore.CacheMaxAges{
+				vcsclient.RouteRepoTreeEntry: {Short: time.Minute},
+			},
+			want: "max-age=3600, public",
+		},
+	}
+	for label, test := range tests {
+		testHandler.CacheMaxAges = test.maxAges
+		testHandler.RouteCacheMaxAges = test.routeMaxAges
+
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "myfile").String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("%s: got status code %d, want %d", label, got, want)
+		}
+		if cc := resp.Header.Get("cache-control"); cc != test.want {
+			t.Errorf("%s: got cache-control %q, want %q", label, cc, test.want)
+		}
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	testHandler.Service = vcsstore.NewService(&vcsstore.Config{StorageDir: storageDir})
+
+	repoPath := "a.b/c"
+	cmd := exec.Command("sh", "-c", "git init -q && echo a > f && git add f && git commit -q -m a && git rev-parse HEAD")
+	cmd.Dir = filepath.Join(storageDir, repoPath)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_AUTHOR_DATE=2006-01-02T15:04:05Z",
+		"GIT_COMMITTER_NAME=c", "GIT_COMMITTER_EMAIL=c@c.com", "GIT_COMMITTER_DATE=2007-02-03T16:05:06Z",
+	)
+	if err := os.MkdirAll(cmd.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git failed: %s\n%s", err, out)
+	}
+	commitID := vcs.CommitID(strings.TrimSpace(string(out)))
+
+	get := func(url, ifModifiedSince string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifModifiedSince != "" {
+			req.Header.Set("if-modified-since", ifModifiedSince)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	tests := map[string]struct {
+		url              string
+		wantLastModified string // "" to only check that it is set
+	}{
+		"tree entry": {
+			url: testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "f").String(),
+		},
+		"commit": {
+			url:              testHandler.router.URLToRepoCommit(repoPath, commitID).String(),
+			wantLastModified: "Sat, 03 Feb 2007 16:05:06 GMT", // committer date
+		},
+	}
+	for label, test := range tests {
+		resp := get(test.url, "")
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("%s: got status code %d, want %d", label, got, want)
+			continue
+		}
+		lastModified := resp.Header.Get("last-modified")
+		if lastModified == "" || (test.wantLastModified != "" && lastModified != test.wantLastModified) {
+			t.Errorf("%s: got Last-Modified %q, want %q", label, lastModified, test.wantLastModified)
+			continue
+		}
+
+		resp = get(test.url, lastModified)
+		if got, want := resp.StatusCode, http.StatusNotModified; got != want {
+			t.Errorf("%s: with If-Modified-Since equal to Last-Modified: got status code %d, want %d", label, got, want)
+		}
+		if cc := resp.Header.Get("cache-control"); cc != longCacheControl {
+			t.Errorf("%s: with If-Modified-Since equal to Last-Modified: got cache-control %q, want %q", label, cc, longCacheControl)
+		}
+
+		resp = get(test.url, "Mon, 02 Jan 2006 15:04:04 GMT")
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("%s: with earlier If-Modified-Since: got status code %d, want %d", label, got, want)
+		}
+	}
+
+	// If-Modified-Since is ignored for responses at abbreviated commit
+	// IDs, which may become ambiguous.
+	resp := get(testHandler.router.URLToRepoTreeEntry(repoPath, commitID[:7], "f").String(), "Mon, 01 Jan 2035 00:00:00 GMT")
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("tree entry at abbreviated commit ID: with If-Modified-Since: got status code %d, want %d", got, want)
+	}
+	if lm := resp.Header.Get("last-modified"); lm != "" {
+		t.Errorf("tree entry at abbreviated commit ID: got Last-Modified %q, want none", lm)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func (h *Handler) serveRepoCommit(w http.ResponseWriter, r *http.Request) error {
@@ -17,7 +18,7 @@ func (h *Handler) serveRepoCommit(w http.ResponseWriter, r *http.Request) error
 	}
 	defer done()
 
-	commitID, canon, err := getCommitID(r)
+	commitID, canon, err := getCommitID(r, repo)
 	if err != nil {
 		return err
 	}
@@ -32,15 +33,18 @@ func (h *Handler) serveRepoCommit(w http.ResponseWriter, r *http.Request) error
 		}
 
 		if commit.ID != commitID {
-			setShortCache(w)
+			h.setShortCache(w, r)
 			http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, commit.ID).String(), http.StatusFound)
 			return nil
 		}
 
 		if canon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		}
-		return writeJSON(w, commit)
+		if canon && checkLastModified(w, r, vcsclient.CommitCommitter(commit).Time()) {
+			return nil
+		}
+		return writeResponse(w, r, commit)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("GetCommit not yet implemented for %T", repo)}
@@ -48,17 +52,29 @@ func (h *Handler) serveRepoCommit(w http.ResponseWriter, r *http.Request) error
 
 // getCommitID retrieves the CommitID from the route variables and
 // runs checkCommitID on it.
-func getCommitID(r *http.Request) (vcs.CommitID, bool, error) {
-	return checkCommitID(mux.Vars(r)["CommitID"])
+func getCommitID(r *http.Request, repo interface{}) (vcs.CommitID, bool, error) {
+	return checkCommitID(repo, mux.Vars(r)["CommitID"])
 }
 
-// checkCommitID returns whether the commit ID is canonical (i.e., the
-// full 40-character commit ID), and an error (if any).
-func checkCommitID(commitID string) (vcs.CommitID, bool, error) {
+// checkCommitID returns whether the commit ID of a commit in repo is
+// canonical (i.e., the full 40-character commit ID, or any bzr
+// revision ID), and an error (if any).
+func checkCommitID(repo interface{}, commitID string) (vcs.CommitID, bool, error) {
 	if commitID == "" {
 		return "", false, &httpError{http.StatusBadRequest, errors.New("CommitID is empty")}
 	}
 
+	if repoVCSType(repo) == "bzr" {
+		// bzr revision IDs (such as
+		// "a@a.com-20140506192021-k3m1bqv0ynxqm3ez") are
+		// printable ASCII. They never contain "..", which would
+		// make a revision range of a revision spec.
+		if !isBzrRevisionID(commitID) {
+			return "", false, &httpError{http.StatusBadRequest, errors.New("CommitID must be a bzr revision ID")}
+		}
+		return vcs.CommitID(commitID), true, nil
+	}
+
 	if !isLowercaseHex(commitID) {
 		return "", false, &httpError{http.StatusBadRequest, errors.New("CommitID must be lowercase hex")}
 	}
@@ -70,6 +86,12 @@ func commitIDIsCanon(commitID string) bool {
 	return len(commitID) == 40
 }
 
+func isBzrRevisionID(s string) bool {
+	return !strings.Contains(s, "..") && strings.IndexFunc(s, func(c rune) bool {
+		return c < 0x21 || c > 0x7e
+	}) == -1
+}
+
 func isLowercaseHex(s string) bool {
 	return strings.IndexFunc(s, func(c rune) bool {
 		return !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f'))
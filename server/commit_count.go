@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func (h *Handler) serveRepoCommitCount(w http.ResponseWriter, r *http.Request) error {
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var opt vcs.CommitsOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+
+	head, canon, err := checkCommitID(repo, string(opt.Head))
+	if err != nil {
+		return err
+	}
+	opt.Head = head
+	if opt.Base != "" {
+		base, baseCanon, err := checkCommitID(repo, string(opt.Base))
+		if err != nil {
+			return err
+		}
+		opt.Base = base
+		canon = canon && baseCanon
+	}
+	if err := vcs.CheckFollow(opt); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+
+	if repo, ok := repo.(vcs.CommitCounter); ok {
+		// Share cached counts with the Commits endpoint, whose totals
+		// are the same.
+		cache, _ := h.Service.(vcsstore.CommitCountCache)
+		var count uint
+		var cached bool
+		if cache != nil {
+			count, cached = cache.CommitCount(repoPath, opt)
+			observeCommitCountCacheLookup(cached)
+		}
+		if !cached {
+			count, err = repo.CountCommits(opt)
+			if err != nil {
+				return err
+			}
+			if cache != nil {
+				cache.SetCommitCount(repoPath, opt, count)
+			}
+		}
+
+		if canon {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		w.Header().Set(vcsclient.CommitCountHeader, strconv.FormatUint(uint64(count), 10))
+		return writeResponse(w, r, count)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("CountCommits not yet implemented for %T", repo)}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoCommitCount(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{
+		Head:   "abcd",
+		Base:   "ef01",
+		Path:   "f",
+		Author: "a@a.com",
+		Since:  time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		Until:  time.Date(2007, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+
+	rm := &mockCountCommits{
+		t:     t,
+		opt:   opt,
+		count: 123,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitCount(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	if count, want := resp.Header.Get(vcsclient.CommitCountHeader), "123"; count != want {
+		t.Errorf("got commit count header %q, want %q", count, want)
+	}
+	var count uint
+	if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != rm.count {
+		t.Errorf("got count %d, want %d", count, rm.count)
+	}
+}
+
+func TestServeRepoCommitCount_cached(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: vcs.CommitID(strings.Repeat("a", 40))}
+
+	rm := &mockCountCommits{t: t, opt: opt, count: 123}
+	sm := &mockServiceWithCommitCountCache{
+		mockServiceForExistingRepo: mockServiceForExistingRepo{
+			t:        t,
+			repoPath: repoPath,
+			repo:     rm,
+		},
+		counts: map[vcs.CommitID]uint{},
+	}
+	testHandler.Service = sm
+
+	getCount := func() string {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitCount(repoPath, opt).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.Header.Get(vcsclient.CommitCountHeader)
+	}
+
+	// The first request counts the commits and caches the count.
+	if count, want := getCount(), "123"; count != want {
+		t.Errorf("got commit count header %q, want %q", count, want)
+	}
+	if got, want := sm.counts[opt.Head], uint(123); got != want {
+		t.Errorf("got cached count %d, want %d", got, want)
+	}
+
+	// The second request uses the cached count and doesn't ask the
+	// repository to count commits.
+	sm.counts[opt.Head] = 456
+	rm.called = false
+	if count, want := getCount(), "456"; count != want {
+		t.Errorf("got commit count header %q, want %q", count, want)
+	}
+	if rm.called {
+		t.Errorf("got CountCommits called, want the cached count used")
+	}
+}
+
+func TestServeRepoCommitCount_invalidFollow(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", Follow: true}
+
+	rm := &mockCountCommits{t: t}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitCount(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+	if rm.called {
+		t.Errorf("got CountCommits called, want the options rejected first")
+	}
+}
+
+type mockCountCommits struct {
+	t *testing.T
+
+	// expected args
+	opt vcs.CommitsOptions
+
+	// return values
+	count uint
+	err   error
+
+	called bool
+}
+
+func (m *mockCountCommits) CountCommits(opt vcs.CommitsOptions) (uint, error) {
+	if !reflect.DeepEqual(opt, m.opt) {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
+	m.called = true
+	return m.count, m.err
+}
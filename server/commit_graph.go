@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoCommitGraph(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var opt vcs.CommitsOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+
+	head, canon, err := checkCommitID(repo, string(opt.Head))
+	if err != nil {
+		return err
+	}
+	opt.Head = head
+	if opt.Base != "" {
+		base, baseCanon, err := checkCommitID(repo, string(opt.Base))
+		if err != nil {
+			return err
+		}
+		opt.Base = base
+		canon = canon && baseCanon
+	}
+
+	if repo, ok := repo.(vcs.CommitGrapher); ok {
+		nodes, err := repo.CommitGraph(opt)
+		if err != nil {
+			return err
+		}
+
+		// Generation numbers are only known for commits in the
+		// commit-graph file, which may be written later.
+		if opt.IncludeGenerations && canon {
+			for _, node := range nodes {
+				if node.Generation == 0 {
+					canon = false
+					break
+				}
+			}
+		}
+
+		if canon {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		return writeResponse(w, r, nodes)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("CommitGraph not yet implemented for %T", repo)}
+}
+
+// commitGraphWriter writes repositories' commit-graph files in the
+// background.
+type commitGraphWriter struct {
+	mu sync.Mutex
+
+	// pending is the set of repos whose commit-graph file is being
+	// written. A repo's value is true if it was updated again in the
+	// meantime, so its commit-graph file must be written again.
+	pending map[string]bool
+
+	wg sync.WaitGroup // for tests to wait for writes to finish
+}
+
+// writeCommitGraph starts writing the commit-graph file of the
+// repository at repoPath in the background if h.WriteCommitGraphs is
+// set, so that the request that updated the repository needn't wait
+// for it. If it's already being written, it is written again
+// afterwards (instead of concurrently).
+func (h *Handler) writeCommitGraph(repoPath string) {
+	if !h.WriteCommitGraphs {
+		return
+	}
+
+	w := &h.commitGraphs
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, writing := w.pending[repoPath]; writing {
+		w.pending[repoPath] = true
+		return
+	}
+	if w.pending == nil {
+		w.pending = map[string]bool{}
+	}
+	w.pending[repoPath] = false
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			h.writeCommitGraphNow(repoPath)
+
+			w.mu.Lock()
+			again := w.pending[repoPath]
+			if again {
+				w.pending[repoPath] = false
+			} else {
+				delete(w.pending, repoPath)
+			}
+			w.mu.Unlock()
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+// writeCommitGraphNow writes the commit-graph file of the repository
+// at repoPath. Errors are logged, not returned, because the repository
+// was already updated successfully.
+func (h *Handler) writeCommitGraphNow(repoPath string) {
+	repo, err := h.Service.Open(repoPath)
+	if err != nil {
+		h.Log.Printf("Writing commit-graph: opening repo %s failed: %s.", repoPath, err)
+		return
+	}
+	defer h.Service.Close(repoPath)
+
+	if repo, ok := repo.(vcs.CommitGraphWriter); ok {
+		start := time.Now()
+		if err := repo.WriteCommitGraph(); err != nil {
+			h.Log.Printf("Writing commit-graph for repo %s failed: %s.", repoPath, err)
+			return
+		}
+		h.Log.Printf("Wrote commit-graph for repo %s in %s.", repoPath, time.Since(start))
+	}
+}
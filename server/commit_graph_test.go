@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoCommitGraph(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", N: 2, Skip: 3}
+
+	rm := &mockCommitGraph{
+		t:     t,
+		opt:   opt,
+		nodes: []*vcs.CommitNode{{ID: "abcd", Parents: []vcs.CommitID{"wxyz", "ef01"}}, {ID: "wxyz"}},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitGraph(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var nodes []*vcs.CommitNode
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nodes, rm.nodes) {
+		t.Errorf("got nodes %+v, want %+v", nodes, rm.nodes)
+	}
+}
+
+func TestServeRepoCommitGraph_generations(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	head := vcs.CommitID("abcdabcdabcdabcdabcdabcdabcdabcdabcdabcd")
+	opt := vcs.CommitsOptions{Head: head, IncludeGenerations: true}
+
+	tests := map[string]struct {
+		nodes            []*vcs.CommitNode
+		wantCacheControl string
+	}{
+		"all known": {
+			nodes:            []*vcs.CommitNode{{ID: head, Parents: []vcs.CommitID{"ef01"}, Generation: 2}, {ID: "ef01", Generation: 1}},
+			wantCacheControl: longCacheControl,
+		},
+		// A commit that isn't in the commit-graph file yet may get a
+		// generation number later.
+		"some unknown": {
+			nodes:            []*vcs.CommitNode{{ID: head, Parents: []vcs.CommitID{"ef01"}}, {ID: "ef01", Generation: 1}},
+			wantCacheControl: shortCacheControl,
+		},
+	}
+	for label, test := range tests {
+		rm := &mockCommitGraph{t: t, opt: opt, nodes: test.nodes}
+		testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm}
+
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitGraph(repoPath, opt).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var nodes []*vcs.CommitNode
+		err = json.NewDecoder(resp.Body).Decode(&nodes)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !rm.called {
+			t.Errorf("%s: !called", label)
+		}
+		if !reflect.DeepEqual(nodes, test.nodes) {
+			t.Errorf("%s: got nodes %+v, want %+v", label, nodes, test.nodes)
+		}
+		if cc := resp.Header.Get("cache-control"); cc != test.wantCacheControl {
+			t.Errorf("%s: got cache-control %q, want %q", label, cc, test.wantCacheControl)
+		}
+	}
+}
+
+func TestServeRepoCreateOrUpdate_writeCommitGraph(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	for _, writeCommitGraphs := range []bool{false, true} {
+		rm := &mockCommitGraphWriter{mockUpdateEverythinger: mockUpdateEverythinger{t: t}}
+		testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm}
+		testHandler.WriteCommitGraphs = writeCommitGraphs
+
+		resp, err := http.Post(server.URL+testHandler.router.URLToRepo(repoPath).String(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		testHandler.commitGraphs.wg.Wait()
+
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("WriteCommitGraphs=%v: got code %d, want %d", writeCommitGraphs, got, want)
+		}
+		if !rm.called {
+			t.Errorf("WriteCommitGraphs=%v: !called", writeCommitGraphs)
+		}
+		if rm.written != writeCommitGraphs {
+			t.Errorf("WriteCommitGraphs=%v: got written %v", writeCommitGraphs, rm.written)
+		}
+	}
+}
+
+// TestWriteCommitGraph_coalesced tests that updates to a repository
+// while its commit-graph file is being written cause it to be written
+// once more afterwards, not concurrently.
+func TestWriteCommitGraph_coalesced(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &blockingCommitGraphWriter{started: make(chan struct{}, 10), release: make(chan struct{})}
+	testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm}
+	testHandler.WriteCommitGraphs = true
+
+	testHandler.writeCommitGraph(repoPath)
+	<-rm.started
+	for i := 0; i < 3; i++ {
+		testHandler.writeCommitGraph(repoPath)
+	}
+	close(rm.release)
+	testHandler.commitGraphs.wg.Wait()
+
+	if got, want := atomic.LoadInt32(&rm.writes), int32(2); got != want {
+		t.Errorf("got %d writes, want %d", got, want)
+	}
+}
+
+type blockingCommitGraphWriter struct {
+	started chan struct{} // receives when each write starts
+	release chan struct{} // writes finish once this is closed
+
+	writes int32
+}
+
+func (m *blockingCommitGraphWriter) WriteCommitGraph() error {
+	atomic.AddInt32(&m.writes, 1)
+	m.started <- struct{}{}
+	<-m.release
+	return nil
+}
+
+func (m *blockingCommitGraphWriter) VerifyCommitGraph() error { return nil }
+
+type mockCommitGraphWriter struct {
+	mockUpdateEverythinger
+
+	written bool
+}
+
+func (m *mockCommitGraphWriter) WriteCommitGraph() error {
+	if !m.called {
+		m.t.Errorf("mock: WriteCommitGraph called before UpdateEverything")
+	}
+	m.written = true
+	return nil
+}
+
+func (m *mockCommitGraphWriter) VerifyCommitGraph() error { return nil }
+
+type mockCommitGraph struct {
+	t *testing.T
+
+	// expected args
+	opt vcs.CommitsOptions
+
+	// return values
+	nodes []*vcs.CommitNode
+	err   error
+
+	called bool
+}
+
+func (m *mockCommitGraph) CommitGraph(opt vcs.CommitsOptions) ([]*vcs.CommitNode, error) {
+	if !reflect.DeepEqual(opt, m.opt) {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
+	m.called = true
+	return m.nodes, m.err
+}
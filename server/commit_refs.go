@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func (h *Handler) serveRepoCommitRefs(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	commitID, _, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.CommitRefsLister); ok {
+		branches, tags, err := repo.RefsForCommit(commitID)
+		if err != nil {
+			return err
+		}
+
+		// Don't cache for long even if the commit ID is canonical,
+		// because branches and tags can be created, moved, or deleted.
+		h.setShortCache(w, r)
+		return writeResponse(w, r, &vcsclient.CommitRefs{Branches: branches, Tags: tags})
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("RefsForCommit not yet implemented for %T", repo)}
+}
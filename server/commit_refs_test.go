@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoCommitRefs(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID("abcd")
+
+	rm := &mockRefsForCommit{
+		t:        t,
+		commitID: commitID,
+		branches: []string{"main", "release-2"},
+		tags:     []string{"v2.0"},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitRefs(repoPath, commitID).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var refs *vcsclient.CommitRefs
+	if err := json.NewDecoder(resp.Body).Decode(&refs); err != nil {
+		t.Fatal(err)
+	}
+	want := &vcsclient.CommitRefs{Branches: rm.branches, Tags: rm.tags}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("got refs %+v, want %+v", refs, want)
+	}
+}
+
+type mockRefsForCommit struct {
+	t *testing.T
+
+	// expected args
+	commitID vcs.CommitID
+
+	// return values
+	branches, tags []string
+	err            error
+
+	called bool
+}
+
+func (m *mockRefsForCommit) RefsForCommit(commitID vcs.CommitID) ([]string, []string, error) {
+	if commitID != m.commitID {
+		m.t.Errorf("mock: got commitID %q, want %q", commitID, m.commitID)
+	}
+	m.called = true
+	return m.branches, m.tags, m.err
+}
@@ -2,14 +2,227 @@ package server
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/bzrcmd"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
+// TestServeRepoCommit_authorAndCommitter tests that a commit's
+// author and committer are returned distinctly and in full.
+func TestServeRepoCommit_authorAndCommitter(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	testHandler.Service = vcsstore.NewService(&vcsstore.Config{StorageDir: storageDir})
+
+	repoPath := "a.b/c"
+	cmd := exec.Command("sh", "-c", "git init -q && git commit -q --allow-empty -m a && git rev-parse HEAD")
+	cmd.Dir = filepath.Join(storageDir, repoPath)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_AUTHOR_DATE=2006-01-02T15:04:05+0900",
+		"GIT_COMMITTER_NAME=c", "GIT_COMMITTER_EMAIL=c@c.com", "GIT_COMMITTER_DATE=2007-02-03T16:05:06-0700",
+	)
+	if err := os.MkdirAll(cmd.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git failed: %s\n%s", err, out)
+	}
+	commitID := vcs.CommitID(strings.TrimSpace(string(out)))
+
+	wantAuthor := vcs.NewSignature("a", "a@a.com", mustParseTime(t, "2006-01-02T15:04:05+09:00"))
+	wantCommitter := vcs.NewSignature("c", "c@c.com", mustParseTime(t, "2007-02-03T16:05:06-07:00"))
+	check := func(label string, commit *vcs.Commit) {
+		if got := vcsclient.CommitAuthor(commit); got != wantAuthor {
+			t.Errorf("%s: got author %+v, want %+v", label, got, wantAuthor)
+		}
+		if got, want := vcsclient.CommitAuthor(commit).Time().Format(time.RFC3339), "2006-01-02T15:04:05+09:00"; got != want {
+			t.Errorf("%s: got author time %s, want %s", label, got, want)
+		}
+		if commit.Committer == nil {
+			t.Errorf("%s: got no committer", label)
+		} else if got := vcsclient.CommitCommitter(commit); got != wantCommitter {
+			t.Errorf("%s: got committer %+v, want %+v", label, got, wantCommitter)
+		}
+		if got, want := vcsclient.CommitCommitter(commit).TzOffset, int32(-7*60*60); got != want {
+			t.Errorf("%s: got committer time zone offset %d, want %d", label, got, want)
+		}
+		if got, want := vcsclient.CommitCommitter(commit).Time().Format(time.RFC3339), "2007-02-03T16:05:06-07:00"; got != want {
+			t.Errorf("%s: got committer time %s, want %s", label, got, want)
+		}
+	}
+
+	baseURL, _ := url.Parse(server.URL)
+	repo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.GetCommit(commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("GetCommit", commit)
+
+	commits, _, err := repo.Commits(vcs.CommitsOptions{Head: commitID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("got %d commits, want 1", len(commits))
+	}
+	check("Commits", commits[0])
+
+	// The JSON representation has both signatures in full.
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommit(repoPath, commitID).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var jsonCommit struct {
+		Author, Committer map[string]interface{}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jsonCommit); err != nil {
+		t.Fatal(err)
+	}
+	for _, sig := range []map[string]interface{}{jsonCommit.Author, jsonCommit.Committer} {
+		for _, field := range []string{"name", "email", "date", "tz_offset"} {
+			if _, ok := sig[field]; !ok {
+				t.Errorf("got JSON signature %v, want field %q", sig, field)
+			}
+		}
+	}
+	if got, want := jsonCommit.Committer["tz_offset"], float64(-7*60*60); got != want {
+		t.Errorf("got JSON committer tz_offset %v, want %v", got, want)
+	}
+}
+
+func TestCheckCommitID(t *testing.T) {
+	gitRepo, bzrRepo := &mockGetCommit{}, &bzrcmd.Repository{}
+	tests := []struct {
+		repo      interface{}
+		commitID  string
+		wantCanon bool
+		wantErr   bool
+	}{
+		{gitRepo, "abcd", false, false},
+		{gitRepo, strings.Repeat("a", 40), true, false},
+		{gitRepo, "ABCD", false, true},
+		{gitRepo, "a@a.com-20140506192021-k3m1bqv0ynxqm3ez", false, true},
+		{bzrRepo, "a@a.com-20140506192021-k3m1bqv0ynxqm3ez", true, false},
+		{bzrRepo, strings.Repeat("a", 40), true, false},
+		{bzrRepo, "a@a.com..branch:/tmp/b", false, true},
+		{bzrRepo, "a b", false, true},
+		{bzrRepo, "", false, true},
+	}
+	for _, test := range tests {
+		_, canon, err := checkCommitID(test.repo, test.commitID)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%T %q: got error %v, want error %v", test.repo, test.commitID, err, test.wantErr)
+			continue
+		}
+		if canon != test.wantCanon {
+			t.Errorf("%T %q: got canon %v, want %v", test.repo, test.commitID, canon, test.wantCanon)
+		}
+	}
+}
+
+// TestServeRepoCommit_bzr tests that the commit and tree routes work
+// for bzr repositories, whose commit IDs are revision IDs (not hex).
+func TestServeRepoCommit_bzr(t *testing.T) {
+	if _, err := exec.LookPath("bzr"); err != nil {
+		t.Skip("bzr not found")
+	}
+
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	testHandler.Service = vcsstore.NewService(&vcsstore.Config{StorageDir: storageDir})
+
+	repoPath := "a.b/c"
+	cmd := exec.Command("bash", "-c", "bzr init -q && bzr whoami --branch 'a <a@a.com>' && echo -n hello > f && bzr add -q f && bzr commit -q -m foo && bzr revision-info")
+	cmd.Dir = filepath.Join(storageDir, repoPath)
+	if err := os.MkdirAll(cmd.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bzr failed: %s\n%s", err, out)
+	}
+	fields := strings.Fields(string(out)) // "REVNO REVID"
+	if len(fields) != 2 {
+		t.Fatalf("unexpected `bzr revision-info` output: %q", out)
+	}
+	commitID := vcs.CommitID(fields[1])
+
+	baseURL, _ := url.Parse(server.URL)
+	repo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := repo.ResolveRevision("-1"); err != nil {
+		t.Fatal(err)
+	} else if got != commitID {
+		t.Errorf("got revision -1 %q, want %q", got, commitID)
+	}
+
+	commit, err := repo.GetCommit(commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.ID != commitID || commit.Message != "foo" {
+		t.Errorf("got commit %+v, want ID %q and message %q", commit, commitID, "foo")
+	}
+
+	fs, err := repo.FileSystem(commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fs.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("got file contents %q, want %q", data, "hello")
+	}
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
 func TestServeRepoCommit(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
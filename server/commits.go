@@ -1,16 +1,20 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func (h *Handler) serveRepoCommits(w http.ResponseWriter, r *http.Request) error {
-	repo, _, done, err := h.getRepo(r)
+	repo, repoPath, done, err := h.getRepo(r)
 	if err != nil {
 		return err
 	}
@@ -21,31 +25,149 @@ func (h *Handler) serveRepoCommits(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	head, canon, err := checkCommitID(string(opt.Head))
+	head, canon, err := checkCommitID(repo, string(opt.Head))
 	if err != nil {
 		return err
 	}
 	opt.Head = head
+	if opt.Base != "" {
+		base, baseCanon, err := checkCommitID(repo, string(opt.Base))
+		if err != nil {
+			return err
+		}
+		opt.Base = base
+		canon = canon && baseCanon
+	}
+	if opt.Cursor != "" {
+		if _, err := vcs.ParseCommitsCursor(opt.Cursor); err != nil {
+			return &httpError{http.StatusBadRequest, err}
+		}
+	}
+	if err := vcs.CheckFollow(opt); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
 
 	type commits interface {
 		Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error)
 	}
 	if repo, ok := repo.(commits); ok {
-		commits, total, err := repo.Commits(opt)
+		// Counting commits is expensive, so use the cached count if
+		// there is one.
+		cache, _ := h.Service.(vcsstore.CommitCountCache)
+		var total uint
+		var cached bool
+		if cache != nil && !opt.NoTotal {
+			total, cached = cache.CommitCount(repoPath, opt)
+			observeCommitCountCacheLookup(cached)
+		}
+
+		repoOpt := opt
+		repoOpt.NoTotal = opt.NoTotal || cached
+
+		if repo, ok := repo.(vcs.CommitStreamer); ok && acceptsNDJSON(r) {
+			if canon {
+				h.setLongCache(w, r)
+			} else {
+				h.setShortCache(w, r)
+			}
+			w.Header().Set("content-type", vcsclient.NDJSONContentType)
+
+			// The total and next cursor aren't known until all
+			// commits have been streamed, so send them as trailers
+			// (unless the total is cached).
+			if cached || opt.NoTotal {
+				w.Header().Set(vcsclient.TotalCommitsHeader, strconv.FormatUint(uint64(total), 10))
+			} else {
+				w.Header().Add("Trailer", vcsclient.TotalCommitsHeader)
+			}
+			w.Header().Add("Trailer", vcsclient.NextCommitsCursorHeader)
+
+			// Only a page of N commits needs to be kept to compute
+			// the next cursor.
+			enc := json.NewEncoder(w)
+			var page []*vcs.Commit
+			var streamed bool
+			repoTotal, err := repo.StreamCommits(repoOpt, func(c *vcs.Commit) error {
+				if opt.N != 0 {
+					page = append(page, c)
+				}
+				streamed = true
+				return enc.Encode(c)
+			})
+			if err != nil {
+				if !streamed {
+					return err
+				}
+				// The 200 status was already sent, so report the
+				// error in a final record.
+				h.writeNDJSONError(w, r, enc, err)
+				return nil
+			}
+
+			if !cached && !opt.NoTotal {
+				if cache != nil {
+					cache.SetCommitCount(repoPath, opt, repoTotal)
+				}
+				w.Header().Set(vcsclient.TotalCommitsHeader, strconv.FormatUint(uint64(repoTotal), 10))
+			}
+			if cursor := vcs.NextCommitsCursor(opt, page); cursor != "" {
+				w.Header().Set(vcsclient.NextCommitsCursorHeader, cursor)
+			}
+			return nil
+		}
+
+		commits, repoTotal, err := repo.Commits(repoOpt)
 		if err != nil {
 			return err
 		}
 
+		if !cached {
+			total = repoTotal
+			if cache != nil && !opt.NoTotal {
+				cache.SetCommitCount(repoPath, opt, total)
+			}
+		}
+
 		if canon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 		}
 
 		w.Header().Set(vcsclient.TotalCommitsHeader, strconv.FormatUint(uint64(total), 10))
 
-		return writeJSON(w, commits)
+		if cursor := vcs.NextCommitsCursor(opt, commits); cursor != "" {
+			w.Header().Set(vcsclient.NextCommitsCursorHeader, cursor)
+		}
+
+		return writeResponse(w, r, commits)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Commits not yet implemented for %T", repo)}
 }
+
+// writeNDJSONError writes a vcsclient.NDJSONError record for err,
+// which occurred after the NDJSON response was begun (so it can't have
+// an error status). As in error responses, the message is omitted
+// unless h.Debug is set or err is a known error.
+func (h *Handler) writeNDJSONError(w http.ResponseWriter, r *http.Request, enc *json.Encoder, err error) {
+	h.Log.Printf("Error streaming %q (request %s) after the response began: %s.", r.URL.RequestURI(), RequestIDFromContext(r.Context()), err)
+	rec := vcsclient.NDJSONError{Error: &vcsclient.ErrorResponse{}}
+	if h.Debug || vcsclient.KnownError(unwrapHTTPError(err)) != nil {
+		rec.Error.Message = err.Error()
+	}
+	if err := enc.Encode(rec); err != nil {
+		h.Log.Printf("Error writing NDJSON error record (request %s): %s.", RequestIDFromContext(r.Context()), err)
+	}
+}
+
+// acceptsNDJSON reports whether the request's Accept header includes
+// the NDJSON media type.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(v)); err == nil && mt == vcsclient.NDJSONContentType {
+			return true
+		}
+	}
+	return false
+}
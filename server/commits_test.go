@@ -2,12 +2,19 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+	"sourcegraph.com/sqs/pbtypes"
 )
 
 func TestServeRepoCommits(t *testing.T) {
@@ -57,6 +64,423 @@ func TestServeRepoCommits(t *testing.T) {
 	}
 }
 
+func TestServeRepoCommits_msgpack(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd"}
+
+	rm := &mockCommits{
+		t:   t,
+		opt: opt,
+		commits: []*vcs.Commit{
+			{ID: "abcd", Author: vcs.Signature{Name: "a", Email: "a@a.com", Date: pbtypes.NewTimestamp(time.Unix(123, 0).UTC())}, Message: "m", Parents: []vcs.CommitID{"wxyz"}},
+		},
+		total: 1,
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+testHandler.router.URLToRepoCommits(repoPath, opt).String(), nil)
+	req.Header.Set("Accept", vcsclient.MsgpackContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("content-type"); ct != vcsclient.MsgpackContentType {
+		t.Errorf("got Content-Type %q, want %q", ct, vcsclient.MsgpackContentType)
+	}
+
+	var commits []*vcs.Commit
+	if err := vcsclient.DecodeMsgpack(resp.Body, &commits); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(commits, rm.commits) {
+		t.Errorf("got commits %+v, want %+v", commits, rm.commits)
+	}
+}
+
+func TestServeRepoCommits_base(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", Base: "ef01"}
+
+	rm := &mockCommits{
+		t:       t,
+		opt:     opt,
+		commits: []*vcs.Commit{{ID: "abcd"}},
+		total:   1,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if total, want := resp.Header.Get(vcsclient.TotalCommitsHeader), "1"; total != want {
+		t.Errorf("got total commits header %q, want %q", total, want)
+	}
+}
+
+func TestServeRepoCommits_invalidBase(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", Base: "master"}
+
+	rm := &mockCommits{t: t}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if rm.called {
+		t.Errorf("called")
+	}
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+func TestServeRepoCommits_cachedTotal(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: vcs.CommitID(strings.Repeat("a", 40)), N: 2}
+
+	rm := &mockCommits{
+		t:       t,
+		opt:     opt,
+		commits: []*vcs.Commit{{ID: "abcd"}},
+		total:   123,
+	}
+	sm := &mockServiceWithCommitCountCache{
+		mockServiceForExistingRepo: mockServiceForExistingRepo{
+			t:        t,
+			repoPath: repoPath,
+			repo:     rm,
+		},
+		counts: map[vcs.CommitID]uint{},
+	}
+	testHandler.Service = sm
+
+	getTotal := func() string {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.Header.Get(vcsclient.TotalCommitsHeader)
+	}
+
+	// The first request computes and caches the total.
+	if total, want := getTotal(), "123"; total != want {
+		t.Errorf("got total commits header %q, want %q", total, want)
+	}
+	if got, want := sm.counts[opt.Head], uint(123); got != want {
+		t.Errorf("got cached total %d, want %d", got, want)
+	}
+
+	// The second request uses the cached total and doesn't ask the
+	// repository to count commits.
+	sm.counts[opt.Head] = 456
+	rm.opt.NoTotal = true
+	if total, want := getTotal(), "456"; total != want {
+		t.Errorf("got total commits header %q, want %q", total, want)
+	}
+}
+
+func TestServeRepoCommits_cursor(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	cursor := strings.Repeat("b", 40)
+	opt := vcs.CommitsOptions{Head: "abcd", N: 1, Cursor: cursor}
+
+	rm := &mockCommits{
+		t:       t,
+		opt:     opt,
+		commits: []*vcs.Commit{{ID: vcs.CommitID(cursor), Parents: []vcs.CommitID{vcs.CommitID(strings.Repeat("c", 40))}}},
+		total:   123,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if got, want := resp.Header.Get(vcsclient.NextCommitsCursorHeader), strings.Repeat("c", 40); got != want {
+		t.Errorf("got next cursor header %q, want %q", got, want)
+	}
+}
+
+func TestServeRepoCommits_invalidCursor(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", N: 1, Cursor: "master"}
+
+	rm := &mockCommits{t: t}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if rm.called {
+		t.Errorf("called")
+	}
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+func TestServeRepoCommits_follow(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", Path: "f", Follow: true}
+
+	rm := &mockCommits{
+		t:       t,
+		opt:     opt,
+		commits: []*vcs.Commit{{ID: "abcd"}},
+		total:   1,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+func TestServeRepoCommits_invalidFollow(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", Path: "*.go", Follow: true}
+
+	rm := &mockCommits{t: t}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommits(repoPath, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if rm.called {
+		t.Errorf("called")
+	}
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+func TestServeRepoCommits_ndjson(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd", N: 2}
+
+	rm := &mockStreamCommits{mockCommits: mockCommits{
+		t:       t,
+		opt:     opt,
+		commits: []*vcs.Commit{{ID: "abcd"}, {ID: "wxyz"}},
+		total:   123,
+	}}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	req, err := http.NewRequest("GET", server.URL+testHandler.router.URLToRepoCommits(repoPath, opt).String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", vcsclient.NDJSONContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if ct := resp.Header.Get("content-type"); ct != vcsclient.NDJSONContentType {
+		t.Errorf("got content-type %q, want %q", ct, vcsclient.NDJSONContentType)
+	}
+
+	var commits []*vcs.Commit
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var c *vcs.Commit
+		if err := dec.Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		commits = append(commits, c)
+	}
+	if !reflect.DeepEqual(commits, rm.commits) {
+		t.Errorf("got commits %+v, want %+v", commits, rm.commits)
+	}
+
+	// The total is only known after the commits are streamed, so it
+	// is sent as a trailer.
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if total, want := resp.Trailer.Get(vcsclient.TotalCommitsHeader), "123"; total != want {
+		t.Errorf("got total commits trailer %q, want %q", total, want)
+	}
+}
+
+// TestServeRepoCommits_ndjsonError tests that an error after some
+// commits were streamed is reported to the client in a final error
+// record (since the response status was already sent).
+func TestServeRepoCommits_ndjsonError(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd"}
+	baseURL, _ := url.Parse(server.URL)
+	repo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		streamErr error
+		wantMsg   string // the message the client gets
+	}{
+		"known error": {
+			streamErr: vcs.ErrCommitNotFound,
+			wantMsg:   vcs.ErrCommitNotFound.Error(),
+		},
+		"other error": {
+			streamErr: errors.New("secret internal error"),
+			wantMsg:   "", // not in debug mode
+		},
+	}
+	for label, test := range tests {
+		rm := &mockStreamCommits{
+			mockCommits: mockCommits{
+				t:       t,
+				opt:     opt,
+				commits: []*vcs.Commit{{ID: "abcd"}},
+				total:   123,
+			},
+			streamErr: test.streamErr,
+		}
+		testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm}
+
+		var commits []*vcs.Commit
+		_, err := repo.(vcs.CommitStreamer).StreamCommits(opt, func(c *vcs.Commit) error {
+			commits = append(commits, c)
+			return nil
+		})
+		errResp, ok := err.(*vcsclient.ErrorResponse)
+		if !ok {
+			t.Errorf("%s: got error %v, want *vcsclient.ErrorResponse", label, err)
+			continue
+		}
+		if errResp.Message != test.wantMsg {
+			t.Errorf("%s: got error message %q, want %q", label, errResp.Message, test.wantMsg)
+		}
+		if !reflect.DeepEqual(commits, rm.commits) {
+			t.Errorf("%s: got commits %+v before the error, want %+v", label, commits, rm.commits)
+		}
+	}
+}
+
+type mockServiceWithCommitCountCache struct {
+	mockServiceForExistingRepo
+	counts map[vcs.CommitID]uint
+}
+
+var _ vcsstore.CommitCountCache = (*mockServiceWithCommitCountCache)(nil)
+
+func (m *mockServiceWithCommitCountCache) CommitCount(repoPath string, opt vcs.CommitsOptions) (uint, bool) {
+	total, ok := m.counts[opt.Head]
+	return total, ok
+}
+
+func (m *mockServiceWithCommitCountCache) SetCommitCount(repoPath string, opt vcs.CommitsOptions, total uint) {
+	m.counts[opt.Head] = total
+}
+
 type mockCommits struct {
 	t *testing.T
 
@@ -78,3 +502,25 @@ func (m *mockCommits) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, erro
 	m.called = true
 	return m.commits, m.total, m.err
 }
+
+type mockStreamCommits struct {
+	mockCommits
+
+	streamErr error // returned after all commits are streamed
+}
+
+func (m *mockStreamCommits) StreamCommits(opt vcs.CommitsOptions, fn func(*vcs.Commit) error) (uint, error) {
+	commits, total, err := m.Commits(opt)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range commits {
+		if err := fn(c); err != nil {
+			return 0, err
+		}
+	}
+	if m.streamErr != nil {
+		return 0, m.streamErr
+	}
+	return total, nil
+}
@@ -28,9 +28,9 @@ func (h *Handler) serveRepoCommitters(w http.ResponseWriter, r *http.Request) er
 			return err
 		}
 
-		setShortCache(w)
+		h.setShortCache(w, r)
 
-		return writeJSON(w, committers)
+		return writeResponse(w, r, committers)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Committers not yet implemented for %T", repo)}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoConfig(w http.ResponseWriter, r *http.Request) error {
+	key := mux.Vars(r)["Key"]
+	if !vcs.ValidConfigKey(key) {
+		return vcs.ErrInvalidConfigKey
+	}
+
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.Configurer); ok {
+		value, err := repo.GetConfig(key)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("cache-control", "no-cache, max-age=0")
+		return writeResponse(w, r, value)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("GetConfig not yet implemented for %T", repo)}
+}
+
+func (h *Handler) serveRepoSetConfig(w http.ResponseWriter, r *http.Request) error {
+	key := mux.Vars(r)["Key"]
+	if !vcs.ValidConfigKey(key) {
+		return vcs.ErrInvalidConfigKey
+	}
+	if !vcs.SettableConfigKey(key) {
+		return vcs.ErrConfigKeyNotSettable
+	}
+
+	var value string
+	if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.Configurer); ok {
+		if err := repo.SetConfig(key, value); err != nil {
+			return err
+		}
+		h.Log.Printf("Config: set %s in repo %s.", key, repoPath)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("SetConfig not yet implemented for %T", repo)}
+}
+
+// secretConfigSections are the (lowercased) configuration sections
+// whose values may contain credentials, such as remote URLs
+// (remote.<name>.url), credential helpers' settings, HTTP headers
+// (http.extraHeader), and URL rewrites (url.<base>.insteadOf).
+var secretConfigSections = map[string]bool{
+	"credential": true,
+	"http":       true,
+	"remote":     true,
+	"url":        true,
+}
+
+// isSecretConfigKey returns whether the value of the configuration
+// key may contain credentials. Reading such keys requires OpConfig,
+// like listing remotes.
+func isSecretConfigKey(key string) bool {
+	section := key
+	if i := strings.Index(key, "."); i != -1 {
+		section = key[:i]
+	}
+	return secretConfigSections[strings.ToLower(section)]
+}
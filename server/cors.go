@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+// DefaultCORSAllowedMethods are the HTTP methods that cross-origin
+// requests may use if none are configured.
+var DefaultCORSAllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE"}
+
+// DefaultCORSAllowedHeaders are the request headers that cross-origin
+// requests may set if none are configured.
+var DefaultCORSAllowedHeaders = []string{"Accept", "Authorization", "Content-Type", "If-Modified-Since", RequestIDHeader}
+
+// corsExposedHeaders are the response headers (other than the
+// CORS-safelisted ones) that browser clients may read.
+var corsExposedHeaders = strings.Join([]string{
+	vcsclient.TotalCommitsHeader,
+	vcsclient.CommitCountHeader,
+	vcsclient.NextCommitsCursorHeader,
+	vcsclient.RefsGenerationHeader,
+	vcsclient.ObjectTypeHeader,
+	RequestIDHeader,
+	"Date",
+	"Location",
+	"Retry-After",
+	"WWW-Authenticate",
+}, ", ")
+
+// corsMaxAge is how long browsers may cache the result of a preflight
+// request.
+const corsMaxAge = 10 * time.Minute
+
+// CORS handles cross-origin requests from browsers (see
+// https://fetch.spec.whatwg.org/#http-cors-protocol).
+type CORS struct {
+	// AllowedOrigins are the origins (such as
+	// "https://example.com") that may make cross-origin requests. An
+	// origin of "*" allows all origins.
+	AllowedOrigins []string
+
+	// AllowedMethods are the HTTP methods that cross-origin requests
+	// may use.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers that cross-origin
+	// requests may set.
+	AllowedHeaders []string
+}
+
+// NewCORS creates a CORS configured by conf. If
+// conf.CORSAllowedOrigins is empty, it returns nil (i.e., no
+// cross-origin requests are allowed).
+func NewCORS(conf *vcsstore.Config) *CORS {
+	if len(conf.CORSAllowedOrigins) == 0 {
+		return nil
+	}
+	c := &CORS{
+		AllowedOrigins: conf.CORSAllowedOrigins,
+		AllowedMethods: conf.CORSAllowedMethods,
+		AllowedHeaders: conf.CORSAllowedHeaders,
+	}
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = DefaultCORSAllowedMethods
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = DefaultCORSAllowedHeaders
+	}
+	return c
+}
+
+// handle sets the CORS headers of the response to r. If r is a
+// preflight request, handle responds to it and returns true;
+// otherwise the caller should go on to handle r.
+func (c *CORS) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("origin")
+	if origin == "" {
+		return false
+	}
+	w.Header().Add("vary", "Origin")
+
+	if r.Method == "OPTIONS" && r.Header.Get("access-control-request-method") != "" {
+		w.Header().Add("vary", "Access-Control-Request-Method")
+		w.Header().Add("vary", "Access-Control-Request-Headers")
+		if c.allowOrigin(origin) && containsFold(c.AllowedMethods, r.Header.Get("access-control-request-method")) && c.allowHeaders(r.Header.Get("access-control-request-headers")) {
+			w.Header().Set("access-control-allow-origin", origin)
+			w.Header().Set("access-control-allow-methods", strings.Join(c.AllowedMethods, ", "))
+			w.Header().Set("access-control-allow-headers", strings.Join(c.AllowedHeaders, ", "))
+			w.Header().Set("access-control-max-age", strconv.Itoa(int(corsMaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+
+	if c.allowOrigin(origin) {
+		w.Header().Set("access-control-allow-origin", origin)
+		w.Header().Set("access-control-expose-headers", corsExposedHeaders)
+	}
+	return false
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders returns whether all of the headers in the
+// comma-separated list hdrs (from an Access-Control-Request-Headers
+// header) are allowed.
+func (c *CORS) allowHeaders(hdrs string) bool {
+	for _, hdr := range strings.Split(hdrs, ",") {
+		if hdr = strings.TrimSpace(hdr); hdr != "" && !containsFold(c.AllowedHeaders, hdr) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
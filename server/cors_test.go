@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestCORS_preflight(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	// The preflight request must not reach the service (which is nil).
+	testHandler.CORS = &CORS{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: DefaultCORSAllowedMethods,
+		AllowedHeaders: DefaultCORSAllowedHeaders,
+	}
+	url := server.URL + testHandler.router.URLToRepoCommits("a.b/c", vcs.CommitsOptions{Head: "abcd"}).String()
+
+	tests := map[string]struct {
+		origin, method, headers string
+		allowed                 bool
+	}{
+		"allowed":            {"https://example.com", "GET", "authorization, x-request-id", true},
+		"disallowed origin":  {"https://evil.example.com", "GET", "", false},
+		"disallowed method":  {"https://example.com", "PATCH", "", false},
+		"disallowed headers": {"https://example.com", "GET", "x-foo", false},
+	}
+	for label, test := range tests {
+		req, _ := http.NewRequest("OPTIONS", url, nil)
+		req.Header.Set("origin", test.origin)
+		req.Header.Set("access-control-request-method", test.method)
+		if test.headers != "" {
+			req.Header.Set("access-control-request-headers", test.headers)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusNoContent; got != want {
+			t.Errorf("%s: got code %d, want %d", label, got, want)
+		}
+		if !test.allowed {
+			if got := resp.Header.Get("access-control-allow-origin"); got != "" {
+				t.Errorf("%s: got Access-Control-Allow-Origin %q, want none", label, got)
+			}
+			continue
+		}
+		if got := resp.Header.Get("access-control-allow-origin"); got != test.origin {
+			t.Errorf("%s: got Access-Control-Allow-Origin %q, want %q", label, got, test.origin)
+		}
+		if got := resp.Header.Get("access-control-allow-methods"); !strings.Contains(got, "GET") {
+			t.Errorf("%s: got Access-Control-Allow-Methods %q, want it to include GET", label, got)
+		}
+		if got := resp.Header.Get("access-control-allow-headers"); !strings.Contains(got, "Authorization") {
+			t.Errorf("%s: got Access-Control-Allow-Headers %q, want it to include Authorization", label, got)
+		}
+	}
+}
+
+func TestCORS_get(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	testHandler.CORS = &CORS{AllowedOrigins: []string{"*"}}
+
+	repoPath := "a.b/c"
+	opt := vcs.CommitsOptions{Head: "abcd"}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo: &mockCommits{
+			t:       t,
+			opt:     opt,
+			commits: []*vcs.Commit{{ID: "abcd"}},
+			total:   1,
+		},
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+testHandler.router.URLToRepoCommits(repoPath, opt).String(), nil)
+	req.Header.Set("origin", "https://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got code %d, want %d", got, want)
+	}
+	if got, want := resp.Header.Get("access-control-allow-origin"), "https://example.com"; got != want {
+		t.Errorf("got Access-Control-Allow-Origin %q, want %q", got, want)
+	}
+	if got := resp.Header.Get("access-control-expose-headers"); !strings.Contains(got, vcsclient.TotalCommitsHeader) {
+		t.Errorf("got Access-Control-Expose-Headers %q, want it to include %q", got, vcsclient.TotalCommitsHeader)
+	}
+	if got := resp.Header.Values("vary"); !containsFold(got, "Origin") {
+		t.Errorf("got Vary %q, want it to include Origin", got)
+	}
+}
+
+func TestCORS_disabled(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	req, _ := http.NewRequest("GET", server.URL+testHandler.router.URLTo(vcsclient.RouteRoot).String(), nil)
+	req.Header.Set("origin", "https://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get("access-control-allow-origin"); got != "" {
+		t.Errorf("got Access-Control-Allow-Origin %q, want none", got)
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoDefaultBranch(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.DefaultBrancher); ok {
+		branch, err := repo.DefaultBranch()
+		if err != nil {
+			return err
+		}
+
+		// The default branch can change whenever HEAD or the branches
+		// are updated.
+		h.setShortCache(w, r)
+		return writeResponse(w, r, branch)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("DefaultBranch not yet implemented for %T", repo)}
+}
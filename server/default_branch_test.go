@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoDefaultBranch(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockDefaultBranch{t: t, branch: "main"}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDefaultBranch(repoPath).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var branch string
+	if err := json.NewDecoder(resp.Body).Decode(&branch); err != nil {
+		t.Fatal(err)
+	}
+	if branch != rm.branch {
+		t.Errorf("got default branch %q, want %q", branch, rm.branch)
+	}
+}
+
+func TestServeRepoDefaultBranch_emptyRepo(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := exec.Command("git", "init", "--bare")
+	c.Dir = dir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("Command %v failed: %s. Output was:\n\n%s", c.Args, err, out)
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDefaultBranch(repoPath).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+	if err := vcsclient.CheckResponse(resp, false); vcsclient.KnownError(err) != vcs.ErrRepoEmpty {
+		t.Errorf("got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+}
+
+type mockDefaultBranch struct {
+	t *testing.T
+
+	// return values
+	branch string
+	err    error
+
+	called bool
+}
+
+func (m *mockDefaultBranch) DefaultBranch() (string, error) {
+	m.called = true
+	return m.branch, m.err
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoDescribe(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var opt vcs.DescribeOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+
+	commitID, _, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.Describer); ok {
+		desc, err := repo.Describe(commitID, opt)
+		if err != nil {
+			return err
+		}
+
+		// Don't cache for long even if the commit ID is canonical,
+		// because a newly created tag can change the description.
+		h.setShortCache(w, r)
+		return writeResponse(w, r, desc)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("Describe not yet implemented for %T", repo)}
+}
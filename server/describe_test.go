@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoDescribe(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID("abcd")
+	opt := vcs.DescribeOptions{Abbrev: 10, Always: true}
+
+	rm := &mockDescribe{
+		t:        t,
+		commitID: commitID,
+		opt:      opt,
+		desc:     "v1.2.3-4-gabcd",
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDescribe(repoPath, commitID, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var desc string
+	if err := json.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc != rm.desc {
+		t.Errorf("got description %q, want %q", desc, rm.desc)
+	}
+}
+
+func TestServeRepoDescribe_noDescription(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID("abcd")
+
+	rm := &mockDescribe{
+		t:        t,
+		commitID: commitID,
+		err:      vcs.ErrNoDescription,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDescribe(repoPath, commitID, vcs.DescribeOptions{}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+type mockDescribe struct {
+	t *testing.T
+
+	// expected args
+	commitID vcs.CommitID
+	opt      vcs.DescribeOptions
+
+	// return values
+	desc string
+	err  error
+
+	called bool
+}
+
+func (m *mockDescribe) Describe(commitID vcs.CommitID, opt vcs.DescribeOptions) (string, error) {
+	if commitID != m.commitID {
+		m.t.Errorf("mock: got commitID %q, want %q", commitID, m.commitID)
+	}
+	if opt != m.opt {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
+	m.called = true
+	return m.desc, m.err
+}
@@ -3,15 +3,18 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func (h *Handler) serveRepoDiff(w http.ResponseWriter, r *http.Request) error {
 	v := mux.Vars(r)
 
-	repo, _, done, err := h.getRepo(r)
+	repo, repoPath, done, err := h.getRepo(r)
 	if err != nil {
 		return err
 	}
@@ -23,26 +26,42 @@ func (h *Handler) serveRepoDiff(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if repo, ok := repo.(vcs.Differ); ok {
-		diff, err := repo.Diff(vcs.CommitID(v["Base"]), vcs.CommitID(v["Head"]), &opt)
-		if err != nil {
-			return err
+		base, head := vcs.CommitID(v["Base"]), vcs.CommitID(v["Head"])
+
+		// Diffs between full commit IDs never change, so the service
+		// may have cached them.
+		cache, _ := h.Service.(vcsstore.DiffCache)
+		var diff *vcs.Diff
+		var cached bool
+		if cache != nil {
+			diff, cached = cache.Diff(repoPath, base, head, &opt)
+			observeDiffCacheLookup(cached)
+		}
+		if !cached {
+			diff, err = repo.Diff(base, head, &opt)
+			if err != nil {
+				return err
+			}
+			if cache != nil {
+				cache.SetDiff(repoPath, base, head, &opt, diff)
+			}
 		}
 
-		_, baseCanon, err := checkCommitID(v["Base"])
+		_, baseCanon, err := checkCommitID(repo, v["Base"])
 		if err != nil {
 			return err
 		}
-		_, headCanon, err := checkCommitID(v["Head"])
+		_, headCanon, err := checkCommitID(repo, v["Head"])
 		if err != nil {
 			return err
 		}
 		if baseCanon && headCanon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 		}
 
-		return writeJSON(w, diff)
+		return writeResponse(w, r, diff)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Diff not yet implemented for %T", repo)}
@@ -74,22 +93,60 @@ func (h *Handler) serveRepoCrossRepoDiff(w http.ResponseWriter, r *http.Request)
 			return err
 		}
 
-		_, baseCanon, err := checkCommitID(v["Base"])
+		_, baseCanon, err := checkCommitID(baseRepo, v["Base"])
 		if err != nil {
 			return err
 		}
-		_, headCanon, err := checkCommitID(v["Head"])
+		_, headCanon, err := checkCommitID(headRepo, v["Head"])
 		if err != nil {
 			return err
 		}
 		if baseCanon && headCanon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 		}
 
-		return writeJSON(w, diff)
+		return writeResponse(w, r, diff)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("CrossRepoDiff not yet implemented for %T", baseRepo)}
 }
+
+func (h *Handler) serveRepoTreeDiff(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var opt vcsclient.TreeDiffOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.TreeDiffer); ok {
+		diff, err := repo.DiffTrees(opt.Base, opt.Head, &opt.DiffOptions)
+		if err != nil {
+			return err
+		}
+
+		if treeSpecIsCanon(opt.Base) && treeSpecIsCanon(opt.Head) {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+
+		return writeResponse(w, r, diff)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("DiffTrees not yet implemented for %T", repo)}
+}
+
+// treeSpecIsCanon returns whether the tree spec ("<rev>:<path>" or
+// "<rev>") refers to a tree by full commit ID, so that the tree never
+// changes.
+func treeSpecIsCanon(spec string) bool {
+	rev := strings.SplitN(spec, ":", 2)[0]
+	return isLowercaseHex(rev) && commitIDIsCanon(rev)
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"reflect"
 	"testing"
 
@@ -10,6 +12,8 @@ import (
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
 	vcs_testing "sourcegraph.com/sourcegraph/go-vcs/vcs/testing"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func TestServeRepoDiff(t *testing.T) {
@@ -56,6 +60,115 @@ func TestServeRepoDiff(t *testing.T) {
 	}
 }
 
+func TestServeRepoDiff_renameOptions(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.DiffOptions{DetectRenames: true, RenameThreshold: 70, DetectCopies: true}
+
+	rm := &mockDiff{
+		t:    t,
+		base: vcs.CommitID(strings.Repeat("a", 40)),
+		head: vcs.CommitID(strings.Repeat("b", 40)),
+		opt:  opt,
+		diff: &vcs.Diff{
+			Raw:     "diff --git f g\nsimilarity index 73%\nrename from f\nrename to g\n",
+			Renames: []*vcs.DiffRename{{OrigPath: "f", Path: "g", Similarity: 73}},
+		},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDiff(repoPath, rm.base, rm.head, &opt).String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var diff *vcs.Diff
+	if err := json.NewDecoder(resp.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(diff, rm.diff) {
+		t.Errorf("got diff %+v, want %+v", diff, rm.diff)
+	}
+}
+
+func TestServeRepoDiff_cached(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-diff-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	repoPath := "a.b/c"
+	canonBase, canonHead := vcs.CommitID(strings.Repeat("a", 40)), vcs.CommitID(strings.Repeat("b", 40))
+
+	tests := map[string]struct {
+		base, head vcs.CommitID
+		opt        vcs.DiffOptions
+		wantCalls  int
+	}{
+		// The second request is served from the cache, without
+		// computing the diff again.
+		"canonical": {base: canonBase, head: canonHead, wantCalls: 1},
+		"canonical with options": {
+			base: canonBase, head: canonHead,
+			opt:       vcs.DiffOptions{Paths: []string{"a", "b"}, DetectRenames: true},
+			wantCalls: 1,
+		},
+
+		// The commit that an abbreviated commit ID refers to may
+		// change (if another commit with the same prefix is added),
+		// so its diffs aren't cached.
+		"abbreviated": {base: "abcd", head: canonHead, wantCalls: 2},
+	}
+	for label, test := range tests {
+		rm := &mockDiff{t: t, base: test.base, head: test.head, opt: test.opt, diff: &vcs.Diff{Raw: "diff " + label}}
+		testHandler.Service = &mockServiceWithDiffCache{
+			mockServiceForExistingRepo: mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm},
+			DiffCache:                  vcsstore.NewService(&vcsstore.Config{StorageDir: storageDir}).(vcsstore.DiffCache),
+		}
+
+		for i := 0; i < 2; i++ {
+			resp, err := http.Get(server.URL + testHandler.router.URLToRepoDiff(repoPath, test.base, test.head, &test.opt).String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var diff *vcs.Diff
+			err = json.NewDecoder(resp.Body).Decode(&diff)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(diff, rm.diff) {
+				t.Errorf("%s: request %d: got diff %+v, want %+v", label, i, diff, rm.diff)
+			}
+		}
+		if rm.calls != test.wantCalls {
+			t.Errorf("%s: got %d Diff calls, want %d", label, rm.calls, test.wantCalls)
+		}
+	}
+}
+
+type mockServiceWithDiffCache struct {
+	mockServiceForExistingRepo
+	vcsstore.DiffCache
+}
+
 type mockDiff struct {
 	t *testing.T
 
@@ -68,9 +181,91 @@ type mockDiff struct {
 	err  error
 
 	called bool
+	calls  int
 }
 
 func (m *mockDiff) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	if base != m.base {
+		m.t.Errorf("mock: got base %q, want %q", base, m.base)
+	}
+	if head != m.head {
+		m.t.Errorf("mock: got head %q, want %q", head, m.head)
+	}
+	if !reflect.DeepEqual(opt, &m.opt) {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, &m.opt)
+	}
+	m.called = true
+	m.calls++
+	return m.diff, m.err
+}
+
+func TestServeRepoTreeDiff(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcsclient.TreeDiffOptions{
+		Base:        strings.Repeat("a", 40) + ":upstream",
+		Head:        "master:vendor/upstream",
+		DiffOptions: vcs.DiffOptions{DetectRenames: true},
+	}
+
+	rm := &mockDiffTrees{
+		t:    t,
+		base: opt.Base,
+		head: opt.Head,
+		opt:  opt.DiffOptions,
+		diff: &vcs.Diff{Raw: "diff"},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeDiff(repoPath, &opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	// The head is a branch, which can change.
+	if cc := resp.Header.Get("cache-control"); cc != shortCacheControl {
+		t.Errorf("got cache-control %q, want %q", cc, shortCacheControl)
+	}
+
+	var diff *vcs.Diff
+	if err := json.NewDecoder(resp.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(diff, rm.diff) {
+		t.Errorf("got diff %+v, want %+v", diff, rm.diff)
+	}
+}
+
+type mockDiffTrees struct {
+	t *testing.T
+
+	// expected args
+	base, head string
+	opt        vcs.DiffOptions
+
+	// return values
+	diff *vcs.Diff
+	err  error
+
+	called bool
+}
+
+func (m *mockDiffTrees) DiffTrees(base, head string, opt *vcs.DiffOptions) (*vcs.Diff, error) {
 	if base != m.base {
 		m.t.Errorf("mock: got base %q, want %q", base, m.base)
 	}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoDiffStat(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	commitID, canon, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.DiffStater); ok {
+		stats, err := repo.DiffStat(commitID)
+		if err != nil {
+			return err
+		}
+
+		if canon {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		return writeResponse(w, r, stats)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("DiffStat not yet implemented for %T", repo)}
+}
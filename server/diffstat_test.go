@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoDiffStat(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+
+	rm := &mockDiffStat{
+		t:        t,
+		commitID: commitID,
+		stats:    []*vcs.FileStat{{Path: "g", OrigPath: "f", Added: 1, Deleted: 2}, {Path: "b", Binary: true}},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoDiffStat(repoPath, commitID).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	if cc := resp.Header.Get("cache-control"); cc != longCacheControl {
+		t.Errorf("got cache-control %q, want %q", cc, longCacheControl)
+	}
+
+	var stats []*vcs.FileStat
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stats, rm.stats) {
+		t.Errorf("got stats %+v, want %+v", stats, rm.stats)
+	}
+}
+
+type mockDiffStat struct {
+	t *testing.T
+
+	// expected args
+	commitID vcs.CommitID
+
+	// return values
+	stats []*vcs.FileStat
+	err   error
+
+	called bool
+}
+
+func (m *mockDiffStat) DiffStat(commitID vcs.CommitID) ([]*vcs.FileStat, error) {
+	if commitID != m.commitID {
+		m.t.Errorf("mock: got commitID %q, want %q", commitID, m.commitID)
+	}
+	m.called = true
+	return m.stats, m.err
+}
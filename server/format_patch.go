@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoFormatPatch(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	head, headCanon, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	var opt vcs.FormatPatchOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+	baseCanon := true
+	if opt.Base != "" {
+		if opt.Base, baseCanon, err = checkCommitID(repo, string(opt.Base)); err != nil {
+			return err
+		}
+	}
+
+	if repo, ok := repo.(vcs.PatchFormatter); ok {
+		patches, err := repo.FormatPatch(head, opt)
+		if err != nil {
+			return err
+		}
+		defer patches.Close()
+
+		w.Header().Set("content-type", "text/plain; charset=utf-8")
+		if headCanon && baseCanon {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		_, err = io.Copy(w, patches)
+		return err
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("FormatPatch not yet implemented for %T", repo)}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoFormatPatch(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	head := vcs.CommitID(strings.Repeat("a", 40))
+	opt := vcs.FormatPatchOptions{Base: vcs.CommitID(strings.Repeat("b", 40)), IncludeMerges: true}
+
+	rm := &mockFormatPatch{
+		t:       t,
+		head:    head,
+		opt:     opt,
+		patches: "From aaaa Mon Sep 17 00:00:00 2001\nSubject: [PATCH] foo\n",
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoFormatPatch(repoPath, head, opt).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	if got, want := resp.Header.Get("content-type"), "text/plain; charset=utf-8"; got != want {
+		t.Errorf("got content-type %q, want %q", got, want)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != rm.patches {
+		t.Errorf("got patches %q, want %q", data, rm.patches)
+	}
+	if cc := resp.Header.Get("cache-control"); cc != longCacheControl {
+		t.Errorf("got cache-control %q, want %q", cc, longCacheControl)
+	}
+}
+
+func TestServeRepoFormatPatch_invalidCommitID(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockFormatPatch{t: t}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	for _, base := range []vcs.CommitID{"HEAD", "--output=x", "a..b"} {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoFormatPatch(repoPath, "abcd", vcs.FormatPatchOptions{Base: base}).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+			t.Errorf("%s: got status %d, want %d", base, got, want)
+		}
+	}
+	if rm.called {
+		t.Error("got FormatPatch called, want invalid commit IDs rejected first")
+	}
+}
+
+type mockFormatPatch struct {
+	t *testing.T
+
+	// expected args
+	head vcs.CommitID
+	opt  vcs.FormatPatchOptions
+
+	// return values
+	patches string
+	err     error
+
+	called bool
+}
+
+func (m *mockFormatPatch) FormatPatch(head vcs.CommitID, opt vcs.FormatPatchOptions) (io.ReadCloser, error) {
+	if head != m.head {
+		m.t.Errorf("mock: got head %q, want %q", head, m.head)
+	}
+	if !reflect.DeepEqual(opt, m.opt) {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
+	m.called = true
+	if m.err != nil {
+		return nil, m.err
+	}
+	return ioutil.NopCloser(strings.NewReader(m.patches)), nil
+}
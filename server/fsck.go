@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoFsck(w http.ResponseWriter, r *http.Request) error {
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.Fscker); ok {
+		report, err := repo.Fsck()
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			h.Log.Printf("Fsck: repo %s has %d problems.", repoPath, len(report.Problems))
+		}
+
+		w.Header().Set("cache-control", "no-cache, max-age=0")
+		return writeResponse(w, r, report)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("Fsck not yet implemented for %T", repo)}
+}
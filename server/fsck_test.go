@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoFsck(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockFsck{
+		t: t,
+		report: vcs.FsckReport{Problems: []*vcs.FsckProblem{
+			{Kind: vcs.FsckMissing, ObjectType: "blob", ObjectID: "abcd", Message: "missing blob abcd"},
+		}},
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	fsck := func() *http.Response {
+		resp, err := http.Post(server.URL+testHandler.router.URLToRepoFsck(repoPath).String(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Without authentication configured, fsck is denied.
+	resp := fsck()
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusForbidden; got != want {
+		t.Errorf("without auth: got status %d, want %d", got, want)
+	}
+	if rm.called {
+		t.Errorf("without auth: called")
+	}
+
+	var gotOp string
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		gotOp = op
+		return nil
+	}
+	resp = fsck()
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("with auth: got status %d, want %d", got, want)
+	}
+	if !rm.called {
+		t.Errorf("with auth: !called")
+	}
+	if gotOp != OpConfig {
+		t.Errorf("got operation %q, want %q", gotOp, OpConfig)
+	}
+
+	var report vcs.FsckReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report, rm.report) {
+		t.Errorf("got report %+v, want %+v", report, rm.report)
+	}
+}
+
+type mockFsck struct {
+	t *testing.T
+
+	// return values
+	report vcs.FsckReport
+
+	called bool
+}
+
+func (m *mockFsck) Fsck() (vcs.FsckReport, error) {
+	m.called = true
+	return m.report, nil
+}
+
+var _ vcs.Fscker = (*mockFsck)(nil)
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoGC(w http.ResponseWriter, r *http.Request) error {
+	var opt vcs.GCOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.GarbageCollector); ok {
+		start := time.Now()
+		if err := repo.GC(opt); err != nil {
+			return err
+		}
+		h.Log.Printf("GC: repo %s (aggressive: %v) took %s.", repoPath, opt.Aggressive, time.Since(start))
+		h.storageChanged(repoPath)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("GC not yet implemented for %T", repo)}
+}
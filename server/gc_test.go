@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoGC(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	opt := vcs.GCOptions{Aggressive: true}
+	gc := func() *http.Response {
+		resp, err := http.Post(server.URL+testHandler.router.URLToRepoGC(repoPath, opt).String(), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// Without authentication configured, gc is denied.
+	rm := &mockGC{t: t, opt: opt}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	if got, want := gc().StatusCode, http.StatusForbidden; got != want {
+		t.Errorf("without auth: got status %d, want %d", got, want)
+	}
+	if rm.called {
+		t.Errorf("without auth: called")
+	}
+
+	var gotOp string
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		gotOp = op
+		return nil
+	}
+	if got, want := gc().StatusCode, http.StatusNoContent; got != want {
+		t.Errorf("with auth: got status %d, want %d", got, want)
+	}
+	if !rm.called {
+		t.Errorf("with auth: !called")
+	}
+	if gotOp != OpConfig {
+		t.Errorf("got operation %q, want %q", gotOp, OpConfig)
+	}
+}
+
+type mockGC struct {
+	t *testing.T
+
+	// expected args
+	opt vcs.GCOptions
+
+	called bool
+}
+
+func (m *mockGC) GC(opt vcs.GCOptions) error {
+	if opt != m.opt {
+		m.t.Errorf("mock: got opt %+v, want %+v", opt, m.opt)
+	}
+	m.called = true
+	return nil
+}
+
+var _ vcs.GarbageCollector = (*mockGC)(nil)
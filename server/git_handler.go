@@ -2,12 +2,16 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/vcsstore/git"
 )
 
@@ -16,12 +20,27 @@ func (h *Handler) serveInfoRefs(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	setVCSType(r, "git")
 	rawService := r.URL.Query().Get("service")
 
+	if rawService == "" {
+		// Dumb HTTP protocol clients don't specify a service.
+		t, err := h.GitTransporter.GitTransport(repoPath)
+		if err != nil {
+			return err
+		}
+		if t, ok := t.(git.DumbTransport); ok {
+			return serveDumbFile(w, r, t, "info/refs")
+		}
+	}
+
 	var service string
 	if strings.HasPrefix(rawService, "git-") {
 		service = rawService[len("git-"):]
 	}
+	if err := git.CheckService(service); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
 
 	t, err := h.GitTransporter.GitTransport(repoPath)
 	if err != nil {
@@ -29,7 +48,7 @@ func (h *Handler) serveInfoRefs(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	var refsBuf bytes.Buffer
-	err = t.InfoRefs(&refsBuf, service)
+	err = t.InfoRefs(r.Context(), &refsBuf, service)
 	if err != nil {
 		return err
 	}
@@ -45,16 +64,30 @@ func (h *Handler) serveReceivePack(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return err
 	}
+	setVCSType(r, "git")
 
 	var opt git.GitTransportOpt
 	opt.ContentEncoding = r.Header.Get("content-encoding")
+	if h.OnPush != nil {
+		opt.OnPush = func(updates []git.RefUpdate) {
+			h.OnPush(repoPath, updates)
+		}
+	}
 
 	t, err := h.GitTransporter.GitTransport(repoPath)
 	if err != nil {
 		return err
 	}
 	w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
-	return t.ReceivePack(w, r.Body, opt)
+	defer unblockBodyOnCancel(w, r)()
+	err = t.ReceivePack(r.Context(), w, r.Body, opt)
+	// Refs may have been updated even if receive-pack failed.
+	h.refsChanged(repoPath)
+	h.storageChanged(repoPath)
+	if err == nil {
+		h.writeCommitGraph(repoPath)
+	}
+	return err
 }
 
 func (h *Handler) serveUploadPack(w http.ResponseWriter, r *http.Request) error {
@@ -62,6 +95,7 @@ func (h *Handler) serveUploadPack(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return err
 	}
+	setVCSType(r, "git")
 
 	t, err := h.GitTransporter.GitTransport(repoPath)
 	if err != nil {
@@ -71,12 +105,83 @@ func (h *Handler) serveUploadPack(w http.ResponseWriter, r *http.Request) error
 	var opt git.GitTransportOpt
 	opt.ContentEncoding = r.Header.Get("content-encoding")
 	w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
-	if err := t.UploadPack(w, r.Body, opt); err != nil {
+	defer unblockBodyOnCancel(w, r)()
+	if err := t.UploadPack(r.Context(), w, r.Body, opt); err != nil {
 		return err
 	}
 	return nil
 }
 
+// unblockBodyOnCancel makes a blocked read of r.Body return (with a
+// timeout error) when r's context is canceled, by setting a read
+// deadline on the connection. The git transport waits for its reads
+// of the body to return before returning, and a client that stops
+// sending the body without closing the connection would otherwise
+// block it. The returned func stops it; it must be called before the
+// handler returns.
+func unblockBodyOnCancel(w http.ResponseWriter, r *http.Request) (stop func()) {
+	rc := http.NewResponseController(w)
+	done := make(chan struct{})
+	stopFunc := context.AfterFunc(r.Context(), func() {
+		defer close(done)
+		rc.SetReadDeadline(time.Now())
+	})
+	return func() {
+		if !stopFunc() {
+			<-done // don't return while SetReadDeadline is running
+		}
+	}
+}
+
+func (h *Handler) serveDumbFile(w http.ResponseWriter, r *http.Request) error {
+	repoPath, err := h.getRepoPath(r, "")
+	if err != nil {
+		return err
+	}
+	setVCSType(r, "git")
+
+	t, err := h.GitTransporter.GitTransport(repoPath)
+	if err != nil {
+		return err
+	}
+	dt, ok := t.(git.DumbTransport)
+	if !ok {
+		return &httpError{http.StatusNotFound, fmt.Errorf("dumb HTTP protocol not supported for %T", t)}
+	}
+	return serveDumbFile(w, r, dt, mux.Vars(r)["File"])
+}
+
+// serveDumbFile writes the file at name (relative to the git dir)
+// for a dumb HTTP protocol client.
+func serveDumbFile(w http.ResponseWriter, r *http.Request, t git.DumbTransport, name string) error {
+	f, err := t.OpenFile(r.Context(), name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &httpError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	defer f.Close()
+
+	// Objects and packs never change, but the other files do.
+	switch {
+	case strings.HasPrefix(name, "objects/pack/") && strings.HasSuffix(name, ".pack"):
+		w.Header().Set("Content-Type", "application/x-git-packed-objects")
+		hdrCacheForever(w)
+	case strings.HasPrefix(name, "objects/pack/") && strings.HasSuffix(name, ".idx"):
+		w.Header().Set("Content-Type", "application/x-git-packed-objects-toc")
+		hdrCacheForever(w)
+	case strings.HasPrefix(name, "objects/") && name != "objects/info/packs":
+		w.Header().Set("Content-Type", "application/x-git-loose-object")
+		hdrCacheForever(w)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		hdrNocache(w)
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
 // Helpers copied from githttp
 func hdrNocache(w http.ResponseWriter) {
 	w.Header().Set("Expires", "Fri, 01 Jan 1980 00:00:00 GMT")
@@ -3,11 +3,17 @@ package server
 import (
 	"compress/flate"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/git"
@@ -30,54 +36,109 @@ func (t *localGitTransporter) GitTransport(repoPath string) (git.GitTransport, e
 	if err != nil {
 		return nil, err
 	}
-	return &localGitTransport{dir: cloneDir}, nil
+	return &localGitTransport{dir: cloneDir, allowFilter: t.Config.AllowPartialClone, allowDumb: t.Config.AllowDumbHTTP, log: t.Config.RequestLog}, nil
 }
 
 // localGitTransport is a git repository hosted on local disk
 type localGitTransport struct {
 	dir string
+
+	// allowFilter is whether upload-pack lets clients request partial
+	// clones (with a filter such as "blob:none").
+	allowFilter bool
+
+	// allowDumb is whether to serve the dumb HTTP protocol.
+	allowDumb bool
+
+	// log, if set, receives the stderr of git subprocesses (with the
+	// ID of the request they were run for).
+	log *slog.Logger
 }
 
-func (r *localGitTransport) InfoRefs(w io.Writer, service string) error {
-	if service != "upload-pack" && service != "receive-pack" {
-		return fmt.Errorf("unrecognized git service \"%s\"", service)
+var _ git.DumbTransport = (*localGitTransport)(nil)
+
+// dumbFilePattern matches the names of the files that dumb HTTP
+// protocol clients fetch (SHA-1 or SHA-256 object IDs).
+var dumbFilePattern = regexp.MustCompile(`^(?:HEAD|info/refs|objects/info/packs|objects/[0-9a-f]{2}/(?:[0-9a-f]{38}|[0-9a-f]{62})|objects/pack/pack-(?:[0-9a-f]{40}|[0-9a-f]{64})\.(?:pack|idx))$`)
+
+func (r *localGitTransport) OpenFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !r.allowDumb || !dumbFilePattern.MatchString(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
 	}
-	w.Write(packetWrite("# service=git-" + service + "\n"))
-	w.Write(packetFlush())
+	if _, err := os.Stat(r.dir); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(r.dir, name))
+}
 
-	cmd := exec.Command("git", service, "--stateless-rpc", "--advertise-refs", ".")
+// command returns the command that runs the git service with args.
+func (r *localGitTransport) command(ctx context.Context, service string, args ...string) *exec.Cmd {
+	var config []string
+	if service == git.ServiceUploadPack && r.allowFilter {
+		// Clients of partial clones later fetch the missing objects
+		// by ID, so they must be allowed to want any reachable
+		// object (not just ref tips).
+		config = []string{"-c", "uploadpack.allowFilter=true", "-c", "uploadpack.allowReachableSHA1InWant=true"}
+	}
+	cmd := exec.CommandContext(ctx, "git", append(append(config, service), args...)...)
 	cmd.Dir = r.dir
-	cmd.Stdout, cmd.Stderr = w, os.Stderr
-	return cmd.Run()
+	return cmd
 }
 
-func (r *localGitTransport) ReceivePack(w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
-	return r.servicePack("receive-pack", w, rdr, opt)
+// stderr returns the writer for the stderr of a git subprocess for
+// service run for the request with context ctx (or fallback, if no
+// log is configured). The caller must close it after the subprocess
+// exits.
+func (r *localGitTransport) stderr(ctx context.Context, service string, fallback io.Writer) io.WriteCloser {
+	if r.log == nil {
+		return nopWriteCloser{fallback}
+	}
+	return &stderrLogger{log: requestLog(r.log, ctx), msg: "git subprocess", args: []any{"service", service, "dir", r.dir}}
 }
 
-func (r *localGitTransport) UploadPack(w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
-	return r.servicePack("upload-pack", w, rdr, opt)
-}
+type nopWriteCloser struct{ io.Writer }
 
-func (r *localGitTransport) servicePack(service string, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
-	var err error
-	switch opt.ContentEncoding {
-	case "gzip":
-		rdr, err = gzip.NewReader(rdr)
-	case "deflate":
-		rdr = flate.NewReader(rdr)
-	}
-	if err != nil {
+func (nopWriteCloser) Close() error { return nil }
+
+func (r *localGitTransport) InfoRefs(ctx context.Context, w io.Writer, service string) error {
+	if err := git.CheckService(service); err != nil {
 		return err
 	}
+	w.Write(packetWrite("# service=git-" + service + "\n"))
+	w.Write(packetFlush())
 
-	rpcReader := &githttp.RpcReader{
-		Reader: rdr,
-		Rpc:    service,
+	cmd := r.command(ctx, service, "--stateless-rpc", "--advertise-refs", ".")
+	stderr := r.stderr(ctx, service, os.Stderr)
+	defer stderr.Close()
+	cmd.Stdout, cmd.Stderr = w, stderr
+	start := time.Now()
+	err := cmd.Run()
+	observeGitCommand(service, err, time.Since(start))
+	return err
+}
+
+func (r *localGitTransport) ReceivePack(ctx context.Context, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
+	return r.servicePack(ctx, git.ServiceReceivePack, w, rdr, opt)
+}
+
+func (r *localGitTransport) UploadPack(ctx context.Context, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
+	return r.servicePack(ctx, git.ServiceUploadPack, w, rdr, opt)
+}
+
+// servicePack runs the git service, copying rdr to its stdin and its
+// stdout to w. If ctx is canceled, the git process is killed, but
+// servicePack still waits for any read from rdr to return (so that it
+// never reads from rdr after returning). The caller must ensure that
+// a blocked read from rdr returns when ctx is canceled.
+func (r *localGitTransport) servicePack(ctx context.Context, service string, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
+	if err := git.CheckService(service); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("git", service, "--stateless-rpc", ".")
-	cmd.Dir = r.dir
+	cmd := r.command(ctx, service, "--stateless-rpc", ".")
+	stderr := r.stderr(ctx, service, ioutil.Discard)
+	defer stderr.Close()
+	cmd.Stderr = stderr
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return err
@@ -90,8 +151,10 @@ func (r *localGitTransport) servicePack(service string, w io.Writer, rdr io.Read
 	}
 	defer stdout.Close()
 
+	start := time.Now()
 	err = cmd.Start()
 	if err != nil {
+		observeGitCommand(service, err, time.Since(start))
 		return err
 	}
 
@@ -100,19 +163,121 @@ func (r *localGitTransport) servicePack(service string, w io.Writer, rdr io.Read
 		Reader: stdout,
 	}
 
-	// Copy input to git binary
-	io.Copy(stdin, rpcReader)
+	// Copy input to git binary. Do this (and the decoding, which
+	// also reads from rdr) in a separate goroutine so that git's
+	// output is written to w while the input is still being read.
+	var (
+		rpcReader *githttp.RpcReader
+		copyErr   error
+	)
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		defer stdin.Close()
+
+		rdr := rdr
+		switch opt.ContentEncoding {
+		case "gzip":
+			zr, err := gzip.NewReader(rdr)
+			if err != nil {
+				copyErr = err
+				return
+			}
+			defer zr.Close()
+			rdr = zr
+		case "deflate":
+			zr := flate.NewReader(rdr)
+			defer zr.Close()
+			rdr = zr
+		}
+
+		rpcReader = &githttp.RpcReader{
+			Reader: rdr,
+			Rpc:    service,
+		}
+		io.Copy(stdin, rpcReader)
+	}()
 
 	// Write git binary's output to http response
 	io.Copy(w, gitReader)
 
-	// Wait till command has completed
+	// Wait till command has completed (or was killed because ctx
+	// was canceled).
 	mainError := cmd.Wait()
-	if mainError == nil {
+	<-copied
+	if ctx.Err() != nil {
+		observeGitCommand(service, ctx.Err(), time.Since(start))
+		return ctx.Err()
+	}
+	if copyErr != nil {
+		mainError = copyErr
+	} else if mainError == nil {
 		mainError = gitReader.GitError
 	}
-	for _, e := range rpcReader.Events {
-		log.Printf("EVENT: %q\n", e)
+	observeGitCommand(service, mainError, time.Since(start))
+	if mainError == nil && service == git.ServiceReceivePack && opt.OnPush != nil && rpcReader != nil {
+		if pushed := refUpdatesFromEvents(rpcReader.Events); len(pushed) > 0 {
+			updates, err := r.appliedRefUpdates(pushed)
+			if err != nil {
+				return err
+			}
+			if len(updates) > 0 {
+				opt.OnPush(updates)
+			}
+		}
 	}
 	return mainError
 }
+
+// appliedRefUpdates returns the updates whose refs now have their new
+// values. Others were rejected (receive-pack succeeds even if a hook
+// rejects some or all of the updates, because it reports the status
+// of each to the client).
+func (r *localGitTransport) appliedRefUpdates(updates []git.RefUpdate) ([]git.RefUpdate, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)", "--")
+	for _, u := range updates {
+		cmd.Args = append(cmd.Args, u.Ref)
+	}
+	cmd.Dir = r.dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec %v failed: %s", cmd.Args, err)
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	var applied []git.RefUpdate
+	for _, u := range updates {
+		id, exists := refs[u.Ref]
+		deleted := strings.Trim(u.New, "0") == ""
+		if (exists && id == u.New) || (!exists && deleted) {
+			applied = append(applied, u)
+		}
+	}
+	return applied, nil
+}
+
+// refUpdatesFromEvents returns the ref updates described by the push
+// and tag events that an RpcReader scanned from a receive-pack
+// request.
+func refUpdatesFromEvents(events []githttp.Event) []git.RefUpdate {
+	var updates []git.RefUpdate
+	for _, e := range events {
+		var ref string
+		switch e.Type {
+		case githttp.PUSH:
+			ref = "refs/heads/" + e.Branch
+		case githttp.TAG:
+			ref = "refs/tags/" + e.Tag
+		default:
+			// PUSH_FORCE events don't name a ref.
+			continue
+		}
+		updates = append(updates, git.RefUpdate{Ref: ref, Old: e.Last, New: e.Commit})
+	}
+	return updates
+}
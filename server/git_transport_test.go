@@ -0,0 +1,518 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/git"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+
+	githttp "github.com/AaronO/go-git-http"
+)
+
+func TestLocalGitTransport_invalidService(t *testing.T) {
+	// The dir doesn't exist, so any git process would fail to start
+	// with a different error.
+	tr := &localGitTransport{dir: "/doesntexist"}
+
+	for _, service := range []string{"", "config", "upload-archive", "upload-pack --help"} {
+		var w bytes.Buffer
+		if err := tr.InfoRefs(context.Background(), &w, service); err != git.ErrInvalidService {
+			t.Errorf("InfoRefs(%q): got err %v, want %v", service, err, git.ErrInvalidService)
+		}
+		if w.Len() != 0 {
+			t.Errorf("InfoRefs(%q): got output %q, want none", service, w.Bytes())
+		}
+
+		if err := tr.servicePack(context.Background(), service, &w, strings.NewReader(""), git.GitTransportOpt{}); err != git.ErrInvalidService {
+			t.Errorf("servicePack(%q): got err %v, want %v", service, err, git.ErrInvalidService)
+		}
+	}
+}
+
+func TestServeInfoRefs_invalidService(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	testHandler.GitTransporter = NewGitTransporter(nil)
+
+	req, err := http.NewRequest("GET", server.URL+"/a.b/c/.git/info/refs?service=git-config", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "git/2.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestLocalGitTransport_cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if out, err := exec.Command("git", "init", "--bare", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s\n%s", err, out)
+	}
+	tr := &localGitTransport{dir: dir}
+
+	for _, encoding := range []string{"", "gzip", "deflate"} {
+		// The request body never ends (and, for gzip, never even
+		// yields a header), like that of a client that hung.
+		body, bodyW := io.Pipe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- tr.UploadPack(ctx, ioutil.Discard, body, git.GitTransportOpt{ContentEncoding: encoding})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		// UploadPack doesn't return while it is still reading the
+		// body.
+		select {
+		case err := <-done:
+			t.Fatalf("%q: UploadPack returned (err %v) while reading the body", encoding, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		bodyW.CloseWithError(errors.New("body read unblocked"))
+
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("%q: got err %v, want %v", encoding, err, context.Canceled)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%q: UploadPack did not return after cancellation", encoding)
+		}
+	}
+}
+
+// TestServeUploadPack_cancel tests that the handler returns when the
+// request is canceled even if the client stops sending the body
+// without closing the connection.
+func TestServeUploadPack_cancel(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	repoPath := "a.b/c"
+	if out, err := exec.Command("git", "init", "--bare", filepath.Join(storageDir, repoPath)).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s\n%s", err, out)
+	}
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	h := NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil)
+
+	served := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(served)
+		ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer s.Close()
+
+	// The request body never ends, like that of a client that hung.
+	body, bodyW := io.Pipe()
+	defer bodyW.Close()
+	req, err := http.NewRequest("POST", s.URL+"/"+repoPath+"/.git/git-upload-pack", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "git/2.0")
+	start := time.Now()
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	select {
+	case <-served:
+		if d := time.Since(start); d < 50*time.Millisecond {
+			t.Errorf("handler returned after %s, before the request was canceled", d)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("handler did not return after cancellation")
+	}
+}
+
+// TestServeGit_tls tests that the git smart-HTTP transport works over
+// TLS (and HTTP/2), as used by `vcsstore serve -tls.cert ... -tls.key ...`.
+func TestServeGit_tls(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	h := NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil)
+
+	tlsServer := httptest.NewUnstartedServer(h)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	// The server speaks HTTP/2.
+	resp, err := tlsServer.Client().Get(tlsServer.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("got protocol %s, want HTTP/2", resp.Proto)
+	}
+
+	caFile := filepath.Join(storageDir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = storageDir
+		cmd.Env = append(os.Environ(), "GIT_SSL_CAINFO="+caFile, "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "--bare", filepath.Join(storageDir, repoPath))
+	run("init", "-q", "work")
+	run("-C", "work", "commit", "-q", "--allow-empty", "-m", "a")
+	run("-C", "work", "push", "-q", tlsServer.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master")
+	run("clone", "-q", tlsServer.URL+"/"+repoPath+"/.git", "clone")
+
+	if got, want := run("-C", "clone", "rev-parse", "HEAD"), run("-C", "work", "rev-parse", "HEAD"); got != want {
+		t.Errorf("got cloned HEAD %s, want %s", got, want)
+	}
+}
+
+func TestServeGit_partialClone(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		storageDir, err := ioutil.TempDir("", "vcsstore-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(storageDir)
+		conf := &vcsstore.Config{StorageDir: storageDir, AllowPartialClone: allow}
+		s := httptest.NewServer(NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil))
+		defer s.Close()
+
+		repoPath := "a.b/c"
+		run := func(args ...string) string {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = storageDir
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("git %v failed: %s\n%s", args, err, out)
+			}
+			return strings.TrimSpace(string(out))
+		}
+		run("init", "-q", "--bare", filepath.Join(storageDir, repoPath))
+		run("init", "-q", "work")
+		if err := ioutil.WriteFile(filepath.Join(storageDir, "work", "f"), []byte("hello"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		run("-C", "work", "add", "f")
+		run("-C", "work", "commit", "-q", "-m", "a")
+		run("-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master")
+		blob := run("-C", "work", "rev-parse", "HEAD:f")
+
+		run("clone", "-q", "--bare", "--filter=blob:none", s.URL+"/"+repoPath+"/.git", "clone")
+		missing := run("-C", "clone", "rev-list", "--objects", "--missing=print", "HEAD")
+		if isMissing := strings.Contains(missing, "?"+blob); isMissing != allow {
+			t.Errorf("allow=%v: got blob missing from clone %v, want %v", allow, isMissing, allow)
+		}
+
+		// The missing blob is fetched on demand.
+		if got := run("-C", "clone", "cat-file", "blob", blob); got != "hello" {
+			t.Errorf("allow=%v: got blob contents %q, want %q", allow, got, "hello")
+		}
+	}
+}
+
+func TestServeGit_dumbHTTP(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		storageDir, err := ioutil.TempDir("", "vcsstore-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(storageDir)
+		conf := &vcsstore.Config{StorageDir: storageDir, AllowDumbHTTP: allow}
+		h := NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil)
+		h.UpdateServerInfo = allow
+		s := httptest.NewServer(h)
+		defer s.Close()
+
+		repoPath := "a.b/c"
+		gitDir := filepath.Join(storageDir, repoPath)
+		git := func(env []string, args ...string) (string, error) {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = storageDir
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+			cmd.Env = append(cmd.Env, env...)
+			out, err := cmd.CombinedOutput()
+			return strings.TrimSpace(string(out)), err
+		}
+		run := func(args ...string) string {
+			out, err := git(nil, args...)
+			if err != nil {
+				t.Fatalf("git %v failed: %s\n%s", args, err, out)
+			}
+			return out
+		}
+		run("init", "-q", "--bare", gitDir)
+		run("init", "-q", "work")
+		if err := ioutil.WriteFile(filepath.Join(storageDir, "work", "f"), []byte("hello"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		run("-C", "work", "add", "f")
+		run("-C", "work", "commit", "-q", "-m", "a")
+		run("-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master")
+
+		// Pack the first commit's objects, so the dumb client must
+		// fetch both packs and loose objects.
+		run("-C", gitDir, "repack", "-q", "-a", "-d")
+		run("-C", "work", "commit", "-q", "--allow-empty", "-m", "b")
+		run("-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master", "HEAD~1:refs/heads/b")
+
+		// GIT_SMART_HTTP=0 makes the client use the dumb protocol.
+		out, err := git([]string{"GIT_SMART_HTTP=0"}, "clone", "-q", "--mirror", s.URL+"/"+repoPath+"/.git", "clone")
+		if !allow {
+			if err == nil {
+				t.Errorf("allow=%v: dumb HTTP clone succeeded, want it to fail", allow)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("allow=%v: dumb HTTP clone failed: %s\n%s", allow, err, out)
+		}
+		if got, want := run("-C", "clone", "for-each-ref"), run("-C", gitDir, "for-each-ref"); got != want {
+			t.Errorf("allow=%v: got refs\n%s\nwant\n%s", allow, got, want)
+		}
+		if got, want := run("-C", "clone", "cat-file", "blob", "b:f"), "hello"; got != want {
+			t.Errorf("allow=%v: got blob contents %q, want %q", allow, got, want)
+		}
+		run("-C", "clone", "fsck", "--no-progress")
+
+		// Serving info/refs only reads the repository. (Refs changed
+		// outside of the server aren't listed until the next change
+		// made through it.)
+		run("-C", gitDir, "update-ref", "refs/heads/direct", "master")
+		req, err := http.NewRequest("GET", s.URL+"/"+repoPath+"/.git/info/refs", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", "git/2.0")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(refs), "refs/heads/b") || strings.Contains(string(refs), "refs/heads/direct") {
+			t.Errorf("allow=%v: got info/refs status %d and contents\n%s\nwant the refs pushed through the server only", allow, resp.StatusCode, refs)
+		}
+	}
+}
+
+func TestServeGit_encodedPush(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	s := httptest.NewServer(NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil))
+	defer s.Close()
+	baseURL, _ := url.Parse(s.URL)
+
+	repoPath := "a.b/c"
+	gitDir := filepath.Join(storageDir, repoPath)
+	run := func(stdin string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = storageDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v failed: %s", args, err)
+		}
+		return string(out)
+	}
+	run("", "init", "-q", "--bare", gitDir)
+	run("", "init", "-q", "work")
+	if err := ioutil.WriteFile(filepath.Join(storageDir, "work", "f"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	run("", "-C", "work", "add", "f")
+
+	tr, err := vcsclient.New(baseURL, nil).GitTransport(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const zero = "0000000000000000000000000000000000000000"
+	old := zero
+	for _, encoding := range []string{"", "gzip", "deflate"} {
+		run("", "-C", "work", "commit", "-q", "--allow-empty", "-m", "encoding "+encoding)
+		commit := strings.TrimSpace(run("", "-C", "work", "rev-parse", "HEAD"))
+
+		// Build the receive-pack request that `git push` would send.
+		cmd := fmt.Sprintf("%s %s refs/heads/master\x00report-status\n", old, commit)
+		var body bytes.Buffer
+		fmt.Fprintf(&body, "%04x%s0000", len(cmd)+4, cmd)
+		revs := commit + "\n"
+		if old != zero {
+			revs += "^" + old + "\n"
+		}
+		body.WriteString(run(revs, "-C", "work", "pack-objects", "-q", "--stdout", "--revs"))
+
+		var out bytes.Buffer
+		if err := tr.ReceivePack(context.Background(), &out, &body, git.GitTransportOpt{ContentEncoding: encoding}); err != nil {
+			t.Fatalf("encoding %q: ReceivePack: %s", encoding, err)
+		}
+		if !strings.Contains(out.String(), "unpack ok") || !strings.Contains(out.String(), "ok refs/heads/master") {
+			t.Errorf("encoding %q: got receive-pack output %q, want the push to succeed", encoding, out.String())
+		}
+		if got := strings.TrimSpace(run("", "-C", gitDir, "rev-parse", "refs/heads/master")); got != commit {
+			t.Errorf("encoding %q: got master %s, want %s", encoding, got, commit)
+		}
+		old = commit
+	}
+}
+
+func TestServeGit_onPush(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	h := NewHandler(vcsstore.NewService(conf), NewGitTransporter(conf), nil)
+	type push struct {
+		repoPath string
+		updates  []git.RefUpdate
+	}
+	var pushes []push
+	h.OnPush = func(repoPath string, updates []git.RefUpdate) {
+		pushes = append(pushes, push{repoPath, updates})
+	}
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	repoPath := "a.b/c"
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = storageDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "--bare", filepath.Join(storageDir, repoPath))
+	run("init", "-q", "work")
+	run("-C", "work", "commit", "-q", "--allow-empty", "-m", "a")
+	commit := run("-C", "work", "rev-parse", "HEAD")
+	const zero = "0000000000000000000000000000000000000000"
+
+	run("-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/b")
+	want := []push{{repoPath, []git.RefUpdate{{Ref: "refs/heads/b", Old: zero, New: commit}}}}
+	if !reflect.DeepEqual(pushes, want) {
+		t.Errorf("got pushes %+v, want %+v", pushes, want)
+	}
+
+	// Fetches don't call OnPush.
+	pushes = nil
+	run("clone", "-q", s.URL+"/"+repoPath+"/.git", "clone")
+	if len(pushes) != 0 {
+		t.Errorf("got pushes %+v after fetch, want none", pushes)
+	}
+
+	// Deleting a ref is an update to the zero ID.
+	pushes = nil
+	run("-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", ":refs/heads/b")
+	want = []push{{repoPath, []git.RefUpdate{{Ref: "refs/heads/b", Old: commit, New: zero}}}}
+	if !reflect.DeepEqual(pushes, want) {
+		t.Errorf("got pushes %+v, want %+v", pushes, want)
+	}
+
+	// Pushes rejected by a hook don't call OnPush.
+	pushes = nil
+	hook := filepath.Join(storageDir, repoPath, "hooks", "pre-receive")
+	if err := ioutil.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-C", "work", "push", "-q", s.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/c")
+	cmd.Dir = storageDir
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("push rejected by hook succeeded, want it to fail\n%s", out)
+	}
+	if len(pushes) != 0 {
+		t.Errorf("got pushes %+v after rejected push, want none", pushes)
+	}
+}
+
+func TestRefUpdatesFromEvents(t *testing.T) {
+	const (
+		a = "1111111111111111111111111111111111111111"
+		b = "2222222222222222222222222222222222222222"
+		z = "0000000000000000000000000000000000000000"
+	)
+	commands := string(packetWrite("shallow "+a+"\n")) +
+		string(packetWrite(a+" "+b+" refs/heads/master\x00report-status side-band-64k")) +
+		string(packetWrite(z+" "+a+" refs/tags/t")) +
+		string(packetFlush())
+
+	// The pack is read after the command list (and, for large
+	// pushes, in separate reads).
+	rpcReader := &githttp.RpcReader{
+		Reader: io.MultiReader(strings.NewReader(commands), strings.NewReader("PACK"+strings.Repeat("x", 100000))),
+		Rpc:    git.ServiceReceivePack,
+	}
+	if _, err := io.Copy(ioutil.Discard, rpcReader); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []git.RefUpdate{
+		{Ref: "refs/heads/master", Old: a, New: b},
+		{Ref: "refs/tags/t", Old: z, New: a},
+	}
+	if got := refUpdatesFromEvents(rpcReader.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("got updates %+v, want %+v", got, want)
+	}
+}
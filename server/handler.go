@@ -1,9 +1,12 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"time"
@@ -30,6 +33,68 @@ type Handler struct {
 	// servers, as internal error messages may reveal sensitive information.
 	Debug bool
 
+	// Authenticator, if set, decides whether each request may operate
+	// on the repository it refers to. If it and AuthorizeRepo are
+	// nil, all requests are allowed except those that modify
+	// repositories via the API (such as setting config or creating
+	// branches).
+	Authenticator Authenticator
+
+	// AuthorizeRepo, if set, is called (after Authenticator) to decide
+	// whether the bearer token (which is empty if the request has
+	// none) permits operation (OpRead, OpClone, OpPush, or OpConfig)
+	// on the repository at repoPath. It returns a non-nil error to
+	// deny access, which results in an HTTP 403 response unless the
+	// error is ErrUnauthorized.
+	AuthorizeRepo func(ctx context.Context, token, repoPath, operation string) error
+
+	// OnPush, if set, is called after each successful push (via the
+	// git smart HTTP transport) to the repository at repoPath with
+	// the ref updates that were pushed.
+	OnPush func(repoPath string, updates []git.RefUpdate)
+
+	// RateLimiter, if set, limits the rate of requests from each
+	// client.
+	RateLimiter *RateLimiter
+
+	// CORS, if set, allows cross-origin requests from browsers. If
+	// nil, responses have no CORS headers.
+	CORS *CORS
+
+	// Metrics is whether to serve Prometheus metrics at /metrics.
+	Metrics bool
+
+	// MaxContentsSize, if nonzero, is the size in bytes above which
+	// file contents are omitted from tree entry responses (and
+	// ContentsOmitted is set), unless the request specifies a range
+	// or EntireFile.
+	MaxContentsSize int64
+
+	// WriteCommitGraphs is whether to write a repository's
+	// commit-graph file (see vcs.CommitGraphWriter) in the background
+	// after it is cloned or updated (via the create-or-update
+	// endpoint) or pushed to, so that commit graph responses can
+	// include generation numbers.
+	WriteCommitGraphs bool
+
+	// UpdateServerInfo is whether to update the files that dumb HTTP
+	// git clients use to find a repository's refs and packs (see
+	// vcs.ServerInfoUpdater) whenever its refs change. Set it if
+	// GitTransporter serves the dumb HTTP protocol.
+	UpdateServerInfo bool
+
+	// CacheMaxAges and RouteCacheMaxAges configure the Cache-Control
+	// headers of responses (see the vcsstore.Config fields of the
+	// same names).
+	CacheMaxAges      vcsstore.CacheMaxAges
+	RouteCacheMaxAges map[string]vcsstore.CacheMaxAges
+
+	// RequestLog, if set, receives a structured record of each
+	// request (see vcsstore.Config.RequestLog).
+	RequestLog *slog.Logger
+
+	commitGraphs commitGraphWriter
+
 	middleware []Middleware
 }
 
@@ -37,6 +102,14 @@ type Handler struct {
 // creates one if parent is nil). Middleware is injected between mux and the
 // handler functions (so you have access to gorilla/context for example)
 func NewHandler(svc vcsstore.Service, gitTrans git.GitTransporter, parent *mux.Router, mw ...Middleware) *Handler {
+	if parent == nil {
+		parent = mux.NewRouter()
+	}
+
+	// Add the metrics route before the vcsclient routes, or else it
+	// would be matched as a repository path.
+	metricsRoute := parent.Path("/metrics").Methods("GET").Name(RouteMetrics)
+
 	router := vcsclient.NewRouter(parent)
 	r := (*mux.Router)(router)
 
@@ -52,27 +125,62 @@ func NewHandler(svc vcsstore.Service, gitTrans git.GitTransporter, parent *mux.R
 		return robustHandler{h, handlerFunc}
 	}
 
+	metricsRoute.HandlerFunc(h.serveMetrics)
+
 	r.Get(git.RouteGitInfoRefs).Handler(handler(h.serveInfoRefs))
 	r.Get(git.RouteGitUploadPack).Handler(handler(h.serveUploadPack))
 	r.Get(git.RouteGitReceivePack).Handler(handler(h.serveReceivePack))
+	r.Get(git.RouteGitDumbFile).Handler(handler(h.serveDumbFile))
 
 	r.Get(vcsclient.RouteRoot).Handler(handler(h.serveRoot))
 	r.Get(vcsclient.RouteRepo).Handler(handler(h.serveRepo))
+	r.Get(vcsclient.RouteRepoExists).Handler(handler(h.serveRepoExists))
 	r.Get(vcsclient.RouteRepoCreateOrUpdate).Handler(handler(h.serveRepoCreateOrUpdate))
+	r.Get(vcsclient.RouteRepoInit).Handler(handler(h.serveRepoInit))
+	r.Get(vcsclient.RouteRepoCloneAsync).Handler(handler(h.serveRepoCloneAsync))
+	r.Get(vcsclient.RouteRepoCloneStatus).Handler(handler(h.serveRepoCloneStatus))
 	r.Get(vcsclient.RouteRepoBlameFile).Handler(handler(h.serveRepoBlameFile))
 	r.Get(vcsclient.RouteRepoBranch).Handler(handler(h.serveRepoBranch))
 	r.Get(vcsclient.RouteRepoBranches).Handler(handler(h.serveRepoBranches))
 	r.Get(vcsclient.RouteRepoCommit).Handler(handler(h.serveRepoCommit))
 	r.Get(vcsclient.RouteRepoCommits).Handler(handler(h.serveRepoCommits))
 	r.Get(vcsclient.RouteRepoCommitters).Handler(handler(h.serveRepoCommitters))
+	r.Get(vcsclient.RouteRepoCommitRefs).Handler(handler(h.serveRepoCommitRefs))
+	r.Get(vcsclient.RouteRepoCommitGraph).Handler(handler(h.serveRepoCommitGraph))
+	r.Get(vcsclient.RouteRepoCommitCount).Handler(handler(h.serveRepoCommitCount))
+	r.Get(vcsclient.RouteRepoCommitNotes).Handler(handler(h.serveRepoCommitNotes))
+	r.Get(vcsclient.RouteRepoCreateBranch).Handler(handler(h.serveRepoCreateBranch))
+	r.Get(vcsclient.RouteRepoCreateTag).Handler(handler(h.serveRepoCreateTag))
+	r.Get(vcsclient.RouteRepoDeleteBranch).Handler(handler(h.serveRepoDeleteBranch))
+	r.Get(vcsclient.RouteRepoDeleteTag).Handler(handler(h.serveRepoDeleteTag))
+	r.Get(vcsclient.RouteRepoDefaultBranch).Handler(handler(h.serveRepoDefaultBranch))
+	r.Get(vcsclient.RouteRepoReflog).Handler(handler(h.serveRepoReflog))
+	r.Get(vcsclient.RouteRepoRevisionAt).Handler(handler(h.serveRepoRevisionAt))
+	r.Get(vcsclient.RouteRepoConfig).Handler(handler(h.serveRepoConfig))
+	r.Get(vcsclient.RouteRepoSetConfig).Handler(handler(h.serveRepoSetConfig))
+	r.Get(vcsclient.RouteRepoGC).Handler(handler(h.serveRepoGC))
+	r.Get(vcsclient.RouteRepoFsck).Handler(handler(h.serveRepoFsck))
+	r.Get(vcsclient.RouteRepoRemotes).Handler(handler(h.serveRepoRemotes))
+	r.Get(vcsclient.RouteRepoAddRemote).Handler(handler(h.serveRepoAddRemote))
+	r.Get(vcsclient.RouteRepoRemoveRemote).Handler(handler(h.serveRepoRemoveRemote))
+	r.Get(vcsclient.RouteRepoFetchRemote).Handler(handler(h.serveRepoFetchRemote))
 	r.Get(vcsclient.RouteRepoDiff).Handler(handler(h.serveRepoDiff))
 	r.Get(vcsclient.RouteRepoCrossRepoDiff).Handler(handler(h.serveRepoCrossRepoDiff))
+	r.Get(vcsclient.RouteRepoTreeDiff).Handler(handler(h.serveRepoTreeDiff))
+	r.Get(vcsclient.RouteRepoDescribe).Handler(handler(h.serveRepoDescribe))
+	r.Get(vcsclient.RouteRepoDiffStat).Handler(handler(h.serveRepoDiffStat))
+	r.Get(vcsclient.RouteRepoFormatPatch).Handler(handler(h.serveRepoFormatPatch))
 	r.Get(vcsclient.RouteRepoMergeBase).Handler(handler(h.serveRepoMergeBase))
+	r.Get(vcsclient.RouteRepoObject).Handler(handler(h.serveRepoObject))
 	r.Get(vcsclient.RouteRepoCrossRepoMergeBase).Handler(handler(h.serveRepoCrossRepoMergeBase))
+	r.Get(vcsclient.RouteRepoIsAncestor).Handler(handler(h.serveRepoIsAncestor))
+	r.Get(vcsclient.RouteRepoAheadBehind).Handler(handler(h.serveRepoAheadBehind))
 	r.Get(vcsclient.RouteRepoSearch).Handler(handler(h.serveRepoSearch))
 	r.Get(vcsclient.RouteRepoRevision).Handler(handler(h.serveRepoRevision))
+	r.Get(vcsclient.RouteRepoRevisions).Handler(handler(h.serveRepoRevisions))
 	r.Get(vcsclient.RouteRepoTag).Handler(handler(h.serveRepoTag))
 	r.Get(vcsclient.RouteRepoTags).Handler(handler(h.serveRepoTags))
+	r.Get(vcsclient.RouteRepoStatMulti).Handler(handler(h.serveRepoStatMulti))
 	r.Get(vcsclient.RouteRepoTreeEntry).Handler(handler(h.serveRepoTreeEntry))
 
 	return h
@@ -80,6 +188,10 @@ func NewHandler(svc vcsstore.Service, gitTrans git.GitTransporter, parent *mux.R
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("date", time.Now().UTC().Format(http.TimeFormat))
+	r = withRequestID(w, r)
+	if h.CORS != nil && h.CORS.handle(w, r) {
+		return
+	}
 	(*mux.Router)(h.router).ServeHTTP(w, r)
 }
 
@@ -92,37 +204,71 @@ type robustHandler struct {
 
 // robust handler wraps f to handle errors it returns.
 func (h robustHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+	var err error
+	defer func() {
+		d := time.Since(start)
+		observeRequest(r, rec.code, d)
+		h.h.logRequest(r, rec.code, d, err)
+	}()
+	w = rec
+
 	innerHandler := func(w http.ResponseWriter, r *http.Request) {
-		err := h.handlerFunc(w, r)
+		err = h.h.rateLimit(w, r)
+		if err == nil {
+			err = h.handlerFunc(w, r)
+		}
 		if err != nil {
 			c := errorHTTPStatusCode(err)
-			h.h.Log.Printf("HTTP %d error serving %q: %s.", c, r.URL.RequestURI(), err)
+			h.h.Log.Printf("HTTP %d error serving %q (request %s): %s.", c, r.URL.RequestURI(), RequestIDFromContext(r.Context()), err)
 			w.Header().Set("cache-control", "no-cache, max-age=0") // don't cache errors
+			if err == ErrUnauthorized {
+				w.Header().Set("www-authenticate", `Bearer realm="vcsstore"`)
+			}
 			http.Error(w, errorBody(h.h.Debug, err), c)
 		}
 	}
 	FuncWithMiddleware(innerHandler, h.h.middleware...)(w, r)
 }
 
-// errorBody formats an error message for the HTTP response.
+// errorBody formats an error message for the HTTP response. Unless
+// debug is true, only the messages of known errors (see
+// vcsclient.KnownError) are included, since other errors may reveal
+// internal details.
 func errorBody(debug bool, err error) string {
-	if debug {
-		data, _ := json.Marshal(&vcsclient.ErrorResponse{Message: err.Error()})
-		return string(data)
+	if !debug && vcsclient.KnownError(unwrapHTTPError(err)) == nil {
+		return ""
 	}
-	return ""
+	data, _ := json.Marshal(&vcsclient.ErrorResponse{Message: err.Error()})
+	return string(data)
 }
 
-// writeJSON writes a JSON Content-Type header and a JSON-encoded object to the
-// http.ResponseWriter.
-func writeJSON(w http.ResponseWriter, v interface{}) error {
-	data, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return &httpError{http.StatusInternalServerError, err}
+// writeResponse writes v to the http.ResponseWriter, encoded as
+// msgpack if r's Accept header prefers it (see
+// vcsclient.AcceptsMsgpack) or else as JSON, along with the
+// corresponding Content-Type header.
+func writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	w.Header().Add("vary", "Accept")
+
+	var data []byte
+	if vcsclient.AcceptsMsgpack(r.Header.Get("accept")) {
+		var buf bytes.Buffer
+		if err := vcsclient.EncodeMsgpack(&buf, v); err != nil {
+			return &httpError{http.StatusInternalServerError, err}
+		}
+		data = buf.Bytes()
+		w.Header().Set("content-type", vcsclient.MsgpackContentType)
+	} else {
+		var err error
+		data, err = json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return &httpError{http.StatusInternalServerError, err}
+		}
+		w.Header().Set("content-type", "application/json; charset=utf-8")
 	}
 
-	w.Header().Set("content-type", "application/json; charset=utf-8")
-	_, err = w.Write(data)
+	_, err := w.Write(data)
 	return err
 }
 
@@ -132,4 +278,11 @@ func init() {
 	schemaDecoder.RegisterConverter(vcs.CommitID(""), func(s string) reflect.Value {
 		return reflect.ValueOf(vcs.CommitID(s))
 	})
+	schemaDecoder.RegisterConverter(time.Time{}, func(s string) reflect.Value {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(t)
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 type httpError struct {
@@ -21,6 +22,22 @@ func (err httpError) Error() string {
 
 func (err httpError) httpStatusCode() int { return err.statusCode }
 
+// unwrapHTTPError returns the reason for err if it is an httpError,
+// or else err.
+func unwrapHTTPError(err error) error {
+	switch e := err.(type) {
+	case *httpError:
+		if e.err != nil {
+			return e.err
+		}
+	case httpError:
+		if e.err != nil {
+			return e.err
+		}
+	}
+	return err
+}
+
 // errorHTTPStatusCode returns the HTTP error code that most closely describes err.
 func errorHTTPStatusCode(err error) int {
 	if c, present := errStatuses[err]; present {
@@ -40,8 +57,32 @@ func errorHTTPStatusCode(err error) int {
 }
 
 var errStatuses = map[error]int{
-	vcs.ErrCommitNotFound:   http.StatusNotFound,
-	vcs.ErrBranchNotFound:   http.StatusNotFound,
-	vcs.ErrRevisionNotFound: http.StatusNotFound,
-	vcs.ErrTagNotFound:      http.StatusNotFound,
+	vcs.ErrCommitNotFound:             http.StatusNotFound,
+	vcs.ErrBranchNotFound:             http.StatusNotFound,
+	vcs.ErrRevisionNotFound:           http.StatusNotFound,
+	vcs.ErrTagNotFound:                http.StatusNotFound,
+	vcs.ErrNoDescription:              http.StatusNotFound,
+	vcs.ErrObjectNotFound:             http.StatusNotFound,
+	vcs.ErrInvalidObjectID:            http.StatusBadRequest,
+	vcs.ErrInvalidFollow:              http.StatusBadRequest,
+	vcs.ErrInvalidTreeSpec:            http.StatusBadRequest,
+	vcs.ErrConfigKeyNotFound:          http.StatusNotFound,
+	vcs.ErrRepoEmpty:                  http.StatusNotFound,
+	vcs.ErrNoteNotFound:               http.StatusNotFound,
+	vcs.ErrNoReflog:                   http.StatusNotFound,
+	vcs.ErrReflogTooShort:             http.StatusNotFound,
+	vcs.ErrInvalidConfigKey:           http.StatusBadRequest,
+	vcs.ErrConfigKeyNotSettable:       http.StatusForbidden,
+	vcs.ErrRefExists:                  http.StatusConflict,
+	vcs.ErrInvalidRefName:             http.StatusBadRequest,
+	vcs.ErrRemoteNotFound:             http.StatusNotFound,
+	vcs.ErrRemoteExists:               http.StatusConflict,
+	vcs.ErrInvalidRemoteName:          http.StatusBadRequest,
+	vcs.ErrInvalidRemoteURL:           http.StatusBadRequest,
+	vcsclient.ErrRepoNotExist:         http.StatusNotFound,
+	vcsclient.ErrRepoExists:           http.StatusConflict,
+	vcsclient.ErrStorageQuotaExceeded: http.StatusInsufficientStorage,
+	vcsclient.ErrCloneJobNotExist:     http.StatusNotFound,
+	ErrUnauthorized:                   http.StatusUnauthorized,
+	ErrForbidden:                      http.StatusForbidden,
 }
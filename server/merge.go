@@ -27,10 +27,10 @@ func (h *Handler) serveRepoMergeBase(w http.ResponseWriter, r *http.Request) err
 
 		var statusCode int
 		if commitIDIsCanon(string(a)) && commitIDIsCanon(string(b)) {
-			setLongCache(w)
+			h.setLongCache(w, r)
 			statusCode = http.StatusMovedPermanently
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 			statusCode = http.StatusFound
 		}
 		http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, mb).String(), statusCode)
@@ -65,10 +65,10 @@ func (h *Handler) serveRepoCrossRepoMergeBase(w http.ResponseWriter, r *http.Req
 
 		var statusCode int
 		if commitIDIsCanon(string(a)) && commitIDIsCanon(string(b)) {
-			setLongCache(w)
+			h.setLongCache(w, r)
 			statusCode = http.StatusMovedPermanently
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 			statusCode = http.StatusFound
 		}
 		http.Redirect(w, r, h.router.URLToRepoCommit(repoPathA, mb).String(), statusCode)
@@ -77,3 +77,73 @@ func (h *Handler) serveRepoCrossRepoMergeBase(w http.ResponseWriter, r *http.Req
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("CrossRepoMerger not yet implemented by %T", repoA)}
 }
+
+func (h *Handler) serveRepoIsAncestor(w http.ResponseWriter, r *http.Request) error {
+	v := mux.Vars(r)
+
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a, canonA, err := checkCommitID(repo, v["CommitIDA"])
+	if err != nil {
+		return err
+	}
+	b, canonB, err := checkCommitID(repo, v["CommitIDB"])
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.AncestorChecker); ok {
+		isAncestor, err := repo.IsAncestor(a, b)
+		if err != nil {
+			return err
+		}
+
+		if canonA && canonB {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		return writeResponse(w, r, isAncestor)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("AncestorChecker not yet implemented by %T", repo)}
+}
+
+func (h *Handler) serveRepoAheadBehind(w http.ResponseWriter, r *http.Request) error {
+	v := mux.Vars(r)
+
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	base, canonBase, err := checkCommitID(repo, v["Base"])
+	if err != nil {
+		return err
+	}
+	head, canonHead, err := checkCommitID(repo, v["Head"])
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.AheadBehindCounter); ok {
+		ahead, behind, err := repo.AheadBehind(base, head)
+		if err != nil {
+			return err
+		}
+
+		if canonBase && canonHead {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		return writeResponse(w, r, &vcs.BehindAhead{Behind: uint32(behind), Ahead: uint32(ahead)})
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("AheadBehindCounter not yet implemented by %T", repo)}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
 	"reflect"
 	"testing"
@@ -135,3 +136,132 @@ func (m *mockCrossRepoMergeBase) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Re
 	m.called = true
 	return m.mergeBase, m.err
 }
+
+func TestServeRepoIsAncestor(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockIsAncestor{
+		t:          t,
+		a:          "a",
+		b:          "b",
+		isAncestor: true,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoIsAncestor(repoPath, "a", "b").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var isAncestor bool
+	if err := json.NewDecoder(resp.Body).Decode(&isAncestor); err != nil {
+		t.Fatal(err)
+	}
+	if !isAncestor {
+		t.Errorf("got isAncestor == false, want true")
+	}
+}
+
+type mockIsAncestor struct {
+	t *testing.T
+
+	// expected args
+	a, b vcs.CommitID
+
+	// return values
+	isAncestor bool
+	err        error
+
+	called bool
+}
+
+func (m *mockIsAncestor) IsAncestor(a, b vcs.CommitID) (bool, error) {
+	if a != m.a {
+		m.t.Errorf("mock: got a == %q, want %q", a, m.a)
+	}
+	if b != m.b {
+		m.t.Errorf("mock: got b == %q, want %q", b, m.b)
+	}
+	m.called = true
+	return m.isAncestor, m.err
+}
+
+func TestServeRepoAheadBehind(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockAheadBehind{
+		t:      t,
+		base:   "a",
+		head:   "b",
+		ahead:  2,
+		behind: 1,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoAheadBehind(repoPath, "a", "b").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var counts vcs.BehindAhead
+	if err := json.NewDecoder(resp.Body).Decode(&counts); err != nil {
+		t.Fatal(err)
+	}
+	if want := (vcs.BehindAhead{Behind: 1, Ahead: 2}); counts != want {
+		t.Errorf("got counts %+v, want %+v", counts, want)
+	}
+}
+
+type mockAheadBehind struct {
+	t *testing.T
+
+	// expected args
+	base, head vcs.CommitID
+
+	// return values
+	ahead, behind int
+	err           error
+
+	called bool
+}
+
+func (m *mockAheadBehind) AheadBehind(base, head vcs.CommitID) (int, int, error) {
+	if base != m.base {
+		m.t.Errorf("mock: got base == %q, want %q", base, m.base)
+	}
+	if head != m.head {
+		m.t.Errorf("mock: got head == %q, want %q", head, m.head)
+	}
+	m.called = true
+	return m.ahead, m.behind, m.err
+}
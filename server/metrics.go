@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sourcegraph/mux"
+)
+
+// RouteMetrics is the name of the route that serves Prometheus
+// metrics (if Handler.Metrics is true).
+const RouteMetrics = "metrics"
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vcsstore",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests, by route, VCS type, and status code.",
+	}, []string{"route", "vcs", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vcsstore",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latencies, by route and VCS type.",
+	}, []string{"route", "vcs"})
+
+	gitCommandCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vcsstore",
+		Subsystem: "git",
+		Name:      "commands_total",
+		Help:      "Total number of git subprocesses run by the git transport, by git service and result.",
+	}, []string{"service", "result"})
+	gitCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vcsstore",
+		Subsystem: "git",
+		Name:      "command_duration_seconds",
+		Help:      "Durations of git subprocesses run by the git transport, by git service.",
+	}, []string{"service"})
+
+	commitCountCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vcsstore",
+		Subsystem: "commit_count_cache",
+		Name:      "lookups_total",
+		Help:      "Total number of commit count cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+	diffCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vcsstore",
+		Subsystem: "diff_cache",
+		Name:      "lookups_total",
+		Help:      "Total number of diff cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount, requestDuration, gitCommandCount, gitCommandDuration, commitCountCacheLookups, diffCacheLookups)
+}
+
+func (h *Handler) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.Metrics {
+		http.NotFound(w, r)
+		return
+	}
+	promhttp.Handler().ServeHTTP(w, r)
+}
+
+// vcsTypeKey is the gorilla/context key for the VCS type ("git",
+// "hg", etc.) of the repository that a request operates on.
+type vcsTypeKey struct{}
+
+// setVCSType records the VCS type of the request's repository for
+// the request's metrics.
+func setVCSType(r *http.Request, vcsType string) {
+	context.Set(r, vcsTypeKey{}, vcsType)
+}
+
+// repoVCSType returns the VCS type of repo, based on the go-vcs
+// package that implements it. To keep metrics cardinality bounded, it
+// never returns anything other than "git", "hg", "bzr", or "other".
+func repoVCSType(repo interface{}) string {
+	t := reflect.TypeOf(repo)
+	if t == nil {
+		return "other"
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch path.Base(t.PkgPath()) {
+	case "git", "gitcmd":
+		return "git"
+	case "hg", "hgcmd":
+		return "hg"
+	case "bzrcmd":
+		return "bzr"
+	}
+	return "other"
+}
+
+// observeRequest records metrics for a request that took d to serve
+// and responded with the HTTP status code.
+func observeRequest(r *http.Request, code int, d time.Duration) {
+	var route string
+	if rt := mux.CurrentRoute(r); rt != nil {
+		route = rt.GetName()
+	}
+	vcsType, _ := context.Get(r, vcsTypeKey{}).(string)
+	if vcsType == "" {
+		vcsType = "none"
+	}
+	requestCount.WithLabelValues(route, vcsType, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(route, vcsType).Observe(d.Seconds())
+}
+
+// observeGitCommand records metrics for a git transport subprocess.
+func observeGitCommand(service string, err error, d time.Duration) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	gitCommandCount.WithLabelValues(service, result).Inc()
+	gitCommandDuration.WithLabelValues(service).Observe(d.Seconds())
+}
+
+// observeCommitCountCacheLookup records whether a commit count
+// cache lookup was a hit.
+func observeCommitCountCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	commitCountCacheLookups.WithLabelValues(result).Inc()
+}
+
+// observeDiffCacheLookup records whether a diff cache lookup was a
+// hit.
+func observeDiffCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	diffCacheLookups.WithLabelValues(result).Inc()
+}
+
+// statusRecorder is an http.ResponseWriter that records the HTTP
+// status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for
+// http.ResponseController.
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Flush implements http.Flusher so that streaming responses (such as
+// the git transport's) are not buffered.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeMetrics(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	testHandler.Metrics = true
+
+	// Make a request so that there are request metrics to report.
+	resp, err := http.Get(server.URL + testHandler.router.URLTo(vcsclient.RouteRoot).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `vcsstore_http_requests_total{code="200"`; !strings.Contains(string(body), want) {
+		t.Errorf("got metrics %q, want them to contain %q", body, want)
+	}
+	if want := `route="vcs:root"`; !strings.Contains(string(body), want) {
+		t.Errorf("got metrics %q, want them to contain %q", body, want)
+	}
+}
+
+func TestServeMetrics_disabled(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func (h *Handler) serveRepoCommitNotes(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var opt vcsclient.NotesOptions
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return err
+	}
+
+	commitID, _, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if repo, ok := repo.(vcs.NotesGetter); ok {
+		note, err := repo.GetNotes(commitID, opt.Ref)
+		if err != nil {
+			return err
+		}
+
+		// Don't cache for long even if the commit ID is canonical,
+		// because notes can be added, edited, or removed.
+		h.setShortCache(w, r)
+		return writeResponse(w, r, note)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("GetNotes not yet implemented for %T", repo)}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoCommitNotes(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID("abcd")
+
+	rm := &mockGetNotes{
+		t:        t,
+		commitID: commitID,
+		ref:      "ci",
+		note:     "build: passed",
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitNotes(repoPath, commitID, vcsclient.NotesOptions{Ref: "ci"}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var note string
+	if err := json.NewDecoder(resp.Body).Decode(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note != rm.note {
+		t.Errorf("got note %q, want %q", note, rm.note)
+	}
+}
+
+func TestServeRepoCommitNotes_noteNotFound(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	commitID := vcs.CommitID("abcd")
+
+	rm := &mockGetNotes{
+		t:        t,
+		commitID: commitID,
+		err:      vcs.ErrNoteNotFound,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoCommitNotes(repoPath, commitID, vcsclient.NotesOptions{}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+	if err := vcsclient.KnownError(vcsclient.CheckResponse(resp, false)); err != vcs.ErrNoteNotFound {
+		t.Errorf("got error %v, want %v", err, vcs.ErrNoteNotFound)
+	}
+}
+
+type mockGetNotes struct {
+	t *testing.T
+
+	// expected args
+	commitID vcs.CommitID
+	ref      string
+
+	// return values
+	note string
+	err  error
+
+	called bool
+}
+
+func (m *mockGetNotes) GetNotes(commitID vcs.CommitID, ref string) (string, error) {
+	if commitID != m.commitID {
+		m.t.Errorf("mock: got commitID %q, want %q", commitID, m.commitID)
+	}
+	if ref != m.ref {
+		m.t.Errorf("mock: got ref %q, want %q", ref, m.ref)
+	}
+	m.called = true
+	return m.note, m.err
+}
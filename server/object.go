@@ -0,0 +1,41 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func (h *Handler) serveRepoObject(w http.ResponseWriter, r *http.Request) error {
+	// Validate the object ID before it gets anywhere near git.
+	id := mux.Vars(r)["ObjectID"]
+	if !vcs.ValidObjectID(id) {
+		return vcs.ErrInvalidObjectID
+	}
+
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.ObjectGetter); ok {
+		typ, contents, err := repo.GetObject(id)
+		if err != nil {
+			return err
+		}
+		defer contents.Close()
+
+		w.Header().Set(vcsclient.ObjectTypeHeader, typ)
+		w.Header().Set("content-type", "application/octet-stream")
+		h.setLongCache(w, r) // objects are immutable
+		_, err = io.Copy(w, contents)
+		return err
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("GetObject not yet implemented for %T", repo)}
+}
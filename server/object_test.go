@@ -0,0 +1,131 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoObject(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	id := strings.Repeat("a", 40)
+
+	rm := &mockGetObject{
+		t:        t,
+		id:       id,
+		typ:      "blob",
+		contents: "hello",
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoObject(repoPath, id).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	if got := resp.Header.Get(vcsclient.ObjectTypeHeader); got != "blob" {
+		t.Errorf("got object type %q, want %q", got, "blob")
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != rm.contents {
+		t.Errorf("got contents %q, want %q", data, rm.contents)
+	}
+}
+
+func TestServeRepoObject_invalidID(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockGetObject{t: t}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	for _, id := range []string{"HEAD", "abcd", strings.Repeat("A", 40), strings.Repeat("a", 41), "-" + strings.Repeat("a", 39)} {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoObject(repoPath, id).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+			t.Errorf("%s: got status %d, want %d", id, got, want)
+		}
+	}
+	if rm.called {
+		t.Error("got GetObject called, want invalid object IDs rejected first")
+	}
+}
+
+func TestServeRepoObject_notFound(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	id := strings.Repeat("a", 40)
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     &mockGetObject{t: t, id: id, err: vcs.ErrObjectNotFound},
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoObject(repoPath, id).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+type mockGetObject struct {
+	t *testing.T
+
+	// expected args
+	id string
+
+	// return values
+	typ      string
+	contents string
+	err      error
+
+	called bool
+}
+
+func (m *mockGetObject) GetObject(id string) (string, io.ReadCloser, error) {
+	if id != m.id {
+		m.t.Errorf("mock: got id %q, want %q", id, m.id)
+	}
+	m.called = true
+	if m.err != nil {
+		return "", nil, m.err
+	}
+	return m.typ, ioutil.NopCloser(strings.NewReader(m.contents)), nil
+}
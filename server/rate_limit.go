@@ -0,0 +1,218 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/git"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+// errRateLimited is returned when a client exceeds its rate limit.
+var errRateLimited = &httpError{http.StatusTooManyRequests, errors.New("rate limit exceeded")}
+
+// RouteCostTreeEntryLastCommit is the key in RateLimiter.Costs of a
+// request to the tree entry route for the entries' last commits
+// (?lastCommit=1), which runs git log for each entry.
+const RouteCostTreeEntryLastCommit = vcsclient.RouteRepoTreeEntry + "?lastCommit"
+
+// DefaultRouteCosts is the default cost (in rate limiter tokens) of a
+// request to each route. Routes not listed cost 1 token.
+var DefaultRouteCosts = map[string]float64{
+	vcsclient.RouteRepoBlameFile:     5,
+	vcsclient.RouteRepoCommitCount:   2,
+	vcsclient.RouteRepoCommits:       2,
+	vcsclient.RouteRepoCrossRepoDiff: 10,
+	vcsclient.RouteRepoDiff:          10,
+	vcsclient.RouteRepoFormatPatch:   10,
+	vcsclient.RouteRepoGC:            20,
+	vcsclient.RouteRepoSearch:        10,
+	vcsclient.RouteRepoStatMulti:     2,
+	vcsclient.RouteRepoTreeDiff:      5,
+	RouteCostTreeEntryLastCommit:     5,
+	git.RouteGitUploadPack:           5,
+}
+
+// A RateLimiter limits the rate of requests from each client using a
+// token bucket per client. Clients are identified by their bearer
+// token if it has been verified (see Verified), or else by their IP
+// address, so that sending made-up tokens doesn't evade the limit.
+type RateLimiter struct {
+	// Rate is the number of tokens added to each client's bucket per
+	// second.
+	Rate float64
+
+	// Burst is the capacity of each client's bucket.
+	Burst float64
+
+	// Costs is the cost (in tokens) of a request to each route, by
+	// route name (or RouteCostTreeEntryLastCommit). Routes not listed
+	// cost 1 token.
+	Costs map[string]float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	verified  map[string]time.Time // bearer token -> when it was last verified
+	lastPrune time.Time
+}
+
+// NewRateLimiter creates a RateLimiter configured by conf. If
+// conf.RateLimit is 0, it returns nil (i.e., no rate limiting).
+func NewRateLimiter(conf *vcsstore.Config) *RateLimiter {
+	if conf.RateLimit <= 0 {
+		return nil
+	}
+	burst := float64(conf.RateLimitBurst)
+	if burst == 0 {
+		burst = math.Max(1, conf.RateLimit)
+	}
+	return &RateLimiter{
+		Rate:  conf.RateLimit,
+		Burst: burst,
+		Costs: DefaultRouteCosts,
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // when tokens was last updated
+}
+
+// rateLimiterPruneInterval is how often buckets of idle clients are
+// removed.
+const rateLimiterPruneInterval = time.Minute
+
+// verifiedTokenTTL is how long a bearer token identifies its client
+// after it was last verified.
+const verifiedTokenTTL = time.Hour
+
+// Allow takes the cost of r from its client's bucket and returns
+// whether r is allowed. If it is not, it also returns how long the
+// client must wait until it would be.
+func (l *RateLimiter) Allow(r *http.Request) (ok bool, retryAfter time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	key := l.key(r, now)
+	l.mu.Unlock()
+	return l.take(key, l.cost(r), now)
+}
+
+// Verified records that r's bearer token (if any) was successfully
+// authenticated, so that later requests with it are limited by token
+// instead of by IP address.
+func (l *RateLimiter) Verified(r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.verified == nil {
+		l.verified = map[string]time.Time{}
+	}
+	l.verified[token] = time.Now()
+}
+
+// key returns the key that identifies r's client. l.mu must be held.
+func (l *RateLimiter) key(r *http.Request, now time.Time) string {
+	if token, ok := bearerToken(r); ok {
+		if t, present := l.verified[token]; present && now.Sub(t) < verifiedTokenTTL {
+			return "token:" + token
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func (l *RateLimiter) cost(r *http.Request) float64 {
+	cost := 1.0
+	if rt := mux.CurrentRoute(r); rt != nil {
+		route := rt.GetName()
+		if route == vcsclient.RouteRepoTreeEntry {
+			if v, _ := strconv.ParseBool(r.URL.Query().Get("lastCommit")); v {
+				route = RouteCostTreeEntryLastCommit
+			}
+		}
+		if c, present := l.Costs[route]; present {
+			cost = c
+		}
+	}
+	// Don't reject requests that could never be allowed.
+	return math.Min(cost, l.Burst)
+}
+
+func (l *RateLimiter) take(key string, cost float64, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[string]*tokenBucket{}
+	}
+	if now.Sub(l.lastPrune) > rateLimiterPruneInterval {
+		l.prune(now)
+	}
+
+	b, present := l.buckets[key]
+	if !present {
+		b = &tokenBucket{tokens: l.Burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refilled(b, now)
+	b.last = now
+
+	if b.tokens < cost {
+		wait := time.Duration((cost - b.tokens) / l.Rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens -= cost
+	return true, 0
+}
+
+// refilled returns the number of tokens in b at time now.
+func (l *RateLimiter) refilled(b *tokenBucket, now time.Time) float64 {
+	return math.Min(l.Burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+}
+
+// prune removes full buckets, which are indistinguishable from new
+// buckets, and expired verified tokens, so that memory use doesn't
+// grow with the number of distinct clients.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refilled(b, now) >= l.Burst {
+			delete(l.buckets, key)
+		}
+	}
+	for token, t := range l.verified {
+		if now.Sub(t) >= verifiedTokenTTL {
+			delete(l.verified, token)
+		}
+	}
+	l.lastPrune = now
+}
+
+// rateLimit returns errRateLimited (and sets the Retry-After header)
+// if r exceeds its client's rate limit.
+func (h *Handler) rateLimit(w http.ResponseWriter, r *http.Request) error {
+	if h.RateLimiter == nil {
+		return nil
+	}
+	if ok, retryAfter := h.RateLimiter.Allow(r); !ok {
+		secs := int(math.Ceil(retryAfter.Seconds()))
+		if secs < 1 {
+			secs = 1
+		}
+		w.Header().Set("retry-after", strconv.Itoa(secs))
+		return errRateLimited
+	}
+	return nil
+}
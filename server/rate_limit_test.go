@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestRateLimiter_take(t *testing.T) {
+	l := &RateLimiter{Rate: 2, Burst: 4}
+	now := time.Unix(1000, 0)
+
+	// The bucket starts full.
+	for i := 0; i < 4; i++ {
+		if ok, _ := l.take("a", 1, now); !ok {
+			t.Fatalf("request %d: not allowed, want allowed", i)
+		}
+	}
+	ok, retryAfter := l.take("a", 1, now)
+	if ok {
+		t.Fatal("request over burst: allowed, want not allowed")
+	}
+	if want := 500 * time.Millisecond; retryAfter != want {
+		t.Errorf("got retryAfter %s, want %s", retryAfter, want)
+	}
+
+	// Other clients have their own buckets.
+	if ok, _ := l.take("b", 3, now); !ok {
+		t.Error("other client: not allowed, want allowed")
+	}
+
+	// Tokens are added at Rate per second.
+	now = now.Add(time.Second)
+	if ok, _ := l.take("a", 2, now); !ok {
+		t.Error("after refill: not allowed, want allowed")
+	}
+	if ok, _ := l.take("a", 1, now); ok {
+		t.Error("after refill and take: allowed, want not allowed")
+	}
+}
+
+func TestRateLimiter_prune(t *testing.T) {
+	l := &RateLimiter{Rate: 1, Burst: 1}
+	now := time.Unix(1000, 0)
+	l.take("a", 1, now)
+	l.take("b", 1, now.Add(rateLimiterPruneInterval))
+
+	l.take("c", 1, now.Add(rateLimiterPruneInterval+time.Second))
+	if _, present := l.buckets["a"]; present {
+		t.Error("idle client's bucket was not pruned")
+	}
+	if _, present := l.buckets["c"]; !present {
+		t.Error("active client's bucket was pruned")
+	}
+}
+
+func TestServeRateLimited(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	testHandler.RateLimiter = &RateLimiter{Rate: 0.1, Burst: 2}
+
+	url := server.URL + testHandler.router.URLTo(vcsclient.RouteRoot).String()
+	for i, wantStatus := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != wantStatus {
+			t.Errorf("request %d: got code %d, want %d", i, got, wantStatus)
+		}
+		if wantStatus == http.StatusTooManyRequests {
+			if got, want := resp.Header.Get("retry-after"), "10"; got != want {
+				t.Errorf("got Retry-After %q, want %q", got, want)
+			}
+		}
+	}
+}
+
+func TestRateLimiter_key(t *testing.T) {
+	l := &RateLimiter{Rate: 1, Burst: 1}
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("authorization", "Bearer t")
+	now := time.Now()
+
+	// Unverified tokens don't identify the client.
+	if got, want := l.key(r, now), "ip:1.2.3.4"; got != want {
+		t.Errorf("unverified token: got key %q, want %q", got, want)
+	}
+
+	l.Verified(r)
+	if got, want := l.key(r, now), "token:t"; got != want {
+		t.Errorf("verified token: got key %q, want %q", got, want)
+	}
+
+	// Verification expires.
+	if got, want := l.key(r, now.Add(verifiedTokenTTL+time.Second)), "ip:1.2.3.4"; got != want {
+		t.Errorf("expired token: got key %q, want %q", got, want)
+	}
+	l.prune(now.Add(verifiedTokenTTL + time.Second))
+	if _, present := l.verified["t"]; present {
+		t.Error("expired token was not pruned")
+	}
+}
+
+func TestServeRateLimited_unverifiedTokens(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	testHandler.RateLimiter = &RateLimiter{Rate: 0.1, Burst: 2}
+
+	// Each request has a different (made-up) token, but they share
+	// the client IP's bucket.
+	url := server.URL + testHandler.router.URLTo(vcsclient.RouteRoot).String()
+	for i, wantStatus := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("authorization", fmt.Sprintf("Bearer t%d", i))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.StatusCode; got != wantStatus {
+			t.Errorf("request %d: got code %d, want %d", i, got, wantStatus)
+		}
+	}
+}
+
+func TestRateLimiter_cost_lastCommit(t *testing.T) {
+	l := &RateLimiter{Burst: 100, Costs: DefaultRouteCosts}
+	router := vcsclient.NewRouter(nil)
+	for _, tc := range []struct {
+		query string
+		want  float64
+	}{
+		{"", 1},
+		{"?lastCommit=1", DefaultRouteCosts[RouteCostTreeEntryLastCommit]},
+	} {
+		var got float64
+		(*mux.Router)(router).Get(vcsclient.RouteRepoTreeEntry).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = l.cost(r)
+		})
+		u := router.URLToRepoTreeEntry("a.b/c", "c", "p")
+		req, _ := http.NewRequest("GET", u.String()+tc.query, nil)
+		(*mux.Router)(router).ServeHTTP(httptest.NewRecorder(), req)
+		if got != tc.want {
+			t.Errorf("%q: got cost %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoCreateBranch(w http.ResponseWriter, r *http.Request) error {
+	return h.serveRepoEditRef(w, r, "Branch", func(repo vcs.RefEditor, name string, commitID vcs.CommitID) error {
+		return repo.CreateBranch(name, commitID)
+	})
+}
+
+func (h *Handler) serveRepoDeleteBranch(w http.ResponseWriter, r *http.Request) error {
+	return h.serveRepoEditRef(w, r, "Branch", func(repo vcs.RefEditor, name string, _ vcs.CommitID) error {
+		return repo.DeleteBranch(name)
+	})
+}
+
+func (h *Handler) serveRepoCreateTag(w http.ResponseWriter, r *http.Request) error {
+	return h.serveRepoEditRef(w, r, "Tag", func(repo vcs.RefEditor, name string, commitID vcs.CommitID) error {
+		return repo.CreateTag(name, commitID)
+	})
+}
+
+func (h *Handler) serveRepoDeleteTag(w http.ResponseWriter, r *http.Request) error {
+	return h.serveRepoEditRef(w, r, "Tag", func(repo vcs.RefEditor, name string, _ vcs.CommitID) error {
+		return repo.DeleteTag(name)
+	})
+}
+
+// serveRepoEditRef serves a request to create (with a POST whose body
+// is the JSON-encoded commit ID) or delete the branch or tag named by
+// the route variable nameVar.
+func (h *Handler) serveRepoEditRef(w http.ResponseWriter, r *http.Request, nameVar string, edit func(repo vcs.RefEditor, name string, commitID vcs.CommitID) error) error {
+	name := mux.Vars(r)[nameVar]
+	if !vcs.ValidRefName(name) {
+		return vcs.ErrInvalidRefName
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var commitID vcs.CommitID
+	if r.Method == "POST" {
+		if err := json.NewDecoder(r.Body).Decode(&commitID); err != nil {
+			return &httpError{http.StatusBadRequest, err}
+		}
+		commitID, _, err = checkCommitID(repo, string(commitID))
+		if err != nil {
+			return err
+		}
+	}
+
+	if repo, ok := repo.(vcs.RefEditor); ok {
+		err := edit(repo, name, commitID)
+		if err != nil {
+			return err
+		}
+		h.refsChanged(repoPath)
+
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("RefEditor not yet implemented for %T", repo)}
+}
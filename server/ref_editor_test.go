@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoEditRef(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	testHandler.Service = vcsstore.NewService(&vcsstore.Config{StorageDir: storageDir})
+
+	repoPath := "a.b/c"
+	cmd := exec.Command("sh", "-c", "git init -q && git commit -q --allow-empty -m a && git rev-parse HEAD")
+	cmd.Dir = filepath.Join(storageDir, repoPath)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+	if err := os.MkdirAll(cmd.Dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git failed: %s\n%s", err, out)
+	}
+	commitID := vcs.CommitID(strings.TrimSpace(string(out)))
+
+	baseURL, _ := url.Parse(server.URL)
+	repo_, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repo_.(interface {
+		vcs.Repository
+		vcs.RefEditor
+	})
+
+	// Without authentication configured, refs can't be edited.
+	if err := repo.CreateBranch("b", commitID); !vcsclient.IsHTTPErrorCode(err, 403) {
+		t.Errorf("CreateBranch without auth: got error %v, want HTTP 403", err)
+	}
+
+	var gotOp string
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		gotOp = op
+		return nil
+	}
+
+	if _, err := repo.ResolveBranch("b"); err != vcs.ErrBranchNotFound {
+		t.Fatalf("ResolveBranch before CreateBranch: got error %v, want %v", err, vcs.ErrBranchNotFound)
+	}
+	if err := repo.CreateBranch("b", commitID); err != nil {
+		t.Fatalf("CreateBranch: %s", err)
+	}
+	if gotOp != OpPush {
+		t.Errorf("got operation %q, want %q", gotOp, OpPush)
+	}
+	if id, err := repo.ResolveBranch("b"); err != nil || id != commitID {
+		t.Errorf("ResolveBranch after CreateBranch: got %q, %v, want %q", id, err, commitID)
+	}
+	if err := repo.CreateBranch("b", commitID); err != vcs.ErrRefExists {
+		t.Errorf("CreateBranch of existing branch: got error %v, want %v", err, vcs.ErrRefExists)
+	}
+	if err := repo.DeleteBranch("b"); err != nil {
+		t.Errorf("DeleteBranch: %s", err)
+	}
+	if _, err := repo.ResolveBranch("b"); err != vcs.ErrBranchNotFound {
+		t.Errorf("ResolveBranch after DeleteBranch: got error %v, want %v", err, vcs.ErrBranchNotFound)
+	}
+	if err := repo.DeleteBranch("b"); err != vcs.ErrBranchNotFound {
+		t.Errorf("DeleteBranch of nonexistent branch: got error %v, want %v", err, vcs.ErrBranchNotFound)
+	}
+
+	if err := repo.CreateTag("v1", commitID); err != nil {
+		t.Fatalf("CreateTag: %s", err)
+	}
+	if id, err := repo.ResolveTag("v1"); err != nil || id != commitID {
+		t.Errorf("ResolveTag after CreateTag: got %q, %v, want %q", id, err, commitID)
+	}
+	if err := repo.DeleteTag("v1"); err != nil {
+		t.Errorf("DeleteTag: %s", err)
+	}
+	if err := repo.DeleteTag("v1"); err != vcs.ErrTagNotFound {
+		t.Errorf("DeleteTag of nonexistent tag: got error %v, want %v", err, vcs.ErrTagNotFound)
+	}
+
+	if err := repo.CreateBranch("-f", commitID); !vcsclient.IsHTTPErrorCode(err, 400) {
+		t.Errorf("CreateBranch with invalid name: got error %v, want HTTP 400", err)
+	}
+}
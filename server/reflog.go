@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func (h *Handler) serveRepoReflog(w http.ResponseWriter, r *http.Request) error {
+	ref := mux.Vars(r)["Ref"]
+	if !vcs.ValidRefName(ref) {
+		return vcs.ErrInvalidRefName
+	}
+
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.Reflogger); ok {
+		entries, err := repo.Reflog(ref)
+		if err != nil && err != vcs.ErrNoReflog {
+			return err
+		}
+
+		// The reflog changes whenever the ref is updated.
+		w.Header().Set("cache-control", "no-cache, max-age=0")
+
+		// A missing reflog (as in bare mirror clones, which don't
+		// record one) is an empty result, not an error.
+		return writeResponse(w, r, &vcsclient.Reflog{Entries: entries, NoReflog: err == vcs.ErrNoReflog})
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("Reflog not yet implemented for %T", repo)}
+}
+
+func (h *Handler) serveRepoRevisionAt(w http.ResponseWriter, r *http.Request) error {
+	ref := mux.Vars(r)["Ref"]
+	if !vcs.ValidRefName(ref) {
+		return vcs.ErrInvalidRefName
+	}
+
+	var opt vcsclient.RevisionAtOpt
+	if err := schemaDecoder.Decode(&opt, r.URL.Query()); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	if opt.At.IsZero() {
+		return &httpError{http.StatusBadRequest, errors.New("At is empty")}
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.RevisionAtResolver); ok {
+		commitID, err := repo.ResolveRevisionAt(ref, opt.At)
+		if err != nil {
+			return err
+		}
+
+		// The reflog only grows, but a ref can be updated with a
+		// backdated entry (or its reflog expired), so don't cache
+		// this for long.
+		h.setShortCache(w, r)
+		http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, commitID).String(), http.StatusFound)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("ResolveRevisionAt not yet implemented for %T", repo)}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestServeRepoReflog(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+
+	rm := &mockReflog{
+		t:   t,
+		ref: "refs/heads/master",
+		entries: []*vcs.ReflogEntry{
+			{Old: "abcd", New: "ef01", Actor: vcs.Signature{Name: "a", Email: "a@a.com"}, Action: "push"},
+		},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoReflog(repoPath, "refs/heads/master").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var reflog *vcsclient.Reflog
+	if err := json.NewDecoder(resp.Body).Decode(&reflog); err != nil {
+		t.Fatal(err)
+	}
+	want := &vcsclient.Reflog{Entries: rm.entries}
+	if !reflect.DeepEqual(reflog, want) {
+		t.Errorf("got reflog %+v, want %+v", reflog, want)
+	}
+}
+
+func TestServeRepoReflog_noReflog(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+
+	rm := &mockReflog{
+		t:   t,
+		ref: "master",
+		err: vcs.ErrNoReflog,
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoReflog(repoPath, "master").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	var reflog *vcsclient.Reflog
+	if err := json.NewDecoder(resp.Body).Decode(&reflog); err != nil {
+		t.Fatal(err)
+	}
+	want := &vcsclient.Reflog{NoReflog: true}
+	if !reflect.DeepEqual(reflog, want) {
+		t.Errorf("got reflog %+v, want %+v", reflog, want)
+	}
+}
+
+func TestServeRepoRevisionAt(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	at := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	rm := &mockResolveRevisionAt{
+		t:        t,
+		ref:      "refs/heads/master",
+		at:       at,
+		commitID: "abcd",
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLToRepoRevisionAt(repoPath, "refs/heads/master", &vcsclient.RevisionAtOpt{At: at}).String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, "abcd"))
+
+	if cc := resp.Header.Get("cache-control"); cc != shortCacheControl {
+		t.Errorf("got cache-control %q, want %q", cc, shortCacheControl)
+	}
+}
+
+func TestServeRepoRevisionAt_errors(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	at := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{vcs.ErrReflogTooShort, http.StatusNotFound},
+		{vcs.ErrNoReflog, http.StatusNotFound},
+		{vcs.ErrRevisionNotFound, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		rm := &mockResolveRevisionAt{t: t, ref: "master", at: at, err: test.err}
+		testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm}
+
+		resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLToRepoRevisionAt(repoPath, "master", &vcsclient.RevisionAtOpt{At: at}).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.wantStatus {
+			t.Errorf("%v: got status %d, want %d", test.err, resp.StatusCode, test.wantStatus)
+		}
+	}
+
+	// A missing or malformed time is a bad request.
+	for _, query := range []string{"", "?At=yesterday"} {
+		testHandler.Service = &mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: &mockResolveRevisionAt{t: t}}
+		resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLTo(vcsclient.RouteRepoRevisionAt, "RepoPath", repoPath, "Ref", "master").String() + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+			t.Errorf("query %q: got status %d, want %d", query, got, want)
+		}
+	}
+}
+
+type mockReflog struct {
+	t *testing.T
+
+	// expected args
+	ref string
+
+	// return values
+	entries []*vcs.ReflogEntry
+	err     error
+
+	called bool
+}
+
+func (m *mockReflog) Reflog(ref string) ([]*vcs.ReflogEntry, error) {
+	if ref != m.ref {
+		m.t.Errorf("mock: got ref %q, want %q", ref, m.ref)
+	}
+	m.called = true
+	return m.entries, m.err
+}
+
+type mockResolveRevisionAt struct {
+	t *testing.T
+
+	// expected args
+	ref string
+	at  time.Time
+
+	// return values
+	commitID vcs.CommitID
+	err      error
+
+	called bool
+}
+
+func (m *mockResolveRevisionAt) ResolveRevisionAt(ref string, t time.Time) (vcs.CommitID, error) {
+	if ref != m.ref {
+		m.t.Errorf("mock: got ref %q, want %q", ref, m.ref)
+	}
+	if !t.Equal(m.at) {
+		m.t.Errorf("mock: got time %v, want %v", t, m.at)
+	}
+	m.called = true
+	return m.commitID, m.err
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sourcegraph/mux"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func (h *Handler) serveRepoRemotes(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.RemoteManager); ok {
+		remotes, err := repo.ListRemotes()
+		if err != nil {
+			return err
+		}
+		if remotes == nil {
+			remotes = []*vcs.Remote{}
+		}
+
+		w.Header().Set("cache-control", "no-cache, max-age=0")
+		return writeResponse(w, r, remotes)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("ListRemotes not yet implemented for %T", repo)}
+}
+
+func (h *Handler) serveRepoAddRemote(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["Remote"]
+	if !vcs.ValidRemoteName(name) {
+		return vcs.ErrInvalidRemoteName
+	}
+
+	var url string
+	if err := json.NewDecoder(r.Body).Decode(&url); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	if !vcs.ValidRemoteURL(url) {
+		return vcs.ErrInvalidRemoteURL
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.RemoteManager); ok {
+		if err := repo.AddRemote(name, url); err != nil {
+			return err
+		}
+		h.Log.Printf("Remotes: added %s to repo %s.", name, repoPath)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("AddRemote not yet implemented for %T", repo)}
+}
+
+func (h *Handler) serveRepoRemoveRemote(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["Remote"]
+	if !vcs.ValidRemoteName(name) {
+		return vcs.ErrInvalidRemoteName
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.RemoteManager); ok {
+		err := repo.RemoveRemote(name)
+		// Removing a remote also removes its remote-tracking refs.
+		h.refsChanged(repoPath)
+		if err != nil {
+			return err
+		}
+		h.Log.Printf("Remotes: removed %s from repo %s.", name, repoPath)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("RemoveRemote not yet implemented for %T", repo)}
+}
+
+func (h *Handler) serveRepoFetchRemote(w http.ResponseWriter, r *http.Request) error {
+	name := mux.Vars(r)["Remote"]
+	if !vcs.ValidRemoteName(name) {
+		return vcs.ErrInvalidRemoteName
+	}
+
+	var opt vcs.RemoteOpts
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+			return &httpError{http.StatusBadRequest, err}
+		}
+	}
+
+	repo, repoPath, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if repo, ok := repo.(vcs.RemoteManager); ok {
+		err := repo.Fetch(name, opt)
+		h.refsChanged(repoPath)
+		h.storageChanged(repoPath)
+		if err != nil {
+			return cloneOrUpdateError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("Fetch not yet implemented for %T", repo)}
+}
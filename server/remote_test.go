@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestServeRepoRemotes(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockRemotes{t: t, remotes: map[string]string{"origin": "https://example.com/foo.git"}}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	do := func(method, url string, body interface{}) *http.Response {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+url, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	remoteURL := testHandler.router.URLToRepoRemote(repoPath, "upstream").String()
+
+	// Without authentication configured, managing remotes is denied.
+	resp := do("PUT", remoteURL, "https://example.com/upstream.git")
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusForbidden; got != want {
+		t.Errorf("without auth: got status %d, want %d", got, want)
+	}
+
+	ops := map[string]string{}
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		ops[op] = op
+		return nil
+	}
+
+	// Invalid URLs are rejected.
+	resp = do("PUT", remoteURL, "ext::sh -c touch% /tmp/x")
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("invalid URL: got status %d, want %d", got, want)
+	}
+
+	resp = do("PUT", remoteURL, "https://example.com/upstream.git")
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNoContent; got != want {
+		t.Errorf("add: got status %d, want %d", got, want)
+	}
+	resp = do("PUT", remoteURL, "https://example.com/upstream.git")
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusConflict; got != want {
+		t.Errorf("add existing: got status %d, want %d", got, want)
+	}
+
+	resp = do("GET", testHandler.router.URLToRepoRemotes(repoPath).String(), nil)
+	var remotes []*vcs.Remote
+	if err := json.NewDecoder(resp.Body).Decode(&remotes); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	want := []*vcs.Remote{{Name: "origin", URL: "https://example.com/foo.git"}, {Name: "upstream", URL: "https://example.com/upstream.git"}}
+	if !reflect.DeepEqual(remotes, want) {
+		t.Errorf("got remotes %+v, want %+v", remotes, want)
+	}
+
+	resp = do("POST", testHandler.router.URLToRepoFetchRemote(repoPath, "upstream").String(), vcs.RemoteOpts{})
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNoContent; got != want {
+		t.Errorf("fetch: got status %d, want %d", got, want)
+	}
+	if rm.fetched != "upstream" {
+		t.Errorf("got fetched remote %q, want %q", rm.fetched, "upstream")
+	}
+
+	resp = do("DELETE", remoteURL, nil)
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNoContent; got != want {
+		t.Errorf("remove: got status %d, want %d", got, want)
+	}
+	resp = do("DELETE", remoteURL, nil)
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("remove nonexistent: got status %d, want %d", got, want)
+	}
+
+	if want := map[string]string{OpConfig: OpConfig, OpClone: OpClone}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("got operations %v, want %v", ops, want)
+	}
+}
+
+type mockRemotes struct {
+	t *testing.T
+
+	remotes map[string]string // name -> URL
+	fetched string
+}
+
+func (m *mockRemotes) AddRemote(name, url string) error {
+	if _, present := m.remotes[name]; present {
+		return vcs.ErrRemoteExists
+	}
+	m.remotes[name] = url
+	return nil
+}
+
+func (m *mockRemotes) RemoveRemote(name string) error {
+	if _, present := m.remotes[name]; !present {
+		return vcs.ErrRemoteNotFound
+	}
+	delete(m.remotes, name)
+	return nil
+}
+
+func (m *mockRemotes) ListRemotes() ([]*vcs.Remote, error) {
+	var remotes []*vcs.Remote
+	for _, name := range []string{"origin", "upstream"} {
+		if url, present := m.remotes[name]; present {
+			remotes = append(remotes, &vcs.Remote{Name: name, URL: url})
+		}
+	}
+	return remotes, nil
+}
+
+func (m *mockRemotes) Fetch(remote string, opt vcs.RemoteOpts) error {
+	if _, present := m.remotes[remote]; !present {
+		return vcs.ErrRemoteNotFound
+	}
+	m.fetched = remote
+	return nil
+}
+
+var _ vcs.RemoteManager = (*mockRemotes)(nil)
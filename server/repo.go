@@ -9,6 +9,7 @@ import (
 
 	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
@@ -19,11 +20,28 @@ func (h *Handler) serveRepo(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer done()
 
-	return writeJSON(w, struct {
+	return writeResponse(w, r, struct {
 		ImplementationType string
 	}{fmt.Sprintf("%T", repo)})
 }
 
+func (h *Handler) serveRepoExists(w http.ResponseWriter, r *http.Request) error {
+	repoPath, err := h.getRepoPath(r, "")
+	if err != nil {
+		return err
+	}
+
+	exists, err := h.Service.Exists(repoPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return vcsclient.ErrRepoNotExist
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
 func (h *Handler) serveRepoCreateOrUpdate(w http.ResponseWriter, r *http.Request) error {
 	var cloneInfo vcsclient.CloneInfo
 	if r.ContentLength > 0 {
@@ -31,6 +49,9 @@ func (h *Handler) serveRepoCreateOrUpdate(w http.ResponseWriter, r *http.Request
 			return err
 		}
 	}
+	if err := checkCloneInfo(&cloneInfo); err != nil {
+		return err
+	}
 
 	var cloned bool // whether the repo was newly cloned
 	repo, repoPath, _, err := h.getRepo(r)
@@ -44,6 +65,8 @@ func (h *Handler) serveRepoCreateOrUpdate(w http.ResponseWriter, r *http.Request
 	defer h.Service.Close(repoPath)
 
 	if cloned {
+		h.refsChanged(repoPath)
+		h.writeCommitGraph(repoPath)
 		w.WriteHeader(http.StatusCreated)
 		return nil
 	}
@@ -53,15 +76,97 @@ func (h *Handler) serveRepoCreateOrUpdate(w http.ResponseWriter, r *http.Request
 	}
 	if repo, ok := repo.(updateEverythinger); ok {
 		err := repo.UpdateEverything(cloneInfo.RemoteOpts)
+		h.refsChanged(repoPath)
+		h.storageChanged(repoPath)
 		if err != nil {
 			return cloneOrUpdateError(err)
 		}
+		h.writeCommitGraph(repoPath)
 
 		return nil
 	}
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Remote updates not yet implemented for %T", repo)}
 }
 
+func (h *Handler) serveRepoCloneAsync(w http.ResponseWriter, r *http.Request) error {
+	var cloneInfo vcsclient.CloneInfo
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&cloneInfo); err != nil {
+			return &httpError{http.StatusBadRequest, err}
+		}
+	}
+	if err := checkCloneInfo(&cloneInfo); err != nil {
+		return err
+	}
+
+	repoPath, err := h.getRepoPath(r, "")
+	if err != nil {
+		return err
+	}
+
+	cloner, ok := h.Service.(vcsstore.AsyncCloner)
+	if !ok {
+		return &httpError{http.StatusNotImplemented, fmt.Errorf("CloneAsync not yet implemented for %T", h.Service)}
+	}
+	job, err := cloner.CloneAsync(repoPath, &cloneInfo)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("location", h.router.URLToRepoCloneStatus(repoPath, job.ID).String())
+	return writeResponse(w, r, job)
+}
+
+func (h *Handler) serveRepoCloneStatus(w http.ResponseWriter, r *http.Request) error {
+	repoPath, err := h.getRepoPath(r, "")
+	if err != nil {
+		return err
+	}
+
+	cloner, ok := h.Service.(vcsstore.AsyncCloner)
+	if !ok {
+		return &httpError{http.StatusNotImplemented, fmt.Errorf("CloneJob not yet implemented for %T", h.Service)}
+	}
+	job, err := cloner.CloneJob(repoPath, mux.Vars(r)["JobID"])
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("cache-control", "no-cache, max-age=0")
+	return writeResponse(w, r, job)
+}
+
+func (h *Handler) serveRepoInit(w http.ResponseWriter, r *http.Request) error {
+	var initInfo vcsclient.InitInfo
+	if err := json.NewDecoder(r.Body).Decode(&initInfo); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	if initInfo.VCS != "git" {
+		return &httpError{http.StatusBadRequest, fmt.Errorf("creating empty repositories of VCS type %q is not supported", initInfo.VCS)}
+	}
+
+	repoPath, err := h.getRepoPath(r, "")
+	if err != nil {
+		return err
+	}
+
+	if err := h.Service.Init(repoPath, initInfo.VCS); err != nil {
+		return err
+	}
+	h.refsChanged(repoPath)
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// checkCloneInfo returns an HTTP 400 error if the clone options in
+// cloneInfo are invalid.
+func checkCloneInfo(cloneInfo *vcsclient.CloneInfo) error {
+	if cloneInfo.Depth < 0 {
+		return &httpError{http.StatusBadRequest, fmt.Errorf("invalid clone depth %d (must not be negative)", cloneInfo.Depth)}
+	}
+	return nil
+}
+
 func cloneOrUpdateError(err error) error {
 	if err != nil {
 		var c int
@@ -106,6 +211,10 @@ func (h *Handler) getRepoLabeled(r *http.Request, label string) (repo interface{
 		return nil, repoPath, nil, err
 	}
 
+	if label == "" {
+		setVCSType(r, repoVCSType(repo))
+	}
+
 	done = func() {
 		h.Service.Close(repoPath)
 	}
@@ -119,5 +228,8 @@ func (h *Handler) getRepoPath(r *http.Request, label string) (repoPath string, e
 	if repoPath == "" {
 		return "", &httpError{http.StatusBadRequest, errors.New("repoPath not found")}
 	}
-	return repoPath, err
+	if err := h.authenticate(r, repoPath); err != nil {
+		return "", err
+	}
+	return repoPath, nil
 }
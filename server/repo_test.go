@@ -2,11 +2,19 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
 	"sourcegraph.com/sourcegraph/vcsstore"
@@ -81,6 +89,42 @@ func TestServeRepo_DoesNotExist(t *testing.T) {
 	}
 }
 
+func TestServeRepoExists(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	for _, exists := range []bool{false, true} {
+		sm := &mockService{
+			t: t,
+
+			repoPath: repoPath,
+			open: func(repoPath string) (interface{}, error) {
+				t.Fatal("unexpectedly called Open")
+				panic("unreachable")
+			},
+			exists: func(repoPath string) (bool, error) {
+				return exists, nil
+			},
+		}
+		testHandler.Service = sm
+
+		resp, err := http.Head(server.URL + testHandler.router.URLToRepo(repoPath).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		want := http.StatusNotFound
+		if exists {
+			want = http.StatusOK
+		}
+		if got := resp.StatusCode; got != want {
+			t.Errorf("exists=%v: got code %d, want %d", exists, got, want)
+		}
+	}
+}
+
 func TestServeRepoCreateOrUpdate_CreateNew_noBody(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
@@ -174,6 +218,39 @@ func TestServeRepoCreateOrUpdate_CreateNew_withBody(t *testing.T) {
 	}
 }
 
+func TestServeRepoCreateOrUpdate_negativeDepth(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockService{
+		t: t,
+		open: func(repoPath string) (interface{}, error) {
+			t.Errorf("got Open called, want the clone options rejected first")
+			return nil, os.ErrNotExist
+		},
+		clone: func(repoPath string, opt *vcsclient.CloneInfo) (interface{}, error) {
+			t.Errorf("got Clone called, want the clone options rejected first")
+			return nil, nil
+		},
+	}
+
+	body, _ := json.Marshal(vcsclient.CloneInfo{VCS: "git", CloneURL: "https://example.com/a", Depth: -1})
+	for _, u := range []*url.URL{
+		testHandler.router.URLToRepo(repoPath),
+		testHandler.router.URLTo(vcsclient.RouteRepoCloneAsync, "RepoPath", repoPath),
+	} {
+		resp, err := http.Post(server.URL+u.String(), "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+			t.Errorf("%s: got code %d, want %d", u, got, want)
+		}
+	}
+}
+
 func TestServeRepoCreateOrUpdate_UpdateExisting_noBody(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
@@ -268,6 +345,125 @@ func (m *mockUpdateEverythinger) UpdateEverything(opt vcs.RemoteOpts) error {
 	return m.err
 }
 
+func TestServeRepoInit(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)}
+	testHandler.Service = vcsstore.NewService(conf)
+	testHandler.GitTransporter = NewGitTransporter(conf)
+
+	repoPath := "a.b/c"
+	baseURL, _ := url.Parse(server.URL)
+	repo_, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repo_.(interface {
+		vcs.Repository
+		vcsclient.RepositoryIniter
+	})
+
+	// Without authentication configured, repositories can't be
+	// created.
+	if err := repo.Init(&vcsclient.InitInfo{VCS: "git"}); !vcsclient.IsHTTPErrorCode(err, http.StatusForbidden) {
+		t.Errorf("Init without auth: got error %v, want HTTP 403", err)
+	}
+
+	var gotOp string
+	testHandler.AuthorizeRepo = func(ctx context.Context, token, repoPath, op string) error {
+		gotOp = op
+		return nil
+	}
+
+	if err := repo.Init(&vcsclient.InitInfo{VCS: "hg"}); !vcsclient.IsHTTPErrorCode(err, http.StatusBadRequest) {
+		t.Errorf("Init with unsupported VCS: got error %v, want HTTP 400", err)
+	}
+	if err := repo.Init(&vcsclient.InitInfo{VCS: "git"}); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	if gotOp != OpPush {
+		t.Errorf("got operation %q, want %q", gotOp, OpPush)
+	}
+	if err := repo.Init(&vcsclient.InitInfo{VCS: "git"}); err != vcsclient.ErrRepoExists {
+		t.Errorf("Init of existing repo: got error %v, want %v", err, vcsclient.ErrRepoExists)
+	}
+
+	// The new repository can be pushed to immediately.
+	workDir := filepath.Join(storageDir, "work")
+	cmd := exec.Command("sh", "-c", "git init -q \"$0\" && cd \"$0\" && git commit -q --allow-empty -m a && git push -q \"$1\" HEAD:refs/heads/master && git rev-parse HEAD", workDir, server.URL+"/"+repoPath+"/.git")
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git push failed: %s\n%s", err, out)
+	}
+	commitID := vcs.CommitID(strings.TrimSpace(string(out)))
+	if id, err := repo.ResolveBranch("master"); err != nil || id != commitID {
+		t.Errorf("ResolveBranch after push: got %q, %v, want %q", id, err, commitID)
+	}
+}
+
+func TestServeRepoCloneAsync(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)}
+	testHandler.Service = vcsstore.NewService(conf)
+
+	srcDir := filepath.Join(storageDir, "src")
+	cmd := exec.Command("sh", "-c", "git init -q \"$0\" && cd \"$0\" && git commit -q --allow-empty -m a && git rev-parse HEAD", srcDir)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("creating source repo failed: %s\n%s", err, out)
+	}
+	commitID := vcs.CommitID(strings.TrimSpace(string(out)))
+
+	repoPath := "a.b/c"
+	baseURL, _ := url.Parse(server.URL)
+	repo_, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repo_.(interface {
+		vcs.Repository
+		vcsclient.RepositoryAsyncCloner
+	})
+
+	h, err := repo.CloneAsync(&vcsclient.CloneInfo{VCS: "git", CloneURL: srcDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	job, err := h.Wait(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Wait: %s", err)
+	}
+	if job.ID != h.ID || job.State != vcsclient.CloneJobDone {
+		t.Errorf("got job %+v, want job %s done", job, h.ID)
+	}
+	if id, err := repo.ResolveBranch("master"); err != nil || id != commitID {
+		t.Errorf("ResolveBranch after clone: got %q, %v, want %q", id, err, commitID)
+	}
+
+	// Unknown jobs aren't found.
+	h.ID = "doesntexist"
+	if _, err := h.Poll(); err != vcsclient.ErrCloneJobNotExist {
+		t.Errorf("Poll of unknown job: got error %v, want %v", err, vcsclient.ErrCloneJobNotExist)
+	}
+}
+
 type mockServiceForExistingRepo struct {
 	t *testing.T
 
@@ -296,6 +492,18 @@ func (m *mockServiceForExistingRepo) Clone(repoPath string, opt *vcsclient.Clone
 	return m.repo, m.err
 }
 
+func (m *mockServiceForExistingRepo) Init(repoPath, vcsType string) error {
+	m.t.Errorf("mock: unexpectedly called Init for repo that exists (%s)", repoPath)
+	return vcsclient.ErrRepoExists
+}
+
+func (m *mockServiceForExistingRepo) Exists(repoPath string) (bool, error) {
+	if m.repoPath != "" && repoPath != m.repoPath {
+		m.t.Errorf("mock: got repoPath arg %q, want %q", repoPath, m.repoPath)
+	}
+	return true, nil
+}
+
 func (m *mockServiceForExistingRepo) Close(repoPath string) {}
 
 type mockService struct {
@@ -306,8 +514,10 @@ type mockService struct {
 	opt      vcsclient.CloneInfo
 
 	// mockable methods
-	open  func(repoPath string) (interface{}, error)
-	clone func(repoPath string, opt *vcsclient.CloneInfo) (interface{}, error)
+	open   func(repoPath string) (interface{}, error)
+	clone  func(repoPath string, opt *vcsclient.CloneInfo) (interface{}, error)
+	init   func(repoPath, vcsType string) error
+	exists func(repoPath string) (bool, error)
 }
 
 var _ vcsstore.Service = (*mockService)(nil)
@@ -329,6 +539,20 @@ func (m *mockService) Clone(repoPath string, opt *vcsclient.CloneInfo) (interfac
 	return m.clone(repoPath, opt)
 }
 
+func (m *mockService) Init(repoPath, vcsType string) error {
+	if m.repoPath != "" && repoPath != m.repoPath {
+		m.t.Errorf("mock: got repoPath arg %q, want %q", repoPath, m.repoPath)
+	}
+	return m.init(repoPath, vcsType)
+}
+
+func (m *mockService) Exists(repoPath string) (bool, error) {
+	if m.repoPath != "" && repoPath != m.repoPath {
+		m.t.Errorf("mock: got repoPath arg %q, want %q", repoPath, m.repoPath)
+	}
+	return m.exists(repoPath)
+}
+
 func (m *mockService) Close(repoPath string) {}
 
 func asJSON(v interface{}) string {
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sourcegraph/mux"
+)
+
+// RequestIDHeader is the HTTP header that carries a request's ID. If
+// a request has one, its ID is used; otherwise one is generated. The
+// ID is also set in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen is the maximum length of a request ID taken from a
+// request header. Longer (or non-printable) IDs are replaced.
+const maxRequestIDLen = 200
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request that ctx belongs
+// to, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID returns r with its request ID (from its
+// X-Request-ID header, or else newly generated) in its context, and
+// sets the ID in the response's header.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails
+	}
+	return hex.EncodeToString(b)
+}
+
+// discardLogger is used when no RequestLog is configured.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// requestLog returns log (or a logger that discards records, if log
+// is nil) with the request ID from ctx.
+func requestLog(log *slog.Logger, ctx context.Context) *slog.Logger {
+	if log == nil {
+		return discardLogger
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		log = log.With("request_id", id)
+	}
+	return log
+}
+
+// logRequest logs a record of the request r to h.RequestLog.
+func (h *Handler) logRequest(r *http.Request, code int, d time.Duration, err error) {
+	log := requestLog(h.RequestLog, r.Context())
+	if log == discardLogger {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("uri", r.URL.RequestURI()),
+	}
+	if rt := mux.CurrentRoute(r); rt != nil {
+		attrs = append(attrs, slog.String("route", rt.GetName()))
+	}
+	if repoPath := mux.Vars(r)["RepoPath"]; repoPath != "" {
+		attrs = append(attrs, slog.String("repo", repoPath))
+	}
+	attrs = append(attrs, slog.Int("status", code), slog.Duration("duration", d))
+
+	level := slog.LevelInfo
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		if code >= 500 {
+			level = slog.LevelError
+		} else {
+			level = slog.LevelWarn
+		}
+	}
+	log.LogAttrs(r.Context(), level, "request", attrs...)
+}
+
+// stderrLogger is an io.Writer that logs each line written to it (such
+// as a subprocess's stderr).
+type stderrLogger struct {
+	log  *slog.Logger
+	msg  string
+	args []any
+
+	partial []byte // unterminated line
+}
+
+func (l *stderrLogger) Write(p []byte) (int, error) {
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i == -1 {
+			break
+		}
+		l.logLine(l.partial[:i])
+		l.partial = l.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close logs the last line, if it is unterminated.
+func (l *stderrLogger) Close() error {
+	l.logLine(l.partial)
+	l.partial = nil
+	return nil
+}
+
+func (l *stderrLogger) logLine(line []byte) {
+	if line = bytes.TrimSpace(line); len(line) > 0 {
+		l.log.Warn(l.msg, append(l.args, "stderr", string(line))...)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestRequestLog(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	var buf bytes.Buffer
+	testHandler.RequestLog = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo: &mockFileSystem{
+			t:  t,
+			at: commitID,
+			fs: mapFS(map[string]string{"myfile": "mydata"}),
+		},
+	}
+
+	get := func(path, requestID string) (string, map[string]interface{}) {
+		buf.Reset()
+		req, err := http.NewRequest("GET", server.URL+testHandler.router.URLToRepoTreeEntry(repoPath, commitID, path).String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("request log record %q: %s", buf.Bytes(), err)
+		}
+		return resp.Header.Get(RequestIDHeader), record
+	}
+
+	// The client's request ID is used.
+	id, record := get("myfile", "my-request")
+	if id != "my-request" {
+		t.Errorf("got response request ID %q, want %q", id, "my-request")
+	}
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "request",
+		"request_id": "my-request",
+		"method":     "GET",
+		"route":      vcsclient.RouteRepoTreeEntry,
+		"repo":       repoPath,
+		"status":     float64(http.StatusOK),
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("got request log %s %v, want %v", k, record[k], v)
+		}
+	}
+	if _, present := record["duration"]; !present {
+		t.Error("request log has no duration")
+	}
+
+	// A request ID is generated, and errors are logged.
+	id, record = get("doesntexist", "")
+	if !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(id) {
+		t.Errorf("got generated request ID %q, want 16 hex digits", id)
+	}
+	if record["request_id"] != id {
+		t.Errorf("got request log request_id %v, want %q", record["request_id"], id)
+	}
+	if record["status"] != float64(http.StatusNotFound) || record["level"] != "WARN" || record["error"] == nil {
+		t.Errorf("got request log %v, want a warning with status 404 and the error", record)
+	}
+
+	// Invalid request IDs are replaced.
+	if id, _ := get("myfile", "a\tb"); id == "a\tb" || id == "" {
+		t.Errorf("got response request ID %q, want a generated one", id)
+	}
+}
+
+func TestStderrLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := &stderrLogger{log: slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}})), msg: "git subprocess", args: []any{"service", "upload-pack"}}
+	l.Write([]byte("error: a\n\nerr"))
+	l.Write([]byte("or: b"))
+	l.Close()
+
+	want := `level=WARN msg="git subprocess" service=upload-pack stderr="error: a"
+level=WARN msg="git subprocess" service=upload-pack stderr="error: b"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got log\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/sourcegraph/mux"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func (h *Handler) serveRepoBranch(w http.ResponseWriter, r *http.Request) error {
@@ -21,12 +26,14 @@ func (h *Handler) serveRepoBranch(w http.ResponseWriter, r *http.Request) error
 		ResolveBranch(string) (vcs.CommitID, error)
 	}
 	if repo, ok := repo.(resolveBranch); ok {
-		commitID, err := repo.ResolveBranch(v["Branch"])
+		commitID, err := h.resolveRef(w, repoPath, "branch:"+v["Branch"], func() (vcs.CommitID, error) {
+			return repo.ResolveBranch(v["Branch"])
+		})
 		if err != nil {
 			return err
 		}
 
-		setShortCache(w)
+		h.setShortCache(w, r)
 		http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, commitID).String(), http.StatusFound)
 		return nil
 	}
@@ -47,17 +54,28 @@ func (h *Handler) serveRepoRevision(w http.ResponseWriter, r *http.Request) erro
 		ResolveRevision(string) (vcs.CommitID, error)
 	}
 	if repo, ok := repo.(resolveRevision); ok {
-		commitID, err := repo.ResolveRevision(v["RevSpec"])
+		spec := v["RevSpec"]
+		var commitID vcs.CommitID
+		if commitIDIsCanon(spec) || strings.Contains(spec, "@{") {
+			// Specs with reflog or time suffixes (such as
+			// "master@{yesterday}") may resolve differently without
+			// any change to the refs, so they are never cached.
+			commitID, err = repo.ResolveRevision(spec)
+		} else {
+			commitID, err = h.resolveRef(w, repoPath, "rev:"+spec, func() (vcs.CommitID, error) {
+				return repo.ResolveRevision(spec)
+			})
+		}
 		if err != nil {
 			return err
 		}
 
 		var statusCode int
-		if commitIDIsCanon(v["RevSpec"]) {
-			setLongCache(w)
+		if commitIDIsCanon(spec) {
+			h.setLongCache(w, r)
 			statusCode = http.StatusMovedPermanently
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 			statusCode = http.StatusFound
 		}
 		http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, commitID).String(), statusCode)
@@ -67,6 +85,47 @@ func (h *Handler) serveRepoRevision(w http.ResponseWriter, r *http.Request) erro
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("ResolveRevision not yet implemented for %T", repo)}
 }
 
+// maxBatchRevisions is the maximum number of revision specifiers that
+// may be resolved in a single request to serveRepoRevisions.
+const maxBatchRevisions = 1000
+
+func (h *Handler) serveRepoRevisions(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var specs []string
+	if err := json.NewDecoder(r.Body).Decode(&specs); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	if len(specs) > maxBatchRevisions {
+		return &httpError{http.StatusBadRequest, fmt.Errorf("too many revision specifiers (%d > %d)", len(specs), maxBatchRevisions)}
+	}
+
+	type resolveRevision interface {
+		ResolveRevision(string) (vcs.CommitID, error)
+	}
+	if repo, ok := repo.(resolveRevision); ok {
+		revs := make(map[string]*vcsclient.ResolvedRevision, len(specs))
+		for _, spec := range specs {
+			if _, seen := revs[spec]; seen {
+				continue
+			}
+			commitID, err := repo.ResolveRevision(spec)
+			if err != nil {
+				revs[spec] = &vcsclient.ResolvedRevision{Error: err.Error()}
+			} else {
+				revs[spec] = &vcsclient.ResolvedRevision{CommitID: commitID}
+			}
+		}
+		return writeResponse(w, r, revs)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("ResolveRevision not yet implemented for %T", repo)}
+}
+
 func (h *Handler) serveRepoTag(w http.ResponseWriter, r *http.Request) error {
 	v := mux.Vars(r)
 
@@ -80,15 +139,87 @@ func (h *Handler) serveRepoTag(w http.ResponseWriter, r *http.Request) error {
 		ResolveTag(string) (vcs.CommitID, error)
 	}
 	if repo, ok := repo.(resolveTag); ok {
-		commitID, err := repo.ResolveTag(v["Tag"])
+		commitID, err := h.resolveRef(w, repoPath, "tag:"+v["Tag"], func() (vcs.CommitID, error) {
+			return repo.ResolveTag(v["Tag"])
+		})
 		if err != nil {
 			return err
 		}
 
-		setShortCache(w)
+		h.setShortCache(w, r)
 		http.Redirect(w, r, h.router.URLToRepoCommit(repoPath, commitID).String(), http.StatusFound)
 		return nil
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("ResolveTag not yet implemented for %T", repo)}
 }
+
+// resolveRef resolves a revision that depends on the repository's
+// refs (such as a branch name) by calling resolve. If h.Service is a
+// vcsstore.RefsCache, the result is memoized under key until the
+// repository's refs change, and the refs generation response header
+// is set.
+func (h *Handler) resolveRef(w http.ResponseWriter, repoPath, key string, resolve func() (vcs.CommitID, error)) (vcs.CommitID, error) {
+	cache, ok := h.Service.(vcsstore.RefsCache)
+	if !ok {
+		return resolve()
+	}
+
+	gen := cache.RefsGeneration(repoPath)
+	w.Header().Set(vcsclient.RefsGenerationHeader, strconv.FormatUint(gen, 10))
+	if commitID, ok := cache.ResolvedRevision(repoPath, key); ok {
+		return commitID, nil
+	}
+	commitID, err := resolve()
+	if err != nil {
+		return "", err
+	}
+	cache.SetResolvedRevision(repoPath, gen, key, commitID)
+	return commitID, nil
+}
+
+// setRefsGeneration sets the refs generation response header if
+// h.Service is a vcsstore.RefsCache.
+func (h *Handler) setRefsGeneration(w http.ResponseWriter, repoPath string) {
+	if cache, ok := h.Service.(vcsstore.RefsCache); ok {
+		w.Header().Set(vcsclient.RefsGenerationHeader, strconv.FormatUint(cache.RefsGeneration(repoPath), 10))
+	}
+}
+
+// refsChanged records that the repository's refs may have changed,
+// invalidating cached data derived from them.
+func (h *Handler) refsChanged(repoPath string) {
+	if cache, ok := h.Service.(vcsstore.RefsCache); ok {
+		cache.BumpRefsGeneration(repoPath)
+	}
+	if h.UpdateServerInfo {
+		h.updateServerInfo(repoPath)
+	}
+}
+
+// updateServerInfo updates the files that list the refs and packs of
+// the repository at repoPath for dumb HTTP clients. Errors are
+// logged, not returned, because they don't affect the result of the
+// request that changed the refs.
+func (h *Handler) updateServerInfo(repoPath string) {
+	repo, err := h.Service.Open(repoPath)
+	if err != nil {
+		h.Log.Printf("Updating server info: opening repo %s failed: %s.", repoPath, err)
+		return
+	}
+	defer h.Service.Close(repoPath)
+
+	if repo, ok := repo.(vcs.ServerInfoUpdater); ok {
+		if err := repo.UpdateServerInfo(); err != nil {
+			h.Log.Printf("Updating server info for repo %s failed: %s.", repoPath, err)
+		}
+	}
+}
+
+// storageChanged records that the repository's size may have changed
+// (if h.Service limits the storage of its repositories).
+func (h *Handler) storageChanged(repoPath string) {
+	if rec, ok := h.Service.(vcsstore.StorageRecorder); ok {
+		rec.RecordRepositorySize(repoPath)
+	}
+}
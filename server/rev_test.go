@@ -1,10 +1,21 @@
 package server
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/vcsstore"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 )
 
 func TestServeRepoBranch(t *testing.T) {
@@ -39,6 +50,98 @@ func TestServeRepoBranch(t *testing.T) {
 	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, "abcd"))
 }
 
+// TestServeRepoBranch_afterPush tests that a branch resolution cached
+// by the service is refreshed after a push to the branch.
+func TestServeRepoBranch_afterPush(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	testHandler.Service = vcsstore.NewService(conf)
+	testHandler.GitTransporter = NewGitTransporter(conf)
+
+	repoPath := "a.b/c"
+	workDir := filepath.Join(storageDir, "work")
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run(storageDir, "init", "--bare", filepath.Join(storageDir, repoPath))
+	run(storageDir, "init", workDir)
+	push := func(msg string) vcs.CommitID {
+		run(workDir, "commit", "--allow-empty", "-m", msg)
+		run(workDir, "push", server.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master")
+		return vcs.CommitID(run(workDir, "rev-parse", "HEAD"))
+	}
+	resolve := func() *http.Response {
+		resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLToRepoBranch(repoPath, "master").String())
+		if err != nil && !isIgnoredRedirectErr(err) {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	commitID1 := push("a")
+	resp := resolve()
+	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, commitID1))
+	gen1 := resp.Header.Get(vcsclient.RefsGenerationHeader)
+	if gen1 == "" {
+		t.Fatal("got no refs generation header")
+	}
+
+	commitID2 := push("b")
+	resp = resolve()
+	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, commitID2))
+	if gen2 := resp.Header.Get(vcsclient.RefsGenerationHeader); gen2 == gen1 {
+		t.Errorf("got refs generation %s after push, want it changed", gen2)
+	}
+}
+
+// TestServeRepoBranch_notFound tests that clients can tell that a
+// branch doesn't exist, even when the server is not in debug mode.
+func TestServeRepoBranch_notFound(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockResolveBranch{
+		t:    t,
+		name: "mybranch",
+		err:  vcs.ErrBranchNotFound,
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Debug = false
+
+	baseURL, _ := url.Parse(server.URL)
+	repo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.ResolveBranch("mybranch")
+	if err != vcs.ErrBranchNotFound {
+		t.Errorf("got error %v, want %v", err, vcs.ErrBranchNotFound)
+	}
+	if !vcsclient.IsNotFound(err) {
+		t.Errorf("got IsNotFound(%v) == false, want true", err)
+	}
+}
+
 func TestServeRepoRevision(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
@@ -71,6 +174,90 @@ func TestServeRepoRevision(t *testing.T) {
 	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, "abcd"))
 }
 
+// TestServeRepoRevision_reflogNotCached tests that revision specifiers
+// with reflog or time suffixes are never resolved from the cache.
+func TestServeRepoRevision_reflogNotCached(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockResolveRevision{
+		t:        t,
+		revSpec:  "master@{1.day.ago}",
+		commitID: "abcd",
+	}
+	testHandler.Service = struct {
+		*mockServiceForExistingRepo
+		vcsstore.RefsCache
+	}{
+		&mockServiceForExistingRepo{t: t, repoPath: repoPath, repo: rm},
+		staleRefsCache{},
+	}
+
+	resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLToRepoRevision(repoPath, "master@{1.day.ago}").String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !rm.called {
+		t.Errorf("!called")
+	}
+	testRedirectedTo(t, resp, http.StatusFound, testHandler.router.URLToRepoCommit(repoPath, "abcd"))
+}
+
+// staleRefsCache is a vcsstore.RefsCache that has every revision
+// cached (resolved to "stale").
+type staleRefsCache struct{}
+
+func (staleRefsCache) RefsGeneration(repoPath string) uint64 { return 1 }
+func (staleRefsCache) BumpRefsGeneration(repoPath string)    {}
+func (staleRefsCache) ResolvedRevision(repoPath, key string) (vcs.CommitID, bool) {
+	return "stale", true
+}
+func (staleRefsCache) SetResolvedRevision(repoPath string, gen uint64, key string, commitID vcs.CommitID) {
+}
+
+func TestServeRepoRevision_emptyRepo(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := exec.Command("git", "init", "--bare")
+	c.Dir = dir
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("Command %v failed: %s. Output was:\n\n%s", c.Args, err, out)
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	resp, err := ignoreRedirectsClient.Get(server.URL + testHandler.router.URLToRepoRevision(repoPath, "HEAD").String())
+	if err != nil && !isIgnoredRedirectErr(err) {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+	if err := vcsclient.CheckResponse(resp, false); !vcsclient.IsEmpty(err) {
+		t.Errorf("got error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+}
+
 func TestServeRepoTag(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
@@ -165,3 +352,64 @@ func (m *mockResolveRevision) ResolveRevision(revSpec string) (vcs.CommitID, err
 	m.called = true
 	return m.commitID, m.err
 }
+
+func TestServeRepoRevisions(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockResolveRevisions{
+		commitIDs: map[string]vcs.CommitID{"master": "abcd", "v1": "ef01"},
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	body := strings.NewReader(`["master", "v1", "nonexistent", "master"]`)
+	resp, err := http.Post(server.URL+testHandler.router.URLToRepoRevisions(repoPath).String(), "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got status %d, want %d", got, want)
+	}
+
+	var revs map[string]*vcsclient.ResolvedRevision
+	if err := json.NewDecoder(resp.Body).Decode(&revs); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*vcsclient.ResolvedRevision{
+		"master":      {CommitID: "abcd"},
+		"v1":          {CommitID: "ef01"},
+		"nonexistent": {Error: vcs.ErrRevisionNotFound.Error()},
+	}
+	if !reflect.DeepEqual(revs, want) {
+		t.Errorf("got revs %+v, want %+v", revs, want)
+	}
+
+	// Each distinct spec is resolved only once.
+	if rm.calls != 3 {
+		t.Errorf("got %d ResolveRevision calls, want 3", rm.calls)
+	}
+}
+
+type mockResolveRevisions struct {
+	commitIDs map[string]vcs.CommitID
+	calls     int
+}
+
+func (m *mockResolveRevisions) ResolveRevision(revSpec string) (vcs.CommitID, error) {
+	m.calls++
+	if commitID, ok := m.commitIDs[revSpec]; ok {
+		return commitID, nil
+	}
+	return "", vcs.ErrRevisionNotFound
+}
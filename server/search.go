@@ -19,7 +19,7 @@ func (h *Handler) serveRepoSearch(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	rev, canon, err := getCommitID(r)
+	rev, canon, err := getCommitID(r, repo)
 	if err != nil {
 		return err
 	}
@@ -45,12 +45,12 @@ func (h *Handler) serveRepoSearch(w http.ResponseWriter, r *http.Request) error
 		}
 
 		if canon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 		}
 
-		return writeJSON(w, res)
+		return writeResponse(w, r, res)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Search not yet implemented for %T", repo)}
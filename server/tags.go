@@ -8,7 +8,7 @@ import (
 )
 
 func (h *Handler) serveRepoTags(w http.ResponseWriter, r *http.Request) error {
-	repo, _, done, err := h.getRepo(r)
+	repo, repoPath, done, err := h.getRepo(r)
 	if err != nil {
 		return err
 	}
@@ -18,12 +18,13 @@ func (h *Handler) serveRepoTags(w http.ResponseWriter, r *http.Request) error {
 		Tags() ([]*vcs.Tag, error)
 	}
 	if repo, ok := repo.(tags); ok {
+		h.setRefsGeneration(w, repoPath)
 		tags, err := repo.Tags()
 		if err != nil {
 			return err
 		}
 
-		return writeJSON(w, tags)
+		return writeResponse(w, r, tags)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("Tags not yet implemented for %T", repo)}
@@ -1,9 +1,15 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
+	pathpkg "path"
+	"strings"
 
 	"github.com/sourcegraph/mux"
 	"golang.org/x/tools/godoc/vfs"
@@ -20,7 +26,7 @@ func (h *Handler) serveRepoTreeEntry(w http.ResponseWriter, r *http.Request) err
 	}
 	defer done()
 
-	commitID, canon, err := getCommitID(r)
+	commitID, canon, err := getCommitID(r, repo)
 	if err != nil {
 		return err
 	}
@@ -33,6 +39,9 @@ func (h *Handler) serveRepoTreeEntry(w http.ResponseWriter, r *http.Request) err
 		if err != nil {
 			return err
 		}
+		if c, ok := fs.(io.Closer); ok {
+			defer c.Close() // stop its `git cat-file` processes
+		}
 
 		// Check for extended range options (GetFileOptions).
 		var fopt vcsclient.GetFileOptions
@@ -40,21 +49,167 @@ func (h *Handler) serveRepoTreeEntry(w http.ResponseWriter, r *http.Request) err
 			return err
 		}
 
-		fr, err := vcsclient.GetFileWithOptions(fs, v["Path"], fopt)
+		// Omit large files' contents unless the client explicitly
+		// asked for them.
+		fr, err := vcsclient.GetFileWithMaxContentsSize(fs, v["Path"], fopt, h.MaxContentsSize)
 		if err != nil {
 			if os.IsNotExist(err) {
 				return &httpError{http.StatusNotFound, err}
 			}
+			if err == pathpkg.ErrBadPattern {
+				return &httpError{http.StatusBadRequest, err}
+			}
+			return err
+		}
+
+		if fr.Type == vcsclient.FileEntry {
+			fr.ContentType, err = detectContentType(fs, v["Path"], fr)
+			if err != nil {
+				return err
+			}
+		}
+
+		if fopt.LastCommit {
+			if err := setLastCommits(repo, commitID, v["Path"], fr.TreeEntry); err != nil {
+				return err
+			}
+		}
+
+		if canon {
+			h.setLongCache(w, r)
+		} else {
+			h.setShortCache(w, r)
+		}
+		if canon && checkLastModified(w, r, fr.ModTime.Time()) {
+			return nil
+		}
+		return writeResponse(w, r, fr)
+	}
+
+	return &httpError{http.StatusNotImplemented, fmt.Errorf("FileSystem not yet implemented for %T", repo)}
+}
+
+// setLastCommits sets the LastCommit field of the tree entry e (at
+// path) and of each of its sub-entries, using a single call to
+// LastCommits.
+func setLastCommits(repo interface{}, at vcs.CommitID, path string, e *vcsclient.TreeEntry) error {
+	lc, ok := repo.(vcs.LastCommitter)
+	if !ok {
+		return &httpError{http.StatusNotImplemented, fmt.Errorf("LastCommits not yet implemented for %T", repo)}
+	}
+
+	var paths []string
+	var entries []*vcsclient.TreeEntry
+	var add func(path string, e *vcsclient.TreeEntry)
+	add = func(path string, e *vcsclient.TreeEntry) {
+		paths = append(paths, path)
+		entries = append(entries, e)
+		for _, sub := range e.Entries {
+			add(pathpkg.Join(path, sub.Name), sub)
+		}
+	}
+	add(path, e)
+
+	commits, err := lc.LastCommits(at, paths)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		e.LastCommit = commits[i]
+	}
+	return nil
+}
+
+// maxBatchStatPaths is the maximum number of paths that may be
+// statted in a single request to serveRepoStatMulti.
+const maxBatchStatPaths = 1000
+
+func (h *Handler) serveRepoStatMulti(w http.ResponseWriter, r *http.Request) error {
+	repo, _, done, err := h.getRepo(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	commitID, canon, err := getCommitID(r, repo)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	if err := json.NewDecoder(r.Body).Decode(&paths); err != nil {
+		return &httpError{http.StatusBadRequest, err}
+	}
+	if len(paths) > maxBatchStatPaths {
+		return &httpError{http.StatusBadRequest, fmt.Errorf("too many paths (%d > %d)", len(paths), maxBatchStatPaths)}
+	}
+
+	type fileSystem interface {
+		FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+	}
+	if repo, ok := repo.(fileSystem); ok {
+		fs, err := repo.FileSystem(commitID)
+		if err != nil {
 			return err
 		}
+		if c, ok := fs.(io.Closer); ok {
+			defer c.Close() // stop its `git cat-file` processes
+		}
+
+		fis, errs := vcsclient.StatMulti(fs, paths)
+		stats := make([]*vcsclient.FileStat, len(paths))
+		for i := range paths {
+			stats[i] = vcsclient.NewFileStat(fis[i], errs[i])
+		}
 
 		if canon {
-			setLongCache(w)
+			h.setLongCache(w, r)
 		} else {
-			setShortCache(w)
+			h.setShortCache(w, r)
 		}
-		return writeJSON(w, fr)
+		return writeResponse(w, r, stats)
 	}
 
 	return &httpError{http.StatusNotImplemented, fmt.Errorf("FileSystem not yet implemented for %T", repo)}
 }
+
+// sniffLen is the number of bytes that http.DetectContentType
+// considers.
+const sniffLen = 512
+
+// detectContentType returns the MIME type of the file at path, using
+// its first bytes and its filename extension. Text files are always
+// reported as "text/plain; charset=utf-8" so that clients don't
+// render them as (e.g.) HTML.
+func detectContentType(fs vfs.FileSystem, path string, fr *vcsclient.FileWithRange) (string, error) {
+	// Only read the beginning of the file if the returned contents
+	// don't already include it.
+	head := fr.Contents
+	if fr.StartByte != 0 || (len(head) < sniffLen && int64(len(head)) < fr.Size) {
+		f, err := fs.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		head, err = ioutil.ReadAll(io.LimitReader(f, sniffLen))
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+
+	ct := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(ct, "text/"):
+		return "text/plain; charset=utf-8", nil
+	case ct == "application/octet-stream":
+		// The contents didn't match a known signature, so fall back to
+		// the extension (if it's not a text type).
+		if extCT := mime.TypeByExtension(pathpkg.Ext(path)); extCT != "" && !strings.HasPrefix(extCT, "text/") {
+			return extCT, nil
+		}
+	}
+	return ct, nil
+}
@@ -2,8 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -13,6 +17,8 @@ import (
 	"golang.org/x/tools/godoc/vfs"
 	"golang.org/x/tools/godoc/vfs/mapfs"
 	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/vcsstore"
 	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
 	"sourcegraph.com/sqs/pbtypes"
 )
@@ -58,11 +64,14 @@ func TestServeRepoTreeEntry_File(t *testing.T) {
 	}
 
 	wantEntry := &vcsclient.TreeEntry{
-		Name:     "myfile",
-		Type:     vcsclient.FileEntry,
-		Size:     6,
-		ModTime:  pbtypes.NewTimestamp(time.Time{}),
-		Contents: []byte("mydata"),
+		Name:        "myfile",
+		Type:        vcsclient.FileEntry,
+		Mode:        0444,
+		ContentType: "text/plain; charset=utf-8",
+		Size:        6,
+		ModTime:     pbtypes.NewTimestamp(time.Time{}),
+		Contents:    []byte("mydata"),
+		Encoding:    vcsclient.UTF8Encoding,
 	}
 
 	if !reflect.DeepEqual(e, wantEntry) {
@@ -116,17 +125,20 @@ func TestServeRepoTreeEntry_Dir(t *testing.T) {
 	wantEntry := &vcsclient.TreeEntry{
 		Name:    ".",
 		Type:    vcsclient.DirEntry,
+		Mode:    os.ModeDir | 0755,
 		ModTime: pbtypes.NewTimestamp(time.Time{}),
 		Entries: []*vcsclient.TreeEntry{
 			{
 				Name:    "myfile",
 				Type:    vcsclient.FileEntry,
+				Mode:    0444,
 				Size:    6,
 				ModTime: pbtypes.NewTimestamp(time.Time{}),
 			},
 			{
 				Name:    "mydir",
 				Type:    vcsclient.DirEntry,
+				Mode:    os.ModeDir | 0755,
 				ModTime: pbtypes.NewTimestamp(time.Time{}),
 			},
 		},
@@ -145,6 +157,77 @@ func TestServeRepoTreeEntry_Dir(t *testing.T) {
 	}
 }
 
+func TestServeRepoTreeEntry_DirGlob(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	rm := &mockFileSystem{
+		t:  t,
+		at: "abcd",
+		fs: mapFS(map[string]string{
+			"a.go":       "package a",
+			"b.go":       "package a",
+			"README":     "readme",
+			"a_test.txt": "",
+			"sub/c.go":   "package sub",
+		}),
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, "abcd", ".").String() + "?glob=*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+
+	var e *vcsclient.TreeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range e.Entries {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"a.go", "b.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got entries %v, want %v", names, want)
+	}
+}
+
+func TestServeRepoTreeEntry_DirBadGlob(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo: &mockFileSystem{
+			t:  t,
+			at: "abcd",
+			fs: mapFS(map[string]string{"a.go": "package a"}),
+		},
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, "abcd", ".").String() + "?glob=%5B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+}
+
 func TestServeRepoTreeEntry_FileWithOptions(t *testing.T) {
 	setupHandlerTest()
 	defer teardownHandlerTest()
@@ -187,11 +270,14 @@ func TestServeRepoTreeEntry_FileWithOptions(t *testing.T) {
 
 	want := &vcsclient.FileWithRange{
 		TreeEntry: &vcsclient.TreeEntry{
-			Name:     "myfile",
-			Type:     vcsclient.FileEntry,
-			Size:     6,
-			ModTime:  pbtypes.NewTimestamp(time.Time{}),
-			Contents: []byte("da"),
+			Name:        "myfile",
+			Type:        vcsclient.FileEntry,
+			Mode:        0444,
+			ContentType: "text/plain; charset=utf-8",
+			Size:        6,
+			ModTime:     pbtypes.NewTimestamp(time.Time{}),
+			Contents:    []byte("da"),
+			Encoding:    vcsclient.UTF8Encoding,
 		},
 		FileRange: vcsclient.FileRange{
 			StartByte: 2, EndByte: 4,
@@ -209,6 +295,587 @@ func TestServeRepoTreeEntry_FileWithOptions(t *testing.T) {
 	}
 }
 
+func TestServeRepoTreeEntry_FileWithLineRange(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+
+	repoPath := "a.b/c"
+	rm := &mockFileSystem{
+		t:  t,
+		at: commitID,
+		fs: mapFS(map[string]string{"myfile": "line1\nline2\nline3\nline4\n"}),
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "myfile").String() + "?StartLine=2&EndLine=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+
+	var f *vcsclient.FileWithRange
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "line2\nline3"; string(f.Contents) != want {
+		t.Errorf("got contents %q, want %q", f.Contents, want)
+	}
+	wantRange := vcsclient.FileRange{
+		StartLine: 2, EndLine: 3,
+		StartByte: 6, EndByte: 17,
+	}
+	if f.FileRange != wantRange {
+		t.Errorf("got file range %+v, want %+v", f.FileRange, wantRange)
+	}
+}
+
+func TestServeRepoTreeEntry_LFSPointer(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+
+	repoPath := "a.b/c"
+	rm := &mockFileSystem{
+		t:  t,
+		at: commitID,
+		fs: mapFS(map[string]string{"big.bin": pointer}),
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "big.bin").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+
+	var e *vcsclient.TreeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	wantEntry := &vcsclient.TreeEntry{
+		Name:        "big.bin",
+		Type:        vcsclient.FileEntry,
+		Mode:        0444,
+		ContentType: "text/plain; charset=utf-8",
+		Size:        int64(len(pointer)),
+		ModTime:     pbtypes.NewTimestamp(time.Time{}),
+		Contents:    []byte(pointer),
+		Encoding:    vcsclient.UTF8Encoding,
+		LFS:         true,
+		LFSOID:      "sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393",
+		LFSSize:     12345,
+	}
+
+	if !reflect.DeepEqual(e, wantEntry) {
+		t.Errorf("got tree entry %+v, want %+v", e, wantEntry)
+	}
+}
+
+func TestServeRepoTreeEntry_Encoding(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+
+	repoPath := "a.b/c"
+	rm := &mockFileSystem{
+		t:  t,
+		at: commitID,
+		fs: mapFS(map[string]string{
+			"text":   "mydata",
+			"binary": "\x00\xff\xfe",
+			"large":  strings.Repeat("x", 11),
+		}),
+	}
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.MaxContentsSize = 10
+
+	tests := map[string]struct {
+		query string
+
+		wantJSON  map[string]interface{} // expected JSON fields
+		wantEntry *vcsclient.TreeEntry   // subset of fields of the decoded entry
+	}{
+		"text": {
+			wantJSON:  map[string]interface{}{"encoding": "utf-8", "contents": "mydata"},
+			wantEntry: &vcsclient.TreeEntry{Encoding: vcsclient.UTF8Encoding, Contents: []byte("mydata")},
+		},
+		"binary": {
+			wantJSON:  map[string]interface{}{"encoding": "base64", "contents": "AP/+"},
+			wantEntry: &vcsclient.TreeEntry{Encoding: vcsclient.Base64Encoding, Contents: []byte("\x00\xff\xfe")},
+		},
+		"large": {
+			wantJSON:  map[string]interface{}{"contents_omitted": true},
+			wantEntry: &vcsclient.TreeEntry{ContentsOmitted: true},
+		},
+		"large?EntireFile=true": {
+			wantJSON:  map[string]interface{}{"encoding": "utf-8", "contents": strings.Repeat("x", 11)},
+			wantEntry: &vcsclient.TreeEntry{Encoding: vcsclient.UTF8Encoding, Contents: []byte(strings.Repeat("x", 11))},
+		},
+	}
+	for label, test := range tests {
+		u := testHandler.router.URLToRepoTreeEntry(repoPath, commitID, strings.SplitN(label, "?", 2)[0])
+		if i := strings.Index(label, "?"); i != -1 {
+			u.RawQuery = label[i+1:]
+		}
+		resp, err := http.Get(server.URL + u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("%s: got status code %d, want %d", label, got, want)
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			t.Fatal(err)
+		}
+		for k, want := range test.wantJSON {
+			if got := fields[k]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: got JSON field %q = %v, want %v", label, k, got, want)
+			}
+		}
+		if _, ok := test.wantJSON["contents"]; !ok {
+			if _, present := fields["contents"]; present {
+				t.Errorf("%s: got JSON contents field, want it omitted", label)
+			}
+		}
+
+		var e *vcsclient.TreeEntry
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Encoding != test.wantEntry.Encoding || !reflect.DeepEqual(e.Contents, test.wantEntry.Contents) || e.ContentsOmitted != test.wantEntry.ContentsOmitted {
+			t.Errorf("%s: got tree entry %+v, want %+v", label, e, test.wantEntry)
+		}
+	}
+}
+
+func TestServeRepoTreeEntry_ExecutableFile(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmds := []string{
+		"git init",
+		"echo a > f && chmod -x f",
+		"echo b > x && chmod +x x",
+		"git add f x",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git commit -m foo --author='a <a@a.com>'",
+	}
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Command %q failed: %s. Output was:\n\n%s", cmd, err, out)
+		}
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitID, err := repo.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	tests := map[string]os.FileMode{"f": 0644, "x": 0755}
+	for path, wantMode := range tests {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, path).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("%s: got status code %d, want %d", path, got, want)
+		}
+
+		var e *vcsclient.TreeEntry
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Mode != wantMode {
+			t.Errorf("%s: got mode %s, want %s", path, e.Mode, wantMode)
+		}
+	}
+}
+
+func TestServeRepoTreeEntry_Symlink(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmds := []string{
+		"git init",
+		"mkdir d && echo a > d/f",
+		"ln -s d/f link",
+		"git add d/f link",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git commit -m foo --author='a <a@a.com>'",
+	}
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Command %q failed: %s. Output was:\n\n%s", cmd, err, out)
+		}
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitID, err := repo.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "link").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got status code %d, want %d", got, want)
+	}
+
+	var e *vcsclient.TreeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != vcsclient.SymlinkEntry {
+		t.Errorf("got type %s, want %s", e.Type, vcsclient.SymlinkEntry)
+	}
+	if want := "d/f"; e.SymlinkTarget != want {
+		t.Errorf("got symlink target %q, want %q", e.SymlinkTarget, want)
+	}
+}
+
+func TestServeRepoTreeEntry_LastCommit(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmds := []string{
+		"git init",
+		"mkdir -p d/e && echo a > a && echo b > d/b && echo c > d/e/c",
+		"git add -A",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git commit -m c1 --author='a <a@a.com>'",
+		"echo b2 > d/b",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git commit -am c2 --author='a <a@a.com>'",
+		"echo a3 > a",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com git commit -am c3 --author='a <a@a.com>'",
+	}
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Command %q failed: %s. Output was:\n\n%s", cmd, err, out)
+		}
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitID, err := repo.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, "d").String() + "?lastCommit=1&RecurseSingleSubfolder=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got status code %d, want %d", got, want)
+	}
+
+	var e *vcsclient.TreeEntry
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	subjects := map[string]string{}
+	var add func(path string, e *vcsclient.TreeEntry)
+	add = func(path string, e *vcsclient.TreeEntry) {
+		if e.LastCommit == nil {
+			t.Errorf("%s: no last commit", path)
+		} else {
+			subjects[path] = e.LastCommit.Subject
+		}
+		for _, sub := range e.Entries {
+			add(path+"/"+sub.Name, sub)
+		}
+	}
+	add("d", e)
+	want := map[string]string{"d": "c2", "d/b": "c2", "d/e": "c1"}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("got last commit subjects %v, want %v", subjects, want)
+	}
+
+	// Without the option, no last commits are returned.
+	resp2, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, ".").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	var e2 *vcsclient.TreeEntry
+	if err := json.NewDecoder(resp2.Body).Decode(&e2); err != nil {
+		t.Fatal(err)
+	}
+	if e2.LastCommit != nil || e2.Entries[0].LastCommit != nil {
+		t.Errorf("got last commits without the lastCommit option")
+	}
+}
+
+func TestServeRepoTreeEntry_ContentType(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 100)
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo: &mockFileSystem{
+			t:  t,
+			at: commitID,
+			fs: mapFS(map[string]string{
+				"a.png":         png,
+				"a.html":        "<html><body>hello</body></html>",
+				"a.txt":         "hello",
+				"a.avif":        strings.Repeat("\x01\x02", 10),
+				"a.unknown-ext": strings.Repeat("\x01\x02", 10),
+			}),
+		},
+	}
+
+	tests := []struct {
+		path  string
+		query string
+
+		wantContentType string
+	}{
+		{"a.png", "", "image/png"},
+		{"a.png", "?StartByte=10&EndByte=20", "image/png"},
+		{"a.html", "", "text/plain; charset=utf-8"},
+		{"a.txt", "", "text/plain; charset=utf-8"},
+		{"a.avif", "", "image/avif"},
+		{"a.unknown-ext", "", "application/octet-stream"},
+	}
+	for _, test := range tests {
+		resp, err := http.Get(server.URL + testHandler.router.URLToRepoTreeEntry(repoPath, commitID, test.path).String() + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e *vcsclient.TreeEntry
+		err = json.NewDecoder(resp.Body).Decode(&e)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.ContentType != test.wantContentType {
+			t.Errorf("%s%s: got content type %q, want %q", test.path, test.query, e.ContentType, test.wantContentType)
+		}
+	}
+}
+
+func TestServeRepoStatMulti(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	commitID := vcs.CommitID(strings.Repeat("a", 40))
+
+	repoPath := "a.b/c"
+	rm := &mockFileSystem{
+		t:  t,
+		at: commitID,
+		fs: mapFS(map[string]string{"myfile": "mydata", "mydir/f": ""}),
+	}
+	sm := &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     rm,
+	}
+	testHandler.Service = sm
+
+	body := strings.NewReader(`["myfile", "nonexistent", "mydir"]`)
+	resp, err := http.Post(server.URL+testHandler.router.URLToRepoStatMulti(repoPath, commitID).String(), "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got status code %d, want %d", got, want)
+	}
+
+	if !sm.opened {
+		t.Errorf("!opened")
+	}
+	if !rm.called {
+		t.Errorf("!called")
+	}
+
+	var stats []*vcsclient.FileStat
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	want := []*vcsclient.FileStat{
+		{Entry: &vcsclient.TreeEntry{Name: "myfile", Type: vcsclient.FileEntry, Mode: 0444, Size: 6, ModTime: pbtypes.NewTimestamp(time.Time{})}},
+		{NotExist: true},
+		{Entry: &vcsclient.TreeEntry{Name: "mydir", Type: vcsclient.DirEntry, Mode: os.ModeDir | 0755, ModTime: pbtypes.NewTimestamp(time.Time{})}},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got stats %+v, want %+v", stats, want)
+	}
+
+	// used canonical commit ID, so should be long-cached
+	if cc := resp.Header.Get("cache-control"); cc != longCacheControl {
+		t.Errorf("got cache-control %q, want %q", cc, longCacheControl)
+	}
+}
+
+func TestServeRepoStatMulti_git(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	dir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cmds := []string{
+		"git init",
+		"mkdir d && echo a > d/f",
+		"echo b > x && chmod +x x",
+		"ln -s d/f link",
+		"git add d/f x link",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	for _, cmd := range cmds {
+		c := exec.Command("bash", "-c", cmd)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Command %q failed: %s. Output was:\n\n%s", cmd, err, out)
+		}
+	}
+	repo, err := gitcmd.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitID, err := repo.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoPath := "a.b/c"
+	testHandler.Service = &mockServiceForExistingRepo{
+		t:        t,
+		repoPath: repoPath,
+		repo:     repo,
+	}
+
+	baseURL, _ := url.Parse(server.URL)
+	clientRepo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := clientRepo.(interface {
+		FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+	}).FileSystem(commitID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"d", "d/f", "x", "link", "nonexistent", "d/nonexistent"}
+	fis, errs := vcsclient.StatMulti(fs, paths)
+	wantModes := map[string]os.FileMode{"d": os.ModeDir, "d/f": 0644, "x": 0755, "link": 0644}
+	wantModTime := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i, path := range paths {
+		wantMode, exists := wantModes[path]
+		if !exists {
+			if !os.IsNotExist(errs[i]) {
+				t.Errorf("%s: got error %v, want os.IsNotExist", path, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Errorf("%s: got error %v", path, errs[i])
+			continue
+		}
+		if got := fis[i].Mode(); got != wantMode {
+			t.Errorf("%s: got mode %s, want %s", path, got, wantMode)
+		}
+		if got := fis[i].ModTime(); !got.Equal(wantModTime) {
+			t.Errorf("%s: got mod time %s, want %s", path, got, wantModTime)
+		}
+	}
+}
+
 type mockFileSystem struct {
 	t *testing.T
 
@@ -245,3 +912,73 @@ func (fs prefixVFS) Stat(path string) (os.FileInfo, error)  { return fs.FileSyst
 func (fs prefixVFS) ReadDir(path string) ([]os.FileInfo, error) {
 	return fs.FileSystem.ReadDir("/" + path)
 }
+
+// TestServeRepoTreeEntry_afterPush tests that reading a branch's tree
+// reflects a push to the branch, even though the service caches the
+// branch's resolution.
+func TestServeRepoTreeEntry_afterPush(t *testing.T) {
+	setupHandlerTest()
+	defer teardownHandlerTest()
+
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	conf := &vcsstore.Config{StorageDir: storageDir}
+	testHandler.Service = vcsstore.NewService(conf)
+	testHandler.GitTransporter = NewGitTransporter(conf)
+
+	repoPath := "a.b/c"
+	workDir := filepath.Join(storageDir, "work")
+	run := func(dir string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+	run(storageDir, "init", "--bare", filepath.Join(storageDir, repoPath))
+	run(storageDir, "init", workDir)
+	push := func(data string) {
+		if err := ioutil.WriteFile(filepath.Join(workDir, "f"), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		run(workDir, "add", "f")
+		run(workDir, "commit", "-m", data)
+		run(workDir, "push", "-q", server.URL+"/"+repoPath+"/.git", "HEAD:refs/heads/master")
+	}
+
+	baseURL, _ := url.Parse(server.URL)
+	repo, err := vcsclient.New(baseURL, nil).Repository(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBranchFile := func() string {
+		commitID, err := repo.ResolveBranch("master")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, err := repo.(interface {
+			FileSystem(vcs.CommitID) (vfs.FileSystem, error)
+		}).FileSystem(commitID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := vfs.ReadFile(fs, "/f")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	push("a")
+	if got, want := readBranchFile(), "a"; got != want {
+		t.Errorf("got file contents %q, want %q", got, want)
+	}
+	push("b")
+	if got, want := readBranchFile(), "b"; got != want {
+		t.Errorf("after push: got file contents %q, want %q", got, want)
+	}
+}
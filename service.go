@@ -1,10 +1,14 @@
 package vcsstore
 
 import (
+	"container/list"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -20,13 +24,26 @@ type Service interface {
 	// repository is returned.
 	Open(repoPath string) (interface{}, error)
 
-	// Close closes the repository.
+	// Close closes the repository. It must be called exactly once
+	// for each successful call to Open or Clone.
 	Close(repoPath string)
 
 	// Clone clones the repository if a clone doesn't yet exist locally.
 	// Otherwise, it opens the repository. If no errors occur, the repository is
 	// returned.
 	Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interface{}, error)
+
+	// Exists reports whether the repository has been cloned (or
+	// initialized) and is a valid repository. Unlike Open, it doesn't
+	// open the repository or record a user of it, so Close must not
+	// be called.
+	Exists(repoPath string) (bool, error)
+
+	// Init creates an empty bare repository of the given VCS type
+	// (currently only "git" is supported), so that it can be pushed
+	// to. If the repository already exists, vcsclient.ErrRepoExists is
+	// returned.
+	Init(repoPath, vcsType string) error
 }
 
 type Config struct {
@@ -34,15 +51,139 @@ type Config struct {
 	// working directory is used.
 	StorageDir string
 
+	// HashedStorage stores each repository in a directory named by
+	// the SHA-256 hash of its repo path (see HashRepositoryPath)
+	// instead of in a directory at its repo path. Unlike repo paths,
+	// hashes never collide after path cleaning and never exceed
+	// filesystem name length limits. Each repository's repo path is
+	// recorded in a file next to its directory, so that
+	// ListRepositories can find it.
+	//
+	// Repositories stored using one layout are not visible using the
+	// other, so this must not be changed for an existing StorageDir.
+	HashedStorage bool
+
 	Log *log.Logger
 
 	DebugLog *log.Logger
+
+	// CommitCountCacheSize is the maximum number of commit counts (the
+	// total returned by a repository's Commits method) to cache. If
+	// 0, a default size is used; if negative, commit counts are not
+	// cached.
+	CommitCountCacheSize int
+
+	// DiffCacheSize is the maximum total size in bytes of the diffs
+	// between full commit IDs to cache. If 0, a default size (64 MB)
+	// is used; if negative, diffs are not cached.
+	DiffCacheSize int64
+
+	// MaxOpenRepos is the maximum number of repositories to keep
+	// open. Repositories stay open after their last user closes them
+	// (so that later requests can reuse them), and the least recently
+	// used idle ones are closed when there are more than
+	// MaxOpenRepos. Repositories in use are never closed, so more may
+	// be open at once. If 0, a default limit is used; if negative,
+	// repositories are closed as soon as they are no longer in use.
+	MaxOpenRepos int
+
+	// RateLimit is the number of requests per second that each client
+	// (identified by its bearer token, or else its IP address) may
+	// make to the HTTP server, on average. If 0, requests are not
+	// rate-limited.
+	RateLimit float64
+
+	// RateLimitBurst is the maximum number of requests that each
+	// client may make at once (above RateLimit). If 0, it defaults to
+	// 1 or RateLimit, whichever is greater.
+	RateLimitBurst int
+
+	// MaxStorageBytes is the maximum total size in bytes of the
+	// repositories in StorageDir. A Clone that would exceed it fails
+	// with vcsclient.ErrStorageQuotaExceeded (unless
+	// EvictForStorage is set). If 0, storage is unlimited.
+	MaxStorageBytes int64
+
+	// AllowPartialClone lets git clients make partial clones (such
+	// as with `git clone --filter=blob:none`) over the smart HTTP
+	// transport. The objects that a partial clone omits are fetched
+	// on demand, so clients may fetch any object reachable from a ref
+	// by its ID.
+	AllowPartialClone bool
+
+	// AllowDumbHTTP lets git clients that only speak the "dumb" HTTP
+	// transfer protocol fetch from repositories (in addition to the
+	// smart protocol).
+	AllowDumbHTTP bool
+
+	// TmpDirMaxAge is how long a temporary directory in StorageDir
+	// (such as one left behind by a clone that was interrupted by a
+	// crash) must go unmodified before it is removed, if it isn't in
+	// use. Temporary directories are swept when the service is
+	// created and every TmpDirSweepInterval. If 0,
+	// defaultTmpDirMaxAge is used; if negative, they are never
+	// removed.
+	TmpDirMaxAge time.Duration
+
+	// TmpDirSweepInterval is how often to remove stale temporary
+	// directories (see TmpDirMaxAge). If 0, they are only removed
+	// when the service is created.
+	TmpDirSweepInterval time.Duration
+
+	// EvictForStorage makes a Clone that would exceed
+	// MaxStorageBytes delete the least recently accessed repositories
+	// (that are not in use) to make room for the new one, instead of
+	// failing.
+	EvictForStorage bool
+
+	// CacheMaxAges are the Cache-Control max-ages of the HTTP
+	// server's responses. Zero max-ages use the defaults (a year for
+	// responses that never change and 7 seconds for others).
+	CacheMaxAges CacheMaxAges
+
+	// RouteCacheMaxAges overrides CacheMaxAges for the routes with
+	// the given names (such as vcsclient.RouteRepoTreeEntry). Zero
+	// max-ages in an override use those in CacheMaxAges.
+	RouteCacheMaxAges map[string]CacheMaxAges
+
+	// RequestLog, if set, receives a structured record of each HTTP
+	// request (with its method, route, repo path, status, duration,
+	// and request ID) and of the errors of git subprocesses run for
+	// it. Use a logger with a slog.JSONHandler for JSON logs.
+	RequestLog *slog.Logger
+
+	// CORSAllowedOrigins are the origins (such as
+	// "https://example.com", or "*" for all) from which browsers may
+	// make cross-origin requests to the HTTP server. If empty,
+	// cross-origin requests are not allowed.
+	CORSAllowedOrigins []string
+
+	// CORSAllowedMethods and CORSAllowedHeaders are the HTTP methods
+	// and request headers that cross-origin requests may use. If
+	// empty, server.DefaultCORSAllowedMethods and
+	// server.DefaultCORSAllowedHeaders are used.
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+}
+
+// CacheMaxAges are the Cache-Control max-ages of HTTP responses.
+type CacheMaxAges struct {
+	// Long is the max-age of responses that never change, such as
+	// those for a tree entry at a full commit ID.
+	Long time.Duration
+
+	// Short is the max-age of responses that may change, such as
+	// those for a branch or a revision resolved from a branch name.
+	Short time.Duration
 }
 
 // CloneDir validates vcsType and cloneURL. If they are valid, cloneDir returns
 // the local directory that the repository should be cloned to (which it may
 // already exist at). If invalid, cloneDir returns a non-nil error.
 func (c *Config) CloneDir(repoPath string) (string, error) {
+	if c.HashedStorage {
+		return filepath.Join(c.StorageDir, filepath.FromSlash(HashRepositoryPath(repoPath))), nil
+	}
 	return filepath.Join(c.StorageDir, EncodeRepositoryPath(repoPath)), nil
 }
 
@@ -54,31 +195,119 @@ func NewService(c *Config) Service {
 			DebugLog:   log.New(ioutil.Discard, "", 0),
 		}
 	}
-	return &service{
-		Config:    *c,
-		repoMu:    make(map[repoKey]*sync.RWMutex),
-		repos:     map[repoKey]interface{}{},
-		repoUsers: map[repoKey]int{},
+	cacheSize := c.CommitCountCacheSize
+	if cacheSize == 0 {
+		cacheSize = defaultCommitCountCacheSize
+	}
+	diffCacheSize := c.DiffCacheSize
+	if diffCacheSize == 0 {
+		diffCacheSize = defaultDiffCacheSize
+	}
+	maxOpenRepos := c.MaxOpenRepos
+	if maxOpenRepos == 0 {
+		maxOpenRepos = defaultMaxOpenRepos
+	} else if maxOpenRepos < 0 {
+		maxOpenRepos = 0
+	}
+	s := &service{
+		Config:           *c,
+		maxOpenRepos:     maxOpenRepos,
+		repoMu:           make(map[repoKey]*sync.RWMutex),
+		repos:            map[repoKey]interface{}{},
+		repoUsers:        map[repoKey]int{},
+		idleRepos:        list.New(),
+		idleRepoElems:    map[repoKey]*list.Element{},
+		lastAccess:       map[repoKey]time.Time{},
+		repoSizes:        map[repoKey]int64{},
+		tmpDirs:          map[string]struct{}{},
+		cloneJobs:        map[string]*cloneJob{},
+		activeCloneJobs:  map[repoKey]*cloneJob{},
+		commitCountCache: newCommitCountCache(cacheSize),
+		diffCache:        newDiffCache(diffCacheSize),
+		refsCache:        newRefsCache(),
+	}
+	if s.TmpDirMaxAge >= 0 {
+		s.removeStaleTmpDirs()
+		if s.TmpDirSweepInterval > 0 {
+			go s.sweepTmpDirs()
+		}
 	}
+	return s
 }
 
+// rename is os.Rename (it is a var so tests can simulate failures).
+var rename = os.Rename
+
+// defaultMaxOpenRepos is the maximum number of open repositories if
+// Config.MaxOpenRepos is 0.
+const defaultMaxOpenRepos = 100
+
 type service struct {
 	Config
 
+	// maxOpenRepos is the effective Config.MaxOpenRepos.
+	maxOpenRepos int
+
 	// repoMu prevents more than one goroutine from simultaneously
 	// cloning the same repository.
 	repoMu map[repoKey]*sync.RWMutex
 
 	// repo and repoUsers holds all repos that have been opened and not yet
-	// closed. When the count goes to 0, the repo can be freed. It is
-	// protected by repoMuMu.
+	// closed. When the count goes to 0, the repo becomes idle and can
+	// be evicted. It is protected by repoMuMu.
 	repos     map[repoKey]interface{}
 	repoUsers map[repoKey]int
 
-	// repoMuMu synchronizes access to repoMu, repo, and repoUsers.
+	// idleRepos is the LRU list of open repos with no users (of
+	// repoKey, most recently used first). idleRepoElems maps each
+	// idle repo to its element in idleRepos. They are protected by
+	// repoMuMu.
+	idleRepos     *list.List
+	idleRepoElems map[repoKey]*list.Element
+
+	// lastAccess is the time each repo was last opened since the
+	// service started (only if MaxStorageBytes is set, since it is
+	// only used to choose repos to evict). It is protected by
+	// repoMuMu.
+	lastAccess map[repoKey]time.Time
+
+	// repoMuMu synchronizes access to repoMu, repo, repoUsers,
+	// idleRepos, and lastAccess.
 	repoMuMu sync.RWMutex
+
+	// quotaMu serializes the storage quota checks (and evictions) of
+	// concurrent clones, and protects repoSizes.
+	quotaMu sync.Mutex
+
+	// repoSizes is the recorded size of each stored repo, so that
+	// quota checks don't have to measure every repo (see
+	// StorageRecorder). It is only used if MaxStorageBytes is set.
+	repoSizes map[repoKey]int64
+
+	// tmpDirs is the set of temporary directories that clones are
+	// using, which must not be swept. It is protected by tmpDirsMu.
+	tmpDirs   map[string]struct{}
+	tmpDirsMu sync.Mutex
+
+	// cloneJobs holds the clone jobs started by CloneAsync (by ID),
+	// and activeCloneJobs holds the unfinished ones (by repo). They
+	// are protected by cloneJobsMu.
+	cloneJobs       map[string]*cloneJob
+	activeCloneJobs map[repoKey]*cloneJob
+	cloneJobsMu     sync.Mutex
+
+	*commitCountCache
+	*diffCache
+	*refsCache
 }
 
+var (
+	_ CommitCountCache = (*service)(nil)
+	_ DiffCache        = (*service)(nil)
+	_ RefsCache        = (*service)(nil)
+	_ AsyncCloner      = (*service)(nil)
+)
+
 type repoKey struct {
 	cloneDir string
 }
@@ -91,6 +320,9 @@ func (s *service) Open(repoPath string) (interface{}, error) {
 	return s.open(cloneDir)
 }
 
+// open opens the repository at cloneDir (which must be the clone dir
+// of a repo path, not the repo path itself, because repos are keyed
+// by their clone dir) and records a new user of it.
 func (s *service) open(cloneDir string) (interface{}, error) {
 	key := repoKey{cloneDir}
 	vcsType, err := vcsTypeFromDir(cloneDir)
@@ -102,6 +334,7 @@ func (s *service) open(cloneDir string) (interface{}, error) {
 	// yet closed) the repo. Use that instance if so.
 	s.repoMuMu.Lock()
 	if repo := s.repos[key]; repo != nil {
+		s.acquireRepo(key)
 		s.repoMuMu.Unlock()
 		return repo, nil
 	}
@@ -118,36 +351,114 @@ func (s *service) open(cloneDir string) (interface{}, error) {
 	}
 
 	s.repoMuMu.Lock()
-	defer s.repoMuMu.Unlock()
-	s.repoUsers[key]++
-	if repo := s.repos[key]; repo != nil {
-		// Another goroutine raced us to open this repo. Use ours, not
-		// theirs, so that there is only 1 instance of this repo in
+	s.acquireRepo(key)
+	if other := s.repos[key]; other != nil {
+		// Another goroutine raced us to open this repo. Use theirs,
+		// not ours, so that there is only 1 instance of this repo in
 		// use at a time.
-		return repo, nil
+		s.repoMuMu.Unlock()
+		closeRepo(repo)
+		return other, nil
 	}
 	// Otherwise, tell other goroutines to use the repo we just opened.
 	s.repos[key] = repo
+	evicted := s.evictIdleRepos()
+	s.repoMuMu.Unlock()
 
+	closeRepos(evicted)
 	return repo, nil
 }
 
+// acquireRepo records a new user of the repo, which is no longer
+// idle. The caller must hold s.repoMuMu.
+func (s *service) acquireRepo(key repoKey) {
+	s.repoUsers[key]++
+	if s.MaxStorageBytes > 0 {
+		s.lastAccess[key] = time.Now()
+	}
+	if e, ok := s.idleRepoElems[key]; ok {
+		s.idleRepos.Remove(e)
+		delete(s.idleRepoElems, key)
+	}
+}
+
+// evictIdleRepos removes the least recently used idle repos until
+// no more than s.maxOpenRepos are open (or no idle repos remain),
+// and returns them. The caller must hold s.repoMuMu and should call
+// closeRepos on the result after releasing it.
+func (s *service) evictIdleRepos() (evicted []interface{}) {
+	for e := s.idleRepos.Back(); e != nil && len(s.repos) > s.maxOpenRepos; {
+		prev := e.Prev()
+		key := e.Value.(repoKey)
+
+		// Skip repos whose clone lock is held, so that eviction
+		// can't race a Clone of the same repo.
+		mu := s.repoMu[key]
+		if mu != nil && !mu.TryLock() {
+			e = prev
+			continue
+		}
+		evicted = append(evicted, s.repos[key])
+		delete(s.repos, key)
+		s.idleRepos.Remove(e)
+		delete(s.idleRepoElems, key)
+		if mu != nil {
+			mu.Unlock()
+		}
+		e = prev
+	}
+	openRepos.Set(float64(len(s.repos)))
+	return evicted
+}
+
+// closeRepos closes repos (those that implement io.Closer).
+func closeRepos(repos []interface{}) {
+	for _, repo := range repos {
+		closeRepo(repo)
+	}
+}
+
+func closeRepo(repo interface{}) {
+	if c, ok := repo.(io.Closer); ok {
+		c.Close()
+	}
+}
+
 func (s *service) Close(repoPath string) {
 	cloneDir, err := s.CloneDir(repoPath)
 	if err != nil {
 		panic(err)
 	}
 	s.repoMuMu.Lock()
-	defer s.repoMuMu.Unlock()
 	key := repoKey{cloneDir}
+	if s.repoUsers[key] <= 0 {
+		// Don't let the count go negative, or the repo would never
+		// become idle.
+		s.repoMuMu.Unlock()
+		s.Log.Printf("Close(%s): repository has no users (Close called more times than Open or Clone).", repoPath)
+		return
+	}
 	s.repoUsers[key]--
+	var evicted []interface{}
 	if s.repoUsers[key] == 0 {
 		delete(s.repoUsers, key)
-		delete(s.repos, key)
+		if _, open := s.repos[key]; open {
+			s.idleRepoElems[key] = s.idleRepos.PushFront(key)
+			evicted = s.evictIdleRepos()
+		}
 	}
+	s.repoMuMu.Unlock()
+
+	closeRepos(evicted)
 }
 
 func (s *service) Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interface{}, error) {
+	return s.clone(repoPath, cloneInfo, nil)
+}
+
+// clone implements Clone. If job is non-nil, it is updated as the
+// clone starts and progresses.
+func (s *service) clone(repoPath string, cloneInfo *vcsclient.CloneInfo, job *cloneJob) (interface{}, error) {
 	cloneDir, err := s.CloneDir(repoPath)
 	if err != nil {
 		return nil, err
@@ -155,7 +466,7 @@ func (s *service) Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interf
 
 	// See if the clone directory exists and return immediately (without
 	// locking) if so.
-	if r, err := s.open(repoPath); !os.IsNotExist(err) {
+	if r, err := s.open(cloneDir); !os.IsNotExist(err) {
 		if err == nil {
 			s.debugLogf("Clone(%s): repository already exists at %s", repoPath, cloneDir)
 		} else {
@@ -178,6 +489,9 @@ func (s *service) Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interf
 		}
 		return r, err
 	}
+	if job != nil {
+		job.setState(vcsclient.CloneJobCloning)
+	}
 
 	start := time.Now()
 	msg := fmt.Sprintf("%s to %s", repoPath, cloneDir)
@@ -195,22 +509,54 @@ func (s *service) Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interf
 		return nil, err
 	}
 
-	cloneTmpDir, err := ioutil.TempDir(parentDir, "_tmp_"+filepath.Base(cloneDir)+"-")
+	cloneTmpDir, err := ioutil.TempDir(parentDir, tmpDirPrefix+filepath.Base(cloneDir)+"-")
 	if err != nil {
 		return nil, err
 	}
-	s.debugLogf("Clone(%s, %s): cloning to temporary sibling dir %s", repoPath, cloneTmpDir)
-	defer os.RemoveAll(cloneTmpDir)
-
-	cloneOpt := vcs.CloneOpt{Bare: true, Mirror: true, RemoteOpts: cloneInfo.RemoteOpts}
+	s.debugLogf("Clone(%s, %s): cloning to temporary sibling dir %s", repoPath, cloneInfo.CloneURL, cloneTmpDir)
+	s.acquireTmpDir(cloneTmpDir)
+	defer s.releaseTmpDir(cloneTmpDir)
+
+	// A shallow or single-branch clone can't be a mirror, because
+	// mirroring fetches every ref.
+	cloneOpt := vcs.CloneOpt{
+		Bare:       true,
+		Mirror:     cloneInfo.Depth == 0 && cloneInfo.Branch == "",
+		Depth:      cloneInfo.Depth,
+		Branch:     cloneInfo.Branch,
+		RemoteOpts: cloneInfo.RemoteOpts,
+	}
+	if job != nil {
+		cloneOpt.Progress = job
+	}
 	_, err = vcs.Clone(cloneInfo.VCS, cloneInfo.CloneURL, cloneTmpDir, cloneOpt)
+	observeClone(cloneInfo.VCS, err, time.Since(start))
 	if err != nil {
 		return nil, err
 	}
 	s.debugLogf("Clone(%s, %s): cloned to temporary sibling dir %s; now renaming to intended clone dir %s", cloneInfo.VCS, cloneInfo.CloneURL, cloneTmpDir, cloneDir)
 
-	if err := os.Rename(cloneTmpDir, cloneDir); err != nil {
-		s.debugLogf("Clone(%s, %s): Rename(%s -> %s) failed: %s", cloneInfo.VCS, cloneInfo.CloneURL, cloneTmpDir, cloneDir)
+	var size int64
+	if s.MaxStorageBytes > 0 {
+		// Hold the quota lock until the clone is in place, so that
+		// concurrent clones can't both claim the same free space.
+		s.quotaMu.Lock()
+		defer s.quotaMu.Unlock()
+		size, err = s.makeRoomFor(cloneTmpDir)
+		if err != nil {
+			s.Log.Printf("Not cloning %s: %s.", msg, err)
+			return nil, err
+		}
+	}
+
+	if err := rename(cloneTmpDir, cloneDir); err != nil {
+		s.debugLogf("Clone(%s, %s): Rename(%s -> %s) failed: %s", cloneInfo.VCS, cloneInfo.CloneURL, cloneTmpDir, cloneDir, err)
+		return nil, fmt.Errorf("moving clone of %s from temporary dir %s to %s failed: %s", repoPath, cloneTmpDir, cloneDir, err)
+	}
+	if s.MaxStorageBytes > 0 {
+		s.repoSizes[repoKey{cloneDir}] = size
+	}
+	if err := s.recordRepositoryPath(repoPath, cloneDir); err != nil {
 		return nil, err
 	}
 
@@ -221,6 +567,98 @@ func (s *service) Clone(repoPath string, cloneInfo *vcsclient.CloneInfo) (interf
 	return s.open(cloneDir)
 }
 
+func (s *service) Exists(repoPath string) (bool, error) {
+	cloneDir, err := s.CloneDir(repoPath)
+	if err != nil {
+		return false, err
+	}
+
+	// A repo that's open must exist.
+	s.repoMuMu.Lock()
+	open := s.repos[repoKey{cloneDir}] != nil
+	s.repoMuMu.Unlock()
+	if open {
+		return true, nil
+	}
+
+	vcsType, err := vcsTypeFromDir(cloneDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if vcsType != "git" {
+		return true, nil
+	}
+
+	// The marker files that vcsTypeFromDir looks for don't guarantee
+	// that the dir is a valid git repository.
+	gitDir := cloneDir
+	if fi, err := os.Stat(filepath.Join(cloneDir, ".git")); err == nil && fi.Mode().IsDir() {
+		gitDir = filepath.Join(cloneDir, ".git")
+	}
+	cmd := exec.Command("git", "rev-parse", "--resolve-git-dir", gitDir)
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *service) Init(repoPath, vcsType string) error {
+	if vcsType != "git" {
+		return fmt.Errorf("creating empty repositories of VCS type %q is not supported", vcsType)
+	}
+
+	cloneDir, err := s.CloneDir(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// Take the clone lock so we can't race a Clone (or another Init)
+	// of the same repository.
+	mu := s.Mutex(repoKey{cloneDir})
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := os.Stat(cloneDir); err == nil {
+		return vcsclient.ErrRepoExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Initialize the repository in a temporary sibling directory and
+	// rename it, as Clone does, so that a partially initialized
+	// repository is never visible.
+	parentDir := filepath.Dir(cloneDir)
+	if err := os.MkdirAll(parentDir, 0700); err != nil {
+		return err
+	}
+	initTmpDir, err := ioutil.TempDir(parentDir, tmpDirPrefix+filepath.Base(cloneDir)+"-")
+	if err != nil {
+		return err
+	}
+	s.acquireTmpDir(initTmpDir)
+	defer s.releaseTmpDir(initTmpDir)
+
+	cmd := exec.Command("git", "init", "--bare", "--quiet", initTmpDir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec `git init --bare` failed: %s. Output was:\n\n%s", err, out)
+	}
+	s.debugLogf("Init(%s, %s): initialized temporary sibling dir %s; now renaming to intended clone dir %s", repoPath, vcsType, initTmpDir, cloneDir)
+
+	if err := rename(initTmpDir, cloneDir); err != nil {
+		return fmt.Errorf("moving new repository %s from temporary dir %s to %s failed: %s", repoPath, initTmpDir, cloneDir, err)
+	}
+	if err := s.recordRepositoryPath(repoPath, cloneDir); err != nil {
+		return err
+	}
+	s.Log.Printf("Initialized empty %s repository %s at %s.", vcsType, repoPath, cloneDir)
+	return nil
+}
+
 func (s *service) Mutex(key repoKey) *sync.RWMutex {
 	s.repoMuMu.Lock()
 	defer s.repoMuMu.Unlock()
@@ -238,6 +676,19 @@ func isLowercaseLetter(s string) bool {
 	}) == -1
 }
 
+func isLowercaseHex(s string) bool {
+	return strings.IndexFunc(s, func(c rune) bool {
+		return !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f'))
+	}) == -1
+}
+
+// logf logs to Config.Log, if set.
+func (s *service) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log.Printf(format, args...)
+	}
+}
+
 func (s *service) debugLogf(format string, args ...interface{}) {
 	if s.DebugLog != nil {
 		s.DebugLog.Printf(format, args...)
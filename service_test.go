@@ -0,0 +1,640 @@
+package vcsstore
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	_ "sourcegraph.com/sourcegraph/go-vcs/vcs/gitcmd"
+	"sourcegraph.com/sourcegraph/vcsstore/vcsclient"
+)
+
+func TestService_evictIdleRepos(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	for _, repoPath := range []string{"a", "b", "c"} {
+		if out, err := exec.Command("git", "init", "--bare", filepath.Join(storageDir, repoPath)).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %s\n%s", err, out)
+		}
+	}
+
+	s := NewService(&Config{StorageDir: storageDir, MaxOpenRepos: 2}).(*service)
+	open := func(repoPath string) interface{} {
+		repo, err := s.Open(repoPath)
+		if err != nil {
+			t.Fatalf("Open(%q): %s", repoPath, err)
+		}
+		return repo
+	}
+	isOpen := func(repoPath string) bool {
+		s.repoMuMu.Lock()
+		defer s.repoMuMu.Unlock()
+		_, ok := s.repos[repoKey{filepath.Join(storageDir, repoPath)}]
+		return ok
+	}
+
+	// Idle repos stay open and are reused.
+	a := open("a")
+	s.Close("a")
+	if !isOpen("a") {
+		t.Error("got a closed, want idle repo a to stay open")
+	}
+	if a2 := open("a"); a2 != a {
+		t.Error("got a new instance of a, want the idle instance reused")
+	}
+	s.Close("a")
+
+	// The least recently used idle repo is evicted.
+	open("b")
+	s.Close("b")
+	open("a")
+	s.Close("a")
+	open("c")
+	s.Close("c")
+	if isOpen("b") {
+		t.Error("got b open, want least recently used repo b evicted")
+	}
+	if !isOpen("a") || !isOpen("c") {
+		t.Error("got a or c evicted, want them open")
+	}
+
+	// Repos in use are never evicted.
+	open("a")
+	open("c")
+	open("b")
+	if !isOpen("a") || !isOpen("b") || !isOpen("c") {
+		t.Error("got a repo in use evicted, want all open")
+	}
+	s.Close("b")
+	if isOpen("b") {
+		t.Error("got b open, want idle repo b evicted")
+	}
+
+	// Repos whose clone lock is held are not evicted.
+	mu := s.Mutex(repoKey{filepath.Join(storageDir, "a")})
+	mu.Lock()
+	s.Close("a")
+	open("b")
+	if !isOpen("a") {
+		t.Error("got a evicted, want repo a kept open while its clone lock is held")
+	}
+	mu.Unlock()
+}
+
+func TestService_Init(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)})
+	if err := s.Init("a.b/c", "git"); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	if _, err := s.Open("a.b/c"); err != nil {
+		t.Fatalf("Open after Init: %s", err)
+	}
+	s.Close("a.b/c")
+
+	if err := s.Init("a.b/c", "git"); err != vcsclient.ErrRepoExists {
+		t.Errorf("Init of existing repo: got error %v, want %v", err, vcsclient.ErrRepoExists)
+	}
+	if err := s.Init("a.b/d", "hg"); err == nil {
+		t.Error("Init with unsupported VCS type: got no error")
+	}
+}
+
+func TestService_Exists(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)}).(*service)
+	if exists, err := s.Exists("a.b/c"); err != nil || exists {
+		t.Errorf("Exists before Init: got %v, %v, want false", exists, err)
+	}
+	if err := s.Init("a.b/c", "git"); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	if exists, err := s.Exists("a.b/c"); err != nil || !exists {
+		t.Errorf("Exists after Init: got %v, %v, want true", exists, err)
+	}
+	if users := s.repoUsers[repoKey{filepath.Join(storageDir, "a.b/c")}]; users != 0 {
+		t.Errorf("got %d users of repo after Exists, want 0 (not opened)", users)
+	}
+
+	// A dir that only looks like a bare git repository isn't one.
+	if err := os.MkdirAll(filepath.Join(storageDir, "a.b/d", "objects"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := s.Exists("a.b/d"); err != nil || exists {
+		t.Errorf("Exists of invalid repo: got %v, %v, want false", exists, err)
+	}
+}
+
+func TestService_Clone_concurrent(t *testing.T) {
+	srcDir := newTestSourceRepo(t, 1024)
+	defer os.RemoveAll(srcDir)
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)}).(*service)
+	const (
+		repoPath = "a.b/c"
+		n        = 20
+	)
+	key := repoKey{filepath.Join(storageDir, repoPath)}
+
+	repos := make([]interface{}, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo, err := s.Clone(repoPath, &vcsclient.CloneInfo{VCS: "git", CloneURL: srcDir})
+			if err != nil {
+				t.Errorf("Clone: %s", err)
+				return
+			}
+			repos[i] = repo
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+	for i, repo := range repos {
+		if repo != repos[0] {
+			t.Errorf("Clone %d: got a different repo instance, want the same instance for all", i)
+		}
+	}
+
+	s.repoMuMu.Lock()
+	if len(s.repos) != 1 || s.repos[key] != repos[0] {
+		t.Errorf("got open repos %v, want only %v", s.repos, key)
+	}
+	if len(s.repoUsers) != 1 || s.repoUsers[key] != n {
+		t.Errorf("got repo users %v, want %d users of %v", s.repoUsers, n, key)
+	}
+	s.repoMuMu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.Close(repoPath)
+	}
+	// An extra Close must not make the user count negative.
+	s.Close(repoPath)
+
+	s.repoMuMu.Lock()
+	defer s.repoMuMu.Unlock()
+	if len(s.repoUsers) != 0 {
+		t.Errorf("got repo users %v after closing, want none", s.repoUsers)
+	}
+	if _, idle := s.idleRepoElems[key]; !idle || s.idleRepos.Len() != 1 {
+		t.Errorf("got idle repos %v, want only %v", s.idleRepoElems, key)
+	}
+}
+
+func TestService_CloneAsync(t *testing.T) {
+	srcDir := newTestSourceRepo(t, 1024)
+	defer os.RemoveAll(srcDir)
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)}).(*service)
+	const repoPath = "a.b/c"
+	key := repoKey{filepath.Join(storageDir, repoPath)}
+	cloneInfo := &vcsclient.CloneInfo{VCS: "git", CloneURL: srcDir}
+
+	// Hold the clone lock, so the job stays queued while another
+	// caller starts cloning the same repo.
+	mu := s.Mutex(key)
+	mu.Lock()
+	job1, err := s.CloneAsync(repoPath, cloneInfo)
+	if err != nil {
+		mu.Unlock()
+		t.Fatal(err)
+	}
+	job2, err := s.CloneAsync(repoPath, cloneInfo)
+	mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job2.ID != job1.ID {
+		t.Errorf("got job IDs %q and %q, want the same job for both callers", job1.ID, job2.ID)
+	}
+	if job1.State != vcsclient.CloneJobQueued {
+		t.Errorf("got state %q, want %q", job1.State, vcsclient.CloneJobQueued)
+	}
+
+	job := waitForCloneJob(t, s, repoPath, job1.ID)
+	if job.State != vcsclient.CloneJobDone {
+		t.Errorf("got state %q (error %q), want %q", job.State, job.Error, vcsclient.CloneJobDone)
+	}
+	if exists, err := s.Exists(repoPath); err != nil || !exists {
+		t.Errorf("Exists after clone job: got %v, %v, want true", exists, err)
+	}
+	s.repoMuMu.Lock()
+	if users := s.repoUsers[key]; users != 0 {
+		t.Errorf("got %d users of repo after clone job, want 0", users)
+	}
+	s.repoMuMu.Unlock()
+
+	// A finished job isn't shared with new callers.
+	job3, err := s.CloneAsync(repoPath, cloneInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job3.ID == job1.ID {
+		t.Errorf("got job ID %q of finished job, want a new job", job3.ID)
+	}
+	if job := waitForCloneJob(t, s, repoPath, job3.ID); job.State != vcsclient.CloneJobDone {
+		t.Errorf("clone job of existing repo: got state %q, want %q", job.State, vcsclient.CloneJobDone)
+	}
+
+	// Jobs are only visible through the repo they clone.
+	if _, err := s.CloneJob("a.b/d", job1.ID); err != vcsclient.ErrCloneJobNotExist {
+		t.Errorf("CloneJob of other repo: got error %v, want %v", err, vcsclient.ErrCloneJobNotExist)
+	}
+
+	failed, err := s.CloneAsync("a.b/d", &vcsclient.CloneInfo{VCS: "git", CloneURL: filepath.Join(storageDir, "doesntexist")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := waitForCloneJob(t, s, "a.b/d", failed.ID); job.State != vcsclient.CloneJobFailed || job.Error == "" {
+		t.Errorf("clone job of nonexistent remote: got state %q (error %q), want %q with an error", job.State, job.Error, vcsclient.CloneJobFailed)
+	}
+}
+
+// waitForCloneJob polls the clone job until it finishes.
+func waitForCloneJob(t *testing.T, s *service, repoPath, id string) *vcsclient.CloneJob {
+	for start := time.Now(); time.Since(start) < 30*time.Second; time.Sleep(10 * time.Millisecond) {
+		job, err := s.CloneJob(repoPath, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Finished() {
+			return job
+		}
+	}
+	t.Fatalf("timed out waiting for clone job %s", id)
+	panic("unreachable")
+}
+
+func TestCloneJob_progress(t *testing.T) {
+	var job cloneJob
+	for _, p := range []string{"Cloning into bare repository 'x'...\n", "Receiving objects:  45% (4", "5/100)\r", "Receiving objects: 100% (100/100), done.\r\n", "Resolving"} {
+		job.Write([]byte(p))
+	}
+	if got, want := job.snapshot().Progress, "Receiving objects: 100% (100/100), done."; got != want {
+		t.Errorf("got progress %q, want %q", got, want)
+	}
+}
+
+func TestService_Clone_renameFailure(t *testing.T) {
+	srcDir := newTestSourceRepo(t, 1024)
+	defer os.RemoveAll(srcDir)
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	renameErr := errors.New("simulated rename failure")
+	defer func(orig func(string, string) error) { rename = orig }(rename)
+	rename = func(oldpath, newpath string) error { return renameErr }
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)})
+	_, err = s.Clone("a.b/c", &vcsclient.CloneInfo{VCS: "git", CloneURL: srcDir})
+	if err == nil {
+		t.Fatal("Clone: got no error, want rename failure")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "a.b/c") || !strings.Contains(msg, renameErr.Error()) {
+		t.Errorf("Clone: got error %q, want it to mention the repo and the rename failure", msg)
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, "a.b/c")); !os.IsNotExist(err) {
+		t.Errorf("got clone dir after failed rename (Stat error %v), want none", err)
+	}
+
+	if err := s.Init("a.b/d", "git"); err == nil || !strings.Contains(err.Error(), renameErr.Error()) {
+		t.Errorf("Init: got error %v, want it to mention the rename failure", err)
+	}
+}
+
+func TestService_Clone_httpsAuth(t *testing.T) {
+	srcDir := newTestSourceRepo(t, 1024)
+	defer os.RemoveAll(srcDir)
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	// Serve the source repository over HTTP, requiring basic auth.
+	const user, pass = "u", "secret-token"
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(srcDir), "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-test-env") != "1" {
+			http.Error(w, "git didn't inherit the environment", http.StatusForbidden)
+			return
+		}
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != pass {
+			w.Header().Set("www-authenticate", `Basic realm="test"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	cloneURL := srv.URL + "/" + filepath.Base(srcDir)
+
+	// git must inherit the environment (in which this config adds the
+	// header that the server requires).
+	env := map[string]string{"GIT_CONFIG_COUNT": "1", "GIT_CONFIG_KEY_0": "http.extraHeader", "GIT_CONFIG_VALUE_0": "X-Test-Env: 1"}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	s := NewService(&Config{StorageDir: storageDir, Log: log.New(ioutil.Discard, "", 0)})
+
+	_, err = s.Clone("a.b/bad", &vcsclient.CloneInfo{
+		VCS:        "git",
+		CloneURL:   cloneURL,
+		RemoteOpts: vcs.RemoteOpts{HTTPS: &vcs.HTTPSConfig{User: user, Pass: "wrong-token"}},
+	})
+	if err == nil {
+		t.Error("Clone with bad credentials: got no error")
+	} else if strings.Contains(err.Error(), "wrong-token") {
+		t.Errorf("Clone with bad credentials: got error %q, want it not to contain the password", err)
+	}
+
+	repo, err := s.Clone("a.b/good", &vcsclient.CloneInfo{
+		VCS:        "git",
+		CloneURL:   cloneURL,
+		RemoteOpts: vcs.RemoteOpts{HTTPS: &vcs.HTTPSConfig{User: user, Pass: pass}},
+	})
+	if err != nil {
+		t.Fatalf("Clone with credentials: %s", err)
+	}
+	defer s.Close("a.b/good")
+	if _, err := repo.(vcs.Repository).ResolveBranch("master"); err != nil {
+		t.Errorf("ResolveBranch after Clone: %s", err)
+	}
+}
+
+func TestService_removeStaleTmpDirs(t *testing.T) {
+	storageDir, err := ioutil.TempDir("", "vcsstore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+	if out, err := exec.Command("git", "init", "--bare", filepath.Join(storageDir, "a")).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s\n%s", err, out)
+	}
+
+	// makeTmpDir creates a temporary dir (containing a file) that was
+	// last modified age ago.
+	makeTmpDir := func(name string, age time.Duration) string {
+		dir := filepath.Join(storageDir, name)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, "f")
+		if err := ioutil.WriteFile(file, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		for _, path := range []string{file, dir} {
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	exists := func(dir string) bool {
+		_, err := os.Stat(dir)
+		return err == nil
+	}
+
+	stale := makeTmpDir(tmpDirPrefix+"b-1", 2*time.Hour)
+	staleNested := makeTmpDir("c/"+tmpDirPrefix+"d-2", 2*time.Hour)
+	fresh := makeTmpDir(tmpDirPrefix+"e-3", time.Minute)
+	inRepo := makeTmpDir("a/"+tmpDirPrefix+"f-4", 2*time.Hour)
+
+	// Stale temporary dirs are removed when the service is created.
+	s := NewService(&Config{StorageDir: storageDir, TmpDirMaxAge: time.Hour}).(*service)
+	if exists(stale) || exists(staleNested) {
+		t.Error("got stale temporary dirs kept, want them removed")
+	}
+	if !exists(fresh) {
+		t.Error("got recently modified temporary dir removed, want it kept")
+	}
+	if !exists(inRepo) {
+		t.Error("got dir in repository removed, want it kept")
+	}
+
+	// Temporary dirs in use are never removed.
+	inUse := makeTmpDir(tmpDirPrefix+"g-5", 2*time.Hour)
+	s.acquireTmpDir(inUse)
+	s.removeStaleTmpDirs()
+	if !exists(inUse) {
+		t.Error("got temporary dir in use removed, want it kept")
+	}
+	s.releaseTmpDir(inUse)
+
+	// Stale temporary dirs are removed periodically.
+	s = NewService(&Config{StorageDir: storageDir, TmpDirMaxAge: time.Hour, TmpDirSweepInterval: 10 * time.Millisecond}).(*service)
+	stale = makeTmpDir(tmpDirPrefix+"h-6", 2*time.Hour)
+	for start := time.Now(); exists(stale); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("got stale temporary dir kept, want it removed by the periodic sweep")
+		}
+	}
+}
+
+// newTestSourceRepo creates a git repository with a commit of size
+// bytes of incompressible data, to be cloned in tests.
+func newTestSourceRepo(t *testing.T, size int) string {
+	dir, err := ioutil.TempDir("", "vcsstore-test-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init"},
+		{"add", "data"},
+		{"-c", "user.name=a", "-c", "user.email=a@a.com", "commit", "-m", "data"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestService_Clone_storageQuota(t *testing.T) {
+	srcDir := newTestSourceRepo(t, 64*1024)
+	defer os.RemoveAll(srcDir)
+	cloneInfo := &vcsclient.CloneInfo{VCS: "git", CloneURL: srcDir}
+
+	newService := func(evict bool) (*service, func()) {
+		storageDir, err := ioutil.TempDir("", "vcsstore-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewService(&Config{
+			StorageDir:      storageDir,
+			Log:             log.New(ioutil.Discard, "", 0),
+			MaxStorageBytes: 200 * 1024, // room for 2 clones, not 3
+			EvictForStorage: evict,
+		}).(*service)
+		return s, func() { os.RemoveAll(storageDir) }
+	}
+	clone := func(s *service, repoPath string) error {
+		_, err := s.Clone(repoPath, cloneInfo)
+		if err == nil {
+			s.Close(repoPath)
+		}
+		return err
+	}
+	exists := func(s *service, repoPath string) bool {
+		_, err := os.Stat(filepath.Join(s.StorageDir, repoPath))
+		return err == nil
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		s, done := newService(false)
+		defer done()
+		for _, repoPath := range []string{"quota-a", "quota-b"} {
+			if err := clone(s, repoPath); err != nil {
+				t.Fatalf("Clone(%q): %s", repoPath, err)
+			}
+		}
+		if err := clone(s, "quota-c"); err != vcsclient.ErrStorageQuotaExceeded {
+			t.Fatalf("Clone over quota: got error %v, want %v", err, vcsclient.ErrStorageQuotaExceeded)
+		}
+		if exists(s, "quota-c") {
+			t.Error("got repo quota-c cloned, want it rejected")
+		}
+		if !exists(s, "quota-a") || !exists(s, "quota-b") {
+			t.Error("got existing repo removed, want it kept")
+		}
+		if repoPaths, err := s.ListRepositories(); err != nil {
+			t.Fatal(err)
+		} else if len(repoPaths) != 2 {
+			t.Errorf("got repos %v after rejected clone, want 2", repoPaths)
+		}
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		s, done := newService(true)
+		defer done()
+		for _, repoPath := range []string{"quota-a", "quota-b"} {
+			if err := clone(s, repoPath); err != nil {
+				t.Fatalf("Clone(%q): %s", repoPath, err)
+			}
+		}
+
+		// Access quota-a, so that quota-b is the least recently
+		// accessed.
+		if _, err := s.Open("quota-a"); err != nil {
+			t.Fatal(err)
+		}
+		s.Close("quota-a")
+
+		if err := clone(s, "quota-c"); err != nil {
+			t.Fatalf("Clone over quota: %s", err)
+		}
+		if exists(s, "quota-b") {
+			t.Error("got least recently accessed repo quota-b kept, want it evicted")
+		}
+		if !exists(s, "quota-a") || !exists(s, "quota-c") {
+			t.Error("got repo quota-a or quota-c missing, want them kept")
+		}
+		keyB := repoKey{filepath.Join(s.StorageDir, "quota-b")}
+		if _, present := s.repoSizes[keyB]; present {
+			t.Error("got size of evicted repo quota-b kept, want it removed")
+		}
+		if _, present := s.lastAccess[keyB]; present {
+			t.Error("got last access time of evicted repo quota-b kept, want it removed")
+		}
+
+		// Repos in use are never evicted.
+		if _, err := s.Open("quota-a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := clone(s, "quota-d"); err != nil {
+			t.Fatalf("Clone over quota: %s", err)
+		}
+		if !exists(s, "quota-a") || exists(s, "quota-c") {
+			t.Error("got repo quota-a in use evicted, want quota-c evicted instead")
+		}
+		s.Close("quota-a")
+	})
+
+	t.Run("recorded sizes", func(t *testing.T) {
+		s, done := newService(false)
+		defer done()
+		if err := clone(s, "quota-a"); err != nil {
+			t.Fatal(err)
+		}
+		keyA := repoKey{filepath.Join(s.StorageDir, "quota-a")}
+		size, present := s.repoSizes[keyA]
+		if !present || size <= 0 {
+			t.Fatalf("got recorded size %d (present: %v) after clone, want it recorded", size, present)
+		}
+
+		// The recorded size is used instead of measuring the repo
+		// again, until the size is recorded anew.
+		s.repoSizes[keyA] = s.MaxStorageBytes
+		if err := clone(s, "quota-b"); err != vcsclient.ErrStorageQuotaExceeded {
+			t.Fatalf("Clone with recorded size over quota: got error %v, want %v", err, vcsclient.ErrStorageQuotaExceeded)
+		}
+		s.RecordRepositorySize("quota-a")
+		if got := s.repoSizes[keyA]; got != size {
+			t.Errorf("got recorded size %d, want %d", got, size)
+		}
+		if err := clone(s, "quota-b"); err != nil {
+			t.Fatalf("Clone after recording size: %s", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package vcsstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultTmpDirMaxAge is how long a temporary directory must go
+// unmodified before it is removed if Config.TmpDirMaxAge is 0.
+const defaultTmpDirMaxAge = time.Hour
+
+// acquireTmpDir records that dir (a temporary directory created by
+// the caller) is in use, so that it isn't swept.
+func (s *service) acquireTmpDir(dir string) {
+	s.tmpDirsMu.Lock()
+	defer s.tmpDirsMu.Unlock()
+	s.tmpDirs[dir] = struct{}{}
+}
+
+// releaseTmpDir removes dir, which is no longer in use.
+func (s *service) releaseTmpDir(dir string) error {
+	err := os.RemoveAll(dir)
+	s.tmpDirsMu.Lock()
+	defer s.tmpDirsMu.Unlock()
+	delete(s.tmpDirs, dir)
+	return err
+}
+
+func (s *service) tmpDirInUse(dir string) bool {
+	s.tmpDirsMu.Lock()
+	defer s.tmpDirsMu.Unlock()
+	_, inUse := s.tmpDirs[dir]
+	return inUse
+}
+
+// sweepTmpDirs calls removeStaleTmpDirs every
+// Config.TmpDirSweepInterval. It never returns.
+func (s *service) sweepTmpDirs() {
+	for range time.Tick(s.TmpDirSweepInterval) {
+		s.removeStaleTmpDirs()
+	}
+}
+
+// removeStaleTmpDirs removes the temporary directories in the
+// storage dir that aren't in use and haven't been modified in
+// Config.TmpDirMaxAge. They are left behind by clones that were
+// interrupted (for example, by a crash), because the deferred
+// removal never ran.
+//
+// The age check also protects the temporary directories of clones
+// in other processes that share the storage dir.
+func (s *service) removeStaleTmpDirs() {
+	maxAge := s.TmpDirMaxAge
+	if maxAge == 0 {
+		maxAge = defaultTmpDirMaxAge
+	}
+
+	dirs, err := s.listTmpDirs()
+	if err != nil {
+		s.logf("Error listing temporary directories in %s: %s.", s.StorageDir, err)
+		return
+	}
+	for _, dir := range dirs {
+		if s.tmpDirInUse(dir) {
+			continue
+		}
+		mtime, err := lastModified(dir)
+		if os.IsNotExist(err) {
+			continue // removed concurrently
+		} else if err != nil {
+			s.logf("Error checking temporary directory %s: %s.", dir, err)
+			continue
+		}
+		if age := time.Since(mtime); age < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			s.logf("Error removing stale temporary directory %s: %s.", dir, err)
+			continue
+		}
+		s.logf("Removed stale temporary directory %s (last modified %s).", dir, mtime)
+	}
+}
+
+// listTmpDirs returns the temporary directories in the storage dir
+// (which are siblings of repositories).
+func (s *service) listTmpDirs() ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(s.StorageDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed concurrently (or no storage dir yet)
+			}
+			return err
+		}
+		if !fi.IsDir() || path == s.StorageDir {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), tmpDirPrefix) {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		if _, err := vcsTypeFromDir(path); err == nil {
+			// Repositories don't contain temporary directories.
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// lastModified returns the most recent modification time of dir and
+// the files and directories in it.
+func lastModified(dir string) (time.Time, error) {
+	var mtime time.Time
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if path != dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if t := fi.ModTime(); t.After(mtime) {
+			mtime = t
+		}
+		return nil
+	})
+	return mtime, err
+}
@@ -40,3 +40,34 @@ func TestRepository_BlameFile(t *testing.T) {
 		t.Errorf("Repository.BlameFile returned %+v, want %+v", hunks, want)
 	}
 }
+
+func TestRepository_BlameFile_ignoreRevs(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoBlameFile, repo, map[string]string{"RepoPath": repoPath, "Path": "f"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		if got, want := r.URL.Query()["IgnoreRevs"], []string{"r1", "r2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got IgnoreRevs %q, want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("IgnoreRevsFile"), ".git-blame-ignore-revs"; got != want {
+			t.Errorf("got IgnoreRevsFile %q, want %q", got, want)
+		}
+
+		writeJSON(w, []*vcs.Hunk{})
+	})
+
+	if _, err := repo.BlameFile("f", &vcs.BlameOptions{IgnoreRevs: []vcs.CommitID{"r1", "r2"}, IgnoreRevsFile: ".git-blame-ignore-revs"}); err != nil {
+		t.Errorf("Repository.Blame returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
@@ -2,6 +2,7 @@ package vcsclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +29,10 @@ type Client struct {
 	// User agent used for HTTP requests to the vcsstore API.
 	UserAgent string
 
+	// RetryPolicy decides whether to retry failed idempotent requests.
+	// If nil, requests are not retried.
+	RetryPolicy RetryPolicy
+
 	// HTTP client used to communicate with the vcsstore API.
 	httpClient *http.Client
 
@@ -52,6 +57,7 @@ func New(base *url.URL, httpClient *http.Client) *Client {
 	c := &Client{
 		BaseURL:                   base,
 		UserAgent:                 userAgent,
+		RetryPolicy:               DefaultRetryPolicy,
 		httpClient:                httpClient,
 		ignoreRedirectsHTTPClient: &ignoreRedirectsHTTPClient,
 	}
@@ -59,10 +65,21 @@ func New(base *url.URL, httpClient *http.Client) *Client {
 	return c
 }
 
+// Repository returns the repository at repoPath. The HTTP requests
+// made by its methods can't be canceled; use RepositoryContext for
+// that.
 func (c *Client) Repository(repoPath string) (vcs.Repository, error) {
+	return c.RepositoryContext(context.Background(), repoPath)
+}
+
+// RepositoryContext is like Repository, but the HTTP requests made by
+// the returned repository's methods (and by the file systems it
+// returns) use ctx. Canceling ctx aborts any in-flight requests.
+func (c *Client) RepositoryContext(ctx context.Context, repoPath string) (vcs.Repository, error) {
 	return &repository{
 		client:   c,
 		repoPath: repoPath,
+		ctx:      ctx,
 	}, nil
 }
 
@@ -75,6 +92,11 @@ func (c *Client) GitTransport(repoPath string) (git.GitTransport, error) {
 // URLs should always be specified without a preceding slash. If specified, the
 // value pointed to by body is JSON encoded and included as the request body.
 func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Request, error) {
+	return c.NewRequestWithContext(context.Background(), method, urlStr, body)
+}
+
+// NewRequestWithContext is like NewRequest, but the request uses ctx.
+func (c *Client) NewRequestWithContext(ctx context.Context, method, urlStr string, body interface{}) (*http.Request, error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, err
@@ -96,7 +118,7 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 		hasJSONBody = true
 	}
 
-	req, err := http.NewRequest(method, u.String(), &buf)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), &buf)
 	if err != nil {
 		return nil, err
 	}
@@ -106,21 +128,25 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 	}
 
 	req.Header.Add("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", acceptHeader)
 	return req, nil
 }
 
 // Do sends an API request and returns the API response.  The API response is
 // decoded and stored in the value pointed to by v, or returned as an error if
-// an API error has occurred.
+// an API error has occurred. The response is decoded as msgpack or JSON,
+// according to its Content-Type. If v is an io.Writer, the response body is
+// copied to it as it is read (without decoding it). Idempotent requests
+// are retried according to the client's RetryPolicy.
 func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
+	resp, attempts, err := c.send(c.httpClient, req)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
-	err = CheckResponse(resp, false)
+	err = checkResponse(resp, false, attempts)
 	if err != nil {
 		// even though there was an error, we still return the response
 		// in case the caller wants to inspect it further
@@ -130,8 +156,10 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 	if v != nil {
 		if bp, ok := v.(*[]byte); ok {
 			*bp, err = ioutil.ReadAll(resp.Body)
-		} else if buf, ok := v.(*bytes.Buffer); ok {
-			_, err = io.Copy(buf, resp.Body)
+		} else if w, ok := v.(io.Writer); ok {
+			_, err = io.Copy(w, resp.Body)
+		} else if isMsgpack(resp.Header.Get("content-type")) {
+			err = DecodeMsgpack(resp.Body, v)
 		} else {
 			err = json.NewDecoder(resp.Body).Decode(v)
 		}
@@ -145,13 +173,13 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 // doIgnoringRedirects sends an API request and returns the HTTP response. If
 // it encounters an HTTP redirect, it does not follow it.
 func (c *Client) doIgnoringRedirects(req *http.Request) (*http.Response, error) {
-	resp, err := c.ignoreRedirectsHTTPClient.Do(req)
+	resp, attempts, err := c.send(c.ignoreRedirectsHTTPClient, req)
 	if err != nil && !isIgnoredRedirectErr(err) {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	return resp, CheckResponse(resp, true)
+	return resp, checkResponse(resp, true, attempts)
 }
 
 var errIgnoredRedirect = errors.New("not following redirect")
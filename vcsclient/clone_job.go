@@ -0,0 +1,122 @@
+package vcsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCloneJobNotExist is returned when the requested clone job doesn't
+// exist (or has finished long enough ago that it was forgotten).
+var ErrCloneJobNotExist = errors.New("clone job does not exist")
+
+// CloneJobState is the state of a clone job.
+type CloneJobState string
+
+const (
+	CloneJobQueued  CloneJobState = "queued"  // waiting for another clone of the repository
+	CloneJobCloning CloneJobState = "cloning" // cloning in progress
+	CloneJobDone    CloneJobState = "done"    // the repository was cloned (or already existed)
+	CloneJobFailed  CloneJobState = "failed"  // cloning failed (see CloneJob.Error)
+)
+
+// A CloneJob is a clone of a repository that runs in the background
+// on the server (see RepositoryAsyncCloner).
+type CloneJob struct {
+	// ID identifies the job among the server's clone jobs.
+	ID string
+
+	State CloneJobState
+
+	// Progress is the most recent progress message that the VCS
+	// printed while cloning (such as "Receiving objects:  45%
+	// (450/1000)"), if any.
+	Progress string `json:",omitempty"`
+
+	// Error is the error message of a failed job.
+	Error string `json:",omitempty"`
+}
+
+// Finished returns whether the job is done or failed.
+func (j *CloneJob) Finished() bool {
+	return j.State == CloneJobDone || j.State == CloneJobFailed
+}
+
+type RepositoryAsyncCloner interface {
+	// CloneAsync instructs the server to clone the repository in the
+	// background if it doesn't yet exist, and returns a handle to the
+	// clone job without waiting for it to finish. If the repository
+	// is already being cloned, the existing job is returned.
+	CloneAsync(cloneInfo *CloneInfo) (*CloneJobHandle, error)
+}
+
+func (r *repository) CloneAsync(cloneInfo *CloneInfo) (*CloneJobHandle, error) {
+	url, err := r.url(RouteRepoCloneAsync, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("POST", url.String(), cloneInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	var job CloneJob
+	if _, err := r.client.Do(req, &job); err != nil {
+		return nil, knownErrorOr(err)
+	}
+	return &CloneJobHandle{ID: job.ID, repo: r}, nil
+}
+
+// A CloneJobHandle refers to a clone job started by CloneAsync.
+type CloneJobHandle struct {
+	ID string // the clone job's ID
+
+	repo *repository
+}
+
+// Poll returns the current state of the clone job. If the server has
+// forgotten the job (e.g., because it restarted), ErrCloneJobNotExist
+// is returned.
+func (h *CloneJobHandle) Poll() (*CloneJob, error) {
+	url, err := h.repo.url(RouteRepoCloneStatus, map[string]string{"JobID": h.ID}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := h.repo.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var job CloneJob
+	if _, err := h.repo.client.Do(req, &job); err != nil {
+		return nil, knownErrorOr(err)
+	}
+	return &job, nil
+}
+
+// Wait polls the clone job every interval until it finishes or ctx is
+// done, and returns its final state. If the job failed, the job and
+// an error with its message are returned.
+func (h *CloneJobHandle) Wait(ctx context.Context, interval time.Duration) (*CloneJob, error) {
+	for {
+		job, err := h.Poll()
+		if err != nil {
+			return nil, err
+		}
+		switch job.State {
+		case CloneJobDone:
+			return job, nil
+		case CloneJobFailed:
+			return job, fmt.Errorf("clone of %s failed: %s", h.repo.repoPath, job.Error)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return job, ctx.Err()
+		}
+	}
+}
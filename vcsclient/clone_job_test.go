@@ -0,0 +1,73 @@
+package vcsclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepository_CloneAsync(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	opt := &CloneInfo{VCS: "git", CloneURL: "https://example.com/a.git"}
+	const jobID = "j"
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCloneAsync, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+
+		body, _ := json.Marshal(opt)
+		testBody(t, r, string(body)+"\n")
+
+		writeJSON(w, &CloneJob{ID: jobID, State: CloneJobQueued})
+	})
+	// The job makes progress and then fails.
+	states := []CloneJob{
+		{ID: jobID, State: CloneJobCloning},
+		{ID: jobID, State: CloneJobCloning, Progress: "Receiving objects:  45% (450/1000)"},
+		{ID: jobID, State: CloneJobFailed, Progress: "Receiving objects:  45% (450/1000)", Error: "x"},
+	}
+	var polls int
+	mux.HandleFunc(urlPath(t, RouteRepoCloneStatus, repo, map[string]string{"JobID": jobID}), func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		writeJSON(w, &states[polls])
+		polls++
+	})
+
+	h, err := repo.CloneAsync(opt)
+	if err != nil {
+		t.Fatalf("Repository.CloneAsync returned error: %v", err)
+	}
+	if !called {
+		t.Fatal("!called")
+	}
+	if h.ID != jobID {
+		t.Errorf("got job ID %q, want %q", h.ID, jobID)
+	}
+
+	job, err := h.Poll()
+	if err != nil {
+		t.Fatalf("Poll returned error: %v", err)
+	}
+	if !reflect.DeepEqual(*job, states[0]) {
+		t.Errorf("Poll returned %+v, want %+v", job, states[0])
+	}
+
+	job, err = h.Wait(context.Background(), time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "x") {
+		t.Errorf("Wait returned error %v, want the job's error", err)
+	}
+	if !reflect.DeepEqual(*job, states[2]) {
+		t.Errorf("Wait returned %+v, want %+v", job, states[2])
+	}
+}
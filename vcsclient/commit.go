@@ -0,0 +1,20 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+// CommitAuthor returns the author of commit c: the person who
+// originally wrote the change.
+func CommitAuthor(c *vcs.Commit) vcs.Signature {
+	return c.Author
+}
+
+// CommitCommitter returns the committer of commit c: the person who
+// last applied the change (which differs from the author if, e.g.,
+// the commit was rebased, amended, or applied from a patch). If the
+// VCS doesn't record a separate committer, the author is returned.
+func CommitCommitter(c *vcs.Commit) vcs.Signature {
+	if c.Committer != nil {
+		return *c.Committer
+	}
+	return c.Author
+}
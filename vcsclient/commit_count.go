@@ -0,0 +1,32 @@
+package vcsclient
+
+import (
+	"strconv"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.CommitCounter = (*repository)(nil)
+
+func (r *repository) CountCommits(opt vcs.CommitsOptions) (uint, error) {
+	url, err := r.url(RouteRepoCommitCount, nil, opt)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := r.client.Do(req, nil)
+	if err != nil {
+		return 0, knownErrorOr(err)
+	}
+
+	count, err := strconv.ParseUint(resp.Header.Get(CommitCountHeader), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(count), nil
+}
@@ -0,0 +1,59 @@
+package vcsclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_CountCommits(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommitCount, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Head": "abcd", "Base": "ef01", "N": "0", "Skip": "0", "Path": "f", "Author": "a@a.com", "Since": "2006-01-02T15:04:05Z", "NoTotal": "false"})
+
+		w.Header().Set(CommitCountHeader, "123")
+		writeJSON(w, 123)
+	})
+
+	count, err := repo.CountCommits(vcs.CommitsOptions{Head: "abcd", Base: "ef01", Path: "f", Author: "a@a.com", Since: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)})
+	if err != nil {
+		t.Errorf("Repository.CountCommits returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if want := uint(123); count != want {
+		t.Errorf("Repository.CountCommits returned %d, want %d", count, want)
+	}
+}
+
+func TestRepository_CountCommits_notFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoCommitCount, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrCommitNotFound.Error()})
+	})
+
+	if _, err := repo.CountCommits(vcs.CommitsOptions{Head: "abcd"}); err != vcs.ErrCommitNotFound {
+		t.Errorf("got error %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
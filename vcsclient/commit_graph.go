@@ -0,0 +1,24 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.CommitGrapher = (*repository)(nil)
+
+func (r *repository) CommitGraph(opt vcs.CommitsOptions) ([]*vcs.CommitNode, error) {
+	url, err := r.url(RouteRepoCommitGraph, nil, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*vcs.CommitNode
+	if _, err := r.client.Do(req, &nodes); err != nil {
+		return nil, knownErrorOr(err)
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,42 @@
+package vcsclient
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_CommitGraph(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.CommitNode{{ID: "abcd", Parents: []vcs.CommitID{"wxyz"}}, {ID: "wxyz"}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommitGraph, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Head": "abcd", "Base": "", "N": "2", "Skip": "3", "Path": "", "NoTotal": "false"})
+
+		writeJSON(w, want)
+	})
+
+	nodes, err := repo.CommitGraph(vcs.CommitsOptions{Head: "abcd", N: 2, Skip: 3})
+	if err != nil {
+		t.Errorf("Repository.CommitGraph returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Repository.CommitGraph returned %+v, want %+v", nodes, want)
+	}
+}
@@ -0,0 +1,31 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+// CommitRefs lists the branches and tags that point at a commit (see
+// (vcs.CommitRefsLister).RefsForCommit).
+type CommitRefs struct {
+	Branches []string `json:",omitempty"`
+	Tags     []string `json:",omitempty"`
+}
+
+var _ vcs.CommitRefsLister = (*repository)(nil)
+
+func (r *repository) RefsForCommit(commitID vcs.CommitID) (branches []string, tags []string, err error) {
+	url, err := r.url(RouteRepoCommitRefs, map[string]string{"CommitID": string(commitID)}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var refs CommitRefs
+	if _, err := r.client.Do(req, &refs); err != nil {
+		return nil, nil, knownErrorOr(err)
+	}
+
+	return refs.Branches, refs.Tags, nil
+}
@@ -0,0 +1,42 @@
+package vcsclient
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRepository_RefsForCommit(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := &CommitRefs{Branches: []string{"main", "release-2"}, Tags: []string{"v2.0"}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommitRefs, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, want)
+	})
+
+	branches, tags, err := repo.RefsForCommit("abcd")
+	if err != nil {
+		t.Errorf("Repository.RefsForCommit returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(branches, want.Branches) {
+		t.Errorf("Repository.RefsForCommit returned branches %v, want %v", branches, want.Branches)
+	}
+	if !reflect.DeepEqual(tags, want.Tags) {
+		t.Errorf("Repository.RefsForCommit returned tags %v, want %v", tags, want.Tags)
+	}
+}
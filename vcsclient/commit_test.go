@@ -0,0 +1,28 @@
+package vcsclient
+
+import (
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sqs/pbtypes"
+)
+
+func TestCommitAuthorAndCommitter(t *testing.T) {
+	author := vcs.Signature{Name: "a", Email: "a@a.com", Date: pbtypes.NewTimestamp(time.Unix(1, 0))}
+	committer := vcs.Signature{Name: "c", Email: "c@c.com", Date: pbtypes.NewTimestamp(time.Unix(2, 0))}
+
+	c := &vcs.Commit{Author: author, Committer: &committer}
+	if got := CommitAuthor(c); got != author {
+		t.Errorf("got author %+v, want %+v", got, author)
+	}
+	if got := CommitCommitter(c); got != committer {
+		t.Errorf("got committer %+v, want %+v", got, committer)
+	}
+
+	// Without a separate committer, the author is the committer.
+	c = &vcs.Commit{Author: author}
+	if got := CommitCommitter(c); got != author {
+		t.Errorf("got committer %+v, want author %+v", got, author)
+	}
+}
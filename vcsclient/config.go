@@ -0,0 +1,39 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.Configurer = (*repository)(nil)
+
+func (r *repository) GetConfig(key string) (string, error) {
+	url, err := r.url(RouteRepoConfig, map[string]string{"Key": key}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	if _, err := r.client.Do(req, &value); err != nil {
+		return "", knownErrorOr(err)
+	}
+
+	return value, nil
+}
+
+func (r *repository) SetConfig(key, value string) error {
+	url, err := r.url(RouteRepoSetConfig, map[string]string{"Key": key}, nil)
+	if err != nil {
+		return err
+	}
+
+	req, err := r.newRequest("PUT", url.String(), value)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Do(req, nil)
+	return err
+}
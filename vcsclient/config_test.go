@@ -0,0 +1,89 @@
+package vcsclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_GetConfig(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoConfig, repo, map[string]string{"RepoPath": repoPath, "Key": "foo.bar"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, "baz")
+	})
+
+	value, err := repo.GetConfig("foo.bar")
+	if err != nil {
+		t.Errorf("Repository.GetConfig returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if value != "baz" {
+		t.Errorf("Repository.GetConfig returned %q, want %q", value, "baz")
+	}
+}
+
+func TestRepository_GetConfig_notFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoConfig, repo, map[string]string{"RepoPath": repoPath, "Key": "foo.bar"}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrConfigKeyNotFound.Error()})
+	})
+
+	if _, err := repo.GetConfig("foo.bar"); err != vcs.ErrConfigKeyNotFound {
+		t.Errorf("Repository.GetConfig returned error %v, want %v", err, vcs.ErrConfigKeyNotFound)
+	}
+}
+
+func TestRepository_SetConfig(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoSetConfig, repo, map[string]string{"RepoPath": repoPath, "Key": "foo.bar"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "PUT")
+
+		var value string
+		if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+		if value != "baz" {
+			t.Errorf("got value %q, want %q", value, "baz")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := repo.SetConfig("foo.bar", "baz"); err != nil {
+		t.Errorf("Repository.SetConfig returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
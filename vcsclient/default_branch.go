@@ -0,0 +1,24 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.DefaultBrancher = (*repository)(nil)
+
+func (r *repository) DefaultBranch() (string, error) {
+	url, err := r.url(RouteRepoDefaultBranch, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var branch string
+	if _, err := r.client.Do(req, &branch); err != nil {
+		return "", knownErrorOr(err)
+	}
+
+	return branch, nil
+}
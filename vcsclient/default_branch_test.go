@@ -0,0 +1,56 @@
+package vcsclient
+
+import (
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_DefaultBranch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoDefaultBranch, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, "main")
+	})
+
+	branch, err := repo.DefaultBranch()
+	if err != nil {
+		t.Errorf("Repository.DefaultBranch returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if branch != "main" {
+		t.Errorf("Repository.DefaultBranch returned %q, want %q", branch, "main")
+	}
+}
+
+func TestRepository_DefaultBranch_empty(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoDefaultBranch, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrRepoEmpty.Error()})
+	})
+
+	if _, err := repo.DefaultBranch(); err != vcs.ErrRepoEmpty {
+		t.Errorf("Repository.DefaultBranch returned error %v, want %v", err, vcs.ErrRepoEmpty)
+	}
+}
@@ -0,0 +1,24 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.Describer = (*repository)(nil)
+
+func (r *repository) Describe(commitID vcs.CommitID, opt vcs.DescribeOptions) (string, error) {
+	url, err := r.url(RouteRepoDescribe, map[string]string{"CommitID": string(commitID)}, opt)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var desc string
+	if _, err := r.client.Do(req, &desc); err != nil {
+		return "", err
+	}
+
+	return desc, nil
+}
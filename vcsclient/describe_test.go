@@ -0,0 +1,41 @@
+package vcsclient
+
+import (
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_Describe(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := "v1.2.3-4-gabcd"
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoDescribe, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Abbrev": "10", "ExactMatch": "true"})
+
+		writeJSON(w, want)
+	})
+
+	desc, err := repo.Describe("abcd", vcs.DescribeOptions{Abbrev: 10, ExactMatch: true})
+	if err != nil {
+		t.Errorf("Repository.Describe returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if desc != want {
+		t.Errorf("Repository.Describe returned %q, want %q", desc, want)
+	}
+}
@@ -9,15 +9,24 @@ import (
 var (
 	_ vcs.Differ          = (*repository)(nil)
 	_ vcs.CrossRepoDiffer = (*repository)(nil)
+	_ vcs.TreeDiffer      = (*repository)(nil)
 )
 
+// TreeDiffOptions specifies the trees to diff (and how) in a tree diff
+// request (see vcs.TreeDiffer).
+type TreeDiffOptions struct {
+	Base, Head string // tree specs ("<rev>:<path>" or "<rev>")
+
+	vcs.DiffOptions
+}
+
 func (r *repository) Diff(base, head vcs.CommitID, opt *vcs.DiffOptions) (*vcs.Diff, error) {
 	url, err := r.url(RouteRepoDiff, map[string]string{"Base": string(base), "Head": string(head)}, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +52,7 @@ func (r *repository) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repository, h
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -55,3 +64,26 @@ func (r *repository) CrossRepoDiff(base vcs.CommitID, headRepo vcs.Repository, h
 
 	return diff, nil
 }
+
+func (r *repository) DiffTrees(base, head string, opt *vcs.DiffOptions) (*vcs.Diff, error) {
+	treeOpt := &TreeDiffOptions{Base: base, Head: head}
+	if opt != nil {
+		treeOpt.DiffOptions = *opt
+	}
+	url, err := r.url(RouteRepoTreeDiff, nil, treeOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff *vcs.Diff
+	if _, err := r.client.Do(req, &diff); err != nil {
+		return nil, knownErrorOr(err)
+	}
+
+	return diff, nil
+}
@@ -40,6 +40,72 @@ func TestRepository_Diff(t *testing.T) {
 	}
 }
 
+func TestRepository_Diff_ignoreWhitespace(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := &vcs.Diff{}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoDiff, repo, map[string]string{"RepoPath": repoPath, "Base": "b", "Head": "h"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"DetectRenames": "false", "OrigPrefix": "", "NewPrefix": "", "ExcludeReachableFromBoth": "false", "IgnoreWhitespace": "true", "IgnoreWhitespaceChange": "true"})
+
+		writeJSON(w, want)
+	})
+
+	diff, err := repo.Diff("b", "h", &vcs.DiffOptions{IgnoreWhitespace: true, IgnoreWhitespaceChange: true})
+	if err != nil {
+		t.Errorf("Repository.Diff returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Repository.Diff returned %+v, want %+v", diff, want)
+	}
+}
+
+func TestRepository_DiffTrees(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := &vcs.Diff{Raw: "diff"}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoTreeDiff, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Base": "b:x/y", "Head": "h:z", "DetectRenames": "true", "OrigPrefix": "", "NewPrefix": "", "ExcludeReachableFromBoth": "false"})
+
+		writeJSON(w, want)
+	})
+
+	diff, err := repo.DiffTrees("b:x/y", "h:z", &vcs.DiffOptions{DetectRenames: true})
+	if err != nil {
+		t.Errorf("Repository.DiffTrees returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Repository.DiffTrees returned %+v, want %+v", diff, want)
+	}
+}
+
 func TestRepository_CrossRepoDiff(t *testing.T) {
 	setup()
 	defer teardown()
@@ -0,0 +1,24 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.DiffStater = (*repository)(nil)
+
+func (r *repository) DiffStat(commitID vcs.CommitID) ([]*vcs.FileStat, error) {
+	url, err := r.url(RouteRepoDiffStat, map[string]string{"CommitID": string(commitID)}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*vcs.FileStat
+	if _, err := r.client.Do(req, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
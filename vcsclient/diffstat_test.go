@@ -0,0 +1,41 @@
+package vcsclient
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_DiffStat(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.FileStat{{Path: "g", OrigPath: "f", Added: 1, Deleted: 2}, {Path: "b", Binary: true}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoDiffStat, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, want)
+	})
+
+	stats, err := repo.DiffStat("abcd")
+	if err != nil {
+		t.Errorf("Repository.DiffStat returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Repository.DiffStat returned %+v, want %+v", stats, want)
+	}
+}
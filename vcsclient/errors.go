@@ -5,18 +5,26 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
 )
 
 // An ErrorResponse reports errors caused by an API request.
 type ErrorResponse struct {
 	Response *http.Response `json:",omitempty"` // HTTP response that caused this error
 	Message  string         // error message
+	Attempts int            `json:"-"` // number of attempts made (if the request was retried)
 }
 
 func (r *ErrorResponse) Error() string {
-	return fmt.Sprintf("%v %v: %d %v",
+	msg := fmt.Sprintf("%v %v: %d %v",
 		r.Response.Request.Method, r.Response.Request.URL,
 		r.Response.StatusCode, r.Message)
+	if r.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", r.Attempts)
+	}
+	return msg
 }
 
 func (r *ErrorResponse) Description() string { return r.Message }
@@ -40,6 +48,16 @@ func CheckResponse(r *http.Response, redirectOK bool) error {
 	return errorResponse
 }
 
+// checkResponse is like CheckResponse, but it records in the returned
+// error the number of attempts made to get r.
+func checkResponse(r *http.Response, redirectOK bool, attempts int) error {
+	err := CheckResponse(r, redirectOK)
+	if errResp, ok := err.(*ErrorResponse); ok && attempts > 1 {
+		errResp.Attempts = attempts
+	}
+	return err
+}
+
 func IsHTTPErrorCode(err error, statusCode int) bool {
 	if err == nil {
 		return false
@@ -54,3 +72,79 @@ func IsHTTPErrorCode(err error, statusCode int) bool {
 	}
 	return false
 }
+
+// knownErrors are the errors that the server reports by message in
+// error responses (even when it's not in debug mode), so that clients
+// can tell them apart. They all have HTTP status 404, except
+// vcs.ErrRefExists, vcs.ErrRemoteExists, and ErrRepoExists (409),
+// vcs.ErrConfigKeyNotSettable (403), and ErrStorageQuotaExceeded
+// (507).
+var knownErrors = []error{
+	ErrRepoNotExist,
+	vcs.ErrCommitNotFound,
+	vcs.ErrBranchNotFound,
+	vcs.ErrRevisionNotFound,
+	vcs.ErrTagNotFound,
+	vcs.ErrNoDescription,
+	vcs.ErrObjectNotFound,
+	vcs.ErrConfigKeyNotFound,
+	vcs.ErrRepoEmpty,
+	vcs.ErrNoteNotFound,
+	vcs.ErrConfigKeyNotSettable,
+	vcs.ErrNoReflog,
+	vcs.ErrReflogTooShort,
+	vcs.ErrRefExists,
+	vcs.ErrRemoteNotFound,
+	vcs.ErrRemoteExists,
+	ErrRepoExists,
+	ErrStorageQuotaExceeded,
+	ErrCloneJobNotExist,
+}
+
+// KnownError returns the known error (such as vcs.ErrCommitNotFound
+// or ErrRepoNotExist) that err describes, or nil if err doesn't
+// describe a known error. The err may be an *ErrorResponse returned
+// by a client method.
+func KnownError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var msg string
+	if errResp, ok := err.(*ErrorResponse); ok {
+		msg = errResp.Message
+	} else {
+		msg = err.Error()
+	}
+	for _, knownErr := range knownErrors {
+		if err == knownErr || msg == knownErr.Error() {
+			return knownErr
+		}
+	}
+	return nil
+}
+
+// IsNotFound returns whether err indicates that the requested
+// repository, commit, revision, branch, tag, file, or other resource
+// does not exist.
+func IsNotFound(err error) bool {
+	if knownErr := KnownError(err); knownErr != nil {
+		return knownErr != vcs.ErrRefExists && knownErr != vcs.ErrRemoteExists && knownErr != ErrRepoExists && knownErr != ErrStorageQuotaExceeded
+	}
+	return IsHTTPErrorCode(err, http.StatusNotFound) || os.IsNotExist(err)
+}
+
+// IsEmpty returns whether err indicates that the repository has no
+// commits (see vcs.ErrRepoEmpty). The err may be an *ErrorResponse
+// returned by a client method.
+func IsEmpty(err error) bool {
+	return KnownError(err) == vcs.ErrRepoEmpty
+}
+
+// knownErrorOr returns the known error that err describes (see
+// KnownError), or else err.
+func knownErrorOr(err error) error {
+	if knownErr := KnownError(err); knownErr != nil {
+		return knownErr
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package vcsclient
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestKnownError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{errors.New("x"), nil},
+		{vcs.ErrCommitNotFound, vcs.ErrCommitNotFound},
+		{errors.New(vcs.ErrBranchNotFound.Error()), vcs.ErrBranchNotFound},
+		{&ErrorResponse{Message: vcs.ErrRevisionNotFound.Error()}, vcs.ErrRevisionNotFound},
+		{&ErrorResponse{Message: ErrRepoNotExist.Error()}, ErrRepoNotExist},
+		{&ErrorResponse{Message: "x"}, nil},
+	}
+	for _, test := range tests {
+		if got := KnownError(test.err); got != test.want {
+			t.Errorf("KnownError(%v): got %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("x"), false},
+		{vcs.ErrTagNotFound, true},
+		{&os.PathError{Op: "stat", Path: "f", Err: os.ErrNotExist}, true},
+		{&ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}, true},
+		{&ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: vcs.ErrCommitNotFound.Error()}, true},
+		{&ErrorResponse{Response: &http.Response{StatusCode: http.StatusInternalServerError}}, false},
+	}
+	for _, test := range tests {
+		if got := IsNotFound(test.err); got != test.want {
+			t.Errorf("IsNotFound(%v): got %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("x"), false},
+		{vcs.ErrRepoEmpty, true},
+		{&ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: vcs.ErrRepoEmpty.Error()}, true},
+		{&ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: vcs.ErrCommitNotFound.Error()}, false},
+	}
+	for _, test := range tests {
+		if got := IsEmpty(test.err); got != test.want {
+			t.Errorf("IsEmpty(%v): got %v, want %v", test.err, got, test.want)
+		}
+	}
+}
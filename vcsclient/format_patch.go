@@ -0,0 +1,35 @@
+package vcsclient
+
+import (
+	"io"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+var _ vcs.PatchFormatter = (*repository)(nil)
+
+// FormatPatch returns the mbox-format patches of the commits in
+// opt.Base..head (or of only head, if opt.Base is empty). The patches
+// are streamed from the server, so the caller must close them.
+func (r *repository) FormatPatch(head vcs.CommitID, opt vcs.FormatPatchOptions) (io.ReadCloser, error) {
+	url, err := r.url(RouteRepoFormatPatch, map[string]string{"CommitID": string(head)}, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, attempts, err := r.client.send(r.client.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, false, attempts); err != nil {
+		resp.Body.Close()
+		return nil, knownErrorOr(err)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,66 @@
+package vcsclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_FormatPatch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := "From aaaa Mon Sep 17 00:00:00 2001\nSubject: [PATCH] foo\n"
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoFormatPatch, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Base": "bcde", "IncludeMerges": "true"})
+
+		w.Header().Set("content-type", "text/plain; charset=utf-8")
+		w.Write([]byte(want))
+	})
+
+	patches, err := repo.FormatPatch("abcd", vcs.FormatPatchOptions{Base: "bcde", IncludeMerges: true})
+	if err != nil {
+		t.Fatalf("Repository.FormatPatch returned error: %v", err)
+	}
+	defer patches.Close()
+	data, err := ioutil.ReadAll(patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if string(data) != want {
+		t.Errorf("Repository.FormatPatch returned %q, want %q", data, want)
+	}
+}
+
+func TestRepository_FormatPatch_notFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoFormatPatch, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrCommitNotFound.Error()})
+	})
+
+	if _, err := repo.FormatPatch("abcd", vcs.FormatPatchOptions{}); err != vcs.ErrCommitNotFound {
+		t.Errorf("Repository.FormatPatch returned error %v, want %v", err, vcs.ErrCommitNotFound)
+	}
+}
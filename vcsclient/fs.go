@@ -2,6 +2,7 @@ package vcsclient
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -33,6 +34,14 @@ func (fs *repositoryFS) Open(name string) (vfs.ReadSeekCloser, error) {
 	if err != nil {
 		return nil, err
 	}
+	if e.ContentsOmitted {
+		// The file is too large to be returned by default.
+		fr, err := fs.GetFileWithOptions(name, GetFileOptions{EntireFile: true})
+		if err != nil {
+			return nil, err
+		}
+		e = fr.TreeEntry
+	}
 
 	return nopCloser{bytes.NewReader(e.Contents)}, nil
 }
@@ -84,7 +93,7 @@ func (fs *repositoryFS) Get(path string) (*TreeEntry, error) {
 		return nil, err
 	}
 
-	req, err := fs.repo.client.NewRequest("GET", url.String(), nil)
+	req, err := fs.repo.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +127,7 @@ func (fs *repositoryFS) GetFileWithOptions(path string, opt GetFileOptions) (*Fi
 		return nil, err
 	}
 
-	req, err := fs.repo.client.NewRequest("GET", url.String(), nil)
+	req, err := fs.repo.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -149,6 +158,14 @@ type FileGetter interface {
 // called; otherwise the options are applied on the client side after
 // fetching the whole file.
 func GetFileWithOptions(fs vfs.FileSystem, path string, opt GetFileOptions) (*FileWithRange, error) {
+	return GetFileWithMaxContentsSize(fs, path, opt, 0)
+}
+
+// GetFileWithMaxContentsSize is like GetFileWithOptions, but if
+// maxContentsSize is nonzero, it omits the contents of files larger
+// than maxContentsSize bytes (and sets ContentsOmitted) without
+// reading them, unless opt specifies EntireFile or a range.
+func GetFileWithMaxContentsSize(fs vfs.FileSystem, path string, opt GetFileOptions, maxContentsSize int64) (*FileWithRange, error) {
 	if fg, ok := fs.(FileGetter); ok {
 		return fg.GetFileWithOptions(path, opt)
 	}
@@ -166,9 +183,20 @@ func GetFileWithOptions(fs vfs.FileSystem, path string, opt GetFileOptions) (*Fi
 		if err != nil {
 			return nil, err
 		}
+		if opt.Glob != "" {
+			ee, err = filterTreeEntries(ee, "", opt.Glob)
+			if err != nil {
+				return nil, err
+			}
+		}
 		sort.Sort(TreeEntriesByTypeByName(ee))
 		e.Entries = ee
 	} else if fi.Mode().IsRegular() {
+		if maxContentsSize != 0 && fi.Size() > maxContentsSize && !opt.EntireFile && opt.FileRange == (FileRange{}) {
+			e.ContentsOmitted = true
+			return &fwr, nil
+		}
+
 		f, err := fs.Open(path)
 		if err != nil {
 			return nil, err
@@ -181,6 +209,7 @@ func GetFileWithOptions(fs vfs.FileSystem, path string, opt GetFileOptions) (*Fi
 		}
 
 		e.Contents = contents
+		e.setLFSPointer(contents)
 
 		if empty := (GetFileOptions{}); opt != empty {
 			fr, _, err := ComputeFileRange(contents, opt)
@@ -192,11 +221,122 @@ func GetFileWithOptions(fs vfs.FileSystem, path string, opt GetFileOptions) (*Fi
 			e.Contents = e.Contents[fr.StartByte:fr.EndByte]
 			fwr.FileRange = *fr
 		}
+		e.setEncoding()
+	} else if e.Type == SymlinkEntry {
+		// Opening a symlink reads its blob, whose contents are the
+		// link target.
+		f, err := fs.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		target, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		e.SymlinkTarget = string(target)
 	}
 
 	return &fwr, nil
 }
 
+// A FileStat is the result of statting a single path in a call to
+// StatMulti. At most one of its fields is set.
+type FileStat struct {
+	// Entry is the path's tree entry (without contents or
+	// sub-entries), if statting it succeeded.
+	Entry *TreeEntry `json:",omitempty"`
+
+	// NotExist is whether the path doesn't exist.
+	NotExist bool `json:",omitempty"`
+
+	// Error is the error message, if statting the path failed for
+	// another reason.
+	Error string `json:",omitempty"`
+}
+
+// NewFileStat returns the FileStat for the result of a call to Stat.
+func NewFileStat(fi os.FileInfo, err error) *FileStat {
+	switch {
+	case os.IsNotExist(err):
+		return &FileStat{NotExist: true}
+	case err != nil:
+		return &FileStat{Error: err.Error()}
+	}
+	return &FileStat{Entry: newTreeEntry(fi)}
+}
+
+// Stat returns the os.FileInfo or error (for the file at path)
+// described by s.
+func (s *FileStat) Stat(path string) (os.FileInfo, error) {
+	switch {
+	case s.NotExist:
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	case s.Error != "":
+		return nil, &os.PathError{Op: "stat", Path: path, Err: errors.New(s.Error)}
+	case s.Entry == nil:
+		return nil, &os.PathError{Op: "stat", Path: path, Err: errors.New("no tree entry")}
+	}
+	return s.Entry.Stat()
+}
+
+var _ vcs.MultiStater = &repositoryFS{}
+
+// StatMulti implements vcs.MultiStater. It stats all of the paths in
+// a single request.
+func (fs *repositoryFS) StatMulti(paths []string) ([]os.FileInfo, []error) {
+	fis := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	stats, err := fs.statMulti(paths)
+	if err == nil && len(stats) != len(paths) {
+		err = fmt.Errorf("got %d file stats for %d paths", len(stats), len(paths))
+	}
+	for i, path := range paths {
+		if err != nil {
+			errs[i] = err
+		} else {
+			fis[i], errs[i] = stats[i].Stat(path)
+		}
+	}
+	return fis, errs
+}
+
+func (fs *repositoryFS) statMulti(paths []string) ([]*FileStat, error) {
+	url, err := fs.repo.url(RouteRepoStatMulti, map[string]string{"CommitID": string(fs.at)}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := fs.repo.newRequest("POST", url.String(), paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*FileStat
+	if _, err := fs.repo.client.Do(req, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// StatMulti stats each of the paths in fs (see vcs.MultiStater). If
+// fs is a vcs.MultiStater, fs.StatMulti is called; otherwise Stat is
+// called for each path.
+func StatMulti(fs vfs.FileSystem, paths []string) ([]os.FileInfo, []error) {
+	if ms, ok := fs.(vcs.MultiStater); ok {
+		return ms.StatMulti(paths)
+	}
+
+	fis := make([]os.FileInfo, len(paths))
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		fis[i], errs[i] = fs.Stat(path)
+	}
+	return fis, errs
+}
+
 // readDir uses the passed vfs.FileSystem to read from starting at the base path.
 // If recurseSingleSubfolder is true, it will descend and include sub-folders
 // with a single sub-folder inside. first should always be set to true, other values are used internally.
@@ -231,6 +371,7 @@ func newTreeEntry(fi os.FileInfo) *TreeEntry {
 		Name:    fi.Name(),
 		Size:    fi.Size(),
 		ModTime: pbtypes.NewTimestamp(fi.ModTime()),
+		Mode:    fi.Mode(),
 	}
 	if fi.Mode().IsDir() {
 		e.Type = DirEntry
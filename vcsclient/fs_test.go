@@ -2,12 +2,14 @@ package vcsclient
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"reflect"
 	"testing"
 
+	"golang.org/x/tools/godoc/vfs"
 	"golang.org/x/tools/godoc/vfs/mapfs"
 	"sourcegraph.com/sqs/pbtypes"
 )
@@ -57,6 +59,51 @@ func TestRepository_FileSystem_Open(t *testing.T) {
 	}
 }
 
+func TestRepository_FileSystem_Open_contentsOmitted(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+	want := []byte("large")
+
+	var calls int
+	mux.HandleFunc(urlPath(t, RouteRepoTreeEntry, repo, map[string]string{"CommitID": "abcd", "Path": "f"}), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		testMethod(t, r, "GET")
+
+		if r.URL.Query().Get("EntireFile") == "true" {
+			writeJSON(w, &FileWithRange{TreeEntry: &TreeEntry{Contents: want, Encoding: UTF8Encoding}})
+		} else {
+			writeJSON(w, &TreeEntry{ContentsOmitted: true, Encoding: UTF8Encoding})
+		}
+	})
+
+	fs, err := repo.FileSystem("abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fs.Open("f")
+	if err != nil {
+		t.Fatalf("FileSystem.Open returned error: %v", err)
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("got %d requests, want 2", calls)
+	}
+
+	if !bytes.Equal(data, want) {
+		t.Errorf("FileSystem.Open returned contents %q, want %q", data, want)
+	}
+}
+
 func TestRepository_FileSystem_Lstat(t *testing.T) {
 	setup()
 	defer teardown()
@@ -173,6 +220,51 @@ func TestRepository_FileSystem_ReadDir(t *testing.T) {
 	}
 }
 
+func TestRepository_FileSystem_StatMulti(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+	entry := &TreeEntry{Name: "f", Size: 3}
+	wantFI, _ := entry.Stat()
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoStatMulti, repo, map[string]string{"CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+		testBody(t, r, `["f","x","y"]`+"\n")
+
+		writeJSON(w, []*FileStat{{Entry: entry}, {NotExist: true}, {Error: "e"}})
+	})
+
+	fs, err := repo.FileSystem("abcd")
+	if err != nil {
+		t.Errorf("Repository.FileSystem returned error: %v", err)
+		return
+	}
+
+	fis, errs := StatMulti(fs, []string{"f", "x", "y"})
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if errs[0] != nil {
+		t.Errorf("StatMulti returned error %v for f", errs[0])
+	}
+	if !reflect.DeepEqual(fis[0], wantFI) {
+		t.Errorf("StatMulti returned %+v for f, want %+v", fis[0], wantFI)
+	}
+	if !os.IsNotExist(errs[1]) {
+		t.Errorf("StatMulti returned error %v for x, want os.IsNotExist", errs[1])
+	}
+	if errs[2] == nil || os.IsNotExist(errs[2]) {
+		t.Errorf("StatMulti returned error %v for y, want a non-os.IsNotExist error", errs[2])
+	}
+}
+
 func TestRepository_FileSystem_Get(t *testing.T) {
 	setup()
 	defer teardown()
@@ -271,18 +363,21 @@ func TestGetFileWithOptions(t *testing.T) {
 			Name:    "a",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: nil,
 		},
 		{
 			Name:    "d",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: nil,
 		},
 		{
 			Name:    "g",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: nil,
 		},
 		{
@@ -290,6 +385,7 @@ func TestGetFileWithOptions(t *testing.T) {
 			Type:    FileEntry,
 			Size:    1,
 			ModTime: zeroTimestamp,
+			Mode:    0444,
 			Entries: nil,
 		},
 	}
@@ -310,14 +406,17 @@ func TestGetFileWithOptions_recurseSingleSubfolder(t *testing.T) {
 			Name:    "a",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: []*TreeEntry{{
 				Name:    "b",
 				Type:    DirEntry,
 				ModTime: zeroTimestamp,
+				Mode:    os.ModeDir | 0755,
 				Entries: []*TreeEntry{{
 					Name:    "c",
 					Type:    DirEntry,
 					ModTime: zeroTimestamp,
+					Mode:    os.ModeDir | 0755,
 					Entries: nil,
 				}},
 			}},
@@ -326,12 +425,14 @@ func TestGetFileWithOptions_recurseSingleSubfolder(t *testing.T) {
 			Name:    "d",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: nil,
 		},
 		{
 			Name:    "g",
 			Type:    DirEntry,
 			ModTime: zeroTimestamp,
+			Mode:    os.ModeDir | 0755,
 			Entries: nil,
 		},
 		{
@@ -339,6 +440,7 @@ func TestGetFileWithOptions_recurseSingleSubfolder(t *testing.T) {
 			Type:    FileEntry,
 			Size:    1,
 			ModTime: zeroTimestamp,
+			Mode:    0444,
 			Entries: nil,
 		},
 	}
@@ -352,3 +454,43 @@ func TestGetFileWithOptions_recurseSingleSubfolder(t *testing.T) {
 		t.Errorf("GetFileWithOptions returned:\n%+v\nwant:\n%+v", e.Entries, want)
 	}
 }
+
+// noOpenFS is a vfs.FileSystem whose files can be statted but not
+// opened.
+type noOpenFS struct{ vfs.FileSystem }
+
+func (noOpenFS) Open(name string) (vfs.ReadSeekCloser, error) {
+	return nil, errors.New("opened " + name)
+}
+
+func TestGetFileWithMaxContentsSize(t *testing.T) {
+	// Contents larger than the limit are omitted without opening the
+	// file.
+	e, err := GetFileWithMaxContentsSize(noOpenFS{testGetFileWithOptionsFS}, "d/e.txt", GetFileOptions{}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.ContentsOmitted || e.Contents != nil || e.Size != int64(len("e in folder d")) {
+		t.Errorf("got entry %+v, want contents omitted and size %d", e.TreeEntry, len("e in folder d"))
+	}
+
+	// Smaller files and explicitly requested contents are returned.
+	for _, test := range []struct {
+		path string
+		opt  GetFileOptions
+		want string
+	}{
+		{"f.txt", GetFileOptions{}, "f"},
+		{"d/e.txt", GetFileOptions{EntireFile: true}, "e in folder d"},
+		{"d/e.txt", GetFileOptions{FileRange: FileRange{StartByte: 2, EndByte: 4}}, "in"},
+	} {
+		e, err := GetFileWithMaxContentsSize(testGetFileWithOptionsFS, test.path, test.opt, 5)
+		if err != nil {
+			t.Errorf("%s %+v: %s", test.path, test.opt, err)
+			continue
+		}
+		if e.ContentsOmitted || string(e.Contents) != test.want {
+			t.Errorf("%s %+v: got contents %q (omitted %v), want %q", test.path, test.opt, e.Contents, e.ContentsOmitted, test.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.Fscker = (*repository)(nil)
+
+func (r *repository) Fsck() (vcs.FsckReport, error) {
+	url, err := r.url(RouteRepoFsck, nil, nil)
+	if err != nil {
+		return vcs.FsckReport{}, err
+	}
+
+	req, err := r.newRequest("POST", url.String(), nil)
+	if err != nil {
+		return vcs.FsckReport{}, err
+	}
+
+	var report vcs.FsckReport
+	if _, err := r.client.Do(req, &report); err != nil {
+		return vcs.FsckReport{}, knownErrorOr(err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,43 @@
+package vcsclient
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_Fsck(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := vcs.FsckReport{Problems: []*vcs.FsckProblem{
+		{Kind: vcs.FsckDangling, ObjectType: "commit", ObjectID: "abcd", Message: "dangling commit abcd"},
+	}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoFsck, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+
+		writeJSON(w, want)
+	})
+
+	report, err := repo.Fsck()
+	if err != nil {
+		t.Errorf("Repository.Fsck returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Repository.Fsck returned %+v, want %+v", report, want)
+	}
+}
@@ -0,0 +1,20 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.GarbageCollector = (*repository)(nil)
+
+func (r *repository) GC(opt vcs.GCOptions) error {
+	url, err := r.url(RouteRepoGC, nil, opt)
+	if err != nil {
+		return err
+	}
+
+	req, err := r.newRequest("POST", url.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Do(req, nil)
+	return knownErrorOr(err)
+}
@@ -0,0 +1,33 @@
+package vcsclient
+
+import (
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_GC(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoGC, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+		testFormValues(t, r, values{"Aggressive": "true"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := repo.GC(vcs.GCOptions{Aggressive: true}); err != nil {
+		t.Errorf("Repository.GC returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
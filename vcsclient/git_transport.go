@@ -1,7 +1,10 @@
 package vcsclient
 
 import (
-	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -18,8 +21,8 @@ type gitTransport struct {
 
 var _ git.GitTransport = (*gitTransport)(nil)
 
-func (t *gitTransport) InfoRefs(w io.Writer, service string) error {
-	rp := &repository{client: t.client, repoPath: t.repoPath}
+func (t *gitTransport) InfoRefs(ctx context.Context, w io.Writer, service string) error {
+	rp := &repository{client: t.client, repoPath: t.repoPath, ctx: ctx}
 	urlQuery := struct {
 		Service string `url:"service"`
 	}{
@@ -31,72 +34,91 @@ func (t *gitTransport) InfoRefs(w io.Writer, service string) error {
 	}
 	u = t.client.BaseURL.ResolveReference(u)
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", "git/1.9.1") // TODO: kludge
-	var out bytes.Buffer
-	_, err = t.client.Do(req, &out)
-	if err != nil {
-		return err
-	}
-
-	_, err = io.Copy(w, &out)
-	if err != nil {
-		return err
-	}
-	return nil
+	_, err = t.client.Do(req, w)
+	return err
 }
 
-func (t *gitTransport) ReceivePack(w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
-	rp := &repository{client: t.client, repoPath: t.repoPath}
+func (t *gitTransport) ReceivePack(ctx context.Context, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
+	rp := &repository{client: t.client, repoPath: t.repoPath, ctx: ctx}
 	u, err := rp.url(git.RouteGitReceivePack, nil, nil)
 	if err != nil {
 		return err
 	}
 	u = t.client.BaseURL.ResolveReference(u)
 
-	req, err := http.NewRequest("POST", u.String(), rdr)
+	body, err := encodeBody(rdr, opt.ContentEncoding)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("User-Agent", "git/1.9.1") // TODO: kludge
-	req.Header.Set("content-encoding", opt.ContentEncoding)
-
-	var out bytes.Buffer
-	_, err = t.client.Do(req, &out)
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), body)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("User-Agent", "git/1.9.1") // TODO: kludge
+	req.Header.Set("content-encoding", opt.ContentEncoding)
 
-	_, err = io.Copy(w, &out)
-
-	return nil
+	_, err = t.client.Do(req, w)
+	return err
 }
 
-func (t *gitTransport) UploadPack(w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
-	rp := &repository{client: t.client, repoPath: t.repoPath}
+func (t *gitTransport) UploadPack(ctx context.Context, w io.Writer, rdr io.Reader, opt git.GitTransportOpt) error {
+	rp := &repository{client: t.client, repoPath: t.repoPath, ctx: ctx}
 	u, err := rp.url(git.RouteGitUploadPack, nil, nil)
 	if err != nil {
 		return err
 	}
 	u = t.client.BaseURL.ResolveReference(u)
 
-	req, err := http.NewRequest("POST", u.String(), rdr)
+	body, err := encodeBody(rdr, opt.ContentEncoding)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("User-Agent", "git/1.9.1") // TODO: kludge
-	req.Header.Set("content-encoding", opt.ContentEncoding)
-
-	var out bytes.Buffer
-	_, err = t.client.Do(req, &out)
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), body)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("User-Agent", "git/1.9.1") // TODO: kludge
+	req.Header.Set("content-encoding", opt.ContentEncoding)
 
-	_, err = io.Copy(w, &out)
+	_, err = t.client.Do(req, w)
+	return err
+}
+
+// encodeBody returns a reader that compresses rdr with the given
+// content encoding ("gzip", "deflate", or "" for none) as it is read,
+// so that large packs are streamed instead of buffered.
+func encodeBody(rdr io.Reader, encoding string) (io.Reader, error) {
+	var newWriter func(io.Writer) io.WriteCloser
+	switch encoding {
+	case "":
+		return rdr, nil
+	case "gzip":
+		newWriter = func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	case "deflate":
+		newWriter = func(w io.Writer) io.WriteCloser {
+			zw, _ := flate.NewWriter(w, flate.DefaultCompression) // only errors on invalid level
+			return zw
+		}
+	default:
+		return nil, fmt.Errorf("unsupported git request content encoding %q", encoding)
+	}
 
-	return nil
+	// The HTTP client closes the pipe reader when it's done with the
+	// request body (even if it fails), which makes any blocked write
+	// fail and ends the goroutine.
+	pr, pw := io.Pipe()
+	go func() {
+		zw := newWriter(pw)
+		_, err := io.Copy(zw, rdr)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
 }
@@ -2,9 +2,12 @@ package vcsclient
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"runtime"
 	"testing"
 
 	"strings"
@@ -74,7 +77,7 @@ func Test_gitTransport_InfoRefs(t *testing.T) {
 			})
 
 			var buf bytes.Buffer
-			err = gitTransport.InfoRefs(&buf, test.service)
+			err = gitTransport.InfoRefs(context.Background(), &buf, test.service)
 			if err != nil {
 				t.Errorf("unexpected error calling gitTransport.InfoRefs: %s", err)
 			}
@@ -127,7 +130,7 @@ func Test_gitTransport_ReceivePack(t *testing.T) {
 
 	var out bytes.Buffer
 	in := bytes.NewReader([]byte(expIn))
-	err = gitTransport.ReceivePack(&out, in, opt)
+	err = gitTransport.ReceivePack(context.Background(), &out, in, opt)
 	if err != nil {
 		t.Fatalf("unexpected error calling gitTransport.ReceivePack: %s", err)
 	}
@@ -182,7 +185,7 @@ func Test_gitTransport_UploadPack(t *testing.T) {
 
 	var out bytes.Buffer
 	in := bytes.NewReader([]byte(expIn))
-	err = gitTransport.UploadPack(&out, in, opt)
+	err = gitTransport.UploadPack(context.Background(), &out, in, opt)
 	if err != nil {
 		t.Fatalf("unexpected error calling gitTransport.UploadPack: %s", err)
 	}
@@ -195,3 +198,57 @@ func Test_gitTransport_UploadPack(t *testing.T) {
 		t.Errorf("expected output \"%s\" but got \"%s\"", expOut, string(out.Bytes()))
 	}
 }
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func Test_gitTransport_ReceivePack_streaming(t *testing.T) {
+	setup()
+	defer teardown()
+
+	// Larger than the memory the client may use.
+	const size = 64 << 20
+
+	gitTransport, err := vcsclient.GitTransport("a.b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux.HandleFunc("/a.b/c/.git/git-receive-pack", func(w http.ResponseWriter, r *http.Request) {
+		if n, err := io.Copy(ioutil.Discard, r.Body); err != nil || n != size {
+			t.Errorf("got request body of %d bytes (err %v), want %d", n, err, size)
+		}
+		io.Copy(w, io.LimitReader(zeroReader{}, size))
+	})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var out countingWriter
+	err = gitTransport.ReceivePack(context.Background(), &out, io.LimitReader(zeroReader{}, size), git.GitTransportOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != size {
+		t.Errorf("got %d bytes of output, want %d", out, size)
+	}
+
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > size/4 {
+		t.Errorf("got %d bytes allocated for a %d-byte push, want the pack streamed", alloc, size)
+	}
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
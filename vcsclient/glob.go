@@ -0,0 +1,70 @@
+package vcsclient
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether name matches the slash-separated glob
+// pattern. Each pattern element is matched against the corresponding
+// name element using path.Match, so "*" matches any sequence of
+// non-slash characters, "?" matches any single non-slash character,
+// and character classes ("[a-z]") are supported. In addition, a "**"
+// element matches zero or more whole name elements (e.g., "**/*.go"
+// matches "a.go" and "b/c/d.go").
+//
+// The only possible returned error is path.ErrBadPattern, when
+// pattern is malformed.
+func MatchGlob(pattern, name string) (bool, error) {
+	return matchGlobElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobElems(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest of the pattern against every
+			// suffix of name (including the empty suffix).
+			for i := 0; i <= len(name); i++ {
+				if ok, err := matchGlobElems(pattern[1:], name[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// filterTreeEntries returns the entries whose path (relative to the
+// listed directory, with prefix prepended) matches pattern. A
+// directory entry that doesn't match is kept if any of its (already
+// expanded) sub-entries match.
+func filterTreeEntries(entries []*TreeEntry, prefix, pattern string) ([]*TreeEntry, error) {
+	var filtered []*TreeEntry
+	for _, e := range entries {
+		name := path.Join(prefix, e.Name)
+		match, err := MatchGlob(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if e.Type == DirEntry && len(e.Entries) > 0 {
+			e.Entries, err = filterTreeEntries(e.Entries, name, pattern)
+			if err != nil {
+				return nil, err
+			}
+			match = match || len(e.Entries) > 0
+		}
+		if match {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,37 @@
+package vcsclient
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+		wantErr       error
+	}{
+		{"*.go", "a.go", true, nil},
+		{"*.go", "a.txt", false, nil},
+		{"*.go", "b/a.go", false, nil},
+		{"?.go", "a.go", true, nil},
+		{"?.go", "ab.go", false, nil},
+		{"b/*.go", "b/a.go", true, nil},
+		{"**/*.go", "a.go", true, nil},
+		{"**/*.go", "b/c/a.go", true, nil},
+		{"b/**", "b/c/a.go", true, nil},
+		{"b/**/a.go", "b/a.go", true, nil},
+		{"b/**/a.go", "c/a.go", false, nil},
+		{"[", "a", false, path.ErrBadPattern},
+	}
+	for _, test := range tests {
+		got, err := MatchGlob(test.pattern, test.name)
+		if err != test.wantErr {
+			t.Errorf("MatchGlob(%q, %q): got error %v, want %v", test.pattern, test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("MatchGlob(%q, %q): got %v, want %v", test.pattern, test.name, got, test.want)
+		}
+	}
+}
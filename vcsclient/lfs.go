@@ -0,0 +1,64 @@
+package vcsclient
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// lfsPointerVersionPrefix is the prefix of the first line of every
+// Git LFS pointer file.
+const lfsPointerVersionPrefix = "version https://git-lfs."
+
+// lfsPointerMaxSize is the maximum size of a Git LFS pointer file,
+// per the Git LFS spec. Larger files are never treated as pointers.
+const lfsPointerMaxSize = 1024
+
+// LFSPointer describes a Git LFS pointer file, which is committed to
+// the repository in place of the real file contents.
+type LFSPointer struct {
+	OID  string // object ID of the real contents (e.g., "sha256:...")
+	Size int64  // size in bytes of the real contents
+}
+
+// ParseLFSPointer parses data as a Git LFS pointer file. If data is
+// not a valid pointer, it returns nil.
+func ParseLFSPointer(data []byte) *LFSPointer {
+	if len(data) > lfsPointerMaxSize || !bytes.HasPrefix(data, []byte(lfsPointerVersionPrefix)) {
+		return nil
+	}
+
+	var p LFSPointer
+	var hasSize bool
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for _, line := range lines[1:] {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil
+		}
+		switch key, val := parts[0], parts[1]; key {
+		case "oid":
+			p.OID = val
+		case "size":
+			size, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || size < 0 {
+				return nil
+			}
+			p.Size, hasSize = size, true
+		}
+	}
+	if p.OID == "" || !hasSize {
+		return nil
+	}
+	return &p
+}
+
+// setLFSPointer marks e as a Git LFS pointer if contents is a valid
+// pointer file.
+func (e *TreeEntry) setLFSPointer(contents []byte) {
+	if p := ParseLFSPointer(contents); p != nil {
+		e.LFS = true
+		e.LFSOID = p.OID
+		e.LFSSize = p.Size
+	}
+}
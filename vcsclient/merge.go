@@ -7,8 +7,10 @@ import (
 )
 
 var (
-	_ vcs.Merger          = (*repository)(nil)
-	_ vcs.CrossRepoMerger = (*repository)(nil)
+	_ vcs.Merger             = (*repository)(nil)
+	_ vcs.CrossRepoMerger    = (*repository)(nil)
+	_ vcs.AncestorChecker    = (*repository)(nil)
+	_ vcs.AheadBehindCounter = (*repository)(nil)
 )
 
 func (r *repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
@@ -17,7 +19,7 @@ func (r *repository) MergeBase(a, b vcs.CommitID) (vcs.CommitID, error) {
 		return "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return "", err
 	}
@@ -43,7 +45,7 @@ func (r *repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b
 		return "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return "", err
 	}
@@ -55,3 +57,41 @@ func (r *repository) CrossRepoMergeBase(a vcs.CommitID, repoB vcs.Repository, b
 
 	return r.parseCommitIDInURL(resp.Header.Get("location"))
 }
+
+func (r *repository) IsAncestor(a, b vcs.CommitID) (bool, error) {
+	url, err := r.url(RouteRepoIsAncestor, map[string]string{"CommitIDA": string(a), "CommitIDB": string(b)}, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	var isAncestor bool
+	if _, err := r.client.Do(req, &isAncestor); err != nil {
+		return false, err
+	}
+
+	return isAncestor, nil
+}
+
+func (r *repository) AheadBehind(base, head vcs.CommitID) (ahead, behind int, err error) {
+	url, err := r.url(RouteRepoAheadBehind, map[string]string{"Base": string(base), "Head": string(head)}, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counts vcs.BehindAhead
+	if _, err := r.client.Do(req, &counts); err != nil {
+		return 0, 0, err
+	}
+
+	return int(counts.Ahead), int(counts.Behind), nil
+}
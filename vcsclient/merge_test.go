@@ -73,3 +73,63 @@ func TestRepository_CrossRepoMergeBase(t *testing.T) {
 		t.Errorf("Repository.CrossRepoMergeBase returned %+v, want %+v", commitID, want)
 	}
 }
+
+func TestRepository_IsAncestor(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoIsAncestor, repo, map[string]string{"RepoPath": repoPath, "CommitIDA": "a", "CommitIDB": "b"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, true)
+	})
+
+	isAncestor, err := repo.IsAncestor("a", "b")
+	if err != nil {
+		t.Errorf("Repository.IsAncestor returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !isAncestor {
+		t.Errorf("Repository.IsAncestor returned false, want true")
+	}
+}
+
+func TestRepository_AheadBehind(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoAheadBehind, repo, map[string]string{"RepoPath": repoPath, "Base": "a", "Head": "b"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, vcs.BehindAhead{Behind: 1, Ahead: 2})
+	})
+
+	ahead, behind, err := repo.AheadBehind("a", "b")
+	if err != nil {
+		t.Errorf("Repository.AheadBehind returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if ahead != 2 || behind != 1 {
+		t.Errorf("Repository.AheadBehind returned ahead %d, behind %d, want ahead 2, behind 1", ahead, behind)
+	}
+}
@@ -0,0 +1,70 @@
+package vcsclient
+
+import (
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-msgpack/codec"
+)
+
+// MsgpackContentType is the media type of msgpack-encoded API
+// responses. The server encodes responses as msgpack (instead of
+// JSON) if the request's Accept header prefers it.
+const MsgpackContentType = "application/msgpack"
+
+// acceptHeader is the Accept header sent with API requests. It
+// prefers msgpack (which is faster to decode), but servers that don't
+// support it respond with JSON.
+const acceptHeader = MsgpackContentType + ", application/json;q=0.9"
+
+// msgpackHandle is the msgpack encoding configuration shared by the
+// client and server.
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true, RawToString: true}
+
+// EncodeMsgpack writes the msgpack encoding of v to w.
+func EncodeMsgpack(w io.Writer, v interface{}) error {
+	return codec.NewEncoder(w, msgpackHandle).Encode(v)
+}
+
+// DecodeMsgpack reads the msgpack-encoded value from r and stores it
+// in the value pointed to by v.
+func DecodeMsgpack(r io.Reader, v interface{}) error {
+	return codec.NewDecoder(r, msgpackHandle).Decode(v)
+}
+
+// AcceptsMsgpack returns whether the Accept header value accept
+// prefers msgpack to JSON.
+func AcceptsMsgpack(accept string) bool {
+	msgpackQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case MsgpackContentType, "application/x-msgpack":
+			if q > msgpackQ {
+				msgpackQ = q
+			}
+		case "application/json", "application/*", "*/*":
+			if q > jsonQ {
+				jsonQ = q
+			}
+		}
+	}
+	return msgpackQ > 0 && msgpackQ >= jsonQ
+}
+
+// isMsgpack returns whether contentType is the msgpack media type.
+func isMsgpack(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == MsgpackContentType || mediaType == "application/x-msgpack")
+}
@@ -0,0 +1,120 @@
+package vcsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sqs/pbtypes"
+)
+
+func TestAcceptsMsgpack(t *testing.T) {
+	tests := map[string]bool{
+		"":                                      false,
+		"application/json":                      false,
+		"*/*":                                   false,
+		"application/msgpack":                   true,
+		"application/x-msgpack":                 true,
+		acceptHeader:                            true,
+		"application/json, application/msgpack": true,
+		"application/msgpack;q=0.5, application/json": false,
+		"application/msgpack;q=0":                     false,
+	}
+	for accept, want := range tests {
+		if got := AcceptsMsgpack(accept); got != want {
+			t.Errorf("%q: got %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestRepository_Commits_msgpack(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := makeCommits(2)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommits, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if !AcceptsMsgpack(r.Header.Get("accept")) {
+			t.Errorf("got Accept %q, want msgpack preferred", r.Header.Get("accept"))
+		}
+		w.Header().Set("content-type", MsgpackContentType)
+		w.Header().Set(TotalCommitsHeader, "2")
+		if err := EncodeMsgpack(w, want); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	commits, total, err := repo.Commits(vcs.CommitsOptions{Head: "abcd"})
+	if err != nil {
+		t.Fatalf("Repository.Commits returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if total != 2 {
+		t.Errorf("got total %d, want 2", total)
+	}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("Repository.Commits returned %+v, want %+v", commits, want)
+	}
+}
+
+func makeCommits(n int) []*vcs.Commit {
+	commits := make([]*vcs.Commit, n)
+	for i := range commits {
+		id := vcs.CommitID(fmt.Sprintf("%040x", i))
+		sig := vcs.Signature{Name: "a", Email: "a@a.com", Date: pbtypes.NewTimestamp(time.Unix(int64(i), 0).UTC())}
+		commits[i] = &vcs.Commit{
+			ID:        id,
+			Author:    sig,
+			Committer: &sig,
+			Message:   fmt.Sprintf("commit %d\n\nThis is the body of commit %d.", i, i),
+			Parents:   []vcs.CommitID{id},
+		}
+	}
+	return commits
+}
+
+func benchmarkCommits(b *testing.B, encode func(*bytes.Buffer, interface{}) error, decode func(*bytes.Buffer, interface{}) error) {
+	commits := makeCommits(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := encode(&buf, commits); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(buf.Len()))
+		var decoded []*vcs.Commit
+		if err := decode(&buf, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommits_JSON(b *testing.B) {
+	benchmarkCommits(b,
+		func(buf *bytes.Buffer, v interface{}) error { return json.NewEncoder(buf).Encode(v) },
+		func(buf *bytes.Buffer, v interface{}) error { return json.NewDecoder(buf).Decode(v) },
+	)
+}
+
+func BenchmarkCommits_Msgpack(b *testing.B) {
+	benchmarkCommits(b,
+		func(buf *bytes.Buffer, v interface{}) error { return EncodeMsgpack(buf, v) },
+		func(buf *bytes.Buffer, v interface{}) error { return DecodeMsgpack(buf, v) },
+	)
+}
@@ -0,0 +1,30 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+// NotesOptions specifies the notes ref to read a commit's note from
+// (see (vcs.NotesGetter).GetNotes).
+type NotesOptions struct {
+	Ref string `url:",omitempty"` // notes ref (such as "commits" for refs/notes/commits, the default)
+}
+
+var _ vcs.NotesGetter = (*repository)(nil)
+
+func (r *repository) GetNotes(commitID vcs.CommitID, ref string) (string, error) {
+	url, err := r.url(RouteRepoCommitNotes, map[string]string{"CommitID": string(commitID)}, &NotesOptions{Ref: ref})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var note string
+	if _, err := r.client.Do(req, &note); err != nil {
+		return "", knownErrorOr(err)
+	}
+
+	return note, nil
+}
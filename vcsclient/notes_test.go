@@ -0,0 +1,59 @@
+package vcsclient
+
+import (
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_GetNotes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := "build: passed"
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommitNotes, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Ref": "ci"})
+
+		writeJSON(w, want)
+	})
+
+	note, err := repo.GetNotes("abcd", "ci")
+	if err != nil {
+		t.Errorf("Repository.GetNotes returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if note != want {
+		t.Errorf("Repository.GetNotes returned %q, want %q", note, want)
+	}
+}
+
+func TestRepository_GetNotes_noteNotFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoCommitNotes, repo, map[string]string{"RepoPath": repoPath, "CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrNoteNotFound.Error()})
+	})
+
+	if _, err := repo.GetNotes("abcd", ""); err != vcs.ErrNoteNotFound {
+		t.Errorf("Repository.GetNotes returned error %v, want %v", err, vcs.ErrNoteNotFound)
+	}
+}
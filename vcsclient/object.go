@@ -0,0 +1,44 @@
+package vcsclient
+
+import (
+	"io"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// ObjectTypeHeader is the name of the HTTP header that contains the
+// type ("blob", "tree", "commit", or "tag") of the object returned by
+// GetObject.
+const ObjectTypeHeader = "x-vcsstore-object-type"
+
+var _ vcs.ObjectGetter = (*repository)(nil)
+
+// GetObject returns the type and contents of the object with the
+// given full object ID. The contents are streamed from the server, so
+// the caller must close them.
+func (r *repository) GetObject(id string) (string, io.ReadCloser, error) {
+	if !vcs.ValidObjectID(id) {
+		return "", nil, vcs.ErrInvalidObjectID
+	}
+
+	url, err := r.url(RouteRepoObject, map[string]string{"ObjectID": id}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, attempts, err := r.client.send(r.client.httpClient, req)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := checkResponse(resp, false, attempts); err != nil {
+		resp.Body.Close()
+		return "", nil, knownErrorOr(err)
+	}
+
+	return resp.Header.Get(ObjectTypeHeader), resp.Body, nil
+}
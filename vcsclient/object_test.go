@@ -0,0 +1,61 @@
+package vcsclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_GetObject(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	id := strings.Repeat("a", 40)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoObject, repo, map[string]string{"RepoPath": repoPath, "ObjectID": id}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		w.Header().Set(ObjectTypeHeader, "blob")
+		w.Write([]byte("hello"))
+	})
+
+	typ, contents, err := repo.GetObject(id)
+	if err != nil {
+		t.Fatalf("Repository.GetObject returned error: %v", err)
+	}
+	defer contents.Close()
+	data, err := ioutil.ReadAll(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if typ != "blob" {
+		t.Errorf("Repository.GetObject returned type %q, want %q", typ, "blob")
+	}
+	if string(data) != "hello" {
+		t.Errorf("Repository.GetObject returned contents %q, want %q", data, "hello")
+	}
+}
+
+func TestRepository_GetObject_invalidID(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repo, _ := vcsclient.Repository("a.b/c")
+	if _, _, err := repo.(vcs.ObjectGetter).GetObject("HEAD"); err != vcs.ErrInvalidObjectID {
+		t.Errorf("got error %v, want %v", err, vcs.ErrInvalidObjectID)
+	}
+}
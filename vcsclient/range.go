@@ -9,7 +9,8 @@ import (
 // ComputeFileRange determines the actual file range according to the
 // input range parameter. For example, if input has a line range set,
 // the returned FileRange will contain the byte range that corresponds
-// to the input line range.
+// to the input line range. If both a line range and a byte range are
+// set, the line range is used and the byte range is ignored.
 func ComputeFileRange(data []byte, opt GetFileOptions) (*FileRange, *fileset.File, error) {
 	fr := opt.FileRange // alias for brevity
 
@@ -25,7 +26,9 @@ func ComputeFileRange(data []byte, opt GetFileOptions) (*FileRange, *fileset.Fil
 	lines := fr.StartLine != 0 || fr.EndLine != 0
 	bytes := fr.StartByte != 0 || fr.EndByte != 0
 	if lines && bytes {
-		return nil, nil, fmt.Errorf("must specify a line range OR a byte range, not both (%+v)", fr)
+		// Prefer the line range.
+		fr.StartByte, fr.EndByte = 0, 0
+		bytes = false
 	}
 
 	// TODO(sqs): fix up the sketchy int conversions
@@ -48,6 +48,11 @@ func TestComputeFileRange(t *testing.T) {
 			opt:  GetFileOptions{FileRange: FileRange{StartLine: 2, EndLine: 2}},
 			want: FileRange{StartLine: 2, EndLine: 2, StartByte: 2, EndByte: 4},
 		},
+		"3 lines, line and byte range": {
+			data: []byte("a\nb\nc\n"),
+			opt:  GetFileOptions{FileRange: FileRange{StartLine: 2, EndLine: 3, StartByte: 0, EndByte: 1}},
+			want: FileRange{StartLine: 2, EndLine: 3, StartByte: 2, EndByte: 6},
+		},
 	}
 	for label, test := range tests {
 		got, _, err := ComputeFileRange(test.data, test.opt)
@@ -0,0 +1,36 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.RefEditor = (*repository)(nil)
+
+func (r *repository) CreateBranch(name string, commit vcs.CommitID) error {
+	return r.editRef("POST", RouteRepoCreateBranch, map[string]string{"Branch": name}, commit)
+}
+
+func (r *repository) DeleteBranch(name string) error {
+	return r.editRef("DELETE", RouteRepoDeleteBranch, map[string]string{"Branch": name}, nil)
+}
+
+func (r *repository) CreateTag(name string, commit vcs.CommitID) error {
+	return r.editRef("POST", RouteRepoCreateTag, map[string]string{"Tag": name}, commit)
+}
+
+func (r *repository) DeleteTag(name string) error {
+	return r.editRef("DELETE", RouteRepoDeleteTag, map[string]string{"Tag": name}, nil)
+}
+
+func (r *repository) editRef(method, routeName string, routeVars map[string]string, body interface{}) error {
+	url, err := r.url(routeName, routeVars, nil)
+	if err != nil {
+		return err
+	}
+
+	req, err := r.newRequest(method, url.String(), body)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Do(req, nil)
+	return knownErrorOr(err)
+}
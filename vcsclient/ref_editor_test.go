@@ -0,0 +1,87 @@
+package vcsclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_CreateBranch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCreateBranch, repo, map[string]string{"RepoPath": repoPath, "Branch": "b"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+
+		var commitID vcs.CommitID
+		if err := json.NewDecoder(r.Body).Decode(&commitID); err != nil {
+			t.Fatal(err)
+		}
+		if commitID != "abcd" {
+			t.Errorf("got commit ID %q, want %q", commitID, "abcd")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if err := repo.CreateBranch("b", "abcd"); err != nil {
+		t.Errorf("Repository.CreateBranch returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
+
+func TestRepository_CreateBranch_exists(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoCreateBranch, repo, map[string]string{"RepoPath": repoPath, "Branch": "b"}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrRefExists.Error()})
+	})
+
+	err := repo.CreateBranch("b", "abcd")
+	if err != vcs.ErrRefExists {
+		t.Errorf("Repository.CreateBranch returned error %v, want %v", err, vcs.ErrRefExists)
+	}
+	if IsNotFound(err) {
+		t.Errorf("got IsNotFound(%v) == true, want false", err)
+	}
+}
+
+func TestRepository_DeleteTag(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoDeleteTag, repo, map[string]string{"RepoPath": repoPath, "Tag": "v1"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "DELETE")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := repo.DeleteTag("v1"); err != nil {
+		t.Errorf("Repository.DeleteTag returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
@@ -0,0 +1,68 @@
+package vcsclient
+
+import (
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+// Reflog is the reflog of a ref (see (vcs.Reflogger).Reflog).
+type Reflog struct {
+	Entries []*vcs.ReflogEntry `json:",omitempty"`
+
+	// NoReflog is whether the repository has no reflog for the ref
+	// (for example, because it's a bare mirror clone, which doesn't
+	// record reflogs). If set, Entries is empty.
+	NoReflog bool `json:",omitempty"`
+}
+
+// RevisionAtOpt specifies the time at which to resolve a ref (see
+// (vcs.RevisionAtResolver).ResolveRevisionAt).
+type RevisionAtOpt struct {
+	At time.Time
+}
+
+var _ vcs.Reflogger = (*repository)(nil)
+
+func (r *repository) Reflog(ref string) ([]*vcs.ReflogEntry, error) {
+	url, err := r.url(RouteRepoReflog, map[string]string{"Ref": ref}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var reflog Reflog
+	if _, err := r.client.Do(req, &reflog); err != nil {
+		return nil, knownErrorOr(err)
+	}
+
+	if reflog.NoReflog {
+		return nil, vcs.ErrNoReflog
+	}
+	return reflog.Entries, nil
+}
+
+var _ vcs.RevisionAtResolver = (*repository)(nil)
+
+func (r *repository) ResolveRevisionAt(ref string, t time.Time) (vcs.CommitID, error) {
+	url, err := r.url(RouteRepoRevisionAt, map[string]string{"Ref": ref}, &RevisionAtOpt{At: t})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.doIgnoringRedirects(req)
+	if err != nil {
+		return "", knownErrorOr(err)
+	}
+
+	return r.parseCommitIDInURL(resp.Header.Get("location"))
+}
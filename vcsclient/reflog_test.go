@@ -0,0 +1,116 @@
+package vcsclient
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_Reflog(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.ReflogEntry{
+		{Old: "abcd", New: "ef01", Actor: vcs.Signature{Name: "a", Email: "a@a.com"}, Action: "commit", Message: "foo"},
+	}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoReflog, repo, map[string]string{"RepoPath": repoPath, "Ref": "refs/heads/master"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, &Reflog{Entries: want})
+	})
+
+	entries, err := repo.Reflog("refs/heads/master")
+	if err != nil {
+		t.Errorf("Repository.Reflog returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Repository.Reflog returned %+v, want %+v", entries, want)
+	}
+}
+
+func TestRepository_Reflog_noReflog(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoReflog, repo, map[string]string{"RepoPath": repoPath, "Ref": "master"}), func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, &Reflog{NoReflog: true})
+	})
+
+	entries, err := repo.Reflog("master")
+	if err != vcs.ErrNoReflog {
+		t.Errorf("Repository.Reflog returned error %v, want %v", err, vcs.ErrNoReflog)
+	}
+	if entries != nil {
+		t.Errorf("Repository.Reflog returned %+v, want nil", entries)
+	}
+}
+
+func TestRepository_ResolveRevisionAt(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	at := time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoRevisionAt, repo, map[string]string{"RepoPath": repoPath, "Ref": "refs/heads/master"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"At": "2006-01-02T15:04:05-07:00"})
+
+		http.Redirect(w, r, urlPath(t, RouteRepoCommit, repo, map[string]string{"CommitID": "abcd"}), http.StatusFound)
+	})
+
+	commitID, err := repo.ResolveRevisionAt("refs/heads/master", at)
+	if err != nil {
+		t.Errorf("Repository.ResolveRevisionAt returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if want := vcs.CommitID("abcd"); commitID != want {
+		t.Errorf("Repository.ResolveRevisionAt returned %+v, want %+v", commitID, want)
+	}
+}
+
+func TestRepository_ResolveRevisionAt_reflogTooShort(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoRevisionAt, repo, map[string]string{"RepoPath": repoPath, "Ref": "master"}), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrReflogTooShort.Error()})
+	})
+
+	if _, err := repo.ResolveRevisionAt("master", time.Unix(0, 0)); err != vcs.ErrReflogTooShort {
+		t.Errorf("Repository.ResolveRevisionAt returned error %v, want %v", err, vcs.ErrReflogTooShort)
+	}
+}
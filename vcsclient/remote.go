@@ -0,0 +1,51 @@
+package vcsclient
+
+import "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+var _ vcs.RemoteManager = (*repository)(nil)
+
+func (r *repository) AddRemote(name, url string) error {
+	return r.editRemote("PUT", RouteRepoAddRemote, name, url)
+}
+
+func (r *repository) RemoveRemote(name string) error {
+	return r.editRemote("DELETE", RouteRepoRemoveRemote, name, nil)
+}
+
+func (r *repository) Fetch(remote string, opt vcs.RemoteOpts) error {
+	return r.editRemote("POST", RouteRepoFetchRemote, remote, opt)
+}
+
+func (r *repository) ListRemotes() ([]*vcs.Remote, error) {
+	url, err := r.url(RouteRepoRemotes, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var remotes []*vcs.Remote
+	if _, err := r.client.Do(req, &remotes); err != nil {
+		return nil, err
+	}
+
+	return remotes, nil
+}
+
+func (r *repository) editRemote(method, routeName, name string, body interface{}) error {
+	url, err := r.url(routeName, map[string]string{"Remote": name}, nil)
+	if err != nil {
+		return err
+	}
+
+	req, err := r.newRequest(method, url.String(), body)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Do(req, nil)
+	return knownErrorOr(err)
+}
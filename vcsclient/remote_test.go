@@ -0,0 +1,127 @@
+package vcsclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestRepository_AddRemote(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoAddRemote, repo, map[string]string{"RepoPath": repoPath, "Remote": "upstream"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "PUT")
+
+		var url string
+		if err := json.NewDecoder(r.Body).Decode(&url); err != nil {
+			t.Fatal(err)
+		}
+		if want := "https://example.com/foo.git"; url != want {
+			t.Errorf("got URL %q, want %q", url, want)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := repo.AddRemote("upstream", "https://example.com/foo.git"); err != nil {
+		t.Errorf("Repository.AddRemote returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
+
+func TestRepository_RemoveRemote_notFound(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	mux.HandleFunc(urlPath(t, RouteRepoRemoveRemote, repo, map[string]string{"RepoPath": repoPath, "Remote": "upstream"}), func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, &ErrorResponse{Message: vcs.ErrRemoteNotFound.Error()})
+	})
+
+	if err := repo.RemoveRemote("upstream"); err != vcs.ErrRemoteNotFound {
+		t.Errorf("Repository.RemoveRemote returned error %v, want %v", err, vcs.ErrRemoteNotFound)
+	}
+}
+
+func TestRepository_ListRemotes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.Remote{{Name: "origin", URL: "https://example.com/foo.git"}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoRemotes, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+
+		writeJSON(w, want)
+	})
+
+	remotes, err := repo.ListRemotes()
+	if err != nil {
+		t.Errorf("Repository.ListRemotes returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(remotes, want) {
+		t.Errorf("Repository.ListRemotes returned %+v, want %+v", remotes, want)
+	}
+}
+
+func TestRepository_Fetch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	opt := vcs.RemoteOpts{SSH: &vcs.SSHConfig{User: "git", PrivateKey: []byte("key")}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoFetchRemote, repo, map[string]string{"RepoPath": repoPath, "Remote": "upstream"}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+
+		var gotOpt vcs.RemoteOpts
+		if err := json.NewDecoder(r.Body).Decode(&gotOpt); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotOpt, opt) {
+			t.Errorf("got opt %+v, want %+v", gotOpt, opt)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if err := repo.Fetch("upstream", opt); err != nil {
+		t.Errorf("Repository.Fetch returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
@@ -1,8 +1,12 @@
 package vcsclient
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -17,22 +21,35 @@ import (
 
 var ErrRepoNotExist = errors.New("repository does not exist on remote server")
 
+// ErrRepoExists is returned when trying to create a repository that
+// already exists on the server.
+var ErrRepoExists = errors.New("repository already exists on remote server")
+
+// ErrStorageQuotaExceeded is returned when cloning a repository would
+// exceed the server's storage quota.
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded on remote server")
+
 func IsRepoNotExist(err error) bool {
-	if err == nil {
-		return false
-	}
-	if err == ErrRepoNotExist {
-		return true
-	}
-	if err, ok := err.(*ErrorResponse); ok {
-		return err.Message == ErrRepoNotExist.Error()
-	}
-	return err.Error() == ErrRepoNotExist.Error()
+	return KnownError(err) == ErrRepoNotExist
 }
 
 type repository struct {
 	client   *Client
 	repoPath string
+
+	// ctx is the context of the HTTP requests made by the repository
+	// (and its file systems). If nil, context.Background() is used.
+	ctx context.Context
+}
+
+// newRequest creates an API request (see (*Client).NewRequest) with
+// the repository's context.
+func (r *repository) newRequest(method, urlStr string, body interface{}) (*http.Request, error) {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.client.NewRequestWithContext(ctx, method, urlStr, body)
 }
 
 var _ vcs.Repository = (*repository)(nil)
@@ -53,6 +70,15 @@ type CloneInfo struct {
 	// CloneURL is the remote URL from which to clone.
 	CloneURL string
 
+	// Depth, if nonzero, makes a shallow clone of only the default
+	// branch with history truncated to this many commits. See
+	// vcs.CloneOpt for details.
+	Depth int `json:",omitempty"`
+
+	// Branch, if set, clones (and subsequently updates) only the named
+	// branch instead of mirroring every ref.
+	Branch string `json:",omitempty"`
+
 	// Additional options
 	vcs.RemoteOpts
 }
@@ -63,7 +89,7 @@ func (r *repository) CloneOrUpdate(cloneInfo *CloneInfo) error {
 		return err
 	}
 
-	req, err := r.client.NewRequest("POST", url.String(), cloneInfo)
+	req, err := r.newRequest("POST", url.String(), cloneInfo)
 	if err != nil {
 		return err
 	}
@@ -79,20 +105,76 @@ func (r *repository) CloneOrUpdate(cloneInfo *CloneInfo) error {
 	return nil
 }
 
+type RepositoryExistenceChecker interface {
+	// Exists reports whether the repository has been cloned (or
+	// created) on the server, without cloning or opening it.
+	Exists() (bool, error)
+}
+
+func (r *repository) Exists() (bool, error) {
+	url, err := r.url(RouteRepoExists, nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := r.newRequest("HEAD", url.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := r.client.Do(req, nil); err != nil {
+		if IsHTTPErrorCode(err, http.StatusNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type RepositoryIniter interface {
+	// Init instructs the server to create an empty repository (which
+	// can then be pushed to via the git transport). If the repository
+	// already exists, ErrRepoExists is returned.
+	Init(initInfo *InitInfo) error
+}
+
+// InitInfo is the information needed to create an empty repository.
+type InitInfo struct {
+	// VCS is the type of VCS (currently only "git" is supported)
+	VCS string
+}
+
+func (r *repository) Init(initInfo *InitInfo) error {
+	url, err := r.url(RouteRepoInit, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	req, err := r.newRequest("POST", url.String(), initInfo)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.client.Do(req, nil); err != nil {
+		return knownErrorOr(err)
+	}
+	return nil
+}
+
 func (r *repository) ResolveBranch(name string) (vcs.CommitID, error) {
 	url, err := r.url(RouteRepoBranch, map[string]string{"Branch": name}, nil)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return "", err
 	}
 
 	resp, err := r.client.doIgnoringRedirects(req)
 	if err != nil {
-		return "", err
+		return "", knownErrorOr(err)
 	}
 
 	return r.parseCommitIDInURL(resp.Header.Get("location"))
@@ -104,33 +186,75 @@ func (r *repository) ResolveRevision(spec string) (vcs.CommitID, error) {
 		return "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return "", err
 	}
 
 	resp, err := r.client.doIgnoringRedirects(req)
 	if err != nil {
-		return "", err
+		return "", knownErrorOr(err)
 	}
 
 	return r.parseCommitIDInURL(resp.Header.Get("location"))
 }
 
+// RevisionsResolver is implemented by repositories that can resolve
+// many revision specifiers at once.
+type RevisionsResolver interface {
+	// ResolveRevisions resolves each of the revision specifiers to a
+	// commit ID. The returned map has an entry for each spec. Failing
+	// to resolve one spec does not cause the whole call to fail;
+	// instead, the spec's entry's Error field is set.
+	ResolveRevisions(specs []string) (map[string]*ResolvedRevision, error)
+}
+
+// ResolvedRevision is the result of resolving a single revision
+// specifier in a call to ResolveRevisions.
+type ResolvedRevision struct {
+	// CommitID is the commit ID that the revision resolved to, if
+	// resolution succeeded.
+	CommitID vcs.CommitID `json:",omitempty"`
+
+	// Error is the error message, if resolution failed.
+	Error string `json:",omitempty"`
+}
+
+var _ RevisionsResolver = (*repository)(nil)
+
+func (r *repository) ResolveRevisions(specs []string) (map[string]*ResolvedRevision, error) {
+	url, err := r.url(RouteRepoRevisions, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.newRequest("POST", url.String(), specs)
+	if err != nil {
+		return nil, err
+	}
+
+	var revs map[string]*ResolvedRevision
+	if _, err := r.client.Do(req, &revs); err != nil {
+		return nil, err
+	}
+
+	return revs, nil
+}
+
 func (r *repository) ResolveTag(name string) (vcs.CommitID, error) {
 	url, err := r.url(RouteRepoTag, map[string]string{"Tag": name}, nil)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return "", err
 	}
 
 	resp, err := r.client.doIgnoringRedirects(req)
 	if err != nil {
-		return "", err
+		return "", knownErrorOr(err)
 	}
 
 	return r.parseCommitIDInURL(resp.Header.Get("location"))
@@ -157,7 +281,7 @@ func (r *repository) Branches(opt vcs.BranchesOptions) ([]*vcs.Branch, error) {
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +301,7 @@ func (r *repository) Tags() ([]*vcs.Tag, error) {
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -197,7 +321,7 @@ func (r *repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +329,7 @@ func (r *repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 	var commit *vcs.Commit
 	_, err = r.client.Do(req, &commit)
 	if err != nil {
-		return nil, err
+		return nil, knownErrorOr(err)
 	}
 
 	return commit, nil
@@ -215,29 +339,162 @@ func (r *repository) GetCommit(id vcs.CommitID) (*vcs.Commit, error) {
 // total number of commits in a call to Commits.
 const TotalCommitsHeader = "x-vcsstore-total-commits"
 
+// CommitCountHeader is the name of the HTTP header that contains the
+// number of commits in a call to CountCommits.
+const CommitCountHeader = "x-commit-count"
+
+// NextCommitsCursorHeader is the name of the HTTP header that contains
+// the cursor (for vcs.CommitsOptions.Cursor) that continues a call to
+// Commits after the commits in the response (see
+// vcs.NextCommitsCursor). It is only set if there may be more commits.
+const NextCommitsCursorHeader = "x-vcsstore-next-commits-cursor"
+
+// RefsGenerationHeader is the name of the HTTP header that contains
+// the repository's refs generation in responses that depend on its
+// refs (such as resolved branches and lists of tags). The generation
+// changes whenever the repository's refs may have changed, so clients
+// can compare it to detect changes.
+const RefsGenerationHeader = "x-vcsstore-refs-generation"
+
+// NDJSONContentType is the media type of newline-delimited JSON
+// responses. A client that sends it in the Accept header of a Commits
+// request receives one commit per line, written as each commit is read
+// from the repository.
+const NDJSONContentType = "application/x-ndjson"
+
+// An NDJSONError is the last record of an NDJSON response if the
+// server failed after it had begun streaming the response (and so
+// could no longer respond with an error status). Its Error is never
+// nil, but its message is omitted (as in other error responses) unless
+// the server is in debug mode or the error is a known error (see
+// KnownError).
+type NDJSONError struct {
+	Error *ErrorResponse
+}
+
+// ndjsonErrorPrefix is the start of each NDJSON line that is an
+// NDJSONError record.
+var ndjsonErrorPrefix = []byte(`{"Error":`)
+
 func (r *repository) Commits(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, error) {
+	commits, total, _, err := r.CommitsPage(opt)
+	return commits, total, err
+}
+
+// A CommitsPager is a repository that can list commits a page at a
+// time using cursors.
+type CommitsPager interface {
+	// CommitsPage is like (vcs.Repository).Commits, but it also
+	// returns the cursor to pass as opt.Cursor to get the next page
+	// of commits. The returned cursor is empty if there are no more
+	// commits.
+	CommitsPage(opt vcs.CommitsOptions) (commits []*vcs.Commit, total uint, nextCursor string, err error)
+}
+
+var _ CommitsPager = (*repository)(nil)
+
+func (r *repository) CommitsPage(opt vcs.CommitsOptions) ([]*vcs.Commit, uint, string, error) {
 	url, err := r.url(RouteRepoCommits, nil, opt)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	var commits []*vcs.Commit
 	resp, err := r.client.Do(req, &commits)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	total, err := strconv.ParseUint(string(resp.Header.Get(TotalCommitsHeader)), 10, 64)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
+	}
+
+	return commits, uint(total), resp.Header.Get(NextCommitsCursorHeader), nil
+}
+
+var _ vcs.CommitStreamer = (*repository)(nil)
+
+func (r *repository) StreamCommits(opt vcs.CommitsOptions, fn func(*vcs.Commit) error) (uint, error) {
+	url, err := r.url(RouteRepoCommits, nil, opt)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := r.newRequest("GET", url.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", NDJSONContentType)
+
+	resp, err := r.client.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := CheckResponse(resp, false); err != nil {
+		return 0, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("content-type"), NDJSONContentType) {
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var rec json.RawMessage
+			if err := dec.Decode(&rec); err == io.EOF {
+				break
+			} else if err != nil {
+				return 0, err
+			}
+			if bytes.HasPrefix(rec, ndjsonErrorPrefix) {
+				var e NDJSONError
+				if err := json.Unmarshal(rec, &e); err != nil {
+					return 0, err
+				}
+				if e.Error != nil {
+					e.Error.Response = resp
+					return 0, e.Error
+				}
+			}
+			var commit vcs.Commit
+			if err := json.Unmarshal(rec, &commit); err != nil {
+				return 0, err
+			}
+			if err := fn(&commit); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		// The server's repository doesn't support streaming, so it
+		// responded with a JSON array.
+		var commits []*vcs.Commit
+		if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
+			return 0, err
+		}
+		for _, commit := range commits {
+			if err := fn(commit); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// The total is sent as a trailer if the server had to count the
+	// commits after streaming them.
+	totalStr := resp.Header.Get(TotalCommitsHeader)
+	if totalStr == "" {
+		totalStr = resp.Trailer.Get(TotalCommitsHeader)
+	}
+	total, err := strconv.ParseUint(totalStr, 10, 64)
+	if err != nil {
+		return 0, err
 	}
 
-	return commits, uint(total), nil
+	return uint(total), nil
 }
 
 func (r *repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, error) {
@@ -246,7 +503,7 @@ func (r *repository) Committers(opt vcs.CommittersOptions) ([]*vcs.Committer, er
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1,7 +1,9 @@
 package vcsclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"reflect"
 	"testing"
@@ -43,6 +45,72 @@ func TestRepository_CloneOrUpdate(t *testing.T) {
 	}
 }
 
+func TestRepository_Init(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	opt := &InitInfo{VCS: "git"}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoInit, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+
+		body, _ := json.Marshal(opt)
+		testBody(t, r, string(body)+"\n")
+
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	err := repo.Init(opt)
+	if err != nil {
+		t.Errorf("Repository.Init returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+}
+
+func TestRepository_Exists(t *testing.T) {
+	for _, want := range []bool{false, true} {
+		func() {
+			setup()
+			defer teardown()
+
+			repoPath := "a.b/c"
+			repo_, _ := vcsclient.Repository(repoPath)
+			repo := repo_.(*repository)
+
+			var called bool
+			mux.HandleFunc(urlPath(t, RouteRepoExists, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				testMethod(t, r, "HEAD")
+
+				if !want {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+
+			exists, err := repo.Exists()
+			if err != nil {
+				t.Errorf("Repository.Exists returned error: %v", err)
+			}
+			if exists != want {
+				t.Errorf("Repository.Exists returned %v, want %v", exists, want)
+			}
+
+			if !called {
+				t.Fatal("!called")
+			}
+		}()
+	}
+}
+
 func TestRepository_ResolveBranch(t *testing.T) {
 	setup()
 	defer teardown()
@@ -107,6 +175,42 @@ func TestRepository_ResolveRevision(t *testing.T) {
 	}
 }
 
+func TestRepository_ResolveRevisions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := map[string]*ResolvedRevision{
+		"a": {CommitID: "abcd"},
+		"b": {Error: "revision not found"},
+	}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoRevisions, repo, map[string]string{"RepoPath": repoPath}), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "POST")
+		testBody(t, r, `["a","b"]`+"\n")
+
+		writeJSON(w, want)
+	})
+
+	revs, err := repo.ResolveRevisions([]string{"a", "b"})
+	if err != nil {
+		t.Errorf("Repository.ResolveRevisions returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if !reflect.DeepEqual(revs, want) {
+		t.Errorf("Repository.ResolveRevisions returned %+v, want %+v", revs, want)
+	}
+}
+
 func TestRepository_ResolveTag(t *testing.T) {
 	setup()
 	defer teardown()
@@ -241,6 +345,127 @@ func TestRepository_Commits(t *testing.T) {
 	}
 }
 
+func TestRepository_Commits_cancel(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	ctx, cancel := context.WithCancel(context.Background())
+	repo_, _ := vcsclient.RepositoryContext(ctx, repoPath)
+	repo := repo_.(*repository)
+
+	called := make(chan struct{})
+	unblock := make(chan struct{})
+	defer close(unblock)
+	mux.HandleFunc(urlPath(t, RouteRepoCommits, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		close(called)
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	})
+
+	go func() {
+		<-called
+		cancel()
+	}()
+	_, _, err := repo.Commits(vcs.CommitsOptions{Head: "abcd"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Repository.Commits: got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRepository_CommitsPage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.Commit{{ID: "abcd"}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommits, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"Head": "abcd", "Base": "", "N": "1", "Skip": "0", "Path": "", "NoTotal": "false", "Cursor": "c1"})
+
+		w.Header().Set(TotalCommitsHeader, "123")
+		w.Header().Set(NextCommitsCursorHeader, "c2")
+		writeJSON(w, want)
+	})
+
+	commits, total, nextCursor, err := repo.CommitsPage(vcs.CommitsOptions{Head: "abcd", N: 1, Cursor: "c1"})
+	if err != nil {
+		t.Errorf("Repository.CommitsPage returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if want := uint(123); total != want {
+		t.Errorf("Repository.CommitsPage: got total %d, want %d", total, want)
+	}
+	if want := "c2"; nextCursor != want {
+		t.Errorf("Repository.CommitsPage: got next cursor %q, want %q", nextCursor, want)
+	}
+
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("Repository.CommitsPage returned %+v, want %+v", commits, want)
+	}
+}
+
+func TestRepository_StreamCommits(t *testing.T) {
+	setup()
+	defer teardown()
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	want := []*vcs.Commit{{ID: "abcd"}, {ID: "wxyz"}}
+
+	var called bool
+	mux.HandleFunc(urlPath(t, RouteRepoCommits, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testMethod(t, r, "GET")
+		if accept := r.Header.Get("Accept"); accept != NDJSONContentType {
+			t.Errorf("got Accept %q, want %q", accept, NDJSONContentType)
+		}
+
+		w.Header().Set("content-type", NDJSONContentType)
+		w.Header().Set("Trailer", TotalCommitsHeader)
+		enc := json.NewEncoder(w)
+		for _, c := range want {
+			enc.Encode(c)
+		}
+		w.Header().Set(TotalCommitsHeader, "123")
+	})
+
+	var commits []*vcs.Commit
+	total, err := repo.StreamCommits(vcs.CommitsOptions{Head: "abcd"}, func(c *vcs.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Repository.StreamCommits returned error: %v", err)
+	}
+
+	if !called {
+		t.Fatal("!called")
+	}
+
+	if want := uint(123); total != want {
+		t.Errorf("Repository.StreamCommits: got total %d, want %d", total, want)
+	}
+
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("Repository.StreamCommits returned %+v, want %+v", commits, want)
+	}
+}
+
 func TestRepository_GetCommit(t *testing.T) {
 	setup()
 	defer teardown()
@@ -0,0 +1,121 @@
+package vcsclient
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// A RetryPolicy decides whether a failed idempotent (GET or HEAD)
+// request should be retried. Other requests are never retried.
+type RetryPolicy interface {
+	// Retry is called after attempt number attempt (starting at 1) of
+	// a request returned resp and err. It returns whether to retry the
+	// request and, if so, how long to wait before retrying.
+	Retry(attempt int, resp *http.Response, err error) (wait time.Duration, retry bool)
+}
+
+// DefaultRetryPolicy is the RetryPolicy of clients created by New.
+var DefaultRetryPolicy RetryPolicy = &Backoff{
+	MaxAttempts: 3,
+	Initial:     100 * time.Millisecond,
+	Max:         2 * time.Second,
+}
+
+// Backoff is a RetryPolicy that retries requests that failed with a
+// network error or with HTTP status 502, 503, or 504 (which a
+// restarting server or proxy returns). The wait between attempts
+// doubles after each attempt and is randomly jittered.
+type Backoff struct {
+	MaxAttempts int           // maximum number of attempts (including the first)
+	Initial     time.Duration // wait before the first retry
+	Max         time.Duration // maximum wait between attempts
+}
+
+func (b *Backoff) Retry(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts || !isTransientError(resp, err) {
+		return 0, false
+	}
+	wait := b.Initial << uint(attempt-1)
+	if wait > b.Max || wait <= 0 {
+		wait = b.Max
+	}
+	// Wait between half and all of the interval, so that clients that
+	// failed at the same time don't all retry at the same time.
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	return wait, true
+}
+
+func isTransientError(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// A RetryError is returned when a request failed after it was retried.
+type RetryError struct {
+	Attempts int   // number of attempts made
+	Err      error // error of the last attempt
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%s (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// send sends req using httpClient, retrying it according to the
+// client's RetryPolicy if it is idempotent. It returns the number of
+// attempts made. Retries stop when req's context is done or when its
+// deadline would pass before the next attempt.
+func (c *Client) send(httpClient *http.Client, req *http.Request) (*http.Response, int, error) {
+	if c.RetryPolicy == nil || (req.Method != "GET" && req.Method != "HEAD") {
+		resp, err := httpClient.Do(req)
+		return resp, 1, err
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := httpClient.Do(req)
+		if err != nil && isIgnoredRedirectErr(err) {
+			return resp, attempt, err
+		}
+		if ctx.Err() != nil {
+			return resp, attempt, retryError(attempt, err)
+		}
+
+		wait, retry := c.RetryPolicy.Retry(attempt, resp, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			retry = false
+		}
+		if !retry {
+			return resp, attempt, retryError(attempt, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, attempt, retryError(attempt, ctx.Err())
+		}
+	}
+}
+
+// retryError returns err annotated with the number of attempts made
+// (if the request was retried).
+func retryError(attempts int, err error) error {
+	if err == nil || attempts <= 1 {
+		return err
+	}
+	return &RetryError{Attempts: attempts, Err: err}
+}
@@ -0,0 +1,169 @@
+package vcsclient
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+	"sourcegraph.com/sourcegraph/vcsstore/git"
+)
+
+// countingRetryPolicy retries transient errors up to maxAttempts
+// times without waiting and counts the attempts.
+type countingRetryPolicy struct {
+	maxAttempts int
+	wait        time.Duration
+	attempts    int
+}
+
+func (p *countingRetryPolicy) Retry(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	p.attempts = attempt
+	return p.wait, attempt < p.maxAttempts && isTransientError(resp, err)
+}
+
+func TestClient_Do_retry(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policy := &countingRetryPolicy{maxAttempts: 5}
+	vcsclient.RetryPolicy = policy
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var calls int
+	mux.HandleFunc(urlPath(t, RouteRepoCommit, repo, map[string]string{"CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, "", http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, &vcs.Commit{ID: "abcd"})
+	})
+
+	commit, err := repo.GetCommit("abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.ID != "abcd" {
+		t.Errorf("got commit %q, want %q", commit.ID, "abcd")
+	}
+	if want := 3; calls != want || policy.attempts != want {
+		t.Errorf("got %d calls (%d attempts), want %d", calls, policy.attempts, want)
+	}
+}
+
+func TestClient_Do_retryGiveUp(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policy := &countingRetryPolicy{maxAttempts: 4}
+	vcsclient.RetryPolicy = policy
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var calls int
+	mux.HandleFunc(urlPath(t, RouteRepoCommit, repo, map[string]string{"CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "", http.StatusServiceUnavailable)
+	})
+
+	_, err := repo.GetCommit("abcd")
+	errResp, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("got error %v (%T), want *ErrorResponse", err, err)
+	}
+	if want := 4; calls != want || errResp.Attempts != want {
+		t.Errorf("got %d calls (error reports %d attempts), want %d", calls, errResp.Attempts, want)
+	}
+	if !IsHTTPErrorCode(err, http.StatusServiceUnavailable) {
+		t.Errorf("got error %v, want HTTP status %d", err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestClient_Do_retryDeadline(t *testing.T) {
+	setup()
+	defer teardown()
+
+	vcsclient.RetryPolicy = &countingRetryPolicy{maxAttempts: 5, wait: time.Hour}
+
+	repoPath := "a.b/c"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	repo_, _ := vcsclient.RepositoryContext(ctx, repoPath)
+	repo := repo_.(*repository)
+
+	var calls int
+	mux.HandleFunc(urlPath(t, RouteRepoCommit, repo, map[string]string{"CommitID": "abcd"}), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "", http.StatusBadGateway)
+	})
+
+	// The next attempt would be after the deadline, so it is not made.
+	if _, err := repo.GetCommit("abcd"); !IsHTTPErrorCode(err, http.StatusBadGateway) {
+		t.Errorf("got error %v, want HTTP status %d", err, http.StatusBadGateway)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func TestClient_Do_noRetryPOST(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policy := &countingRetryPolicy{maxAttempts: 5}
+	vcsclient.RetryPolicy = policy
+
+	repoPath := "a.b/c"
+	repo_, _ := vcsclient.Repository(repoPath)
+	repo := repo_.(*repository)
+
+	var calls int
+	mux.HandleFunc(urlPath(t, git.RouteGitReceivePack, repo, nil), func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "", http.StatusBadGateway)
+	})
+
+	transport, _ := vcsclient.GitTransport(repoPath)
+	var out bytes.Buffer
+	if err := transport.ReceivePack(context.Background(), &out, bytes.NewReader([]byte("x")), git.GitTransportOpt{}); err == nil {
+		t.Fatal("got no error, want HTTP error")
+	}
+	if calls != 1 || policy.attempts != 0 {
+		t.Errorf("got %d calls (%d attempts), want 1 call and no retry policy consultation", calls, policy.attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := &Backoff{MaxAttempts: 4, Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond}
+	resp := &http.Response{StatusCode: http.StatusBadGateway}
+	for _, test := range []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 300 * time.Millisecond},
+	} {
+		wait, retry := b.Retry(test.attempt, resp, nil)
+		if !retry {
+			t.Errorf("attempt %d: got no retry, want retry", test.attempt)
+		}
+		if wait < test.max/2 || wait > test.max {
+			t.Errorf("attempt %d: got wait %s, want between %s and %s", test.attempt, wait, test.max/2, test.max)
+		}
+	}
+	if _, retry := b.Retry(4, resp, nil); retry {
+		t.Error("got retry after MaxAttempts, want no retry")
+	}
+	if _, retry := b.Retry(1, &http.Response{StatusCode: http.StatusNotFound}, nil); retry {
+		t.Error("got retry of 404, want no retry")
+	}
+}
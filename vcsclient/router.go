@@ -15,21 +15,53 @@ import (
 const (
 	// Route names
 	RouteRepo                   = "vcs:repo"
+	RouteRepoAheadBehind        = "vcs:repo.ahead-behind"
 	RouteRepoBlameFile          = "vcs:repo.blame-file"
 	RouteRepoBranch             = "vcs:repo.branch"
 	RouteRepoBranches           = "vcs:repo.branches"
+	RouteRepoCloneAsync         = "vcs:repo.clone-async"
+	RouteRepoCloneStatus        = "vcs:repo.clone-status"
 	RouteRepoCommit             = "vcs:repo.commit"
 	RouteRepoCommits            = "vcs:repo.commits"
 	RouteRepoCommitters         = "vcs:repo.committers"
+	RouteRepoCommitRefs         = "vcs:repo.commit-refs"
+	RouteRepoCommitGraph        = "vcs:repo.commit-graph"
+	RouteRepoCommitCount        = "vcs:repo.commit-count"
+	RouteRepoCommitNotes        = "vcs:repo.commit-notes"
+	RouteRepoCreateBranch       = "vcs:repo.create-branch"
+	RouteRepoCreateTag          = "vcs:repo.create-tag"
+	RouteRepoDeleteBranch       = "vcs:repo.delete-branch"
+	RouteRepoDeleteTag          = "vcs:repo.delete-tag"
+	RouteRepoDefaultBranch      = "vcs:repo.default-branch"
+	RouteRepoConfig             = "vcs:repo.config"
+	RouteRepoSetConfig          = "vcs:repo.set-config"
 	RouteRepoCreateOrUpdate     = "vcs:repo.create-or-update"
 	RouteRepoDiff               = "vcs:repo.diff"
+	RouteRepoExists             = "vcs:repo.exists"
 	RouteRepoCrossRepoDiff      = "vcs:repo.cross-repo-diff"
+	RouteRepoDescribe           = "vcs:repo.describe"
+	RouteRepoFsck               = "vcs:repo.fsck"
+	RouteRepoGC                 = "vcs:repo.gc"
+	RouteRepoDiffStat           = "vcs:repo.diffstat"
+	RouteRepoFormatPatch        = "vcs:repo.format-patch"
+	RouteRepoInit               = "vcs:repo.init"
+	RouteRepoIsAncestor         = "vcs:repo.is-ancestor"
 	RouteRepoMergeBase          = "vcs:repo.merge-base"
+	RouteRepoObject             = "vcs:repo.object"
 	RouteRepoCrossRepoMergeBase = "vcs:repo.cross-repo-merge-base"
+	RouteRepoReflog             = "vcs:repo.reflog"
+	RouteRepoRemotes            = "vcs:repo.remotes"
+	RouteRepoAddRemote          = "vcs:repo.add-remote"
+	RouteRepoRemoveRemote       = "vcs:repo.remove-remote"
+	RouteRepoFetchRemote        = "vcs:repo.fetch-remote"
 	RouteRepoRevision           = "vcs:repo.rev"
+	RouteRepoRevisionAt         = "vcs:repo.rev-at"
+	RouteRepoRevisions          = "vcs:repo.revs"
 	RouteRepoSearch             = "vcs:repo.search"
+	RouteRepoStatMulti          = "vcs:repo.stat-multi"
 	RouteRepoTag                = "vcs:repo.tag"
 	RouteRepoTags               = "vcs:repo.tags"
+	RouteRepoTreeDiff           = "vcs:repo.tree-diff"
 	RouteRepoTreeEntry          = "vcs:repo.tree-entry"
 	RouteRoot                   = "vcs:root"
 )
@@ -50,6 +82,7 @@ func NewRouter(parent *muxpkg.Router) *Router {
 	repoPath := "/{RepoPath:" + repoURIPattern + "}"
 	parent.Path(repoPath).Methods("GET").Name(RouteRepo)
 	parent.Path(repoPath).Methods("POST").Name(RouteRepoCreateOrUpdate)
+	parent.Path(repoPath).Methods("HEAD").Name(RouteRepoExists)
 
 	repo := parent.PathPrefix(repoPath).Subrouter()
 
@@ -57,18 +90,43 @@ func NewRouter(parent *muxpkg.Router) *Router {
 	repoGit := repo.PathPrefix("/.git").Subrouter()
 	git.NewRouter(repoGit)
 
+	repo.Path("/.init").Methods("POST").Name(RouteRepoInit)
+	repo.Path("/.clone").Methods("POST").Name(RouteRepoCloneAsync)
+	repo.Path("/.clone-status/{JobID}").Methods("GET").Name(RouteRepoCloneStatus)
 	repo.Path("/.blame/{Path:.+}").Methods("GET").Name(RouteRepoBlameFile)
 	repo.Path("/.diff/{Base}..{Head}").Methods("GET").Name(RouteRepoDiff)
+	repo.Path("/.tree-diff").Methods("GET").Name(RouteRepoTreeDiff)
 	repo.Path("/.cross-repo-diff/{Base}..{HeadRepoPath:" + repoURIPattern + "}:{Head}").Methods("GET").Name(RouteRepoCrossRepoDiff)
 	repo.Path("/.branches").Methods("GET").Name(RouteRepoBranches)
+	repo.Path("/.default-branch").Methods("GET").Name(RouteRepoDefaultBranch)
 	repo.Path("/.branches/{Branch:.+}").Methods("GET").Name(RouteRepoBranch)
+	repo.Path("/.branches/{Branch:.+}").Methods("POST").Name(RouteRepoCreateBranch)
+	repo.Path("/.branches/{Branch:.+}").Methods("DELETE").Name(RouteRepoDeleteBranch)
+	repo.Path("/.revs").Methods("POST").Name(RouteRepoRevisions)
 	repo.Path("/.revs/{RevSpec:.+}").Methods("GET").Name(RouteRepoRevision)
 	repo.Path("/.tags").Methods("GET").Name(RouteRepoTags)
 	repo.Path("/.tags/{Tag:.+}").Methods("GET").Name(RouteRepoTag)
+	repo.Path("/.tags/{Tag:.+}").Methods("POST").Name(RouteRepoCreateTag)
+	repo.Path("/.tags/{Tag:.+}").Methods("DELETE").Name(RouteRepoDeleteTag)
 	repo.Path("/.merge-base/{CommitIDA}/{CommitIDB}").Methods("GET").Name(RouteRepoMergeBase)
 	repo.Path("/.cross-repo-merge-base/{CommitIDA}/{BRepoPath:" + repoURIPattern + "}/{CommitIDB}").Methods("GET").Name(RouteRepoCrossRepoMergeBase)
+	repo.Path("/.is-ancestor/{CommitIDA}/{CommitIDB}").Methods("GET").Name(RouteRepoIsAncestor)
+	repo.Path("/.ahead-behind/{Base}/{Head}").Methods("GET").Name(RouteRepoAheadBehind)
 	repo.Path("/.committers").Methods("GET").Name(RouteRepoCommitters)
+	repo.Path("/.reflog/{Ref:.+}").Methods("GET").Name(RouteRepoReflog)
+	repo.Path("/.rev-at/{Ref:.+}").Methods("GET").Name(RouteRepoRevisionAt)
+	repo.Path("/.config/{Key}").Methods("GET").Name(RouteRepoConfig)
+	repo.Path("/.config/{Key}").Methods("PUT").Name(RouteRepoSetConfig)
+	repo.Path("/.gc").Methods("POST").Name(RouteRepoGC)
+	repo.Path("/.fsck").Methods("POST").Name(RouteRepoFsck)
+	repo.Path("/.remotes").Methods("GET").Name(RouteRepoRemotes)
+	repo.Path("/.remotes/{Remote}").Methods("PUT").Name(RouteRepoAddRemote)
+	repo.Path("/.remotes/{Remote}").Methods("DELETE").Name(RouteRepoRemoveRemote)
+	repo.Path("/.remotes/{Remote}/fetch").Methods("POST").Name(RouteRepoFetchRemote)
 	repo.Path("/.commits").Methods("GET").Name(RouteRepoCommits)
+	repo.Path("/.commit-graph").Methods("GET").Name(RouteRepoCommitGraph)
+	repo.Path("/.commit-count").Methods("GET").Name(RouteRepoCommitCount)
+	repo.Path("/.objects/{ObjectID}").Methods("GET").Name(RouteRepoObject)
 	commitPath := "/.commits/{CommitID}"
 	repo.Path(commitPath).Methods("GET").Name(RouteRepoCommit)
 	commit := repo.PathPrefix(commitPath).Subrouter()
@@ -93,7 +151,13 @@ func NewRouter(parent *muxpkg.Router) *Router {
 		return vars
 	}
 	commit.Path("/tree{Path:(?:/.*)*}").Methods("GET").PostMatchFunc(cleanTreeVars).BuildVarsFunc(prepareTreeVars).Name(RouteRepoTreeEntry)
+	commit.Path("/stat").Methods("POST").Name(RouteRepoStatMulti)
 	commit.Path("/search").Methods("GET").Name(RouteRepoSearch)
+	commit.Path("/describe").Methods("GET").Name(RouteRepoDescribe)
+	commit.Path("/refs").Methods("GET").Name(RouteRepoCommitRefs)
+	commit.Path("/notes").Methods("GET").Name(RouteRepoCommitNotes)
+	commit.Path("/diffstat").Methods("GET").Name(RouteRepoDiffStat)
+	commit.Path("/format-patch").Methods("GET").Name(RouteRepoFormatPatch)
 
 	return (*Router)(parent)
 }
@@ -102,6 +166,10 @@ func (r *Router) URLToRepo(repoPath string) *url.URL {
 	return r.URLTo(RouteRepo, "RepoPath", repoPath)
 }
 
+func (r *Router) URLToRepoCloneStatus(repoPath string, jobID string) *url.URL {
+	return r.URLTo(RouteRepoCloneStatus, "RepoPath", repoPath, "JobID", jobID)
+}
+
 func (r *Router) URLToRepoBlameFile(repoPath string, path string, opt *vcs.BlameOptions) *url.URL {
 	u := r.URLTo(RouteRepoBlameFile, "RepoPath", repoPath, "Path", path)
 	if opt != nil {
@@ -126,6 +194,18 @@ func (r *Router) URLToRepoDiff(repoPath string, base, head vcs.CommitID, opt *vc
 	return u
 }
 
+func (r *Router) URLToRepoTreeDiff(repoPath string, opt *TreeDiffOptions) *url.URL {
+	u := r.URLTo(RouteRepoTreeDiff, "RepoPath", repoPath)
+	if opt != nil {
+		q, err := query.Values(opt)
+		if err != nil {
+			panic(err.Error())
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u
+}
+
 func (r *Router) URLToRepoCrossRepoDiff(baseRepoPath string, base vcs.CommitID, headRepoPath string, head vcs.CommitID, opt *vcs.DiffOptions) *url.URL {
 	u := r.URLTo(RouteRepoCrossRepoDiff, "RepoPath", baseRepoPath, "Base", string(base), "HeadRepoPath", headRepoPath, "Head", string(head))
 	if opt != nil {
@@ -156,6 +236,10 @@ func (r *Router) URLToRepoRevision(repoPath string, revSpec string) *url.URL {
 	return r.URLTo(RouteRepoRevision, "RepoPath", repoPath, "RevSpec", revSpec)
 }
 
+func (r *Router) URLToRepoRevisions(repoPath string) *url.URL {
+	return r.URLTo(RouteRepoRevisions, "RepoPath", repoPath)
+}
+
 func (r *Router) URLToRepoTag(repoPath string, tag string) *url.URL {
 	return r.URLTo(RouteRepoTag, "RepoPath", repoPath, "Tag", tag)
 }
@@ -178,6 +262,26 @@ func (r *Router) URLToRepoCommits(repoPath string, opt vcs.CommitsOptions) *url.
 	return u
 }
 
+func (r *Router) URLToRepoCommitGraph(repoPath string, opt vcs.CommitsOptions) *url.URL {
+	u := r.URLTo(RouteRepoCommitGraph, "RepoPath", repoPath)
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (r *Router) URLToRepoCommitCount(repoPath string, opt vcs.CommitsOptions) *url.URL {
+	u := r.URLTo(RouteRepoCommitCount, "RepoPath", repoPath)
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
 func (r *Router) URLToRepoCommitters(repoPath string, opt vcs.CommittersOptions) *url.URL {
 	u := r.URLTo(RouteRepoCommitters, "RepoPath", repoPath)
 	q, err := query.Values(opt)
@@ -192,6 +296,10 @@ func (r *Router) URLToRepoTreeEntry(repoPath string, commitID vcs.CommitID, path
 	return r.URLTo(RouteRepoTreeEntry, "RepoPath", repoPath, "CommitID", string(commitID), "Path", path)
 }
 
+func (r *Router) URLToRepoStatMulti(repoPath string, commitID vcs.CommitID) *url.URL {
+	return r.URLTo(RouteRepoStatMulti, "RepoPath", repoPath, "CommitID", string(commitID))
+}
+
 func (r *Router) URLToRepoSearch(repoPath string, at vcs.CommitID, opt vcs.SearchOptions) *url.URL {
 	u := r.URLTo(RouteRepoSearch, "RepoPath", repoPath, "CommitID", string(at))
 	q, err := query.Values(opt)
@@ -202,6 +310,96 @@ func (r *Router) URLToRepoSearch(repoPath string, at vcs.CommitID, opt vcs.Searc
 	return u
 }
 
+func (r *Router) URLToRepoDescribe(repoPath string, commitID vcs.CommitID, opt vcs.DescribeOptions) *url.URL {
+	u := r.URLTo(RouteRepoDescribe, "RepoPath", repoPath, "CommitID", string(commitID))
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (r *Router) URLToRepoDefaultBranch(repoPath string) *url.URL {
+	return r.URLTo(RouteRepoDefaultBranch, "RepoPath", repoPath)
+}
+
+func (r *Router) URLToRepoCommitRefs(repoPath string, commitID vcs.CommitID) *url.URL {
+	return r.URLTo(RouteRepoCommitRefs, "RepoPath", repoPath, "CommitID", string(commitID))
+}
+
+func (r *Router) URLToRepoCommitNotes(repoPath string, commitID vcs.CommitID, opt NotesOptions) *url.URL {
+	u := r.URLTo(RouteRepoCommitNotes, "RepoPath", repoPath, "CommitID", string(commitID))
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (r *Router) URLToRepoReflog(repoPath string, ref string) *url.URL {
+	return r.URLTo(RouteRepoReflog, "RepoPath", repoPath, "Ref", ref)
+}
+
+func (r *Router) URLToRepoRevisionAt(repoPath string, ref string, opt *RevisionAtOpt) *url.URL {
+	u := r.URLTo(RouteRepoRevisionAt, "RepoPath", repoPath, "Ref", ref)
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (r *Router) URLToRepoConfig(repoPath string, key string) *url.URL {
+	return r.URLTo(RouteRepoConfig, "RepoPath", repoPath, "Key", key)
+}
+
+func (r *Router) URLToRepoRemotes(repoPath string) *url.URL {
+	return r.URLTo(RouteRepoRemotes, "RepoPath", repoPath)
+}
+
+func (r *Router) URLToRepoRemote(repoPath string, name string) *url.URL {
+	return r.URLTo(RouteRepoAddRemote, "RepoPath", repoPath, "Remote", name)
+}
+
+func (r *Router) URLToRepoFetchRemote(repoPath string, name string) *url.URL {
+	return r.URLTo(RouteRepoFetchRemote, "RepoPath", repoPath, "Remote", name)
+}
+
+func (r *Router) URLToRepoFsck(repoPath string) *url.URL {
+	return r.URLTo(RouteRepoFsck, "RepoPath", repoPath)
+}
+
+func (r *Router) URLToRepoGC(repoPath string, opt vcs.GCOptions) *url.URL {
+	u := r.URLTo(RouteRepoGC, "RepoPath", repoPath)
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (r *Router) URLToRepoObject(repoPath string, objectID string) *url.URL {
+	return r.URLTo(RouteRepoObject, "RepoPath", repoPath, "ObjectID", objectID)
+}
+
+func (r *Router) URLToRepoDiffStat(repoPath string, commitID vcs.CommitID) *url.URL {
+	return r.URLTo(RouteRepoDiffStat, "RepoPath", repoPath, "CommitID", string(commitID))
+}
+
+func (r *Router) URLToRepoFormatPatch(repoPath string, head vcs.CommitID, opt vcs.FormatPatchOptions) *url.URL {
+	u := r.URLTo(RouteRepoFormatPatch, "RepoPath", repoPath, "CommitID", string(head))
+	q, err := query.Values(opt)
+	if err != nil {
+		panic(err.Error())
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
 func (r *Router) URLToRepoMergeBase(repoPath string, a, b vcs.CommitID) *url.URL {
 	return r.URLTo(RouteRepoMergeBase, "RepoPath", repoPath, "CommitIDA", string(a), "CommitIDB", string(b))
 }
@@ -210,6 +408,14 @@ func (r *Router) URLToRepoCrossRepoMergeBase(repoPath string, a vcs.CommitID, bR
 	return r.URLTo(RouteRepoCrossRepoMergeBase, "RepoPath", repoPath, "CommitIDA", string(a), "BRepoPath", bRepoPath, "CommitIDB", string(b))
 }
 
+func (r *Router) URLToRepoIsAncestor(repoPath string, a, b vcs.CommitID) *url.URL {
+	return r.URLTo(RouteRepoIsAncestor, "RepoPath", repoPath, "CommitIDA", string(a), "CommitIDB", string(b))
+}
+
+func (r *Router) URLToRepoAheadBehind(repoPath string, base, head vcs.CommitID) *url.URL {
+	return r.URLTo(RouteRepoAheadBehind, "RepoPath", repoPath, "Base", string(base), "Head", string(head))
+}
+
 func (r *Router) URLTo(route string, vars ...string) *url.URL {
 	url, err := (*muxpkg.Router)(r).Get(route).URL(vars...)
 	if err != nil {
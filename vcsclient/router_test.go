@@ -87,6 +87,88 @@ func TestMatch(t *testing.T) {
 			wantVars:      map[string]string{"RepoPath": repoPath},
 		},
 
+		// Repo commit graph
+		{
+			path:          "/" + encodedRepoPath + "/.commit-graph",
+			wantRouteName: RouteRepoCommitGraph,
+			wantVars:      map[string]string{"RepoPath": repoPath},
+		},
+
+		// Repo commit count
+		{
+			path:          "/" + encodedRepoPath + "/.commit-count",
+			wantRouteName: RouteRepoCommitCount,
+			wantVars:      map[string]string{"RepoPath": repoPath},
+		},
+
+		// Repo default branch
+		{
+			path:          "/" + encodedRepoPath + "/.default-branch",
+			wantRouteName: RouteRepoDefaultBranch,
+			wantVars:      map[string]string{"RepoPath": repoPath},
+		},
+
+		// Repo reflog
+		{
+			path:          "/" + encodedRepoPath + "/.reflog/refs/heads/master",
+			wantRouteName: RouteRepoReflog,
+			wantVars:      map[string]string{"RepoPath": repoPath, "Ref": "refs/heads/master"},
+		},
+		{
+			path:          "/" + encodedRepoPath + "/.rev-at/refs/heads/master",
+			wantRouteName: RouteRepoRevisionAt,
+			wantVars:      map[string]string{"RepoPath": repoPath, "Ref": "refs/heads/master"},
+		},
+
+		// Repo config
+		{
+			path:          "/" + encodedRepoPath + "/.config/foo.bar",
+			wantRouteName: RouteRepoConfig,
+			wantVars:      map[string]string{"RepoPath": repoPath, "Key": "foo.bar"},
+		},
+
+		// Repo remotes
+		{
+			path:          "/" + encodedRepoPath + "/.remotes",
+			wantRouteName: RouteRepoRemotes,
+			wantVars:      map[string]string{"RepoPath": repoPath},
+		},
+
+		// Repo object
+		{
+			path:          "/" + encodedRepoPath + "/.objects/myobjectid",
+			wantRouteName: RouteRepoObject,
+			wantVars:      map[string]string{"RepoPath": repoPath, "ObjectID": "myobjectid"},
+		},
+
+		// Repo describe
+		{
+			path:          "/" + encodedRepoPath + "/.commits/mycommitid/describe",
+			wantRouteName: RouteRepoDescribe,
+			wantVars:      map[string]string{"RepoPath": repoPath, "CommitID": "mycommitid"},
+		},
+
+		// Repo commit refs
+		{
+			path:          "/" + encodedRepoPath + "/.commits/mycommitid/refs",
+			wantRouteName: RouteRepoCommitRefs,
+			wantVars:      map[string]string{"RepoPath": repoPath, "CommitID": "mycommitid"},
+		},
+
+		// Repo commit notes
+		{
+			path:          "/" + encodedRepoPath + "/.commits/mycommitid/notes",
+			wantRouteName: RouteRepoCommitNotes,
+			wantVars:      map[string]string{"RepoPath": repoPath, "CommitID": "mycommitid"},
+		},
+
+		// Repo diffstat
+		{
+			path:          "/" + encodedRepoPath + "/.commits/mycommitid/diffstat",
+			wantRouteName: RouteRepoDiffStat,
+			wantVars:      map[string]string{"RepoPath": repoPath, "CommitID": "mycommitid"},
+		},
+
 		// Repo tree
 		{
 			path:          "/" + encodedRepoPath + "/.commits/mycommitid/tree",
@@ -138,6 +220,18 @@ func TestMatch(t *testing.T) {
 			wantRouteName: RouteRepoCrossRepoMergeBase,
 			wantVars:      map[string]string{"RepoPath": repoPath, "CommitIDA": "a", "BRepoPath": "x.com/y/z", "CommitIDB": "b"},
 		},
+
+		// Repo ancestry
+		{
+			path:          "/" + encodedRepoPath + "/.is-ancestor/a/b",
+			wantRouteName: RouteRepoIsAncestor,
+			wantVars:      map[string]string{"RepoPath": repoPath, "CommitIDA": "a", "CommitIDB": "b"},
+		},
+		{
+			path:          "/" + encodedRepoPath + "/.ahead-behind/a/b",
+			wantRouteName: RouteRepoAheadBehind,
+			wantVars:      map[string]string{"RepoPath": repoPath, "Base": "a", "Head": "b"},
+		},
 	}
 
 	for _, test := range tests {
@@ -8,7 +8,7 @@ func (r *repository) Search(at vcs.CommitID, opt vcs.SearchOptions) ([]*vcs.Sear
 		return nil, err
 	}
 
-	req, err := r.client.NewRequest("GET", url.String(), nil)
+	req, err := r.newRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
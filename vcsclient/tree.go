@@ -1,8 +1,10 @@
 package vcsclient
 
 import (
+	"encoding/json"
 	"os"
 	"time"
+	"unicode/utf8"
 )
 
 // Stat returns the FileInfo structure describing the tree entry.
@@ -11,7 +13,7 @@ func (e *TreeEntry) Stat() (os.FileInfo, error) {
 	// have to rename its fields that conflict with FileInfo's method names
 	// (Name and Size).
 
-	var mode os.FileMode
+	mode := e.Mode
 	switch e.Type {
 	case DirEntry:
 		mode |= os.ModeDir
@@ -55,3 +57,100 @@ func (v TreeEntriesByTypeByName) Less(i, j int) bool {
 	}
 	return v[i].Name < v[j].Name
 }
+
+// Values of TreeEntry.Encoding.
+const (
+	UTF8Encoding   = "utf-8"
+	Base64Encoding = "base64"
+)
+
+// setEncoding sets e.Encoding based on whether e.Contents is UTF-8
+// text.
+func (e *TreeEntry) setEncoding() {
+	if utf8.Valid(e.Contents) {
+		e.Encoding = UTF8Encoding
+	} else {
+		e.Encoding = Base64Encoding
+	}
+}
+
+// treeEntry is TreeEntry without its MarshalJSON and UnmarshalJSON
+// methods.
+type treeEntry TreeEntry
+
+// treeEntryJSON is the JSON representation of a TreeEntry. Its
+// Contents field overrides TreeEntry's, so that UTF-8 contents are
+// a string (instead of being base64-encoded like other []byte
+// values).
+type treeEntryJSON struct {
+	*treeEntry
+	Contents interface{} `json:"contents,omitempty"`
+}
+
+func (e *TreeEntry) toJSON() treeEntryJSON {
+	v := treeEntryJSON{treeEntry: (*treeEntry)(e)}
+	if len(e.Contents) > 0 {
+		if e.Encoding == UTF8Encoding {
+			v.Contents = string(e.Contents)
+		} else {
+			v.Contents = e.Contents
+		}
+	}
+	return v
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *TreeEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *TreeEntry) UnmarshalJSON(data []byte) error {
+	var v struct {
+		*treeEntry
+		Contents json.RawMessage `json:"contents,omitempty"`
+	}
+	v.treeEntry = (*treeEntry)(e)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	e.Contents = nil
+	if len(v.Contents) == 0 || string(v.Contents) == "null" {
+		return nil
+	}
+	if e.Encoding == UTF8Encoding {
+		var s string
+		if err := json.Unmarshal(v.Contents, &s); err != nil {
+			return err
+		}
+		e.Contents = []byte(s)
+		return nil
+	}
+	return json.Unmarshal(v.Contents, &e.Contents)
+}
+
+// MarshalJSON implements json.Marshaler. It is necessary because
+// FileWithRange would otherwise use the MarshalJSON method of its
+// embedded TreeEntry, omitting the FileRange fields.
+func (f *FileWithRange) MarshalJSON() ([]byte, error) {
+	var v struct {
+		treeEntryJSON
+		FileRange
+	}
+	if f.TreeEntry != nil {
+		v.treeEntryJSON = f.TreeEntry.toJSON()
+	}
+	v.FileRange = f.FileRange
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *FileWithRange) UnmarshalJSON(data []byte) error {
+	if f.TreeEntry == nil {
+		f.TreeEntry = &TreeEntry{}
+	}
+	if err := json.Unmarshal(data, f.TreeEntry); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &f.FileRange)
+}
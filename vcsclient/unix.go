@@ -0,0 +1,50 @@
+package vcsclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// unixSocketScheme is the scheme of base URLs (such as
+// "unix:/var/run/vcsstore.sock") that refer to a server listening on
+// a Unix domain socket.
+const unixSocketScheme = "unix:"
+
+// NewUnixSocket returns a new vcsstore API client that communicates
+// with an HTTP server listening on the Unix domain socket at
+// socketPath (such as one started with `vcsstore serve -unix`).
+func NewUnixSocket(socketPath string) *Client {
+	return New(&url.URL{Scheme: "http", Host: "unix", Path: "/"}, UnixSocketHTTPClient(socketPath))
+}
+
+// UnixSocketHTTPClient returns an HTTP client that sends all
+// requests over the Unix domain socket at socketPath, regardless of
+// the host in their URL.
+func UnixSocketHTTPClient(socketPath string) *http.Client {
+	var d net.Dialer
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// NewFromURL returns a new vcsstore API client for the server at
+// urlStr, which is either a base URL (such as
+// "http://localhost:9090") or "unix:" followed by the path of a Unix
+// domain socket (such as "unix:/var/run/vcsstore.sock").
+func NewFromURL(urlStr string) (*Client, error) {
+	if strings.HasPrefix(urlStr, unixSocketScheme) {
+		return NewUnixSocket(strings.TrimPrefix(urlStr, unixSocketScheme)), nil
+	}
+	base, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	return New(base, nil), nil
+}
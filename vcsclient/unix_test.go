@@ -0,0 +1,59 @@
+package vcsclient
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/go-vcs/vcs"
+)
+
+func TestNewFromURL_unixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcsclient-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "vcsstore.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := NewFromURL("unix:" + socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo_, _ := c.Repository("a.b/c")
+	repo := repo_.(*repository)
+
+	want := vcs.CommitID("abcd")
+	smux := http.NewServeMux()
+	smux.HandleFunc(urlPath(t, RouteRepoBranch, repo, map[string]string{"Branch": "b"}), func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, urlPath(t, RouteRepoCommit, repo, map[string]string{"CommitID": string(want)}), http.StatusFound)
+	})
+	go http.Serve(ln, smux)
+
+	commitID, err := repo.ResolveBranch("b")
+	if err != nil {
+		t.Fatalf("ResolveBranch over Unix socket: %s", err)
+	}
+	if commitID != want {
+		t.Errorf("got commitID %q, want %q", commitID, want)
+	}
+}
+
+func TestNewFromURL_http(t *testing.T) {
+	c, err := NewFromURL("http://localhost:9090/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.BaseURL.String(), "http://localhost:9090/"; got != want {
+		t.Errorf("got base URL %q, want %q", got, want)
+	}
+}
@@ -19,6 +19,9 @@ import proto "github.com/gogo/protobuf/proto"
 
 // discarding unused import gogoproto "github.com/gogo/protobuf/gogoproto/gogo.pb"
 import pbtypes "sourcegraph.com/sqs/pbtypes"
+import vcs "sourcegraph.com/sourcegraph/go-vcs/vcs"
+
+import os "os"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -64,7 +67,7 @@ func (*FileRange) ProtoMessage()    {}
 
 // GetFileOptions specifies options for GetFileWithOptions.
 type GetFileOptions struct {
-	// line or byte range to fetch (can't set both line *and* byte range)
+	// line or byte range to fetch (if both are set, the line range is used)
 	FileRange `protobuf:"bytes,1,opt,name=file_range,embedded=file_range" json:"file_range"`
 	// EntireFile is whether the entire file contents should be returned. If true,
 	// Start/EndLine and Start/EndBytes are ignored.
@@ -85,6 +88,13 @@ type GetFileOptions struct {
 	// RecurseSingleSubfolder only applies if the returned entry is a directory.
 	// It will recursively find and include all sub-directories with a single sub-directory.
 	RecurseSingleSubfolder bool `protobuf:"varint,6,opt,name=recurse_single_subfolder,proto3" json:"recurse_single_subfolder,omitempty" url:",omitempty"`
+	// Glob only applies if the returned entry is a directory. If set,
+	// only entries whose path (relative to the directory) matches it
+	// are returned. See MatchGlob for the supported syntax.
+	Glob string `protobuf:"bytes,7,opt,name=glob,proto3" json:"glob,omitempty" url:"glob,omitempty" schema:"glob"`
+	// LastCommit is whether to include the last commit that changed
+	// the returned entry and each of its (returned) sub-entries.
+	LastCommit bool `protobuf:"varint,8,opt,name=last_commit,proto3" json:"last_commit,omitempty" url:"lastCommit,omitempty" schema:"lastCommit"`
 }
 
 func (m *GetFileOptions) Reset()         { *m = GetFileOptions{} }
@@ -98,6 +108,39 @@ type TreeEntry struct {
 	ModTime  pbtypes.Timestamp `protobuf:"bytes,4,opt,name=mod_time" json:"mod_time"`
 	Contents []byte            `protobuf:"bytes,5,opt,name=contents,proto3" json:"contents,omitempty"`
 	Entries  []*TreeEntry      `protobuf:"bytes,6,rep,name=entries" json:"entries,omitempty"`
+	// LFS is whether the file is a Git LFS pointer. If so, Contents
+	// holds the pointer text, not the real file contents.
+	LFS bool `protobuf:"varint,7,opt,name=lfs,proto3" json:"lfs,omitempty"`
+	// LFSOID is the object ID (e.g., "sha256:...") declared in the Git
+	// LFS pointer.
+	LFSOID string `protobuf:"bytes,8,opt,name=lfs_oid,proto3" json:"lfs_oid,omitempty"`
+	// LFSSize is the size in bytes of the real file contents, as
+	// declared in the Git LFS pointer.
+	LFSSize int64 `protobuf:"varint,9,opt,name=lfs_size,proto3" json:"lfs_size,omitempty"`
+	// Mode is the file mode and permission bits (e.g., 0644 for a
+	// regular file, 0755 for an executable file, or os.ModeSymlink
+	// for a symlink).
+	Mode os.FileMode `protobuf:"varint,10,opt,name=mode,proto3,casttype=os.FileMode" json:"mode,omitempty"`
+	// ContentType is the MIME type of the file's contents (e.g.,
+	// "image/png" or "text/plain; charset=utf-8"). It is only set for
+	// files.
+	ContentType string `protobuf:"bytes,11,opt,name=content_type,proto3" json:"content_type,omitempty"`
+	// SymlinkTarget is the path that the symlink points to. It is
+	// only set for symlinks.
+	SymlinkTarget string `protobuf:"bytes,12,opt,name=symlink_target,proto3" json:"symlink_target,omitempty"`
+	// Encoding describes Contents: "utf-8" if they are UTF-8 text
+	// (and are a string in JSON), or "base64" if they are binary (and
+	// are base64-encoded in JSON). It is only set for files whose
+	// contents are returned.
+	Encoding string `protobuf:"bytes,13,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	// ContentsOmitted is whether Contents were omitted because the
+	// file is larger than the server's limit. Request the contents
+	// with GetFileOptions.EntireFile or a byte or line range.
+	ContentsOmitted bool `protobuf:"varint,14,opt,name=contents_omitted,proto3" json:"contents_omitted,omitempty"`
+	// LastCommit is the most recent commit that changed the entry (or,
+	// for a directory, any file in it). It is only set if requested
+	// with GetFileOptions.LastCommit.
+	LastCommit *vcs.Commit `protobuf:"bytes,15,opt,name=last_commit" json:"last_commit,omitempty"`
 }
 
 func (m *TreeEntry) Reset()         { *m = TreeEntry{} }
@@ -118,6 +161,13 @@ func (m *TreeEntry) GetEntries() []*TreeEntry {
 	return nil
 }
 
+func (m *TreeEntry) GetLastCommit() *vcs.Commit {
+	if m != nil {
+		return m.LastCommit
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("vcsclient.TreeEntryType", name, value)
 }